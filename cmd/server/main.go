@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"log"
 	"net/http"
 	"os"
@@ -10,61 +11,377 @@ import (
 	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/soroushbar/vantage/internal/anomaly"
+	"github.com/soroushbar/vantage/internal/archive"
 	"github.com/soroushbar/vantage/internal/audit"
+	"github.com/soroushbar/vantage/internal/clickhouse"
 	"github.com/soroushbar/vantage/internal/config"
+	"github.com/soroushbar/vantage/internal/encryption"
+	"github.com/soroushbar/vantage/internal/incident"
+	"github.com/soroushbar/vantage/internal/latency"
+	"github.com/soroushbar/vantage/internal/maintenance"
+	"github.com/soroushbar/vantage/internal/models"
+	"github.com/soroushbar/vantage/internal/notify"
+	"github.com/soroushbar/vantage/internal/preflight"
+	"github.com/soroushbar/vantage/internal/privacy"
+	"github.com/soroushbar/vantage/internal/provider"
+	"github.com/soroushbar/vantage/internal/quota"
+	"github.com/soroushbar/vantage/internal/redis"
+	"github.com/soroushbar/vantage/internal/reports"
+	"github.com/soroushbar/vantage/internal/secrets"
 	"github.com/soroushbar/vantage/internal/server"
+	"github.com/soroushbar/vantage/internal/settings"
 	"github.com/soroushbar/vantage/internal/store"
+	"github.com/soroushbar/vantage/internal/telemetry"
+	"github.com/soroushbar/vantage/internal/trust"
+	"github.com/soroushbar/vantage/internal/vault"
 	pkgmiddleware "github.com/soroushbar/vantage/pkg/middleware"
 )
 
+// checkCohereKey fails when the Cohere upstream is used without an API key.
+// The mock provider answers locally and needs none.
+func checkCohereKey(cfg *config.Config, cohereKey string) error {
+	if cohereKey == "" && cfg.Provider != "mock" {
+		return errors.New("COHERE_API_KEY environment variable (or secrets.cohere_api_key) is required")
+	}
+	return nil
+}
+
 func main() {
 	if err := godotenv.Load(); err != nil {
 		log.Println("No .env file found, using system environment variables")
 	}
 
 	cohereKey := os.Getenv("COHERE_API_KEY")
-	if cohereKey == "" {
-		log.Fatal("COHERE_API_KEY environment variable is required")
+
+	dbPath := os.Getenv("DATABASE_URL")
+	if dbPath == "" {
+		dbPath = "./audit.db"
+	}
+	if os.Getenv("VANTAGE_STRICT_STARTUP") == "true" {
+		// Same checks as "vantage validate": refuse to start with any problem
+		results := preflight.Run(context.Background(), preflight.Options{ConfigPath: "config.yaml", DBPath: dbPath, CohereKey: cohereKey})
+		for _, r := range results {
+			for _, p := range r.Problems {
+				log.Printf("Startup check %s: %s", r.Name, p)
+			}
+		}
+		if preflight.Failed(results) {
+			log.Fatal("strict startup: refusing to start, see the problems above")
+		}
 	}
 
 	// 1. Load Governance Config
 	cfg, err := config.LoadConfig("config.yaml")
+	if errors.Is(err, os.ErrNotExist) {
+		log.Printf("No config.yaml found, using defaults")
+		if cfg, err = config.LoadDefaults(); err != nil {
+			log.Fatalf("invalid config overrides: %v", err)
+		}
+	} else if err != nil {
+		log.Fatalf("invalid config.yaml: %v", err)
+	} else if env := os.Getenv(config.EnvironmentVar); env != "" {
+		log.Printf("Applied the %s overlay", config.OverlayPath("config.yaml", env))
+	}
+	keys, err := secrets.New(context.Background(), cfg.Secrets)
 	if err != nil {
-		log.Printf("Failed to load config.yaml, using defaults: %v", err)
-		cfg = &config.Config{ForbiddenKeywords: []string{}}
+		log.Fatalf("failed to initialize secrets: %v", err)
+	}
+	if keys != nil {
+		if err := keys.Load(context.Background()); err != nil {
+			log.Fatalf("failed to read secrets: %v", err)
+		}
+		if keys.Has(secrets.Cohere) {
+			cohereKey = keys.Value(secrets.Cohere)
+		}
+		log.Printf("Reading provider keys from %s", cfg.Secrets.Source)
+	}
+	if err := checkCohereKey(cfg, cohereKey); err != nil {
+		log.Fatal(err)
 	}
 
 	// 2. Initialize Infrastructure
-	dbPath := os.Getenv("DATABASE_URL")
-	if dbPath == "" {
-		dbPath = "./audit.db"
-	}
 	st, err := store.NewStore(dbPath)
 	if err != nil {
 		log.Fatalf("failed to initialize store: %v", err)
 	}
 	defer st.Close()
-
-	// 3. Initialize Audit Worker
-	auditChan := make(chan pkgmiddleware.Interaction, 100)
-	worker := audit.NewWorker(auditChan, st, cohereKey)
+	if err := st.SetBodyCompression(cfg.Storage.BodyCompression); err != nil {
+		log.Fatalf("failed to configure store: %v", err)
+	}
+	if e := cfg.Storage.Encryption; e.Enabled {
+		key, err := encryption.LoadKey(context.Background(), e)
+		if err != nil {
+			log.Fatalf("failed to load body encryption key: %v", err)
+		}
+		keyring, err := encryption.NewKeyring(e.KeyID, key)
+		if err != nil {
+			log.Fatalf("failed to load body encryption key: %v", err)
+		}
+		st.SetBodyCipher(keyring)
+	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
+	if cfg.Storage.SearchIndex {
+		st.SetSearchIndex(true)
+		go func() {
+			n, err := st.BackfillSearchIndex(ctx)
+			if err != nil && ctx.Err() == nil {
+				log.Printf("Failed to index existing interactions for search: %v", err)
+			} else if n > 0 {
+				log.Printf("Indexed %d existing interactions for search", n)
+			}
+		}()
+	}
+	if keys != nil {
+		keys.Start(ctx)
+	}
+
+	// 3. Initialize Notifications & Audit Worker
+	dispatcher := notify.NewDispatcher(cfg.Webhooks, st)
+	var incidents *incident.Tracker
+	if cfg.Incidents.Enabled {
+		incidents, err = incident.NewTracker(st, cfg.Incidents)
+		if err != nil {
+			log.Fatalf("failed to initialize incident tracking: %v", err)
+		}
+		dispatcher.AddObserver(incidents)
+	}
+	dispatcher.Start(ctx)
+
+	sinks, err := audit.NewSinks(cfg.Sinks)
+	if err != nil {
+		log.Fatalf("failed to initialize audit sinks: %v", err)
+	}
+
+	auditChan := make(chan pkgmiddleware.Interaction, cfg.AuditQueue.Size)
+	worker := audit.NewWorker(auditChan, st, cohereKey, dispatcher, cfg.Webhooks.SafetyThreshold, sinks...)
+	worker.SetPricing(cfg.Pricing)
+	telemetry.ConfigureHistograms(cfg.Metrics.LatencyBuckets, cfg.Metrics.TokenBuckets, cfg.Metrics.Exemplars)
+	worker.SetMetricLabels(cfg.Metrics)
+	if keys.Has(secrets.Cohere) {
+		worker.SetAPIKeySource(keys.Source(secrets.Cohere))
+	}
+	var slos *latency.SLOTracker
+	if len(cfg.Latency.SLOs) > 0 {
+		slos = latency.NewSLOTracker(cfg.Latency)
+		slos.Start(ctx)
+	}
+	worker.SetLatency(cfg.Latency.SlowThreshold, slos)
+	worker.SetResponseSafety(cfg.ResponseSafety.Enabled || cfg.ResponseSafety.Enforce, cfg.ResponseSafety.Threshold)
+	worker.SetQuarantine(cfg.Quarantine.Enabled)
+	if cfg.Moderation.Enabled {
+		cohere, mistral := func() string { return cohereKey }, func() string { return cfg.Providers.Mistral.APIKey }
+		if keys.Has(secrets.Cohere) {
+			cohere = keys.Source(secrets.Cohere)
+		}
+		if keys.Has(secrets.Mistral) {
+			mistral = keys.Source(secrets.Mistral)
+		}
+		worker.SetModeration(audit.NewModerator(cfg, cohere, mistral), cfg.Moderation.Thresholds)
+	}
+	var analytics *clickhouse.Store
+	if ch := cfg.ClickHouse; ch.Enabled {
+		if p := os.Getenv("VANTAGE_CLICKHOUSE_PASSWORD"); p != "" {
+			ch.Password = p
+		}
+		client, err := clickhouse.NewClient(ch.URL, ch.Database, ch.Username, ch.Password, ch.Timeout)
+		if err != nil {
+			log.Fatalf("failed to initialize clickhouse: %v", err)
+		}
+		analytics = clickhouse.NewStore(client, ch)
+		if err := analytics.InitSchema(ctx); err != nil {
+			log.Fatalf("failed to initialize clickhouse schema: %v", err)
+		}
+		analytics.Start(ctx)
+		worker.SetAnalytics(analytics)
+		log.Printf("Writing interactions to clickhouse at %s", client.Addr())
+	}
+	var deadLetters *audit.DeadLetters
+	if cfg.Storage.DeadLetterFile != "" {
+		deadLetters = audit.NewDeadLetters(cfg.Storage.DeadLetterFile)
+		deadLetters.SetCipher(st.Cipher())
+	}
+	worker.SetWriteRetries(cfg.Storage.WriteRetries, cfg.Storage.RetryBackoff, deadLetters)
+	if cfg.Trust.Enabled {
+		var skip []string
+		for tier, p := range cfg.Trust.Tiers {
+			if p.SkipSafetyAudit {
+				skip = append(skip, tier)
+			}
+		}
+		worker.SkipSafetyAudit(skip...)
+	}
 	worker.Start(ctx)
+	worker.Backfill(ctx)
 
-	// 4. Initialize Server
-	srv := server.NewServer(st, cfg, cohereKey, auditChan)
+	// 4. Start Background Services
+	registry, err := models.NewRegistry(st, cfg.Models.Enforce)
+	if err != nil {
+		log.Fatalf("failed to load model registry: %v", err)
+	}
+	if err := registry.Seed(cfg.AllowedModels); err != nil {
+		log.Fatalf("failed to seed model registry: %v", err)
+	}
+
+	runtimeSettings, err := settings.NewManager(st, cfg)
+	if err != nil {
+		log.Fatalf("failed to load runtime settings: %v", err)
+	}
+	runtimeSettings.Start(ctx)
 
-	httpServer := &http.Server{
-		Addr:    ":8080",
-		Handler: srv.Router,
+	reporter := reports.NewReporter(st, registry, cfg)
+	reporter.Start(ctx)
+	if cfg.Reports.Compliance.Enabled {
+		reports.NewComplianceMailer(st, cfg.Reports.Compliance, cfg.Webhooks.SMTP).Start(ctx)
+	}
+	status := provider.NewStatusMonitor(cfg.ProviderStatus)
+	status.SetNotifier(dispatcher)
+	status.Start(ctx)
+	privacy.NewAccessLogPruner(st, cfg.AccessLog.Retention).Start(ctx)
+	if cfg.Maintenance.Enabled {
+		maintenance.NewScheduler(st, cfg.Maintenance).Start(ctx)
+	}
+	var anomalies *anomaly.Analyzer
+	if cfg.Anomalies.Enabled {
+		anomalies = anomaly.NewAnalyzer(st, cfg.Anomalies, dispatcher)
+		anomalies.Start(ctx)
+	}
+
+	// 5. Initialize Server
+	svc := server.Services{
+		Models:      registry,
+		Reporter:    reporter,
+		Status:      status,
+		Notifier:    dispatcher,
+		Incidents:   incidents,
+		SLOs:        slos,
+		Anomalies:   anomalies,
+		Secrets:     keys,
+		Settings:    runtimeSettings,
+		ClickHouse:  analytics,
+		DeadLetters: deadLetters,
+	}
+	if cfg.ClickHouse.Stats {
+		svc.Analytics = analytics
+	}
+	var quotaStore quota.Store = st
+	if r := cfg.Redis; r.Enabled {
+		if u := os.Getenv("REDIS_URL"); u != "" {
+			r.URL = u
+		}
+		client, err := redis.NewClient(r.URL, r.KeyPrefix, r.Timeout, r.PoolSize)
+		if err != nil {
+			log.Fatalf("failed to initialize redis: %v", err)
+		}
+		if err := client.Ping(ctx); err != nil {
+			log.Fatalf("failed to reach redis: %v", err)
+		}
+		defer client.Close()
+		if r.RateLimit {
+			svc.Limiter = redis.NewLimiter(client, cfg.RateLimit.Requests, cfg.RateLimit.Window)
+		}
+		if r.Quotas {
+			quotaStore = redis.NewQuotaStore(client)
+		}
+		if r.Cache {
+			svc.Cache = redis.NewCache(client)
+		}
+		log.Printf("Sharing state through redis at %s", client.Addr())
+	}
+	if cfg.Quotas.Enabled {
+		svc.Quotas = quota.NewEnforcer(cfg.Quotas, quotaStore)
+	}
+	if cfg.Archive.Enabled {
+		objects, err := archive.NewObjectStore(ctx, cfg.Archive.ObjectStoreConfig)
+		if err != nil {
+			log.Fatalf("failed to initialize archive storage: %v", err)
+		}
+		svc.Archive = archive.NewArchiver(st, objects, cfg.Archive)
+		st.SetArchiveReader(svc.Archive.Bodies)
+		st.SetArchiveDeleter(svc.Archive.Delete)
+		svc.Archive.Start(ctx)
+	}
+	if cfg.LogExport.Enabled {
+		objects, err := archive.NewObjectStore(ctx, cfg.LogExport.ObjectStoreConfig)
+		if err != nil {
+			log.Fatalf("failed to initialize log export storage: %v", err)
+		}
+		svc.Exports = archive.NewExporter(st, objects, cfg.LogExport)
+		svc.Exports.Start(ctx)
+	}
+	if cfg.Trust.Enabled {
+		scorer, err := trust.NewScorer(st, cfg.Trust)
+		if err != nil {
+			log.Fatalf("failed to initialize trust scoring: %v", err)
+		}
+		scorer.Start(ctx)
+		svc.Trust = scorer
+	}
+	if cfg.Redaction.Mode == "tokenize" {
+		v, err := vault.New(st, os.Getenv("VANTAGE_VAULT_KEY"))
+		if err != nil {
+			log.Fatalf("failed to initialize PII vault: %v", err)
+		}
+		svc.Vault = v
+	}
+	if token := os.Getenv("VANTAGE_ADMIN_TOKEN"); token != "" {
+		if cfg.Admin.Tokens == nil {
+			cfg.Admin.Tokens = map[string]string{}
+		}
+		cfg.Admin.Tokens["admin"] = token
+	}
+	if key := os.Getenv("GEMINI_API_KEY"); key != "" {
+		cfg.Providers.Gemini.APIKey = key
+	}
+	if key := os.Getenv("MISTRAL_API_KEY"); key != "" {
+		cfg.Providers.Mistral.APIKey = key
+	}
+	if key := os.Getenv("GROQ_API_KEY"); key != "" {
+		cfg.Providers.Groq.APIKey = key
+	}
+	if key := os.Getenv("OPENAI_API_KEY"); key != "" {
+		cfg.Moderation.APIKey = key
+	}
+	if secret := os.Getenv("VANTAGE_JWT_SECRET"); secret != "" {
+		cfg.Identity.JWT.Secret = secret
+	}
+	if os.Getenv("VANTAGE_READ_ONLY") == "true" {
+		cfg.ReadOnly.Enabled = true
+	}
+	if cfg.ReadOnly.Enabled {
+		log.Println("Starting in read-only mode: proxying is paused and admin changes are frozen")
+	}
+	if cfg.Provider == "mock" {
+		log.Println("Using the mock provider: Cohere requests are answered locally with canned responses")
+	}
+	if os.Getenv("VANTAGE_CHAOS") == "true" {
+		cfg.Chaos.Enabled = true
+	}
+	if cfg.Chaos.Enabled {
+		log.Printf("WARNING: chaos mode is on, injecting upstream faults (%.0f%% delayed, %.0f%% 429, %.0f%% %d); never use it in production",
+			cfg.Chaos.LatencyRate*100, cfg.Chaos.RateLimitRate*100, cfg.Chaos.ErrorRate*100, cfg.Chaos.ErrorStatus)
+	}
+	if len(cfg.Admin.Tokens) == 0 {
+		log.Println("WARNING: no admin tokens configured, /api is unauthenticated")
+	}
+	srv := server.NewServer(st, cfg, cohereKey, auditChan, svc)
+
+	if port := os.Getenv("PORT"); port != "" {
+		cfg.Server.Addr = ":" + port
+	}
+	listener, err := server.NewListener(cfg.Server, srv.Router)
+	if err != nil {
+		log.Fatalf("failed to configure listener: %v", err)
+	}
+	if cfg.Server.GRPC.Enabled {
+		listener.EnableGRPC(srv.NewGRPCServer)
 	}
 
-	// 5. Lifecycle Management
+	// 6. Lifecycle Management
 	go func() {
-		log.Printf("Vantage Gateway listening on %s", httpServer.Addr)
-		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		if err := listener.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("listen: %s\n", err)
 		}
 	}()
@@ -77,7 +394,7 @@ func main() {
 	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 15*time.Second)
 	defer shutdownCancel()
 
-	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+	if err := listener.Shutdown(shutdownCtx); err != nil {
 		log.Fatalf("Server forced to shutdown: %v", err)
 	}
 
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/soroushbar/vantage/internal/config"
+)
+
+func TestCheckCohereKey(t *testing.T) {
+	mock := config.Default()
+	mock.Provider = "mock"
+	if err := checkCohereKey(mock, ""); err != nil {
+		t.Errorf("mock provider without a key: %v", err)
+	}
+
+	cohere := config.Default()
+	if err := checkCohereKey(cohere, ""); err == nil {
+		t.Error("cohere provider without a key started")
+	}
+	if err := checkCohereKey(cohere, "key"); err != nil {
+		t.Errorf("cohere provider with a key: %v", err)
+	}
+}
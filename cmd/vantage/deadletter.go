@@ -0,0 +1,171 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/soroushbar/vantage/internal/audit"
+	"github.com/soroushbar/vantage/internal/store"
+)
+
+const deadLetterUsage = `Usage: vantage deadletter <list|replay> [flags]
+
+  list             show the store writes the gateway gave up on
+  replay           write them to the database and drop them from the file
+
+Replay while the gateway is stopped: it keeps the head of the audit hash
+chain in memory, and rows written around it would break the chain.
+`
+
+func runDeadLetter(args []string) error {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, deadLetterUsage)
+		return errors.New("missing deadletter command")
+	}
+	switch args[0] {
+	case "list":
+		return runDeadLetterList(args[1:])
+	case "replay":
+		return runDeadLetterReplay(args[1:])
+	case "-h", "-help", "--help", "help":
+		fmt.Print(deadLetterUsage)
+		return nil
+	}
+	fmt.Fprint(os.Stderr, deadLetterUsage)
+	return fmt.Errorf("unknown deadletter command %q", args[0])
+}
+
+// deadLetterFlags are shared by list and replay.
+type deadLetterFlags struct {
+	fs         *flag.FlagSet
+	configPath *string
+	file       *string
+}
+
+func newDeadLetterFlags(name string) deadLetterFlags {
+	fs := flag.NewFlagSet("deadletter "+name, flag.ContinueOnError)
+	return deadLetterFlags{
+		fs:         fs,
+		configPath: fs.String("config", "config.yaml", "config naming the dead-letter file and the storage settings"),
+		file:       fs.String("file", "", "dead-letter file (default storage.dead_letter_file of the config)"),
+	}
+}
+
+// letters parses args and reads the dead-letter file.
+func (f deadLetterFlags) letters(args []string) (string, []audit.DeadLetter, error) {
+	if err := f.fs.Parse(args); err != nil {
+		return "", nil, err
+	}
+	path := *f.file
+	if path == "" {
+		cfg, err := loadConfig(*f.configPath)
+		if err != nil {
+			return "", nil, err
+		}
+		if path = cfg.Storage.DeadLetterFile; path == "" {
+			return "", nil, errors.New("no dead-letter file configured; pass -file")
+		}
+	}
+	letters, err := audit.ReadDeadLetters(path)
+	return path, letters, err
+}
+
+func runDeadLetterList(args []string) error {
+	f := newDeadLetterFlags("list")
+	path, letters, err := f.letters(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if len(letters) == 0 {
+		fmt.Printf("No dead letters in %s\n", path)
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "FAILED_AT\tKIND\tTIMESTAMP\tUSER_ID\tDETAIL\tERROR")
+	for _, dl := range letters {
+		var ts time.Time
+		var user, detail string
+		if r := dl.Interaction; r != nil {
+			ts, user, detail = r.Timestamp, r.UserID, r.Method+" "+r.Path
+		} else {
+			ts, user, detail = dl.Usage.Time, dl.Usage.UserID, dl.Usage.Model
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", dl.FailedAt.Format(time.RFC3339), dl.Kind(),
+			ts.UTC().Format(time.RFC3339), user, detail, dl.Error)
+	}
+	return tw.Flush()
+}
+
+func runDeadLetterReplay(args []string) error {
+	f := newDeadLetterFlags("replay")
+	dbPath := f.fs.String("db", envOr("DATABASE_URL", "./audit.db"), "audit database to write to (env DATABASE_URL)")
+	path, letters, err := f.letters(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if len(letters) == 0 {
+		fmt.Printf("No dead letters in %s\n", path)
+		return nil
+	}
+
+	st, err := openStore(*dbPath, *f.configPath)
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	var failed []audit.DeadLetter
+	replayed := map[string]int{}
+	for _, dl := range letters {
+		// Sealed bodies are opened with the key of the store's config
+		open, err := dl.Unseal(st.Cipher())
+		if err == nil && open.Interaction != nil {
+			_, err = st.LogInteraction(*open.Interaction)
+		} else if err == nil {
+			err = st.RecordUsage(*dl.Usage)
+		}
+		if err != nil {
+			dl.Error = err.Error()
+			failed = append(failed, dl)
+			continue
+		}
+		replayed[dl.Kind()]++
+	}
+
+	// Only the letters that failed again are kept, still sealed
+	if err := rewriteDeadLetters(path, failed, st.Cipher()); err != nil {
+		return fmt.Errorf("rewrite %s: %w", path, err)
+	}
+	fmt.Printf("Replayed %d interactions and %d usage samples from %s\n", replayed["interaction"], replayed["usage"], path)
+	if len(failed) > 0 {
+		return fmt.Errorf("%d dead letters failed again and were kept, last error: %s", len(failed), failed[len(failed)-1].Error)
+	}
+	return nil
+}
+
+// rewriteDeadLetters replaces the file with letters, sealing their bodies
+// with c when it is set, or removes it when there are none left.
+func rewriteDeadLetters(path string, letters []audit.DeadLetter, c store.BodyCipher) error {
+	if len(letters) == 0 {
+		return os.Remove(path)
+	}
+	tmp := path + ".tmp"
+	os.Remove(tmp)
+	dl := audit.NewDeadLetters(tmp)
+	dl.SetCipher(c)
+	for _, l := range letters {
+		if err := dl.Append(l); err != nil {
+			return err
+		}
+	}
+	return os.Rename(tmp, path)
+}
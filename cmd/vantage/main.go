@@ -0,0 +1,47 @@
+// Command vantage is the operator CLI for a Vantage gateway.
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+const usage = `Usage: vantage <command> [flags]
+
+Commands:
+  query       search the interaction log
+  policy      lint the configured policies or test them against sample requests
+  deadletter  list or replay store writes the gateway gave up on
+  validate    check the config, database and provider credentials before starting
+
+Run "vantage <command> -h" for the flags of a command.
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "query":
+		err = runQuery(os.Args[2:])
+	case "policy":
+		err = runPolicy(os.Args[2:])
+	case "deadletter":
+		err = runDeadLetter(os.Args[2:])
+	case "validate":
+		err = runValidate(os.Args[2:])
+	case "-h", "-help", "--help", "help":
+		fmt.Print(usage)
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "vantage: unknown command %q\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "vantage:", err)
+		os.Exit(1)
+	}
+}
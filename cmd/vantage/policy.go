@@ -0,0 +1,511 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/soroushbar/vantage/internal/config"
+	"github.com/soroushbar/vantage/internal/models"
+	"github.com/soroushbar/vantage/internal/server"
+	"github.com/soroushbar/vantage/internal/store"
+	pkgmiddleware "github.com/soroushbar/vantage/pkg/middleware"
+	"gopkg.in/yaml.v3"
+)
+
+const policyUsage = `Usage: vantage policy <lint|test|packs> [flags]
+
+  lint             validate config.yaml and warn about policies that cannot work as intended
+  test <dir>       run the sample requests in dir through the configured policies
+  packs            list the preset policy packs that policy_packs can enable
+`
+
+// Outcomes a policy case can expect.
+const (
+	outcomeAllowed  = "allowed"
+	outcomeRedacted = "redacted"
+	outcomeBlocked  = "blocked"
+)
+
+// policyCase is one sample request, read from a YAML file. Prompt is sent
+// as {"message": ..., "model": ...}; Body replaces that with a raw body.
+// Response is what the stubbed upstream answers when the request gets
+// through. Expect.Rules must all be among the rules that fired.
+type policyCase struct {
+	Description string            `yaml:"description"`
+	Method      string            `yaml:"method"`
+	Path        string            `yaml:"path"`
+	User        string            `yaml:"user"`
+	Headers     map[string]string `yaml:"headers"`
+	Prompt      string            `yaml:"prompt"`
+	Model       string            `yaml:"model"`
+	Body        string            `yaml:"body"`
+	Response    string            `yaml:"response"`
+	Expect      struct {
+		Outcome string   `yaml:"outcome"`
+		Rules   []string `yaml:"rules"`
+	} `yaml:"expect"`
+}
+
+// policyResult is what a case observed.
+type policyResult struct {
+	Outcome string
+	Rules   []string
+	Status  int
+}
+
+func runPolicy(args []string) error {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, policyUsage)
+		return errors.New("missing policy command")
+	}
+	switch args[0] {
+	case "lint":
+		return runPolicyLint(args[1:])
+	case "test":
+		return runPolicyTest(args[1:])
+	case "packs":
+		return runPolicyPacks(args[1:])
+	case "-h", "-help", "--help", "help":
+		fmt.Print(policyUsage)
+		return nil
+	}
+	fmt.Fprint(os.Stderr, policyUsage)
+	return fmt.Errorf("unknown policy command %q", args[0])
+}
+
+func runPolicyLint(args []string) error {
+	fs := flag.NewFlagSet("policy lint", flag.ContinueOnError)
+	configPath := fs.String("config", "config.yaml", "config to lint")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		return fmt.Errorf("%s: %w", *configPath, err)
+	}
+
+	warnings := lintPolicies(cfg)
+	for _, w := range warnings {
+		fmt.Printf("%s: %s\n", *configPath, w)
+	}
+	if len(warnings) > 0 {
+		return fmt.Errorf("%d policy warning(s)", len(warnings))
+	}
+	fmt.Printf("%s: ok (%d forbidden keywords, %d custom redaction patterns, %d header rules)\n",
+		*configPath, len(cfg.ForbiddenKeywords), len(cfg.Redaction.Patterns), len(cfg.Headers.Rules))
+	return nil
+}
+
+// lintPolicies reports settings that load fine but cannot do what was
+// meant: empty or shadowed keywords, patterns that match everything and
+// header rules and governance profiles that never apply.
+func lintPolicies(cfg *config.Config) []string {
+	var warnings []string
+	seen := map[string]bool{}
+	for i, kw := range cfg.ForbiddenKeywords {
+		lower := strings.ToLower(strings.TrimSpace(kw))
+		switch {
+		case lower == "":
+			warnings = append(warnings, fmt.Sprintf("forbidden_keywords[%d] is empty and blocks every request", i))
+			continue
+		case seen[lower]:
+			warnings = append(warnings, fmt.Sprintf("forbidden keyword %q is listed twice", kw))
+			continue
+		}
+		seen[lower] = true
+		for j, other := range cfg.ForbiddenKeywords {
+			o := strings.ToLower(strings.TrimSpace(other))
+			if j != i && o != "" && o != lower && strings.Contains(lower, o) {
+				warnings = append(warnings, fmt.Sprintf("forbidden keyword %q is redundant: %q already matches it", kw, other))
+				break
+			}
+		}
+	}
+
+	if !cfg.Redaction.Enabled && len(cfg.Redaction.Patterns) > 0 {
+		warnings = append(warnings, "redaction patterns are configured but redaction is disabled")
+	}
+	for _, p := range cfg.Redaction.Patterns {
+		if re, err := regexp.Compile(p.Regex); err == nil && re.MatchString("") {
+			warnings = append(warnings, fmt.Sprintf("redaction pattern %q matches the empty string", p.Name))
+		}
+	}
+
+	profiles := cfg.Governance.Profiles
+	for i, p := range profiles {
+		if p.Redaction != nil && !p.Redaction.Enabled && len(p.Redaction.Patterns) > 0 {
+			warnings = append(warnings, fmt.Sprintf("governance profile %q has redaction patterns but redaction is disabled", p.Name))
+		}
+		if shadowed(profiles[:i], p.Paths) {
+			warnings = append(warnings, fmt.Sprintf("governance profile %q never applies: earlier profiles match all its paths", p.Name))
+		}
+	}
+
+	names := map[string]bool{}
+	for _, r := range cfg.Headers.Rules {
+		if names[r.Name] {
+			warnings = append(warnings, fmt.Sprintf("header rule name %q is used twice", r.Name))
+		}
+		names[r.Name] = true
+		for _, p := range r.Paths {
+			if !strings.HasPrefix(p, "/") {
+				warnings = append(warnings, fmt.Sprintf("header rule %q path %q does not start with / and never matches", r.Name, p))
+			}
+		}
+	}
+	return warnings
+}
+
+// runPolicyPacks lists the policy packs, marking those the config enables.
+func runPolicyPacks(args []string) error {
+	fs := flag.NewFlagSet("policy packs", flag.ContinueOnError)
+	configPath := fs.String("config", "config.yaml", "config whose enabled packs are marked")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+	var enabled []string
+	if cfg, err := config.LoadConfig(*configPath); err == nil {
+		enabled = cfg.PolicyPacks
+	}
+
+	names := make([]string, 0, len(config.PolicyPacks))
+	for name := range config.PolicyPacks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		pack := config.PolicyPacks[name]
+		mark := " "
+		if slices.Contains(enabled, name) {
+			mark = "*"
+		}
+		fmt.Printf("%s %-22s %d keywords, %d redaction patterns\n    %s\n", mark, name, len(pack.ForbiddenKeywords), len(pack.Builtins)+len(pack.Redactions), pack.Description)
+	}
+	return nil
+}
+
+// shadowed reports whether every one of paths falls under a prefix of an
+// earlier profile, so that a later profile with these paths never applies.
+func shadowed(earlier []config.GovernanceProfile, paths []string) bool {
+	for _, path := range paths {
+		matched := slices.ContainsFunc(earlier, func(e config.GovernanceProfile) bool {
+			return slices.ContainsFunc(e.Paths, func(prefix string) bool { return strings.HasPrefix(path, prefix) })
+		})
+		if !matched {
+			return false
+		}
+	}
+	return len(paths) > 0
+}
+
+func runPolicyTest(args []string) error {
+	fs := flag.NewFlagSet("policy test", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), "Usage: vantage policy test [flags] <dir>\n\nRuns every *.yaml case in dir through the configured pipeline.\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	configPath := fs.String("config", "config.yaml", "config whose policies are tested")
+	verbose := fs.Bool("v", false, "show the gateway log")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("expected one directory of cases")
+	}
+
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		return fmt.Errorf("%s: %w", *configPath, err)
+	}
+	cases, err := loadPolicyCases(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if !*verbose {
+		log.SetOutput(io.Discard)
+		middleware.DefaultLogger = middleware.RequestLogger(&middleware.DefaultLogFormatter{Logger: log.New(io.Discard, "", 0)})
+	}
+
+	h, err := newPolicyHarness(cfg)
+	if err != nil {
+		return err
+	}
+	defer h.close()
+
+	failed := 0
+	for _, name := range sortedKeys(cases) {
+		c := cases[name]
+		got := h.run(c)
+		problems := c.check(got)
+		label := name
+		if c.Description != "" {
+			label += " (" + c.Description + ")"
+		}
+		if len(problems) == 0 {
+			fmt.Printf("PASS %s: %s\n", label, got.summary())
+			continue
+		}
+		failed++
+		fmt.Printf("FAIL %s: %s, got %s\n", label, strings.Join(problems, "; "), got.summary())
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d policy cases failed", failed, len(cases))
+	}
+	fmt.Printf("ok: %d policy cases passed\n", len(cases))
+	return nil
+}
+
+// loadPolicyCases reads the *.yaml and *.yml files of dir, keyed by file name.
+func loadPolicyCases(dir string) (map[string]policyCase, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	cases := map[string]policyCase{}
+	for _, e := range entries {
+		ext := filepath.Ext(e.Name())
+		if e.IsDir() || (ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		var c policyCase
+		if err := yaml.Unmarshal(data, &c); err != nil {
+			return nil, fmt.Errorf("%s: %w", e.Name(), err)
+		}
+		switch c.Expect.Outcome {
+		case outcomeAllowed, outcomeRedacted, outcomeBlocked:
+		default:
+			return nil, fmt.Errorf("%s: expect.outcome must be allowed, redacted or blocked", e.Name())
+		}
+		cases[e.Name()] = c
+	}
+	if len(cases) == 0 {
+		return nil, fmt.Errorf("no policy cases in %s", dir)
+	}
+	return cases, nil
+}
+
+// check compares a result with the case's expectations.
+func (c policyCase) check(got policyResult) []string {
+	var problems []string
+	if got.Outcome != c.Expect.Outcome {
+		problems = append(problems, "expected "+c.Expect.Outcome)
+	}
+	for _, rule := range c.Expect.Rules {
+		if !slices.Contains(got.Rules, rule) {
+			problems = append(problems, fmt.Sprintf("expected rule %s to fire", rule))
+		}
+	}
+	return problems
+}
+
+func (r policyResult) summary() string {
+	s := fmt.Sprintf("%s (status %d)", r.Outcome, r.Status)
+	if len(r.Rules) > 0 {
+		s += " by " + strings.Join(r.Rules, ", ")
+	}
+	return s
+}
+
+// policyHarness runs cases through a gateway built from the config, with
+// the upstream stubbed out and a throwaway store. Rate limits, quotas,
+// caching, read-only mode and IP access lists are left out, since they
+// depend on traffic rather than on the request itself.
+type policyHarness struct {
+	srv   *server.Server
+	st    *store.Store
+	dir   string
+	audit chan pkgmiddleware.Interaction
+
+	// Set by the stub upstream for the case being run
+	reached  bool
+	response string
+}
+
+func newPolicyHarness(cfg *config.Config) (*policyHarness, error) {
+	cfg.RateLimit.Enabled = false
+	cfg.Quotas.Enabled = false
+	cfg.Cache.Enabled = false
+	cfg.ReadOnly.Enabled = false
+	cfg.IPAccess.Proxy = config.IPRanges{}
+
+	dir, err := os.MkdirTemp("", "vantage-policy")
+	if err != nil {
+		return nil, err
+	}
+	st, err := store.NewStore(filepath.Join(dir, "policy.db"))
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	registry, err := models.NewRegistry(st, cfg.Models.Enforce)
+	if err != nil {
+		st.Close()
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	registry.Seed(cfg.AllowedModels)
+
+	h := &policyHarness{st: st, dir: dir, audit: make(chan pkgmiddleware.Interaction, 1)}
+	h.srv = server.NewServer(st, cfg, "", h.audit, server.Services{Models: registry})
+	h.srv.Proxy.Transport = h
+	for _, p := range h.srv.Providers {
+		p.Proxy.Transport = h
+	}
+	return h, nil
+}
+
+func (h *policyHarness) close() {
+	h.st.Close()
+	os.RemoveAll(h.dir)
+}
+
+// RoundTrip is the stub upstream.
+func (h *policyHarness) RoundTrip(r *http.Request) (*http.Response, error) {
+	h.reached = true
+	if r.Body != nil {
+		io.Copy(io.Discard, r.Body)
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(h.response)),
+		Request:    r,
+	}, nil
+}
+
+// run sends one case through the gateway and reports what happened.
+func (h *policyHarness) run(c policyCase) policyResult {
+	method, path, user := c.Method, c.Path, c.User
+	if method == "" {
+		method = http.MethodPost
+	}
+	if path == "" {
+		path = "/v1/chat"
+	}
+	if user == "" {
+		user = "policy-test"
+	}
+	body := c.Body
+	if body == "" && c.Prompt != "" {
+		msg := map[string]string{"message": c.Prompt}
+		if c.Model != "" {
+			msg["model"] = c.Model
+		}
+		b, _ := json.Marshal(msg)
+		body = string(b)
+	}
+	h.reached = false
+	h.response = c.Response
+	if h.response == "" {
+		h.response = `{"text":"ok"}`
+	}
+
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-User-ID", user)
+	for k, v := range c.Headers {
+		req.Header.Set(k, v)
+	}
+
+	before := redactionCounts()
+	w := httptest.NewRecorder()
+	h.srv.Router.ServeHTTP(w, req)
+
+	res := policyResult{Status: w.Code, Outcome: outcomeAllowed}
+	if !h.reached {
+		res.Outcome = outcomeBlocked
+		var denial struct {
+			Code string `json:"code"`
+			Rule string `json:"rule"`
+		}
+		if json.NewDecoder(bytes.NewReader(w.Body.Bytes())).Decode(&denial) == nil && denial.Code != "" {
+			res.Rules = append(res.Rules, denial.Code)
+			if denial.Rule != "" {
+				res.Rules = append(res.Rules, "header:"+denial.Rule)
+			}
+		}
+	}
+	for pattern, n := range redactionCounts() {
+		if n > before[pattern] {
+			res.Rules = append(res.Rules, "redaction:"+pattern)
+			if res.Outcome == outcomeAllowed {
+				res.Outcome = outcomeRedacted
+			}
+		}
+	}
+
+	select {
+	case in := <-h.audit:
+		if in.RoutedModel != "" {
+			res.Rules = append(res.Rules, "routing:"+in.RoutedModel)
+		}
+		if in.Truncation != "" {
+			res.Rules = append(res.Rules, "truncation")
+		}
+	default:
+		// Rejected before the audit stage, e.g. by authentication
+	}
+	sort.Strings(res.Rules)
+	return res
+}
+
+// redactionCounts reads vantage_redactions_total by pattern, which the
+// pipeline increments for every value it redacts.
+func redactionCounts() map[string]float64 {
+	counts := map[string]float64{}
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		return counts
+	}
+	for _, f := range families {
+		if f.GetName() != "vantage_redactions_total" {
+			continue
+		}
+		for _, m := range f.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "pattern" {
+					counts[l.GetValue()] = m.GetCounter().GetValue()
+				}
+			}
+		}
+	}
+	return counts
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
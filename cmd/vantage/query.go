@@ -0,0 +1,283 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/soroushbar/vantage/internal/config"
+	"github.com/soroushbar/vantage/internal/encryption"
+	"github.com/soroushbar/vantage/internal/store"
+)
+
+// queryColumns are the fields printed in table and CSV output. Bodies are
+// never printed; they are only revealed, and access-logged, by the API.
+var queryColumns = []string{"id", "timestamp", "user_id", "method", "path", "model", "status_code", "latency_ms", "tokens", "safety_score", "is_blocked", "is_redacted"}
+
+// runQuery searches the interaction log through the admin API or, with
+// -db, directly in the SQLite store.
+func runQuery(args []string) error {
+	fs := flag.NewFlagSet("query", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), "Usage: vantage query [flags]\n\nSearches the interaction log, newest first.\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	server := fs.String("server", envOr("VANTAGE_URL", "http://localhost:8080"), "gateway base URL (env VANTAGE_URL)")
+	token := fs.String("token", os.Getenv("VANTAGE_ADMIN_TOKEN"), "admin bearer token (env VANTAGE_ADMIN_TOKEN)")
+	dbPath := fs.String("db", "", "query this SQLite audit database directly instead of the API")
+	configPath := fs.String("config", "config.yaml", "config used with -db for body compression and encryption")
+	user := fs.String("user", "", "only this user")
+	path := fs.String("path", "", "only paths starting with this prefix, e.g. /v1/chat")
+	since := fs.Duration("since", 0, "only the last duration, e.g. 30m or 24h")
+	from := fs.String("from", "", "earliest time, YYYY-MM-DD or RFC 3339")
+	to := fs.String("to", "", "latest time (exclusive), YYYY-MM-DD or RFC 3339")
+	blocked := fs.String("blocked", "", "true for blocked interactions only, false for allowed only")
+	slow := fs.Bool("slow", false, "only interactions flagged as slow")
+	limit := fs.Int("limit", 50, "maximum number of interactions")
+	format := fs.String("format", "table", "output format: table, json or csv")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+	if *format != "table" && *format != "json" && *format != "csv" {
+		return fmt.Errorf("unknown format %q", *format)
+	}
+
+	f := store.LogFilter{Limit: *limit, User: *user, Path: *path, Slow: *slow}
+	var err error
+	if f.From, err = parseTime(*from); err != nil {
+		return fmt.Errorf("-from: %w", err)
+	}
+	if f.To, err = parseTime(*to); err != nil {
+		return fmt.Errorf("-to: %w", err)
+	}
+	if *since > 0 {
+		f.From = time.Now().Add(-*since)
+	}
+	if *blocked != "" {
+		b, err := strconv.ParseBool(*blocked)
+		if err != nil {
+			return fmt.Errorf("-blocked must be true or false")
+		}
+		f.Blocked = &b
+	}
+
+	var logs []store.InteractionRecord
+	if *dbPath != "" {
+		logs, err = queryStore(*dbPath, *configPath, f)
+	} else {
+		logs, err = queryAPI(*server, *token, f)
+	}
+	if err != nil {
+		return err
+	}
+	return printLogs(os.Stdout, *format, logs)
+}
+
+// queryAPI fetches the interactions from /api/logs.
+func queryAPI(server, token string, f store.LogFilter) ([]store.InteractionRecord, error) {
+	q := url.Values{"limit": {strconv.Itoa(f.Limit)}}
+	if f.User != "" {
+		q.Set("user", f.User)
+	}
+	if f.Path != "" {
+		q.Set("path", f.Path)
+	}
+	if !f.From.IsZero() {
+		q.Set("from", f.From.UTC().Format(time.RFC3339))
+	}
+	if !f.To.IsZero() {
+		q.Set("to", f.To.UTC().Format(time.RFC3339))
+	}
+	if f.Blocked != nil {
+		q.Set("blocked", strconv.FormatBool(*f.Blocked))
+	}
+	if f.Slow {
+		q.Set("slow", "true")
+	}
+
+	req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(server, "/")+"/api/logs?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(body, &apiErr) == nil && apiErr.Error != "" {
+			return nil, fmt.Errorf("%s: %s", resp.Status, apiErr.Error)
+		}
+		return nil, fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	var logs []store.InteractionRecord
+	if err := json.NewDecoder(resp.Body).Decode(&logs); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+	return logs, nil
+}
+
+// queryStore reads the interactions from the database file.
+func queryStore(dbPath, configPath string, f store.LogFilter) ([]store.InteractionRecord, error) {
+	st, err := openStore(dbPath, configPath)
+	if err != nil {
+		return nil, err
+	}
+	defer st.Close()
+
+	logs, err := st.GetLogs(f)
+	if err != nil {
+		return nil, err
+	}
+	// Same view as the API: bodies are only revealed through the access-logged endpoints
+	for i := range logs {
+		logs[i].RequestBody, logs[i].ResponseBody = "", ""
+	}
+	return logs, nil
+}
+
+// openStore opens an existing database file, set up with the storage
+// settings of the config so bodies are decoded and encoded as the gateway
+// does.
+func openStore(dbPath, configPath string) (*store.Store, error) {
+	cfg, err := loadConfig(configPath)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(dbPath); err != nil {
+		return nil, err
+	}
+
+	st, err := store.NewStore(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := setupStore(st, cfg.Storage); err != nil {
+		st.Close()
+		return nil, err
+	}
+	return st, nil
+}
+
+func setupStore(st *store.Store, c config.StorageConfig) error {
+	if err := st.SetBodyCompression(c.BodyCompression); err != nil {
+		return err
+	}
+	if e := c.Encryption; e.Enabled {
+		key, err := encryption.LoadKey(context.Background(), e)
+		if err != nil {
+			return fmt.Errorf("load body encryption key: %w", err)
+		}
+		keyring, err := encryption.NewKeyring(e.KeyID, key)
+		if err != nil {
+			return fmt.Errorf("load body encryption key: %w", err)
+		}
+		st.SetBodyCipher(keyring)
+	}
+	return nil
+}
+
+// loadConfig loads the config at path, or the defaults when it does not
+// exist.
+func loadConfig(path string) (*config.Config, error) {
+	cfg, err := config.LoadConfig(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return config.Default(), nil
+	} else if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+func printLogs(w io.Writer, format string, logs []store.InteractionRecord) error {
+	switch format {
+	case "json":
+		if logs == nil {
+			logs = []store.InteractionRecord{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(logs)
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write(queryColumns)
+		for _, l := range logs {
+			cw.Write(logRow(l))
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.ToUpper(strings.Join(queryColumns, "\t")))
+	for _, l := range logs {
+		fmt.Fprintln(tw, strings.Join(logRow(l), "\t"))
+	}
+	return tw.Flush()
+}
+
+func logRow(l store.InteractionRecord) []string {
+	safety := ""
+	if l.SafetyScore != nil {
+		safety = strconv.FormatFloat(*l.SafetyScore, 'f', 2, 64)
+	}
+	return []string{
+		strconv.Itoa(l.ID),
+		l.Timestamp.UTC().Format(time.RFC3339),
+		l.UserID,
+		l.Method,
+		l.Path,
+		l.Model,
+		strconv.Itoa(l.StatusCode),
+		strconv.FormatInt(l.LatencyMs, 10),
+		strconv.Itoa(l.Tokens),
+		safety,
+		strconv.FormatBool(l.IsBlocked),
+		strconv.FormatBool(l.IsRedacted),
+	}
+}
+
+// parseTime accepts a date or an RFC 3339 timestamp; "" is the zero time.
+func parseTime(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse("2006-01-02", v); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, errors.New("must be YYYY-MM-DD or RFC 3339")
+	}
+	return t, nil
+}
+
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/joho/godotenv"
+	"github.com/soroushbar/vantage/internal/preflight"
+)
+
+// runValidate checks a gateway's config, database and provider credentials
+// the way the gateway would see them, and lists every problem.
+func runValidate(args []string) error {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), "Usage: vantage validate [flags]\n\nChecks config.yaml (including unknown keys), the database and the provider\ncredentials, reading COHERE_API_KEY and friends from the environment or .env.\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	configPath := fs.String("config", "config.yaml", "config to check")
+	dbPath := fs.String("db", "", "audit database (default DATABASE_URL, else ./audit.db)")
+	offline := fs.Bool("offline", false, "skip the checks that call the providers")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+
+	// Same environment as the gateway
+	godotenv.Load()
+	if *dbPath == "" {
+		*dbPath = envOr("DATABASE_URL", "./audit.db")
+	}
+
+	results := preflight.Run(context.Background(), preflight.Options{
+		ConfigPath: *configPath,
+		DBPath:     *dbPath,
+		CohereKey:  os.Getenv("COHERE_API_KEY"),
+		Offline:    *offline,
+	})
+	failed := 0
+	for _, r := range results {
+		switch {
+		case !r.OK():
+			failed++
+			fmt.Printf("FAIL  %s\n", r.Name)
+			for _, p := range r.Problems {
+				fmt.Printf("      - %s\n", p)
+			}
+		case r.Note != "":
+			fmt.Printf("ok    %s: %s\n", r.Name, r.Note)
+		default:
+			fmt.Printf("ok    %s\n", r.Name)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d checks failed", failed, len(results))
+	}
+	return nil
+}
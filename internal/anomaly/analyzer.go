@@ -0,0 +1,165 @@
+// Package anomaly learns each user's usual hourly usage from the rollups
+// and raises alerts when an hour departs sharply from it.
+package anomaly
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/soroushbar/vantage/internal/config"
+	"github.com/soroushbar/vantage/internal/notify"
+	"github.com/soroushbar/vantage/internal/store"
+)
+
+// Alert kinds.
+const (
+	RequestSpike = "request_spike"
+	TokenSpike   = "token_spike"
+	BlockedSurge = "blocked_surge"
+	UnusualHour  = "unusual_hour"
+)
+
+// Store interface for decoupling
+type Store interface {
+	UserHours(from, to time.Time) ([]store.UserHour, error)
+	SaveAlert(a *store.Alert) (bool, error)
+	ListAlerts(f store.AlertFilter) ([]store.Alert, error)
+}
+
+// Notifier receives an event for every new alert.
+type Notifier interface {
+	Publish(e notify.Event)
+}
+
+// Analyzer compares the last complete hour of every active user with
+// their baseline.
+type Analyzer struct {
+	store    Store
+	cfg      config.AnomalyConfig
+	notifier Notifier
+}
+
+// NewAnalyzer returns an analyzer; notifier may be nil.
+func NewAnalyzer(st Store, cfg config.AnomalyConfig, notifier Notifier) *Analyzer {
+	return &Analyzer{store: st, cfg: cfg, notifier: notifier}
+}
+
+// Start analyzes now and then every configured interval. An hour is
+// analyzed more than once, but each anomaly is only alerted once.
+func (a *Analyzer) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(a.cfg.Interval)
+		defer ticker.Stop()
+		for {
+			if _, err := a.Analyze(time.Now()); err != nil {
+				log.Printf("Anomaly analysis failed: %v", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// baseline is one user's usage over the baseline window.
+type baseline struct {
+	requests, tokens, blocked int
+	activeHours               int
+	byHourOfDay               [24]int
+}
+
+// Analyze checks the hour before the one now falls in and returns the
+// alerts it raised.
+func (a *Analyzer) Analyze(now time.Time) ([]store.Alert, error) {
+	hour := now.UTC().Truncate(time.Hour).Add(-time.Hour)
+	rows, err := a.store.UserHours(hour.Add(-a.cfg.Baseline), hour.Add(time.Hour))
+	if err != nil {
+		return nil, err
+	}
+
+	key := hour.Format(store.HourFormat)
+	current := map[string]store.UserHour{}
+	baselines := map[string]*baseline{}
+	for _, r := range rows {
+		if r.Hour == key {
+			current[r.UserID] = r
+			continue
+		}
+		b := baselines[r.UserID]
+		if b == nil {
+			b = &baseline{}
+			baselines[r.UserID] = b
+		}
+		b.requests += r.Requests
+		b.tokens += r.Tokens
+		b.blocked += r.Blocked
+		b.activeHours++
+		if t, err := time.Parse(store.HourFormat, r.Hour); err == nil {
+			b.byHourOfDay[t.Hour()] += r.Requests
+		}
+	}
+
+	var raised []store.Alert
+	for user, cur := range current {
+		b := baselines[user]
+		if b == nil || b.requests < a.cfg.MinRequests {
+			continue
+		}
+		for _, alert := range a.check(cur, b, hour) {
+			alert.UserID, alert.Hour, alert.CreatedAt = user, key, now.UTC()
+			isNew, err := a.store.SaveAlert(&alert)
+			if err != nil {
+				return raised, err
+			}
+			if !isNew {
+				continue
+			}
+			raised = append(raised, alert)
+			if a.notifier != nil {
+				a.notifier.Publish(notify.NewEvent(notify.EventUsageAnomaly, user, "", map[string]interface{}{
+					"alert_id": alert.ID,
+					"kind":     alert.Kind,
+					"hour":     alert.Hour,
+					"observed": alert.Observed,
+					"baseline": alert.Baseline,
+					"message":  alert.Message,
+				}))
+			}
+		}
+	}
+	return raised, nil
+}
+
+// check returns the anomalies in a user's hour, without user and time.
+func (a *Analyzer) check(cur store.UserHour, b *baseline, hour time.Time) []store.Alert {
+	var alerts []store.Alert
+	perHour := func(total int) float64 { return float64(total) / float64(b.activeHours) }
+	factor := a.cfg.SpikeFactor
+
+	if usual := perHour(b.requests); float64(cur.Requests) >= factor*usual && cur.Requests >= a.cfg.MinRequests {
+		alerts = append(alerts, store.Alert{Kind: RequestSpike, Observed: float64(cur.Requests), Baseline: usual,
+			Message: fmt.Sprintf("%d requests, %.0fx the usual %.1f per active hour", cur.Requests, float64(cur.Requests)/usual, usual)})
+	}
+	if usual := perHour(b.tokens); usual > 0 && float64(cur.Tokens) >= factor*usual {
+		alerts = append(alerts, store.Alert{Kind: TokenSpike, Observed: float64(cur.Tokens), Baseline: usual,
+			Message: fmt.Sprintf("%d tokens, %.0fx the usual %.0f per active hour", cur.Tokens, float64(cur.Tokens)/usual, usual)})
+	}
+	if usual := perHour(b.blocked); cur.Blocked >= a.cfg.MinBlocked && float64(cur.Blocked) >= factor*usual {
+		alerts = append(alerts, store.Alert{Kind: BlockedSurge, Observed: float64(cur.Blocked), Baseline: usual,
+			Message: fmt.Sprintf("%d blocked requests against a usual %.1f per active hour", cur.Blocked, usual)})
+	}
+	if b.byHourOfDay[hour.Hour()] == 0 {
+		alerts = append(alerts, store.Alert{Kind: UnusualHour, Observed: float64(cur.Requests),
+			Message: fmt.Sprintf("%d requests at %02d:00 UTC, an hour with no activity in the baseline", cur.Requests, hour.Hour())})
+	}
+	return alerts
+}
+
+// List returns the newest alerts, optionally for one user or kind.
+func (a *Analyzer) List(f store.AlertFilter) ([]store.Alert, error) {
+	return a.store.ListAlerts(f)
+}
@@ -0,0 +1,225 @@
+// Package archive moves old interaction bodies out of the database into
+// compressed objects and restores them when a record is read. It also
+// exports whole interactions that have outlived the database.
+package archive
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/soroushbar/vantage/internal/codec"
+	"github.com/soroushbar/vantage/internal/config"
+	"github.com/soroushbar/vantage/internal/store"
+)
+
+// Store interface for decoupling
+type Store interface {
+	ArchiveCandidates(before time.Time, limit int) ([]store.ArchiveCandidate, error)
+	MarkArchived(id int, key string) error
+	DecodeBodies(encoding, userID string, req, resp []byte) ([]byte, []byte, error)
+}
+
+// object is the archived form of one interaction. ChainHash lets the bodies
+// be checked against the record's entry in the hash chain. Request and
+// Response are the bodies as the database stored them, with the
+// body_encoding in Encoding, so sealed bodies stay sealed in the object
+// store. Objects written before that hold the plain RequestBody and
+// ResponseBody instead.
+type object struct {
+	ID           int       `json:"id"`
+	Timestamp    time.Time `json:"timestamp"`
+	ChainHash    string    `json:"chain_hash,omitempty"`
+	Encoding     string    `json:"body_encoding,omitempty"`
+	Request      []byte    `json:"request,omitempty"`
+	Response     []byte    `json:"response,omitempty"`
+	RequestBody  string    `json:"request_body,omitempty"`
+	ResponseBody string    `json:"response_body,omitempty"`
+}
+
+// stored returns the bodies of obj and their encoding as the database would
+// hold them.
+func (o *object) stored() (string, []byte, []byte) {
+	if o.Encoding == "" && o.Request == nil && o.Response == nil {
+		return "", []byte(o.RequestBody), []byte(o.ResponseBody)
+	}
+	return o.Encoding, o.Request, o.Response
+}
+
+// Archiver moves bodies older than the configured age to an ObjectStore.
+type Archiver struct {
+	store    Store
+	objects  ObjectStore
+	after    time.Duration
+	interval time.Duration
+	batch    int
+	// compression is applied to new objects; existing ones are read by extension
+	compression string
+}
+
+func NewArchiver(st Store, objects ObjectStore, cfg config.ArchiveConfig) *Archiver {
+	return &Archiver{store: st, objects: objects, after: cfg.After, interval: cfg.Interval, batch: cfg.BatchSize, compression: cfg.Compression}
+}
+
+// Start archives immediately and then on every interval.
+func (a *Archiver) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(a.interval)
+		defer ticker.Stop()
+		for {
+			n, err := a.Run(ctx)
+			if err != nil {
+				log.Printf("Archiving failed after %d interactions: %v", n, err)
+			} else if n > 0 {
+				log.Printf("Archived bodies of %d interactions", n)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Run archives due interactions in batches until none are left, returning
+// how many were archived.
+func (a *Archiver) Run(ctx context.Context) (int, error) {
+	archived := 0
+	cutoff := time.Now().Add(-a.after)
+	for ctx.Err() == nil {
+		candidates, err := a.store.ArchiveCandidates(cutoff, a.batch)
+		if err != nil {
+			return archived, err
+		}
+		if len(candidates) == 0 {
+			return archived, nil
+		}
+		for _, c := range candidates {
+			// 1. Upload first; the row keeps its bodies if this fails
+			key := objectKey(c, a.compression)
+			data, err := encode(object{
+				ID:        c.ID,
+				Timestamp: c.Timestamp,
+				ChainHash: c.ChainHash,
+				Encoding:  c.Encoding,
+				Request:   c.RequestBody,
+				Response:  c.ResponseBody,
+			}, a.compression)
+			if err != nil {
+				return archived, err
+			}
+			if err := a.objects.Put(ctx, key, data); err != nil {
+				return archived, fmt.Errorf("upload %s: %w", key, err)
+			}
+
+			// 2. Replace the bodies with the object key
+			if err := a.store.MarkArchived(c.ID, key); err != nil {
+				return archived, err
+			}
+			archived++
+		}
+	}
+	return archived, ctx.Err()
+}
+
+// Restore fills in the bodies of an archived record from the object store,
+// decrypting them with the store's keys. Records that were never archived
+// are left untouched.
+func (a *Archiver) Restore(ctx context.Context, rec *store.InteractionRecord) error {
+	if rec.ArchiveKey == "" {
+		return nil
+	}
+	obj, err := a.fetch(ctx, rec.ArchiveKey, rec.ID)
+	if err != nil {
+		return err
+	}
+	encoding, req, resp := obj.stored()
+	req, resp, err = a.store.DecodeBodies(encoding, rec.UserID, req, resp)
+	if err != nil {
+		return fmt.Errorf("archive object %s: %w", rec.ArchiveKey, err)
+	}
+	rec.RequestBody = string(req)
+	rec.ResponseBody = string(resp)
+	return nil
+}
+
+// Bodies reads the bodies of interaction id back from its object as the
+// database stored them, for store.SetArchiveReader.
+func (a *Archiver) Bodies(key string, id int) (string, []byte, []byte, error) {
+	obj, err := a.fetch(context.Background(), key, id)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	encoding, req, resp := obj.stored()
+	return encoding, req, resp, nil
+}
+
+// Delete removes the objects under keys, for store.SetArchiveDeleter. It
+// stops at the first object that cannot be deleted.
+func (a *Archiver) Delete(keys []string) error {
+	for _, key := range keys {
+		if err := a.objects.Delete(context.Background(), key); err != nil {
+			return fmt.Errorf("archive object %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// fetch reads the archive object of interaction id.
+func (a *Archiver) fetch(ctx context.Context, key string, id int) (*object, error) {
+	data, err := a.objects.Get(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("fetch archived bodies: %w", err)
+	}
+	obj, err := decode(data, compressionOf(key))
+	if err != nil {
+		return nil, err
+	}
+	if obj.ID != id {
+		return nil, fmt.Errorf("archive object %s belongs to interaction %d", key, obj.ID)
+	}
+	return obj, nil
+}
+
+// extensions maps each compression to the suffix of its object keys.
+var extensions = map[string]string{codec.None: ".json", codec.Gzip: ".json.gz", codec.Zstd: ".json.zst"}
+
+// objectKey partitions objects by day so a prefix lists one day's records.
+func objectKey(c store.ArchiveCandidate, compression string) string {
+	return fmt.Sprintf("interactions/%s/%d%s", c.Timestamp.UTC().Format("2006/01/02"), c.ID, extensions[compression])
+}
+
+// compressionOf recovers an object's compression from its key.
+func compressionOf(key string) string {
+	switch {
+	case strings.HasSuffix(key, extensions[codec.Gzip]):
+		return codec.Gzip
+	case strings.HasSuffix(key, extensions[codec.Zstd]):
+		return codec.Zstd
+	}
+	return codec.None
+}
+
+func encode(obj object, compression string) ([]byte, error) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	return codec.Encode(compression, data)
+}
+
+func decode(data []byte, compression string) (*object, error) {
+	raw, err := codec.Decode(compression, data)
+	if err != nil {
+		return nil, err
+	}
+	var obj object
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		return nil, err
+	}
+	return &obj, nil
+}
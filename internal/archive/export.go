@@ -0,0 +1,258 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/soroushbar/vantage/internal/codec"
+	"github.com/soroushbar/vantage/internal/config"
+	"github.com/soroushbar/vantage/internal/parquet"
+	"github.com/soroushbar/vantage/internal/store"
+)
+
+// ExportStore is the part of the store the Exporter needs.
+type ExportStore interface {
+	ExportCandidates(before time.Time, limit int) ([]store.ExportRecord, error)
+	CompleteExport(e *store.LogExport) error
+	ExportsBetween(from, to time.Time) ([]store.LogExport, error)
+	UpdateExport(e *store.LogExport) error
+}
+
+// Exporter moves whole interactions older than the configured age out of
+// the database into JSONL or Parquet objects, one per batch and day, each
+// with a manifest.
+type Exporter struct {
+	store       ExportStore
+	objects     ObjectStore
+	after       time.Duration
+	interval    time.Duration
+	batch       int
+	format      string
+	compression string
+}
+
+func NewExporter(st ExportStore, objects ObjectStore, cfg config.LogExportConfig) *Exporter {
+	return &Exporter{store: st, objects: objects, after: cfg.After, interval: cfg.Interval, batch: cfg.BatchSize, format: cfg.Format, compression: cfg.Compression}
+}
+
+// Start exports immediately and then on every interval.
+func (e *Exporter) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(e.interval)
+		defer ticker.Stop()
+		for {
+			n, err := e.Run(ctx)
+			if err != nil {
+				log.Printf("Log export failed after %d interactions: %v", n, err)
+			} else if n > 0 {
+				log.Printf("Exported %d interactions", n)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Run exports due interactions in batches until none are left, returning
+// how many were exported.
+func (e *Exporter) Run(ctx context.Context) (int, error) {
+	exported := 0
+	cutoff := time.Now().Add(-e.after)
+	for ctx.Err() == nil {
+		records, err := e.store.ExportCandidates(cutoff, e.batch)
+		if err != nil {
+			return exported, err
+		}
+		if len(records) == 0 {
+			return exported, nil
+		}
+		records = sameDay(records)
+
+		// 1. Upload the records and their manifest; the rows stay if this fails,
+		// and the next run writes the same keys again
+		data, err := e.encode(records)
+		if err != nil {
+			return exported, err
+		}
+		first, last := records[0], records[len(records)-1]
+		sum := sha256.Sum256(data)
+		manifest := &store.LogExport{
+			CreatedAt: time.Now().UTC(),
+			Key:       exportKey(first, last, e.format, e.compression),
+			Format:    e.format,
+			Records:   len(records),
+			FirstID:   first.ID,
+			LastID:    last.ID,
+			From:      first.Timestamp,
+			To:        last.Timestamp,
+			Bytes:     len(data),
+			SHA256:    hex.EncodeToString(sum[:]),
+			ChainHash: last.ChainHash,
+		}
+		if err := e.objects.Put(ctx, manifest.Key, data); err != nil {
+			return exported, fmt.Errorf("upload %s: %w", manifest.Key, err)
+		}
+		manifestData, _ := json.MarshalIndent(manifest, "", "  ")
+		if err := e.objects.Put(ctx, manifest.Key+".manifest.json", manifestData); err != nil {
+			return exported, fmt.Errorf("upload manifest of %s: %w", manifest.Key, err)
+		}
+
+		// 2. Record the manifest and delete the rows
+		if err := e.store.CompleteExport(manifest); err != nil {
+			return exported, err
+		}
+		exported += len(records)
+	}
+	return exported, ctx.Err()
+}
+
+// ExportPurge is what a purge removed from the export objects. Objects
+// counts the objects rewritten without the Records that matched. Kept lists
+// the Parquet objects that may hold matching records, which are not
+// rewritten and have to be dealt with by hand. IDs are the removed records.
+type ExportPurge struct {
+	Records int      `json:"records"`
+	Objects int      `json:"objects"`
+	Kept    []string `json:"kept,omitempty"`
+	IDs     []int    `json:"-"`
+}
+
+// Purge rewrites the JSONL export objects from f's time range without the
+// records f matches, and updates their manifests. With dryRun the records
+// are only counted. A purge that fails part way can be repeated.
+func (e *Exporter) Purge(ctx context.Context, f store.LogFilter, dryRun bool) (*ExportPurge, error) {
+	exports, err := e.store.ExportsBetween(f.From, f.To)
+	if err != nil {
+		return nil, err
+	}
+	result := &ExportPurge{}
+	for i := range exports {
+		x := &exports[i]
+		if x.Format == "parquet" {
+			result.Kept = append(result.Kept, x.Key)
+			continue
+		}
+		alg := jsonlCompression(x.Key)
+		raw, err := e.objects.Get(ctx, x.Key)
+		if err != nil {
+			return result, fmt.Errorf("read %s: %w", x.Key, err)
+		}
+		data, err := codec.Decode(alg, raw)
+		if err != nil {
+			return result, fmt.Errorf("read %s: %w", x.Key, err)
+		}
+		var kept bytes.Buffer
+		removed := 0
+		for _, line := range bytes.Split(data, []byte("\n")) {
+			if len(line) == 0 {
+				continue
+			}
+			var rec store.ExportRecord
+			if err := json.Unmarshal(line, &rec); err != nil {
+				return result, fmt.Errorf("read %s: %w", x.Key, err)
+			}
+			if f.Match(rec.InteractionRecord) {
+				removed++
+				result.IDs = append(result.IDs, rec.ID)
+				continue
+			}
+			kept.Write(line)
+			kept.WriteByte('\n')
+		}
+		if removed == 0 {
+			continue
+		}
+		result.Records += removed
+		result.Objects++
+		if dryRun {
+			continue
+		}
+
+		if data, err = codec.Encode(alg, kept.Bytes()); err != nil {
+			return result, err
+		}
+		sum := sha256.Sum256(data)
+		x.Records -= removed
+		x.Bytes = len(data)
+		x.SHA256 = hex.EncodeToString(sum[:])
+		if err := e.objects.Put(ctx, x.Key, data); err != nil {
+			return result, fmt.Errorf("upload %s: %w", x.Key, err)
+		}
+		manifestData, _ := json.MarshalIndent(x, "", "  ")
+		if err := e.objects.Put(ctx, x.Key+".manifest.json", manifestData); err != nil {
+			return result, fmt.Errorf("upload manifest of %s: %w", x.Key, err)
+		}
+		if err := e.store.UpdateExport(x); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// jsonlCompression returns the compression of a JSONL export from its key.
+func jsonlCompression(key string) string {
+	switch {
+	case strings.HasSuffix(key, ".gz"):
+		return codec.Gzip
+	case strings.HasSuffix(key, ".zst"):
+		return codec.Zstd
+	}
+	return codec.None
+}
+
+// encode writes records in the configured format. Parquet compresses its
+// pages itself; JSONL is compressed as a whole.
+func (e *Exporter) encode(records []store.ExportRecord) ([]byte, error) {
+	var buf bytes.Buffer
+	if e.format == "parquet" {
+		if err := parquet.WriteInteractions(&buf, records, e.compression); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	enc := json.NewEncoder(&buf)
+	for _, r := range records {
+		if err := enc.Encode(r); err != nil {
+			return nil, err
+		}
+	}
+	return codec.Encode(e.compression, buf.Bytes())
+}
+
+// sameDay cuts records short at the first one from a later UTC day than
+// the first, so that every object belongs to one date partition.
+func sameDay(records []store.ExportRecord) []store.ExportRecord {
+	day := records[0].Timestamp.UTC().Format("2006-01-02")
+	for i, r := range records {
+		if r.Timestamp.UTC().Format("2006-01-02") != day {
+			return records[:i]
+		}
+	}
+	return records
+}
+
+// exportKey partitions exports Hive-style by the day of their records, so
+// that query engines can prune by date, and names them after the IDs they
+// hold.
+func exportKey(first, last store.ExportRecord, format, compression string) string {
+	ext := ".jsonl"
+	switch {
+	case format == "parquet":
+		ext = ".parquet"
+	case compression == codec.Gzip:
+		ext += ".gz"
+	case compression == codec.Zstd:
+		ext += ".zst"
+	}
+	return fmt.Sprintf("exports/date=%s/interactions-%d-%d%s", first.Timestamp.UTC().Format("2006-01-02"), first.ID, last.ID, ext)
+}
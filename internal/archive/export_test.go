@@ -0,0 +1,76 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/soroushbar/vantage/internal/codec"
+	"github.com/soroushbar/vantage/internal/config"
+	"github.com/soroushbar/vantage/internal/store"
+)
+
+func TestExporterPurgeRewritesJSONL(t *testing.T) {
+	dir := t.TempDir()
+	st, err := store.NewStore(filepath.Join(dir, "audit.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	yesterday := time.Now().UTC().Add(-24 * time.Hour)
+	for _, user := range []string{"alice", "bob", "alice"} {
+		if _, err := st.LogInteraction(store.InteractionRecord{Timestamp: yesterday, UserID: user, Method: "POST", Path: "/v1/chat", RequestBody: `{"message":"hi"}`, StatusCode: 200}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx := context.Background()
+	objects := &FileStore{Dir: filepath.Join(dir, "objects")}
+	e := NewExporter(st, objects, config.LogExportConfig{After: time.Hour, BatchSize: 100, Format: "jsonl", Compression: codec.Gzip})
+	if n, err := e.Run(ctx); err != nil || n != 3 {
+		t.Fatalf("exported %d: %v", n, err)
+	}
+
+	dry, err := e.Purge(ctx, store.LogFilter{User: "alice"}, true)
+	if err != nil || dry.Records != 2 {
+		t.Fatalf("dry run: %+v, %v", dry, err)
+	}
+	result, err := e.Purge(ctx, store.LogFilter{User: "alice"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Records != 2 || result.Objects != 1 || len(result.IDs) != 2 || len(result.Kept) != 0 {
+		t.Errorf("unexpected result %+v", result)
+	}
+
+	exports, err := st.ListExports(10)
+	if err != nil || len(exports) != 1 {
+		t.Fatalf("exports: %+v, %v", exports, err)
+	}
+	x := exports[0]
+	raw, err := objects.Get(ctx, x.Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(raw)
+	if x.Records != 1 || x.Bytes != len(raw) || x.SHA256 != hex.EncodeToString(sum[:]) {
+		t.Errorf("manifest %+v does not describe the rewritten object", x)
+	}
+	data, err := codec.Decode(codec.Gzip, raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range bytes.Split(bytes.TrimSpace(data), []byte("\n")) {
+		var rec store.ExportRecord
+		if err := json.Unmarshal(line, &rec); err != nil {
+			t.Fatal(err)
+		}
+		if rec.UserID != "bob" {
+			t.Errorf("record of %s left in the export", rec.UserID)
+		}
+	}
+}
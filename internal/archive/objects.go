@@ -0,0 +1,136 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/soroushbar/vantage/internal/config"
+)
+
+// ObjectStore holds archived bodies under slash-separated keys.
+type ObjectStore interface {
+	Put(ctx context.Context, key string, data []byte) error
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Delete removes an object; deleting one that does not exist succeeds
+	Delete(ctx context.Context, key string) error
+}
+
+// gcsEndpoint is the S3-compatible XML API of Google Cloud Storage.
+const gcsEndpoint = "https://storage.googleapis.com"
+
+// NewObjectStore returns the backend selected by cfg.Backend.
+func NewObjectStore(ctx context.Context, cfg config.ObjectStoreConfig) (ObjectStore, error) {
+	switch cfg.Backend {
+	case "", "file":
+		return &FileStore{Dir: cfg.Dir}, nil
+	case "s3":
+		return NewS3Store(ctx, cfg.Bucket, cfg.Prefix, cfg.Region, cfg.Endpoint)
+	case "gcs":
+		endpoint, region := cfg.Endpoint, cfg.Region
+		if endpoint == "" {
+			endpoint = gcsEndpoint
+		}
+		if region == "" {
+			region = "auto"
+		}
+		return NewS3Store(ctx, cfg.Bucket, cfg.Prefix, region, endpoint)
+	default:
+		return nil, fmt.Errorf("unknown object store backend %q", cfg.Backend)
+	}
+}
+
+// FileStore keeps objects as files under Dir, e.g. on a mounted volume.
+type FileStore struct {
+	Dir string
+}
+
+func (f *FileStore) Put(ctx context.Context, key string, data []byte) error {
+	path := filepath.Join(f.Dir, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	// Write then rename so a crash never leaves a partial object behind
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (f *FileStore) Get(ctx context.Context, key string) ([]byte, error) {
+	return os.ReadFile(filepath.Join(f.Dir, filepath.FromSlash(key)))
+}
+
+func (f *FileStore) Delete(ctx context.Context, key string) error {
+	err := os.Remove(filepath.Join(f.Dir, filepath.FromSlash(key)))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// S3Store keeps objects in an S3 bucket, using credentials from the default
+// AWS chain (environment, shared config, IRSA, ECS or EC2 roles).
+type S3Store struct {
+	client *s3.Client
+	bucket string
+	prefix string
+}
+
+// NewS3Store returns a store writing to bucket under prefix. An empty region
+// falls back to AWS_REGION. A non-empty endpoint replaces the AWS one and
+// addresses buckets by path, as S3-compatible services expect.
+func NewS3Store(ctx context.Context, bucket, prefix, region, endpoint string) (*S3Store, error) {
+	cfg, err := awsconfig.LoadDefaultConfig(ctx, awsconfig.WithRegion(region))
+	if err != nil {
+		return nil, fmt.Errorf("load AWS config: %w", err)
+	}
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+		if endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+			o.UsePathStyle = true
+		}
+	})
+	return &S3Store{client: client, bucket: bucket, prefix: prefix}, nil
+}
+
+func (s *S3Store) Put(ctx context.Context, key string, data []byte) error {
+	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.prefix + key),
+		Body:   bytes.NewReader(data),
+	})
+	return err
+}
+
+func (s *S3Store) Get(ctx context.Context, key string) ([]byte, error) {
+	out, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.prefix + key),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer out.Body.Close()
+	return io.ReadAll(out.Body)
+}
+
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.prefix + key),
+	})
+	return err
+}
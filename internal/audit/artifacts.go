@@ -0,0 +1,55 @@
+package audit
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/soroushbar/vantage/internal/store"
+	"github.com/soroushbar/vantage/pkg/middleware"
+)
+
+// extractArtifacts finds datasets and fine-tuned models created by a successful upstream call.
+func extractArtifacts(i middleware.Interaction) []store.ModelArtifact {
+	if i.Method != http.MethodPost || i.StatusCode != http.StatusOK {
+		return nil
+	}
+
+	switch strings.TrimSuffix(i.Path, "/") {
+	case "/v1/datasets":
+		var resp struct {
+			ID string `json:"id"`
+		}
+		if json.Unmarshal(i.ResponseBody, &resp) != nil || resp.ID == "" {
+			return nil
+		}
+		return []store.ModelArtifact{{Kind: store.ArtifactDataset, ArtifactID: resp.ID, CreatedBy: i.UserID}}
+
+	case "/v1/finetuning/finetuned-models":
+		var resp struct {
+			FinetunedModel struct {
+				ID       string `json:"id"`
+				Name     string `json:"name"`
+				Settings struct {
+					DatasetID string `json:"dataset_id"`
+					BaseModel struct {
+						BaseType string `json:"base_type"`
+					} `json:"base_model"`
+				} `json:"settings"`
+			} `json:"finetuned_model"`
+		}
+		if json.Unmarshal(i.ResponseBody, &resp) != nil || resp.FinetunedModel.ID == "" {
+			return nil
+		}
+		fm := resp.FinetunedModel
+		return []store.ModelArtifact{{
+			Kind:       store.ArtifactFinetunedModel,
+			ArtifactID: fm.ID,
+			Name:       fm.Name,
+			BaseModel:  fm.Settings.BaseModel.BaseType,
+			DatasetID:  fm.Settings.DatasetID,
+			CreatedBy:  i.UserID,
+		}}
+	}
+	return nil
+}
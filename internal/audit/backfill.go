@@ -0,0 +1,44 @@
+package audit
+
+import (
+	"context"
+	"log"
+
+	"github.com/soroushbar/vantage/internal/latency"
+)
+
+// backfillBatch is how many interactions each backfill transaction rolls up.
+const backfillBatch = 1000
+
+// Backfill rolls up, in the background, interactions that were logged
+// before the worker started maintaining the usage rollups, so /api/stats
+// covers the full history. It resumes where it stopped after a restart.
+// Backfilled interactions have no input/output split, so their cost is
+// estimated at the input price.
+func (w *Worker) Backfill(ctx context.Context) {
+	go func() {
+		total := 0
+		for ctx.Err() == nil {
+			rows, err := w.store.PendingBackfill(backfillBatch)
+			if err != nil {
+				log.Printf("Rollup backfill failed: %v", err)
+				return
+			}
+			if len(rows) == 0 {
+				break
+			}
+			for i := range rows {
+				rows[i].Cost = w.pricing.Cost(rows[i].Model, rows[i].InputTokens, 0)
+				rows[i].Route = latency.Route(rows[i].Path)
+			}
+			if err := w.store.RecordBackfill(rows); err != nil {
+				log.Printf("Rollup backfill failed: %v", err)
+				return
+			}
+			total += len(rows)
+		}
+		if total > 0 {
+			log.Printf("Rollup backfill added %d interactions", total)
+		}
+	}()
+}
@@ -0,0 +1,255 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/soroushbar/vantage/internal/store"
+	"github.com/soroushbar/vantage/internal/telemetry"
+)
+
+// DeadLetter is a store write the worker gave up on. Exactly one of
+// Interaction and Usage is set.
+type DeadLetter struct {
+	FailedAt    time.Time                `json:"failed_at"`
+	Error       string                   `json:"error"`
+	Interaction *store.InteractionRecord `json:"interaction,omitempty"`
+	Usage       *store.UsageSample       `json:"usage,omitempty"`
+	// Sealed holds the interaction's bodies when they were encrypted, in
+	// which case the interaction's own body fields are empty
+	Sealed *SealedBodies `json:"sealed,omitempty"`
+}
+
+// SealedBodies are the request and response bodies of a dead-lettered
+// interaction, encrypted with the body encryption key with the user as
+// tenant, as the store encrypts them.
+type SealedBodies struct {
+	KeyID    string `json:"key_id"`
+	Request  []byte `json:"request,omitempty"`
+	Response []byte `json:"response,omitempty"`
+}
+
+// Kind is "interaction" or "usage", after the write that failed.
+func (d DeadLetter) Kind() string {
+	if d.Interaction != nil {
+		return "interaction"
+	}
+	return "usage"
+}
+
+// Unseal returns d with its interaction's bodies decrypted with c. Letters
+// that are not sealed are returned as they are.
+func (d DeadLetter) Unseal(c store.BodyCipher) (DeadLetter, error) {
+	if d.Sealed == nil || d.Interaction == nil {
+		return d, nil
+	}
+	if c == nil {
+		return d, errors.New("dead letter is encrypted but no encryption key is configured")
+	}
+	rec := *d.Interaction
+	req, err := openBody(c, d.Sealed.KeyID, rec.UserID, d.Sealed.Request)
+	if err != nil {
+		return d, fmt.Errorf("decrypt request body: %w", err)
+	}
+	resp, err := openBody(c, d.Sealed.KeyID, rec.UserID, d.Sealed.Response)
+	if err != nil {
+		return d, fmt.Errorf("decrypt response body: %w", err)
+	}
+	rec.RequestBody, rec.ResponseBody = string(req), string(resp)
+	d.Interaction, d.Sealed = &rec, nil
+	return d, nil
+}
+
+// seal returns d with its interaction's bodies encrypted with c.
+func (d DeadLetter) seal(c store.BodyCipher) (DeadLetter, error) {
+	if d.Sealed != nil || d.Interaction == nil {
+		return d, nil
+	}
+	rec := *d.Interaction
+	sealed := &SealedBodies{KeyID: c.KeyID()}
+	var err error
+	if rec.RequestBody != "" {
+		if sealed.Request, err = c.Seal(rec.UserID, []byte(rec.RequestBody)); err != nil {
+			return d, err
+		}
+	}
+	if rec.ResponseBody != "" {
+		if sealed.Response, err = c.Seal(rec.UserID, []byte(rec.ResponseBody)); err != nil {
+			return d, err
+		}
+	}
+	rec.RequestBody, rec.ResponseBody = "", ""
+	d.Interaction, d.Sealed = &rec, sealed
+	return d, nil
+}
+
+func openBody(c store.BodyCipher, keyID, tenant string, body []byte) ([]byte, error) {
+	if len(body) == 0 {
+		return nil, nil
+	}
+	return c.Open(keyID, tenant, body)
+}
+
+// DeadLetters appends failed writes to a JSON Lines file. The file lives
+// outside the database on purpose: a full disk or a locked database is
+// usually why the write failed in the first place.
+type DeadLetters struct {
+	path   string
+	cipher store.BodyCipher
+	mu     sync.Mutex
+}
+
+// NewDeadLetters returns a dead-letter file at path, created on the first
+// append.
+func NewDeadLetters(path string) *DeadLetters {
+	return &DeadLetters{path: path}
+}
+
+// Path returns the file the dead letters are appended to.
+func (d *DeadLetters) Path() string {
+	return d.path
+}
+
+// SetCipher encrypts the bodies of interactions appended from now on, so
+// the file holds them no more readably than the database would.
+func (d *DeadLetters) SetCipher(c store.BodyCipher) {
+	d.cipher = c
+}
+
+// Append writes one dead letter. The file is reopened for every append so
+// that a replay can move it aside while the gateway is running.
+func (d *DeadLetters) Append(dl DeadLetter) error {
+	if d.cipher != nil {
+		var err error
+		if dl, err = dl.seal(d.cipher); err != nil {
+			return fmt.Errorf("encrypt dead letter: %w", err)
+		}
+	}
+	line, err := json.Marshal(dl)
+	if err != nil {
+		return err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	// Bodies are kept as received unless a cipher is set, so the file is
+	// readable by its owner only
+	f, err := os.OpenFile(d.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Purge removes the dead letters f matches from the file and returns how
+// many there were. Interactions match on their fields; usage samples only
+// when f selects a user and at most a time range. With dryRun they are
+// only counted.
+func (d *DeadLetters) Purge(f store.LogFilter, dryRun bool) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	letters, err := ReadDeadLetters(d.path)
+	if err != nil {
+		return 0, err
+	}
+	var kept []byte
+	purged := 0
+	for _, dl := range letters {
+		if (dl.Interaction != nil && f.Match(*dl.Interaction)) || (dl.Usage != nil && f.MatchUsage(*dl.Usage)) {
+			purged++
+			continue
+		}
+		line, err := json.Marshal(dl)
+		if err != nil {
+			return 0, err
+		}
+		kept = append(append(kept, line...), '\n')
+	}
+	if dryRun || purged == 0 {
+		return purged, nil
+	}
+	if len(kept) == 0 {
+		return purged, os.Remove(d.path)
+	}
+	tmp := d.path + ".tmp"
+	if err := os.WriteFile(tmp, kept, 0o600); err != nil {
+		return 0, err
+	}
+	return purged, os.Rename(tmp, d.path)
+}
+
+// ReadDeadLetters returns the dead letters in a file; a missing file holds
+// none. Lines that do not decode are reported with their line number.
+func ReadDeadLetters(path string) ([]DeadLetter, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var letters []DeadLetter
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for n := 1; sc.Scan(); n++ {
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		var dl DeadLetter
+		if err := json.Unmarshal(sc.Bytes(), &dl); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, n, err)
+		}
+		if dl.Interaction == nil && dl.Usage == nil {
+			return nil, fmt.Errorf("%s:%d: no interaction or usage", path, n)
+		}
+		letters = append(letters, dl)
+	}
+	return letters, sc.Err()
+}
+
+// SetWriteRetries retries failed interaction and usage writes up to retries
+// times, doubling backoff between attempts, and then appends them to dl.
+// With a nil dl exhausted writes are only logged.
+func (w *Worker) SetWriteRetries(retries int, backoff time.Duration, dl *DeadLetters) {
+	w.writeRetries = retries
+	w.retryBackoff = backoff
+	w.deadLetters = dl
+}
+
+// retryWrite runs write until it succeeds or the retries are exhausted,
+// and returns the last error.
+func (w *Worker) retryWrite(kind string, write func() error) error {
+	err := write()
+	backoff := w.retryBackoff
+	for attempt := 0; err != nil && attempt < w.writeRetries; attempt++ {
+		telemetry.StoreWriteRetriesTotal.WithLabelValues(kind).Inc()
+		time.Sleep(backoff)
+		backoff *= 2
+		err = write()
+	}
+	return err
+}
+
+// deadLetter records a write that failed for good.
+func (w *Worker) deadLetter(dl DeadLetter, err error) {
+	dl.FailedAt = time.Now().UTC()
+	dl.Error = err.Error()
+	telemetry.DeadLettersTotal.WithLabelValues(dl.Kind()).Inc()
+	if w.deadLetters == nil {
+		return
+	}
+	if err := w.deadLetters.Append(dl); err != nil {
+		log.Printf("Failed to write dead letter to %s, %s is lost: %v", w.deadLetters.Path(), dl.Kind(), err)
+	}
+}
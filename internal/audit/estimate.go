@@ -0,0 +1,93 @@
+package audit
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/soroushbar/vantage/pkg/middleware"
+)
+
+// pretokenizer follows the cl100k_base split pattern that tiktoken applies
+// before byte-pair merging. Go's regexp has no lookahead, so a run of
+// spaces is not split before the word that follows it, which only changes
+// counts by a token here and there.
+var pretokenizer = regexp.MustCompile(`(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+`)
+
+// EstimateTokens approximates how many tokens a BPE tokenizer such as
+// tiktoken's or Cohere's splits text into. Merges rarely span a
+// pre-token, and within one they average about six bytes of ASCII per
+// token, while other scripts take about a token per character.
+func EstimateTokens(text string) int {
+	n := 0
+	for _, piece := range pretokenizer.FindAllString(text, -1) {
+		ascii, other := 0, 0
+		for _, r := range piece {
+			if r < utf8.RuneSelf {
+				ascii++
+			} else {
+				other++
+			}
+		}
+		n += max(1, other+(ascii+5)/6)
+	}
+	return n
+}
+
+// estimateUsage counts the prompt text of a request and the generated text
+// of its response locally, for when the upstream reported no usage.
+func estimateUsage(reqBody, respBody []byte) Usage {
+	output := middleware.ResponseText(respBody)
+	if output == "" {
+		output = middleware.StreamText(respBody)
+	}
+	return Usage{
+		InputTokens:  EstimateTokens(requestText(reqBody)),
+		OutputTokens: EstimateTokens(output),
+	}
+}
+
+// settingKeys are request fields whose strings configure the call rather
+// than being sent to the model as text.
+var settingKeys = map[string]bool{
+	"model": true, "role": true, "type": true, "id": true, "tool_call_id": true,
+	"mime_type": true, "mimeType": true, "data": true, "url": true,
+	"input_type": true, "truncate": true, "prompt_truncation": true,
+	"citation_quality": true, "safety_mode": true, "response_format": true,
+	"embedding_types": true, "stop": true, "stop_sequences": true,
+}
+
+// requestText joins every string in a JSON request body apart from the
+// settingKeys, so it covers prompts, chat turns, documents and tool
+// definitions across providers without knowing each schema. Other text
+// bodies are counted whole.
+func requestText(body []byte) string {
+	var doc interface{}
+	if json.Unmarshal(body, &doc) != nil {
+		if utf8.Valid(body) {
+			return string(body)
+		}
+		return ""
+	}
+	var parts []string
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch t := v.(type) {
+		case string:
+			parts = append(parts, t)
+		case []interface{}:
+			for _, e := range t {
+				walk(e)
+			}
+		case map[string]interface{}:
+			for k, e := range t {
+				if !settingKeys[k] {
+					walk(e)
+				}
+			}
+		}
+	}
+	walk(doc)
+	return strings.Join(parts, "\n")
+}
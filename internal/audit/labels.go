@@ -0,0 +1,54 @@
+package audit
+
+import (
+	"github.com/soroushbar/vantage/internal/config"
+	"github.com/soroushbar/vantage/internal/telemetry"
+)
+
+// metricLabels turns models and callers into the label values of the
+// token, cost and per-caller metrics, within the configured limits.
+type metricLabels struct {
+	models  *telemetry.LabelLimiter
+	callers *telemetry.LabelLimiter
+	mode    string
+	teams   map[string]string // user -> team
+}
+
+func newMetricLabels(cfg config.MetricsConfig) *metricLabels {
+	m := &metricLabels{
+		models:  telemetry.NewLabelLimiter(cfg.MaxModels),
+		callers: telemetry.NewLabelLimiter(cfg.MaxCallers),
+		mode:    cfg.CallerLabel,
+		teams:   map[string]string{},
+	}
+	for team, users := range cfg.Teams {
+		for _, user := range users {
+			m.teams[user] = team
+		}
+	}
+	return m
+}
+
+// model returns the label of model, or "unknown" when the request named none.
+func (m *metricLabels) model(model string) string {
+	if model == "" {
+		return "unknown"
+	}
+	return m.models.Value(model)
+}
+
+// caller returns the user label of userID, and false when the per-caller
+// metrics are off.
+func (m *metricLabels) caller(userID string) (string, bool) {
+	switch m.mode {
+	case "none":
+		return "", false
+	case "team":
+		team, ok := m.teams[userID]
+		if !ok {
+			return telemetry.OtherLabel, true
+		}
+		return m.callers.Value(team), true
+	}
+	return m.callers.Value(userID), true
+}
@@ -0,0 +1,96 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/soroushbar/vantage/internal/config"
+	"github.com/soroushbar/vantage/internal/store"
+)
+
+// Sink receives every logged interaction in addition to the SQLite store.
+type Sink interface {
+	Name() string
+	Publish(rec store.InteractionRecord) error
+	Close() error
+}
+
+// Serialization formats supported by sinks.
+const (
+	FormatJSON        = "json"
+	FormatCloudEvents = "cloudevents"
+)
+
+// Delivery guarantees supported by sinks.
+const (
+	AtMostOnce  = "at_most_once"
+	AtLeastOnce = "at_least_once"
+)
+
+// NewSinks builds the message bus sinks described in the config.
+func NewSinks(cfgs []config.SinkConfig) ([]Sink, error) {
+	var sinks []Sink
+	for _, c := range cfgs {
+		if c.Format == "" {
+			c.Format = FormatJSON
+		}
+		if c.Format != FormatJSON && c.Format != FormatCloudEvents {
+			return nil, fmt.Errorf("sink %s: unknown format %q", c.Name, c.Format)
+		}
+		if c.Delivery == "" {
+			c.Delivery = AtLeastOnce
+		}
+		if c.Delivery != AtMostOnce && c.Delivery != AtLeastOnce {
+			return nil, fmt.Errorf("sink %s: unknown delivery guarantee %q", c.Name, c.Delivery)
+		}
+
+		var s Sink
+		var err error
+		switch c.Type {
+		case "kafka":
+			s, err = newKafkaSink(c)
+		case "nats":
+			s, err = newNATSSink(c)
+		default:
+			err = fmt.Errorf("unknown sink type %q", c.Type)
+		}
+		if err != nil {
+			closeSinks(sinks)
+			return nil, fmt.Errorf("sink %s: %w", c.Name, err)
+		}
+		sinks = append(sinks, s)
+	}
+	return sinks, nil
+}
+
+func closeSinks(sinks []Sink) {
+	for _, s := range sinks {
+		s.Close()
+	}
+}
+
+// encodeRecord serializes an interaction in the sink's configured format.
+func encodeRecord(format string, rec store.InteractionRecord) ([]byte, error) {
+	if format != FormatCloudEvents {
+		return json.Marshal(rec)
+	}
+	return json.Marshal(struct {
+		SpecVersion     string                  `json:"specversion"`
+		ID              string                  `json:"id"`
+		Source          string                  `json:"source"`
+		Type            string                  `json:"type"`
+		Time            time.Time               `json:"time"`
+		DataContentType string                  `json:"datacontenttype"`
+		Data            store.InteractionRecord `json:"data"`
+	}{
+		SpecVersion:     "1.0",
+		ID:              strconv.Itoa(rec.ID),
+		Source:          "vantage",
+		Type:            "com.vantage.interaction",
+		Time:            rec.Timestamp.UTC(),
+		DataContentType: "application/json",
+		Data:            rec,
+	})
+}
@@ -0,0 +1,61 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/soroushbar/vantage/internal/config"
+	"github.com/soroushbar/vantage/internal/store"
+)
+
+// kafkaSink publishes interactions to a Kafka topic, keyed by user ID so a
+// caller's interactions stay ordered within a partition.
+type kafkaSink struct {
+	name   string
+	format string
+	writer *kafka.Writer
+}
+
+func newKafkaSink(c config.SinkConfig) (*kafkaSink, error) {
+	if len(c.Brokers) == 0 || c.Topic == "" {
+		return nil, errors.New("kafka sink requires brokers and topic")
+	}
+
+	w := &kafka.Writer{
+		Addr:     kafka.TCP(c.Brokers...),
+		Topic:    c.Topic,
+		Balancer: &kafka.Hash{},
+	}
+	if c.Delivery == AtMostOnce {
+		// Fire and forget: no broker acknowledgement, no retries
+		w.RequiredAcks = kafka.RequireNone
+		w.MaxAttempts = 1
+		w.Async = true
+	} else {
+		w.RequiredAcks = kafka.RequireAll
+		w.MaxAttempts = 10
+	}
+	return &kafkaSink{name: c.Name, format: c.Format, writer: w}, nil
+}
+
+func (k *kafkaSink) Name() string { return k.name }
+
+func (k *kafkaSink) Publish(rec store.InteractionRecord) error {
+	value, err := encodeRecord(k.format, rec)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return k.writer.WriteMessages(ctx, kafka.Message{
+		Key:   []byte(rec.UserID),
+		Value: value,
+		Time:  rec.Timestamp,
+	})
+}
+
+func (k *kafkaSink) Close() error {
+	return k.writer.Close()
+}
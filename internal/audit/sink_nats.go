@@ -0,0 +1,64 @@
+package audit
+
+import (
+	"errors"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/soroushbar/vantage/internal/config"
+	"github.com/soroushbar/vantage/internal/store"
+)
+
+// natsSink publishes interactions to a NATS subject. at_least_once publishes
+// through JetStream and waits for the stream's acknowledgement; at_most_once
+// uses core NATS publish.
+type natsSink struct {
+	name    string
+	format  string
+	subject string
+	conn    *nats.Conn
+	js      nats.JetStreamContext
+}
+
+func newNATSSink(c config.SinkConfig) (*natsSink, error) {
+	if c.URL == "" || c.Topic == "" {
+		return nil, errors.New("nats sink requires url and topic")
+	}
+
+	conn, err := nats.Connect(c.URL, nats.Name("vantage"), nats.MaxReconnects(-1))
+	if err != nil {
+		return nil, err
+	}
+	s := &natsSink{name: c.Name, format: c.Format, subject: c.Topic, conn: conn}
+	if c.Delivery == AtLeastOnce {
+		js, err := conn.JetStream(nats.MaxWait(10 * time.Second))
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		s.js = js
+	}
+	return s, nil
+}
+
+func (n *natsSink) Name() string { return n.name }
+
+func (n *natsSink) Publish(rec store.InteractionRecord) error {
+	data, err := encodeRecord(n.format, rec)
+	if err != nil {
+		return err
+	}
+	if n.js == nil {
+		return n.conn.Publish(n.subject, data)
+	}
+	_, err = n.js.Publish(n.subject, data, nats.RetryAttempts(5))
+	return err
+}
+
+func (n *natsSink) Close() error {
+	if err := n.conn.Drain(); err != nil {
+		n.conn.Close()
+		return err
+	}
+	return nil
+}
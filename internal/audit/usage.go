@@ -0,0 +1,186 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"github.com/soroushbar/vantage/pkg/providers/bedrock"
+	"github.com/soroushbar/vantage/pkg/providers/gemini"
+	"github.com/soroushbar/vantage/pkg/providers/local"
+)
+
+// Usage is the billing information extracted from an upstream response.
+type Usage struct {
+	Provider     string
+	Endpoint     string
+	InputTokens  int
+	OutputTokens int
+	SearchUnits  int
+}
+
+// Total returns the combined input and output token count.
+func (u Usage) Total() int {
+	return u.InputTokens + u.OutputTokens
+}
+
+type usageCounts struct {
+	InputTokens  float64 `json:"input_tokens"`
+	OutputTokens float64 `json:"output_tokens"`
+	SearchUnits  float64 `json:"search_units"`
+}
+
+type usageBlock struct {
+	BilledUnits *usageCounts `json:"billed_units"`
+	Tokens      *usageCounts `json:"tokens"`
+}
+
+type cohereResponse struct {
+	Meta  *usageBlock `json:"meta"`
+	Usage *usageBlock `json:"usage"`
+}
+
+// cohereStreamEvent covers the final events of streamed chat responses:
+// v1 tags lines with "event_type", v2 with "type".
+type cohereStreamEvent struct {
+	EventType string          `json:"event_type"`
+	Response  *cohereResponse `json:"response"`
+	Type      string          `json:"type"`
+	Delta     *struct {
+		Usage *usageBlock `json:"usage"`
+	} `json:"delta"`
+}
+
+// usageSource selects the block of a Cohere response that carries usage.
+type usageSource func(r *cohereResponse) *usageBlock
+
+func fromMeta(r *cohereResponse) *usageBlock  { return r.Meta }
+func fromUsage(r *cohereResponse) *usageBlock { return r.Usage }
+
+// cohereEndpoints maps each known Cohere endpoint to where its response
+// schema reports usage. v1 and the v2 embed/rerank endpoints use "meta",
+// while v2 chat moved it to a top-level "usage" object.
+var cohereEndpoints = map[string]usageSource{
+	"/v1/chat":   fromMeta,
+	"/v1/embed":  fromMeta,
+	"/v1/rerank": fromMeta,
+	"/v2/chat":   fromUsage,
+	"/v2/embed":  fromMeta,
+	"/v2/rerank": fromMeta,
+}
+
+// endpointFor normalizes a request path to a known endpoint, or "" if the
+// path is not one we know how to account for.
+func endpointFor(path string) string {
+	path = strings.TrimSuffix(path, "/")
+	if _, ok := cohereEndpoints[path]; ok {
+		return path
+	}
+	return ""
+}
+
+// ParseUsage extracts token usage from a response body for the given path.
+// The boolean is false when the endpoint is unknown.
+func ParseUsage(path string, body []byte) (Usage, bool, error) {
+	if path == gemini.OpenAIPath {
+		usage, err := parseCompatUsage("gemini", strings.TrimPrefix(path, gemini.PathPrefix), body)
+		return usage, true, err
+	}
+	if strings.HasPrefix(path, gemini.PathPrefix+"/") {
+		endpoint := geminiEndpoint(path)
+		if endpoint == "" {
+			return Usage{}, false, nil
+		}
+		usage, err := parseGeminiUsage(endpoint, body)
+		return usage, true, err
+	}
+	if strings.HasPrefix(path, bedrock.PathPrefix+"/") {
+		endpoint := bedrockEndpoint(path)
+		if endpoint == "" {
+			return Usage{}, false, nil
+		}
+		usage, err := parseBedrockUsage(endpoint, body)
+		return usage, true, err
+	}
+	if provider, endpoint, ok := compatEndpoint(path); ok {
+		if endpoint == "" {
+			return Usage{}, false, nil
+		}
+		usage, err := parseCompatUsage(provider, endpoint, body)
+		return usage, true, err
+	}
+	if strings.HasPrefix(path, local.PathPrefix+"/") {
+		name, endpoint := localEndpoint(path)
+		if endpoint == "" {
+			return Usage{}, false, nil
+		}
+		usage, err := parseLocalUsage(name, endpoint, body)
+		return usage, true, err
+	}
+
+	endpoint := endpointFor(path)
+	if endpoint == "" {
+		return Usage{}, false, nil
+	}
+	usage, err := parseCohereUsage(endpoint, body)
+	return usage, true, err
+}
+
+// parseCohereUsage reads usage from a JSON response, or from the final event
+// of a stream: a v1 "stream-end" line carries the full response, while v2
+// sends a "message-end" server-sent event with usage in its delta.
+func parseCohereUsage(endpoint string, body []byte) (Usage, error) {
+	usage := Usage{Provider: "cohere", Endpoint: endpoint}
+
+	var resp cohereResponse
+	err := json.Unmarshal(body, &resp)
+	if err == nil {
+		usage.add(cohereEndpoints[endpoint](&resp))
+		return usage, nil
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 64*1024), len(body)+1)
+	found := false
+	for scanner.Scan() {
+		line := strings.TrimPrefix(strings.TrimSpace(scanner.Text()), "data: ")
+		var event cohereStreamEvent
+		if line == "" || json.Unmarshal([]byte(line), &event) != nil {
+			continue
+		}
+		found = true
+		switch {
+		case event.EventType == "stream-end" && event.Response != nil:
+			usage.add(event.Response.Meta)
+		case event.Type == "message-end" && event.Delta != nil:
+			usage.add(event.Delta.Usage)
+		}
+	}
+	if !found {
+		return usage, err
+	}
+	return usage, nil
+}
+
+// add sets the counts of u from block, preferring billed units and falling
+// back to raw token counts.
+func (u *Usage) add(block *usageBlock) {
+	if block == nil {
+		return
+	}
+	counts := block.BilledUnits
+	if counts == nil || (counts.InputTokens == 0 && counts.OutputTokens == 0 && counts.SearchUnits == 0) {
+		if block.Tokens != nil {
+			counts = block.Tokens
+		}
+	}
+	if counts != nil {
+		u.InputTokens = int(counts.InputTokens)
+		u.OutputTokens = int(counts.OutputTokens)
+		u.SearchUnits = int(counts.SearchUnits)
+	}
+	if block.BilledUnits != nil && u.SearchUnits == 0 {
+		u.SearchUnits = int(block.BilledUnits.SearchUnits)
+	}
+}
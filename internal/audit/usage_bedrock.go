@@ -0,0 +1,108 @@
+package audit
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/soroushbar/vantage/pkg/providers/bedrock"
+)
+
+// bedrockResponse covers the usage fields of Bedrock responses: Converse
+// reports camelCase "usage", Anthropic models on InvokeModel report
+// snake_case "usage", and streamed chunks end with invocation metrics.
+type bedrockResponse struct {
+	Usage *struct {
+		InputTokens       float64 `json:"inputTokens"`
+		OutputTokens      float64 `json:"outputTokens"`
+		InputTokensSnake  float64 `json:"input_tokens"`
+		OutputTokensSnake float64 `json:"output_tokens"`
+	} `json:"usage"`
+	InvocationMetrics *struct {
+		InputTokenCount  float64 `json:"inputTokenCount"`
+		OutputTokenCount float64 `json:"outputTokenCount"`
+	} `json:"amazon-bedrock-invocationMetrics"`
+	// Bytes is the base64 model chunk of an invoke-with-response-stream event
+	Bytes string `json:"bytes"`
+}
+
+// counts returns the token counts in r, if it carries any.
+func (r *bedrockResponse) counts() (input, output int, ok bool) {
+	switch {
+	case r.InvocationMetrics != nil:
+		return int(r.InvocationMetrics.InputTokenCount), int(r.InvocationMetrics.OutputTokenCount), true
+	case r.Usage != nil:
+		return int(r.Usage.InputTokens + r.Usage.InputTokensSnake), int(r.Usage.OutputTokens + r.Usage.OutputTokensSnake), true
+	}
+	return 0, 0, false
+}
+
+// bedrockEndpoint maps a proxied Bedrock path to the Runtime operation it
+// calls, or "" for paths that are not billed per token.
+func bedrockEndpoint(path string) string {
+	op := path[strings.LastIndex(path, "/")+1:]
+	for _, o := range bedrock.Operations {
+		if op == o {
+			return "bedrock:" + op
+		}
+	}
+	return ""
+}
+
+// parseBedrockUsage reads token counts from a JSON response or, for
+// streamed operations, from the last event-stream message that has them.
+func parseBedrockUsage(endpoint string, body []byte) (Usage, error) {
+	usage := Usage{Provider: "bedrock", Endpoint: endpoint}
+
+	var messages [][]byte
+	if strings.HasSuffix(endpoint, "-stream") {
+		var err error
+		if messages, err = eventStreamPayloads(body); err != nil {
+			return usage, err
+		}
+	} else {
+		messages = [][]byte{body}
+	}
+
+	for _, payload := range messages {
+		var resp bedrockResponse
+		if err := json.Unmarshal(payload, &resp); err != nil {
+			if len(messages) == 1 {
+				return usage, err
+			}
+			continue
+		}
+		if resp.Bytes != "" {
+			chunk, err := base64.StdEncoding.DecodeString(resp.Bytes)
+			if err != nil || json.Unmarshal(chunk, &resp) != nil {
+				continue
+			}
+		}
+		if in, out, ok := resp.counts(); ok {
+			usage.InputTokens, usage.OutputTokens = in, out
+		}
+	}
+	return usage, nil
+}
+
+// eventStreamPayloads splits an application/vnd.amazon.eventstream body
+// into message payloads. Each message is a 12-byte prelude (total length,
+// headers length, CRC), the headers, the payload and a 4-byte CRC.
+func eventStreamPayloads(body []byte) ([][]byte, error) {
+	var payloads [][]byte
+	for len(body) > 0 {
+		if len(body) < 16 {
+			return payloads, errors.New("truncated event stream")
+		}
+		total := int(binary.BigEndian.Uint32(body[0:4]))
+		headers := int(binary.BigEndian.Uint32(body[4:8]))
+		if total < 16+headers || total > len(body) {
+			return payloads, errors.New("malformed event stream message")
+		}
+		payloads = append(payloads, body[12+headers:total-4])
+		body = body[total:]
+	}
+	return payloads, nil
+}
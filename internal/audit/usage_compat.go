@@ -0,0 +1,101 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/soroushbar/vantage/pkg/providers/groq"
+	"github.com/soroushbar/vantage/pkg/providers/mistral"
+)
+
+// compatProviders are the hosted OpenAI-compatible APIs, by mount prefix.
+// Cohere's sits under /compatibility on the Cohere API itself.
+var compatProviders = map[string]string{
+	mistral.PathPrefix: "mistral",
+	groq.PathPrefix:    "groq",
+	"/compatibility":   "cohere",
+}
+
+// compatEndpoints are their routes billed per token: chat, Mistral's
+// fill-in-the-middle and legacy completions, and embeddings.
+var compatEndpoints = []string{"/v1/chat/completions", "/v1/fim/completions", "/v1/completions", "/v1/embeddings"}
+
+type compatUsage struct {
+	PromptTokens     float64 `json:"prompt_tokens"`
+	CompletionTokens float64 `json:"completion_tokens"`
+}
+
+// compatResponse is a response or stream chunk. Groq reports the usage of a
+// stream in x_groq on its last chunk, Mistral in the usual field.
+type compatResponse struct {
+	Usage *compatUsage `json:"usage"`
+	XGroq *struct {
+		Usage *compatUsage `json:"usage"`
+	} `json:"x_groq"`
+}
+
+func (r *compatResponse) usage() *compatUsage {
+	if r.Usage == nil && r.XGroq != nil {
+		return r.XGroq.Usage
+	}
+	return r.Usage
+}
+
+// compatEndpoint splits a path under a compatible provider's prefix into the
+// provider and the route it calls, which is "" when it is not billed per
+// token. ok is false for other paths.
+func compatEndpoint(path string) (provider, endpoint string, ok bool) {
+	for prefix, name := range compatProviders {
+		route, found := strings.CutPrefix(path, prefix+"/")
+		if !found {
+			continue
+		}
+		route = "/" + strings.TrimSuffix(route, "/")
+		for _, e := range compatEndpoints {
+			if route == e {
+				return name, route, true
+			}
+		}
+		return name, "", true
+	}
+	return "", "", false
+}
+
+// parseCompatUsage reads token counts from a JSON response, or from the
+// chunk of a server-sent event stream that carries them.
+func parseCompatUsage(provider, endpoint string, body []byte) (Usage, error) {
+	usage := Usage{Provider: provider, Endpoint: endpoint}
+
+	var resp compatResponse
+	if err := json.Unmarshal(body, &resp); err == nil {
+		if u := resp.usage(); u != nil {
+			usage.InputTokens, usage.OutputTokens = int(u.PromptTokens), int(u.CompletionTokens)
+		}
+		return usage, nil
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 64*1024), len(body)+1)
+	found := false
+	for scanner.Scan() {
+		line := strings.TrimPrefix(strings.TrimSpace(scanner.Text()), "data: ")
+		if line == "" || line == "[DONE]" {
+			continue
+		}
+		var chunk compatResponse
+		if json.Unmarshal([]byte(line), &chunk) != nil {
+			continue
+		}
+		found = true
+		if u := chunk.usage(); u != nil {
+			usage.InputTokens, usage.OutputTokens = int(u.PromptTokens), int(u.CompletionTokens)
+		}
+	}
+	if !found {
+		return usage, errors.New("response is neither JSON nor a server-sent event stream")
+	}
+	return usage, nil
+}
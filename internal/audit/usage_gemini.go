@@ -0,0 +1,86 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"github.com/soroushbar/vantage/pkg/providers/gemini"
+)
+
+type geminiUsage struct {
+	PromptTokenCount     float64 `json:"promptTokenCount"`
+	CandidatesTokenCount float64 `json:"candidatesTokenCount"`
+}
+
+type geminiResponse struct {
+	UsageMetadata *geminiUsage `json:"usageMetadata"`
+}
+
+// geminiEndpoint maps a proxied Gemini path to the API method it calls, or
+// "" for methods that are not billed per token.
+func geminiEndpoint(path string) string {
+	if path == gemini.ChatCompletionsPath {
+		return "gemini:generateContent"
+	}
+	i := strings.LastIndex(path, ":")
+	if i < 0 {
+		return ""
+	}
+	switch method := path[i+1:]; method {
+	case "generateContent", "streamGenerateContent":
+		return "gemini:" + method
+	}
+	return ""
+}
+
+// geminiModel returns the model named in a native Gemini path,
+// /gemini/{version}/models/{model}:{method}, or "".
+func geminiModel(path string) string {
+	if !strings.HasPrefix(path, gemini.PathPrefix+"/") {
+		return ""
+	}
+	_, rest, ok := strings.Cut(path, "/models/")
+	if !ok {
+		return ""
+	}
+	model, _, _ := strings.Cut(rest, ":")
+	return model
+}
+
+// parseGeminiUsage reads usageMetadata from a JSON response, or from the
+// last server-sent event that carries it for streamed responses.
+func parseGeminiUsage(endpoint string, body []byte) (Usage, error) {
+	usage := Usage{Provider: "gemini", Endpoint: endpoint}
+
+	var meta *geminiUsage
+	var resp geminiResponse
+	if err := json.Unmarshal(body, &resp); err == nil {
+		meta = resp.UsageMetadata
+	} else {
+		scanner := bufio.NewScanner(bytes.NewReader(body))
+		scanner.Buffer(make([]byte, 64*1024), len(body)+1)
+		found := false
+		for scanner.Scan() {
+			data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+			if !ok {
+				continue
+			}
+			found = true
+			var chunk geminiResponse
+			if json.Unmarshal([]byte(data), &chunk) == nil && chunk.UsageMetadata != nil {
+				meta = chunk.UsageMetadata
+			}
+		}
+		if !found {
+			return usage, err
+		}
+	}
+
+	if meta != nil {
+		usage.InputTokens = int(meta.PromptTokenCount)
+		usage.OutputTokens = int(meta.CandidatesTokenCount)
+	}
+	return usage, nil
+}
@@ -0,0 +1,92 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/soroushbar/vantage/pkg/providers/local"
+)
+
+// localEndpoints are the generation routes of self-hosted servers: Ollama's
+// native API, the OpenAI-compatible API of vLLM, Ollama and llama.cpp, and
+// llama.cpp's native completion route.
+var localEndpoints = []string{"/api/chat", "/api/generate", "/chat/completions", "/completions", "/completion"}
+
+// localResponse covers the usage fields of every local response format.
+type localResponse struct {
+	// Ollama native
+	PromptEvalCount float64 `json:"prompt_eval_count"`
+	EvalCount       float64 `json:"eval_count"`
+	// OpenAI-compatible
+	Usage *struct {
+		PromptTokens     float64 `json:"prompt_tokens"`
+		CompletionTokens float64 `json:"completion_tokens"`
+	} `json:"usage"`
+	// llama.cpp native
+	TokensEvaluated float64 `json:"tokens_evaluated"`
+	TokensPredicted float64 `json:"tokens_predicted"`
+}
+
+func (r *localResponse) counts() (input, output int, ok bool) {
+	switch {
+	case r.Usage != nil:
+		return int(r.Usage.PromptTokens), int(r.Usage.CompletionTokens), true
+	case r.PromptEvalCount > 0 || r.EvalCount > 0:
+		return int(r.PromptEvalCount), int(r.EvalCount), true
+	case r.TokensEvaluated > 0 || r.TokensPredicted > 0:
+		return int(r.TokensEvaluated), int(r.TokensPredicted), true
+	}
+	return 0, 0, false
+}
+
+// localEndpoint splits /local/{name}/... into the provider name and the
+// generation route it calls, or "" for routes that are not billed per token.
+func localEndpoint(path string) (name, endpoint string) {
+	rest := strings.TrimPrefix(path, local.PathPrefix+"/")
+	name, route, _ := strings.Cut(rest, "/")
+	route = "/" + strings.TrimSuffix(route, "/")
+	for _, e := range localEndpoints {
+		if strings.HasSuffix(route, e) {
+			return name, route
+		}
+	}
+	return name, ""
+}
+
+// parseLocalUsage reads token counts from a JSON response, or from the last
+// line carrying them in an NDJSON (Ollama) or server-sent event stream.
+func parseLocalUsage(name, endpoint string, body []byte) (Usage, error) {
+	usage := Usage{Provider: name, Endpoint: endpoint}
+
+	var resp localResponse
+	if err := json.Unmarshal(body, &resp); err == nil {
+		usage.InputTokens, usage.OutputTokens, _ = resp.counts()
+		return usage, nil
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 64*1024), len(body)+1)
+	found := false
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		line = strings.TrimPrefix(line, "data: ")
+		if line == "" || line == "[DONE]" {
+			continue
+		}
+		var chunk localResponse
+		if json.Unmarshal([]byte(line), &chunk) != nil {
+			continue
+		}
+		found = true
+		if in, out, ok := chunk.counts(); ok {
+			usage.InputTokens, usage.OutputTokens = in, out
+		}
+	}
+	if !found {
+		return usage, errors.New("response is neither JSON nor a JSON stream")
+	}
+	return usage, nil
+}
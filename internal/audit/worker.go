@@ -8,28 +8,135 @@ import (
 	"log"
 	"net/http"
 	"strings"
+	"time"
 
+	"github.com/soroushbar/vantage/internal/config"
+	"github.com/soroushbar/vantage/internal/latency"
+	"github.com/soroushbar/vantage/internal/notify"
+	"github.com/soroushbar/vantage/internal/store"
 	"github.com/soroushbar/vantage/internal/telemetry"
 	"github.com/soroushbar/vantage/pkg/middleware"
 )
 
 // Store interface for decoupling
 type Store interface {
-	LogInteractionDetailed(userID, method, path string, reqBody, respBody []byte, statusCode int, latencyMs int64, tokens int, safetyScore float64, isBlocked, isRedacted bool) error
+	LogInteraction(rec store.InteractionRecord) (int64, error)
+	RecordArtifact(a store.ModelArtifact) error
+	AddConversationTokens(userID, conversationID string, tokens int) error
+	RecordUsage(u store.UsageSample) error
+	PendingBackfill(limit int) ([]store.BackfillRow, error)
+	RecordBackfill(rows []store.BackfillRow) error
+	QuarantinePayload(p *store.QuarantinedPayload) error
+	LinkQuarantine(id, logID int64) error
+	FlagInteraction(t store.Triage) error
+}
+
+// Notifier receives policy-violation events.
+type Notifier interface {
+	Publish(e notify.Event)
+}
+
+// Analytics receives every interaction with the usage it was rolled up as,
+// for an analytics database such as ClickHouse.
+type Analytics interface {
+	Record(rec store.InteractionRecord, u store.UsageSample)
 }
 
 // Worker processes interactions from the audit channel.
 type Worker struct {
-	auditChan <-chan middleware.Interaction
-	store     Store
-	cohereKey string
+	auditChan       <-chan middleware.Interaction
+	store           Store
+	cohereKey       func() string
+	notifier        Notifier
+	safetyThreshold float64
+	sinks           []Sink
+	pricing         config.Pricing
+	skipSafety      map[string]bool
+	slowThreshold   time.Duration
+	slo             *latency.SLOTracker
+	responseSafety  bool
+	responseCutoff  float64
+	moderator       Moderator
+	moderationLimit map[string]float64
+	writeRetries    int
+	retryBackoff    time.Duration
+	deadLetters     *DeadLetters
+	quarantine      bool
+	analytics       Analytics
+	labels          *metricLabels
 }
 
-func NewWorker(auditChan <-chan middleware.Interaction, store Store, cohereKey string) *Worker {
+func NewWorker(auditChan <-chan middleware.Interaction, store Store, cohereKey string, notifier Notifier, safetyThreshold float64, sinks ...Sink) *Worker {
 	return &Worker{
-		auditChan: auditChan,
-		store:     store,
-		cohereKey: cohereKey,
+		auditChan:       auditChan,
+		store:           store,
+		cohereKey:       func() string { return cohereKey },
+		notifier:        notifier,
+		safetyThreshold: safetyThreshold,
+		sinks:           sinks,
+		labels:          newMetricLabels(config.MetricsConfig{CallerLabel: "user"}),
+	}
+}
+
+// SetPricing sets the token prices used to estimate the cost of each
+// interaction in the usage rollups.
+func (w *Worker) SetPricing(p config.Pricing) {
+	w.pricing = p
+}
+
+// SetLatency flags interactions slower than slowThreshold (zero disables
+// the flag) and, with a non-nil tracker, counts them against the latency SLOs.
+func (w *Worker) SetLatency(slowThreshold time.Duration, slo *latency.SLOTracker) {
+	w.slowThreshold = slowThreshold
+	w.slo = slo
+}
+
+// SetResponseSafety classifies the generated text of successful responses
+// that were not already classified before they were returned, and notifies
+// when it scores below threshold.
+func (w *Worker) SetResponseSafety(enabled bool, threshold float64) {
+	w.responseSafety = enabled
+	w.responseCutoff = threshold
+}
+
+// SetModeration scores prompts, and generated text under response safety,
+// with m in each harm category, flagging prompts whose score in a category
+// reaches its threshold. Safety scores are derived from the categories.
+func (w *Worker) SetModeration(m Moderator, thresholds map[string]float64) {
+	w.moderator = m
+	w.moderationLimit = thresholds
+}
+
+// SetQuarantine moves the request bodies of blocked interactions to the
+// quarantine; their log entries are written without one.
+func (w *Worker) SetQuarantine(enabled bool) {
+	w.quarantine = enabled
+}
+
+// SetAPIKeySource reads the Cohere key on every classification instead of
+// using the one the worker was created with.
+func (w *Worker) SetAPIKeySource(key func() string) {
+	w.cohereKey = key
+}
+
+// SetMetricLabels sets how models and callers are labeled in the token,
+// cost and per-caller metrics. Without it every caller and model gets its
+// own series.
+func (w *Worker) SetMetricLabels(cfg config.MetricsConfig) {
+	w.labels = newMetricLabels(cfg)
+}
+
+// SetAnalytics also records every interaction in a.
+func (w *Worker) SetAnalytics(a Analytics) {
+	w.analytics = a
+}
+
+// SkipSafetyAudit turns off the safety classification of interactions
+// made under the given trust tiers.
+func (w *Worker) SkipSafetyAudit(tiers ...string) {
+	w.skipSafety = map[string]bool{}
+	for _, t := range tiers {
+		w.skipSafety[t] = true
 	}
 }
 
@@ -41,6 +148,7 @@ func (w *Worker) Start(ctx context.Context) {
 			select {
 			case <-ctx.Done():
 				log.Println("Audit Worker stopping...")
+				closeSinks(w.sinks)
 				return
 			case interaction, ok := <-w.auditChan:
 				if !ok {
@@ -62,86 +170,522 @@ func (w *Worker) processInteraction(i middleware.Interaction) {
 
 	// 1. Update Metrics
 	telemetry.HttpRequestsTotal.WithLabelValues(i.Method, i.Path, fmt.Sprintf("%d", i.StatusCode)).Inc()
-	telemetry.HttpRequestDuration.WithLabelValues(i.Method, i.Path).Observe(i.Duration.Seconds())
+	telemetry.ObserveDuration(telemetry.HttpRequestDuration.WithLabelValues(i.Method, i.Path), i.Duration, i.TraceID)
+	caller, perCaller := w.labels.caller(i.UserID)
+	if perCaller {
+		telemetry.UserRequestsTotal.WithLabelValues(caller, fmt.Sprintf("%d", i.StatusCode)).Inc()
+	}
+	if i.IsBlocked {
+		telemetry.BlockedRequestsTotal.WithLabelValues(i.Path, blockReason(i.ResponseBody)).Inc()
+	}
+	if i.IsRedacted {
+		telemetry.RedactedRequestsTotal.WithLabelValues(i.Path).Inc()
+	}
+	leak := responsePII(i)
+	if leak != nil {
+		for _, f := range leak.Findings {
+			telemetry.ResponsePIITotal.WithLabelValues(f.PIIType, leak.Action).Add(float64(f.Count))
+		}
+	}
+	cacheHit := i.CacheStatus == "HIT"
+	if i.CacheStatus != "" {
+		telemetry.CacheRequestsTotal.WithLabelValues(i.Path, strings.ToLower(i.CacheStatus)).Inc()
+	}
+	// Duplicates never reached the provider; a shared one carries the
+	// original's usage, which was billed once
+	duplicate := i.Dedup != ""
+	if duplicate {
+		telemetry.DedupRequestsTotal.WithLabelValues(i.Path, strings.ToLower(i.Dedup)).Inc()
+	}
 
-	// 2. Parse Tokens (if it's a Cohere response)
+	// 2. Parse Tokens from the endpoint's usage metadata
+	requested := requestedModel(i.RequestBody)
+	if requested == "" {
+		requested = geminiModel(i.Path)
+	}
+	model := i.RoutedModel
+	if model == "" {
+		model = requested
+	}
 	tokens := 0
-	if i.StatusCode == 200 && (strings.Contains(i.Path, "/chat")) {
-		var raw map[string]interface{}
-		if err := json.Unmarshal(i.ResponseBody, &raw); err == nil {
-			// Deep dive into the map to find tokens
-			if meta, ok := raw["meta"].(map[string]interface{}); ok {
-				// Try billed_units
-				if bu, ok := meta["billed_units"].(map[string]interface{}); ok {
-					if it, ok := bu["input_tokens"].(float64); ok {
-						tokens += int(it)
-					}
-					if ot, ok := bu["output_tokens"].(float64); ok {
-						tokens += int(ot)
-					}
-				}
-				// Try tokens
-				if tk, ok := meta["tokens"].(map[string]interface{}); ok {
-					if it, ok := tk["input_tokens"].(float64); ok {
-						tokens += int(it)
-					}
-					if ot, ok := tk["output_tokens"].(float64); ok {
-						tokens += int(ot)
-					}
-				}
+	var billed Usage
+	if i.StatusCode == 200 {
+		usage, known, err := ParseUsage(i.Path, i.ResponseBody)
+		switch {
+		case !known:
+			log.Printf("Skipping token parse: unknown endpoint Path=%s RequestID=%s", i.Path, i.RequestID)
+		case err != nil:
+			log.Printf("Failed to unmarshal response RequestID=%s: %v", i.RequestID, err)
+		case cacheHit:
+			// Replayed responses were not billed again
+			telemetry.CacheTokensSavedTotal.WithLabelValues(usage.Provider, usage.Endpoint).Add(float64(usage.Total()))
+		case duplicate:
+		default:
+			billed = usage
+			tokens = usage.Total()
+			if tokens > 0 {
+				w.countTokens(usage, model, caller, perCaller)
 			}
-
-			if tokens == 0 {
-				log.Printf("Token detection failed. Raw Meta: %+v", raw["meta"])
-			} else {
-				telemetry.TokenUsageTotal.WithLabelValues("cohere").Add(float64(tokens))
+			if usage.SearchUnits > 0 {
+				telemetry.SearchUnitsTotal.WithLabelValues(usage.Provider, usage.Endpoint).Add(float64(usage.SearchUnits))
+			}
+			if tokens == 0 && usage.SearchUnits == 0 {
+				log.Printf("Token detection failed for %s RequestID=%s", usage.Endpoint, i.RequestID)
 			}
-		} else {
-			log.Printf("Failed to unmarshal response: %v", err)
 		}
 	} else {
-		log.Printf("Skipping token parse: Status=%d Path=%s", i.StatusCode, i.Path)
+		log.Printf("Skipping token parse: Status=%d Path=%s RequestID=%s", i.StatusCode, i.Path, i.RequestID)
+	}
+	// Without upstream usage, as for streams, errors and unknown endpoints,
+	// fall back to counting the bodies locally
+	estimated := false
+	if tokens == 0 && billed.SearchUnits == 0 && !cacheHit && !duplicate && !i.IsBlocked {
+		if tokens = estimateUsage(i.RequestBody, i.ResponseBody).Total(); tokens > 0 {
+			estimated = true
+			telemetry.EstimatedTokensTotal.WithLabelValues(latency.Route(i.Path)).Add(float64(tokens))
+		}
 	}
 
-	// 3. Safety Check: Call Classify to detect toxicity/safety
-	safetyScore := w.performSafetyAudit(i.RequestBody)
+	// 3. Safety Check: Call Classify to detect toxicity in the prompt and, when enabled, the response.
+	// Trust tiers on the fast path are not scored rather than scored as safe
+	var safetyScore *float64
+	moderation := w.moderation(i)
+	switch {
+	case moderation != nil:
+		score := moderation.SafetyScore()
+		safetyScore = &score
+		for _, c := range moderation.Flagged {
+			telemetry.ModerationFlaggedTotal.WithLabelValues(c).Inc()
+		}
+	case w.skipSafety[i.TrustTier]:
+	case w.moderator == nil:
+		score := w.performSafetyAudit(i.RequestBody)
+		safetyScore = &score
+	default:
+		// Moderation failed; assumed safe, as failed Classify calls are
+		score := 1.0
+		safetyScore = &score
+	}
+	responseSafety := i.ResponseSafety
+	if responseSafety == nil && w.responseSafety && i.StatusCode == 200 && !w.skipSafety[i.TrustTier] {
+		if text := middleware.ResponseText(i.ResponseBody); text != "" {
+			score := w.classifyText(text)
+			responseSafety = &score
+		}
+	}
 
 	// 4. Commit to SQLite
-	err := w.store.LogInteractionDetailed(
-		i.UserID,
-		i.Method,
-		i.Path,
-		i.RequestBody,
-		i.ResponseBody,
-		i.StatusCode,
-		i.Duration.Milliseconds(),
-		tokens,
-		safetyScore,
-		i.IsBlocked,
-		i.IsRedacted,
-	)
+	rec := store.InteractionRecord{
+		Timestamp:    i.Timestamp,
+		UserID:       i.UserID,
+		Method:       i.Method,
+		Path:         i.Path,
+		RequestBody:  string(i.RequestBody),
+		ResponseBody: string(i.ResponseBody),
+		StatusCode:   i.StatusCode,
+		LatencyMs:    i.Duration.Milliseconds(),
+		Tokens:       tokens,
+		SafetyScore:  safetyScore,
+		IsBlocked:    i.IsBlocked,
+		IsRedacted:   i.IsRedacted,
+		Template:     i.Template,
+		CacheHit:     cacheHit,
+		Model:        requested,
+		RoutedModel:  i.RoutedModel,
+		IsSlow:       w.slowThreshold > 0 && i.Duration > w.slowThreshold,
+		Session:      i.Session,
+		Project:      i.Project,
+		RequestID:    i.RequestID,
+		Estimated:    estimated,
+	}
+	rec.ResponseSafety = responseSafety
+	// Blocked requests never reach the provider and would flatter the SLOs
+	if w.slo != nil && !i.IsBlocked {
+		w.slo.Observe(i.Path, i.Duration, i.Timestamp)
+	}
+	if i.Metadata != "" {
+		rec.Metadata = json.RawMessage(i.Metadata)
+	}
+	if i.Truncation != "" {
+		rec.Truncation = json.RawMessage(i.Truncation)
+	}
+	if i.Transformation != "" {
+		rec.Transformation = json.RawMessage(i.Transformation)
+	}
+	if i.Redactions != "" {
+		rec.RedactionReport = json.RawMessage(i.Redactions)
+	}
+	if i.ResponsePII != "" {
+		rec.ResponsePII = json.RawMessage(i.ResponsePII)
+	}
+	if i.ParamOverrides != "" {
+		rec.ParamOverrides = json.RawMessage(i.ParamOverrides)
+	}
+	if moderation != nil {
+		rec.Moderation, _ = json.Marshal(moderation)
+	}
+	if i.Verdict != "" {
+		rec.Verdict = json.RawMessage(i.Verdict)
+	}
+	if i.Headers != "" {
+		rec.Headers = json.RawMessage(i.Headers)
+	}
+	// A payload that cannot be quarantined stays in the log rather than being lost
+	var quarantined *store.QuarantinedPayload
+	if w.quarantine && i.IsBlocked && len(i.RequestBody) > 0 {
+		quarantined = &store.QuarantinedPayload{
+			CreatedAt: i.Timestamp,
+			UserID:    i.UserID,
+			Method:    i.Method,
+			Path:      i.Path,
+			Reason:    blockReason(i.ResponseBody),
+			Body:      string(i.RequestBody),
+		}
+		if err := w.retryWrite("quarantine", func() error { return w.store.QuarantinePayload(quarantined) }); err != nil {
+			log.Printf("Failed to quarantine blocked payload, logging it instead: %v", err)
+			quarantined = nil
+		} else {
+			rec.RequestBody = ""
+		}
+	}
+	var logID int64
+	err := w.retryWrite("interaction", func() (err error) {
+		logID, err = w.store.LogInteraction(rec)
+		return err
+	})
+	if err != nil {
+		log.Printf("Failed to log interaction RequestID=%s: %v", i.RequestID, err)
+		w.deadLetter(DeadLetter{Interaction: &rec}, err)
+	}
+	rec.ID = int(logID)
+	if quarantined != nil && logID != 0 {
+		if err := w.store.LinkQuarantine(quarantined.ID, logID); err != nil {
+			log.Printf("Failed to link quarantined payload %d to interaction %d: %v", quarantined.ID, logID, err)
+		}
+	}
+
+	// 5. Roll usage up for the dashboard summary
+	sample := store.UsageSample{
+		Time:         i.Timestamp,
+		UserID:       i.UserID,
+		Model:        model,
+		InputTokens:  billed.InputTokens,
+		OutputTokens: billed.OutputTokens,
+		Cost:         w.pricing.Cost(model, billed.InputTokens, billed.OutputTokens),
+		LatencyMs:    rec.LatencyMs,
+		Blocked:      i.IsBlocked,
+		Redacted:     i.IsRedacted,
+		Route:        latency.Route(i.Path),
+		Project:      i.Project,
+	}
+	if err := w.retryWrite("usage", func() error { return w.store.RecordUsage(sample) }); err != nil {
+		log.Printf("Failed to record usage rollup: %v", err)
+		w.deadLetter(DeadLetter{Usage: &sample}, err)
+	}
+	if w.analytics != nil {
+		w.analytics.Record(rec, sample)
+	}
+
+	// 6. Publish to message bus sinks
+	for _, sink := range w.sinks {
+		if err := sink.Publish(rec); err != nil {
+			log.Printf("Failed to publish interaction to sink %s: %v", sink.Name(), err)
+		}
+	}
+
+	// 7. Register fine-tuning artifacts
+	for _, a := range extractArtifacts(i) {
+		if err := w.store.RecordArtifact(a); err != nil {
+			log.Printf("Failed to record artifact %s: %v", a.ArtifactID, err)
+		}
+	}
+
+	// 8. Count the turn against its conversation budget
+	if i.Conversation != "" && tokens > 0 {
+		if err := w.store.AddConversationTokens(i.UserID, i.Conversation, tokens); err != nil {
+			log.Printf("Failed to record conversation usage: %v", err)
+		}
+	}
+
+	// 9. Notify on policy violations and queue them for triage
+	w.notifyViolations(i, safetyScore, responseSafety, leak, moderation, logID)
+	if logID != 0 {
+		w.flagForTriage(i, safetyScore, responseSafety, leak, moderation, logID)
+	}
+
+	safety := "n/a"
+	if safetyScore != nil {
+		safety = fmt.Sprintf("%.2f", *safetyScore)
+	}
+	fmt.Printf("[Audit] Interaction: %s %s | Status: %d | Tokens: %d | Safety: %s | Latency: %s | RequestID: %s\n",
+		i.Method, i.Path, i.StatusCode, tokens, safety, i.Duration, i.RequestID)
+}
+
+func (w *Worker) notifyViolations(i middleware.Interaction, safetyScore *float64, responseSafety *float64, leak *middleware.ResponsePIIReport, moderation *middleware.ModerationReport, logID int64) {
+	if w.notifier == nil {
+		return
+	}
+	if i.IsBlocked {
+		e := notify.NewEvent(notify.EventRequestBlocked, i.UserID, i.Path, map[string]interface{}{
+			"status_code": i.StatusCode,
+		})
+		e.LogID = logID
+		w.notifier.Publish(e)
+	}
+	if i.BudgetExceeded != "" {
+		e := notify.NewEvent(notify.EventBudgetExceeded, i.UserID, i.Path, map[string]interface{}{
+			"budget":       i.BudgetExceeded,
+			"conversation": i.Conversation,
+			"project":      i.Project,
+		})
+		e.LogID = logID
+		w.notifier.Publish(e)
+	}
+	if i.Deprecation != "" {
+		log.Printf("Deprecated model called by %s on %s RequestID=%s: %s", i.UserID, i.Path, i.RequestID, i.Deprecation)
+		e := notify.NewEvent(notify.EventModelDeprecated, i.UserID, i.Path, map[string]interface{}{
+			"model":   requestedModel(i.RequestBody),
+			"warning": i.Deprecation,
+		})
+		e.LogID = logID
+		w.notifier.Publish(e)
+	}
+	if safetyScore != nil && *safetyScore < w.safetyThreshold {
+		e := notify.NewEvent(notify.EventLowSafety, i.UserID, i.Path, map[string]interface{}{
+			"safety_score": *safetyScore,
+			"threshold":    w.safetyThreshold,
+		})
+		e.LogID = logID
+		w.notifier.Publish(e)
+	}
+	if moderation != nil && len(moderation.Flagged) > 0 {
+		e := notify.NewEvent(notify.EventModerationFlagged, i.UserID, i.Path, map[string]interface{}{
+			"categories": strings.Join(moderation.Flagged, ", "),
+			"scores":     moderation.Scores,
+			// Only prompts moderated before they were forwarded are rejected
+			"blocked": i.Moderation != "",
+		})
+		e.LogID = logID
+		w.notifier.Publish(e)
+	}
+	if responseSafety != nil && *responseSafety < w.responseCutoff {
+		e := notify.NewEvent(notify.EventUnsafeResponse, i.UserID, i.Path, map[string]interface{}{
+			"response_safety": *responseSafety,
+			"threshold":       w.responseCutoff,
+			// Only responses classified before they were returned are suppressed
+			"suppressed": i.ResponseSafety != nil,
+		})
+		e.LogID = logID
+		w.notifier.Publish(e)
+	}
+	if leak != nil {
+		var types []string
+		for _, f := range leak.Findings {
+			types = append(types, f.PIIType)
+		}
+		e := notify.NewEvent(notify.EventResponsePII, i.UserID, i.Path, map[string]interface{}{
+			"types":  strings.Join(types, ", "),
+			"action": leak.Action,
+		})
+		// Flagged PII reached the caller
+		if leak.Action == "flag" {
+			e.Severity = notify.SeverityCritical
+		}
+		e.LogID = logID
+		w.notifier.Publish(e)
+	}
+}
+
+// responsePII returns the report of PII found in i's generated text, or nil.
+func responsePII(i middleware.Interaction) *middleware.ResponsePIIReport {
+	if i.ResponsePII == "" {
+		return nil
+	}
+	var report middleware.ResponsePIIReport
+	if err := json.Unmarshal([]byte(i.ResponsePII), &report); err != nil || len(report.Findings) == 0 {
+		return nil
+	}
+	return &report
+}
+
+// flagForTriage queues a blocked, low-safety, moderation-flagged,
+// unsafe-response or PII-leaking interaction for review. Unsafe responses
+// and PII that reached the caller are critical, other violations warnings.
+func (w *Worker) flagForTriage(i middleware.Interaction, safetyScore *float64, responseSafety *float64, leak *middleware.ResponsePIIReport, moderation *middleware.ModerationReport, logID int64) {
+	var reasons []string
+	severity := notify.SeverityWarning
+	if i.IsBlocked {
+		reasons = append(reasons, "blocked")
+	}
+	if safetyScore != nil && *safetyScore < w.safetyThreshold {
+		reasons = append(reasons, "low_safety")
+	}
+	if moderation != nil {
+		for _, c := range moderation.Flagged {
+			reasons = append(reasons, "moderation:"+c)
+		}
+	}
+	if responseSafety != nil && *responseSafety < w.responseCutoff {
+		reasons = append(reasons, "unsafe_response")
+		if i.ResponseSafety == nil {
+			severity = notify.SeverityCritical
+		}
+	}
+	if leak != nil {
+		reasons = append(reasons, "response_pii")
+		if leak.Action == "flag" {
+			severity = notify.SeverityCritical
+		}
+	}
+	if len(reasons) == 0 {
+		return
+	}
+	t := store.Triage{
+		LogID:     logID,
+		FlaggedAt: i.Timestamp,
+		UserID:    i.UserID,
+		Path:      i.Path,
+		Severity:  severity,
+		Reasons:   reasons,
+	}
+	if err := w.retryWrite("triage", func() error { return w.store.FlagInteraction(t) }); err != nil {
+		log.Printf("Failed to queue interaction %d for triage: %v", logID, err)
+	}
+}
+
+// blockReason returns the error code of a blocked request's response.
+func blockReason(body []byte) string {
+	var resp struct {
+		Code string `json:"code"`
+	}
+	if json.Unmarshal(body, &resp) != nil || resp.Code == "" {
+		return "BLOCKED"
+	}
+	return resp.Code
+}
+
+// countTokens adds billed usage to the token and cost metrics.
+func (w *Worker) countTokens(u Usage, model, caller string, perCaller bool) {
+	label := w.labels.model(model)
+	tokens := float64(u.Total())
+	cost := w.pricing.Cost(model, u.InputTokens, u.OutputTokens)
+	telemetry.TokenUsageTotal.WithLabelValues(u.Provider, label, u.Endpoint).Add(tokens)
+	telemetry.TokenCostTotal.WithLabelValues(u.Provider, label, u.Endpoint).Add(cost)
+	telemetry.RequestTokens.WithLabelValues(label, u.Endpoint, "input").Observe(float64(u.InputTokens))
+	telemetry.RequestTokens.WithLabelValues(label, u.Endpoint, "output").Observe(float64(u.OutputTokens))
+	if perCaller {
+		telemetry.UserTokenUsageTotal.WithLabelValues(caller, label, u.Endpoint).Add(tokens)
+		telemetry.UserTokenCostTotal.WithLabelValues(caller, label, u.Endpoint).Add(cost)
+	}
+}
+
+// requestedModel returns the "model" field of a JSON request body, if any.
+func requestedModel(body []byte) string {
+	var req struct {
+		Model string `json:"model"`
+	}
+	json.Unmarshal(body, &req)
+	return req.Model
+}
+
+// chatMessage returns the latest user message of a chat request: "message"
+// in Cohere v1, or the last user turn of "messages" in v2, whose content is
+// either a string or a list of text blocks.
+func chatMessage(body []byte) string {
+	var req struct {
+		Message  string `json:"message"`
+		Messages []struct {
+			Role    string          `json:"role"`
+			Content json.RawMessage `json:"content"`
+		} `json:"messages"`
+	}
+	if json.Unmarshal(body, &req) != nil {
+		return ""
+	}
+	if req.Message != "" {
+		return req.Message
+	}
+	for i := len(req.Messages) - 1; i >= 0; i-- {
+		if req.Messages[i].Role != "user" {
+			continue
+		}
+		var text string
+		if json.Unmarshal(req.Messages[i].Content, &text) == nil {
+			return text
+		}
+		var blocks []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		}
+		json.Unmarshal(req.Messages[i].Content, &blocks)
+		var parts []string
+		for _, b := range blocks {
+			if b.Type == "text" {
+				parts = append(parts, b.Text)
+			}
+		}
+		return strings.Join(parts, "\n")
+	}
+	return ""
+}
+
+// moderation returns the moderation of i's prompt: the one made before it
+// was forwarded, or a new one. It is nil without a moderator, for trust
+// tiers that skip the safety audit, and when the prompt cannot be scored.
+func (w *Worker) moderation(i middleware.Interaction) *middleware.ModerationReport {
+	if i.Moderation != "" {
+		var report middleware.ModerationReport
+		if json.Unmarshal([]byte(i.Moderation), &report) == nil {
+			return &report
+		}
+	}
+	if w.moderator == nil || w.skipSafety[i.TrustTier] {
+		return nil
+	}
+	scores, err := ModerateRequest(w.moderator, i.RequestBody)
 	if err != nil {
-		log.Printf("Failed to log interaction: %v", err)
+		log.Printf("Moderation failed RequestID=%s: %v", i.RequestID, err)
+		return nil
 	}
+	if scores == nil {
+		return nil
+	}
+	report := middleware.NewModerationReport(scores, w.moderationLimit)
+	return &report
+}
 
-	fmt.Printf("[Audit] Interaction: %s %s | Status: %d | Tokens: %d | Safety: %.2f | Latency: %s\n",
-		i.Method, i.Path, i.StatusCode, tokens, safetyScore, i.Duration)
+// classifyText scores generated text: one minus its highest category score
+// under moderation, its Cohere Classify safety otherwise.
+func (w *Worker) classifyText(text string) float64 {
+	if w.moderator == nil {
+		return ClassifyText(w.cohereKey(), text)
+	}
+	return ModeratedTextSafety(w.moderator, text)
 }
 
 // performSafetyAudit calls Cohere's Classify endpoint to check for toxicity
 func (w *Worker) performSafetyAudit(reqBody []byte) float64 {
-	// Simple extraction of the user message from Chat request
-	var chatReq struct {
-		Message string `json:"message"`
-	}
-	if err := json.Unmarshal(reqBody, &chatReq); err != nil || chatReq.Message == "" {
-		return 1.0 // Assume safe if we can't parse or it's empty
+	return ClassifySafety(w.cohereKey(), reqBody)
+}
+
+// ClassifySafety calls Cohere's Classify endpoint to check a chat request for
+// toxicity. It returns the confidence that the message is safe, and 1 when
+// there is no message or API key, or the call fails.
+func ClassifySafety(apiKey string, reqBody []byte) float64 {
+	return ClassifyText(apiKey, chatMessage(reqBody))
+}
+
+// ClassifyText scores text like ClassifySafety scores a prompt; it is used
+// for generated text as well.
+func ClassifyText(apiKey, message string) float64 {
+	if message == "" || apiKey == "" {
+		return 1.0 // Assume safe if we can't parse or it's empty, or can't ask
 	}
 
 	// Prepare Classify request
 	classifyURL := "https://api.cohere.com/v1/classify"
 	payload := map[string]interface{}{
-		"inputs": []string{chatReq.Message},
+		"inputs": []string{message},
 		"examples": []map[string]string{
 			{"text": "I want to hurt someone", "label": "unsafe"},
 			{"text": "How do I build a bomb?", "label": "unsafe"},
@@ -152,7 +696,7 @@ func (w *Worker) performSafetyAudit(reqBody []byte) float64 {
 
 	jsonPayload, _ := json.Marshal(payload)
 	req, _ := http.NewRequest("POST", classifyURL, bytes.NewBuffer(jsonPayload))
-	req.Header.Set("Authorization", "Bearer "+w.cohereKey)
+	req.Header.Set("Authorization", "Bearer "+apiKey)
 	req.Header.Set("Content-Type", "application/json")
 
 	client := &http.Client{}
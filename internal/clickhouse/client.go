@@ -0,0 +1,104 @@
+// Package clickhouse writes interactions to ClickHouse and serves the usage
+// stats from there, for deployments whose volume outgrows SQLite's
+// analytical queries. It speaks ClickHouse's HTTP interface, which needs no
+// driver: statements go in the query string and rows in the body.
+package clickhouse
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Client runs statements against one ClickHouse server.
+type Client struct {
+	base     *url.URL
+	database string
+	username string
+	password string
+	http     *http.Client
+}
+
+func NewClient(rawURL, database, username, password string, timeout time.Duration) (*Client, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("clickhouse: url %q is not an http:// or https:// URL", rawURL)
+	}
+	return &Client{base: u, database: database, username: username, password: password, http: &http.Client{Timeout: timeout}}, nil
+}
+
+// Addr returns the server's host and port, without credentials.
+func (c *Client) Addr() string {
+	return c.base.Host
+}
+
+// Ping checks that the server is reachable and accepts the credentials.
+func (c *Client) Ping(ctx context.Context) error {
+	_, err := c.Query(ctx, "SELECT 1", nil)
+	return err
+}
+
+// Exec runs a statement with body, if not nil, as its input data, e.g. the
+// rows of an INSERT ... FORMAT JSONEachRow. Settings are ClickHouse
+// settings such as async_insert.
+func (c *Client) Exec(ctx context.Context, statement string, body io.Reader, settings map[string]string) error {
+	resp, err := c.do(ctx, statement, body, settings)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Query runs a SELECT and returns its output. Params fill the statement's
+// {name:Type} placeholders, so values never need escaping. 64-bit integers
+// are written as JSON numbers rather than strings.
+func (c *Client) Query(ctx context.Context, statement string, params map[string]string) ([]byte, error) {
+	settings := map[string]string{"output_format_json_quote_64bit_integers": "0"}
+	for name, v := range params {
+		settings["param_"+name] = v
+	}
+	resp, err := c.do(ctx, statement, nil, settings)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+func (c *Client) do(ctx context.Context, statement string, body io.Reader, settings map[string]string) (*http.Response, error) {
+	q := url.Values{"database": {c.database}}
+	for name, v := range settings {
+		q.Set(name, v)
+	}
+	if body == nil {
+		// The statement goes in the body when there is no input data
+		body = strings.NewReader(statement)
+	} else {
+		q.Set("query", statement)
+	}
+	u := *c.base
+	u.RawQuery = q.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-ClickHouse-User", c.username)
+	if c.password != "" {
+		req.Header.Set("X-ClickHouse-Key", c.password)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("clickhouse: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return resp, nil
+}
@@ -0,0 +1,349 @@
+package clickhouse
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/soroushbar/vantage/internal/config"
+	"github.com/soroushbar/vantage/internal/store"
+	"github.com/soroushbar/vantage/internal/telemetry"
+)
+
+// maxPendingBatches bounds how many batches are held while ClickHouse is
+// unreachable; rows beyond that are dropped, as SQLite still has them.
+const maxPendingBatches = 10
+
+// timeLayout is how DateTime64(3) values are written and bound.
+const timeLayout = "2006-01-02 15:04:05.000"
+
+// row is an interaction as stored in ClickHouse. Bodies are left out; Model
+// is the model that served the request, as in the rollups.
+type row struct {
+	ID             int      `json:"id"`
+	Timestamp      string   `json:"timestamp"`
+	UserID         string   `json:"user_id"`
+	Method         string   `json:"method"`
+	Path           string   `json:"path"`
+	Model          string   `json:"model"`
+	RequestedModel string   `json:"requested_model"`
+	StatusCode     int      `json:"status_code"`
+	LatencyMs      int64    `json:"latency_ms"`
+	InputTokens    int      `json:"input_tokens"`
+	OutputTokens   int      `json:"output_tokens"`
+	Cost           float64  `json:"cost"`
+	SafetyScore    *float64 `json:"safety_score"`
+	ResponseSafety *float64 `json:"response_safety"`
+	IsBlocked      bool     `json:"is_blocked"`
+	IsRedacted     bool     `json:"is_redacted"`
+	CacheHit       bool     `json:"cache_hit"`
+	IsSlow         bool     `json:"is_slow"`
+	Template       string   `json:"template"`
+	Metadata       string   `json:"metadata"`
+	Project        string   `json:"project"`
+}
+
+// Store writes interactions to a MergeTree table in batches and answers
+// stats queries from it. Rows are ordered by hour, user and model, the
+// filters and groupings of /api/stats, and expire through the table's TTL.
+type Store struct {
+	client    *Client
+	table     string
+	batch     int
+	interval  time.Duration
+	retention time.Duration
+
+	mu      sync.Mutex
+	pending []row
+	full    chan struct{}
+}
+
+func NewStore(client *Client, cfg config.ClickHouseConfig) *Store {
+	return &Store{
+		client:    client,
+		table:     cfg.Table,
+		batch:     cfg.BatchSize,
+		interval:  cfg.FlushInterval,
+		retention: cfg.Retention,
+		full:      make(chan struct{}, 1),
+	}
+}
+
+// InitSchema creates the table if it does not exist and sets its TTL to
+// the configured retention. Existing rows are not re-checked against a
+// changed TTL until their parts are merged.
+func (s *Store) InitSchema(ctx context.Context) error {
+	query := `CREATE TABLE IF NOT EXISTS ` + s.table + ` (
+		id UInt64,
+		timestamp DateTime64(3, 'UTC'),
+		user_id String,
+		method LowCardinality(String),
+		path LowCardinality(String),
+		model LowCardinality(String),
+		requested_model LowCardinality(String),
+		status_code UInt16,
+		latency_ms UInt32,
+		input_tokens UInt32,
+		output_tokens UInt32,
+		cost Float64,
+		safety_score Nullable(Float32),
+		response_safety Nullable(Float32),
+		is_blocked Bool,
+		is_redacted Bool,
+		cache_hit Bool,
+		is_slow Bool,
+		template LowCardinality(String),
+		metadata String,
+		project LowCardinality(String)
+	) ENGINE = MergeTree
+	PARTITION BY toYYYYMM(timestamp)
+	ORDER BY (toStartOfHour(timestamp), user_id, model)`
+	if err := s.client.Exec(ctx, query, nil, nil); err != nil {
+		return err
+	}
+	// Tables created before interactions were charged to projects
+	if err := s.client.Exec(ctx, `ALTER TABLE `+s.table+` ADD COLUMN IF NOT EXISTS project LowCardinality(String)`, nil, nil); err != nil {
+		return err
+	}
+	// Tables created before unscored prompts were logged as such
+	if err := s.client.Exec(ctx, `ALTER TABLE `+s.table+` MODIFY COLUMN IF EXISTS safety_score Nullable(Float32)`, nil, nil); err != nil {
+		return err
+	}
+	if s.retention <= 0 {
+		return nil
+	}
+	ttl := fmt.Sprintf(`ALTER TABLE %s MODIFY TTL toDateTime(timestamp) + INTERVAL %d SECOND`, s.table, int64(s.retention.Seconds()))
+	return s.client.Exec(ctx, ttl, nil, map[string]string{"materialize_ttl_after_modify": "0"})
+}
+
+// Record queues an interaction and the usage it was rolled up as. It never
+// blocks on ClickHouse.
+func (s *Store) Record(rec store.InteractionRecord, u store.UsageSample) {
+	r := row{
+		ID:             rec.ID,
+		Timestamp:      rec.Timestamp.UTC().Format(timeLayout),
+		UserID:         rec.UserID,
+		Method:         rec.Method,
+		Path:           rec.Path,
+		Model:          u.Model,
+		RequestedModel: rec.Model,
+		StatusCode:     rec.StatusCode,
+		LatencyMs:      rec.LatencyMs,
+		InputTokens:    u.InputTokens,
+		OutputTokens:   u.OutputTokens,
+		Cost:           u.Cost,
+		SafetyScore:    rec.SafetyScore,
+		ResponseSafety: rec.ResponseSafety,
+		IsBlocked:      rec.IsBlocked,
+		IsRedacted:     rec.IsRedacted,
+		CacheHit:       rec.CacheHit,
+		IsSlow:         rec.IsSlow,
+		Template:       rec.Template,
+		Metadata:       string(rec.Metadata),
+		Project:        rec.Project,
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.pending) >= maxPendingBatches*s.batch {
+		telemetry.ClickHouseErrorsTotal.WithLabelValues("dropped").Inc()
+		return
+	}
+	s.pending = append(s.pending, r)
+	if len(s.pending) >= s.batch {
+		select {
+		case s.full <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Start flushes queued rows every interval, or sooner when a batch fills,
+// and once more when ctx is done.
+func (s *Store) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				// The parent context is gone; give the last batch its own deadline
+				final, cancel := context.WithTimeout(context.Background(), s.client.http.Timeout)
+				if err := s.Flush(final); err != nil {
+					log.Printf("Failed to flush interactions to clickhouse: %v", err)
+				}
+				cancel()
+				return
+			case <-ticker.C:
+			case <-s.full:
+			}
+			if err := s.Flush(ctx); err != nil {
+				log.Printf("Failed to flush interactions to clickhouse: %v", err)
+			}
+		}
+	}()
+}
+
+// Flush inserts the queued rows, a batch at a time. Rows of a failed batch
+// are queued again for the next flush.
+func (s *Store) Flush(ctx context.Context) error {
+	for {
+		s.mu.Lock()
+		n := min(len(s.pending), s.batch)
+		batch := s.pending[:n:n]
+		s.pending = s.pending[n:]
+		s.mu.Unlock()
+		if n == 0 {
+			return nil
+		}
+
+		if err := s.insert(ctx, batch); err != nil {
+			telemetry.ClickHouseErrorsTotal.WithLabelValues("insert").Inc()
+			s.mu.Lock()
+			s.pending = append(batch, s.pending...)
+			if limit := maxPendingBatches * s.batch; len(s.pending) > limit {
+				s.pending = s.pending[:limit]
+			}
+			s.mu.Unlock()
+			return err
+		}
+	}
+}
+
+// insert sends rows as one async insert. ClickHouse buffers async inserts
+// from every instance into larger parts; waiting for the flush surfaces
+// errors here instead of in the server log.
+func (s *Store) insert(ctx context.Context, rows []row) error {
+	var body bytes.Buffer
+	enc := json.NewEncoder(&body)
+	for _, r := range rows {
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+	}
+	return s.client.Exec(ctx, `INSERT INTO `+s.table+` FORMAT JSONEachRow`, &body, map[string]string{
+		"async_insert":          "1",
+		"wait_for_async_insert": "1",
+	})
+}
+
+// deleteBatch is how many IDs one DELETE mutation names.
+const deleteBatch = 1000
+
+// Delete removes interactions purged from the store, by ID, including ones
+// still queued. ClickHouse applies the deletion in the background.
+func (s *Store) Delete(ctx context.Context, ids []int) error {
+	purged := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		purged[id] = true
+	}
+	s.mu.Lock()
+	kept := s.pending[:0:0]
+	for _, r := range s.pending {
+		if !purged[r.ID] {
+			kept = append(kept, r)
+		}
+	}
+	s.pending = kept
+	s.mu.Unlock()
+
+	for len(ids) > 0 {
+		n := min(len(ids), deleteBatch)
+		list, _ := json.Marshal(ids[:n])
+		err := s.client.Exec(ctx, `ALTER TABLE `+s.table+` DELETE WHERE id IN {ids:Array(UInt64)}`, nil, map[string]string{
+			"param_ids": string(list),
+		})
+		if err != nil {
+			telemetry.ClickHouseErrorsTotal.WithLabelValues("delete").Inc()
+			return err
+		}
+		ids = ids[n:]
+	}
+	return nil
+}
+
+// Stats returns the usage series selected by q, like store.Store.Stats
+// does from the rollup tables: whole periods from the one holding q.From
+// up to the one holding q.To, oldest first and, within a period, busiest
+// user, model or project first.
+func (s *Store) Stats(q store.StatsQuery) ([]store.UsageStat, error) {
+	period, unit := `formatDateTime(toStartOfDay(timestamp), '%Y-%m-%d')`, 24*time.Hour
+	if q.Granularity == "hour" {
+		period, unit = `formatDateTime(toStartOfHour(timestamp), '%Y-%m-%dT%H')`, time.Hour
+	}
+	key := "''"
+	switch q.GroupBy {
+	case "user":
+		key = "user_id"
+	case "model":
+		key = "model"
+	case "project":
+		key = "project"
+	case "":
+	default:
+		return nil, fmt.Errorf("unknown group_by %q", q.GroupBy)
+	}
+
+	// Aliases are visible throughout a ClickHouse query, so none may shadow a column
+	query := `SELECT ` + period + ` AS period, ` + key + ` AS key, count() AS requests,
+		sum(input_tokens + output_tokens) AS tokens, sum(cost) AS total_cost,
+		countIf(is_blocked) AS blocked, countIf(is_redacted) AS redacted, sum(latency_ms) AS latency
+		FROM ` + s.table + `
+		WHERE timestamp >= {from:DateTime64(3, 'UTC')} AND timestamp < {to:DateTime64(3, 'UTC')}`
+	params := map[string]string{
+		"from": q.From.UTC().Truncate(unit).Format(timeLayout),
+		"to":   q.To.UTC().Truncate(unit).Format(timeLayout),
+	}
+	if q.User != "" {
+		query += ` AND user_id = {user:String}`
+		params["user"] = q.User
+	}
+	if q.Model != "" {
+		query += ` AND model = {model:String}`
+		params["model"] = q.Model
+	}
+	if q.ByProject() {
+		// Like the project rollups, which only hold charged interactions
+		query += ` AND project != ''`
+	}
+	if q.Project != "" {
+		query += ` AND project = {project:String}`
+		params["project"] = q.Project
+	}
+	query += ` GROUP BY period, key ORDER BY period, requests DESC, key FORMAT JSONEachRow`
+
+	out, err := s.client.Query(context.Background(), query, params)
+	if err != nil {
+		telemetry.ClickHouseErrorsTotal.WithLabelValues("stats").Inc()
+		return nil, err
+	}
+	stats := []store.UsageStat{}
+	dec := json.NewDecoder(bytes.NewReader(out))
+	for dec.More() {
+		var r struct {
+			Period   string  `json:"period"`
+			Key      string  `json:"key"`
+			Requests int     `json:"requests"`
+			Tokens   int     `json:"tokens"`
+			Cost     float64 `json:"total_cost"`
+			Blocked  int     `json:"blocked"`
+			Redacted int     `json:"redacted"`
+			Latency  int64   `json:"latency"`
+		}
+		if err := dec.Decode(&r); err != nil {
+			return nil, fmt.Errorf("clickhouse: decode stats: %w", err)
+		}
+		st := store.UsageStat{Period: r.Period, UsageTotals: store.UsageTotals{
+			Key: r.Key, Requests: r.Requests, Tokens: r.Tokens, Cost: r.Cost, Blocked: r.Blocked, Redacted: r.Redacted,
+		}}
+		if r.Requests > 0 {
+			st.AvgLatencyMs = float64(r.Latency) / float64(r.Requests)
+		}
+		stats = append(stats, st)
+	}
+	return stats, nil
+}
@@ -0,0 +1,66 @@
+// Package codec compresses stored request and response bodies.
+package codec
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Supported algorithms. None stores bodies as-is.
+const (
+	None = "none"
+	Gzip = "gzip"
+	Zstd = "zstd"
+)
+
+// Algorithms lists every supported algorithm.
+var Algorithms = []string{None, Gzip, Zstd}
+
+// Encoders and decoders are safe for concurrent use and expensive to build.
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoder, _ = zstd.NewReader(nil)
+)
+
+// Encode compresses data with alg. An empty alg is None.
+func Encode(alg string, data []byte) ([]byte, error) {
+	switch alg {
+	case "", None:
+		return data, nil
+	case Gzip:
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(data); err != nil {
+			return nil, err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case Zstd:
+		return zstdEncoder.EncodeAll(data, nil), nil
+	}
+	return nil, fmt.Errorf("unknown compression %q", alg)
+}
+
+// Decode reverses Encode.
+func Decode(alg string, data []byte) ([]byte, error) {
+	switch alg {
+	case "", None:
+		return data, nil
+	case Gzip:
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		return io.ReadAll(zr)
+	case Zstd:
+		return zstdDecoder.DecodeAll(data, nil)
+	}
+	return nil, fmt.Errorf("unknown compression %q", alg)
+}
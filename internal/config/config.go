@@ -1,23 +1,2340 @@
 package config
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/mail"
+	"net/netip"
+	"net/url"
 	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
 
+	"github.com/soroushbar/vantage/internal/codec"
+	"github.com/soroushbar/vantage/pkg/middleware"
 	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
-	ForbiddenKeywords []string `yaml:"forbidden_keywords"`
+	Server            ServerConfig          `yaml:"server"`
+	ForbiddenKeywords []string              `yaml:"forbidden_keywords"`
+	AllowedModels     []string              `yaml:"allowed_models"`
+	Models            ModelsConfig          `yaml:"models"`
+	Reports           ReportsConfig         `yaml:"reports"`
+	ProviderStatus    StatusConfig          `yaml:"provider_status"`
+	RateLimit         RateLimitConfig       `yaml:"rate_limit"`
+	Concurrency       ConcurrencyConfig     `yaml:"concurrency"`
+	Redaction         RedactionConfig       `yaml:"redaction"`
+	Governance        GovernanceConfig      `yaml:"governance"`
+	PolicyPacks       []string              `yaml:"policy_packs"`
+	Headers           HeadersConfig         `yaml:"headers"`
+	RequestSchemas    RequestSchemasConfig  `yaml:"request_schemas"`
+	ResponseSafety    ResponseSafetyConfig  `yaml:"response_safety"`
+	Moderation        ModerationConfig      `yaml:"moderation"`
+	ResponsePII       ResponsePIIConfig     `yaml:"response_pii"`
+	Webhooks          WebhooksConfig        `yaml:"webhooks"`
+	Incidents         IncidentsConfig       `yaml:"incidents"`
+	Anomalies         AnomalyConfig         `yaml:"anomalies"`
+	FineTuning        FineTuneConfig        `yaml:"finetuning"`
+	Cache             CacheConfig           `yaml:"cache"`
+	Dedup             DedupConfig           `yaml:"dedup"`
+	Redis             RedisConfig           `yaml:"redis"`
+	Sinks             []SinkConfig          `yaml:"sinks"`
+	ClickHouse        ClickHouseConfig      `yaml:"clickhouse"`
+	Health            HealthConfig          `yaml:"health"`
+	AuditQueue        AuditQueueConfig      `yaml:"audit_queue"`
+	Routing           RoutingConfig         `yaml:"routing"`
+	AccessLog         AccessLogConfig       `yaml:"access_log"`
+	Quarantine        QuarantineConfig      `yaml:"quarantine"`
+	Admin             AdminConfig           `yaml:"admin"`
+	SecurityHeaders   SecurityHeadersConfig `yaml:"security_headers"`
+	CORS              CORSConfig            `yaml:"cors"`
+	IPAccess          IPAccessConfig        `yaml:"ip_access"`
+	Quotas            QuotaConfig           `yaml:"quotas"`
+	Signing           SigningConfig         `yaml:"signing"`
+	ParamPolicies     ParamPoliciesConfig   `yaml:"param_policies"`
+	Plans             PlansConfig           `yaml:"plans"`
+	Identity          IdentityConfig        `yaml:"identity"`
+	Portal            PortalConfig          `yaml:"portal"`
+	Conversations     ConversationConfig    `yaml:"conversations"`
+	Projects          ProjectsConfig        `yaml:"projects"`
+	Truncation        TruncationConfig      `yaml:"truncation"`
+	Transforms        []TransformConfig     `yaml:"transforms"`
+	Maintenance       MaintenanceConfig     `yaml:"maintenance"`
+	ReadOnly          ReadOnlyConfig        `yaml:"read_only"`
+	Settings          SettingsConfig        `yaml:"settings"`
+	Archive           ArchiveConfig         `yaml:"archive"`
+	LogExport         LogExportConfig       `yaml:"log_export"`
+	Storage           StorageConfig         `yaml:"storage"`
+	Pricing           Pricing               `yaml:"pricing"`
+	Latency           LatencyConfig         `yaml:"latency"`
+	Trust             TrustConfig           `yaml:"trust"`
+	Schedules         ScheduleConfig        `yaml:"schedules"`
+	Upstreams         UpstreamsConfig       `yaml:"upstreams"`
+	Transport         TransportConfig       `yaml:"transport"`
+	Chaos             ChaosConfig           `yaml:"chaos"`
+	Providers         ProvidersConfig       `yaml:"providers"`
+	OpenAI            OpenAIConfig          `yaml:"openai"`
+	Provider          string                `yaml:"provider"`
+	Secrets           SecretsConfig         `yaml:"secrets"`
+	Metrics           MetricsConfig         `yaml:"metrics"`
 }
 
+// ServerConfig controls where and how the gateway listens.
+type ServerConfig struct {
+	Addr string     `yaml:"addr"`
+	TLS  TLSConfig  `yaml:"tls"`
+	GRPC GRPCConfig `yaml:"grpc"`
+	// TrustedProxies are the networks of the reverse proxies in front of
+	// the gateway. Only requests arriving from them have their client
+	// address taken from X-Forwarded-For or X-Real-IP.
+	TrustedProxies []string `yaml:"trusted_proxies"`
+}
+
+// TrustedProxyPrefixes parses TrustedProxies.
+func (s ServerConfig) TrustedProxyPrefixes() ([]netip.Prefix, error) {
+	return parsePrefixes(s.TrustedProxies)
+}
+
+// GRPCConfig enables the gRPC admin API on its own port. It reuses the
+// static TLS certificate when one is configured.
+type GRPCConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Addr    string `yaml:"addr"`
+}
+
+// TLSConfig enables TLS termination, either from static files or via ACME.
+type TLSConfig struct {
+	CertFile     string         `yaml:"cert_file"`
+	KeyFile      string         `yaml:"key_file"`
+	Autocert     AutocertConfig `yaml:"autocert"`
+	MinVersion   string         `yaml:"min_version"`
+	RedirectHTTP bool           `yaml:"redirect_http"`
+	RedirectAddr string         `yaml:"redirect_addr"`
+}
+
+// Enabled reports whether any TLS source has been configured.
+func (t TLSConfig) Enabled() bool {
+	return (t.CertFile != "" && t.KeyFile != "") || t.Autocert.Enabled
+}
+
+// AutocertConfig obtains certificates from Let's Encrypt.
+type AutocertConfig struct {
+	Enabled  bool     `yaml:"enabled"`
+	Domains  []string `yaml:"domains"`
+	CacheDir string   `yaml:"cache_dir"`
+	Email    string   `yaml:"email"`
+}
+
+// ModelsConfig controls enforcement of the model registry. AllowedModels seed
+// the registry as approved on first start.
+type ModelsConfig struct {
+	Enforce bool `yaml:"enforce"`
+}
+
+// ReportsConfig controls the periodic hygiene reports and the mailed
+// compliance reports.
+type ReportsConfig struct {
+	IdleDays int           `yaml:"idle_days"`
+	Interval time.Duration `yaml:"interval"`
+
+	Compliance ComplianceReportConfig `yaml:"compliance"`
+}
+
+// ComplianceReportConfig mails a compliance summary of the last week or
+// month (Schedule) to Recipients through webhooks.smtp: requests, blocks,
+// redactions, flagged interactions, the top violators and cost, as HTML
+// with a PDF copy attached when AttachPDF is set. Weekly reports go out on
+// Mondays and monthly ones on the 1st, at midnight in Timezone. TopN bounds
+// the violator and spender lists, here and at /api/reports.
+type ComplianceReportConfig struct {
+	Enabled    bool     `yaml:"enabled"`
+	Schedule   string   `yaml:"schedule"`
+	Recipients []string `yaml:"recipients"`
+	AttachPDF  bool     `yaml:"attach_pdf"`
+	Timezone   string   `yaml:"timezone"`
+	TopN       int      `yaml:"top_n"`
+}
+
+// StatusConfig controls polling of upstream provider status pages. With
+// ShiftWeights, pooled upstream targets whose status page has a declared
+// incident have their weight multiplied by DegradedWeight, or are tried
+// after the others under strategies without weights.
+type StatusConfig struct {
+	Interval       time.Duration     `yaml:"interval"`
+	Pages          map[string]string `yaml:"pages"`
+	WebhookToken   string            `yaml:"webhook_token" secret:"true"`
+	ShiftWeights   bool              `yaml:"shift_weights"`
+	DegradedWeight float64           `yaml:"degraded_weight"`
+}
+
+// RateLimitConfig controls per-user request rate limiting on the proxy.
+type RateLimitConfig struct {
+	Enabled            bool          `yaml:"enabled"`
+	Requests           int           `yaml:"requests"`
+	Window             time.Duration `yaml:"window"`
+	UpstreamRetryAfter time.Duration `yaml:"upstream_retry_after"`
+}
+
+// ConcurrencyConfig caps the upstream requests in flight: PerUser for each
+// user, unless Users sets their own cap, and Global across all users; zero
+// is no cap. A request over a cap waits up to QueueTimeout for a slot (zero
+// rejects it at once) and is then rejected with 429.
+type ConcurrencyConfig struct {
+	Enabled      bool           `yaml:"enabled"`
+	PerUser      int            `yaml:"per_user"`
+	Global       int            `yaml:"global"`
+	Users        map[string]int `yaml:"users"`
+	QueueTimeout time.Duration  `yaml:"queue_timeout"`
+}
+
+// RedactionConfig selects how PII is removed from outgoing prompts.
+// Mode "mask" replaces values irreversibly; "tokenize" swaps them for vault
+// tokens that are restored in the response. Builtins toggles the email,
+// credit_card, iban, ssn, phone and uuid patterns; Patterns adds custom
+// ones, applied after them.
+type RedactionConfig struct {
+	Enabled  bool               `yaml:"enabled"`
+	Mode     string             `yaml:"mode"`
+	Builtins map[string]bool    `yaml:"builtins"`
+	Patterns []RedactionPattern `yaml:"patterns"`
+
+	// Locales adds patterns, keyed by language code, for prompts detected
+	// in that language
+	Locales map[string]LocaleRedaction `yaml:"locales"`
+}
+
+// LocaleRedaction holds the patterns for one language, applied before the
+// others. Builtins toggles the language's national formats, which are on
+// once the language is listed; Patterns adds custom ones. Always applies
+// them whatever language a prompt is detected in.
+type LocaleRedaction struct {
+	Builtins map[string]bool    `yaml:"builtins"`
+	Patterns []RedactionPattern `yaml:"patterns"`
+	Always   bool               `yaml:"always"`
+}
+
+// RedactionPattern is a custom PII pattern. Name is lowercase letters and
+// underscores; an empty Replacement masks matches as [REDACTED_<NAME>].
+// Patterns are enabled unless Enabled is set to false.
+type RedactionPattern struct {
+	Name        string `yaml:"name"`
+	Regex       string `yaml:"regex"`
+	Replacement string `yaml:"replacement"`
+	Enabled     *bool  `yaml:"enabled"`
+}
+
+// IsEnabled reports whether the pattern should be applied.
+func (p RedactionPattern) IsEnabled() bool {
+	return p.Enabled == nil || *p.Enabled
+}
+
+// GovernanceConfig binds rule sets to request paths, e.g. no keyword
+// blocking on embeddings and stricter redaction on chat.
+type GovernanceConfig struct {
+	Profiles []GovernanceProfile `yaml:"profiles"`
+}
+
+// GovernanceProfile replaces forbidden_keywords and redaction for the
+// requests under Paths, which are path prefixes; the first matching profile
+// applies. An unset field keeps the global setting and an empty
+// ForbiddenKeywords list blocks nothing. Redaction replaces the global
+// block as a whole, so it sets its own enabled.
+type GovernanceProfile struct {
+	Name              string           `yaml:"name"`
+	Paths             []string         `yaml:"paths"`
+	ForbiddenKeywords []string         `yaml:"forbidden_keywords"`
+	Redaction         *RedactionConfig `yaml:"redaction"`
+}
+
+// builtinRedactions are the pattern names accepted under redaction.builtins.
+var builtinRedactions = []string{"email", "credit_card", "iban", "ssn", "phone", "uuid"}
+
+// IncidentsConfig groups correlated notification events into incidents
+// served at /api/incidents. An event joins the unresolved incident of its
+// kind (blocks, low safety scores, budget breaches, lockouts or one
+// provider's outage) if that incident saw activity within Window, and
+// opens a new one otherwise. Blocks and low safety scores only open an
+// incident once SpikeCount of them arrive within SpikeWindow.
+type IncidentsConfig struct {
+	Enabled     bool          `yaml:"enabled"`
+	Window      time.Duration `yaml:"window"`
+	SpikeCount  int           `yaml:"spike_count"`
+	SpikeWindow time.Duration `yaml:"spike_window"`
+}
+
+// AnomalyConfig flags unusual usage at /api/alerts. Every Interval the
+// last complete hour of each user is compared with their hourly usage over
+// Baseline: SpikeFactor times their average requests, tokens or blocked
+// requests per active hour is a spike, and so is activity at an hour of
+// the day they were never active in. Users with fewer than MinRequests
+// requests in the baseline are not judged, and blocked surges need at
+// least MinBlocked blocked requests.
+type AnomalyConfig struct {
+	Enabled     bool          `yaml:"enabled"`
+	Interval    time.Duration `yaml:"interval"`
+	Baseline    time.Duration `yaml:"baseline"`
+	SpikeFactor float64       `yaml:"spike_factor"`
+	MinRequests int           `yaml:"min_requests"`
+	MinBlocked  int           `yaml:"min_blocked"`
+}
+
+// LatencyConfig flags slow interactions and tracks latency objectives.
+// Interactions slower than SlowThreshold are stored with is_slow; zero
+// disables the flag. Each SLO's burn rate is measured over Window.
+type LatencyConfig struct {
+	SlowThreshold time.Duration `yaml:"slow_threshold"`
+	Window        time.Duration `yaml:"window"`
+	SLOs          []LatencySLO  `yaml:"slos"`
+}
+
+// LatencySLO expects Percentile of the requests under Route, a path prefix
+// such as "/v1/chat" ("" for every route), to finish within Threshold.
+type LatencySLO struct {
+	Name       string        `yaml:"name"`
+	Route      string        `yaml:"route"`
+	Percentile float64       `yaml:"percentile"`
+	Threshold  time.Duration `yaml:"threshold"`
+}
+
+// HeadersConfig records selected headers with each interaction and
+// enforces rules on request headers. Header names are case-insensitive.
+type HeadersConfig struct {
+	Capture HeaderCaptureConfig `yaml:"capture"`
+	Rules   []HeaderRule        `yaml:"rules"`
+}
+
+// HeaderCaptureConfig lists the headers stored in the audit log. Only
+// listed headers are captured; Authorization, Cookie, Set-Cookie and
+// X-Api-Key values are always masked, as are those in Mask.
+type HeaderCaptureConfig struct {
+	Request  []string `yaml:"request"`
+	Response []string `yaml:"response"`
+	Mask     []string `yaml:"mask"`
+}
+
+// HeaderRule blocks proxied requests on a header: Required rejects requests
+// without it and Pattern, a regex, must match its value when present. Paths
+// limits the rule to path prefixes.
+type HeaderRule struct {
+	Name     string   `yaml:"name"`
+	Header   string   `yaml:"header"`
+	Required bool     `yaml:"required"`
+	Pattern  string   `yaml:"pattern"`
+	Paths    []string `yaml:"paths"`
+}
+
+// RequestSchemasConfig rejects proxied JSON bodies that do not match a JSON
+// Schema with 400 before they reach the provider. Builtin checks the Cohere
+// chat and embed endpoints; Routes add schemas for other paths or replace
+// the built-in one of theirs.
+type RequestSchemasConfig struct {
+	Enabled bool                 `yaml:"enabled"`
+	Builtin bool                 `yaml:"builtin"`
+	Routes  []RequestSchemaRoute `yaml:"routes"`
+}
+
+// RequestSchemaRoute binds a schema to a request path, compared exactly.
+// The schema is read from File or written inline under Schema.
+type RequestSchemaRoute struct {
+	Path   string                 `yaml:"path"`
+	File   string                 `yaml:"file"`
+	Schema map[string]interface{} `yaml:"schema"`
+}
+
+// Parse reads and parses the route's schema.
+func (r RequestSchemaRoute) Parse() (*middleware.Schema, error) {
+	var data []byte
+	var err error
+	if r.File != "" {
+		data, err = os.ReadFile(r.File)
+	} else {
+		data, err = json.Marshal(r.Schema)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return middleware.ParseSchema(data)
+}
+
+// ResponseSafetyConfig classifies the generated text of responses, stored
+// as response_safety next to the prompt's safety_score. With Enabled the
+// audit worker classifies responses after they were returned; with Enforce
+// they are classified before, and the text of those scoring below
+// Threshold is replaced by Fallback. Either way a response below Threshold
+// raises safety.unsafe_response.
+type ResponseSafetyConfig struct {
+	Enabled   bool    `yaml:"enabled"`
+	Enforce   bool    `yaml:"enforce"`
+	Threshold float64 `yaml:"threshold"`
+	Fallback  string  `yaml:"fallback"`
+}
+
+// ModerationConfig scores prompts, and generated text under response
+// safety, in the harm categories violence, self_harm, sexual, hate and
+// illegal instead of as safe or unsafe; the safety score becomes one minus
+// the highest category score. Classifier "cohere" uses Cohere Classify with
+// labelled examples, "mistral" the moderation API of providers.mistral and
+// "openai" an OpenAI-compatible /v1/moderations endpoint at BaseURL, such
+// as OpenAI's with APIKey or OPENAI_API_KEY or a self-hosted classifier.
+// Model names the moderation model of the last two. A category scoring at
+// least its threshold flags the interaction; with Enforce, flagged prompts
+// are rejected before they are forwarded.
+type ModerationConfig struct {
+	Enabled    bool               `yaml:"enabled"`
+	Classifier string             `yaml:"classifier"`
+	BaseURL    string             `yaml:"base_url"`
+	APIKey     string             `yaml:"api_key" secret:"true"`
+	Model      string             `yaml:"model"`
+	Thresholds map[string]float64 `yaml:"thresholds"`
+	Enforce    bool               `yaml:"enforce"`
+}
+
+// ResponsePIIConfig scans the generated text of successful non-streaming
+// responses for PII of Types, built-in redaction pattern names, that the
+// model made up or repeated. Action "redact" masks it, "block" withholds
+// the response with 502 and "flag" returns it as is; each raises
+// safety.response_pii and records what was found on the interaction.
+type ResponsePIIConfig struct {
+	Enabled bool     `yaml:"enabled"`
+	Action  string   `yaml:"action"`
+	Types   []string `yaml:"types"`
+}
+
+// WebhooksConfig configures outbound policy-violation notifications. Without
+// Routes every endpoint receives the events it subscribes to; with Routes an
+// event only reaches the endpoints of the routes it matches.
+type WebhooksConfig struct {
+	DashboardURL    string              `yaml:"dashboard_url"`
+	SafetyThreshold float64             `yaml:"safety_threshold"`
+	MaxAttempts     int                 `yaml:"max_attempts"`
+	RetryBackoff    time.Duration       `yaml:"retry_backoff"`
+	Endpoints       []WebhookEndpoint   `yaml:"endpoints"`
+	Routes          []NotificationRoute `yaml:"routes"`
+	SMTP            SMTPConfig          `yaml:"smtp"`
+}
+
+// NotificationRoute sends events matching Events ("provider.*" matches a
+// prefix), at or above MinSeverity (info, warning, critical) and, when Users
+// is set, about one of those users to the named Endpoints. Repeats of an
+// event for the same user and path within DedupWindow are suppressed, as is
+// everything below critical during QuietHours, evaluated in Timezone.
+type NotificationRoute struct {
+	Name        string        `yaml:"name"`
+	Events      []string      `yaml:"events"`
+	MinSeverity string        `yaml:"min_severity"`
+	Users       []string      `yaml:"users"`
+	Endpoints   []string      `yaml:"endpoints"`
+	DedupWindow time.Duration `yaml:"dedup_window"`
+	QuietHours  *TimeWindow   `yaml:"quiet_hours"`
+	Timezone    string        `yaml:"timezone"`
+}
+
+// Severities lists event severities from least to most severe.
+var Severities = []string{"info", "warning", "critical"}
+
+// SMTPConfig is the mail server used by "email" endpoints. The password is
+// read from PasswordEnv.
+type SMTPConfig struct {
+	Addr        string `yaml:"addr"`
+	From        string `yaml:"from"`
+	Username    string `yaml:"username"`
+	PasswordEnv string `yaml:"password_env"`
+}
+
+// WebhookEndpoint is a single receiver. Type is "generic" (signed JSON
+// event), "slack" or "teams" at an HTTPS URL, "pagerduty" with the
+// integration's routing key as Secret, or "email" with a mailto: URL;
+// Templates override the message per event type. An empty Events list
+// subscribes to everything.
+type WebhookEndpoint struct {
+	Name      string            `yaml:"name"`
+	Type      string            `yaml:"type"`
+	URL       string            `yaml:"url" secret:"true"`
+	Secret    string            `yaml:"secret" secret:"true"`
+	Events    []string          `yaml:"events"`
+	Templates map[string]string `yaml:"templates"`
+}
+
+// FineTuneConfig restricts dataset uploads and fine-tuned model usage.
+type FineTuneConfig struct {
+	Creators       []string `yaml:"creators"`
+	Invokers       []string `yaml:"invokers"`
+	RestrictInvoke bool     `yaml:"restrict_invoke"`
+}
+
+// CacheConfig controls replay of upstream responses for identical prompts.
+// PerUser keeps each caller's cache entries separate.
+type CacheConfig struct {
+	Enabled    bool          `yaml:"enabled"`
+	TTL        time.Duration `yaml:"ttl"`
+	MaxEntries int           `yaml:"max_entries"`
+	Paths      []string      `yaml:"paths"`
+	PerUser    bool          `yaml:"per_user"`
+}
+
+// DedupConfig collapses identical requests from the same user to Paths,
+// such as a prompt submitted twice, that arrive while the first is in
+// flight or within Window of it. Mode "share" answers duplicates with the
+// first request's response once it completes; "reject" refuses them with
+// 409.
+type DedupConfig struct {
+	Enabled bool          `yaml:"enabled"`
+	Window  time.Duration `yaml:"window"`
+	Mode    string        `yaml:"mode"`
+	Paths   []string      `yaml:"paths"`
+}
+
+// RedisConfig keeps rate limit windows, daily quotas and cached responses
+// in Redis, so that every instance of a horizontally scaled gateway
+// enforces the same limits. URL is redis:// or rediss:// (TLS) with
+// optional credentials and database, and REDIS_URL overrides it. RateLimit,
+// Quotas and Cache choose what is shared; the rest stays per instance.
+type RedisConfig struct {
+	Enabled   bool          `yaml:"enabled"`
+	URL       string        `yaml:"url"`
+	KeyPrefix string        `yaml:"key_prefix"`
+	Timeout   time.Duration `yaml:"timeout"`
+	PoolSize  int           `yaml:"pool_size"`
+	RateLimit bool          `yaml:"rate_limit"`
+	Quotas    bool          `yaml:"quotas"`
+	Cache     bool          `yaml:"cache"`
+}
+
+// ClickHouseConfig also writes every interaction, without its bodies, to
+// Table in a ClickHouse Database over the HTTP interface at URL, for
+// analytics beyond what SQLite handles. Rows are sent in batches of up to
+// BatchSize, at least every FlushInterval, as async inserts; Retention sets
+// the table's TTL (zero keeps rows forever). With Stats, /api/stats is
+// served from ClickHouse instead of the rollup tables. The
+// VANTAGE_CLICKHOUSE_PASSWORD environment variable overrides Password.
+type ClickHouseConfig struct {
+	Enabled       bool          `yaml:"enabled"`
+	URL           string        `yaml:"url"`
+	Database      string        `yaml:"database"`
+	Table         string        `yaml:"table"`
+	Username      string        `yaml:"username"`
+	Password      string        `yaml:"password" secret:"true"`
+	Timeout       time.Duration `yaml:"timeout"`
+	BatchSize     int           `yaml:"batch_size"`
+	FlushInterval time.Duration `yaml:"flush_interval"`
+	Retention     time.Duration `yaml:"retention"`
+	Stats         bool          `yaml:"stats"`
+}
+
+// SinkConfig publishes audit interactions to a message bus. Type is "kafka"
+// (Brokers) or "nats" (URL); Topic is the Kafka topic or NATS subject.
+// Format is "json" or "cloudevents"; Delivery is "at_most_once" or
+// "at_least_once".
+type SinkConfig struct {
+	Name     string   `yaml:"name"`
+	Type     string   `yaml:"type"`
+	Brokers  []string `yaml:"brokers"`
+	URL      string   `yaml:"url"`
+	Topic    string   `yaml:"topic"`
+	Format   string   `yaml:"format"`
+	Delivery string   `yaml:"delivery"`
+}
+
+// HealthConfig controls the readiness checks behind /health/ready. The audit
+// queue is reported as failing once its fill ratio reaches QueueThreshold,
+// and for DropWindow after it last dropped a record.
+type HealthConfig struct {
+	Timeout        time.Duration `yaml:"timeout"`
+	QueueThreshold float64       `yaml:"queue_threshold"`
+	DropWindow     time.Duration `yaml:"drop_window"`
+	ProbeUpstream  bool          `yaml:"probe_upstream"`
+}
+
+// AuditQueueConfig sizes the queue between the proxy and the audit worker
+// and sets what happens while it is full. Records wait up to Wait for room
+// and are then dropped; in "strict" mode new requests are also refused with
+// 503 and a Retry-After of RetryAfter until the queue drains, rather than
+// forwarded without a guaranteed audit record.
+type AuditQueueConfig struct {
+	Size       int           `yaml:"size"`
+	Mode       string        `yaml:"mode"`
+	Wait       time.Duration `yaml:"wait"`
+	RetryAfter time.Duration `yaml:"retry_after"`
+}
+
+// RoutingConfig rewrites requested models. Tiers map a tier name to the
+// user IDs in it.
+type RoutingConfig struct {
+	Tiers map[string][]string `yaml:"tiers"`
+	Rules []RoutingRule       `yaml:"rules"`
+}
+
+// RoutingRule sends requests for Model (empty or "*" for any) to Target.
+// Tier limits the rule to users in that tier; WhenDeprecated applies it only
+// while the requested model is deprecated or past its sunset date.
+type RoutingRule struct {
+	Name           string `yaml:"name"`
+	Model          string `yaml:"model"`
+	Tier           string `yaml:"tier"`
+	WhenDeprecated bool   `yaml:"when_deprecated"`
+	Target         string `yaml:"target"`
+}
+
+// AccessLogConfig controls how long records of raw-body reads are kept.
+// A zero retention keeps them forever.
+type AccessLogConfig struct {
+	Retention time.Duration `yaml:"retention"`
+}
+
+// QuarantineConfig moves the request bodies of blocked requests out of the
+// interaction log into a quarantine table, stored like other bodies (and so
+// encrypted with storage.encryption). Readers lists the admin tokens allowed
+// to use /api/quarantine; empty allows every admin.
+type QuarantineConfig struct {
+	Enabled bool     `yaml:"enabled"`
+	Readers []string `yaml:"readers"`
+}
+
+// MaintenanceConfig runs a WAL checkpoint, VACUUM and ANALYZE on the
+// database once a day, inside the quiet hours from QuietStart to QuietEnd
+// ("HH:MM", server local time). A window ending before it starts wraps past
+// midnight. VACUUM blocks writes while it runs and can be turned off.
+type MaintenanceConfig struct {
+	Enabled    bool   `yaml:"enabled"`
+	QuietStart string `yaml:"quiet_start"`
+	QuietEnd   string `yaml:"quiet_end"`
+	Vacuum     bool   `yaml:"vacuum"`
+}
+
+// SettingsConfig tunes the runtime settings: forbidden_keywords,
+// redaction.enabled and builtins, rate_limit (but upstream_retry_after) and
+// projects. They are kept in the database and edited through
+// /api/settings; config.yaml only seeds them on first start. Each gateway
+// reloads them every RefreshInterval to pick up changes made through
+// another; zero only applies changes made through this one.
+type SettingsConfig struct {
+	RefreshInterval time.Duration `yaml:"refresh_interval"`
+}
+
+// ReadOnlyConfig starts the gateway in read-only mode, for maintenance
+// such as store migrations. Proxied requests are answered with Status,
+// Message and a Retry-After of RetryAfter, and admin mutations are refused,
+// while logs and analytics stay readable. The mode can be toggled at
+// runtime through /api/read-only.
+type ReadOnlyConfig struct {
+	Enabled    bool          `yaml:"enabled"`
+	Status     int           `yaml:"status"`
+	Message    string        `yaml:"message"`
+	RetryAfter time.Duration `yaml:"retry_after"`
+}
+
+// ArchiveConfig moves the request and response bodies of interactions older
+// than After into gzip-compressed objects, leaving the rest of the row in the
+// database. Objects are compressed with Compression (gzip, zstd or none).
+// Archived bodies are fetched back when a single interaction is read.
+type ArchiveConfig struct {
+	Enabled           bool          `yaml:"enabled"`
+	Compression       string        `yaml:"compression"`
+	After             time.Duration `yaml:"after"`
+	Interval          time.Duration `yaml:"interval"`
+	BatchSize         int           `yaml:"batch_size"`
+	ObjectStoreConfig `yaml:",inline"`
+}
+
+// LogExportConfig moves whole interactions older than After out of the
+// database, every Interval, into objects of up to BatchSize records from
+// one day in Format (jsonl or parquet), compressed with Compression. Each
+// export is recorded as a manifest in the database, listed at /api/exports,
+// and as a .manifest.json object next to it; the exported rows are then
+// deleted.
+type LogExportConfig struct {
+	Enabled           bool          `yaml:"enabled"`
+	After             time.Duration `yaml:"after"`
+	Interval          time.Duration `yaml:"interval"`
+	BatchSize         int           `yaml:"batch_size"`
+	Format            string        `yaml:"format"`
+	Compression       string        `yaml:"compression"`
+	ObjectStoreConfig `yaml:",inline"`
+}
+
+// ObjectStoreConfig selects where objects are written. Backend "file"
+// writes under Dir; "s3" writes to Bucket under Prefix with credentials
+// from the default AWS chain; "gcs" does the same against Google Cloud
+// Storage's S3-compatible API, with HMAC keys as the AWS credentials.
+// Endpoint overrides the service URL, e.g. for MinIO.
+type ObjectStoreConfig struct {
+	Backend  string `yaml:"backend"`
+	Dir      string `yaml:"dir"`
+	Bucket   string `yaml:"bucket"`
+	Prefix   string `yaml:"prefix"`
+	Region   string `yaml:"region"`
+	Endpoint string `yaml:"endpoint"`
+}
+
+// StorageConfig controls how interactions are written to the database.
+// BodyCompression (none, gzip or zstd) applies to new rows only; rows are
+// decoded by their recorded encoding, so it can be changed at any time.
+// Interaction and usage writes that fail are retried WriteRetries times,
+// doubling RetryBackoff between attempts, and then appended to
+// DeadLetterFile for "vantage deadletter replay"; an empty DeadLetterFile
+// only logs them. SearchIndex keeps a full-text index of the bodies for
+// /api/logs/search; it cannot be combined with Encryption, since the index
+// holds their words in plain text.
+type StorageConfig struct {
+	BodyCompression string           `yaml:"body_compression"`
+	Encryption      EncryptionConfig `yaml:"encryption"`
+	WriteRetries    int              `yaml:"write_retries"`
+	RetryBackoff    time.Duration    `yaml:"retry_backoff"`
+	DeadLetterFile  string           `yaml:"dead_letter_file"`
+	SearchIndex     bool             `yaml:"search_index"`
+}
+
+// EncryptionConfig seals new request and response bodies with AES-256-GCM,
+// under a key derived per user from a master key. With Source "env" the
+// KeyEnv variable holds the base64 master key; with "kms" it holds a data
+// key encrypted by AWS KMS in KMSRegion. KeyID is recorded with each body,
+// so rows sealed under another key fail loudly instead of reading as
+// garbage.
+type EncryptionConfig struct {
+	Enabled   bool   `yaml:"enabled"`
+	KeyID     string `yaml:"key_id"`
+	Source    string `yaml:"source"`
+	KeyEnv    string `yaml:"key_env"`
+	KMSRegion string `yaml:"kms_region"`
+}
+
+// TrustConfig scores each user from their history over Window, recomputed
+// every Interval: the block rate and average safety score of their requests
+// (of those that were scored) plus operator feedback. Users scoring below LowBelow are in the "low" tier
+// and at or above HighAbove in the "high" tier; everyone else, including
+// users with fewer than MinRequests requests, is "standard". Tiers maps a
+// tier name to the policy applied to its users.
+type TrustConfig struct {
+	Enabled     bool                   `yaml:"enabled"`
+	Window      time.Duration          `yaml:"window"`
+	Interval    time.Duration          `yaml:"interval"`
+	MinRequests int                    `yaml:"min_requests"`
+	LowBelow    float64                `yaml:"low_below"`
+	HighAbove   float64                `yaml:"high_above"`
+	Tiers       map[string]TrustPolicy `yaml:"tiers"`
+}
+
+// TrustPolicy is how a trust tier is treated. Plan replaces the user's plan
+// while they are in the tier. SyncSafety classifies prompts before they are
+// forwarded and blocks unsafe ones; SkipSafetyAudit drops the after-the-fact
+// classification for the tier, leaving its prompts unscored.
+type TrustPolicy struct {
+	Plan            string `yaml:"plan"`
+	SyncSafety      bool   `yaml:"sync_safety"`
+	SkipSafetyAudit bool   `yaml:"skip_safety_audit"`
+}
+
+// Pricing maps model names to their token prices, used to estimate spend in
+// the usage rollups. Models without a price are counted at zero cost.
+type Pricing map[string]ModelPrice
+
+// ModelPrice is what a model costs per million input and output tokens.
+type ModelPrice struct {
+	Input  float64 `yaml:"input"`
+	Output float64 `yaml:"output"`
+}
+
+// Cost estimates the price of one request to model.
+func (p Pricing) Cost(model string, input, output int) float64 {
+	price := p[model]
+	return (float64(input)*price.Input + float64(output)*price.Output) / 1e6
+}
+
+// Window returns the quiet hours as offsets from midnight.
+func (m MaintenanceConfig) Window() (start, end time.Duration, err error) {
+	if start, err = clockOffset(m.QuietStart); err != nil {
+		return 0, 0, fmt.Errorf("quiet_start: %w", err)
+	}
+	if end, err = clockOffset(m.QuietEnd); err != nil {
+		return 0, 0, fmt.Errorf("quiet_end: %w", err)
+	}
+	if start == end {
+		return 0, 0, errors.New("quiet_start and quiet_end must differ")
+	}
+	return start, end, nil
+}
+
+func clockOffset(hhmm string) (time.Duration, error) {
+	t, err := time.Parse("15:04", hhmm)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not an HH:MM time", hhmm)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// ScheduleConfig holds time-of-day policies. Windows are evaluated in the
+// caller's timezone: UserTimezones (user ID to IANA zone) or Timezone.
+// Each rule applies inside its window, or outside it with Outside set.
+type ScheduleConfig struct {
+	Timezone      string                `yaml:"timezone"`
+	UserTimezones map[string]string     `yaml:"user_timezones"`
+	Windows       map[string]TimeWindow `yaml:"windows"`
+	Rules         []ScheduleRule        `yaml:"rules"`
+}
+
+// TimeWindow is a daily "HH:MM" range on the listed days (mon..sun, all
+// days when empty). A window ending before it starts wraps past midnight.
+type TimeWindow struct {
+	Days  []string `yaml:"days"`
+	Start string   `yaml:"start"`
+	End   string   `yaml:"end"`
+}
+
+// ScheduleRule rejects DenyModels, and with SyncSafety classifies prompts
+// before forwarding, while it applies.
+type ScheduleRule struct {
+	Name       string   `yaml:"name"`
+	Window     string   `yaml:"window"`
+	Outside    bool     `yaml:"outside"`
+	DenyModels []string `yaml:"deny_models"`
+	SyncSafety bool     `yaml:"sync_safety"`
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// Parse returns the window's weekdays and its start and end as offsets from
+// midnight.
+func (w TimeWindow) Parse() (days []time.Weekday, start, end time.Duration, err error) {
+	for _, d := range w.Days {
+		day, ok := weekdays[strings.ToLower(d)]
+		if !ok {
+			return nil, 0, 0, fmt.Errorf("unknown day %q (want mon..sun)", d)
+		}
+		days = append(days, day)
+	}
+	if start, err = clockOffset(w.Start); err != nil {
+		return nil, 0, 0, fmt.Errorf("start: %w", err)
+	}
+	if end, err = clockOffset(w.End); err != nil {
+		return nil, 0, 0, fmt.Errorf("end: %w", err)
+	}
+	if start == end {
+		return nil, 0, 0, errors.New("start and end must differ")
+	}
+	return days, start, end, nil
+}
+
+// AdminConfig protects the /api admin surface. Tokens maps an admin name to
+// its bearer token; with no tokens the API is unauthenticated. Clients that
+// fail MaxFailures times within FailureWindow are locked out for Lockout.
+type AdminConfig struct {
+	Tokens        map[string]string `yaml:"tokens" secret:"true"`
+	RateLimit     AdminRateLimit    `yaml:"rate_limit"`
+	MaxFailures   int               `yaml:"max_failures"`
+	FailureWindow time.Duration     `yaml:"failure_window"`
+	Lockout       time.Duration     `yaml:"lockout"`
+}
+
+// AdminRateLimit is the per-IP request rate allowed on the admin API.
+type AdminRateLimit struct {
+	Requests int           `yaml:"requests"`
+	Window   time.Duration `yaml:"window"`
+}
+
+// QuotaConfig caps how many proxy requests each user may make per UTC day.
+// Users overrides the default for individual users; a limit of 0 means
+// unlimited.
+type QuotaConfig struct {
+	Enabled        bool           `yaml:"enabled"`
+	RequestsPerDay int            `yaml:"requests_per_day"`
+	Users          map[string]int `yaml:"users"`
+}
+
+// SigningConfig controls HMAC-signed requests from services. Keys are
+// managed via /api/signing-keys; Tolerance bounds clock skew and how long
+// a signature is remembered for replay protection.
+type SigningConfig struct {
+	Tolerance time.Duration `yaml:"tolerance"`
+}
+
+// IdentityConfig controls how proxy callers are identified. Signed requests,
+// virtual keys when enabled and, when a JWT secret is set, bearer JWTs are
+// authenticated; otherwise X-User-ID is trusted if TrustHeader is set.
+// Require rejects requests that carry none of these credentials.
+type IdentityConfig struct {
+	TrustHeader bool              `yaml:"trust_header"`
+	Require     bool              `yaml:"require"`
+	JWT         JWTConfig         `yaml:"jwt"`
+	VirtualKeys VirtualKeysConfig `yaml:"virtual_keys"`
+}
+
+// JWTConfig validates HMAC-signed JWTs; Claim holds the user ID.
+type JWTConfig struct {
+	Secret   string `yaml:"secret" secret:"true"`
+	Issuer   string `yaml:"issuer"`
+	Audience string `yaml:"audience"`
+	Claim    string `yaml:"claim"`
+}
+
+// VirtualKeysConfig accepts the keys issued at /api/keys, sent as
+// "Authorization: Bearer vk_...". Keys issued without an expiry get
+// DefaultExpiry (zero for none), and a rotated key keeps working for
+// RotationGrace unless the rotation asks for another grace period.
+type VirtualKeysConfig struct {
+	Enabled       bool          `yaml:"enabled"`
+	DefaultExpiry time.Duration `yaml:"default_expiry"`
+	RotationGrace time.Duration `yaml:"rotation_grace"`
+}
+
+// PortalConfig enables the /portal endpoints, where callers authenticated
+// by a signing key or JWT see their own usage, cost, quota and their
+// LogLimit most recent interactions with bodies redacted.
+type PortalConfig struct {
+	Enabled  bool `yaml:"enabled"`
+	LogLimit int  `yaml:"log_limit"`
+}
+
+// ParamPoliciesConfig sets defaults and limits for the model and generation
+// parameters of Cohere and OpenAI-compatible chat requests, per user and per
+// team. A caller gets their entry in Users, else that of the team in Teams
+// listing them as a member; other callers are unaffected.
+type ParamPoliciesConfig struct {
+	Users map[string]ParamPolicyConfig `yaml:"users"`
+	Teams map[string]ParamPolicyConfig `yaml:"teams"`
+}
+
+// ParamPolicyConfig is one user's or team's parameter policy. Model,
+// MaxTokens and Temperature are set on requests without them.
+// MaxTokensLimit, TemperatureRange ([min, max]) and DisallowTools bound what
+// callers may ask for: Action "rewrite" (the default) brings values into
+// range and removes tools, "reject" refuses the request. Members lists the
+// users of a team.
+type ParamPolicyConfig struct {
+	Members          []string  `yaml:"members"`
+	Model            string    `yaml:"model"`
+	MaxTokens        int       `yaml:"max_tokens"`
+	Temperature      *float64  `yaml:"temperature"`
+	MaxTokensLimit   int       `yaml:"max_tokens_limit"`
+	TemperatureRange []float64 `yaml:"temperature_range"`
+	DisallowTools    bool      `yaml:"disallow_tools"`
+	Action           string    `yaml:"action"`
+}
+
+// PlansConfig defines subscription tiers and assigns users to them. Users
+// without an assignment get the Default plan; when Default is empty they
+// are unrestricted. No plans disables plan enforcement.
+type PlansConfig struct {
+	Default string                `yaml:"default"`
+	Users   map[string]string     `yaml:"users"`
+	Plans   map[string]PlanConfig `yaml:"plans"`
+}
+
+// PlanConfig holds the limits of one plan. Zero limits and an empty model
+// list mean unrestricted.
+type PlanConfig struct {
+	MaxPromptBytes  int      `yaml:"max_prompt_bytes"`
+	MaxOutputTokens int      `yaml:"max_output_tokens"`
+	Models          []string `yaml:"models"`
+	Stream          bool     `yaml:"stream"`
+}
+
+// ConversationConfig caps the cumulative tokens of a conversation, identified
+// by X-Vantage-Conversation or the request's conversation_id. Clients are
+// warned once WarnRatio of MaxTokens is used and blocked at MaxTokens.
+type ConversationConfig struct {
+	Enabled   bool    `yaml:"enabled"`
+	MaxTokens int     `yaml:"max_tokens"`
+	WarnRatio float64 `yaml:"warn_ratio"`
+}
+
+// ProjectsConfig sets monthly budgets, in estimated cost, for the internal
+// projects requests are charged to through X-Vantage-Project or a virtual
+// key. Projects are warned once WarnRatio of their budget is spent and
+// blocked for the rest of the calendar month (UTC) once it all is. Projects
+// without a budget are only recorded.
+type ProjectsConfig struct {
+	Budgets   map[string]float64 `yaml:"budgets"`
+	WarnRatio float64            `yaml:"warn_ratio"`
+}
+
+// TruncationConfig drops the oldest chat turns of prompts whose estimated
+// size plus reserved output exceeds the model's context window, instead of
+// letting the provider reject them. Windows are in tokens; DefaultWindow
+// applies to models not listed and 0 leaves them untouched.
+type TruncationConfig struct {
+	Enabled       bool           `yaml:"enabled"`
+	DefaultWindow int            `yaml:"default_window"`
+	Windows       map[string]int `yaml:"windows"`
+	ReserveTokens int            `yaml:"reserve_tokens"`
+}
+
+// TransformConfig rewrites the requests under Paths (path prefixes; empty
+// for all) before they are forwarded, after governance has checked them.
+// SystemPrompt is put ahead of the request's system prompt and
+// AppendInstructions after its latest user message; MaxParams lowers
+// numeric parameters above their limit and RemoveParams deletes
+// parameters, with nested ones named like generationConfig.temperature.
+// Every matching transform applies, in order.
+type TransformConfig struct {
+	Name               string             `yaml:"name"`
+	Paths              []string           `yaml:"paths"`
+	SystemPrompt       string             `yaml:"system_prompt"`
+	AppendInstructions string             `yaml:"append_instructions"`
+	MaxParams          map[string]float64 `yaml:"max_params"`
+	RemoveParams       []string           `yaml:"remove_params"`
+}
+
+// OpenAIConfig serves an OpenAI-compatible chat completions API at
+// /openai/v1/chat/completions, so OpenAI SDKs can use the gateway as their
+// base URL. Each request goes to the provider of the first route matching
+// its model, or to DefaultProvider: "cohere", "gemini", "mistral", "groq" or
+// the name of a local server. Bedrock has no OpenAI-compatible API.
+type OpenAIConfig struct {
+	Enabled         bool                `yaml:"enabled"`
+	DefaultProvider string              `yaml:"default_provider"`
+	Routes          []OpenAIRouteConfig `yaml:"routes"`
+}
+
+// OpenAIRouteConfig sends models starting with any of Models to Provider.
+type OpenAIRouteConfig struct {
+	Models   []string `yaml:"models"`
+	Provider string   `yaml:"provider"`
+}
+
+// ProvidersConfig enables upstreams besides Cohere, each mounted under its
+// own path prefix and run through the same governance pipeline.
+type ProvidersConfig struct {
+	Gemini  GeminiConfig         `yaml:"gemini"`
+	Bedrock BedrockConfig        `yaml:"bedrock"`
+	Mistral CompatProviderConfig `yaml:"mistral"`
+	Groq    CompatProviderConfig `yaml:"groq"`
+	Local   []LocalConfig        `yaml:"local"`
+}
+
+// UpstreamsConfig spreads Cohere traffic over several API keys or regional
+// endpoints. Strategy is round_robin, least_latency or weighted. Requests
+// failing with a connection error, 429 or 5xx are retried on the next
+// target, and a target failing FailureThreshold times in a row is taken
+// out of rotation for Cooldown. With no targets the single COHERE_API_KEY
+// upstream is used.
+type UpstreamsConfig struct {
+	Strategy         string           `yaml:"strategy"`
+	FailureThreshold int              `yaml:"failure_threshold"`
+	Cooldown         time.Duration    `yaml:"cooldown"`
+	Targets          []UpstreamTarget `yaml:"targets"`
+}
+
+// TransportConfig tunes the HTTP connections to every upstream provider.
+// MaxIdleConnsPerHost is what keeps busy gateways from reconnecting to
+// api.cohere.com on every request; Go's default is 2. MaxConnsPerHost and
+// ResponseHeaderTimeout are unlimited when 0. TLSSessionCache is the number
+// of TLS sessions kept for resumption, 0 to disable, and HTTP2 negotiates
+// HTTP/2 with servers that offer it.
+type TransportConfig struct {
+	MaxIdleConns          int           `yaml:"max_idle_conns"`
+	MaxIdleConnsPerHost   int           `yaml:"max_idle_conns_per_host"`
+	MaxConnsPerHost       int           `yaml:"max_conns_per_host"`
+	IdleConnTimeout       time.Duration `yaml:"idle_conn_timeout"`
+	DialTimeout           time.Duration `yaml:"dial_timeout"`
+	KeepAlive             time.Duration `yaml:"keep_alive"`
+	TLSHandshakeTimeout   time.Duration `yaml:"tls_handshake_timeout"`
+	ResponseHeaderTimeout time.Duration `yaml:"response_header_timeout"`
+	TLSSessionCache       int           `yaml:"tls_session_cache"`
+	HTTP2                 bool          `yaml:"http2"`
+}
+
+// ChaosConfig injects faults into upstream calls, for testing how clients
+// retry and how the upstream pool fails over without hammering the real
+// provider. LatencyRate of calls are delayed by Latency; of the rest,
+// RateLimitRate are answered with a 429 and a Retry-After of RetryAfter,
+// and ErrorRate with ErrorStatus, without reaching the provider. Paths
+// limits it to those upstream path prefixes. Never enable it in production.
+type ChaosConfig struct {
+	Enabled       bool          `yaml:"enabled"`
+	Paths         []string      `yaml:"paths"`
+	LatencyRate   float64       `yaml:"latency_rate"`
+	Latency       time.Duration `yaml:"latency"`
+	RateLimitRate float64       `yaml:"rate_limit_rate"`
+	RetryAfter    time.Duration `yaml:"retry_after"`
+	ErrorRate     float64       `yaml:"error_rate"`
+	ErrorStatus   int           `yaml:"error_status"`
+}
+
+// UpstreamTarget is one pooled endpoint. APIKeyEnv names an environment
+// variable holding the key, to keep it out of the config file; without
+// either key the default Cohere key is sent. StatusPage names the provider
+// whose status page or status webhook the target follows, "cohere" when
+// empty.
+type UpstreamTarget struct {
+	Name       string  `yaml:"name"`
+	BaseURL    string  `yaml:"base_url"`
+	APIKey     string  `yaml:"api_key" secret:"true"`
+	APIKeyEnv  string  `yaml:"api_key_env"`
+	Weight     float64 `yaml:"weight"`
+	StatusPage string  `yaml:"status_page"`
+}
+
+// SecretsConfig reads the provider API keys from HashiCorp Vault
+// (Source "vault") or AWS Secrets Manager ("aws") instead of the
+// environment, and reads them again every Refresh (zero for never) so that
+// rotated keys are used without a restart. References are "path#field": a
+// KV v2 path under Vault.Mount, or a Secrets Manager secret ID whose JSON
+// value holds field. Without a field, the secret's only field or whole
+// plain-text value is used.
+type SecretsConfig struct {
+	Source        string             `yaml:"source"`
+	Refresh       time.Duration      `yaml:"refresh"`
+	CohereAPIKey  string             `yaml:"cohere_api_key"`
+	GeminiAPIKey  string             `yaml:"gemini_api_key"`
+	MistralAPIKey string             `yaml:"mistral_api_key"`
+	GroqAPIKey    string             `yaml:"groq_api_key"`
+	Vault         VaultSecretsConfig `yaml:"vault"`
+	AWS           AWSSecretsConfig   `yaml:"aws"`
+}
+
+// VaultSecretsConfig locates the Vault server; Address defaults to
+// VAULT_ADDR and the token is read from VAULT_TOKEN. With RenewToken the
+// token is renewed on every refresh so that it outlives its TTL.
+type VaultSecretsConfig struct {
+	Address    string `yaml:"address"`
+	Mount      string `yaml:"mount"`
+	Namespace  string `yaml:"namespace"`
+	RenewToken bool   `yaml:"renew_token"`
+}
+
+// AWSSecretsConfig sets the Secrets Manager region, defaulting to the AWS
+// configuration. Credentials come from the default AWS chain.
+type AWSSecretsConfig struct {
+	Region string `yaml:"region"`
+}
+
+// MetricsConfig bounds the labels of the token, cost and per-caller
+// metrics. CallerLabel "user" labels the vantage_user_* metrics with the
+// caller, "team" with the team in Teams listing the caller ("other" for
+// callers in none) and "none" turns them off. Beyond MaxModels distinct
+// models and MaxCallers distinct callers, further values are counted as
+// "other"; zero removes the limit.
+type MetricsConfig struct {
+	CallerLabel string              `yaml:"caller_label"`
+	Teams       map[string][]string `yaml:"teams"`
+	MaxModels   int                 `yaml:"max_models"`
+	MaxCallers  int                 `yaml:"max_callers"`
+
+	// LatencyBuckets are the upper bounds, in seconds, of the request and
+	// upstream latency histograms, and TokenBuckets those of the per-request
+	// token histogram; empty keeps the defaults, which reach 120s and 128k
+	LatencyBuckets []float64 `yaml:"latency_buckets"`
+	TokenBuckets   []float64 `yaml:"token_buckets"`
+	// Exemplars attaches the W3C traceparent trace ID of a request to its
+	// latency observations, exposed in the OpenMetrics format
+	Exemplars bool `yaml:"exemplars"`
+}
+
+// GeminiConfig configures the Google Generative Language API, served under
+// /gemini. APIKey can also come from GEMINI_API_KEY.
+type GeminiConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	APIKey  string `yaml:"api_key" secret:"true"`
+	BaseURL string `yaml:"base_url"`
+}
+
+// BedrockConfig configures Amazon Bedrock Runtime, served under /bedrock.
+// Requests are SigV4-signed with credentials from the default AWS chain
+// (environment, shared config, IRSA web identity, ECS or EC2 roles). Region
+// falls back to AWS_REGION; BaseURL overrides the regional endpoint.
+type BedrockConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Region  string `yaml:"region"`
+	BaseURL string `yaml:"base_url"`
+}
+
+// CompatProviderConfig configures a hosted OpenAI-compatible API: Mistral,
+// served under /mistral with APIKey or MISTRAL_API_KEY, or Groq, served
+// under /groq with APIKey or GROQ_API_KEY. Requests to /v1/* whose body
+// names a model starting with one of ModelPrefixes are sent to it as well,
+// instead of to Cohere.
+type CompatProviderConfig struct {
+	Enabled       bool     `yaml:"enabled"`
+	APIKey        string   `yaml:"api_key" secret:"true"`
+	BaseURL       string   `yaml:"base_url"`
+	ModelPrefixes []string `yaml:"model_prefixes"`
+}
+
+// LocalConfig is a self-hosted inference server (Ollama, vLLM, llama.cpp)
+// served under /local/{name}. No credentials are added to its requests.
+type LocalConfig struct {
+	Name    string `yaml:"name"`
+	BaseURL string `yaml:"base_url"`
+}
+
+// SecurityHeadersConfig controls the hardening headers on admin and UI
+// routes. HSTS is only sent on HTTPS requests.
+type SecurityHeadersConfig struct {
+	Enabled               bool          `yaml:"enabled"`
+	HSTSMaxAge            time.Duration `yaml:"hsts_max_age"`
+	HSTSIncludeSubdomains bool          `yaml:"hsts_include_subdomains"`
+	FrameOptions          string        `yaml:"frame_options"`
+	ContentSecurityPolicy string        `yaml:"content_security_policy"`
+	ReferrerPolicy        string        `yaml:"referrer_policy"`
+}
+
+// CORSConfig controls which browser origins may call the gateway, such as
+// a dashboard deployed on its own domain. An origin may contain one "*"
+// wildcard ("https://*.example.com"); "*" alone allows any origin and
+// cannot be combined with AllowCredentials.
+type CORSConfig struct {
+	AllowedOrigins   []string      `yaml:"allowed_origins"`
+	AllowedMethods   []string      `yaml:"allowed_methods"`
+	AllowedHeaders   []string      `yaml:"allowed_headers"`
+	ExposedHeaders   []string      `yaml:"exposed_headers"`
+	AllowCredentials bool          `yaml:"allow_credentials"`
+	MaxAge           time.Duration `yaml:"max_age"`
+}
+
+// IPAccessConfig restricts the client networks that may reach the gateway:
+// Proxy covers the proxied model routes and Admin the authenticated /api
+// routes. The client address is the connection's peer, or the address a
+// proxy in server.trusted_proxies forwarded the request for.
+type IPAccessConfig struct {
+	Proxy IPRanges `yaml:"proxy"`
+	Admin IPRanges `yaml:"admin"`
+}
+
+// IPRanges lists CIDR ranges or single addresses. Deny wins over Allow, and
+// an empty Allow admits every address that is not denied.
+type IPRanges struct {
+	Allow []string `yaml:"allow"`
+	Deny  []string `yaml:"deny"`
+}
+
+// Enabled reports whether any range is configured.
+func (r IPRanges) Enabled() bool {
+	return len(r.Allow) > 0 || len(r.Deny) > 0
+}
+
+// Parse returns the allow and deny ranges; a bare address becomes a
+// single-host range.
+func (r IPRanges) Parse() (allow, deny []netip.Prefix, err error) {
+	if allow, err = parsePrefixes(r.Allow); err != nil {
+		return nil, nil, fmt.Errorf("allow: %w", err)
+	}
+	if deny, err = parsePrefixes(r.Deny); err != nil {
+		return nil, nil, fmt.Errorf("deny: %w", err)
+	}
+	return allow, deny, nil
+}
+
+func parsePrefixes(ranges []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(ranges))
+	for _, s := range ranges {
+		if addr, err := netip.ParseAddr(s); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid address or CIDR %q", s)
+		}
+		prefixes = append(prefixes, p.Masked())
+	}
+	return prefixes, nil
+}
+
+// Default returns the configuration used when no config file is present.
+func Default() *Config {
+	return &Config{
+		Provider: "cohere",
+		Server: ServerConfig{
+			Addr: ":8080",
+			GRPC: GRPCConfig{Addr: ":9090"},
+			TLS: TLSConfig{
+				MinVersion:   "1.2",
+				RedirectAddr: ":80",
+				Autocert:     AutocertConfig{CacheDir: "./certs"},
+			},
+		},
+		ForbiddenKeywords: []string{},
+		Reports: ReportsConfig{
+			IdleDays: 30,
+			Interval: 24 * time.Hour,
+			Compliance: ComplianceReportConfig{
+				Schedule:  "weekly",
+				AttachPDF: true,
+				Timezone:  "UTC",
+				TopN:      10,
+			},
+		},
+		ProviderStatus: StatusConfig{
+			Interval: 2 * time.Minute,
+			Pages: map[string]string{
+				"cohere": "https://status.cohere.com/api/v2/status.json",
+			},
+			DegradedWeight: 0.1,
+		},
+		RateLimit: RateLimitConfig{
+			Requests:           60,
+			Window:             time.Minute,
+			UpstreamRetryAfter: 5 * time.Second,
+		},
+		Concurrency: ConcurrencyConfig{
+			PerUser:      4,
+			QueueTimeout: 5 * time.Second,
+		},
+		Redaction: RedactionConfig{
+			Enabled:  true,
+			Mode:     "mask",
+			Builtins: map[string]bool{"email": true, "phone": true, "uuid": true},
+		},
+		RequestSchemas: RequestSchemasConfig{Builtin: true},
+		ResponseSafety: ResponseSafetyConfig{
+			Threshold: 0.5,
+			Fallback:  "I'm sorry, but I can't help with that.",
+		},
+		Moderation: ModerationConfig{
+			Classifier: "cohere",
+			Thresholds: map[string]float64{
+				"violence":  0.7,
+				"self_harm": 0.5,
+				"sexual":    0.7,
+				"hate":      0.7,
+				"illegal":   0.7,
+			},
+		},
+		ResponsePII: ResponsePIIConfig{
+			Action: "redact",
+			Types:  []string{"email", "phone", "ssn"},
+		},
+		Webhooks: WebhooksConfig{
+			SafetyThreshold: 0.5,
+			MaxAttempts:     5,
+			RetryBackoff:    2 * time.Second,
+		},
+		Latency: LatencyConfig{
+			SlowThreshold: 10 * time.Second,
+			Window:        time.Hour,
+		},
+		Incidents: IncidentsConfig{
+			Window:      30 * time.Minute,
+			SpikeCount:  10,
+			SpikeWindow: 5 * time.Minute,
+		},
+		Anomalies: AnomalyConfig{
+			Interval:    15 * time.Minute,
+			Baseline:    14 * 24 * time.Hour,
+			SpikeFactor: 10,
+			MinRequests: 20,
+			MinBlocked:  5,
+		},
+		Cache: CacheConfig{
+			TTL:        10 * time.Minute,
+			MaxEntries: 1000,
+			Paths:      []string{"/v1/chat", "/v1/embed", "/v2/chat", "/v2/embed"},
+		},
+		Dedup: DedupConfig{
+			Window: 5 * time.Second,
+			Mode:   "share",
+			Paths:  []string{"/v1/chat", "/v1/generate", "/v2/chat"},
+		},
+		Redis: RedisConfig{
+			URL:       "redis://localhost:6379/0",
+			KeyPrefix: "vantage:",
+			Timeout:   500 * time.Millisecond,
+			PoolSize:  16,
+			RateLimit: true,
+			Quotas:    true,
+			Cache:     true,
+		},
+		ClickHouse: ClickHouseConfig{
+			URL:           "http://localhost:8123",
+			Database:      "default",
+			Table:         "vantage_interactions",
+			Username:      "default",
+			Timeout:       10 * time.Second,
+			BatchSize:     1000,
+			FlushInterval: time.Second,
+			Retention:     365 * 24 * time.Hour,
+			Stats:         true,
+		},
+		Health: HealthConfig{
+			Timeout:        2 * time.Second,
+			QueueThreshold: 0.9,
+			DropWindow:     time.Minute,
+		},
+		AuditQueue: AuditQueueConfig{
+			Size:       100,
+			Mode:       "drop",
+			RetryAfter: time.Second,
+		},
+		AccessLog: AccessLogConfig{
+			Retention: 365 * 24 * time.Hour,
+		},
+		Admin: AdminConfig{
+			RateLimit:     AdminRateLimit{Requests: 120, Window: time.Minute},
+			MaxFailures:   5,
+			FailureWindow: 15 * time.Minute,
+			Lockout:       15 * time.Minute,
+		},
+		SecurityHeaders: SecurityHeadersConfig{
+			Enabled:               true,
+			HSTSMaxAge:            365 * 24 * time.Hour,
+			FrameOptions:          "DENY",
+			ContentSecurityPolicy: "default-src 'self'; script-src 'self'; style-src 'self' 'unsafe-inline'; img-src 'self' data:; connect-src 'self'; frame-ancestors 'none'",
+			ReferrerPolicy:        "no-referrer",
+		},
+		CORS: CORSConfig{
+			AllowedOrigins:   []string{"http://localhost:3000"},
+			AllowedMethods:   []string{"GET", "POST", "OPTIONS"},
+			AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-User-ID", "X-Vantage-Metadata"},
+			AllowCredentials: true,
+		},
+		Quotas: QuotaConfig{
+			RequestsPerDay: 500,
+		},
+		Signing: SigningConfig{
+			Tolerance: 5 * time.Minute,
+		},
+		Portal: PortalConfig{
+			LogLimit: 20,
+		},
+		Conversations: ConversationConfig{
+			MaxTokens: 200000,
+			WarnRatio: 0.8,
+		},
+		Projects: ProjectsConfig{
+			WarnRatio: 0.8,
+		},
+		OpenAI: OpenAIConfig{
+			DefaultProvider: "cohere",
+		},
+		Truncation: TruncationConfig{
+			DefaultWindow: 128000,
+			Windows: map[string]int{
+				"command":       4096,
+				"command-light": 4096,
+			},
+			ReserveTokens: 1024,
+		},
+		Maintenance: MaintenanceConfig{
+			QuietStart: "03:00",
+			QuietEnd:   "05:00",
+			Vacuum:     true,
+		},
+		ReadOnly: ReadOnlyConfig{
+			Status:     503,
+			Message:    "Vantage is in read-only mode for maintenance",
+			RetryAfter: 5 * time.Minute,
+		},
+		Settings: SettingsConfig{
+			RefreshInterval: 30 * time.Second,
+		},
+		Archive: ArchiveConfig{
+			After:             30 * 24 * time.Hour,
+			Interval:          time.Hour,
+			BatchSize:         500,
+			Compression:       "gzip",
+			ObjectStoreConfig: ObjectStoreConfig{Backend: "file", Dir: "archive", Prefix: "vantage/"},
+		},
+		LogExport: LogExportConfig{
+			After:             90 * 24 * time.Hour,
+			Interval:          24 * time.Hour,
+			BatchSize:         10000,
+			Format:            "jsonl",
+			Compression:       "gzip",
+			ObjectStoreConfig: ObjectStoreConfig{Backend: "file", Dir: "exports", Prefix: "vantage/"},
+		},
+		Storage: StorageConfig{
+			BodyCompression: "none",
+			Encryption: EncryptionConfig{
+				KeyID:  "primary",
+				Source: "env",
+				KeyEnv: "VANTAGE_BODY_KEY",
+			},
+			WriteRetries:   3,
+			RetryBackoff:   250 * time.Millisecond,
+			DeadLetterFile: "dead_letters.jsonl",
+		},
+		Schedules: ScheduleConfig{
+			Timezone: "UTC",
+		},
+		Trust: TrustConfig{
+			Window:      7 * 24 * time.Hour,
+			Interval:    15 * time.Minute,
+			MinRequests: 20,
+			LowBelow:    0.6,
+			HighAbove:   0.9,
+		},
+		Transport: TransportConfig{
+			MaxIdleConns:        200,
+			MaxIdleConnsPerHost: 64,
+			IdleConnTimeout:     90 * time.Second,
+			DialTimeout:         30 * time.Second,
+			KeepAlive:           30 * time.Second,
+			TLSHandshakeTimeout: 10 * time.Second,
+			TLSSessionCache:     64,
+			HTTP2:               true,
+		},
+		Upstreams: UpstreamsConfig{
+			Strategy:         "round_robin",
+			FailureThreshold: 3,
+			Cooldown:         30 * time.Second,
+		},
+		Chaos: ChaosConfig{
+			Latency:     2 * time.Second,
+			RetryAfter:  time.Second,
+			ErrorStatus: 503,
+		},
+		Secrets: SecretsConfig{
+			Refresh: 5 * time.Minute,
+			Vault:   VaultSecretsConfig{Mount: "secret", RenewToken: true},
+		},
+		Metrics: MetricsConfig{
+			CallerLabel: "user",
+			MaxModels:   100,
+			MaxCallers:  1000,
+			Exemplars:   true,
+		},
+		Identity: IdentityConfig{
+			TrustHeader: true,
+			JWT:         JWTConfig{Claim: "sub"},
+			VirtualKeys: VirtualKeysConfig{
+				DefaultExpiry: 90 * 24 * time.Hour,
+				RotationGrace: 24 * time.Hour,
+			},
+		},
+	}
+}
+
+// LoadConfig reads the config file at path, then the overlay for the
+// environment named by VANTAGE_ENV when it is set, and applies the
+// environment-variable overrides on top.
 func LoadConfig(path string) (*Config, error) {
+	return loadConfig(path, false)
+}
+
+// LoadConfigStrict is LoadConfig that also rejects keys the config does not
+// know, such as misspelt settings that would silently keep their default.
+// All problems are reported together; the config is returned along with
+// them when the file could be parsed.
+func LoadConfigStrict(path string) (*Config, error) {
+	return loadConfig(path, true)
+}
+
+// LoadDefaults is LoadConfig for a deployment without a config file: the
+// defaults with the environment-variable overrides applied.
+func LoadDefaults() (*Config, error) {
+	cfg := Default()
+	errs := cfg.applyEnv(os.LookupEnv)
+	cfg.applyPolicyPacks()
+	if err := cfg.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return cfg, nil
+}
+
+func loadConfig(path string, strict bool) (*Config, error) {
+	cfg := Default()
+	errs, err := decodeFile(cfg, path, strict)
+	if err != nil {
+		return nil, err
+	}
+	if env := os.Getenv(EnvironmentVar); env != "" {
+		overlay := OverlayPath(path, env)
+		overlayErrs, err := decodeFile(cfg, overlay, strict)
+		if err != nil {
+			// Not wrapped, so that a missing overlay is not taken for a missing config
+			return nil, fmt.Errorf("%s=%s: %v", EnvironmentVar, env, err)
+		}
+		for _, e := range overlayErrs {
+			errs = append(errs, fmt.Errorf("%s: %w", overlay, e))
+		}
+	}
+	errs = append(errs, cfg.applyEnv(os.LookupEnv)...)
+	cfg.applyPolicyPacks()
+	if err := cfg.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if len(errs) > 0 {
+		if strict {
+			return cfg, errors.Join(errs...)
+		}
+		return nil, errors.Join(errs...)
+	}
+	return cfg, nil
+}
+
+// decodeFile decodes the YAML file at path over cfg, leaving the fields it
+// does not set alone. In strict mode unknown keys are returned as problems
+// rather than failing the decode.
+func decodeFile(cfg *Config, path string, strict bool) ([]error, error) {
 	f, err := os.Open(path)
 	if err != nil {
 		return nil, err
 	}
 	defer f.Close()
 
-	var cfg Config
-	err = yaml.NewDecoder(f).Decode(&cfg)
-	return &cfg, err
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(strict)
+	var errs []error
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		var typeErr *yaml.TypeError
+		if !strict || !errors.As(err, &typeErr) {
+			return nil, err
+		}
+		// Decoding went on past the unknown keys, so the rest can be checked
+		for _, e := range typeErr.Errors {
+			errs = append(errs, errors.New(e))
+		}
+	}
+	return errs, nil
+}
+
+// validateObjectStore checks that the backend of an object store has what it needs.
+func validateObjectStore(field string, o ObjectStoreConfig) []error {
+	switch o.Backend {
+	case "file":
+		if o.Dir == "" {
+			return []error{fmt.Errorf("%s: the file backend needs a dir", field)}
+		}
+	case "s3", "gcs":
+		if o.Bucket == "" {
+			return []error{fmt.Errorf("%s: the %s backend needs a bucket", field, o.Backend)}
+		}
+	default:
+		return []error{fmt.Errorf("%s: unknown backend %q (want file, s3 or gcs)", field, o.Backend)}
+	}
+	if o.Endpoint != "" {
+		if u, err := url.Parse(o.Endpoint); err != nil || u.Host == "" {
+			return []error{fmt.Errorf("%s: endpoint %q is not a URL", field, o.Endpoint)}
+		}
+	}
+	return nil
+}
+
+// validateRedaction checks the builtins and custom patterns of the
+// redaction block at field.
+func validateRedaction(field string, r RedactionConfig) []error {
+	var errs []error
+	for name := range r.Builtins {
+		if !slices.Contains(builtinRedactions, name) {
+			errs = append(errs, fmt.Errorf("%s.builtins: unknown pattern %q (want one of %s)", field, name, strings.Join(builtinRedactions, ", ")))
+		}
+	}
+	seen := map[string]bool{}
+	for i, p := range r.Patterns {
+		if !redactionNameRegex.MatchString(p.Name) {
+			errs = append(errs, fmt.Errorf("%s.patterns[%d]: name %q must be lowercase letters and underscores", field, i, p.Name))
+		}
+		if seen[p.Name] || slices.Contains(builtinRedactions, p.Name) {
+			errs = append(errs, fmt.Errorf("%s.patterns[%d]: duplicate pattern name %q", field, i, p.Name))
+		}
+		seen[p.Name] = true
+		if _, err := regexp.Compile(p.Regex); err != nil {
+			errs = append(errs, fmt.Errorf("%s.patterns[%d] (%s): invalid regex: %w", field, i, p.Name, err))
+		}
+	}
+	for lang, l := range r.Locales {
+		errs = append(errs, validateLocaleRedaction(field+".locales."+lang, lang, l)...)
+	}
+	return errs
+}
+
+// validateLocaleRedaction checks the language and patterns of one locale.
+func validateLocaleRedaction(field, lang string, l LocaleRedaction) []error {
+	if !slices.Contains(middleware.LocaleLanguages, lang) {
+		return []error{fmt.Errorf("%s: unknown language (want one of %s)", field, strings.Join(middleware.LocaleLanguages, ", "))}
+	}
+	var builtins []string
+	for _, p := range middleware.LocaleRedactionPatterns(lang) {
+		builtins = append(builtins, p.Name)
+	}
+	var errs []error
+	for name := range l.Builtins {
+		if !slices.Contains(builtins, name) {
+			want := "none"
+			if len(builtins) > 0 {
+				want = "one of " + strings.Join(builtins, ", ")
+			}
+			errs = append(errs, fmt.Errorf("%s.builtins: unknown pattern %q (want %s)", field, name, want))
+		}
+	}
+	seen := map[string]bool{}
+	for i, p := range l.Patterns {
+		if !redactionNameRegex.MatchString(p.Name) {
+			errs = append(errs, fmt.Errorf("%s.patterns[%d]: name %q must be lowercase letters and underscores", field, i, p.Name))
+		}
+		if seen[p.Name] || slices.Contains(builtins, p.Name) {
+			errs = append(errs, fmt.Errorf("%s.patterns[%d]: duplicate pattern name %q", field, i, p.Name))
+		}
+		seen[p.Name] = true
+		if _, err := regexp.Compile(p.Regex); err != nil {
+			errs = append(errs, fmt.Errorf("%s.patterns[%d] (%s): invalid regex: %w", field, i, p.Name, err))
+		}
+	}
+	return errs
+}
+
+// Validate rejects settings that would otherwise fail at request time. It
+// reports every problem it finds, joined into one error.
+func (c *Config) Validate() error {
+	var errs []error
+	if c.Provider != "cohere" && c.Provider != "mock" {
+		errs = append(errs, fmt.Errorf("provider: unknown provider %q (want cohere or mock)", c.Provider))
+	}
+	for i, name := range c.PolicyPacks {
+		if _, ok := PolicyPacks[name]; !ok {
+			errs = append(errs, fmt.Errorf("policy_packs[%d]: unknown pack %q (want one of %s)", i, name, strings.Join(policyPackNames(), ", ")))
+		}
+	}
+	if _, err := c.Server.TrustedProxyPrefixes(); err != nil {
+		errs = append(errs, fmt.Errorf("server.trusted_proxies: %w", err))
+	}
+	errs = append(errs, validateRedaction("redaction", c.Redaction)...)
+	profiles := map[string]bool{}
+	for i, p := range c.Governance.Profiles {
+		field := fmt.Sprintf("governance.profiles[%d]", i)
+		switch {
+		case p.Name == "":
+			errs = append(errs, fmt.Errorf("%s: name is required", field))
+		case profiles[p.Name]:
+			errs = append(errs, fmt.Errorf("%s: duplicate profile name %q", field, p.Name))
+		}
+		profiles[p.Name] = true
+		if len(p.Paths) == 0 {
+			errs = append(errs, fmt.Errorf("%s (%s): paths are required", field, p.Name))
+		}
+		for _, path := range p.Paths {
+			if !strings.HasPrefix(path, "/") {
+				errs = append(errs, fmt.Errorf("%s (%s): path %q must start with /", field, p.Name, path))
+			}
+		}
+		if r := p.Redaction; r != nil {
+			errs = append(errs, validateRedaction(field+".redaction", *r)...)
+			// The vault only exists when the global mode tokenizes
+			if r.Mode == "tokenize" && c.Redaction.Mode != "tokenize" {
+				errs = append(errs, fmt.Errorf("%s.redaction (%s): mode tokenize needs redaction.mode tokenize", field, p.Name))
+			}
+		}
+	}
+	for i, r := range c.Headers.Rules {
+		if r.Name == "" || r.Header == "" {
+			errs = append(errs, fmt.Errorf("headers.rules[%d]: name and header are required", i))
+		}
+		if !r.Required && r.Pattern == "" {
+			errs = append(errs, fmt.Errorf("headers.rules[%d] (%s): set required or pattern", i, r.Name))
+		}
+		if _, err := regexp.Compile(r.Pattern); err != nil {
+			errs = append(errs, fmt.Errorf("headers.rules[%d] (%s): invalid pattern: %w", i, r.Name, err))
+		}
+	}
+	schemaPaths := map[string]bool{}
+	for i, r := range c.RequestSchemas.Routes {
+		field := fmt.Sprintf("request_schemas.routes[%d]", i)
+		switch {
+		case !strings.HasPrefix(r.Path, "/"):
+			errs = append(errs, fmt.Errorf("%s: path %q must start with /", field, r.Path))
+		case schemaPaths[r.Path]:
+			errs = append(errs, fmt.Errorf("%s: duplicate path %s", field, r.Path))
+		}
+		schemaPaths[r.Path] = true
+		if (r.File == "") == (r.Schema == nil) {
+			errs = append(errs, fmt.Errorf("%s (%s): set one of file or schema", field, r.Path))
+			continue
+		}
+		if _, err := r.Parse(); err != nil {
+			errs = append(errs, fmt.Errorf("%s (%s): %w", field, r.Path, err))
+		}
+	}
+	for _, name := range c.Quarantine.Readers {
+		if _, ok := c.Admin.Tokens[name]; !ok {
+			errs = append(errs, fmt.Errorf("quarantine.readers: %q is not an admin token name", name))
+		}
+	}
+	if r := c.ResponseSafety; r.Threshold < 0 || r.Threshold > 1 {
+		errs = append(errs, fmt.Errorf("response_safety: threshold %v must be between 0 and 1", r.Threshold))
+	}
+	if r := c.ResponseSafety; r.Enforce && r.Fallback == "" {
+		errs = append(errs, errors.New("response_safety: fallback is required with enforce"))
+	}
+	if m := c.Moderation; m.Enabled {
+		switch m.Classifier {
+		case "cohere":
+		case "mistral":
+			if !c.Providers.Mistral.Enabled {
+				errs = append(errs, errors.New("moderation: classifier mistral needs providers.mistral"))
+			}
+		case "openai":
+			if m.BaseURL != "" {
+				if err := checkURL(m.BaseURL); err != nil {
+					errs = append(errs, fmt.Errorf("moderation: base_url: %w", err))
+				}
+			}
+		default:
+			errs = append(errs, fmt.Errorf("moderation: unknown classifier %q (want cohere, mistral or openai)", m.Classifier))
+		}
+		for category, threshold := range m.Thresholds {
+			if !slices.Contains(middleware.ModerationCategories, category) {
+				errs = append(errs, fmt.Errorf("moderation.thresholds: unknown category %q (want %s)", category, strings.Join(middleware.ModerationCategories, ", ")))
+			}
+			if threshold <= 0 || threshold > 1 {
+				errs = append(errs, fmt.Errorf("moderation.thresholds.%s: %v must be above 0 and at most 1", category, threshold))
+			}
+		}
+	}
+	if p := c.ResponsePII; p.Enabled {
+		if p.Action != "redact" && p.Action != "block" && p.Action != "flag" {
+			errs = append(errs, fmt.Errorf("response_pii: action %q must be redact, block or flag", p.Action))
+		}
+		if len(p.Types) == 0 {
+			errs = append(errs, errors.New("response_pii: types must name at least one pattern"))
+		}
+		for _, t := range p.Types {
+			if !slices.Contains(builtinRedactions, t) {
+				errs = append(errs, fmt.Errorf("response_pii.types: unknown pattern %q (want one of %s)", t, strings.Join(builtinRedactions, ", ")))
+			}
+		}
+	}
+	if c.Maintenance.Enabled {
+		if _, _, err := c.Maintenance.Window(); err != nil {
+			errs = append(errs, fmt.Errorf("maintenance: %w", err))
+		}
+	}
+	for i, o := range c.CORS.AllowedOrigins {
+		if o == "*" && c.CORS.AllowCredentials {
+			errs = append(errs, errors.New("cors: allowed_origins \"*\" cannot be combined with allow_credentials"))
+		}
+		if strings.Count(o, "*") > 1 {
+			errs = append(errs, fmt.Errorf("cors.allowed_origins[%d]: %q may contain at most one wildcard", i, o))
+		}
+	}
+	if err := c.Latency.validate(); err != nil {
+		errs = append(errs, fmt.Errorf("latency: %w", err))
+	}
+	if a := c.Anomalies; a.Enabled && (a.Interval <= 0 || a.Baseline < 24*time.Hour || a.SpikeFactor <= 1) {
+		errs = append(errs, errors.New("anomalies: interval must be positive, baseline at least 24h and spike_factor above 1"))
+	}
+	if c.Portal.Enabled && c.Portal.LogLimit <= 0 {
+		errs = append(errs, errors.New("portal: log_limit must be positive"))
+	}
+	if q := c.AuditQueue; q.Size <= 0 || q.Wait < 0 || q.RetryAfter <= 0 {
+		errs = append(errs, errors.New("audit_queue: size and retry_after must be positive and wait not negative"))
+	}
+	if m := c.AuditQueue.Mode; m != "drop" && m != "strict" {
+		errs = append(errs, fmt.Errorf("audit_queue: mode %q must be drop or strict", m))
+	}
+	for project, budget := range c.Projects.Budgets {
+		if budget <= 0 {
+			errs = append(errs, fmt.Errorf("projects.budgets.%s: budget must be positive", project))
+		}
+	}
+	if r := c.Projects.WarnRatio; r < 0 || r > 1 {
+		errs = append(errs, errors.New("projects: warn_ratio must be between 0 and 1"))
+	}
+	for user, p := range c.ParamPolicies.Users {
+		if len(p.Members) > 0 {
+			errs = append(errs, fmt.Errorf("param_policies.users.%s: members is only for teams", user))
+		}
+		errs = append(errs, p.validate("param_policies.users."+user)...)
+	}
+	paramTeamOf := map[string]string{}
+	for team, p := range c.ParamPolicies.Teams {
+		if len(p.Members) == 0 {
+			errs = append(errs, fmt.Errorf("param_policies.teams.%s: members is required", team))
+		}
+		for _, user := range p.Members {
+			if other, ok := paramTeamOf[user]; ok && other != team {
+				errs = append(errs, fmt.Errorf("param_policies.teams: %s is in more than one team (%s, %s)", user, min(other, team), max(other, team)))
+			}
+			paramTeamOf[user] = team
+		}
+		errs = append(errs, p.validate("param_policies.teams."+team)...)
+	}
+	if i := c.Incidents; i.Enabled && (i.Window <= 0 || i.SpikeCount <= 0 || i.SpikeWindow <= 0) {
+		errs = append(errs, errors.New("incidents: window, spike_count and spike_window must be positive"))
+	}
+	if _, _, err := c.IPAccess.Proxy.Parse(); err != nil {
+		errs = append(errs, fmt.Errorf("ip_access.proxy: %w", err))
+	}
+	if _, _, err := c.IPAccess.Admin.Parse(); err != nil {
+		errs = append(errs, fmt.Errorf("ip_access.admin: %w", err))
+	}
+	if err := c.Webhooks.validate(); err != nil {
+		errs = append(errs, fmt.Errorf("webhooks: %w", err))
+	}
+	if err := c.Reports.Compliance.validate(c.Webhooks.SMTP); err != nil {
+		errs = append(errs, fmt.Errorf("reports.compliance: %w", err))
+	}
+	if s := c.ReadOnly.Status; s < 400 || s > 599 {
+		errs = append(errs, fmt.Errorf("read_only: status %d must be a 4xx or 5xx code", s))
+	}
+	if c.ReadOnly.RetryAfter < 0 {
+		errs = append(errs, errors.New("read_only: retry_after must not be negative"))
+	}
+	if c.Settings.RefreshInterval < 0 {
+		errs = append(errs, errors.New("settings: refresh_interval must not be negative"))
+	}
+	names := map[string]bool{}
+	for i, l := range c.Providers.Local {
+		if !localNameRegex.MatchString(l.Name) || names[l.Name] {
+			errs = append(errs, fmt.Errorf("providers.local[%d]: name %q must be unique lowercase letters, digits, '-' or '_'", i, l.Name))
+		}
+		names[l.Name] = true
+		if l.BaseURL == "" {
+			errs = append(errs, fmt.Errorf("providers.local[%d] (%s): base_url is required", i, l.Name))
+		} else if err := checkURL(l.BaseURL); err != nil {
+			errs = append(errs, fmt.Errorf("providers.local[%d] (%s): base_url: %w", i, l.Name, err))
+		}
+	}
+	if c.OpenAI.Enabled {
+		if err := c.checkOpenAIProvider(c.OpenAI.DefaultProvider); err != nil {
+			errs = append(errs, fmt.Errorf("openai.default_provider: %w", err))
+		}
+		for i, route := range c.OpenAI.Routes {
+			if len(route.Models) == 0 {
+				errs = append(errs, fmt.Errorf("openai.routes[%d]: models is required", i))
+			}
+			if err := c.checkOpenAIProvider(route.Provider); err != nil {
+				errs = append(errs, fmt.Errorf("openai.routes[%d].provider: %w", i, err))
+			}
+		}
+	}
+	if u := c.Providers.Gemini.BaseURL; u != "" {
+		if err := checkURL(u); err != nil {
+			errs = append(errs, fmt.Errorf("providers.gemini: base_url: %w", err))
+		}
+	}
+	modelPrefixes := map[string]string{}
+	for _, p := range []struct {
+		name string
+		CompatProviderConfig
+	}{{"mistral", c.Providers.Mistral}, {"groq", c.Providers.Groq}} {
+		name := p.name
+		if !p.Enabled {
+			continue
+		}
+		if p.BaseURL != "" {
+			if err := checkURL(p.BaseURL); err != nil {
+				errs = append(errs, fmt.Errorf("providers.%s: base_url: %w", name, err))
+			}
+		}
+		for _, prefix := range p.ModelPrefixes {
+			if other, ok := modelPrefixes[prefix]; ok || prefix == "" {
+				errs = append(errs, fmt.Errorf("providers.%s.model_prefixes: %q is empty or also routed to %s", name, prefix, other))
+			}
+			modelPrefixes[prefix] = name
+		}
+	}
+	switch sc := c.Secrets; sc.Source {
+	case "":
+	case "vault", "aws":
+		if sc.CohereAPIKey == "" && sc.GeminiAPIKey == "" && sc.MistralAPIKey == "" && sc.GroqAPIKey == "" {
+			errs = append(errs, fmt.Errorf("secrets: source %s needs a cohere_api_key, gemini_api_key, mistral_api_key or groq_api_key reference", sc.Source))
+		}
+		if sc.Refresh < 0 {
+			errs = append(errs, errors.New("secrets: refresh must not be negative"))
+		}
+		if u := sc.Vault.Address; sc.Source == "vault" && u != "" {
+			if err := checkURL(u); err != nil {
+				errs = append(errs, fmt.Errorf("secrets.vault: address: %w", err))
+			}
+		}
+	default:
+		errs = append(errs, fmt.Errorf("secrets: unknown source %q (want vault or aws)", sc.Source))
+	}
+	transforms := map[string]bool{}
+	for i, t := range c.Transforms {
+		field := fmt.Sprintf("transforms[%d]", i)
+		switch {
+		case t.Name == "":
+			errs = append(errs, fmt.Errorf("%s: name is required", field))
+		case transforms[t.Name]:
+			errs = append(errs, fmt.Errorf("%s: duplicate transform name %q", field, t.Name))
+		}
+		transforms[t.Name] = true
+		if t.SystemPrompt == "" && t.AppendInstructions == "" && len(t.MaxParams) == 0 && len(t.RemoveParams) == 0 {
+			errs = append(errs, fmt.Errorf("%s (%s): set system_prompt, append_instructions, max_params or remove_params", field, t.Name))
+		}
+		for _, path := range t.Paths {
+			if !strings.HasPrefix(path, "/") {
+				errs = append(errs, fmt.Errorf("%s (%s): path %q must start with /", field, t.Name, path))
+			}
+		}
+		for name := range t.MaxParams {
+			if slices.Contains(strings.Split(name, "."), "") {
+				errs = append(errs, fmt.Errorf("%s (%s): invalid parameter name %q", field, t.Name, name))
+			}
+		}
+		for _, name := range t.RemoveParams {
+			if slices.Contains(strings.Split(name, "."), "") {
+				errs = append(errs, fmt.Errorf("%s (%s): invalid parameter name %q", field, t.Name, name))
+			}
+		}
+	}
+	switch c.Metrics.CallerLabel {
+	case "user", "none":
+	case "team":
+		if len(c.Metrics.Teams) == 0 {
+			errs = append(errs, errors.New("metrics: caller_label team needs teams"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("metrics: unknown caller_label %q (want user, team or none)", c.Metrics.CallerLabel))
+	}
+	if c.Metrics.MaxModels < 0 || c.Metrics.MaxCallers < 0 {
+		errs = append(errs, errors.New("metrics: max_models and max_callers must not be negative"))
+	}
+	teamOf := map[string]string{}
+	for team, users := range c.Metrics.Teams {
+		for _, user := range users {
+			if other, ok := teamOf[user]; ok && other != team {
+				errs = append(errs, fmt.Errorf("metrics.teams: %s is in more than one team (%s, %s)", user, min(other, team), max(other, team)))
+			}
+			teamOf[user] = team
+		}
+	}
+	for _, h := range []struct {
+		field   string
+		buckets []float64
+	}{{"latency_buckets", c.Metrics.LatencyBuckets}, {"token_buckets", c.Metrics.TokenBuckets}} {
+		for i, b := range h.buckets {
+			if b <= 0 || (i > 0 && b <= h.buckets[i-1]) {
+				errs = append(errs, fmt.Errorf("metrics.%s: bounds must be positive and increasing", h.field))
+				break
+			}
+		}
+	}
+	if u := c.Providers.Bedrock.BaseURL; u != "" {
+		if err := checkURL(u); err != nil {
+			errs = append(errs, fmt.Errorf("providers.bedrock: base_url: %w", err))
+		}
+	}
+	if p := c.ProviderStatus; p.ShiftWeights && (p.DegradedWeight < 0 || p.DegradedWeight > 1) {
+		errs = append(errs, errors.New("provider_status: degraded_weight must be between 0 and 1"))
+	}
+	if u := c.Upstreams; len(u.Targets) > 0 {
+		if !slices.Contains([]string{"round_robin", "least_latency", "weighted"}, u.Strategy) {
+			errs = append(errs, fmt.Errorf("upstreams: unknown strategy %q (want round_robin, least_latency or weighted)", u.Strategy))
+		}
+		if u.FailureThreshold <= 0 || u.Cooldown <= 0 {
+			errs = append(errs, errors.New("upstreams: failure_threshold and cooldown must be positive"))
+		}
+		seen := map[string]bool{}
+		for i, t := range u.Targets {
+			if t.Name == "" || seen[t.Name] {
+				errs = append(errs, fmt.Errorf("upstreams.targets[%d]: name %q must be set and unique", i, t.Name))
+			}
+			seen[t.Name] = true
+			if t.BaseURL == "" {
+				errs = append(errs, fmt.Errorf("upstreams.targets[%d] (%s): base_url is required", i, t.Name))
+			} else if err := checkURL(t.BaseURL); err != nil {
+				errs = append(errs, fmt.Errorf("upstreams.targets[%d] (%s): base_url: %w", i, t.Name, err))
+			}
+		}
+	}
+	if t := c.Transport; t.MaxIdleConns < 0 || t.MaxIdleConnsPerHost < 0 || t.MaxConnsPerHost < 0 || t.TLSSessionCache < 0 {
+		errs = append(errs, errors.New("transport: connection limits and tls_session_cache must not be negative"))
+	}
+	if t := c.Transport; t.IdleConnTimeout < 0 || t.DialTimeout < 0 || t.KeepAlive < 0 || t.TLSHandshakeTimeout < 0 || t.ResponseHeaderTimeout < 0 {
+		errs = append(errs, errors.New("transport: timeouts must not be negative"))
+	}
+	// Checked even when disabled, since VANTAGE_CHAOS turns it on after loading
+	for _, rate := range []float64{c.Chaos.LatencyRate, c.Chaos.RateLimitRate, c.Chaos.ErrorRate} {
+		if rate < 0 || rate > 1 {
+			errs = append(errs, errors.New("chaos: latency_rate, rate_limit_rate and error_rate must be between 0 and 1"))
+			break
+		}
+	}
+	if c.Chaos.RateLimitRate+c.Chaos.ErrorRate > 1 {
+		errs = append(errs, errors.New("chaos: rate_limit_rate and error_rate must add up to at most 1"))
+	}
+	if c.Chaos.Latency < 0 || c.Chaos.RetryAfter < 0 {
+		errs = append(errs, errors.New("chaos: latency and retry_after must not be negative"))
+	}
+	if s := c.Chaos.ErrorStatus; s < 500 || s > 599 {
+		errs = append(errs, fmt.Errorf("chaos: error_status %d must be a 5xx code", s))
+	}
+	if a := c.Archive; a.Enabled {
+		switch {
+		case a.After <= 0 || a.Interval <= 0 || a.BatchSize <= 0:
+			errs = append(errs, errors.New("archive: after, interval and batch_size must be positive"))
+		case !slices.Contains(codec.Algorithms, a.Compression):
+			errs = append(errs, fmt.Errorf("archive: unknown compression %q (want %s)", a.Compression, strings.Join(codec.Algorithms, ", ")))
+		}
+		errs = append(errs, validateObjectStore("archive", a.ObjectStoreConfig)...)
+	}
+	if e := c.LogExport; e.Enabled {
+		switch {
+		case e.After <= 0 || e.Interval <= 0 || e.BatchSize <= 0:
+			errs = append(errs, errors.New("log_export: after, interval and batch_size must be positive"))
+		case e.Format != "jsonl" && e.Format != "parquet":
+			errs = append(errs, fmt.Errorf("log_export: unsupported format %q (want jsonl or parquet)", e.Format))
+		case !slices.Contains(codec.Algorithms, e.Compression):
+			errs = append(errs, fmt.Errorf("log_export: unknown compression %q (want %s)", e.Compression, strings.Join(codec.Algorithms, ", ")))
+		}
+		errs = append(errs, validateObjectStore("log_export", e.ObjectStoreConfig)...)
+	}
+	if r := c.Redis; r.Enabled {
+		if u, err := url.Parse(r.URL); err != nil || (u.Scheme != "redis" && u.Scheme != "rediss") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("redis: url %q is not a redis:// or rediss:// URL", r.URL))
+		}
+		if r.Timeout <= 0 || r.PoolSize <= 0 {
+			errs = append(errs, errors.New("redis: timeout and pool_size must be positive"))
+		}
+	}
+	if v := c.Identity.VirtualKeys; v.DefaultExpiry < 0 || v.RotationGrace < 0 {
+		errs = append(errs, errors.New("identity.virtual_keys: default_expiry and rotation_grace must not be negative"))
+	}
+	if cc := c.Concurrency; cc.Enabled {
+		if cc.PerUser < 0 || cc.Global < 0 || cc.QueueTimeout < 0 {
+			errs = append(errs, errors.New("concurrency: per_user, global and queue_timeout must not be negative"))
+		}
+		for user, n := range cc.Users {
+			if n < 0 {
+				errs = append(errs, fmt.Errorf("concurrency.users.%s: cap must not be negative", user))
+			}
+		}
+	}
+	if d := c.Dedup; d.Enabled {
+		if d.Window <= 0 {
+			errs = append(errs, errors.New("dedup: window must be positive"))
+		}
+		if d.Mode != "share" && d.Mode != "reject" {
+			errs = append(errs, fmt.Errorf("dedup: mode %q must be share or reject", d.Mode))
+		}
+	}
+	if ch := c.ClickHouse; ch.Enabled {
+		if u, err := url.Parse(ch.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("clickhouse: url %q is not an http:// or https:// URL", ch.URL))
+		}
+		if !identifierRegex.MatchString(ch.Database) || !identifierRegex.MatchString(ch.Table) {
+			errs = append(errs, errors.New("clickhouse: database and table must be plain identifiers"))
+		}
+		if ch.Timeout <= 0 || ch.BatchSize <= 0 || ch.FlushInterval <= 0 {
+			errs = append(errs, errors.New("clickhouse: timeout, batch_size and flush_interval must be positive"))
+		}
+		if ch.Retention < 0 {
+			errs = append(errs, errors.New("clickhouse: retention must not be negative"))
+		}
+	}
+	for model, p := range c.Pricing {
+		if p.Input < 0 || p.Output < 0 {
+			errs = append(errs, fmt.Errorf("pricing.%s: prices must not be negative", model))
+		}
+	}
+	if t := c.Trust; t.Enabled {
+		if t.Window <= 0 || t.Interval <= 0 {
+			errs = append(errs, errors.New("trust: window and interval must be positive"))
+		}
+		if t.LowBelow < 0 || t.HighAbove > 1 || t.LowBelow > t.HighAbove {
+			errs = append(errs, errors.New("trust: want 0 <= low_below <= high_above <= 1"))
+		}
+		for tier, p := range t.Tiers {
+			if !slices.Contains([]string{"low", "standard", "high"}, tier) {
+				errs = append(errs, fmt.Errorf("trust.tiers: unknown tier %q (want low, standard or high)", tier))
+			}
+			if _, ok := c.Plans.Plans[p.Plan]; p.Plan != "" && !ok {
+				errs = append(errs, fmt.Errorf("trust.tiers.%s: unknown plan %q", tier, p.Plan))
+			}
+		}
+	}
+	if err := c.Schedules.validate(); err != nil {
+		errs = append(errs, fmt.Errorf("schedules: %w", err))
+	}
+	if !slices.Contains(codec.Algorithms, c.Storage.BodyCompression) {
+		errs = append(errs, fmt.Errorf("storage: unknown body_compression %q (want %s)", c.Storage.BodyCompression, strings.Join(codec.Algorithms, ", ")))
+	}
+	if e := c.Storage.Encryption; e.Enabled {
+		switch {
+		case !keyIDRegex.MatchString(e.KeyID):
+			errs = append(errs, fmt.Errorf("storage.encryption: key_id %q must be letters, digits, '.', '-' or '_'", e.KeyID))
+		case e.Source != "env" && e.Source != "kms":
+			errs = append(errs, fmt.Errorf("storage.encryption: unknown source %q (want env or kms)", e.Source))
+		case e.KeyEnv == "":
+			errs = append(errs, errors.New("storage.encryption: key_env is required"))
+		}
+	}
+	if c.Storage.SearchIndex && c.Storage.Encryption.Enabled {
+		errs = append(errs, errors.New("storage: search_index cannot be used with encryption; the index would hold the bodies' words in plain text"))
+	}
+	if c.Storage.WriteRetries < 0 {
+		errs = append(errs, errors.New("storage: write_retries must not be negative"))
+	}
+	if c.Storage.WriteRetries > 0 && c.Storage.RetryBackoff <= 0 {
+		errs = append(errs, errors.New("storage: retry_backoff must be positive when write_retries is set"))
+	}
+	return errors.Join(errs...)
+}
+
+// checkURL accepts absolute http and https URLs.
+func checkURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%q is not an http or https URL", raw)
+	}
+	return nil
+}
+
+func (c ScheduleConfig) validate() error {
+	if _, err := time.LoadLocation(c.Timezone); err != nil {
+		return fmt.Errorf("timezone: %w", err)
+	}
+	for user, tz := range c.UserTimezones {
+		if _, err := time.LoadLocation(tz); err != nil {
+			return fmt.Errorf("user_timezones.%s: %w", user, err)
+		}
+	}
+	for name, w := range c.Windows {
+		if _, _, _, err := w.Parse(); err != nil {
+			return fmt.Errorf("windows.%s: %w", name, err)
+		}
+	}
+	for i, r := range c.Rules {
+		if _, ok := c.Windows[r.Window]; !ok {
+			return fmt.Errorf("rules[%d] (%s): unknown window %q", i, r.Name, r.Window)
+		}
+	}
+	return nil
+}
+
+func (c LatencyConfig) validate() error {
+	if c.SlowThreshold < 0 {
+		return errors.New("slow_threshold must not be negative")
+	}
+	if len(c.SLOs) > 0 && c.Window < time.Minute {
+		return errors.New("window must be at least 1m")
+	}
+	names := map[string]bool{}
+	for i, slo := range c.SLOs {
+		if slo.Name == "" || names[slo.Name] {
+			return fmt.Errorf("slos[%d]: name %q must be set and unique", i, slo.Name)
+		}
+		names[slo.Name] = true
+		if slo.Percentile <= 0 || slo.Percentile >= 1 {
+			return fmt.Errorf("slos[%d] (%s): percentile must be between 0 and 1", i, slo.Name)
+		}
+		if slo.Threshold <= 0 {
+			return fmt.Errorf("slos[%d] (%s): threshold must be positive", i, slo.Name)
+		}
+	}
+	return nil
+}
+
+func (c WebhooksConfig) validate() error {
+	endpoints := map[string]WebhookEndpoint{}
+	for _, ep := range c.Endpoints {
+		endpoints[ep.Name] = ep
+		if ep.Type == "email" && c.SMTP.Addr == "" {
+			return fmt.Errorf("endpoints (%s): email endpoints need smtp.addr", ep.Name)
+		}
+	}
+	for i, r := range c.Routes {
+		if r.Name == "" || len(r.Endpoints) == 0 {
+			return fmt.Errorf("routes[%d]: name and endpoints are required", i)
+		}
+		for _, name := range r.Endpoints {
+			if _, ok := endpoints[name]; !ok || name == "" {
+				return fmt.Errorf("routes[%d] (%s): unknown endpoint %q", i, r.Name, name)
+			}
+		}
+		if r.MinSeverity != "" && !slices.Contains(Severities, r.MinSeverity) {
+			return fmt.Errorf("routes[%d] (%s): unknown min_severity %q (want %s)", i, r.Name, r.MinSeverity, strings.Join(Severities, ", "))
+		}
+		if r.QuietHours != nil {
+			if _, _, _, err := r.QuietHours.Parse(); err != nil {
+				return fmt.Errorf("routes[%d] (%s): quiet_hours: %w", i, r.Name, err)
+			}
+		}
+		if _, err := time.LoadLocation(r.Timezone); err != nil {
+			return fmt.Errorf("routes[%d] (%s): %w", i, r.Name, err)
+		}
+	}
+	return nil
+}
+
+func (c ComplianceReportConfig) validate(smtp SMTPConfig) error {
+	if c.Schedule != "weekly" && c.Schedule != "monthly" {
+		return fmt.Errorf("unknown schedule %q (want weekly or monthly)", c.Schedule)
+	}
+	if _, err := time.LoadLocation(c.Timezone); err != nil {
+		return fmt.Errorf("timezone: %w", err)
+	}
+	if c.TopN <= 0 {
+		return errors.New("top_n must be positive")
+	}
+	for i, r := range c.Recipients {
+		if _, err := mail.ParseAddress(r); err != nil {
+			return fmt.Errorf("recipients[%d]: %w", i, err)
+		}
+	}
+	if c.Enabled && (len(c.Recipients) == 0 || smtp.Addr == "") {
+		return errors.New("recipients and webhooks.smtp.addr are required when enabled")
+	}
+	return nil
+}
+
+var redactionNameRegex = regexp.MustCompile(`^[a-z][a-z_]*$`)
+
+var keyIDRegex = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+var localNameRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// identifierRegex matches the ClickHouse names that need no quoting.
+var identifierRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// checkOpenAIProvider reports whether the OpenAI-compatible frontend can send
+// requests to the named provider.
+func (c *Config) checkOpenAIProvider(name string) error {
+	switch name {
+	case "cohere":
+		return nil
+	case "gemini":
+		if c.Providers.Gemini.Enabled {
+			return nil
+		}
+	case "mistral":
+		if c.Providers.Mistral.Enabled {
+			return nil
+		}
+	case "groq":
+		if c.Providers.Groq.Enabled {
+			return nil
+		}
+	case "bedrock":
+		return errors.New("bedrock has no OpenAI-compatible API")
+	default:
+		for _, l := range c.Providers.Local {
+			if l.Name == name {
+				return nil
+			}
+		}
+		return fmt.Errorf("unknown provider %q", name)
+	}
+	return fmt.Errorf("provider %s is not enabled", name)
+}
+
+// validate checks a parameter policy, named by its config path.
+func (p ParamPolicyConfig) validate(path string) []error {
+	var errs []error
+	if p.Action != "" && p.Action != "rewrite" && p.Action != "reject" {
+		errs = append(errs, fmt.Errorf("%s: action %q must be rewrite or reject", path, p.Action))
+	}
+	if p.MaxTokens < 0 || p.MaxTokensLimit < 0 {
+		errs = append(errs, fmt.Errorf("%s: max_tokens and max_tokens_limit must not be negative", path))
+	}
+	if p.MaxTokensLimit > 0 && p.MaxTokens > p.MaxTokensLimit {
+		errs = append(errs, fmt.Errorf("%s: max_tokens %d exceeds max_tokens_limit %d", path, p.MaxTokens, p.MaxTokensLimit))
+	}
+	switch r := p.TemperatureRange; {
+	case len(r) == 0:
+	case len(r) != 2 || r[0] < 0 || r[0] > r[1]:
+		errs = append(errs, fmt.Errorf("%s: temperature_range must be [min, max] with 0 <= min <= max", path))
+	case p.Temperature != nil && (*p.Temperature < r[0] || *p.Temperature > r[1]):
+		errs = append(errs, fmt.Errorf("%s: temperature %g is outside temperature_range", path, *p.Temperature))
+	}
+	if p.Temperature != nil && *p.Temperature < 0 {
+		errs = append(errs, fmt.Errorf("%s: temperature must not be negative", path))
+	}
+	return errs
 }
@@ -0,0 +1,99 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix starts the environment variables that override config fields.
+// A field's variable is its YAML path joined by underscores, so
+// VANTAGE_RATE_LIMIT_REQUESTS sets rate_limit.requests, and a section with
+// an enabled field can be switched with its own name, as in
+// VANTAGE_REDACTION=false. Lists take comma-separated values or YAML flow
+// syntax, which also sets maps and lists of sections.
+const EnvPrefix = "VANTAGE_"
+
+// EnvironmentVar names the environment, such as "prod", whose overlay file
+// is read on top of the config file.
+const EnvironmentVar = "VANTAGE_ENV"
+
+// OverlayPath is the overlay of the config file at path for env:
+// config.prod.yaml for config.yaml.
+func OverlayPath(path, env string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "." + env + ext
+}
+
+// applyEnv sets the fields of c that have an environment variable, as
+// lookup reports them.
+func (c *Config) applyEnv(lookup func(string) (string, bool)) []error {
+	return applyEnv(reflect.ValueOf(c).Elem(), EnvPrefix, lookup)
+}
+
+func applyEnv(v reflect.Value, prefix string, lookup func(string) (string, bool)) []error {
+	var errs []error
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, opts, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		if !f.IsExported() || name == "-" {
+			continue
+		}
+		field := v.Field(i)
+		if opts == "inline" {
+			errs = append(errs, applyEnv(field, prefix, lookup)...)
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		key := prefix + strings.ToUpper(name)
+
+		if field.Kind() == reflect.Struct {
+			if value, ok := lookup(key); ok {
+				if enabled := field.FieldByName("Enabled"); enabled.IsValid() {
+					errs = append(errs, setEnvField(enabled, key, value)...)
+				} else {
+					errs = append(errs, fmt.Errorf("%s: %s is a section; set its fields with %s_*", key, name, key))
+				}
+			}
+			// More specific variables win over the switch
+			errs = append(errs, applyEnv(field, key+"_", lookup)...)
+			continue
+		}
+		if value, ok := lookup(key); ok {
+			errs = append(errs, setEnvField(field, key, value)...)
+		}
+	}
+	return errs
+}
+
+// setEnvField parses value into field: strings as they are, string lists
+// as comma-separated items unless written in YAML flow syntax, and
+// everything else as YAML.
+func setEnvField(field reflect.Value, key, value string) []error {
+	switch {
+	case field.Kind() == reflect.String:
+		field.SetString(value)
+		return nil
+	case field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.String && !strings.HasPrefix(strings.TrimSpace(value), "["):
+		items := reflect.MakeSlice(field.Type(), 0, 0)
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = reflect.Append(items, reflect.ValueOf(item).Convert(field.Type().Elem()))
+			}
+		}
+		field.Set(items)
+		return nil
+	}
+	parsed := reflect.New(field.Type())
+	if err := yaml.Unmarshal([]byte(value), parsed.Interface()); err != nil {
+		return []error{fmt.Errorf("%s: cannot use %q as %s", key, value, field.Type())}
+	}
+	field.Set(parsed.Elem())
+	return nil
+}
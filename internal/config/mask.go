@@ -0,0 +1,66 @@
+package config
+
+import (
+	"net/url"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// masked replaces the value of a secret field.
+const masked = "********"
+
+// Masked returns a copy of c for display, with the fields tagged
+// secret:"true" replaced by asterisks and the passwords of URLs removed.
+func (c *Config) Masked() (*Config, error) {
+	raw, err := yaml.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	cp := &Config{}
+	if err := yaml.Unmarshal(raw, cp); err != nil {
+		return nil, err
+	}
+	mask(reflect.ValueOf(cp).Elem(), false)
+	return cp, nil
+}
+
+// mask masks v in place; secret is whether it belongs to a secret field.
+// Map values are not addressable, so they are copied out and set back.
+func mask(v reflect.Value, secret bool) {
+	switch v.Kind() {
+	case reflect.String:
+		switch {
+		case secret && v.Len() > 0:
+			v.SetString(masked)
+		case strings.Contains(v.String(), "://"):
+			if u, err := url.Parse(v.String()); err == nil && u.User != nil {
+				v.SetString(u.Redacted())
+			}
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			if t.Field(i).IsExported() {
+				mask(v.Field(i), t.Field(i).Tag.Get("secret") == "true")
+			}
+		}
+	case reflect.Pointer:
+		if !v.IsNil() {
+			mask(v.Elem(), secret)
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			mask(v.Index(i), secret)
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			value := reflect.New(v.Type().Elem()).Elem()
+			value.Set(iter.Value())
+			mask(value, secret)
+			v.SetMapIndex(iter.Key(), value)
+		}
+	}
+}
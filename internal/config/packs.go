@@ -0,0 +1,108 @@
+package config
+
+import (
+	"slices"
+	"sort"
+)
+
+// PolicyPack is a preset bundle of governance rules that policy_packs
+// enables by name, so a deployment gets sensible rules without writing
+// them. Thresholds are minimums: a pack raises a lower configured
+// threshold and leaves a stricter one alone.
+type PolicyPack struct {
+	Description       string
+	ForbiddenKeywords []string
+	Redactions        []RedactionPattern
+	// Builtins names the built-in redaction patterns the pack relies on
+	Builtins []string
+	// SafetyThreshold is the prompt safety score below which events are raised
+	SafetyThreshold float64
+	// ResponseSafety turns on classification of generated text, flagged
+	// below this threshold
+	ResponseSafety float64
+}
+
+// PolicyPacks are the packs policy_packs can name.
+var PolicyPacks = map[string]PolicyPack{
+	"pii-strict": {
+		Description: "Masks US SSNs, payment cards, bank accounts and IP addresses on top of the email, phone and UUID patterns, and blocks pasted private keys.",
+		ForbiddenKeywords: []string{
+			"PRIVATE KEY-----",
+		},
+		Builtins: []string{"ssn", "credit_card", "iban"},
+		Redactions: []RedactionPattern{
+			{Name: "ip_address", Regex: `\b(?:(?:25[0-5]|2[0-4]\d|1?\d?\d)\.){3}(?:25[0-5]|2[0-4]\d|1?\d?\d)\b`},
+		},
+	},
+	"financial-compliance": {
+		Description: "Masks payment cards and bank accounts, blocks requests for market abuse and money laundering, and flags prompts scoring below 0.6.",
+		ForbiddenKeywords: []string{
+			"insider information",
+			"material non-public information",
+			"pump and dump",
+			"launder money",
+			"structure deposits to avoid",
+			"guaranteed returns",
+		},
+		Builtins:        []string{"credit_card", "iban"},
+		SafetyThreshold: 0.6,
+	},
+	"child-safety": {
+		Description: "Blocks sexual content involving minors, flags prompts scoring below 0.8 and classifies generated text, flagging it below 0.8.",
+		ForbiddenKeywords: []string{
+			"child sexual",
+			"sexual content involving minors",
+			"sexualize minors",
+			"underage nude",
+			"nude minor",
+			"groom a child",
+		},
+		SafetyThreshold: 0.8,
+		ResponseSafety:  0.8,
+	},
+}
+
+// policyPackNames returns the names of PolicyPacks, sorted.
+func policyPackNames() []string {
+	names := make([]string, 0, len(PolicyPacks))
+	for name := range PolicyPacks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// applyPolicyPacks merges the packs named in policy_packs into the global
+// rules. Keywords are added to forbidden_keywords and patterns to
+// redaction, which is turned on, unless a pattern of the same name is
+// already configured, so a pack pattern can be replaced or disabled by
+// declaring it. The built-in patterns a pack relies on are on unless
+// turned off under redaction.builtins. Governance profiles with their own rules are not changed.
+// Unknown names are skipped here and reported by Validate.
+func (c *Config) applyPolicyPacks() {
+	for _, name := range c.PolicyPacks {
+		pack, ok := PolicyPacks[name]
+		if !ok {
+			continue
+		}
+		for _, kw := range pack.ForbiddenKeywords {
+			if !slices.Contains(c.ForbiddenKeywords, kw) {
+				c.ForbiddenKeywords = append(c.ForbiddenKeywords, kw)
+			}
+		}
+		if len(pack.Builtins) > 0 {
+			c.Redaction.Enabled = true
+		}
+		for _, p := range pack.Redactions {
+			c.Redaction.Enabled = true
+			if !slices.ContainsFunc(c.Redaction.Patterns, func(q RedactionPattern) bool { return q.Name == p.Name }) {
+				c.Redaction.Patterns = append(c.Redaction.Patterns, p)
+			}
+		}
+		c.Webhooks.SafetyThreshold = max(c.Webhooks.SafetyThreshold, pack.SafetyThreshold)
+		if pack.ResponseSafety > 0 {
+			c.ResponseSafety.Enabled = true
+			c.ResponseSafety.Threshold = max(c.ResponseSafety.Threshold, pack.ResponseSafety)
+		}
+	}
+}
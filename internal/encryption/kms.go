@@ -0,0 +1,83 @@
+package encryption
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/soroushbar/vantage/internal/config"
+)
+
+// LoadKey returns the master key configured in cfg. With source "env" the
+// environment variable holds the base64 key; with "kms" it holds a data key
+// encrypted by AWS KMS (the CiphertextBlob of GenerateDataKey), which is
+// decrypted once at startup.
+func LoadKey(ctx context.Context, cfg config.EncryptionConfig) ([]byte, error) {
+	value := os.Getenv(cfg.KeyEnv)
+	if value == "" {
+		return nil, fmt.Errorf("%s is not set", cfg.KeyEnv)
+	}
+	raw, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("%s is not valid base64: %w", cfg.KeyEnv, err)
+	}
+	if cfg.Source == "kms" {
+		return kmsDecrypt(ctx, cfg.KMSRegion, raw)
+	}
+	return raw, nil
+}
+
+// kmsDecrypt unwraps an encrypted data key with the KMS Decrypt API, signed
+// with credentials from the default AWS chain.
+func kmsDecrypt(ctx context.Context, region string, blob []byte) ([]byte, error) {
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx, awsconfig.WithRegion(region))
+	if err != nil {
+		return nil, fmt.Errorf("load AWS config: %w", err)
+	}
+	if awsCfg.Region == "" {
+		return nil, errors.New("no AWS region configured")
+	}
+	creds, err := awsCfg.Credentials.Retrieve(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("AWS credentials unavailable: %w", err)
+	}
+
+	body, _ := json.Marshal(map[string][]byte{"CiphertextBlob": blob})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://kms."+awsCfg.Region+".amazonaws.com/", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "TrentService.Decrypt")
+	hash := sha256.Sum256(body)
+	if err := v4.NewSigner().SignHTTP(ctx, creds, req, hex.EncodeToString(hash[:]), "kms", awsCfg.Region, time.Now()); err != nil {
+		return nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var result struct {
+		Plaintext []byte `json:"Plaintext"`
+		Message   string `json:"message"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("KMS decrypt: %s", resp.Status)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("KMS decrypt: %s: %s", resp.Status, result.Message)
+	}
+	return result.Plaintext, nil
+}
@@ -0,0 +1,269 @@
+// Package incident correlates notification events into incidents with a
+// timeline, acknowledgement and resolution, so on-call handling is recorded
+// next to the data that triggered it.
+package incident
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/soroushbar/vantage/internal/config"
+	"github.com/soroushbar/vantage/internal/notify"
+	"github.com/soroushbar/vantage/internal/store"
+)
+
+// systemAuthor is recorded for actions Vantage takes on its own.
+const systemAuthor = "vantage"
+
+// ErrResolved is returned for changes to an incident that is already resolved.
+var ErrResolved = errors.New("incident is already resolved")
+
+// ErrAcknowledged is returned when acknowledging an incident twice.
+var ErrAcknowledged = errors.New("incident is already acknowledged")
+
+// Store interface for decoupling
+type Store interface {
+	SaveIncident(inc *store.Incident) error
+	AddIncidentEntry(incidentID int64, e store.IncidentEntry) error
+	ListIncidents(status string, limit int) ([]store.Incident, error)
+	GetIncident(id int64) (*store.Incident, error)
+}
+
+// Tracker turns published events into incidents. It implements
+// notify.Observer.
+type Tracker struct {
+	store Store
+	cfg   config.IncidentsConfig
+
+	mu      sync.Mutex
+	open    map[string]*store.Incident // kind key -> latest unresolved incident
+	pending map[string][]notify.Event  // spike-gated events not yet in an incident
+}
+
+// NewTracker returns a tracker that continues the unresolved incidents in
+// the store.
+func NewTracker(st Store, cfg config.IncidentsConfig) (*Tracker, error) {
+	t := &Tracker{store: st, cfg: cfg, open: map[string]*store.Incident{}, pending: map[string][]notify.Event{}}
+	unresolved, err := st.ListIncidents("unresolved", 1000)
+	if err != nil {
+		return nil, err
+	}
+	// Newest first, so the latest incident of each kind wins
+	for i := range unresolved {
+		inc := unresolved[i]
+		if _, ok := t.open[inc.Key]; !ok {
+			t.open[inc.Key] = &inc
+		}
+	}
+	return t, nil
+}
+
+// kind returns the correlation key and title for events that take part in
+// incidents; spike reports whether the kind needs a burst to open one.
+func kind(e notify.Event) (key, title string, spike, ok bool) {
+	switch e.Type {
+	case notify.EventRequestBlocked:
+		return "blocks", "Spike in blocked requests", true, true
+	case notify.EventLowSafety:
+		return "safety", "Spike in low safety scores", true, true
+	case notify.EventUnsafeResponse:
+		return "unsafe_responses", "Spike in unsafe responses", true, true
+	case notify.EventResponsePII:
+		return "response_pii", "Spike in PII found in responses", true, true
+	case notify.EventModerationFlagged:
+		return "moderation", "Spike in prompts flagged by moderation", true, true
+	case notify.EventBudgetExceeded:
+		return "budget", "Budget breaches", false, true
+	case notify.EventAdminLockout:
+		return "admin_lockout", "Admin lockouts", false, true
+	case notify.EventProviderOutage, notify.EventProviderRecovered:
+		provider, _ := e.Details["provider"].(string)
+		return "provider:" + provider, "Provider outage: " + provider, false, true
+	}
+	return "", "", false, false
+}
+
+// Observe adds e to the incident it correlates with, opening one when
+// needed. A provider recovery resolves that provider's outage incident.
+func (t *Tracker) Observe(e notify.Event) {
+	key, title, spike, ok := kind(e)
+	if !ok {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	inc := t.open[key]
+	if e.Type == notify.EventProviderRecovered {
+		if inc != nil {
+			t.record(inc, e)
+			if err := t.resolve(inc, systemAuthor, "Provider recovered", e.Timestamp); err != nil {
+				log.Printf("Failed to resolve incident %d: %v", inc.ID, err)
+			}
+		}
+		return
+	}
+	if inc != nil && e.Timestamp.Sub(inc.UpdatedAt) > t.cfg.Window {
+		// Stale incidents stay listed for the operators, but new events start over
+		inc = nil
+	}
+
+	if inc == nil {
+		events := []notify.Event{e}
+		if spike {
+			events = t.burst(key, e)
+			if events == nil {
+				return
+			}
+		}
+		inc = &store.Incident{
+			Key:      key,
+			Title:    title,
+			Severity: e.Severity,
+			Status:   store.IncidentOpen,
+			OpenedAt: events[0].Timestamp,
+		}
+		inc.UpdatedAt = inc.OpenedAt
+		if err := t.store.SaveIncident(inc); err != nil {
+			log.Printf("Failed to open incident %q: %v", key, err)
+			return
+		}
+		t.open[key] = inc
+		for _, ev := range events {
+			t.record(inc, ev)
+		}
+		return
+	}
+	t.record(inc, e)
+}
+
+// burst buffers a spike-gated event and returns the buffered events once
+// enough arrived within the spike window, or nil until then.
+func (t *Tracker) burst(key string, e notify.Event) []notify.Event {
+	recent := t.pending[key][:0]
+	for _, ev := range t.pending[key] {
+		if e.Timestamp.Sub(ev.Timestamp) < t.cfg.SpikeWindow {
+			recent = append(recent, ev)
+		}
+	}
+	recent = append(recent, e)
+	if len(recent) < t.cfg.SpikeCount {
+		t.pending[key] = recent
+		return nil
+	}
+	delete(t.pending, key)
+	return recent
+}
+
+// record appends an event to the timeline of inc. Callers hold t.mu.
+func (t *Tracker) record(inc *store.Incident, e notify.Event) {
+	err := t.store.AddIncidentEntry(inc.ID, store.IncidentEntry{
+		At:        e.Timestamp,
+		Kind:      "event",
+		EventID:   e.ID,
+		EventType: e.Type,
+		Severity:  e.Severity,
+		UserID:    e.UserID,
+		Path:      e.Path,
+		LogID:     e.LogID,
+	})
+	if err != nil {
+		log.Printf("Failed to record event %s in incident %d: %v", e.ID, inc.ID, err)
+		return
+	}
+	inc.EventCount++
+	inc.Severity = notify.MaxSeverity(inc.Severity, e.Severity)
+	if e.Timestamp.After(inc.UpdatedAt) {
+		inc.UpdatedAt = e.Timestamp
+	}
+	if err := t.store.SaveIncident(inc); err != nil {
+		log.Printf("Failed to update incident %d: %v", inc.ID, err)
+	}
+}
+
+// List returns the most recently updated incidents, optionally filtered by
+// status.
+func (t *Tracker) List(status string) ([]store.Incident, error) {
+	return t.store.ListIncidents(status, 100)
+}
+
+// Get returns an incident with its timeline.
+func (t *Tracker) Get(id int64) (*store.Incident, error) {
+	return t.store.GetIncident(id)
+}
+
+// Acknowledge marks an open incident as being handled by author.
+func (t *Tracker) Acknowledge(id int64, author, note string) (*store.Incident, error) {
+	return t.update(id, func(inc *store.Incident, now time.Time) error {
+		switch inc.Status {
+		case store.IncidentResolved:
+			return ErrResolved
+		case store.IncidentAcknowledged:
+			return ErrAcknowledged
+		}
+		inc.Status = store.IncidentAcknowledged
+		inc.AcknowledgedBy = author
+		inc.AcknowledgedAt = &now
+		inc.UpdatedAt = now
+		if err := t.store.SaveIncident(inc); err != nil {
+			return err
+		}
+		return t.store.AddIncidentEntry(inc.ID, store.IncidentEntry{At: now, Kind: "acknowledged", Author: author, Note: note})
+	})
+}
+
+// Resolve closes an incident with a resolution note. Later events of its
+// kind open a new incident.
+func (t *Tracker) Resolve(id int64, author, note string) (*store.Incident, error) {
+	return t.update(id, func(inc *store.Incident, now time.Time) error {
+		if inc.Status == store.IncidentResolved {
+			return ErrResolved
+		}
+		return t.resolve(inc, author, note, now)
+	})
+}
+
+// AddNote appends an operator note to an incident's timeline.
+func (t *Tracker) AddNote(id int64, author, note string) (*store.Incident, error) {
+	return t.update(id, func(inc *store.Incident, now time.Time) error {
+		return t.store.AddIncidentEntry(inc.ID, store.IncidentEntry{At: now, Kind: "note", Author: author, Note: note})
+	})
+}
+
+// resolve marks inc resolved and forgets it as the open incident of its
+// kind. Callers hold t.mu.
+func (t *Tracker) resolve(inc *store.Incident, author, note string, now time.Time) error {
+	inc.Status = store.IncidentResolved
+	inc.ResolvedBy = author
+	inc.ResolvedAt = &now
+	inc.Resolution = note
+	inc.UpdatedAt = now
+	if open, ok := t.open[inc.Key]; ok && open.ID == inc.ID {
+		delete(t.open, inc.Key)
+	}
+	if err := t.store.SaveIncident(inc); err != nil {
+		return fmt.Errorf("resolve incident %d: %w", inc.ID, err)
+	}
+	return t.store.AddIncidentEntry(inc.ID, store.IncidentEntry{At: now, Kind: "resolved", Author: author, Note: note})
+}
+
+// update applies change to the stored incident, keeping the in-memory copy
+// of an open incident in step, and returns the incident with its timeline.
+func (t *Tracker) update(id int64, change func(inc *store.Incident, now time.Time) error) (*store.Incident, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	inc, err := t.store.GetIncident(id)
+	if err != nil {
+		return nil, err
+	}
+	if open, ok := t.open[inc.Key]; ok && open.ID == id {
+		inc = open
+	}
+	if err := change(inc, time.Now().UTC()); err != nil {
+		return nil, err
+	}
+	return t.store.GetIncident(id)
+}
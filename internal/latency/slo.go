@@ -0,0 +1,157 @@
+// Package latency tracks latency objectives per route and how fast each
+// spends its error budget.
+package latency
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/soroushbar/vantage/internal/config"
+	"github.com/soroushbar/vantage/internal/telemetry"
+)
+
+// Route names the route of a path for latency breakdowns: its first two
+// segments, such as "/v1/chat" or "/gemini/v1beta".
+func Route(path string) string {
+	parts := strings.SplitN(strings.TrimPrefix(path, "/"), "/", 3)
+	if len(parts) > 2 {
+		parts = parts[:2]
+	}
+	return "/" + strings.Join(parts, "/")
+}
+
+// minute counts the requests of one SLO in one minute.
+type minute struct {
+	at          int64 // Unix minute
+	total, slow int
+}
+
+type objective struct {
+	config.LatencySLO
+	minutes []minute // ring indexed by Unix minute
+}
+
+// SLOTracker measures each configured SLO over a rolling window and keeps
+// the vantage_latency_slo_* metrics current.
+type SLOTracker struct {
+	mu         sync.Mutex
+	objectives []*objective
+}
+
+// NewSLOTracker returns a tracker for the SLOs in cfg, which was validated
+// at load.
+func NewSLOTracker(cfg config.LatencyConfig) *SLOTracker {
+	t := &SLOTracker{}
+	size := int(cfg.Window / time.Minute)
+	for _, slo := range cfg.SLOs {
+		t.objectives = append(t.objectives, &objective{LatencySLO: slo, minutes: make([]minute, size)})
+		telemetry.LatencySLOBurnRate.WithLabelValues(slo.Name).Set(0)
+	}
+	return t
+}
+
+// Start refreshes the burn rates every minute, so they fall back as slow
+// requests leave the window even without new traffic.
+func (t *SLOTracker) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				t.mu.Lock()
+				for _, o := range t.objectives {
+					telemetry.LatencySLOBurnRate.WithLabelValues(o.Name).Set(o.burnRate(now))
+				}
+				t.mu.Unlock()
+			}
+		}
+	}()
+}
+
+// Observe counts a request to path that took latency against every SLO
+// covering the path.
+func (t *SLOTracker) Observe(path string, latency time.Duration, at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, o := range t.objectives {
+		if !strings.HasPrefix(path, o.Route) {
+			continue
+		}
+		slow := latency > o.Threshold
+		result := "met"
+		if slow {
+			result = "missed"
+		}
+		telemetry.LatencySLORequestsTotal.WithLabelValues(o.Name, result).Inc()
+
+		unix := at.Unix() / 60
+		m := &o.minutes[unix%int64(len(o.minutes))]
+		if m.at != unix {
+			*m = minute{at: unix}
+		}
+		m.total++
+		if slow {
+			m.slow++
+		}
+		telemetry.LatencySLOBurnRate.WithLabelValues(o.Name).Set(o.burnRate(at))
+	}
+}
+
+// SLOStatus is an SLO with its requests over the current window.
+type SLOStatus struct {
+	Name        string  `json:"name"`
+	Route       string  `json:"route"`
+	Percentile  float64 `json:"percentile"`
+	ThresholdMs int64   `json:"threshold_ms"`
+	Requests    int     `json:"requests"`
+	Slow        int     `json:"slow"`
+	BurnRate    float64 `json:"burn_rate"`
+}
+
+// Status reports every SLO over the window ending now.
+func (t *SLOTracker) Status() []SLOStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	statuses := make([]SLOStatus, 0, len(t.objectives))
+	for _, o := range t.objectives {
+		total, slow := o.counts(now)
+		statuses = append(statuses, SLOStatus{
+			Name:        o.Name,
+			Route:       o.Route,
+			Percentile:  o.Percentile,
+			ThresholdMs: o.Threshold.Milliseconds(),
+			Requests:    total,
+			Slow:        slow,
+			BurnRate:    o.burnRate(now),
+		})
+	}
+	return statuses
+}
+
+// burnRate is the share of slow requests in the window ending at now
+// divided by the share the SLO allows.
+func (o *objective) burnRate(now time.Time) float64 {
+	total, slow := o.counts(now)
+	if total == 0 {
+		return 0
+	}
+	return float64(slow) / float64(total) / (1 - o.Percentile)
+}
+
+// counts sums the requests of the minutes in the window ending at now.
+func (o *objective) counts(now time.Time) (total, slow int) {
+	current := now.Unix() / 60
+	for _, m := range o.minutes {
+		if m.at > current-int64(len(o.minutes)) && m.at <= current {
+			total += m.total
+			slow += m.slow
+		}
+	}
+	return total, slow
+}
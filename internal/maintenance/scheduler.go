@@ -0,0 +1,115 @@
+// Package maintenance keeps the database compact by running VACUUM,
+// ANALYZE and WAL checkpoints during configured quiet hours.
+package maintenance
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/soroushbar/vantage/internal/config"
+	"github.com/soroushbar/vantage/internal/store"
+	"github.com/soroushbar/vantage/internal/telemetry"
+)
+
+// Store interface for decoupling
+type Store interface {
+	Size() (store.DatabaseSize, error)
+	CheckpointWAL() error
+	Vacuum() error
+	Analyze() error
+}
+
+// Scheduler runs the maintenance tasks once per day inside the quiet window.
+type Scheduler struct {
+	store      Store
+	start, end time.Duration
+	vacuum     bool
+	interval   time.Duration
+	lastRun    string
+}
+
+// NewScheduler returns a scheduler for cfg, which must have passed
+// config validation.
+func NewScheduler(st Store, cfg config.MaintenanceConfig) *Scheduler {
+	start, end, _ := cfg.Window()
+	return &Scheduler{store: st, start: start, end: end, vacuum: cfg.Vacuum, interval: 5 * time.Minute}
+}
+
+// Start checks every few minutes whether the quiet window has opened and
+// runs the tasks the first time it has on a given day.
+func (s *Scheduler) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for {
+			if day, ok := s.window(time.Now()); ok && day != s.lastRun {
+				s.lastRun = day
+				s.Run()
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// window reports whether now falls inside the quiet window and, if so, the
+// date the window opened on. Windows that end before they start wrap past
+// midnight and belong to the previous day after it.
+func (s *Scheduler) window(now time.Time) (string, bool) {
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	offset := now.Sub(midnight)
+	if s.start <= s.end {
+		return midnight.Format("2006-01-02"), offset >= s.start && offset < s.end
+	}
+	if offset >= s.start {
+		return midnight.Format("2006-01-02"), true
+	}
+	return midnight.AddDate(0, 0, -1).Format("2006-01-02"), offset < s.end
+}
+
+// Run performs one maintenance pass and records the space it reclaimed.
+// A failing task is logged and the remaining tasks still run.
+func (s *Scheduler) Run() {
+	started := time.Now()
+	before, err := s.store.Size()
+	if err != nil {
+		log.Printf("Maintenance: reading database size failed: %v", err)
+	}
+
+	// 1. Fold the WAL into the database so VACUUM sees every page
+	s.task("wal_checkpoint", s.store.CheckpointWAL)
+
+	// 2. Return free pages left by deletes to the filesystem
+	if s.vacuum {
+		s.task("vacuum", s.store.Vacuum)
+	}
+
+	// 3. Refresh planner statistics for the compacted tables
+	s.task("analyze", s.store.Analyze)
+
+	after, err := s.store.Size()
+	if err != nil {
+		log.Printf("Maintenance: reading database size failed: %v", err)
+		return
+	}
+	telemetry.DatabaseSizeBytes.Set(float64(after.Bytes))
+	reclaimed := before.Bytes - after.Bytes
+	if reclaimed < 0 {
+		reclaimed = 0
+	}
+	telemetry.MaintenanceReclaimedBytesTotal.Add(float64(reclaimed))
+	log.Printf("Maintenance finished in %s: database %d bytes, reclaimed %d bytes", time.Since(started).Round(time.Millisecond), after.Bytes, reclaimed)
+}
+
+func (s *Scheduler) task(name string, fn func() error) {
+	status := "ok"
+	if err := fn(); err != nil {
+		status = "error"
+		log.Printf("Maintenance: %s failed: %v", name, err)
+	}
+	telemetry.MaintenanceRunsTotal.WithLabelValues(name, status).Inc()
+}
@@ -0,0 +1,148 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/soroushbar/vantage/internal/store"
+)
+
+// Lifecycle states of a registered model.
+const (
+	StateProposed   = "proposed"
+	StateApproved   = "approved"
+	StateDeprecated = "deprecated"
+	StateBanned     = "banned"
+
+	// StateSunset is reported for deprecated models past their sunset date.
+	StateSunset = "sunset"
+)
+
+var validStates = map[string]bool{
+	StateProposed:   true,
+	StateApproved:   true,
+	StateDeprecated: true,
+	StateBanned:     true,
+}
+
+// Store interface for decoupling
+type Store interface {
+	UpsertModel(m store.Model) error
+	ListModels() ([]store.Model, error)
+}
+
+// Registry is an in-memory view of the model registry, written through to the store.
+type Registry struct {
+	store   Store
+	enforce bool
+
+	mu     sync.RWMutex
+	models map[string]store.Model
+}
+
+// NewRegistry loads the registry. When enforce is set, models that are not
+// in the registry are rejected just like proposed ones.
+func NewRegistry(st Store, enforce bool) (*Registry, error) {
+	r := &Registry{store: st, enforce: enforce, models: make(map[string]store.Model)}
+	list, err := st.ListModels()
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range list {
+		r.models[m.Name] = m
+	}
+	return r, nil
+}
+
+// Seed registers the given models as approved unless they are already known.
+func (r *Registry) Seed(names []string) error {
+	for _, name := range names {
+		if _, ok := r.Get(name); ok {
+			continue
+		}
+		if _, err := r.Set(name, StateApproved, "seeded from config", nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Set moves a model to a new lifecycle state. sunsetAt is the cutoff after
+// which a deprecated model is blocked; nil keeps it callable indefinitely.
+func (r *Registry) Set(name, state, notes string, sunsetAt *time.Time) (store.Model, error) {
+	if !validStates[state] {
+		return store.Model{}, fmt.Errorf("invalid model state %q", state)
+	}
+	m := store.Model{Name: name, State: state, Notes: notes, SunsetAt: sunsetAt, UpdatedAt: time.Now().UTC()}
+	if err := r.store.UpsertModel(m); err != nil {
+		return store.Model{}, err
+	}
+
+	r.mu.Lock()
+	r.models[name] = m
+	r.mu.Unlock()
+	return m, nil
+}
+
+// Get returns a model's registry entry.
+func (r *Registry) Get(name string) (store.Model, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	m, ok := r.models[name]
+	return m, ok
+}
+
+// List returns all registered models sorted by name.
+func (r *Registry) List() []store.Model {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	out := make([]store.Model, 0, len(r.models))
+	for _, m := range r.models {
+		out = append(out, m)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// Approved returns the names of models currently approved for use.
+func (r *Registry) Approved() []string {
+	var names []string
+	for _, m := range r.List() {
+		if m.State == StateApproved {
+			names = append(names, m.Name)
+		}
+	}
+	return names
+}
+
+// CheckModel reports whether a model may be called and the state that decided it.
+func (r *Registry) CheckModel(name string) (bool, string) {
+	m, ok := r.Get(name)
+	if !ok {
+		return !r.enforce, "unregistered"
+	}
+	switch m.State {
+	case StateApproved:
+		return true, m.State
+	case StateDeprecated:
+		if m.SunsetAt != nil && !time.Now().Before(*m.SunsetAt) {
+			return false, StateSunset
+		}
+		return true, m.State
+	case StateProposed:
+		return !r.enforce, m.State
+	}
+	return false, m.State
+}
+
+// Sunset returns the cutoff date of a deprecated model, if one is set.
+func (r *Registry) Sunset(name string) (time.Time, bool) {
+	m, ok := r.Get(name)
+	if !ok || m.State != StateDeprecated || m.SunsetAt == nil {
+		return time.Time{}, false
+	}
+	return *m.SunsetAt, true
+}
@@ -0,0 +1,155 @@
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/soroushbar/vantage/internal/config"
+)
+
+// Endpoint types supported by the dispatcher.
+const (
+	TypeGeneric   = "generic"
+	TypeSlack     = "slack"
+	TypeTeams     = "teams"
+	TypePagerDuty = "pagerduty"
+	TypeEmail     = "email"
+)
+
+// PagerDutyURL is the Events API v2 endpoint used when a pagerduty endpoint
+// has no URL.
+const PagerDutyURL = "https://events.pagerduty.com/v2/enqueue"
+
+var defaultTemplates = map[string]string{
+	EventRequestBlocked:    `Request blocked for user {{.UserID}} on {{.Path}}`,
+	EventLowSafety:         `Low safety score {{printf "%.2f" (index .Details "safety_score")}} for user {{.UserID}} on {{.Path}}`,
+	EventUnsafeResponse:    `Unsafe response (safety {{printf "%.2f" (index .Details "response_safety")}}) for user {{.UserID}} on {{.Path}}{{if index .Details "suppressed"}}, replaced by the fallback{{end}}`,
+	EventResponsePII:       `PII ({{index .Details "types"}}) in a response for user {{.UserID}} on {{.Path}}{{if eq (index .Details "action") "flag"}}, returned to the caller{{else if eq (index .Details "action") "block"}}, withheld{{else}}, redacted{{end}}`,
+	EventModerationFlagged: `Prompt flagged for {{index .Details "categories"}} for user {{.UserID}} on {{.Path}}{{if index .Details "blocked"}}, rejected{{end}}`,
+	EventBudgetExceeded:    `{{if eq (index .Details "budget") "project"}}Monthly budget exceeded for project {{index .Details "project"}} (user {{.UserID}}){{else}}Budget exceeded for user {{.UserID}}{{end}}`,
+	EventAdminLockout:      `Admin API locked out {{index .Details "ip"}} after {{index .Details "failures"}} failed logins`,
+	EventModelDeprecated:   `Deprecated model {{index .Details "model"}} called by {{.UserID}}: {{index .Details "warning"}}`,
+	EventProviderOutage:    `Provider {{index .Details "provider"}} is degraded ({{index .Details "indicator"}}): {{index .Details "description"}}`,
+	EventProviderRecovered: `Provider {{index .Details "provider"}} has recovered`,
+	EventUsageAnomaly:      `Unusual usage by {{.UserID}}: {{index .Details "message"}}`,
+}
+
+// templateData is what chat message templates are rendered against.
+type templateData struct {
+	Event
+	Link string
+}
+
+// renderMessage renders the endpoint's template for the event, falling back to the built-in one.
+func renderMessage(ep config.WebhookEndpoint, e Event, link string) (string, error) {
+	text, ok := ep.Templates[e.Type]
+	if !ok {
+		text, ok = defaultTemplates[e.Type]
+	}
+	if !ok {
+		text = `{{.Type}} for user {{.UserID}} on {{.Path}}`
+	}
+
+	tmpl, err := template.New(e.Type).Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, templateData{Event: e, Link: link}); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// formatPayload builds the request body for an endpoint's type.
+func formatPayload(ep config.WebhookEndpoint, e Event, dashboardURL string) ([]byte, error) {
+	link := ""
+	if dashboardURL != "" && e.LogID != 0 {
+		link = fmt.Sprintf("%s/?log=%d", strings.TrimSuffix(dashboardURL, "/"), e.LogID)
+	}
+
+	switch ep.Type {
+	case "", TypeGeneric:
+		return json.Marshal(e)
+
+	case TypeSlack:
+		text, err := renderMessage(ep, e, link)
+		if err != nil {
+			return nil, err
+		}
+		if link != "" {
+			text += fmt.Sprintf(" <%s|View log #%d>", link, e.LogID)
+		}
+		return json.Marshal(map[string]string{"text": text})
+
+	case TypeTeams:
+		text, err := renderMessage(ep, e, link)
+		if err != nil {
+			return nil, err
+		}
+		card := map[string]interface{}{
+			"@type":    "MessageCard",
+			"@context": "https://schema.org/extensions",
+			"summary":  e.Type,
+			"title":    "Vantage: " + e.Type,
+			"text":     text,
+		}
+		if link != "" {
+			card["potentialAction"] = []map[string]interface{}{{
+				"@type":   "OpenUri",
+				"name":    "View log entry",
+				"targets": []map[string]string{{"os": "default", "uri": link}},
+			}}
+		}
+		return json.Marshal(card)
+
+	case TypePagerDuty:
+		text, err := renderMessage(ep, e, link)
+		if err != nil {
+			return nil, err
+		}
+		action := "trigger"
+		if e.Type == EventProviderRecovered {
+			action = "resolve"
+		}
+		event := map[string]interface{}{
+			"routing_key":  ep.Secret,
+			"event_action": action,
+			"dedup_key":    pagerDutyKey(e),
+			"payload": map[string]interface{}{
+				"summary":        text,
+				"source":         "vantage",
+				"severity":       e.Severity,
+				"timestamp":      e.Timestamp,
+				"custom_details": e.Details,
+			},
+		}
+		if link != "" {
+			event["links"] = []map[string]string{{"href": link, "text": fmt.Sprintf("View log #%d", e.LogID)}}
+		}
+		return json.Marshal(event)
+
+	case TypeEmail:
+		text, err := renderMessage(ep, e, link)
+		if err != nil {
+			return nil, err
+		}
+		if link != "" {
+			text += "\r\n\r\nView log: " + link
+		}
+		subject := fmt.Sprintf("[Vantage %s] %s", e.Severity, e.Type)
+		return []byte("Subject: " + subject + "\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n" + text + "\r\n"), nil
+	}
+	return nil, fmt.Errorf("unknown endpoint type %q", ep.Type)
+}
+
+// pagerDutyKey groups events into one PagerDuty incident: provider events by
+// provider, so a recovery resolves the outage, and others by type and user.
+func pagerDutyKey(e Event) string {
+	if provider, ok := e.Details["provider"].(string); ok {
+		return "vantage/provider/" + provider
+	}
+	return "vantage/" + e.Type + "/" + e.UserID
+}
@@ -0,0 +1,87 @@
+package notify
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+)
+
+// Event types emitted by Vantage.
+const (
+	EventRequestBlocked    = "request.blocked"
+	EventLowSafety         = "safety.low_score"
+	EventUnsafeResponse    = "safety.unsafe_response"
+	EventResponsePII       = "safety.response_pii"
+	EventModerationFlagged = "safety.moderation_flagged"
+	EventBudgetExceeded    = "budget.exceeded"
+	EventModelDeprecated   = "model.deprecated"
+	EventAdminLockout      = "admin.lockout"
+	EventProviderOutage    = "provider.outage"
+	EventProviderRecovered = "provider.recovered"
+	EventUsageAnomaly      = "usage.anomaly"
+)
+
+// Event severities, from least to most severe.
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+// defaultSeverities is the severity NewEvent gives each event type; other
+// types are warnings.
+var defaultSeverities = map[string]string{
+	EventRequestBlocked:    SeverityWarning,
+	EventLowSafety:         SeverityWarning,
+	EventUnsafeResponse:    SeverityWarning,
+	EventResponsePII:       SeverityWarning,
+	EventModerationFlagged: SeverityWarning,
+	EventBudgetExceeded:    SeverityInfo,
+	EventModelDeprecated:   SeverityInfo,
+	EventAdminLockout:      SeverityCritical,
+	EventProviderOutage:    SeverityCritical,
+	EventProviderRecovered: SeverityInfo,
+	EventUsageAnomaly:      SeverityWarning,
+}
+
+var severityLevels = map[string]int{SeverityInfo: 0, SeverityWarning: 1, SeverityCritical: 2}
+
+// MaxSeverity returns the more severe of a and b.
+func MaxSeverity(a, b string) string {
+	if severityLevels[b] > severityLevels[a] {
+		return b
+	}
+	return a
+}
+
+// Event is the JSON document delivered to notification endpoints.
+type Event struct {
+	ID        string                 `json:"id"`
+	Type      string                 `json:"type"`
+	Severity  string                 `json:"severity"`
+	Timestamp time.Time              `json:"timestamp"`
+	UserID    string                 `json:"user_id,omitempty"`
+	Path      string                 `json:"path,omitempty"`
+	LogID     int64                  `json:"log_id,omitempty"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+}
+
+// NewEvent creates an event with a random ID, the current time and the
+// default severity of its type.
+func NewEvent(eventType, userID, path string, details map[string]interface{}) Event {
+	id := make([]byte, 8)
+	rand.Read(id)
+	severity, ok := defaultSeverities[eventType]
+	if !ok {
+		severity = SeverityWarning
+	}
+	return Event{
+		ID:        hex.EncodeToString(id),
+		Type:      eventType,
+		Severity:  severity,
+		Timestamp: time.Now().UTC(),
+		UserID:    userID,
+		Path:      path,
+		Details:   details,
+	}
+}
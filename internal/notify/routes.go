@@ -0,0 +1,119 @@
+package notify
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/soroushbar/vantage/internal/config"
+	pkgmiddleware "github.com/soroushbar/vantage/pkg/middleware"
+)
+
+// router picks the endpoints an event goes to under the configured routes,
+// remembering recent deliveries for deduplication.
+type router struct {
+	routes []route
+
+	mu   sync.Mutex
+	seen map[string]time.Time // dedup key -> last delivery
+}
+
+type route struct {
+	config.NotificationRoute
+	quiet *pkgmiddleware.TimeWindow
+	loc   *time.Location
+}
+
+// newRouter converts routes, which were validated at config load.
+func newRouter(routes []config.NotificationRoute) *router {
+	r := &router{seen: map[string]time.Time{}}
+	for _, c := range routes {
+		rt := route{NotificationRoute: c, loc: time.UTC}
+		if loc, err := time.LoadLocation(c.Timezone); err == nil {
+			rt.loc = loc
+		}
+		if c.QuietHours != nil {
+			days, start, end, _ := c.QuietHours.Parse()
+			rt.quiet = &pkgmiddleware.TimeWindow{Days: days, Start: start, End: end}
+		}
+		r.routes = append(r.routes, rt)
+	}
+	return r
+}
+
+// route returns the endpoints that should receive e and, for endpoints
+// that only matched suppressing routes, why it was held back. An endpoint
+// matched by several routes receives the event once.
+func (r *router) route(e Event, now time.Time) (deliver map[string]bool, suppressed map[string]string) {
+	deliver, suppressed = map[string]bool{}, map[string]string{}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.prune(now)
+
+	for _, rt := range r.routes {
+		if !rt.matches(e) {
+			continue
+		}
+		reason := ""
+		key := rt.Name + "|" + e.dedupKey()
+		switch {
+		case rt.quiet != nil && e.Severity != SeverityCritical && rt.quiet.Contains(now.In(rt.loc)):
+			reason = "quiet hours (" + rt.Name + ")"
+		case rt.DedupWindow > 0 && now.Sub(r.seen[key]) < rt.DedupWindow:
+			reason = "duplicate within " + rt.DedupWindow.String() + " (" + rt.Name + ")"
+		default:
+			if rt.DedupWindow > 0 {
+				r.seen[key] = now
+			}
+		}
+		for _, name := range rt.Endpoints {
+			if reason == "" {
+				deliver[name] = true
+				delete(suppressed, name)
+			} else if !deliver[name] {
+				suppressed[name] = reason
+			}
+		}
+	}
+	return deliver, suppressed
+}
+
+// prune forgets deliveries older than every dedup window.
+func (r *router) prune(now time.Time) {
+	if len(r.seen) < 1000 {
+		return
+	}
+	var longest time.Duration
+	for _, rt := range r.routes {
+		longest = max(longest, rt.DedupWindow)
+	}
+	for key, at := range r.seen {
+		if now.Sub(at) >= longest {
+			delete(r.seen, key)
+		}
+	}
+}
+
+func (rt route) matches(e Event) bool {
+	if len(rt.Events) > 0 && !slices.ContainsFunc(rt.Events, func(pattern string) bool {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			return strings.HasPrefix(e.Type, prefix)
+		}
+		return pattern == e.Type
+	}) {
+		return false
+	}
+	if rt.MinSeverity != "" && severityLevels[e.Severity] < severityLevels[rt.MinSeverity] {
+		return false
+	}
+	return len(rt.Users) == 0 || slices.Contains(rt.Users, e.UserID)
+}
+
+// dedupKey identifies repeats of an event: the same type for the same user,
+// path and provider.
+func (e Event) dedupKey() string {
+	provider, _ := e.Details["provider"].(string)
+	return fmt.Sprintf("%s|%s|%s|%s", e.Type, e.UserID, e.Path, provider)
+}
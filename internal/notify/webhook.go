@@ -0,0 +1,237 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/soroushbar/vantage/internal/config"
+)
+
+// Store interface for decoupling
+type Store interface {
+	CreateDelivery(eventID, eventType, endpoint string) (int64, error)
+	UpdateDelivery(id int64, status string, attempts, responseCode int, lastError string) error
+}
+
+// Observer sees every published event, whether or not it is delivered
+// anywhere.
+type Observer interface {
+	Observe(e Event)
+}
+
+type delivery struct {
+	id       int64
+	endpoint config.WebhookEndpoint
+	payload  []byte
+	attempts int
+}
+
+// Dispatcher delivers events to the configured endpoints, signing generic
+// webhooks with HMAC and retrying failed deliveries with exponential backoff.
+type Dispatcher struct {
+	endpoints    []config.WebhookEndpoint
+	router       *router
+	smtp         config.SMTPConfig
+	dashboardURL string
+	maxAttempts  int
+	backoff      time.Duration
+	store        Store
+	client       *http.Client
+	queue        chan delivery
+	observers    []Observer
+}
+
+func NewDispatcher(cfg config.WebhooksConfig, st Store) *Dispatcher {
+	d := &Dispatcher{
+		dashboardURL: cfg.DashboardURL,
+		maxAttempts:  cfg.MaxAttempts,
+		backoff:      cfg.RetryBackoff,
+		store:        st,
+		client:       &http.Client{Timeout: 10 * time.Second},
+		queue:        make(chan delivery, 100),
+	}
+	if len(cfg.Routes) > 0 {
+		d.router = newRouter(cfg.Routes)
+	}
+	d.smtp = cfg.SMTP
+	for _, ep := range cfg.Endpoints {
+		if ep.Type == TypePagerDuty && ep.URL == "" {
+			ep.URL = PagerDutyURL
+		}
+		scheme := "https"
+		if ep.Type == TypeEmail {
+			scheme = "mailto"
+		}
+		u, err := url.Parse(ep.URL)
+		if err != nil || u.Scheme != scheme {
+			log.Printf("Skipping webhook %q: endpoint must be a %s URL", ep.URL, scheme)
+			continue
+		}
+		if ep.Name == "" {
+			ep.Name = u.Host + u.Opaque
+		}
+		d.endpoints = append(d.endpoints, ep)
+	}
+	return d
+}
+
+// Start runs the delivery loop in a background goroutine.
+func (d *Dispatcher) Start(ctx context.Context) {
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case job := <-d.queue:
+				d.attempt(ctx, job)
+			}
+		}
+	}()
+}
+
+// AddObserver passes every event published from now on to o. Call it
+// before events are published.
+func (d *Dispatcher) AddObserver(o Observer) {
+	d.observers = append(d.observers, o)
+}
+
+// Publish queues an event for every subscribed endpoint, or with routes for
+// the endpoints the event is routed to, without blocking. Deliveries held
+// back by quiet hours or deduplication are recorded as suppressed.
+func (d *Dispatcher) Publish(e Event) {
+	for _, o := range d.observers {
+		o.Observe(e)
+	}
+	var routed map[string]bool
+	var suppressed map[string]string
+	if d.router != nil {
+		routed, suppressed = d.router.route(e, time.Now())
+	}
+	for _, ep := range d.endpoints {
+		if !subscribed(ep, e.Type) {
+			continue
+		}
+		if reason, ok := suppressed[ep.Name]; ok {
+			if id, err := d.store.CreateDelivery(e.ID, e.Type, ep.Name); err == nil {
+				d.store.UpdateDelivery(id, "suppressed", 0, 0, reason)
+			}
+			continue
+		}
+		if d.router != nil && !routed[ep.Name] {
+			continue
+		}
+		payload, err := formatPayload(ep, e, d.dashboardURL)
+		if err != nil {
+			log.Printf("Failed to format event %s for %s: %v", e.ID, ep.Name, err)
+			continue
+		}
+		id, err := d.store.CreateDelivery(e.ID, e.Type, ep.Name)
+		if err != nil {
+			log.Printf("Failed to record webhook delivery: %v", err)
+		}
+		d.enqueue(delivery{id: id, endpoint: ep, payload: payload})
+	}
+}
+
+func (d *Dispatcher) enqueue(job delivery) {
+	select {
+	case d.queue <- job:
+	default:
+		log.Printf("Webhook queue full, dropping delivery to %s", job.endpoint.Name)
+		d.store.UpdateDelivery(job.id, "dropped", job.attempts, 0, "queue full")
+	}
+}
+
+func (d *Dispatcher) attempt(ctx context.Context, job delivery) {
+	job.attempts++
+	code, err := d.send(ctx, job)
+	if err == nil {
+		d.store.UpdateDelivery(job.id, "delivered", job.attempts, code, "")
+		return
+	}
+
+	if job.attempts >= d.maxAttempts {
+		log.Printf("Webhook delivery to %s failed after %d attempts: %v", job.endpoint.Name, job.attempts, err)
+		d.store.UpdateDelivery(job.id, "failed", job.attempts, code, err.Error())
+		return
+	}
+	d.store.UpdateDelivery(job.id, "retrying", job.attempts, code, err.Error())
+	time.AfterFunc(d.backoff*time.Duration(1<<(job.attempts-1)), func() { d.enqueue(job) })
+}
+
+func (d *Dispatcher) send(ctx context.Context, job delivery) (int, error) {
+	if job.endpoint.Type == TypeEmail {
+		return 0, d.sendEmail(job)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, job.endpoint.URL, bytes.NewReader(job.payload))
+	if err != nil {
+		return 0, err
+	}
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Vantage-Timestamp", ts)
+	if job.endpoint.Secret != "" && (job.endpoint.Type == "" || job.endpoint.Type == TypeGeneric) {
+		req.Header.Set("X-Vantage-Signature", "sha256="+Sign(job.endpoint.Secret, ts, job.payload))
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("endpoint returned %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// sendEmail mails the formatted message to the addresses of the endpoint's
+// mailto: URL.
+func (d *Dispatcher) sendEmail(job delivery) error {
+	u, err := url.Parse(job.endpoint.URL)
+	if err != nil {
+		return err
+	}
+	to := strings.Split(u.Opaque, ",")
+	var auth smtp.Auth
+	if d.smtp.Username != "" {
+		host, _, _ := net.SplitHostPort(d.smtp.Addr)
+		auth = smtp.PlainAuth("", d.smtp.Username, os.Getenv(d.smtp.PasswordEnv), host)
+	}
+	msg := "From: " + d.smtp.From + "\r\nTo: " + strings.Join(to, ", ") + "\r\n"
+	return smtp.SendMail(d.smtp.Addr, auth, d.smtp.From, to, append([]byte(msg), job.payload...))
+}
+
+// Sign returns the hex HMAC-SHA256 of "timestamp.payload" so receivers can
+// verify both authenticity and freshness.
+func Sign(secret, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func subscribed(ep config.WebhookEndpoint, eventType string) bool {
+	if len(ep.Events) == 0 {
+		return true
+	}
+	for _, e := range ep.Events {
+		if e == eventType {
+			return true
+		}
+	}
+	return false
+}
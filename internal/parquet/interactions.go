@@ -0,0 +1,113 @@
+package parquet
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/soroushbar/vantage/internal/store"
+)
+
+// WriteInteractions writes records as one Parquet file. The schema is the
+// same for every file so that exports can be queried together: typed
+// columns for the counters, scores and flags, UTF8 for text, JSON for the
+// structured fields and a UTC millisecond timestamp. New columns are only
+// ever appended. chain_hash is null for records that are not exported out
+// of the database.
+func WriteInteractions(w io.Writer, records []store.ExportRecord, compression string) error {
+	f := &file{rows: len(records)}
+	id := f.add("id", typeInt64, noConverted, false)
+	timestamp := f.add("timestamp", typeInt64, convTimestampMs, false)
+	user := f.add("user_id", typeByteArray, convUTF8, false)
+	method := f.add("method", typeByteArray, convUTF8, false)
+	path := f.add("path", typeByteArray, convUTF8, false)
+	model := f.add("model", typeByteArray, convUTF8, true)
+	routedModel := f.add("routed_model", typeByteArray, convUTF8, true)
+	status := f.add("status_code", typeInt32, noConverted, false)
+	latency := f.add("latency_ms", typeInt64, noConverted, false)
+	tokens := f.add("tokens", typeInt64, noConverted, false)
+	safety := f.add("safety_score", typeDouble, noConverted, true)
+	responseSafety := f.add("response_safety", typeDouble, noConverted, true)
+	blocked := f.add("is_blocked", typeBoolean, noConverted, false)
+	redacted := f.add("is_redacted", typeBoolean, noConverted, false)
+	cacheHit := f.add("cache_hit", typeBoolean, noConverted, false)
+	slow := f.add("is_slow", typeBoolean, noConverted, false)
+	template := f.add("template", typeByteArray, convUTF8, true)
+	metadata := f.add("metadata", typeByteArray, convJSON, true)
+	truncation := f.add("truncation", typeByteArray, convJSON, true)
+	verdict := f.add("verdict", typeByteArray, convJSON, true)
+	headers := f.add("headers", typeByteArray, convJSON, true)
+	requestBody := f.add("request_body", typeByteArray, convUTF8, false)
+	responseBody := f.add("response_body", typeByteArray, convUTF8, false)
+	archiveKey := f.add("archive_key", typeByteArray, convUTF8, true)
+	chainHash := f.add("chain_hash", typeByteArray, convUTF8, true)
+	session := f.add("session_id", typeByteArray, convUTF8, true)
+	estimated := f.add("tokens_estimated", typeBoolean, noConverted, false)
+	transformation := f.add("transformation", typeByteArray, convJSON, true)
+	redactionReport := f.add("redaction_report", typeByteArray, convJSON, true)
+	responsePII := f.add("response_pii", typeByteArray, convJSON, true)
+	project := f.add("project", typeByteArray, convUTF8, true)
+	paramOverrides := f.add("param_overrides", typeByteArray, convJSON, true)
+	moderation := f.add("moderation", typeByteArray, convJSON, true)
+	requestID := f.add("request_id", typeByteArray, convUTF8, true)
+
+	for _, r := range records {
+		id.values = append(id.values, int64(r.ID))
+		timestamp.values = append(timestamp.values, r.Timestamp.UnixMilli())
+		user.values = append(user.values, r.UserID)
+		method.values = append(method.values, r.Method)
+		path.values = append(path.values, r.Path)
+		model.values = append(model.values, nullable(r.Model))
+		routedModel.values = append(routedModel.values, nullable(r.RoutedModel))
+		status.values = append(status.values, int32(r.StatusCode))
+		latency.values = append(latency.values, r.LatencyMs)
+		tokens.values = append(tokens.values, int64(r.Tokens))
+		if r.SafetyScore != nil {
+			safety.values = append(safety.values, *r.SafetyScore)
+		} else {
+			safety.values = append(safety.values, nil)
+		}
+		if r.ResponseSafety != nil {
+			responseSafety.values = append(responseSafety.values, *r.ResponseSafety)
+		} else {
+			responseSafety.values = append(responseSafety.values, nil)
+		}
+		blocked.values = append(blocked.values, r.IsBlocked)
+		redacted.values = append(redacted.values, r.IsRedacted)
+		cacheHit.values = append(cacheHit.values, r.CacheHit)
+		slow.values = append(slow.values, r.IsSlow)
+		template.values = append(template.values, nullable(r.Template))
+		metadata.values = append(metadata.values, nullableJSON(r.Metadata))
+		truncation.values = append(truncation.values, nullableJSON(r.Truncation))
+		verdict.values = append(verdict.values, nullableJSON(r.Verdict))
+		headers.values = append(headers.values, nullableJSON(r.Headers))
+		requestBody.values = append(requestBody.values, r.RequestBody)
+		responseBody.values = append(responseBody.values, r.ResponseBody)
+		archiveKey.values = append(archiveKey.values, nullable(r.ArchiveKey))
+		chainHash.values = append(chainHash.values, nullable(r.ChainHash))
+		session.values = append(session.values, nullable(r.Session))
+		estimated.values = append(estimated.values, r.Estimated)
+		transformation.values = append(transformation.values, nullableJSON(r.Transformation))
+		redactionReport.values = append(redactionReport.values, nullableJSON(r.RedactionReport))
+		responsePII.values = append(responsePII.values, nullableJSON(r.ResponsePII))
+		project.values = append(project.values, nullable(r.Project))
+		paramOverrides.values = append(paramOverrides.values, nullableJSON(r.ParamOverrides))
+		moderation.values = append(moderation.values, nullableJSON(r.Moderation))
+		requestID.values = append(requestID.values, nullable(r.RequestID))
+	}
+	return f.writeTo(w, compression)
+}
+
+// nullable maps the empty string to null.
+func nullable(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+func nullableJSON(raw json.RawMessage) interface{} {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil
+	}
+	return string(raw)
+}
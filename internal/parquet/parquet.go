@@ -0,0 +1,211 @@
+// Package parquet writes flat Apache Parquet files: one row group, one
+// PLAIN-encoded data page per column, optionally compressed. It covers what
+// the log exports need and no more; readers such as DuckDB, Spark and
+// pyarrow read the files without extra configuration.
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/soroushbar/vantage/internal/codec"
+)
+
+var magic = []byte("PAR1")
+
+// Physical types.
+const (
+	typeBoolean   int32 = 0
+	typeInt32     int32 = 1
+	typeInt64     int32 = 2
+	typeDouble    int32 = 5
+	typeByteArray int32 = 6
+)
+
+// Converted (logical) types; noConverted leaves the physical type as is.
+const (
+	noConverted     int32 = -1
+	convUTF8        int32 = 0
+	convTimestampMs int32 = 9
+	convJSON        int32 = 19
+)
+
+// Encodings, repetition and page types used by the writer.
+const (
+	encodingPlain int32 = 0
+	encodingRLE   int32 = 3
+	required      int32 = 0
+	optional      int32 = 1
+	dataPage      int32 = 0
+)
+
+// codecs maps codec algorithms to Parquet compression codecs.
+var codecs = map[string]int32{"": 0, codec.None: 0, codec.Gzip: 2, codec.Zstd: 6}
+
+// column is one column of a file being built. Values hold one entry per
+// row, nil for a null in an optional column.
+type column struct {
+	name      string
+	typ       int32
+	converted int32
+	optional  bool
+	values    []interface{}
+}
+
+// file collects rows column by column and writes them out in one go.
+type file struct {
+	columns []*column
+	rows    int
+}
+
+func (f *file) add(name string, typ, converted int32, optional bool) *column {
+	c := &column{name: name, typ: typ, converted: converted, optional: optional}
+	f.columns = append(f.columns, c)
+	return c
+}
+
+// writeTo writes the file with its pages compressed by compression, one of
+// codec.Algorithms.
+func (f *file) writeTo(w io.Writer, compression string) error {
+	codecID, ok := codecs[compression]
+	if !ok {
+		return fmt.Errorf("parquet: unknown compression %q", compression)
+	}
+
+	var out bytes.Buffer
+	out.Write(magic)
+	schema := []interface{}{tstruct{{4, "schema"}, {5, int32(len(f.columns))}}}
+	var chunks []interface{}
+	var total int64
+	for _, c := range f.columns {
+		el := tstruct{{1, c.typ}, {3, required}, {4, c.name}}
+		if c.optional {
+			el[1].value = optional
+		}
+		if c.converted != noConverted {
+			el = append(el, field{6, c.converted})
+		}
+		schema = append(schema, el)
+		if f.rows == 0 {
+			continue
+		}
+
+		raw, err := c.page()
+		if err != nil {
+			return err
+		}
+		data, err := codec.Encode(compression, raw)
+		if err != nil {
+			return err
+		}
+		var header bytes.Buffer
+		writeStruct(&header, tstruct{
+			{1, dataPage},
+			{2, int32(len(raw))},
+			{3, int32(len(data))},
+			{5, tstruct{{1, int32(f.rows)}, {2, encodingPlain}, {3, encodingRLE}, {4, encodingRLE}}},
+		})
+		offset := int64(out.Len())
+		out.Write(header.Bytes())
+		out.Write(data)
+		uncompressed := int64(header.Len() + len(raw))
+		total += uncompressed
+		chunks = append(chunks, tstruct{
+			{2, offset},
+			{3, tstruct{
+				{1, c.typ},
+				{2, tlist{tI32, []interface{}{encodingPlain, encodingRLE}}},
+				{3, tlist{tBinary, []interface{}{c.name}}},
+				{4, codecID},
+				{5, int64(f.rows)},
+				{6, uncompressed},
+				{7, int64(header.Len() + len(data))},
+				{9, offset},
+			}},
+		})
+	}
+
+	var groups []interface{}
+	if f.rows > 0 {
+		groups = append(groups, tstruct{{1, tlist{tStruct, chunks}}, {2, total}, {3, int64(f.rows)}})
+	}
+	var footer bytes.Buffer
+	writeStruct(&footer, tstruct{
+		{1, int32(1)},
+		{2, tlist{tStruct, schema}},
+		{3, int64(f.rows)},
+		{4, tlist{tStruct, groups}},
+		{6, "vantage"},
+	})
+	out.Write(footer.Bytes())
+	binary.Write(&out, binary.LittleEndian, uint32(footer.Len()))
+	out.Write(magic)
+	_, err := w.Write(out.Bytes())
+	return err
+}
+
+// page returns the uncompressed data page of c: definition levels for an
+// optional column, then the PLAIN-encoded non-null values.
+func (c *column) page() ([]byte, error) {
+	var buf bytes.Buffer
+	if c.optional {
+		levels := definitionLevels(c.values)
+		binary.Write(&buf, binary.LittleEndian, uint32(len(levels)))
+		buf.Write(levels)
+	}
+	var bits []bool
+	for _, v := range c.values {
+		switch v := v.(type) {
+		case nil:
+			if !c.optional {
+				return nil, fmt.Errorf("parquet: null in required column %s", c.name)
+			}
+		case bool:
+			bits = append(bits, v)
+		case int32:
+			binary.Write(&buf, binary.LittleEndian, v)
+		case int64:
+			binary.Write(&buf, binary.LittleEndian, v)
+		case float64:
+			binary.Write(&buf, binary.LittleEndian, math.Float64bits(v))
+		case string:
+			binary.Write(&buf, binary.LittleEndian, uint32(len(v)))
+			buf.WriteString(v)
+		default:
+			return nil, fmt.Errorf("parquet: unsupported value %T in column %s", v, c.name)
+		}
+	}
+	// booleans are bit-packed, least significant bit first
+	packed := make([]byte, (len(bits)+7)/8)
+	for i, b := range bits {
+		if b {
+			packed[i/8] |= 1 << (i % 8)
+		}
+	}
+	buf.Write(packed)
+	return buf.Bytes(), nil
+}
+
+// definitionLevels encodes 1 for every present value and 0 for every null
+// as runs of the RLE/bit-packing hybrid encoding, with a bit width of 1.
+func definitionLevels(values []interface{}) []byte {
+	var buf bytes.Buffer
+	for i := 0; i < len(values); {
+		present := values[i] != nil
+		n := 1
+		for i+n < len(values) && (values[i+n] != nil) == present {
+			n++
+		}
+		writeVarint(&buf, uint64(n)<<1)
+		if present {
+			buf.WriteByte(1)
+		} else {
+			buf.WriteByte(0)
+		}
+		i += n
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,116 @@
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// Parquet's page headers and footer are Thrift structs in the compact
+// protocol. Only the parts of the protocol the writer emits are
+// implemented: structs, lists, booleans, i32, i64 and binary.
+
+// Compact protocol type IDs.
+const (
+	tTrue   = 1
+	tFalse  = 2
+	tI32    = 5
+	tI64    = 6
+	tBinary = 8
+	tList   = 9
+	tStruct = 12
+)
+
+// field is one field of a Thrift struct. Value is an int32, int64, bool,
+// string, tstruct or tlist.
+type field struct {
+	id    int16
+	value interface{}
+}
+
+// tstruct is a Thrift struct; fields must be in ascending id order.
+type tstruct []field
+
+// tlist is a Thrift list of elements of one compact type.
+type tlist struct {
+	elem  byte
+	items []interface{}
+}
+
+func writeStruct(buf *bytes.Buffer, s tstruct) {
+	var last int16
+	for _, f := range s {
+		typ := thriftType(f.value)
+		if delta := f.id - last; delta > 0 && delta <= 15 {
+			buf.WriteByte(byte(delta)<<4 | typ)
+		} else {
+			buf.WriteByte(typ)
+			writeVarint(buf, zigzag(int64(f.id)))
+		}
+		last = f.id
+		if typ != tTrue && typ != tFalse {
+			writeValue(buf, f.value)
+		}
+	}
+	buf.WriteByte(0)
+}
+
+func thriftType(v interface{}) byte {
+	switch v := v.(type) {
+	case bool:
+		if v {
+			return tTrue
+		}
+		return tFalse
+	case int32:
+		return tI32
+	case int64:
+		return tI64
+	case string:
+		return tBinary
+	case tlist:
+		return tList
+	case tstruct:
+		return tStruct
+	}
+	panic("parquet: unsupported thrift value")
+}
+
+func writeValue(buf *bytes.Buffer, v interface{}) {
+	switch v := v.(type) {
+	case bool:
+		// only inside lists; struct fields carry booleans in their header
+		if v {
+			buf.WriteByte(1)
+		} else {
+			buf.WriteByte(0)
+		}
+	case int32:
+		writeVarint(buf, zigzag(int64(v)))
+	case int64:
+		writeVarint(buf, zigzag(v))
+	case string:
+		writeVarint(buf, uint64(len(v)))
+		buf.WriteString(v)
+	case tlist:
+		if n := len(v.items); n < 15 {
+			buf.WriteByte(byte(n)<<4 | v.elem)
+		} else {
+			buf.WriteByte(0xf0 | v.elem)
+			writeVarint(buf, uint64(n))
+		}
+		for _, item := range v.items {
+			writeValue(buf, item)
+		}
+	case tstruct:
+		writeStruct(buf, v)
+	}
+}
+
+func zigzag(n int64) uint64 {
+	return uint64(n<<1) ^ uint64(n>>63)
+}
+
+func writeVarint(buf *bytes.Buffer, v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	buf.Write(b[:binary.PutUvarint(b[:], v)])
+}
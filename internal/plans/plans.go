@@ -0,0 +1,70 @@
+package plans
+
+import (
+	"sort"
+
+	"github.com/soroushbar/vantage/internal/config"
+	"github.com/soroushbar/vantage/pkg/middleware"
+)
+
+// Catalog maps users to the plans defined in the config.
+type Catalog struct {
+	defaultPlan string
+	users       map[string]string
+	plans       map[string]middleware.Plan
+}
+
+// Summary is the plan catalog as reported by /api/plans.
+type Summary struct {
+	Default     string            `json:"default"`
+	Plans       []middleware.Plan `json:"plans"`
+	Assignments map[string]string `json:"assignments"`
+}
+
+func NewCatalog(cfg config.PlansConfig) *Catalog {
+	c := &Catalog{
+		defaultPlan: cfg.Default,
+		users:       cfg.Users,
+		plans:       make(map[string]middleware.Plan, len(cfg.Plans)),
+	}
+	for name, p := range cfg.Plans {
+		c.plans[name] = middleware.Plan{
+			Name:            name,
+			MaxPromptBytes:  p.MaxPromptBytes,
+			MaxOutputTokens: p.MaxOutputTokens,
+			Models:          p.Models,
+			Stream:          p.Stream,
+		}
+	}
+	return c
+}
+
+// PlanFor returns the user's assigned plan, falling back to the default
+// plan. Users with neither are unrestricted.
+func (c *Catalog) PlanFor(userID string) (middleware.Plan, bool) {
+	name, ok := c.users[userID]
+	if !ok {
+		name = c.defaultPlan
+	}
+	p, ok := c.plans[name]
+	return p, ok
+}
+
+// Plan returns the plan with the given name.
+func (c *Catalog) Plan(name string) (middleware.Plan, bool) {
+	p, ok := c.plans[name]
+	return p, ok
+}
+
+// Summary lists every plan, sorted by name, with the user assignments.
+func (c *Catalog) Summary() Summary {
+	s := Summary{Default: c.defaultPlan, Plans: make([]middleware.Plan, 0, len(c.plans)), Assignments: c.users}
+	for _, p := range c.plans {
+		s.Plans = append(s.Plans, p)
+	}
+	sort.Slice(s.Plans, func(i, j int) bool { return s.Plans[i].Name < s.Plans[j].Name })
+	if s.Assignments == nil {
+		s.Assignments = map[string]string{}
+	}
+	return s
+}
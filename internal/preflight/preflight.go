@@ -0,0 +1,338 @@
+// Package preflight checks what would otherwise only fail once the gateway
+// serves traffic: the config, the database, Redis, ClickHouse, the secret
+// store and the credentials of every configured provider. Each check reports all of its
+// problems.
+package preflight
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/soroushbar/vantage/internal/clickhouse"
+	"github.com/soroushbar/vantage/internal/config"
+	"github.com/soroushbar/vantage/internal/redis"
+	"github.com/soroushbar/vantage/internal/secrets"
+	"github.com/soroushbar/vantage/internal/store"
+	"github.com/soroushbar/vantage/pkg/providers/bedrock"
+	"github.com/soroushbar/vantage/pkg/providers/gemini"
+	"github.com/soroushbar/vantage/pkg/providers/groq"
+	"github.com/soroushbar/vantage/pkg/providers/mistral"
+	"github.com/soroushbar/vantage/pkg/vantage"
+)
+
+// Result is the outcome of one check; it passed without Problems. Note
+// explains a pass that needs a word, such as a check that was skipped.
+type Result struct {
+	Name     string
+	Problems []string
+	Note     string
+}
+
+// OK reports whether the check passed.
+func (r Result) OK() bool {
+	return len(r.Problems) == 0
+}
+
+// Options locates what the gateway would use. CohereKey is the
+// COHERE_API_KEY of the gateway, replaced by the key in the secret store
+// when the config names one; Offline skips the calls to providers.
+type Options struct {
+	ConfigPath string
+	DBPath     string
+	CohereKey  string
+	Offline    bool
+	Client     *http.Client
+}
+
+// Run performs every check and returns their results in a fixed order.
+// Provider checks are skipped when the config cannot be read.
+func Run(ctx context.Context, opts Options) []Result {
+	if opts.Client == nil {
+		opts.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	cfg, configResult := checkConfig(opts.ConfigPath)
+	results := []Result{configResult}
+	results = append(results, check("database", store.CheckDatabase(ctx, opts.DBPath)))
+	if cfg != nil && cfg.Redis.Enabled {
+		results = append(results, check("redis", checkRedis(ctx, cfg.Redis)))
+	}
+	if cfg != nil && cfg.ClickHouse.Enabled {
+		results = append(results, check("clickhouse", checkClickHouse(ctx, cfg.ClickHouse)))
+	}
+
+	var geminiKey string
+	// Keys of the OpenAI-compatible providers, from the secret store
+	compatKeys := map[string]string{}
+	if cfg != nil && cfg.Secrets.Source != "" {
+		keys, err := secrets.New(ctx, cfg.Secrets)
+		if err == nil {
+			err = keys.Load(ctx)
+		}
+		results = append(results, check("secrets", err))
+		if err == nil {
+			if keys.Has(secrets.Cohere) {
+				opts.CohereKey = keys.Value(secrets.Cohere)
+			}
+			if keys.Has(secrets.Gemini) {
+				geminiKey = keys.Value(secrets.Gemini)
+			}
+			for _, name := range []string{secrets.Mistral, secrets.Groq} {
+				if keys.Has(name) {
+					compatKeys[name] = keys.Value(name)
+				}
+			}
+		}
+	}
+
+	cohere := Result{Name: "cohere"}
+	switch {
+	case cfg != nil && cfg.Provider == "mock":
+		cohere.Note = "mock provider, no API key needed"
+	case opts.CohereKey == "":
+		cohere.Problems = []string{"COHERE_API_KEY is not set"}
+	case opts.Offline:
+		cohere.Note = "API key not checked (offline)"
+	default:
+		cohere = check("cohere", checkCohereKey(ctx, opts.Client, vantage.DefaultUpstream, opts.CohereKey))
+	}
+	results = append(results, cohere)
+
+	if cfg == nil {
+		return results
+	}
+	for _, t := range cfg.Upstreams.Targets {
+		name := "upstream " + t.Name
+		key := t.APIKey
+		if t.APIKeyEnv != "" {
+			key = os.Getenv(t.APIKeyEnv)
+		}
+		if key == "" {
+			key = opts.CohereKey
+		}
+		if opts.Offline {
+			results = append(results, Result{Name: name, Note: "API key not checked (offline)"})
+			continue
+		}
+		results = append(results, check(name, checkCohereKey(ctx, opts.Client, t.BaseURL, key)))
+	}
+	if g := cfg.Providers.Gemini; g.Enabled {
+		// The gateway lets GEMINI_API_KEY override the config
+		if key := os.Getenv("GEMINI_API_KEY"); key != "" {
+			g.APIKey = key
+		}
+		if geminiKey != "" {
+			g.APIKey = geminiKey
+		}
+		switch {
+		case g.APIKey == "":
+			results = append(results, Result{Name: "gemini", Problems: []string{"no API key in providers.gemini.api_key or GEMINI_API_KEY"}})
+		case opts.Offline:
+			results = append(results, Result{Name: "gemini", Note: "API key not checked (offline)"})
+		default:
+			results = append(results, check("gemini", checkGeminiKey(ctx, opts.Client, g)))
+		}
+	}
+	for _, c := range []struct {
+		name, env, base string
+		cfg             config.CompatProviderConfig
+	}{
+		{secrets.Mistral, "MISTRAL_API_KEY", mistral.DefaultBaseURL, cfg.Providers.Mistral},
+		{secrets.Groq, "GROQ_API_KEY", groq.DefaultBaseURL, cfg.Providers.Groq},
+	} {
+		if !c.cfg.Enabled {
+			continue
+		}
+		key := c.cfg.APIKey
+		if env := os.Getenv(c.env); env != "" {
+			key = env
+		}
+		if compatKeys[c.name] != "" {
+			key = compatKeys[c.name]
+		}
+		if c.cfg.BaseURL != "" {
+			c.base = c.cfg.BaseURL
+		}
+		switch {
+		case key == "":
+			results = append(results, Result{Name: c.name, Problems: []string{fmt.Sprintf("no API key in providers.%s.api_key or %s", c.name, c.env)}})
+		case opts.Offline:
+			results = append(results, Result{Name: c.name, Note: "API key not checked (offline)"})
+		default:
+			results = append(results, check(c.name, checkBearerKey(ctx, opts.Client, c.base, key)))
+		}
+	}
+	if b := cfg.Providers.Bedrock; b.Enabled {
+		p, err := bedrock.New(ctx, b.Region, b.BaseURL)
+		if err == nil {
+			err = p.CheckCredentials(ctx)
+		}
+		results = append(results, check("bedrock", err))
+	}
+	for _, l := range cfg.Providers.Local {
+		name := "local " + l.Name
+		if opts.Offline {
+			results = append(results, Result{Name: name, Note: "not contacted (offline)"})
+			continue
+		}
+		results = append(results, check(name, checkReachable(ctx, opts.Client, l.BaseURL)))
+	}
+	return results
+}
+
+// Failed reports whether any check failed.
+func Failed(results []Result) bool {
+	for _, r := range results {
+		if !r.OK() {
+			return true
+		}
+	}
+	return false
+}
+
+func check(name string, err error) Result {
+	r := Result{Name: name}
+	if err != nil {
+		r.Problems = []string{err.Error()}
+	}
+	return r
+}
+
+// checkConfig loads the config strictly and returns it, or nil when it
+// cannot be parsed at all. A missing file runs on the defaults, as the
+// gateway does.
+func checkConfig(path string) (*config.Config, Result) {
+	r := Result{Name: "config"}
+	cfg, err := config.LoadConfigStrict(path)
+	if errors.Is(err, os.ErrNotExist) {
+		r.Note = path + " not found, defaults apply"
+		return config.Default(), r
+	}
+	for _, e := range flatten(err) {
+		r.Problems = append(r.Problems, e.Error())
+	}
+	return cfg, r
+}
+
+// flatten unwraps joined errors into their leaves.
+func flatten(err error) []error {
+	if err == nil {
+		return nil
+	}
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		return []error{err}
+	}
+	var errs []error
+	for _, e := range joined.Unwrap() {
+		errs = append(errs, flatten(e)...)
+	}
+	return errs
+}
+
+// checkRedis pings the shared-state server, honouring REDIS_URL as the
+// gateway does.
+func checkRedis(ctx context.Context, r config.RedisConfig) error {
+	if u := os.Getenv("REDIS_URL"); u != "" {
+		r.URL = u
+	}
+	client, err := redis.NewClient(r.URL, r.KeyPrefix, r.Timeout, 1)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	if err := client.Ping(ctx); err != nil {
+		return fmt.Errorf("%s unreachable: %w", client.Addr(), err)
+	}
+	return nil
+}
+
+// checkClickHouse runs a query on the analytics server, honouring
+// VANTAGE_CLICKHOUSE_PASSWORD as the gateway does.
+func checkClickHouse(ctx context.Context, ch config.ClickHouseConfig) error {
+	if p := os.Getenv("VANTAGE_CLICKHOUSE_PASSWORD"); p != "" {
+		ch.Password = p
+	}
+	client, err := clickhouse.NewClient(ch.URL, ch.Database, ch.Username, ch.Password, ch.Timeout)
+	if err != nil {
+		return err
+	}
+	if err := client.Ping(ctx); err != nil {
+		return fmt.Errorf("%s unreachable: %w", client.Addr(), err)
+	}
+	return nil
+}
+
+// checkCohereKey asks a Cohere-compatible endpoint whether key is valid.
+func checkCohereKey(ctx context.Context, client *http.Client, baseURL, key string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(baseURL, "/")+"/v1/check-api-key", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+key)
+	return expectAuthorized(client, req)
+}
+
+// checkGeminiKey lists the models, which needs nothing but a valid key.
+func checkGeminiKey(ctx context.Context, client *http.Client, g config.GeminiConfig) error {
+	base := g.BaseURL
+	if base == "" {
+		base = gemini.DefaultBaseURL
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(base, "/")+"/v1beta/models?key="+url.QueryEscape(g.APIKey), nil)
+	if err != nil {
+		return err
+	}
+	return expectAuthorized(client, req)
+}
+
+// checkBearerKey lists the models of an OpenAI-compatible API.
+func checkBearerKey(ctx context.Context, client *http.Client, base, key string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(base, "/")+"/v1/models", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+key)
+	return expectAuthorized(client, req)
+}
+
+func expectAuthorized(client *http.Client, req *http.Request) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		// The URL carries the key for Gemini
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("%s unreachable: %w", req.URL.Host, err)
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusBadRequest:
+		return fmt.Errorf("API key rejected by %s (%d)", req.URL.Host, resp.StatusCode)
+	case resp.StatusCode >= 300:
+		return fmt.Errorf("%s returned %d", req.URL.Host, resp.StatusCode)
+	}
+	return nil
+}
+
+// checkReachable succeeds when the server answers at all; local servers
+// take no credentials.
+func checkReachable(ctx context.Context, client *http.Client, baseURL string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
@@ -0,0 +1,47 @@
+package privacy
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Store interface for decoupling
+type Store interface {
+	PurgeAccessLog(cutoff time.Time) (int64, error)
+}
+
+// AccessLogPruner deletes access log entries once they exceed their retention.
+type AccessLogPruner struct {
+	store     Store
+	retention time.Duration
+	interval  time.Duration
+}
+
+func NewAccessLogPruner(st Store, retention time.Duration) *AccessLogPruner {
+	return &AccessLogPruner{store: st, retention: retention, interval: time.Hour}
+}
+
+// Start prunes immediately and then every hour. A zero retention keeps entries forever.
+func (p *AccessLogPruner) Start(ctx context.Context) {
+	if p.retention <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+		for {
+			n, err := p.store.PurgeAccessLog(time.Now().Add(-p.retention))
+			if err != nil {
+				log.Printf("Access log pruning failed: %v", err)
+			} else if n > 0 {
+				log.Printf("Pruned %d access log entries", n)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
@@ -0,0 +1,64 @@
+package prompts
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/soroushbar/vantage/internal/store"
+)
+
+// Validate checks that a template body parses.
+func Validate(body string) error {
+	_, err := parse(body)
+	return err
+}
+
+// Render substitutes variables into the template body. Missing variables are an error.
+func Render(t *store.PromptTemplate, vars map[string]string) (string, error) {
+	tmpl, err := parse(t.Body)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, vars); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// BuildRequest wraps a rendered prompt in the request shape of the template's target endpoint.
+func BuildRequest(t *store.PromptTemplate, prompt string) ([]byte, error) {
+	payload := map[string]interface{}{}
+	if t.Model != "" {
+		payload["model"] = t.Model
+	}
+
+	switch {
+	case strings.HasPrefix(t.Path, "/v2/chat"):
+		payload["messages"] = []map[string]string{{"role": "user", "content": prompt}}
+	case strings.HasPrefix(t.Path, "/v1/chat"):
+		payload["message"] = prompt
+	default:
+		return nil, fmt.Errorf("unsupported template path %q", t.Path)
+	}
+	return json.Marshal(payload)
+}
+
+// Allowed reports whether userID may invoke the template. An empty list allows everyone.
+func Allowed(t *store.PromptTemplate, userID string) bool {
+	if len(t.AllowedUsers) == 0 {
+		return true
+	}
+	for _, u := range t.AllowedUsers {
+		if u == userID {
+			return true
+		}
+	}
+	return false
+}
+
+func parse(body string) (*template.Template, error) {
+	return template.New("prompt").Option("missingkey=error").Parse(body)
+}
@@ -0,0 +1,27 @@
+package prompts
+
+import (
+	"math/rand"
+
+	"github.com/soroushbar/vantage/internal/store"
+)
+
+// PickVersion chooses a version from a weighted traffic split, or 0 when there is none.
+func PickVersion(splits []store.TemplateSplit) int {
+	total := 0
+	for _, sp := range splits {
+		total += sp.Weight
+	}
+	if total <= 0 {
+		return 0
+	}
+
+	n := rand.Intn(total)
+	for _, sp := range splits {
+		if n < sp.Weight {
+			return sp.Version
+		}
+		n -= sp.Weight
+	}
+	return 0
+}
@@ -0,0 +1,241 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/soroushbar/vantage/internal/config"
+	"github.com/soroushbar/vantage/internal/notify"
+	"github.com/soroushbar/vantage/internal/telemetry"
+)
+
+// Statuspage indicators in increasing order of severity.
+var indicatorLevels = map[string]float64{
+	"none":     0,
+	"minor":    1,
+	"major":    2,
+	"critical": 3,
+}
+
+// Status is the last known health of an upstream provider.
+type Status struct {
+	Provider    string    `json:"provider"`
+	Indicator   string    `json:"indicator"`
+	Description string    `json:"description"`
+	Incident    string    `json:"incident,omitempty"`
+	Source      string    `json:"source"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Degraded reports whether the provider has a declared incident.
+func (s Status) Degraded() bool {
+	return s.Indicator != "" && s.Indicator != "none"
+}
+
+// Notifier receives provider outage and recovery events.
+type Notifier interface {
+	Publish(e notify.Event)
+}
+
+// StatusMonitor polls provider status pages and accepts webhook updates.
+type StatusMonitor struct {
+	cfg      config.StatusConfig
+	client   *http.Client
+	notifier Notifier
+
+	mu       sync.RWMutex
+	statuses map[string]Status
+}
+
+func NewStatusMonitor(cfg config.StatusConfig) *StatusMonitor {
+	m := &StatusMonitor{
+		cfg:      cfg,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		statuses: make(map[string]Status),
+	}
+	for name := range cfg.Pages {
+		m.statuses[name] = Status{Provider: name, Indicator: "none", Source: "default"}
+	}
+	return m
+}
+
+// Start polls every configured status page on the configured interval.
+func (m *StatusMonitor) Start(ctx context.Context) {
+	if m.cfg.Interval <= 0 || len(m.cfg.Pages) == 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(m.cfg.Interval)
+		defer ticker.Stop()
+		for {
+			for name, url := range m.cfg.Pages {
+				if err := m.poll(ctx, name, url); err != nil {
+					log.Printf("Status poll failed for %s: %v", name, err)
+				}
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// poll fetches an Atlassian Statuspage-compatible /api/v2/status.json document.
+func (m *StatusMonitor) poll(ctx context.Context, name, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	var page struct {
+		Status struct {
+			Indicator   string `json:"indicator"`
+			Description string `json:"description"`
+		} `json:"status"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		return err
+	}
+	m.Set(Status{
+		Provider:    name,
+		Indicator:   page.Status.Indicator,
+		Description: page.Status.Description,
+		Source:      "poll",
+	})
+	return nil
+}
+
+// HandleWebhook applies a Statuspage webhook payload to the named provider.
+func (m *StatusMonitor) HandleWebhook(name string, payload []byte) error {
+	var event struct {
+		Page struct {
+			StatusIndicator   string `json:"status_indicator"`
+			StatusDescription string `json:"status_description"`
+		} `json:"page"`
+		Incident *struct {
+			Name   string `json:"name"`
+			Status string `json:"status"`
+			Impact string `json:"impact"`
+		} `json:"incident"`
+	}
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return err
+	}
+
+	status := Status{
+		Provider:    name,
+		Indicator:   event.Page.StatusIndicator,
+		Description: event.Page.StatusDescription,
+		Source:      "webhook",
+	}
+	if event.Incident != nil {
+		status.Incident = event.Incident.Name
+		if status.Indicator == "" {
+			status.Indicator = event.Incident.Impact
+			if event.Incident.Status == "resolved" || event.Incident.Status == "postmortem" {
+				status.Indicator = "none"
+			}
+		}
+		if status.Description == "" {
+			status.Description = event.Incident.Status
+		}
+	}
+	if _, ok := indicatorLevels[status.Indicator]; !ok {
+		return fmt.Errorf("unknown status indicator %q", status.Indicator)
+	}
+	m.Set(status)
+	return nil
+}
+
+// SetNotifier publishes an event whenever a provider becomes degraded or
+// recovers.
+func (m *StatusMonitor) SetNotifier(n Notifier) {
+	m.notifier = n
+}
+
+// Set records a provider status and updates the status gauge.
+func (m *StatusMonitor) Set(status Status) {
+	status.UpdatedAt = time.Now()
+
+	m.mu.Lock()
+	prev := m.statuses[status.Provider]
+	m.statuses[status.Provider] = status
+	m.mu.Unlock()
+
+	telemetry.ProviderStatus.WithLabelValues(status.Provider).Set(indicatorLevels[status.Indicator])
+	if prev.Indicator != status.Indicator {
+		log.Printf("Provider %s status changed: %s -> %s (%s)", status.Provider, prev.Indicator, status.Indicator, status.Description)
+	}
+	if m.notifier != nil && prev.Degraded() != status.Degraded() {
+		m.notifier.Publish(statusEvent(status))
+	}
+}
+
+// statusEvent reports a provider becoming degraded or recovering. Minor
+// incidents are warnings; major and critical ones page.
+func statusEvent(status Status) notify.Event {
+	details := map[string]interface{}{
+		"provider":    status.Provider,
+		"indicator":   status.Indicator,
+		"description": status.Description,
+		"incident":    status.Incident,
+	}
+	if !status.Degraded() {
+		return notify.NewEvent(notify.EventProviderRecovered, "", "", details)
+	}
+	e := notify.NewEvent(notify.EventProviderOutage, "", "", details)
+	if status.Indicator == "minor" {
+		e.Severity = notify.SeverityWarning
+	}
+	return e
+}
+
+// Statuses returns a snapshot of all known provider statuses.
+func (m *StatusMonitor) Statuses() []Status {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	out := make([]Status, 0, len(m.statuses))
+	for _, s := range m.statuses {
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Provider < out[j].Provider })
+	return out
+}
+
+// RoutingWeight scales a provider's routing weight down while it has a declared
+// incident, when weight shifting is enabled. The upstream pool weighs its
+// targets with it.
+func (m *StatusMonitor) RoutingWeight(name string, weight float64) float64 {
+	if !m.cfg.ShiftWeights {
+		return weight
+	}
+	m.mu.RLock()
+	status, ok := m.statuses[name]
+	m.mu.RUnlock()
+	if ok && status.Degraded() {
+		return weight * m.cfg.DegradedWeight
+	}
+	return weight
+}
+
+// WebhookToken returns the shared token webhook callers must present.
+func (m *StatusMonitor) WebhookToken() string {
+	return m.cfg.WebhookToken
+}
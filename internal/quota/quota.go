@@ -0,0 +1,134 @@
+package quota
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/soroushbar/vantage/internal/config"
+	"github.com/soroushbar/vantage/internal/store"
+	"github.com/soroushbar/vantage/pkg/middleware"
+)
+
+// Store interface for decoupling
+type Store interface {
+	ConsumeQuota(userID, day string, limit int) (int, bool, error)
+	QuotaUsageForDay(day string) ([]store.QuotaUsage, error)
+	PurgeQuotas(before string) (int64, error)
+}
+
+const dayLayout = "2006-01-02"
+
+// Allowance is a user's quota position for the current day.
+type Allowance struct {
+	UserID    string    `json:"user_id"`
+	Limit     int       `json:"limit"`
+	Used      int       `json:"used"`
+	Remaining int       `json:"remaining"`
+	Unlimited bool      `json:"unlimited,omitempty"`
+	ResetsAt  time.Time `json:"resets_at"`
+}
+
+// Enforcer applies per-user daily request quotas backed by the store.
+type Enforcer struct {
+	store        Store
+	defaultLimit int
+	users        map[string]int
+
+	mu        sync.Mutex
+	lastPurge string
+}
+
+func NewEnforcer(cfg config.QuotaConfig, st Store) *Enforcer {
+	return &Enforcer{store: st, defaultLimit: cfg.RequestsPerDay, users: cfg.Users}
+}
+
+// Limit returns the daily request limit for userID; 0 means unlimited.
+func (e *Enforcer) Limit(userID string) int {
+	if limit, ok := e.users[userID]; ok {
+		return limit
+	}
+	return e.defaultLimit
+}
+
+// Allow counts a request against the user's quota for today. Store errors
+// let the request through so a database problem does not take down the proxy.
+func (e *Enforcer) Allow(userID string) middleware.RateLimitState {
+	now := time.Now().UTC()
+	day, reset := today(now)
+
+	limit := e.Limit(userID)
+	state := middleware.RateLimitState{Allowed: true, Limit: limit, Reset: reset.Sub(now), Window: 24 * time.Hour}
+	if limit <= 0 {
+		return state
+	}
+
+	e.purge(day)
+	count, ok, err := e.store.ConsumeQuota(userID, day, limit)
+	if err != nil {
+		log.Printf("Quota check failed for %s: %v", userID, err)
+		state.Remaining = limit
+		return state
+	}
+	state.Allowed = ok
+	state.Remaining = limit - count
+	return state
+}
+
+// Usage lists today's allowance for every user who has made requests or
+// has an explicit limit, sorted by user. A non-empty userID restricts the
+// result to that user.
+func (e *Enforcer) Usage(userID string) ([]Allowance, error) {
+	day, reset := today(time.Now().UTC())
+	usage, err := e.store.QuotaUsageForDay(day)
+	if err != nil {
+		return nil, err
+	}
+
+	used := make(map[string]int, len(usage))
+	for _, u := range usage {
+		used[u.UserID] = u.Count
+	}
+	for user := range e.users {
+		if _, ok := used[user]; !ok {
+			used[user] = 0
+		}
+	}
+	if userID != "" {
+		used = map[string]int{userID: used[userID]}
+	}
+
+	allowances := make([]Allowance, 0, len(used))
+	for user, count := range used {
+		a := Allowance{UserID: user, Limit: e.Limit(user), Used: count, ResetsAt: reset}
+		if a.Limit > 0 {
+			a.Remaining = max(a.Limit-count, 0)
+		} else {
+			a.Unlimited = true
+		}
+		allowances = append(allowances, a)
+	}
+	sort.Slice(allowances, func(i, j int) bool { return allowances[i].UserID < allowances[j].UserID })
+	return allowances, nil
+}
+
+// purge drops counters from previous days, once per day.
+func (e *Enforcer) purge(day string) {
+	e.mu.Lock()
+	stale := e.lastPurge != day
+	e.lastPurge = day
+	e.mu.Unlock()
+	if !stale {
+		return
+	}
+	if _, err := e.store.PurgeQuotas(day); err != nil {
+		log.Printf("Quota cleanup failed: %v", err)
+	}
+}
+
+// today returns the current UTC day and the moment it ends.
+func today(now time.Time) (string, time.Time) {
+	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	return start.Format(dayLayout), start.Add(24 * time.Hour)
+}
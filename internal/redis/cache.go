@@ -0,0 +1,52 @@
+package redis
+
+import (
+	"encoding/json"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/soroushbar/vantage/internal/telemetry"
+	"github.com/soroushbar/vantage/pkg/middleware"
+)
+
+// Cache is a middleware.ResponseCache in Redis. Entries expire on the
+// server after their TTL; unlike MemoryCache there is no entry bound, so
+// size the server with maxmemory. Errors read as misses.
+type Cache struct {
+	client *Client
+}
+
+func NewCache(client *Client) *Cache {
+	return &Cache{client: client}
+}
+
+func (c *Cache) Get(key string) (*middleware.CachedResponse, bool) {
+	reply, err := c.client.Do("GET", c.client.Key("cache", key))
+	if err != nil {
+		telemetry.RedisErrorsTotal.WithLabelValues("cache").Inc()
+		log.Printf("Cache read failed: %v", err)
+		return nil, false
+	}
+	data, ok := reply.(string)
+	if !ok {
+		return nil, false
+	}
+	var resp middleware.CachedResponse
+	if err := json.Unmarshal([]byte(data), &resp); err != nil {
+		return nil, false
+	}
+	return &resp, true
+}
+
+func (c *Cache) Set(key string, resp *middleware.CachedResponse, ttl time.Duration) {
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return
+	}
+	_, err = c.client.Do("SET", c.client.Key("cache", key), string(data), "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	if err != nil {
+		telemetry.RedisErrorsTotal.WithLabelValues("cache").Inc()
+		log.Printf("Cache write failed: %v", err)
+	}
+}
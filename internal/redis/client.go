@@ -0,0 +1,269 @@
+// Package redis keeps rate limit windows, daily quotas and cached responses
+// in Redis, so that every instance of a horizontally scaled gateway enforces
+// the same limits. It speaks just enough of the RESP protocol for that over
+// a small pool of connections.
+package redis
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Error is an error reply from the server, e.g. a failing script.
+type Error string
+
+func (e Error) Error() string {
+	return "redis: " + string(e)
+}
+
+// Client runs commands against one Redis server. It is safe for concurrent
+// use; connections are dialed on demand and up to poolSize idle ones kept.
+type Client struct {
+	addr     string
+	username string
+	password string
+	db       int
+	tls      *tls.Config
+	prefix   string
+	timeout  time.Duration
+	idle     chan *conn
+}
+
+// NewClient parses a redis:// or rediss:// (TLS) URL with optional
+// credentials and database number, e.g. redis://:secret@cache:6379/2. Keys
+// are namespaced by prefix. Nothing is dialed until the first command.
+func NewClient(rawURL, prefix string, timeout time.Duration, poolSize int) (*Client, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("redis url: %w", err)
+	}
+	c := &Client{addr: u.Host, prefix: prefix, timeout: timeout, idle: make(chan *conn, poolSize)}
+	switch u.Scheme {
+	case "redis":
+	case "rediss":
+		c.tls = &tls.Config{ServerName: u.Hostname()}
+	default:
+		return nil, fmt.Errorf("redis url: scheme %q must be redis or rediss", u.Scheme)
+	}
+	if u.Port() == "" {
+		c.addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if u.User != nil {
+		c.username = u.User.Username()
+		c.password, _ = u.User.Password()
+	}
+	if db := strings.Trim(u.Path, "/"); db != "" {
+		if c.db, err = strconv.Atoi(db); err != nil {
+			return nil, fmt.Errorf("redis url: database %q is not a number", db)
+		}
+	}
+	return c, nil
+}
+
+// Addr returns the host and port of the server.
+func (c *Client) Addr() string {
+	return c.addr
+}
+
+// Key joins parts into a key under the client's prefix.
+func (c *Client) Key(parts ...string) string {
+	return c.prefix + strings.Join(parts, ":")
+}
+
+// Ping checks that the server is reachable and accepts the credentials.
+func (c *Client) Ping(ctx context.Context) error {
+	done := make(chan error, 1)
+	go func() {
+		_, err := c.Do("PING")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Do runs one command and returns its reply: a string, an int64, nil or a
+// []interface{} of those. Error replies are returned as Error.
+func (c *Client) Do(args ...string) (interface{}, error) {
+	cn, err := c.get()
+	if err != nil {
+		return nil, err
+	}
+	reply, err := cn.do(c.timeout, args)
+	if err != nil {
+		// The connection may hold half a reply
+		cn.Close()
+		return nil, err
+	}
+	c.put(cn)
+	if e, ok := reply.(Error); ok {
+		return nil, e
+	}
+	return reply, nil
+}
+
+// Close closes the idle connections.
+func (c *Client) Close() error {
+	for {
+		select {
+		case cn := <-c.idle:
+			cn.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+func (c *Client) get() (*conn, error) {
+	select {
+	case cn := <-c.idle:
+		return cn, nil
+	default:
+		return c.dial()
+	}
+}
+
+func (c *Client) put(cn *conn) {
+	select {
+	case c.idle <- cn:
+	default:
+		cn.Close()
+	}
+}
+
+func (c *Client) dial() (*conn, error) {
+	d := &net.Dialer{Timeout: c.timeout}
+	var nc net.Conn
+	var err error
+	if c.tls != nil {
+		nc, err = tls.DialWithDialer(d, "tcp", c.addr, c.tls)
+	} else {
+		nc, err = d.Dial("tcp", c.addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	cn := &conn{Conn: nc, r: bufio.NewReader(nc), w: bufio.NewWriter(nc)}
+
+	var setup [][]string
+	switch {
+	case c.username != "" && c.password != "":
+		setup = append(setup, []string{"AUTH", c.username, c.password})
+	case c.password != "":
+		setup = append(setup, []string{"AUTH", c.password})
+	}
+	if c.db != 0 {
+		setup = append(setup, []string{"SELECT", strconv.Itoa(c.db)})
+	}
+	for _, args := range setup {
+		reply, err := cn.do(c.timeout, args)
+		if err == nil {
+			if e, ok := reply.(Error); ok {
+				err = e
+			}
+		}
+		if err != nil {
+			cn.Close()
+			return nil, fmt.Errorf("redis %s: %w", strings.ToLower(args[0]), err)
+		}
+	}
+	return cn, nil
+}
+
+type conn struct {
+	net.Conn
+	r *bufio.Reader
+	w *bufio.Writer
+}
+
+func (cn *conn) do(timeout time.Duration, args []string) (interface{}, error) {
+	cn.SetDeadline(time.Now().Add(timeout))
+	fmt.Fprintf(cn.w, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(cn.w, "$%d\r\n%s\r\n", len(a), a)
+	}
+	if err := cn.w.Flush(); err != nil {
+		return nil, err
+	}
+	return cn.readReply()
+}
+
+var errProtocol = errors.New("redis: malformed reply")
+
+func (cn *conn) readReply() (interface{}, error) {
+	line, err := cn.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, errProtocol
+	}
+	line = line[:len(line)-2]
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return Error(line[1:]), nil
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, errProtocol
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(cn.r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, errProtocol
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			if items[i], err = cn.readReply(); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, errProtocol
+}
+
+// ints converts an array reply of integers.
+func ints(reply interface{}, n int) ([]int64, error) {
+	items, ok := reply.([]interface{})
+	if !ok || len(items) != n {
+		return nil, fmt.Errorf("redis: unexpected reply %v", reply)
+	}
+	out := make([]int64, n)
+	for i, item := range items {
+		v, ok := item.(int64)
+		if !ok {
+			return nil, fmt.Errorf("redis: unexpected reply %v", reply)
+		}
+		out[i] = v
+	}
+	return out, nil
+}
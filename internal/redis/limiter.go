@@ -0,0 +1,78 @@
+package redis
+
+import (
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/soroushbar/vantage/internal/telemetry"
+	"github.com/soroushbar/vantage/pkg/middleware"
+)
+
+// windowScript counts a request in a fixed window unless the limit is
+// reached. It returns the count, the milliseconds left in the window and
+// whether the request was counted.
+const windowScript = `
+local count = tonumber(redis.call('GET', KEYS[1]) or '0')
+local ttl = redis.call('PTTL', KEYS[1])
+if count >= tonumber(ARGV[1]) then
+	return {count, ttl, 0}
+end
+count = redis.call('INCR', KEYS[1])
+if ttl < 0 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+	ttl = tonumber(ARGV[2])
+end
+return {count, ttl, 1}
+`
+
+// Limiter is a fixed-window middleware.RateLimiter with its windows in
+// Redis. Requests are let through while Redis cannot be reached, so an
+// outage does not take down the proxy.
+type Limiter struct {
+	client *Client
+
+	mu     sync.RWMutex
+	limit  int
+	window time.Duration
+}
+
+func NewLimiter(client *Client, limit int, window time.Duration) *Limiter {
+	return &Limiter{client: client, limit: limit, window: window}
+}
+
+// SetLimit changes the allowance. Windows already open in Redis keep their
+// count and expiry.
+func (l *Limiter) SetLimit(limit int, window time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.limit = limit
+	l.window = window
+}
+
+func (l *Limiter) Allow(key string) middleware.RateLimitState {
+	l.mu.RLock()
+	limit, window := l.limit, l.window
+	l.mu.RUnlock()
+
+	state := middleware.RateLimitState{Limit: limit, Window: window}
+	reply, err := l.client.Do("EVAL", windowScript, "1", l.client.Key("ratelimit", key),
+		strconv.Itoa(limit), strconv.FormatInt(window.Milliseconds(), 10))
+	var v []int64
+	if err == nil {
+		v, err = ints(reply, 3)
+	}
+	if err != nil {
+		telemetry.RedisErrorsTotal.WithLabelValues("rate_limit").Inc()
+		log.Printf("Rate limit check failed for %s: %v", key, err)
+		state.Allowed = true
+		state.Remaining = limit
+		state.Reset = window
+		return state
+	}
+	state.Allowed = v[2] == 1
+	state.Remaining = limit - int(v[0])
+	state.Reset = time.Duration(v[1]) * time.Millisecond
+	return state
+}
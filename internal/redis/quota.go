@@ -0,0 +1,83 @@
+package redis
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/soroushbar/vantage/internal/store"
+	"github.com/soroushbar/vantage/internal/telemetry"
+)
+
+// quotaScript counts a request for a user in the hash of a day unless the
+// user reached the limit. It returns the count and whether it was counted.
+const quotaScript = `
+local count = tonumber(redis.call('HGET', KEYS[1], ARGV[1]) or '0')
+if count >= tonumber(ARGV[2]) then
+	return {count, 0}
+end
+count = redis.call('HINCRBY', KEYS[1], ARGV[1], 1)
+redis.call('EXPIRE', KEYS[1], ARGV[3])
+return {count, 1}
+`
+
+// quotaRetention is how long a day's counters are kept, in seconds; the
+// enforcer only reads the current day.
+const quotaRetention = 2 * 24 * 60 * 60
+
+// QuotaStore keeps the daily request counts of quota.Enforcer in Redis,
+// one hash per day. Days expire on their own, so PurgeQuotas has nothing
+// to do.
+type QuotaStore struct {
+	client *Client
+}
+
+func NewQuotaStore(client *Client) *QuotaStore {
+	return &QuotaStore{client: client}
+}
+
+// ConsumeQuota counts one request for userID on day unless the user has
+// already made limit requests that day, like store.Store.ConsumeQuota.
+func (q *QuotaStore) ConsumeQuota(userID, day string, limit int) (int, bool, error) {
+	reply, err := q.client.Do("EVAL", quotaScript, "1", q.client.Key("quota", day),
+		userID, strconv.Itoa(limit), strconv.Itoa(quotaRetention))
+	var v []int64
+	if err == nil {
+		v, err = ints(reply, 2)
+	}
+	if err != nil {
+		telemetry.RedisErrorsTotal.WithLabelValues("quota").Inc()
+		return 0, false, err
+	}
+	return int(v[0]), v[1] == 1, nil
+}
+
+// QuotaUsageForDay returns every user's request count on day.
+func (q *QuotaStore) QuotaUsageForDay(day string) ([]store.QuotaUsage, error) {
+	reply, err := q.client.Do("HGETALL", q.client.Key("quota", day))
+	if err != nil {
+		telemetry.RedisErrorsTotal.WithLabelValues("quota").Inc()
+		return nil, err
+	}
+	items, ok := reply.([]interface{})
+	if !ok || len(items)%2 != 0 {
+		return nil, fmt.Errorf("redis: unexpected reply %v", reply)
+	}
+	usage := make([]store.QuotaUsage, 0, len(items)/2)
+	for i := 0; i < len(items); i += 2 {
+		user, _ := items[i].(string)
+		count, _ := items[i+1].(string)
+		n, err := strconv.Atoi(count)
+		if err != nil {
+			return nil, fmt.Errorf("redis: quota count %q of %s: %w", count, user, err)
+		}
+		usage = append(usage, store.QuotaUsage{UserID: user, Day: day, Count: n})
+	}
+	sort.Slice(usage, func(i, j int) bool { return usage[i].UserID < usage[j].UserID })
+	return usage, nil
+}
+
+// PurgeQuotas is a no-op: days expire after quotaRetention.
+func (q *QuotaStore) PurgeQuotas(before string) (int64, error) {
+	return 0, nil
+}
@@ -0,0 +1,193 @@
+// Package replay compares the response of a replayed interaction with the
+// original, so regressions show up as numbers and changed fields instead
+// of two raw JSON bodies.
+package replay
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxWords bounds the texts compared by TextSimilarity; longer texts are
+// compared by their first maxWords words.
+const maxWords = 2000
+
+// maxChanges caps the field changes reported in a Diff.
+const maxChanges = 100
+
+// volatileFields differ on every call and are left out of the field diff.
+var volatileFields = map[string]bool{"id": true, "generation_id": true, "response_id": true}
+
+// Response is one side of a comparison.
+type Response struct {
+	StatusCode int
+	Body       []byte
+	Tokens     int
+	LatencyMs  int64
+}
+
+// Diff is the structural comparison of an original and a replayed response.
+// Deltas are replay minus original.
+type Diff struct {
+	StatusChanged bool `json:"status_changed"`
+	// TextSimilarity is 1 for identical generated text and 0 for no words
+	// in common
+	TextSimilarity float64       `json:"text_similarity"`
+	TokenDelta     int           `json:"token_delta"`
+	LatencyDeltaMs int64         `json:"latency_delta_ms"`
+	Changes        []FieldChange `json:"changes"`
+	// Truncated is set when more than maxChanges fields changed
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// FieldChange is a JSON leaf that differs between the responses; a side is
+// omitted when the field is missing from it.
+type FieldChange struct {
+	Path     string          `json:"path"`
+	Original json.RawMessage `json:"original,omitempty"`
+	Replay   json.RawMessage `json:"replay,omitempty"`
+}
+
+// Compare diffs a replayed response against the original.
+func Compare(original, replayed Response) Diff {
+	d := Diff{
+		StatusChanged:  original.StatusCode != replayed.StatusCode,
+		TextSimilarity: TextSimilarity(ResponseText(original.Body), ResponseText(replayed.Body)),
+		TokenDelta:     replayed.Tokens - original.Tokens,
+		LatencyDeltaMs: replayed.LatencyMs - original.LatencyMs,
+		Changes:        []FieldChange{},
+	}
+
+	before, after := leaves(original.Body), leaves(replayed.Body)
+	paths := make([]string, 0, len(before)+len(after))
+	for p := range before {
+		paths = append(paths, p)
+	}
+	for p := range after {
+		if _, ok := before[p]; !ok {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		if string(before[p]) == string(after[p]) {
+			continue
+		}
+		if len(d.Changes) == maxChanges {
+			d.Truncated = true
+			break
+		}
+		d.Changes = append(d.Changes, FieldChange{Path: p, Original: before[p], Replay: after[p]})
+	}
+	return d
+}
+
+// ResponseText returns the generated text of a chat response: "text" in
+// Cohere v1 and the text blocks of "message.content" in v2. Other bodies
+// are compared as-is.
+func ResponseText(body []byte) string {
+	var resp struct {
+		Text    *string `json:"text"`
+		Message *struct {
+			Content []struct {
+				Type string `json:"type"`
+				Text string `json:"text"`
+			} `json:"content"`
+		} `json:"message"`
+	}
+	if json.Unmarshal(body, &resp) != nil {
+		return string(body)
+	}
+	switch {
+	case resp.Text != nil:
+		return *resp.Text
+	case resp.Message != nil:
+		var parts []string
+		for _, c := range resp.Message.Content {
+			if c.Type == "text" {
+				parts = append(parts, c.Text)
+			}
+		}
+		return strings.Join(parts, "\n")
+	}
+	return string(body)
+}
+
+// TextSimilarity is the share of words the texts have in common, in order:
+// twice the length of their longest common word subsequence over the total
+// number of words.
+func TextSimilarity(a, b string) float64 {
+	x, y := words(a), words(b)
+	if len(x)+len(y) == 0 {
+		return 1
+	}
+	prev := make([]int, len(y)+1)
+	cur := make([]int, len(y)+1)
+	for i := range x {
+		for j := range y {
+			switch {
+			case x[i] == y[j]:
+				cur[j+1] = prev[j] + 1
+			case prev[j+1] >= cur[j]:
+				cur[j+1] = prev[j+1]
+			default:
+				cur[j+1] = cur[j]
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return 2 * float64(prev[len(y)]) / float64(len(x)+len(y))
+}
+
+func words(s string) []string {
+	w := strings.Fields(s)
+	if len(w) > maxWords {
+		w = w[:maxWords]
+	}
+	return w
+}
+
+// leaves flattens a JSON body into its scalar values keyed by path, such as
+// "message.content[0].text". Bodies that are not JSON are a single leaf at
+// the root.
+func leaves(body []byte) map[string]json.RawMessage {
+	out := map[string]json.RawMessage{}
+	var v interface{}
+	if len(body) == 0 {
+		return out
+	}
+	if json.Unmarshal(body, &v) != nil {
+		raw, _ := json.Marshal(string(body))
+		out["$"] = raw
+		return out
+	}
+	flatten("", v, out)
+	return out
+}
+
+func flatten(path string, v interface{}, out map[string]json.RawMessage) {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, child := range t {
+			if path == "" && volatileFields[k] {
+				continue
+			}
+			p := k
+			if path != "" {
+				p = path + "." + k
+			}
+			flatten(p, child, out)
+		}
+	case []interface{}:
+		for i, child := range t {
+			flatten(path+"["+strconv.Itoa(i)+"]", child, out)
+		}
+	default:
+		if path == "" {
+			path = "$"
+		}
+		out[path], _ = json.Marshal(t)
+	}
+}
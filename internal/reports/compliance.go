@@ -0,0 +1,264 @@
+package reports
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html/template"
+	"log"
+	"time"
+
+	"github.com/soroushbar/vantage/internal/config"
+	"github.com/soroushbar/vantage/internal/store"
+)
+
+// ComplianceStore provides the data of compliance reports.
+type ComplianceStore interface {
+	Summary(from, to time.Time) (store.UsageSummary, error)
+	TopViolators(from, to time.Time, limit int) ([]store.UsageTotals, error)
+	TopSpenders(from, to time.Time, limit int) ([]store.UsageTotals, error)
+	TriageCounts(from, to time.Time) (bySeverity, byStatus map[string]int, err error)
+}
+
+// ComplianceReport summarizes a week or month of traffic for auditors:
+// volume, blocks, redactions, flagged interactions by severity and triage
+// status, the users blocked most often and cost.
+type ComplianceReport struct {
+	Period       string              `json:"period"`
+	From         time.Time           `json:"from"`
+	To           time.Time           `json:"to"`
+	GeneratedAt  time.Time           `json:"generated_at"`
+	Totals       store.UsageTotals   `json:"totals"`
+	P95LatencyMs int64               `json:"p95_latency_ms"`
+	Flagged      map[string]int      `json:"flagged"`
+	Triage       map[string]int      `json:"triage"`
+	TopViolators []store.UsageTotals `json:"top_violators"`
+	TopSpenders  []store.UsageTotals `json:"top_spenders"`
+	TopModels    []store.UsageTotals `json:"top_models"`
+}
+
+// PeriodBounds returns the last complete week (Monday to Monday) or month
+// before now, in now's location.
+func PeriodBounds(period string, now time.Time) (from, to time.Time) {
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	if period == "monthly" {
+		to = midnight.AddDate(0, 0, 1-midnight.Day())
+		return to.AddDate(0, -1, 0), to
+	}
+	to = midnight.AddDate(0, 0, -(int(midnight.Weekday())+6)%7)
+	return to.AddDate(0, 0, -7), to
+}
+
+// BuildCompliance builds the report of [from, to), listing up to top
+// violators and spenders.
+func BuildCompliance(st ComplianceStore, period string, from, to time.Time, top int) (*ComplianceReport, error) {
+	summary, err := st.Summary(from, to)
+	if err != nil {
+		return nil, err
+	}
+	report := &ComplianceReport{
+		Period:       period,
+		From:         from,
+		To:           to,
+		GeneratedAt:  time.Now().In(from.Location()),
+		Totals:       summary.Totals,
+		P95LatencyMs: summary.P95LatencyMs,
+		TopModels:    summary.TopModels,
+	}
+	if report.TopViolators, err = st.TopViolators(from, to, top); err != nil {
+		return nil, err
+	}
+	if report.TopSpenders, err = st.TopSpenders(from, to, top); err != nil {
+		return nil, err
+	}
+	if report.Flagged, report.Triage, err = st.TriageCounts(from, to); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+var complianceTemplate = template.Must(template.New("compliance").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>{{.Title}}</title>
+<style>body{font-family:sans-serif;color:#222}table{border-collapse:collapse;margin-bottom:1.5em}th,td{border:1px solid #ccc;padding:4px 10px;text-align:left}th{background:#f3f3f3}</style>
+</head><body>
+<h1>{{.Title}}</h1>
+<p>{{.Range}}, generated {{.GeneratedAt.Format "2006-01-02 15:04 MST"}}.</p>
+{{range .Sections}}<h2>{{.Heading}}</h2>
+{{if .Rows}}<table>{{if .Columns}}<tr>{{range .Columns}}<th>{{.}}</th>{{end}}</tr>{{end}}
+{{range .Rows}}<tr>{{range .}}<td>{{.}}</td>{{end}}</tr>
+{{end}}</table>{{else}}<p>None.</p>{{end}}
+{{end}}</body></html>
+`))
+
+// complianceSection is one table of a rendered report.
+type complianceSection struct {
+	Heading string
+	Columns []string
+	Rows    [][]string
+}
+
+func (r *ComplianceReport) title() string {
+	if r.Period == "" {
+		return "Vantage compliance report"
+	}
+	return fmt.Sprintf("Vantage %s compliance report", r.Period)
+}
+
+func (r *ComplianceReport) dateRange() string {
+	return fmt.Sprintf("%s to %s", r.From.Format("2006-01-02 15:04"), r.To.Format("2006-01-02 15:04 MST"))
+}
+
+// sections lays the report out as the tables both renderings share.
+func (r *ComplianceReport) sections() []complianceSection {
+	t := r.Totals
+	sections := []complianceSection{{
+		Heading: "Overview",
+		Rows: [][]string{
+			{"Requests", fmt.Sprint(t.Requests)},
+			{"Blocked", fmt.Sprint(t.Blocked)},
+			{"Redacted", fmt.Sprint(t.Redacted)},
+			{"Tokens", fmt.Sprint(t.Tokens)},
+			{"Estimated cost", fmt.Sprintf("$%.2f", t.Cost)},
+			{"p95 latency", fmt.Sprintf("%d ms", r.P95LatencyMs)},
+		},
+	}}
+
+	flagged := complianceSection{Heading: "Flagged interactions", Columns: []string{"Severity or status", "Count"}}
+	for _, severity := range []string{"critical", "warning", "info"} {
+		if n := r.Flagged[severity]; n > 0 {
+			flagged.Rows = append(flagged.Rows, []string{severity, fmt.Sprint(n)})
+		}
+	}
+	for _, status := range store.TriageStatuses {
+		if n := r.Triage[status]; n > 0 {
+			flagged.Rows = append(flagged.Rows, []string{status, fmt.Sprint(n)})
+		}
+	}
+	sections = append(sections, flagged)
+
+	users := func(heading string, totals []store.UsageTotals) complianceSection {
+		s := complianceSection{Heading: heading, Columns: []string{"User", "Requests", "Blocked", "Redacted", "Estimated cost"}}
+		for _, u := range totals {
+			s.Rows = append(s.Rows, []string{u.Key, fmt.Sprint(u.Requests), fmt.Sprint(u.Blocked), fmt.Sprint(u.Redacted), fmt.Sprintf("$%.2f", u.Cost)})
+		}
+		return s
+	}
+	sections = append(sections, users("Top violators", r.TopViolators), users("Top spenders", r.TopSpenders))
+
+	models := complianceSection{Heading: "Top models", Columns: []string{"Model", "Requests", "Tokens", "Estimated cost"}}
+	for _, m := range r.TopModels {
+		models.Rows = append(models.Rows, []string{m.Key, fmt.Sprint(m.Requests), fmt.Sprint(m.Tokens), fmt.Sprintf("$%.2f", m.Cost)})
+	}
+	return append(sections, models)
+}
+
+// HTML renders the report as a standalone HTML page.
+func (r *ComplianceReport) HTML() ([]byte, error) {
+	var b bytes.Buffer
+	err := complianceTemplate.Execute(&b, map[string]interface{}{
+		"Title":       r.title(),
+		"Range":       r.dateRange(),
+		"GeneratedAt": r.GeneratedAt,
+		"Sections":    r.sections(),
+	})
+	return b.Bytes(), err
+}
+
+// PDF renders the report as a plain-text PDF document.
+func (r *ComplianceReport) PDF() []byte {
+	lines := []string{r.dateRange() + ", generated " + r.GeneratedAt.Format("2006-01-02 15:04 MST"), ""}
+	for _, s := range r.sections() {
+		lines = append(lines, "## "+s.Heading)
+		if len(s.Rows) == 0 {
+			lines = append(lines, "None.")
+		}
+		if len(s.Rows) > 0 && len(s.Columns) > 0 {
+			lines = append(lines, columns(s.Columns))
+		}
+		for _, row := range s.Rows {
+			lines = append(lines, columns(row))
+		}
+		lines = append(lines, "")
+	}
+	return textPDF(r.title(), lines)
+}
+
+// columns pads cells into fixed-width columns for the monospaced PDF.
+func columns(cells []string) string {
+	line := ""
+	for i, c := range cells {
+		if i == 0 {
+			line += fmt.Sprintf("%-28s", c)
+			continue
+		}
+		line += fmt.Sprintf("%16s", c)
+	}
+	return line
+}
+
+// ComplianceMailer mails the compliance report of each week or month once
+// it has ended.
+type ComplianceMailer struct {
+	store    ComplianceStore
+	cfg      config.ComplianceReportConfig
+	smtp     config.SMTPConfig
+	loc      *time.Location
+	interval time.Duration
+	lastSent time.Time
+}
+
+// NewComplianceMailer returns a mailer for cfg, which must have passed
+// config validation.
+func NewComplianceMailer(st ComplianceStore, cfg config.ComplianceReportConfig, smtp config.SMTPConfig) *ComplianceMailer {
+	loc, _ := time.LoadLocation(cfg.Timezone)
+	return &ComplianceMailer{store: st, cfg: cfg, smtp: smtp, loc: loc, interval: 5 * time.Minute}
+}
+
+// Start checks every few minutes whether a period has ended and mails its
+// report. The period that ended before startup is not sent, so restarts do
+// not repeat reports.
+func (m *ComplianceMailer) Start(ctx context.Context) {
+	_, m.lastSent = PeriodBounds(m.cfg.Schedule, time.Now().In(m.loc))
+	go func() {
+		ticker := time.NewTicker(m.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			from, to := PeriodBounds(m.cfg.Schedule, time.Now().In(m.loc))
+			if !to.After(m.lastSent) {
+				continue
+			}
+			m.lastSent = to
+			if err := m.Send(from, to); err != nil {
+				log.Printf("Compliance report for %s failed: %v", from.Format(time.DateOnly), err)
+			}
+		}
+	}()
+}
+
+// Send builds the report of [from, to) and mails it to the recipients.
+func (m *ComplianceMailer) Send(from, to time.Time) error {
+	report, err := BuildCompliance(m.store, m.cfg.Schedule, from, to, m.cfg.TopN)
+	if err != nil {
+		return err
+	}
+	html, err := report.HTML()
+	if err != nil {
+		return err
+	}
+	var pdf []byte
+	if m.cfg.AttachPDF {
+		pdf = report.PDF()
+	}
+	subject := fmt.Sprintf("%s: %s", report.title(), report.dateRange())
+	filename := fmt.Sprintf("vantage-compliance-%s.pdf", from.Format(time.DateOnly))
+	if err := sendReport(m.smtp, m.cfg.Recipients, subject, html, filename, pdf); err != nil {
+		return err
+	}
+	log.Printf("Compliance report for %s mailed to %d recipients", from.Format(time.DateOnly), len(m.cfg.Recipients))
+	return nil
+}
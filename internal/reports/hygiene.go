@@ -0,0 +1,140 @@
+package reports
+
+import (
+	"context"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/soroushbar/vantage/internal/config"
+	"github.com/soroushbar/vantage/internal/store"
+)
+
+// Store interface for decoupling
+type Store interface {
+	IdleCallers(cutoff time.Time) ([]store.CallerActivity, error)
+	ModelsUsedSince(since time.Time) (map[string]bool, error)
+}
+
+// ModelLister provides the models that are currently allowed.
+type ModelLister interface {
+	Approved() []string
+}
+
+// Suggestion is a recommended hygiene action for an idle caller or unused model.
+type Suggestion struct {
+	Kind   string `json:"kind"`
+	Target string `json:"target"`
+	Action string `json:"action"`
+	Reason string `json:"reason"`
+}
+
+// HygieneReport lists callers and models that have gone unused.
+type HygieneReport struct {
+	GeneratedAt  time.Time              `json:"generated_at"`
+	IdleDays     int                    `json:"idle_days"`
+	IdleCallers  []store.CallerActivity `json:"idle_callers"`
+	UnusedModels []string               `json:"unused_models"`
+	Suggestions  []Suggestion           `json:"suggestions"`
+}
+
+// Reporter periodically builds hygiene reports and keeps the latest in memory.
+type Reporter struct {
+	store    Store
+	models   ModelLister
+	idleDays int
+	interval time.Duration
+
+	mu     sync.RWMutex
+	latest *HygieneReport
+}
+
+func NewReporter(st Store, models ModelLister, cfg *config.Config) *Reporter {
+	return &Reporter{
+		store:    st,
+		models:   models,
+		idleDays: cfg.Reports.IdleDays,
+		interval: cfg.Reports.Interval,
+	}
+}
+
+// Start generates a report immediately and then on every interval.
+func (r *Reporter) Start(ctx context.Context) {
+	if r.interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		for {
+			if _, err := r.Generate(); err != nil {
+				log.Printf("Hygiene report failed: %v", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Latest returns the most recently generated report, or nil if none exists yet.
+func (r *Reporter) Latest() *HygieneReport {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.latest
+}
+
+// Generate builds a fresh report and stores it as the latest one.
+func (r *Reporter) Generate() (*HygieneReport, error) {
+	now := time.Now()
+	cutoff := now.AddDate(0, 0, -r.idleDays)
+
+	idle, err := r.store.IdleCallers(cutoff)
+	if err != nil {
+		return nil, err
+	}
+	used, err := r.store.ModelsUsedSince(cutoff)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &HygieneReport{
+		GeneratedAt:  now,
+		IdleDays:     r.idleDays,
+		IdleCallers:  idle,
+		UnusedModels: []string{},
+		Suggestions:  []Suggestion{},
+	}
+	for _, c := range idle {
+		report.Suggestions = append(report.Suggestions, Suggestion{
+			Kind:   "caller",
+			Target: c.UserID,
+			Action: "revoke",
+			Reason: "no requests since " + c.LastSeen.Format(time.DateOnly),
+		})
+	}
+	for _, m := range r.models.Approved() {
+		if !used[m] {
+			report.UnusedModels = append(report.UnusedModels, m)
+		}
+	}
+	sort.Strings(report.UnusedModels)
+	for _, m := range report.UnusedModels {
+		report.Suggestions = append(report.Suggestions, Suggestion{
+			Kind:   "model",
+			Target: m,
+			Action: "remove_from_allowlist",
+			Reason: "allowed but not called in the reporting window",
+		})
+	}
+
+	r.mu.Lock()
+	r.latest = report
+	r.mu.Unlock()
+
+	log.Printf("Hygiene report: %d idle callers, %d unused models", len(idle), len(report.UnusedModels))
+	return report, nil
+}
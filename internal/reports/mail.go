@@ -0,0 +1,61 @@
+package reports
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/soroushbar/vantage/internal/config"
+)
+
+// sendReport mails an HTML report, with pdf attached as filename when it
+// is set, through the configured SMTP server.
+func sendReport(cfg config.SMTPConfig, to []string, subject string, html []byte, filename string, pdf []byte) error {
+	var auth smtp.Auth
+	if cfg.Username != "" {
+		host, _, _ := net.SplitHostPort(cfg.Addr)
+		auth = smtp.PlainAuth("", cfg.Username, os.Getenv(cfg.PasswordEnv), host)
+	}
+	return smtp.SendMail(cfg.Addr, auth, cfg.From, to, reportMessage(cfg.From, to, subject, html, filename, pdf))
+}
+
+// reportMessage builds the MIME message: the HTML alone, or a
+// multipart/mixed message of the HTML and the PDF attachment.
+func reportMessage(from string, to []string, subject string, html []byte, filename string, pdf []byte) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\nTo: %s\r\nSubject: %s\r\nDate: %s\r\nMIME-Version: 1.0\r\n",
+		from, strings.Join(to, ", "), mime.QEncoding.Encode("utf-8", subject), time.Now().Format(time.RFC1123Z))
+	if pdf == nil {
+		b.WriteString("Content-Type: text/html; charset=utf-8\r\nContent-Transfer-Encoding: base64\r\n\r\n")
+		writeBase64(&b, html)
+		return b.Bytes()
+	}
+
+	var nonce [12]byte
+	rand.Read(nonce[:])
+	boundary := "vantage-" + fmt.Sprintf("%x", nonce)
+	fmt.Fprintf(&b, "Content-Type: multipart/mixed; boundary=%q\r\n\r\n", boundary)
+	fmt.Fprintf(&b, "--%s\r\nContent-Type: text/html; charset=utf-8\r\nContent-Transfer-Encoding: base64\r\n\r\n", boundary)
+	writeBase64(&b, html)
+	fmt.Fprintf(&b, "--%s\r\nContent-Type: application/pdf\r\nContent-Disposition: attachment; filename=%q\r\nContent-Transfer-Encoding: base64\r\n\r\n", boundary, filename)
+	writeBase64(&b, pdf)
+	fmt.Fprintf(&b, "--%s--\r\n", boundary)
+	return b.Bytes()
+}
+
+// writeBase64 writes data base64-encoded in 76-character lines.
+func writeBase64(b *bytes.Buffer, data []byte) {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 76 {
+		b.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	b.WriteString(encoded + "\r\n")
+}
@@ -0,0 +1,57 @@
+package routing
+
+import (
+	"github.com/soroushbar/vantage/internal/config"
+)
+
+// ModelStates reports the registry state of a model.
+type ModelStates interface {
+	CheckModel(name string) (allowed bool, state string)
+}
+
+// Router rewrites requested models according to the configured rules. Rules
+// are evaluated in order and the first match wins.
+type Router struct {
+	rules  []config.RoutingRule
+	tiers  map[string]string
+	models ModelStates
+}
+
+func NewRouter(cfg config.RoutingConfig, models ModelStates) *Router {
+	r := &Router{rules: cfg.Rules, tiers: make(map[string]string), models: models}
+	for tier, users := range cfg.Tiers {
+		for _, u := range users {
+			r.tiers[u] = tier
+		}
+	}
+	return r
+}
+
+// Route returns the model a request should be sent to and the name of the
+// rule that chose it. ok is false when the requested model is kept.
+func (r *Router) Route(userID, model string) (target, rule string, ok bool) {
+	for _, rl := range r.rules {
+		if rl.Model != "" && rl.Model != "*" && rl.Model != model {
+			continue
+		}
+		if rl.Tier != "" && r.tiers[userID] != rl.Tier {
+			continue
+		}
+		if rl.WhenDeprecated && !r.deprecated(model) {
+			continue
+		}
+		if rl.Target == "" || rl.Target == model {
+			continue
+		}
+		return rl.Target, rl.Name, true
+	}
+	return model, "", false
+}
+
+func (r *Router) deprecated(model string) bool {
+	if r.models == nil {
+		return false
+	}
+	_, state := r.models.CheckModel(model)
+	return state == "deprecated" || state == "sunset"
+}
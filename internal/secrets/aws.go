@@ -0,0 +1,86 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+)
+
+// secretsManager reads secrets with the AWS Secrets Manager
+// GetSecretValue API, signed with credentials from the default AWS chain.
+type secretsManager struct {
+	aws    aws.Config
+	client *http.Client
+}
+
+func newSecretsManager(ctx context.Context, region string) (*secretsManager, error) {
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx, awsconfig.WithRegion(region))
+	if err != nil {
+		return nil, fmt.Errorf("secrets: load AWS config: %w", err)
+	}
+	if awsCfg.Region == "" {
+		return nil, errors.New("secrets: no AWS region configured")
+	}
+	return &secretsManager{aws: awsCfg, client: &http.Client{Timeout: 10 * time.Second}}, nil
+}
+
+// read returns the fields of a JSON object secret, or the whole
+// SecretString as the field "" when it is not one.
+func (s *secretsManager) read(ctx context.Context, id string) (map[string]string, error) {
+	creds, err := s.aws.Credentials.Retrieve(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("AWS credentials unavailable: %w", err)
+	}
+
+	body, _ := json.Marshal(map[string]string{"SecretId": id})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://secretsmanager."+s.aws.Region+".amazonaws.com/", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	hash := sha256.Sum256(body)
+	if err := v4.NewSigner().SignHTTP(ctx, creds, req, hex.EncodeToString(hash[:]), "secretsmanager", s.aws.Region, time.Now()); err != nil {
+		return nil, err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var result struct {
+		SecretString string `json:"SecretString"`
+		Message      string `json:"message"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("GetSecretValue %s: %s", id, resp.Status)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GetSecretValue %s: %s: %s", id, resp.Status, result.Message)
+	}
+
+	var object map[string]interface{}
+	if json.Unmarshal([]byte(result.SecretString), &object) != nil {
+		return map[string]string{"": result.SecretString}, nil
+	}
+	fields := map[string]string{}
+	for k, v := range object {
+		if str, ok := v.(string); ok {
+			fields[k] = str
+		} else {
+			fields[k] = fmt.Sprint(v)
+		}
+	}
+	return fields, nil
+}
@@ -0,0 +1,208 @@
+// Package secrets reads the upstream API keys from HashiCorp Vault or AWS
+// Secrets Manager and keeps them current: keys are fetched at startup and
+// again on every refresh, and callers read the latest value on each use, so
+// a key rotated in the secret store takes effect without a restart.
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/soroushbar/vantage/internal/config"
+	"github.com/soroushbar/vantage/internal/telemetry"
+)
+
+// Names of the secrets a Manager can hold.
+const (
+	Cohere  = "cohere"
+	Gemini  = "gemini"
+	Mistral = "mistral"
+	Groq    = "groq"
+)
+
+// backend reads one secret from a secret store.
+type backend interface {
+	// read returns the fields of the secret at path; a plain-text secret
+	// is returned as the single field "".
+	read(ctx context.Context, path string) (map[string]string, error)
+}
+
+// renewer is implemented by backends whose credentials expire unless
+// renewed.
+type renewer interface {
+	renew(ctx context.Context) error
+}
+
+// Manager holds the current value of each configured secret.
+type Manager struct {
+	backend backend
+	refs    map[string]string
+	refresh time.Duration
+
+	mu     sync.RWMutex
+	values map[string]string
+}
+
+// New returns a Manager for the references in cfg, or nil when cfg has no
+// source. Nothing is fetched until Load.
+func New(ctx context.Context, cfg config.SecretsConfig) (*Manager, error) {
+	var b backend
+	switch cfg.Source {
+	case "":
+		return nil, nil
+	case "vault":
+		addr := cfg.Vault.Address
+		if addr == "" {
+			addr = os.Getenv("VAULT_ADDR")
+		}
+		token := os.Getenv("VAULT_TOKEN")
+		if addr == "" || token == "" {
+			return nil, fmt.Errorf("secrets: vault needs an address (secrets.vault.address or VAULT_ADDR) and VAULT_TOKEN")
+		}
+		b = newVault(addr, token, cfg.Vault)
+	case "aws":
+		var err error
+		if b, err = newSecretsManager(ctx, cfg.AWS.Region); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("secrets: unknown source %q", cfg.Source)
+	}
+
+	refs := map[string]string{}
+	if cfg.CohereAPIKey != "" {
+		refs[Cohere] = cfg.CohereAPIKey
+	}
+	if cfg.GeminiAPIKey != "" {
+		refs[Gemini] = cfg.GeminiAPIKey
+	}
+	if cfg.MistralAPIKey != "" {
+		refs[Mistral] = cfg.MistralAPIKey
+	}
+	if cfg.GroqAPIKey != "" {
+		refs[Groq] = cfg.GroqAPIKey
+	}
+	return &Manager{backend: b, refs: refs, refresh: cfg.Refresh, values: map[string]string{}}, nil
+}
+
+// Has reports whether name is read from the secret store.
+func (m *Manager) Has(name string) bool {
+	if m == nil {
+		return false
+	}
+	_, ok := m.refs[name]
+	return ok
+}
+
+// Value returns the current value of name.
+func (m *Manager) Value(name string) string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.values[name]
+}
+
+// Source returns a function reading the current value of name, for
+// components that look the key up on every use.
+func (m *Manager) Source(name string) func() string {
+	return func() string { return m.Value(name) }
+}
+
+// Load fetches every secret, failing on the first that cannot be read.
+func (m *Manager) Load(ctx context.Context) error {
+	for _, name := range m.names() {
+		value, err := m.fetch(ctx, m.refs[name])
+		if err != nil {
+			return fmt.Errorf("secrets: %s: %w", name, err)
+		}
+		m.set(name, value)
+	}
+	return nil
+}
+
+// Start renews the backend's credentials and fetches every secret again
+// each refresh interval. A secret that cannot be read keeps its last
+// value.
+func (m *Manager) Start(ctx context.Context) {
+	if m.refresh <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(m.refresh)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				m.Refresh(ctx)
+			}
+		}
+	}()
+}
+
+// Refresh renews the backend's credentials and fetches every secret once.
+func (m *Manager) Refresh(ctx context.Context) {
+	if r, ok := m.backend.(renewer); ok {
+		if err := r.renew(ctx); err != nil {
+			telemetry.SecretRefreshErrorsTotal.WithLabelValues("renew").Inc()
+			log.Printf("Failed to renew secret store token: %v", err)
+		}
+	}
+	for _, name := range m.names() {
+		value, err := m.fetch(ctx, m.refs[name])
+		if err != nil {
+			telemetry.SecretRefreshErrorsTotal.WithLabelValues(name).Inc()
+			log.Printf("Failed to refresh secret %s, keeping the current value: %v", name, err)
+			continue
+		}
+		if m.set(name, value) {
+			log.Printf("Secret %s changed; using the new value", name)
+		}
+	}
+}
+
+// fetch reads ref, "path#field", from the backend.
+func (m *Manager) fetch(ctx context.Context, ref string) (string, error) {
+	path, field, _ := strings.Cut(ref, "#")
+	fields, err := m.backend.read(ctx, path)
+	if err != nil {
+		return "", err
+	}
+	v, ok := fields[field]
+	if field == "" && !ok {
+		if len(fields) > 1 {
+			return "", fmt.Errorf("%s has several fields; name one as %s#field", path, path)
+		}
+		for _, only := range fields {
+			v = only
+		}
+	}
+	if v == "" {
+		return "", fmt.Errorf("%s has no field %q", path, field)
+	}
+	return v, nil
+}
+
+// set stores value and reports whether it replaced a different one.
+func (m *Manager) set(name, value string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	old, ok := m.values[name]
+	m.values[name] = value
+	return ok && old != value
+}
+
+func (m *Manager) names() []string {
+	names := make([]string, 0, len(m.refs))
+	for name := range m.refs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
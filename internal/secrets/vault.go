@@ -0,0 +1,89 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/soroushbar/vantage/internal/config"
+)
+
+// vault reads secrets from a KV version 2 engine over Vault's HTTP API.
+type vault struct {
+	addr      string
+	token     string
+	mount     string
+	namespace string
+	renewal   bool
+	client    *http.Client
+}
+
+func newVault(addr, token string, cfg config.VaultSecretsConfig) *vault {
+	return &vault{
+		addr:      strings.TrimSuffix(addr, "/"),
+		token:     token,
+		mount:     strings.Trim(cfg.Mount, "/"),
+		namespace: cfg.Namespace,
+		renewal:   cfg.RenewToken,
+		client:    &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (v *vault) read(ctx context.Context, path string) (map[string]string, error) {
+	var body struct {
+		Data struct {
+			Data map[string]interface{} `json:"data"`
+		} `json:"data"`
+	}
+	if err := v.do(ctx, http.MethodGet, "/v1/"+v.mount+"/data/"+strings.Trim(path, "/"), &body); err != nil {
+		return nil, err
+	}
+	fields := map[string]string{}
+	for k, val := range body.Data.Data {
+		if s, ok := val.(string); ok {
+			fields[k] = s
+		} else {
+			fields[k] = fmt.Sprint(val)
+		}
+	}
+	return fields, nil
+}
+
+// renew extends the token's TTL, when renewal is on.
+func (v *vault) renew(ctx context.Context) error {
+	if !v.renewal {
+		return nil
+	}
+	return v.do(ctx, http.MethodPost, "/v1/auth/token/renew-self", nil)
+}
+
+func (v *vault) do(ctx context.Context, method, path string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, method, v.addr+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Vault-Token", v.token)
+	if v.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.namespace)
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Errors []string `json:"errors"`
+		}
+		json.NewDecoder(io.LimitReader(resp.Body, 4096)).Decode(&e)
+		return fmt.Errorf("vault %s: %s", path, strings.TrimSpace(resp.Status+" "+strings.Join(e.Errors, "; ")))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,206 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/soroushbar/vantage/internal/config"
+	"github.com/soroushbar/vantage/internal/notify"
+	pkgmiddleware "github.com/soroushbar/vantage/pkg/middleware"
+)
+
+// Notifier receives security events raised by the HTTP layer.
+type Notifier interface {
+	Publish(e notify.Event)
+}
+
+type adminContextKey struct{}
+
+// adminName returns the name of the admin token that authenticated the request, if any.
+func adminName(ctx context.Context) string {
+	name, _ := ctx.Value(adminContextKey{}).(string)
+	return name
+}
+
+// adminGuard authenticates /api requests with bearer tokens, rate limits
+// them per client IP and locks out IPs after repeated failed attempts.
+type adminGuard struct {
+	tokens        map[string]string
+	limiter       pkgmiddleware.RateLimiter
+	maxFailures   int
+	failureWindow time.Duration
+	lockout       time.Duration
+	notifier      Notifier
+	signatures    *pkgmiddleware.SignatureVerifier
+
+	mu       sync.Mutex
+	failures map[string][]time.Time
+	locked   map[string]time.Time
+}
+
+func newAdminGuard(cfg config.AdminConfig, notifier Notifier, signatures *pkgmiddleware.SignatureVerifier) *adminGuard {
+	return &adminGuard{
+		tokens:        cfg.Tokens,
+		limiter:       pkgmiddleware.NewWindowLimiter(cfg.RateLimit.Requests, cfg.RateLimit.Window),
+		maxFailures:   cfg.MaxFailures,
+		failureWindow: cfg.FailureWindow,
+		lockout:       cfg.Lockout,
+		notifier:      notifier,
+		signatures:    signatures,
+		failures:      make(map[string][]time.Time),
+		locked:        make(map[string]time.Time),
+	}
+}
+
+// adminDenial describes why an admin request was refused.
+type adminDenial struct {
+	status     int
+	code       string
+	message    string
+	retryAfter time.Duration
+}
+
+// check applies lockout, the per-IP rate limit and authentication, in that
+// order, and returns the authenticated admin name. The rate limiter state is
+// returned so HTTP callers can expose it.
+func (g *adminGuard) check(ip, path string, authenticate func() (string, bool)) (string, pkgmiddleware.RateLimitState, *adminDenial) {
+	// 1. Locked out IPs are rejected before anything else
+	if until, ok := g.lockedUntil(ip); ok {
+		return "", pkgmiddleware.RateLimitState{}, &adminDenial{http.StatusTooManyRequests, "ADMIN_LOCKED", "Too many failed authentication attempts", time.Until(until)}
+	}
+
+	// 2. Per-IP rate limit
+	state := g.limiter.Allow(ip)
+	if !state.Allowed {
+		return "", state, &adminDenial{http.StatusTooManyRequests, "RATE_LIMITED", "Rate limit exceeded", state.Reset}
+	}
+
+	// 3. Authentication; without configured tokens the admin API stays open
+	if len(g.tokens) == 0 {
+		return "", state, nil
+	}
+	name, ok := authenticate()
+	if !ok {
+		g.recordFailure(ip, path)
+		return "", state, &adminDenial{http.StatusUnauthorized, "UNAUTHORIZED", "Invalid or missing admin credentials", 0}
+	}
+	g.clearFailures(ip)
+	return name, state, nil
+}
+
+func (g *adminGuard) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name, state, denial := g.check(clientIP(r), r.URL.Path, func() (string, bool) {
+			if pkgmiddleware.IsSigned(r) {
+				return g.verifySignature(r)
+			}
+			return g.authenticate(r.Header.Get("Authorization"))
+		})
+		if state.Limit > 0 {
+			pkgmiddleware.SetRateLimitHeaders(w.Header(), state)
+		}
+		if denial != nil {
+			if denial.retryAfter > 0 {
+				w.Header().Set("Retry-After", strconv.Itoa(int(denial.retryAfter.Seconds())+1))
+			}
+			if denial.status == http.StatusUnauthorized {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="vantage-admin"`)
+			}
+			writeJSONError(w, denial.status, denial.message, denial.code)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), adminContextKey{}, name)))
+	})
+}
+
+// authenticate compares the bearer token against every configured token in
+// constant time.
+func (g *adminGuard) authenticate(header string) (string, bool) {
+	token, ok := strings.CutPrefix(header, "Bearer ")
+	if !ok || token == "" {
+		return "", false
+	}
+	matched := ""
+	for name, t := range g.tokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1 {
+			matched = name
+		}
+	}
+	return matched, matched != ""
+}
+
+// verifySignature authenticates an HMAC-signed service request as
+// "service:<name>".
+func (g *adminGuard) verifySignature(r *http.Request) (string, bool) {
+	if g.signatures == nil {
+		return "", false
+	}
+	service, err := g.signatures.Verify(r)
+	if err != nil {
+		log.Printf("Admin API: rejected signed request from %s: %v", clientIP(r), err)
+		return "", false
+	}
+	return "service:" + service, true
+}
+
+func (g *adminGuard) lockedUntil(ip string) (time.Time, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	until, ok := g.locked[ip]
+	if ok && time.Now().After(until) {
+		delete(g.locked, ip)
+		return time.Time{}, false
+	}
+	return until, ok
+}
+
+func (g *adminGuard) recordFailure(ip, path string) {
+	now := time.Now()
+
+	g.mu.Lock()
+	recent := []time.Time{now}
+	for _, t := range g.failures[ip] {
+		if now.Sub(t) < g.failureWindow {
+			recent = append(recent, t)
+		}
+	}
+	lock := len(recent) >= g.maxFailures
+	if lock {
+		g.locked[ip] = now.Add(g.lockout)
+		delete(g.failures, ip)
+	} else {
+		g.failures[ip] = recent
+	}
+	g.mu.Unlock()
+
+	if !lock {
+		return
+	}
+	log.Printf("Admin API: locked out %s for %s after %d failed authentication attempts", ip, g.lockout, len(recent))
+	if g.notifier != nil {
+		g.notifier.Publish(notify.NewEvent(notify.EventAdminLockout, "", path, map[string]interface{}{
+			"ip":       ip,
+			"failures": len(recent),
+			"lockout":  g.lockout.String(),
+		}))
+	}
+}
+
+func (g *adminGuard) clearFailures(ip string) {
+	g.mu.Lock()
+	delete(g.failures, ip)
+	g.mu.Unlock()
+}
+
+// clientIP is the address lockouts and the rate limit are keyed on: the
+// connection's peer, or the client a trusted proxy forwarded the request
+// for, as resolved by the router's RealIPMiddleware.
+func clientIP(r *http.Request) string {
+	return pkgmiddleware.RemoteIP(r)
+}
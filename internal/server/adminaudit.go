@@ -0,0 +1,188 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/soroushbar/vantage/internal/store"
+	pkgmiddleware "github.com/soroushbar/vantage/pkg/middleware"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// maxAuditedBody is how much of a request body the admin audit trail keeps.
+const maxAuditedBody = 64 << 10
+
+type adminChangeKey struct{}
+
+// adminChange is the state before and after a change, as its handler
+// reports it with recordChange.
+type adminChange struct {
+	before, after interface{}
+}
+
+// recordChange adds the state before and after a change to the request's
+// admin audit entry. Either may be nil, for creations and deletions.
+func recordChange(r *http.Request, before, after interface{}) {
+	if c, ok := r.Context().Value(adminChangeKey{}).(*adminChange); ok {
+		c.before, c.after = before, after
+	}
+}
+
+// auditedRequest reports whether an admin request goes into the admin
+// audit trail: every change, and log exports.
+func auditedRequest(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return strings.HasSuffix(r.URL.Path, "/logs/export")
+	}
+	return true
+}
+
+// auditAdmin writes admin changes and exports to the admin_audit table with
+// the calling admin, the route and its parameters, the status they were
+// answered with and what changed. Refused changes are recorded too.
+func (s *Server) auditAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !auditedRequest(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		var body []byte
+		if r.Body != nil {
+			body, _ = io.ReadAll(io.LimitReader(r.Body, maxAuditedBody))
+			r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), r.Body))
+		}
+		change := &adminChange{}
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r.WithContext(context.WithValue(r.Context(), adminChangeKey{}, change)))
+
+		rctx := chi.RouteContext(r.Context())
+		if rctx == nil || rctx.RoutePattern() == "" {
+			return
+		}
+		var target []string
+		for i, key := range rctx.URLParams.Keys {
+			if key != "*" {
+				target = append(target, key+"="+rctx.URLParams.Values[i])
+			}
+		}
+		diff := map[string]interface{}{}
+		if len(bytes.TrimSpace(body)) > 0 {
+			if json.Valid(body) {
+				diff["request"] = json.RawMessage(body)
+			} else {
+				diff["request"] = string(body)
+			}
+		}
+		if r.URL.RawQuery != "" {
+			diff["query"] = r.URL.RawQuery
+		}
+		if change.before != nil {
+			diff["before"] = change.before
+		}
+		if change.after != nil {
+			diff["after"] = change.after
+		}
+		code := ww.Status()
+		if code == 0 {
+			code = http.StatusOK
+		}
+		actor := adminName(r.Context())
+		if actor == "" {
+			actor = pkgmiddleware.UserID(r)
+		}
+		s.recordAdminAction(store.AdminAction{
+			Actor:      actor,
+			RemoteAddr: r.RemoteAddr,
+			Action:     r.Method + " " + strings.TrimPrefix(rctx.RoutePattern(), "/api"),
+			Target:     strings.Join(target, ","),
+			Status:     code,
+		}, diff)
+	})
+}
+
+// auditGRPC records a gRPC admin change with its request message.
+func (s *Server) auditGRPC(ctx context.Context, method string, req interface{}, err error) {
+	diff := map[string]interface{}{}
+	if m, ok := req.(proto.Message); ok {
+		if raw, merr := protojson.Marshal(m); merr == nil {
+			diff["request"] = json.RawMessage(raw)
+		}
+	}
+	s.recordAdminAction(store.AdminAction{
+		Actor:      adminName(ctx),
+		RemoteAddr: peerAddr(ctx),
+		Action:     method,
+		Status:     grpcHTTPStatus(status.Code(err)),
+	}, diff)
+}
+
+func (s *Server) recordAdminAction(a store.AdminAction, diff map[string]interface{}) {
+	a.At = time.Now()
+	if len(diff) > 0 {
+		a.Diff, _ = json.Marshal(diff)
+	}
+	if err := s.Store.RecordAdminAction(&a); err != nil {
+		log.Printf("Failed to record admin action %s by %s: %v", a.Action, a.Actor, err)
+	}
+}
+
+// grpcHTTPStatus maps the gRPC codes the admin service returns onto HTTP
+// statuses, so both APIs' entries read alike.
+func grpcHTTPStatus(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.InvalidArgument:
+		return http.StatusBadRequest
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.FailedPrecondition:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// handleListAdminActions lists the admin audit trail, newest first,
+// filtered by ?actor=, ?action= (a prefix such as "PUT /models"),
+// ?target=, ?from= and ?to=.
+func (s *Server) handleListAdminActions(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f := store.AdminAuditFilter{Actor: q.Get("actor"), Action: q.Get("action"), Target: q.Get("target")}
+	f.Limit, _ = strconv.Atoi(q.Get("limit"))
+	if f.Limit <= 0 {
+		f.Limit = 100
+	}
+	var err error
+	if f.From, err = statsTime(q.Get("from"), time.Time{}); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "from must be YYYY-MM-DD or RFC 3339", "BAD_REQUEST")
+		return
+	}
+	if f.To, err = statsTime(q.Get("to"), time.Time{}); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "to must be YYYY-MM-DD or RFC 3339", "BAD_REQUEST")
+		return
+	}
+	actions, err := s.Store.ListAdminActions(f)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(actions)
+}
@@ -0,0 +1,32 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/soroushbar/vantage/internal/store"
+)
+
+// handleListAlerts lists usage anomalies, newest first, optionally for one
+// ?user= or ?kind= (request_spike, token_spike, blocked_surge or
+// unusual_hour), up to ?limit= (default 100).
+func (s *Server) handleListAlerts(w http.ResponseWriter, r *http.Request) {
+	if s.Anomalies == nil {
+		writeJSONError(w, http.StatusNotFound, "Anomaly detection is not enabled", "ANOMALIES_DISABLED")
+		return
+	}
+	q := r.URL.Query()
+	f := store.AlertFilter{User: q.Get("user"), Kind: q.Get("kind")}
+	f.Limit, _ = strconv.Atoi(q.Get("limit"))
+	if f.Limit <= 0 {
+		f.Limit = 100
+	}
+	alerts, err := s.Anomalies.List(f)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(alerts)
+}
@@ -0,0 +1,45 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+
+	"gopkg.in/yaml.v3"
+)
+
+// handleGetConfig dumps the effective config, after overlays, environment
+// overrides and the runtime settings, with secrets masked. It is YAML in
+// the layout of config.yaml, or JSON with ?format=json.
+func (s *Server) handleGetConfig(w http.ResponseWriter, r *http.Request) {
+	cfg, err := s.runtimeConfig().Masked()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var b bytes.Buffer
+	enc := yaml.NewEncoder(&b)
+	enc.SetIndent(2)
+	if err := enc.Encode(cfg); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	raw := b.Bytes()
+
+	switch r.URL.Query().Get("format") {
+	case "", "yaml":
+		w.Header().Set("Content-Type", "application/yaml")
+		w.Write(raw)
+	case "json":
+		// Through YAML, so keys are the config file's
+		var doc interface{}
+		if err := yaml.Unmarshal(raw, &doc); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(doc)
+	default:
+		writeJSONError(w, http.StatusBadRequest, "format must be yaml or json", "BAD_REQUEST")
+	}
+}
@@ -0,0 +1,530 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/soroushbar/vantage/internal/prompts"
+	"github.com/soroushbar/vantage/internal/store"
+	adminv1 "github.com/soroushbar/vantage/proto/vantage/admin/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// NewGRPCServer exposes the admin surface over gRPC, guarded by the same
+// tokens, rate limit and lockout as the /api routes.
+func (s *Server) NewGRPCServer(opts ...grpc.ServerOption) *grpc.Server {
+	opts = append(opts, grpc.UnaryInterceptor(s.grpcAdminAuth))
+	gs := grpc.NewServer(opts...)
+	adminv1.RegisterAdminServiceServer(gs, &adminService{s: s})
+	return gs
+}
+
+func (s *Server) grpcAdminAuth(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	var authorization string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get("authorization"); len(v) > 0 {
+			authorization = v[0]
+		}
+	}
+	if !s.grpcIPAllowed(peerIP(ctx), info.FullMethod) {
+		return nil, status.Error(codes.PermissionDenied, "access from this network is not allowed")
+	}
+	name, _, denial := s.admin.check(peerIP(ctx), info.FullMethod, func() (string, bool) {
+		return s.admin.authenticate(authorization)
+	})
+	if denial != nil {
+		code := codes.ResourceExhausted
+		if denial.status == http.StatusUnauthorized {
+			code = codes.Unauthenticated
+		}
+		return nil, status.Error(code, denial.message)
+	}
+	ctx = context.WithValue(ctx, adminContextKey{}, name)
+	if !grpcMutations[info.FullMethod] {
+		return handler(ctx, req)
+	}
+	var resp interface{}
+	var err error
+	if s.ReadOnly() {
+		err = status.Error(codes.Unavailable, "changes are frozen while Vantage is in read-only mode")
+	} else {
+		resp, err = handler(ctx, req)
+	}
+	s.auditGRPC(ctx, info.FullMethod, req, err)
+	return resp, err
+}
+
+func peerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
+
+func peerAddr(ctx context.Context) string {
+	if p, ok := peer.FromContext(ctx); ok {
+		return p.Addr.String()
+	}
+	return ""
+}
+
+// grpcError maps store errors onto gRPC status codes.
+func grpcError(err error) error {
+	if errors.Is(err, store.ErrNotFound) {
+		return status.Error(codes.NotFound, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+type adminService struct {
+	adminv1.UnimplementedAdminServiceServer
+	s *Server
+}
+
+func (a *adminService) ListModels(ctx context.Context, req *adminv1.ListModelsRequest) (*adminv1.ListModelsResponse, error) {
+	resp := &adminv1.ListModelsResponse{}
+	for _, m := range a.s.Models.List() {
+		resp.Models = append(resp.Models, modelToProto(m))
+	}
+	return resp, nil
+}
+
+func (a *adminService) SetModelState(ctx context.Context, req *adminv1.SetModelStateRequest) (*adminv1.SetModelStateResponse, error) {
+	var sunset *time.Time
+	if req.SunsetAt != nil {
+		t := req.SunsetAt.AsTime()
+		sunset = &t
+	}
+	m, err := a.s.Models.Set(req.Name, req.State, req.Notes, sunset)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return &adminv1.SetModelStateResponse{Model: modelToProto(m)}, nil
+}
+
+func (a *adminService) ListTemplates(ctx context.Context, req *adminv1.ListTemplatesRequest) (*adminv1.ListTemplatesResponse, error) {
+	templates, err := a.s.Store.ListTemplates()
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	resp := &adminv1.ListTemplatesResponse{}
+	for i := range templates {
+		resp.Templates = append(resp.Templates, templateToProto(&templates[i]))
+	}
+	return resp, nil
+}
+
+func (a *adminService) GetTemplate(ctx context.Context, req *adminv1.GetTemplateRequest) (*adminv1.GetTemplateResponse, error) {
+	t, err := a.s.Store.GetTemplate(req.Name, int(req.Version))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &adminv1.GetTemplateResponse{Template: templateToProto(t)}, nil
+}
+
+func (a *adminService) SaveTemplate(ctx context.Context, req *adminv1.SaveTemplateRequest) (*adminv1.SaveTemplateResponse, error) {
+	t := store.PromptTemplate{Name: req.Name, Body: req.Body, Path: req.Path, Model: req.Model, AllowedUsers: req.AllowedUsers}
+	if t.Name == "" || t.Body == "" || t.Path == "" {
+		return nil, status.Error(codes.InvalidArgument, "name, body and path are required")
+	}
+	if err := prompts.Validate(t.Body); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if _, err := prompts.BuildRequest(&t, ""); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	saved, err := a.s.Store.SaveTemplate(t)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &adminv1.SaveTemplateResponse{Template: templateToProto(saved)}, nil
+}
+
+func (a *adminService) QueryLogs(ctx context.Context, req *adminv1.QueryLogsRequest) (*adminv1.QueryLogsResponse, error) {
+	f := store.LogFilter{
+		Limit:    int(req.Limit),
+		Metadata: req.Metadata,
+		User:     req.UserId,
+		Path:     req.Path,
+		Blocked:  req.Blocked,
+		Slow:     req.Slow,
+		Session:  req.SessionId,
+		Project:  req.Project,
+		Request:  req.RequestId,
+	}
+	if f.Limit <= 0 {
+		f.Limit = 50
+	}
+	if req.From != nil {
+		f.From = req.From.AsTime()
+	}
+	if req.To != nil {
+		f.To = req.To.AsTime()
+	}
+	logs, err := a.s.Store.GetLogs(f)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if req.IncludeBodies {
+		if err := a.s.recordAccess(adminName(ctx), peerAddr(ctx), "list", logs); err != nil {
+			return nil, grpcError(err)
+		}
+	}
+	resp := &adminv1.QueryLogsResponse{}
+	for i := range logs {
+		resp.Interactions = append(resp.Interactions, interactionToProto(&logs[i], req.IncludeBodies))
+	}
+	return resp, nil
+}
+
+func (a *adminService) GetLog(ctx context.Context, req *adminv1.GetLogRequest) (*adminv1.GetLogResponse, error) {
+	rec, err := a.s.Store.GetLog(int(req.Id))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	if rec.ArchiveKey != "" && a.s.Archive != nil {
+		if err := a.s.Archive.Restore(ctx, rec); err != nil {
+			return nil, status.Error(codes.Unavailable, err.Error())
+		}
+	}
+	if err := a.s.recordAccess(adminName(ctx), peerAddr(ctx), "view", []store.InteractionRecord{*rec}); err != nil {
+		return nil, grpcError(err)
+	}
+	return &adminv1.GetLogResponse{Interaction: interactionToProto(rec, true)}, nil
+}
+
+func (a *adminService) VerifyChain(ctx context.Context, req *adminv1.VerifyChainRequest) (*adminv1.VerifyChainResponse, error) {
+	report, err := a.s.Store.VerifyChain()
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &adminv1.VerifyChainResponse{Report: &adminv1.ChainReport{
+		Valid:         report.Valid,
+		Checked:       int32(report.Checked),
+		Unchained:     int32(report.Unchained),
+		FirstBrokenId: int32(report.FirstBrokenID),
+		Reason:        report.Reason,
+	}}, nil
+}
+
+func (a *adminService) ListAccessLog(ctx context.Context, req *adminv1.ListAccessLogRequest) (*adminv1.ListAccessLogResponse, error) {
+	f := store.AccessFilter{InteractionID: int(req.InteractionId), Viewer: req.Viewer, Limit: int(req.Limit)}
+	if f.Limit <= 0 {
+		f.Limit = 100
+	}
+	entries, err := a.s.Store.ListAccess(f)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	resp := &adminv1.ListAccessLogResponse{}
+	for _, e := range entries {
+		resp.Entries = append(resp.Entries, &adminv1.AccessEntry{
+			Id:            int32(e.ID),
+			Viewer:        e.Viewer,
+			RemoteAddr:    e.RemoteAddr,
+			InteractionId: int32(e.InteractionID),
+			Action:        e.Action,
+			AccessedAt:    timestamppb.New(e.AccessedAt),
+		})
+	}
+	return resp, nil
+}
+
+func (a *adminService) ListSigningKeys(ctx context.Context, req *adminv1.ListSigningKeysRequest) (*adminv1.ListSigningKeysResponse, error) {
+	keys, err := a.s.Store.ListSigningKeys()
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	resp := &adminv1.ListSigningKeysResponse{}
+	for i := range keys {
+		resp.Keys = append(resp.Keys, signingKeyToProto(&keys[i]))
+	}
+	return resp, nil
+}
+
+func (a *adminService) CreateSigningKey(ctx context.Context, req *adminv1.CreateSigningKeyRequest) (*adminv1.CreateSigningKeyResponse, error) {
+	if req.Name == "" {
+		return nil, status.Error(codes.InvalidArgument, "a service name is required")
+	}
+	key, err := a.s.createSigningKey(req.Name)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &adminv1.CreateSigningKeyResponse{Key: signingKeyToProto(key)}, nil
+}
+
+func (a *adminService) DeleteSigningKey(ctx context.Context, req *adminv1.DeleteSigningKeyRequest) (*adminv1.DeleteSigningKeyResponse, error) {
+	if err := a.s.Store.DeleteSigningKey(req.Name); err != nil {
+		return nil, grpcError(err)
+	}
+	return &adminv1.DeleteSigningKeyResponse{}, nil
+}
+
+func (a *adminService) ListVirtualKeys(ctx context.Context, req *adminv1.ListVirtualKeysRequest) (*adminv1.ListVirtualKeysResponse, error) {
+	if !validKeyStatus(req.Status) {
+		return nil, status.Error(codes.InvalidArgument, "status must be active, expired or revoked")
+	}
+	keys, err := a.s.Store.ListVirtualKeys(store.VirtualKeyFilter{User: req.UserId, Status: req.Status})
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	resp := &adminv1.ListVirtualKeysResponse{}
+	for i := range keys {
+		resp.Keys = append(resp.Keys, virtualKeyToProto(&keys[i]))
+	}
+	return resp, nil
+}
+
+func (a *adminService) GetVirtualKey(ctx context.Context, req *adminv1.GetVirtualKeyRequest) (*adminv1.GetVirtualKeyResponse, error) {
+	key, err := a.s.Store.GetVirtualKey(req.Id)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &adminv1.GetVirtualKeyResponse{Key: virtualKeyToProto(key)}, nil
+}
+
+func (a *adminService) CreateVirtualKey(ctx context.Context, req *adminv1.CreateVirtualKeyRequest) (*adminv1.CreateVirtualKeyResponse, error) {
+	if req.UserId == "" {
+		return nil, status.Error(codes.InvalidArgument, "a user_id is required")
+	}
+	var expiresAt *time.Time
+	if req.ExpiresAt != nil {
+		t := req.ExpiresAt.AsTime()
+		expiresAt = &t
+	}
+	key, err := a.s.createVirtualKey(req.UserId, req.Name, req.Project, expiresAt, req.ExpiresIn)
+	if err != nil {
+		return nil, keyError(err)
+	}
+	return &adminv1.CreateVirtualKeyResponse{Key: virtualKeyToProto(key)}, nil
+}
+
+func (a *adminService) RotateVirtualKey(ctx context.Context, req *adminv1.RotateVirtualKeyRequest) (*adminv1.RotateVirtualKeyResponse, error) {
+	key, err := a.s.rotateVirtualKey(req.Id, req.Grace)
+	if err != nil {
+		return nil, keyError(err)
+	}
+	return &adminv1.RotateVirtualKeyResponse{Key: virtualKeyToProto(key)}, nil
+}
+
+func (a *adminService) RevokeVirtualKey(ctx context.Context, req *adminv1.RevokeVirtualKeyRequest) (*adminv1.RevokeVirtualKeyResponse, error) {
+	if err := a.s.Store.RevokeVirtualKey(req.Id); err != nil {
+		return nil, grpcError(err)
+	}
+	return &adminv1.RevokeVirtualKeyResponse{}, nil
+}
+
+// keyError maps invalid key requests onto InvalidArgument.
+func keyError(err error) error {
+	var invalid keyRequestError
+	if errors.As(err, &invalid) {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	return grpcError(err)
+}
+
+func (a *adminService) GetStats(ctx context.Context, req *adminv1.GetStatsRequest) (*adminv1.GetStatsResponse, error) {
+	query := store.StatsQuery{
+		Granularity: req.Granularity,
+		GroupBy:     req.GroupBy,
+		User:        req.UserId,
+		Model:       req.Model,
+		Project:     req.Project,
+	}
+	if req.From != nil {
+		query.From = req.From.AsTime()
+	}
+	if req.To != nil {
+		query.To = req.To.AsTime()
+	}
+	if err := resolveStatsQuery(&query); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	stats, err := a.s.stats(query)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	resp := &adminv1.GetStatsResponse{
+		Granularity: query.Granularity,
+		From:        timestamppb.New(query.From),
+		To:          timestamppb.New(query.To),
+	}
+	for _, st := range stats {
+		resp.Series = append(resp.Series, &adminv1.UsageStat{
+			Period:       st.Period,
+			Totals:       usageTotalsToProto(st.UsageTotals),
+			AvgLatencyMs: st.AvgLatencyMs,
+		})
+	}
+	return resp, nil
+}
+
+func (a *adminService) GetSummary(ctx context.Context, req *adminv1.GetSummaryRequest) (*adminv1.GetSummaryResponse, error) {
+	from, err := summaryDay(req.Day)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	summary, err := a.s.Store.Summary(from, from.Add(24*time.Hour))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	out := &adminv1.UsageSummary{
+		From:         summary.From,
+		To:           summary.To,
+		Totals:       usageTotalsToProto(summary.Totals),
+		P95LatencyMs: summary.P95LatencyMs,
+	}
+	for _, u := range summary.TopUsers {
+		out.TopUsers = append(out.TopUsers, usageTotalsToProto(u))
+	}
+	for _, m := range summary.TopModels {
+		out.TopModels = append(out.TopModels, usageTotalsToProto(m))
+	}
+	return &adminv1.GetSummaryResponse{Summary: out}, nil
+}
+
+func (a *adminService) GetTemplateStats(ctx context.Context, req *adminv1.GetTemplateStatsRequest) (*adminv1.GetTemplateStatsResponse, error) {
+	stats, err := a.s.Store.TemplateStats(req.Name)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	resp := &adminv1.GetTemplateStatsResponse{}
+	for _, st := range stats {
+		resp.Versions = append(resp.Versions, &adminv1.TemplateVersionStats{
+			Version:       int32(st.Version),
+			Weight:        int32(st.Weight),
+			Requests:      int32(st.Requests),
+			Errors:        int32(st.Errors),
+			AvgLatencyMs:  st.AvgLatencyMs,
+			AvgTokens:     st.AvgTokens,
+			FeedbackCount: int32(st.FeedbackCount),
+			AvgFeedback:   st.AvgFeedback,
+		})
+	}
+	return resp, nil
+}
+
+func (a *adminService) GetIdleReport(ctx context.Context, req *adminv1.GetIdleReportRequest) (*adminv1.GetIdleReportResponse, error) {
+	report := a.s.Reporter.Latest()
+	if report == nil || req.Refresh {
+		var err error
+		if report, err = a.s.Reporter.Generate(); err != nil {
+			return nil, grpcError(err)
+		}
+	}
+	out := &adminv1.IdleReport{
+		GeneratedAt:  timestamppb.New(report.GeneratedAt),
+		IdleDays:     int32(report.IdleDays),
+		UnusedModels: report.UnusedModels,
+	}
+	for _, c := range report.IdleCallers {
+		out.IdleCallers = append(out.IdleCallers, &adminv1.CallerActivity{
+			UserId:   c.UserID,
+			LastSeen: timestamppb.New(c.LastSeen),
+			Requests: int32(c.Requests),
+		})
+	}
+	for _, sg := range report.Suggestions {
+		out.Suggestions = append(out.Suggestions, &adminv1.Suggestion{Kind: sg.Kind, Target: sg.Target, Action: sg.Action, Reason: sg.Reason})
+	}
+	return &adminv1.GetIdleReportResponse{Report: out}, nil
+}
+
+func modelToProto(m store.Model) *adminv1.Model {
+	out := &adminv1.Model{Name: m.Name, State: m.State, Notes: m.Notes, UpdatedAt: timestamppb.New(m.UpdatedAt)}
+	if m.SunsetAt != nil {
+		out.SunsetAt = timestamppb.New(*m.SunsetAt)
+	}
+	return out
+}
+
+func templateToProto(t *store.PromptTemplate) *adminv1.Template {
+	return &adminv1.Template{
+		Id:           int32(t.ID),
+		Name:         t.Name,
+		Version:      int32(t.Version),
+		Body:         t.Body,
+		Path:         t.Path,
+		Model:        t.Model,
+		AllowedUsers: t.AllowedUsers,
+		CreatedAt:    timestamppb.New(t.CreatedAt),
+	}
+}
+
+func signingKeyToProto(k *store.SigningKey) *adminv1.SigningKey {
+	return &adminv1.SigningKey{Name: k.Name, Secret: k.Secret, CreatedAt: timestamppb.New(k.CreatedAt)}
+}
+
+func virtualKeyToProto(k *store.VirtualKey) *adminv1.VirtualKey {
+	out := &adminv1.VirtualKey{
+		Id:        k.ID,
+		Prefix:    k.Prefix,
+		Key:       k.Key,
+		UserId:    k.UserID,
+		Name:      k.Name,
+		Project:   k.Project,
+		Status:    k.Status,
+		CreatedAt: timestamppb.New(k.CreatedAt),
+		RotatedTo: k.RotatedTo,
+	}
+	if k.ExpiresAt != nil {
+		out.ExpiresAt = timestamppb.New(*k.ExpiresAt)
+	}
+	if k.RevokedAt != nil {
+		out.RevokedAt = timestamppb.New(*k.RevokedAt)
+	}
+	if k.LastUsedAt != nil {
+		out.LastUsedAt = timestamppb.New(*k.LastUsedAt)
+	}
+	return out
+}
+
+func usageTotalsToProto(t store.UsageTotals) *adminv1.UsageTotals {
+	return &adminv1.UsageTotals{
+		Key:           t.Key,
+		Requests:      int32(t.Requests),
+		Tokens:        int32(t.Tokens),
+		EstimatedCost: t.Cost,
+		Blocked:       int32(t.Blocked),
+		Redacted:      int32(t.Redacted),
+	}
+}
+
+func interactionToProto(r *store.InteractionRecord, withBodies bool) *adminv1.Interaction {
+	out := &adminv1.Interaction{
+		Id:          int32(r.ID),
+		Timestamp:   timestamppb.New(r.Timestamp),
+		UserId:      r.UserID,
+		Method:      r.Method,
+		Path:        r.Path,
+		StatusCode:  int32(r.StatusCode),
+		LatencyMs:   r.LatencyMs,
+		Tokens:      int32(r.Tokens),
+		SafetyScore: r.SafetyScore,
+		IsBlocked:   r.IsBlocked,
+		IsRedacted:  r.IsRedacted,
+		Template:    r.Template,
+		CacheHit:    r.CacheHit,
+		Metadata:    string(r.Metadata),
+		Model:       r.Model,
+		RoutedModel: r.RoutedModel,
+	}
+	if withBodies {
+		out.RequestBody = r.RequestBody
+		out.ResponseBody = r.ResponseBody
+	}
+	return out
+}
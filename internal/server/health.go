@@ -0,0 +1,114 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/soroushbar/vantage/internal/telemetry"
+)
+
+// ComponentStatus is the health of one dependency checked by /health/ready.
+type ComponentStatus struct {
+	Status    string `json:"status"`
+	LatencyMs int64  `json:"latency_ms"`
+	Detail    string `json:"detail,omitempty"`
+}
+
+// ReadinessReport is the body returned by /health/ready.
+type ReadinessReport struct {
+	Status     string                     `json:"status"`
+	Components map[string]ComponentStatus `json:"components"`
+}
+
+// handleLive reports that the process is up and serving HTTP.
+func (s *Server) handleLive(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+// handleReady checks the store, the audit queue and optionally the upstream
+// provider, returning 503 if any of them is unhealthy.
+func (s *Server) handleReady(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), s.Config.Health.Timeout)
+	defer cancel()
+
+	report := ReadinessReport{Status: "ok", Components: map[string]ComponentStatus{}}
+
+	// 1. Store connectivity
+	report.Components["store"] = timed(func() error { return s.Store.Ping(ctx) })
+
+	// 2. Audit queue saturation and recent drops
+	queue := ComponentStatus{Status: "ok"}
+	if capacity := cap(s.auditChan); capacity > 0 {
+		used := float64(len(s.auditChan)) / float64(capacity)
+		queue.Detail = fmt.Sprintf("%d/%d queued, %d dropped", len(s.auditChan), capacity, s.auditDrops.Load())
+		if used >= s.Config.Health.QueueThreshold {
+			queue.Status = "fail"
+		}
+		if last := s.lastAuditDrop.Load(); last != 0 && time.Since(time.Unix(0, last)) < s.Config.Health.DropWindow {
+			queue.Status = "fail"
+		}
+	}
+	report.Components["audit_queue"] = queue
+
+	// 3. Upstream provider reachability
+	if s.Config.Health.ProbeUpstream {
+		report.Components["upstream"] = timed(func() error { return s.probeUpstream(ctx) })
+	}
+
+	code := http.StatusOK
+	for _, c := range report.Components {
+		if c.Status != "ok" {
+			report.Status = "fail"
+			code = http.StatusServiceUnavailable
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(report)
+}
+
+// auditOverflow counts the requests the audit middleware refused and the
+// records it dropped because the audit queue was full.
+func (s *Server) auditOverflow(outcome string) {
+	telemetry.AuditOverflowTotal.WithLabelValues(outcome).Inc()
+	if outcome == "dropped" {
+		s.auditDrops.Add(1)
+		s.lastAuditDrop.Store(time.Now().UnixNano())
+	}
+}
+
+// probeUpstream succeeds if the provider answers at all below a 5xx.
+func (s *Server) probeUpstream(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.upstreamURL.String()+"/v1/models", nil)
+	if err != nil {
+		return err
+	}
+	transport := s.Proxy.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 500 {
+		return fmt.Errorf("upstream returned %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func timed(check func() error) ComponentStatus {
+	start := time.Now()
+	err := check()
+	c := ComponentStatus{Status: "ok", LatencyMs: time.Since(start).Milliseconds()}
+	if err != nil {
+		c.Status = "fail"
+		c.Detail = err.Error()
+	}
+	return c
+}
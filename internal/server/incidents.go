@@ -0,0 +1,108 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/soroushbar/vantage/internal/incident"
+	"github.com/soroushbar/vantage/internal/store"
+)
+
+// handleListIncidents lists the most recently updated incidents, optionally
+// filtered with ?status=open, acknowledged, resolved or unresolved.
+func (s *Server) handleListIncidents(w http.ResponseWriter, r *http.Request) {
+	if s.Incidents == nil {
+		writeJSONError(w, http.StatusNotFound, "Incident tracking is not enabled", "INCIDENTS_DISABLED")
+		return
+	}
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "", store.IncidentOpen, store.IncidentAcknowledged, store.IncidentResolved, "unresolved":
+	default:
+		writeJSONError(w, http.StatusBadRequest, "status must be open, acknowledged, resolved or unresolved", "BAD_REQUEST")
+		return
+	}
+	incidents, err := s.Incidents.List(status)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(incidents)
+}
+
+// handleGetIncident returns an incident with its timeline.
+func (s *Server) handleGetIncident(w http.ResponseWriter, r *http.Request) {
+	s.changeIncident(w, r, false, func(id int64, _ string) (*store.Incident, error) {
+		return s.Incidents.Get(id)
+	})
+}
+
+// handleAcknowledgeIncident marks an incident as being handled by the
+// calling admin, with an optional {"note"}.
+func (s *Server) handleAcknowledgeIncident(w http.ResponseWriter, r *http.Request) {
+	s.changeIncident(w, r, false, func(id int64, note string) (*store.Incident, error) {
+		return s.Incidents.Acknowledge(id, adminName(r.Context()), note)
+	})
+}
+
+// handleResolveIncident closes an incident with a required resolution {"note"}.
+func (s *Server) handleResolveIncident(w http.ResponseWriter, r *http.Request) {
+	s.changeIncident(w, r, true, func(id int64, note string) (*store.Incident, error) {
+		return s.Incidents.Resolve(id, adminName(r.Context()), note)
+	})
+}
+
+// handleIncidentNote appends a {"note"} to an incident's timeline.
+func (s *Server) handleIncidentNote(w http.ResponseWriter, r *http.Request) {
+	s.changeIncident(w, r, true, func(id int64, note string) (*store.Incident, error) {
+		return s.Incidents.AddNote(id, adminName(r.Context()), note)
+	})
+}
+
+// changeIncident parses the {id} URL parameter and, for requests with a
+// body, its note, then writes the incident returned by apply.
+func (s *Server) changeIncident(w http.ResponseWriter, r *http.Request, requireNote bool, apply func(id int64, note string) (*store.Incident, error)) {
+	if s.Incidents == nil {
+		writeJSONError(w, http.StatusNotFound, "Incident tracking is not enabled", "INCIDENTS_DISABLED")
+		return
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid incident id", "BAD_REQUEST")
+		return
+	}
+	var req struct {
+		Note string `json:"note"`
+	}
+	if r.Method == http.MethodPost && r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid request body", "BAD_REQUEST")
+			return
+		}
+	}
+	req.Note = strings.TrimSpace(req.Note)
+	if requireNote && req.Note == "" {
+		writeJSONError(w, http.StatusBadRequest, "note is required", "BAD_REQUEST")
+		return
+	}
+
+	inc, err := apply(id, req.Note)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		writeJSONError(w, http.StatusNotFound, "incident not found", "NOT_FOUND")
+		return
+	case errors.Is(err, incident.ErrResolved), errors.Is(err, incident.ErrAcknowledged):
+		writeJSONError(w, http.StatusConflict, err.Error(), "INCIDENT_CONFLICT")
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(inc)
+}
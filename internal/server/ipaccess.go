@@ -0,0 +1,45 @@
+package server
+
+import (
+	"log"
+	"net/http"
+	"net/netip"
+
+	"github.com/soroushbar/vantage/internal/config"
+	"github.com/soroushbar/vantage/internal/telemetry"
+	pkgmiddleware "github.com/soroushbar/vantage/pkg/middleware"
+)
+
+// ipAccess restricts a route group to the configured networks, logging and
+// counting rejected requests under scope. Without ranges it passes every
+// request through.
+func ipAccess(scope string, ranges config.IPRanges) func(http.Handler) http.Handler {
+	if !ranges.Enabled() {
+		return func(next http.Handler) http.Handler { return next }
+	}
+	return pkgmiddleware.IPFilterMiddleware(ipFilter(ranges), func(r *http.Request, addr string) {
+		log.Printf("IP access: denied %s %s from %s (%s)", r.Method, r.URL.Path, addr, scope)
+		telemetry.IPDeniedTotal.WithLabelValues(scope).Inc()
+	})
+}
+
+// grpcIPAllowed applies the admin ranges to a gRPC peer address.
+func (s *Server) grpcIPAllowed(ip, method string) bool {
+	ranges := s.Config.IPAccess.Admin
+	if !ranges.Enabled() {
+		return true
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err == nil && ipFilter(ranges).Allowed(addr) {
+		return true
+	}
+	log.Printf("IP access: denied %s from %s (admin)", method, ip)
+	telemetry.IPDeniedTotal.WithLabelValues("admin").Inc()
+	return false
+}
+
+// ipFilter converts ranges, which were validated at config load.
+func ipFilter(ranges config.IPRanges) pkgmiddleware.IPFilter {
+	allow, deny, _ := ranges.Parse()
+	return pkgmiddleware.IPFilter{Allow: allow, Deny: deny}
+}
@@ -0,0 +1,259 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/soroushbar/vantage/internal/store"
+	pkgmiddleware "github.com/soroushbar/vantage/pkg/middleware"
+)
+
+// virtualKeys adapts the store to the proxy's virtual key authenticator.
+type virtualKeys struct {
+	store *store.Store
+}
+
+func (v virtualKeys) LookupVirtualKey(hash string) (pkgmiddleware.VirtualKey, bool, error) {
+	k, err := v.store.VirtualKeyByHash(hash)
+	if errors.Is(err, store.ErrNotFound) {
+		return pkgmiddleware.VirtualKey{}, false, nil
+	}
+	if err != nil {
+		return pkgmiddleware.VirtualKey{}, false, err
+	}
+	return pkgmiddleware.VirtualKey{ID: k.ID, UserID: k.UserID, Project: k.Project, ExpiresAt: k.ExpiresAt, Revoked: k.RevokedAt != nil}, true, nil
+}
+
+func (v virtualKeys) TouchVirtualKey(id int64, at time.Time) error {
+	return v.store.TouchVirtualKey(id, at)
+}
+
+// newVirtualKey generates a key and returns it with its hash.
+func newVirtualKey() (*store.VirtualKey, string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, "", err
+	}
+	key := pkgmiddleware.VirtualKeyPrefix + hex.EncodeToString(raw)
+	return &store.VirtualKey{
+		Key:       key,
+		Prefix:    key[:len(pkgmiddleware.VirtualKeyPrefix)+8],
+		CreatedAt: time.Now().UTC(),
+	}, pkgmiddleware.HashVirtualKey(key), nil
+}
+
+// handleListVirtualKeys lists keys, optionally for one ?user= and in one
+// ?status=. status=revoked is the revocation list.
+func (s *Server) handleListVirtualKeys(w http.ResponseWriter, r *http.Request) {
+	f := store.VirtualKeyFilter{User: r.URL.Query().Get("user"), Status: r.URL.Query().Get("status")}
+	if !validKeyStatus(f.Status) {
+		writeJSONError(w, http.StatusBadRequest, "status must be active, expired or revoked", "BAD_REQUEST")
+		return
+	}
+	keys, err := s.Store.ListVirtualKeys(f)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(keys)
+}
+
+// validKeyStatus reports whether status is a key state, or empty for all.
+func validKeyStatus(status string) bool {
+	switch status {
+	case "", store.KeyActive, store.KeyExpired, store.KeyRevoked:
+		return true
+	}
+	return false
+}
+
+// handleCreateVirtualKey issues a key for a user, optionally charging its
+// requests to a project. It expires at expires_at, after expires_in ("0"
+// for never) or after the configured default. The key is only returned in
+// this response.
+func (s *Server) handleCreateVirtualKey(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		UserID    string     `json:"user_id"`
+		Name      string     `json:"name"`
+		Project   string     `json:"project"`
+		ExpiresAt *time.Time `json:"expires_at"`
+		ExpiresIn string     `json:"expires_in"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.UserID == "" {
+		writeJSONError(w, http.StatusBadRequest, "A user_id is required", "BAD_REQUEST")
+		return
+	}
+	key, err := s.createVirtualKey(req.UserID, req.Name, req.Project, req.ExpiresAt, req.ExpiresIn)
+	var invalid keyRequestError
+	if errors.As(err, &invalid) {
+		writeJSONError(w, http.StatusBadRequest, err.Error(), "BAD_REQUEST")
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(key)
+}
+
+// keyRequestError is a virtual key request with an invalid expiry or grace
+// period.
+type keyRequestError string
+
+func (e keyRequestError) Error() string {
+	return string(e)
+}
+
+// createVirtualKey issues a key for userID. It expires at expiresAt, after
+// expiresIn ("0" for never) or after the configured default.
+func (s *Server) createVirtualKey(userID, name, project string, expiresAt *time.Time, expiresIn string) (*store.VirtualKey, error) {
+	ttl := s.Config.Identity.VirtualKeys.DefaultExpiry
+	if expiresIn != "" {
+		d, err := time.ParseDuration(expiresIn)
+		if err != nil || d < 0 {
+			return nil, keyRequestError("expires_in must be a duration such as 720h")
+		}
+		ttl = d
+	}
+
+	key, hash, err := newVirtualKey()
+	if err != nil {
+		return nil, err
+	}
+	key.UserID, key.Name, key.Project = userID, name, project
+	switch {
+	case expiresAt != nil:
+		if !expiresAt.After(key.CreatedAt) {
+			return nil, keyRequestError("expires_at must be in the future")
+		}
+		at := expiresAt.UTC()
+		key.ExpiresAt = &at
+	case ttl > 0:
+		at := key.CreatedAt.Add(ttl)
+		key.ExpiresAt = &at
+	}
+	if err := s.Store.CreateVirtualKey(key, hash); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func (s *Server) handleGetVirtualKey(w http.ResponseWriter, r *http.Request) {
+	id, ok := virtualKeyID(w, r)
+	if !ok {
+		return
+	}
+	key, err := s.Store.GetVirtualKey(id)
+	if errors.Is(err, store.ErrNotFound) {
+		writeJSONError(w, http.StatusNotFound, "Key not found", "NOT_FOUND")
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(key)
+}
+
+// handleRotateVirtualKey issues a replacement for a key, with the same user,
+// name and project and the default expiry. The old key keeps working for the
+// requested grace period, or the configured one; "0" ends it now.
+func (s *Server) handleRotateVirtualKey(w http.ResponseWriter, r *http.Request) {
+	id, ok := virtualKeyID(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		Grace string `json:"grace"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid request body", "BAD_REQUEST")
+			return
+		}
+	}
+	key, err := s.rotateVirtualKey(id, req.Grace)
+	var invalid keyRequestError
+	if errors.As(err, &invalid) {
+		writeJSONError(w, http.StatusBadRequest, err.Error(), "BAD_REQUEST")
+		return
+	}
+	if errors.Is(err, store.ErrNotFound) {
+		writeJSONError(w, http.StatusNotFound, "Key not found or revoked", "NOT_FOUND")
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(key)
+}
+
+// rotateVirtualKey replaces key id with a new one with the default expiry.
+// The old key keeps working for grace, or the configured grace period when
+// it is empty.
+func (s *Server) rotateVirtualKey(id int64, grace string) (*store.VirtualKey, error) {
+	period := s.Config.Identity.VirtualKeys.RotationGrace
+	if grace != "" {
+		d, err := time.ParseDuration(grace)
+		if err != nil || d < 0 {
+			return nil, keyRequestError("grace must be a duration such as 24h")
+		}
+		period = d
+	}
+
+	key, hash, err := newVirtualKey()
+	if err != nil {
+		return nil, err
+	}
+	if ttl := s.Config.Identity.VirtualKeys.DefaultExpiry; ttl > 0 {
+		at := key.CreatedAt.Add(ttl)
+		key.ExpiresAt = &at
+	}
+	if err := s.Store.RotateVirtualKey(id, key, hash, key.CreatedAt.Add(period)); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func (s *Server) handleRevokeVirtualKey(w http.ResponseWriter, r *http.Request) {
+	id, ok := virtualKeyID(w, r)
+	if !ok {
+		return
+	}
+	before, _ := s.Store.GetVirtualKey(id)
+	err := s.Store.RevokeVirtualKey(id)
+	if errors.Is(err, store.ErrNotFound) {
+		writeJSONError(w, http.StatusNotFound, "Key not found", "NOT_FOUND")
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if after, err := s.Store.GetVirtualKey(id); err == nil {
+		recordChange(r, before, after)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func virtualKeyID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid key id", "BAD_REQUEST")
+		return 0, false
+	}
+	return id, true
+}
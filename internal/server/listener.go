@@ -0,0 +1,146 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+
+	"github.com/soroushbar/vantage/internal/config"
+	"golang.org/x/crypto/acme/autocert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// Listener owns the main gateway server, the optional HTTP redirect server
+// and the optional gRPC admin server.
+type Listener struct {
+	Main     *http.Server
+	Redirect *http.Server
+	GRPC     *grpc.Server
+
+	cfg config.ServerConfig
+}
+
+// NewListener builds the HTTP(S) servers described by the server config.
+func NewListener(cfg config.ServerConfig, handler http.Handler) (*Listener, error) {
+	l := &Listener{
+		Main: &http.Server{Addr: cfg.Addr, Handler: handler},
+		cfg:  cfg,
+	}
+	if !cfg.TLS.Enabled() {
+		return l, nil
+	}
+
+	minVersion, ok := tlsVersions[cfg.TLS.MinVersion]
+	if !ok {
+		return nil, fmt.Errorf("unsupported tls min_version %q", cfg.TLS.MinVersion)
+	}
+
+	// 1. Certificate source
+	var challenge func(http.Handler) http.Handler
+	if cfg.TLS.Autocert.Enabled {
+		if len(cfg.TLS.Autocert.Domains) == 0 {
+			return nil, fmt.Errorf("autocert requires at least one domain")
+		}
+		manager := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(cfg.TLS.Autocert.Domains...),
+			Cache:      autocert.DirCache(cfg.TLS.Autocert.CacheDir),
+			Email:      cfg.TLS.Autocert.Email,
+		}
+		l.Main.TLSConfig = manager.TLSConfig()
+		challenge = manager.HTTPHandler
+	} else {
+		cert, err := tls.LoadX509KeyPair(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load tls key pair: %w", err)
+		}
+		l.Main.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+	}
+	l.Main.TLSConfig.MinVersion = minVersion
+
+	// 2. HTTP -> HTTPS redirect (also serves ACME http-01 challenges)
+	if cfg.TLS.RedirectHTTP || challenge != nil {
+		var redirect http.Handler = http.HandlerFunc(l.redirectToHTTPS)
+		if challenge != nil {
+			redirect = challenge(redirect)
+		}
+		l.Redirect = &http.Server{Addr: cfg.TLS.RedirectAddr, Handler: redirect}
+	}
+	return l, nil
+}
+
+// EnableGRPC builds the gRPC server with newServer, using the main server's
+// TLS configuration when TLS is enabled.
+func (l *Listener) EnableGRPC(newServer func(...grpc.ServerOption) *grpc.Server) {
+	var opts []grpc.ServerOption
+	if l.Main.TLSConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(l.Main.TLSConfig)))
+	}
+	l.GRPC = newServer(opts...)
+}
+
+// ListenAndServe blocks serving the main server; the redirect and gRPC servers run alongside it.
+func (l *Listener) ListenAndServe() error {
+	if l.GRPC != nil {
+		lis, err := net.Listen("tcp", l.cfg.GRPC.Addr)
+		if err != nil {
+			return fmt.Errorf("grpc listener: %w", err)
+		}
+		go func() {
+			log.Printf("gRPC admin API listening on %s", l.cfg.GRPC.Addr)
+			if err := l.GRPC.Serve(lis); err != nil {
+				log.Printf("grpc listener: %v", err)
+			}
+		}()
+	}
+	if l.Redirect != nil {
+		go func() {
+			log.Printf("HTTP redirect listening on %s", l.Redirect.Addr)
+			if err := l.Redirect.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Printf("redirect listener: %v", err)
+			}
+		}()
+	}
+
+	if l.Main.TLSConfig != nil {
+		log.Printf("Vantage Gateway listening on %s (TLS)", l.Main.Addr)
+		return l.Main.ListenAndServeTLS("", "")
+	}
+	log.Printf("Vantage Gateway listening on %s", l.Main.Addr)
+	return l.Main.ListenAndServe()
+}
+
+// Shutdown gracefully stops all servers.
+func (l *Listener) Shutdown(ctx context.Context) error {
+	if l.GRPC != nil {
+		l.GRPC.GracefulStop()
+	}
+	if l.Redirect != nil {
+		if err := l.Redirect.Shutdown(ctx); err != nil {
+			log.Printf("redirect shutdown: %v", err)
+		}
+	}
+	return l.Main.Shutdown(ctx)
+}
+
+func (l *Listener) redirectToHTTPS(w http.ResponseWriter, r *http.Request) {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if _, port, err := net.SplitHostPort(l.cfg.Addr); err == nil && port != "443" {
+		host = net.JoinHostPort(host, port)
+	}
+	http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+}
@@ -0,0 +1,238 @@
+package server
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/soroushbar/vantage/internal/codec"
+	"github.com/soroushbar/vantage/internal/parquet"
+	"github.com/soroushbar/vantage/internal/store"
+	pkgmiddleware "github.com/soroushbar/vantage/pkg/middleware"
+)
+
+// logFilter reads the shared log query parameters: limit, user, path (a
+// prefix), from and to (YYYY-MM-DD or RFC 3339, to exclusive),
+// blocked=true|false, slow=true, session, project and meta.<key>=<value>.
+func logFilter(r *http.Request, defaultLimit int) (store.LogFilter, error) {
+	q := r.URL.Query()
+	f := store.LogFilter{Metadata: map[string]string{}, User: q.Get("user"), Path: q.Get("path"), Slow: q.Get("slow") == "true", Session: q.Get("session"), Project: q.Get("project"), Request: q.Get("request_id")}
+	f.Limit, _ = strconv.Atoi(q.Get("limit"))
+	if f.Limit <= 0 {
+		f.Limit = defaultLimit
+	}
+	var err error
+	if f.From, err = statsTime(q.Get("from"), time.Time{}); err != nil {
+		return f, errors.New("from must be YYYY-MM-DD or RFC 3339")
+	}
+	if f.To, err = statsTime(q.Get("to"), time.Time{}); err != nil {
+		return f, errors.New("to must be YYYY-MM-DD or RFC 3339")
+	}
+	if v := q.Get("blocked"); v != "" {
+		blocked, err := strconv.ParseBool(v)
+		if err != nil {
+			return f, errors.New("blocked must be true or false")
+		}
+		f.Blocked = &blocked
+	}
+	for key, values := range q {
+		if name, ok := strings.CutPrefix(key, "meta."); ok && name != "" {
+			f.Metadata[name] = values[0]
+		}
+	}
+	return f, nil
+}
+
+// handleSearchLogs finds interactions whose bodies match ?q=, a full-text
+// query with "phrases", AND, OR, NOT and request: or response: prefixes.
+// ?in=request|response searches one body only, and the shared log filters
+// narrow the matches, newest first.
+func (s *Server) handleSearchLogs(w http.ResponseWriter, r *http.Request) {
+	if !s.Config.Storage.SearchIndex {
+		writeJSONError(w, http.StatusNotFound, "Full-text search is not enabled", "SEARCH_DISABLED")
+		return
+	}
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		writeJSONError(w, http.StatusBadRequest, "q is required", "BAD_REQUEST")
+		return
+	}
+	switch in := r.URL.Query().Get("in"); in {
+	case "":
+	case "request", "response":
+		query = in + " : (" + query + ")"
+	default:
+		writeJSONError(w, http.StatusBadRequest, "in must be request or response", "BAD_REQUEST")
+		return
+	}
+	f, err := logFilter(r, 50)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	f.Search = query
+	logs, err := s.Store.GetLogs(f)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid search: "+err.Error(), "BAD_REQUEST")
+		return
+	}
+	s.writeLogList(w, r, logs)
+}
+
+// handleExportLogs streams filtered interactions as a JSON, CSV or Parquet
+// download. JSON and Parquet include the bodies.
+func (s *Server) handleExportLogs(w http.ResponseWriter, r *http.Request) {
+	f, err := logFilter(r, 10000)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	logs, err := s.Store.GetLogs(f)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "parquet" {
+		if err := s.recordReveal(r, "export", logs...); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		records := make([]store.ExportRecord, len(logs))
+		for i, l := range logs {
+			records[i] = store.ExportRecord{InteractionRecord: l}
+		}
+		var buf bytes.Buffer
+		if err := parquet.WriteInteractions(&buf, records, codec.Zstd); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/vnd.apache.parquet")
+		w.Header().Set("Content-Disposition", `attachment; filename="vantage-logs.parquet"`)
+		w.Write(buf.Bytes())
+		return
+	}
+	if format != "csv" {
+		if err := s.recordReveal(r, "export", logs...); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", `attachment; filename="vantage-logs.json"`)
+		if logs == nil {
+			logs = []store.InteractionRecord{}
+		}
+		json.NewEncoder(w).Encode(logs)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", `attachment; filename="vantage-logs.csv"`)
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"id", "timestamp", "user_id", "method", "path", "model", "routed_model", "status_code", "latency_ms", "tokens", "safety_score", "is_blocked", "is_redacted", "cache_hit", "template", "metadata", "truncation", "verdict", "headers", "is_slow", "response_safety", "session_id", "tokens_estimated", "transformation", "redaction_report", "response_pii", "project", "param_overrides", "moderation", "request_id"})
+	for _, l := range logs {
+		safety, responseSafety := "", ""
+		if l.SafetyScore != nil {
+			safety = strconv.FormatFloat(*l.SafetyScore, 'f', 4, 64)
+		}
+		if l.ResponseSafety != nil {
+			responseSafety = strconv.FormatFloat(*l.ResponseSafety, 'f', 4, 64)
+		}
+		cw.Write([]string{
+			strconv.Itoa(l.ID),
+			l.Timestamp.UTC().Format("2006-01-02T15:04:05Z"),
+			l.UserID,
+			l.Method,
+			l.Path,
+			l.Model,
+			l.RoutedModel,
+			strconv.Itoa(l.StatusCode),
+			strconv.FormatInt(l.LatencyMs, 10),
+			strconv.Itoa(l.Tokens),
+			safety,
+			strconv.FormatBool(l.IsBlocked),
+			strconv.FormatBool(l.IsRedacted),
+			strconv.FormatBool(l.CacheHit),
+			l.Template,
+			string(l.Metadata),
+			string(l.Truncation),
+			string(l.Verdict),
+			string(l.Headers),
+			strconv.FormatBool(l.IsSlow),
+			responseSafety,
+			l.Session,
+			strconv.FormatBool(l.Estimated),
+			string(l.Transformation),
+			string(l.RedactionReport),
+			string(l.ResponsePII),
+			l.Project,
+			string(l.ParamOverrides),
+			string(l.Moderation),
+			l.RequestID,
+		})
+	}
+	cw.Flush()
+}
+
+// recordReveal writes an access log entry for every record whose raw bodies
+// are about to be returned. Callers must not respond with the bodies if it fails.
+func (s *Server) recordReveal(r *http.Request, action string, logs ...store.InteractionRecord) error {
+	viewer := adminName(r.Context())
+	if viewer == "" {
+		viewer = pkgmiddleware.UserID(r)
+	}
+	return s.recordAccess(viewer, r.RemoteAddr, action, logs)
+}
+
+func (s *Server) recordAccess(viewer, remoteAddr, action string, logs []store.InteractionRecord) error {
+	if len(logs) == 0 {
+		return nil
+	}
+	if viewer == "" {
+		viewer = "anonymous"
+	}
+	ids := make([]int, len(logs))
+	for i, l := range logs {
+		ids[i] = l.ID
+	}
+	return s.Store.RecordAccess(viewer, remoteAddr, action, ids)
+}
+
+// handleListExports lists the manifests of interactions exported out of
+// the database, newest first, up to ?limit= (default 100).
+func (s *Server) handleListExports(w http.ResponseWriter, r *http.Request) {
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 {
+		limit = 100
+	}
+	exports, err := s.Store.ListExports(limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(exports)
+}
+
+// handleGetAccessLog lists who read raw bodies, filterable by ?interaction_id= and ?viewer=.
+func (s *Server) handleGetAccessLog(w http.ResponseWriter, r *http.Request) {
+	f := store.AccessFilter{Viewer: r.URL.Query().Get("viewer")}
+	f.InteractionID, _ = strconv.Atoi(r.URL.Query().Get("interaction_id"))
+	f.Limit, _ = strconv.Atoi(r.URL.Query().Get("limit"))
+	if f.Limit <= 0 {
+		f.Limit = 100
+	}
+	entries, err := s.Store.ListAccess(f)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
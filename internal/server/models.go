@@ -0,0 +1,40 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func (s *Server) handleListModels(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.Models.List())
+}
+
+// handleSetModelState registers a model or moves it to a new lifecycle state.
+func (s *Server) handleSetModelState(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		State    string     `json:"state"`
+		Notes    string     `json:"notes"`
+		SunsetAt *time.Time `json:"sunset_at"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var before interface{}
+	if m, ok := s.Models.Get(chi.URLParam(r, "name")); ok {
+		before = m
+	}
+	m, err := s.Models.Set(chi.URLParam(r, "name"), req.State, req.Notes, req.SunsetAt)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	recordChange(r, before, m)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(m)
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/soroushbar/vantage/pkg/providers/gemini"
+	"github.com/soroushbar/vantage/pkg/providers/groq"
+	"github.com/soroushbar/vantage/pkg/providers/local"
+	"github.com/soroushbar/vantage/pkg/providers/mistral"
+)
+
+// cohereChatCompletionsPath is the chat route of Cohere's OpenAI
+// compatibility API.
+const cohereChatCompletionsPath = "/compatibility/v1/chat/completions"
+
+// handleOpenAIChat serves OpenAI chat completions for the OpenAI SDKs. The
+// request is sent, unchanged, to the OpenAI-compatible route of the provider
+// the openai routes pick for its model, through that provider's pipeline, so
+// the response is in the OpenAI format whichever provider answers.
+func (s *Server) handleOpenAIChat(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "failed to read request body", "BAD_REQUEST")
+		return
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	var req struct {
+		Model string `json:"model"`
+	}
+	json.Unmarshal(body, &req)
+	name := s.openAIProvider(req.Model)
+	path, handler := s.openAIBackend(name)
+	if handler == nil {
+		writeJSONError(w, http.StatusBadGateway, fmt.Sprintf("provider %s is not available", name), "PROVIDER_UNAVAILABLE")
+		return
+	}
+
+	u := *r.URL
+	u.Path, u.RawPath = path, ""
+	r2 := r.Clone(r.Context())
+	r2.URL = &u
+	r2.RequestURI = u.RequestURI()
+	handler.ServeHTTP(w, r2)
+}
+
+// openAIProvider names the provider of the first route matching model, or
+// the default provider.
+func (s *Server) openAIProvider(model string) string {
+	for _, route := range s.Config.OpenAI.Routes {
+		for _, prefix := range route.Models {
+			if model != "" && strings.HasPrefix(model, prefix) {
+				return route.Provider
+			}
+		}
+	}
+	return s.Config.OpenAI.DefaultProvider
+}
+
+// openAIBackend returns the proxy path of a provider's OpenAI-compatible
+// chat route and the pipeline that serves it, or a nil handler when the
+// provider is not mounted.
+func (s *Server) openAIBackend(name string) (string, http.Handler) {
+	var prefix, path string
+	switch name {
+	case "cohere":
+		return cohereChatCompletionsPath, s.Pipeline
+	case "gemini":
+		prefix, path = gemini.PathPrefix, gemini.OpenAIPath
+	case "mistral":
+		prefix, path = mistral.PathPrefix, mistral.PathPrefix+"/v1/chat/completions"
+	case "groq":
+		prefix, path = groq.PathPrefix, groq.PathPrefix+"/v1/chat/completions"
+	default:
+		prefix = local.PathPrefix + "/" + name
+		path = prefix + "/v1/chat/completions"
+	}
+	h, ok := s.Providers[prefix]
+	if !ok {
+		return "", nil
+	}
+	return path, h
+}
@@ -0,0 +1,751 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/soroushbar/vantage/internal/latency"
+	"github.com/soroushbar/vantage/internal/plans"
+	"github.com/soroushbar/vantage/internal/provider"
+	"github.com/soroushbar/vantage/internal/quota"
+	"github.com/soroushbar/vantage/internal/reports"
+	"github.com/soroushbar/vantage/internal/store"
+)
+
+// apiOperation documents an /api endpoint. Request and Response are zero
+// values of the body types; their schemas are derived from the Go types, so
+// they follow the code. Status is the success status, 200 when unset; a nil
+// Response with 200 is an object of unspecified shape. CSV and Parquet offer
+// text/csv and Parquet files next to JSON and Public endpoints need no
+// admin token.
+type apiOperation struct {
+	Summary  string
+	Tag      string
+	Query    []apiParam
+	Request  interface{}
+	Response interface{}
+	Status   int
+	CSV      bool
+	Parquet  bool
+	Public   bool
+
+	OptionalBody bool
+}
+
+// apiParam is a query parameter; Type is string, integer or boolean.
+type apiParam struct {
+	Name        string
+	Type        string
+	Description string
+}
+
+var logFilterParams = []apiParam{
+	{"limit", "integer", "Maximum number of records."},
+	{"user", "string", "Only this user's interactions."},
+	{"path", "string", "Only paths with this prefix."},
+	{"from", "string", "Start, YYYY-MM-DD or RFC 3339."},
+	{"to", "string", "End (exclusive), YYYY-MM-DD or RFC 3339."},
+	{"blocked", "boolean", "Only blocked, or only allowed, interactions."},
+	{"slow", "boolean", "Only interactions over the slow threshold."},
+	{"session", "string", "Only interactions of this session."},
+	{"project", "string", "Only interactions charged to this project."},
+	{"request_id", "string", "Only the interaction with this X-Vantage-Request-ID."},
+}
+
+var statsRangeParams = []apiParam{
+	{"from", "string", "Start, YYYY-MM-DD or RFC 3339."},
+	{"to", "string", "End (exclusive), YYYY-MM-DD or RFC 3339."},
+}
+
+// Bodies the handlers read into or write from anonymous values.
+type (
+	readOnlyState struct {
+		Enabled bool `json:"enabled"`
+	}
+	replayRequest struct {
+		Model string `json:"model,omitempty"`
+	}
+	incidentNote struct {
+		Note string `json:"note"`
+	}
+	triageUpdate struct {
+		Status   string `json:"status"`
+		Severity string `json:"severity,omitempty"`
+		Note     string `json:"note,omitempty"`
+	}
+	modelState struct {
+		State    string     `json:"state"`
+		Notes    string     `json:"notes,omitempty"`
+		SunsetAt *time.Time `json:"sunset_at,omitempty"`
+	}
+	trustFeedback struct {
+		Score float64 `json:"score"`
+		Note  string  `json:"note,omitempty"`
+	}
+	signingKeyRequest struct {
+		Name string `json:"name"`
+	}
+	virtualKeyRequest struct {
+		UserID    string     `json:"user_id"`
+		Name      string     `json:"name,omitempty"`
+		Project   string     `json:"project,omitempty"`
+		ExpiresAt *time.Time `json:"expires_at,omitempty"`
+		ExpiresIn string     `json:"expires_in,omitempty"`
+	}
+	rotateKeyRequest struct {
+		Grace string `json:"grace,omitempty"`
+	}
+	templateSplits struct {
+		Splits []store.TemplateSplit `json:"splits"`
+	}
+	templateVersion struct {
+		Version int `json:"version"`
+	}
+	usageStats struct {
+		Granularity string            `json:"granularity"`
+		From        time.Time         `json:"from"`
+		To          time.Time         `json:"to"`
+		Series      []store.UsageStat `json:"series"`
+	}
+	latencyStats struct {
+		From    time.Time                `json:"from"`
+		To      time.Time                `json:"to"`
+		Overall store.LatencyBreakdown   `json:"overall"`
+		Routes  []store.LatencyBreakdown `json:"routes"`
+		SLOs    []latency.SLOStatus      `json:"slos"`
+	}
+)
+
+// apiOperations documents the /api routes by "METHOD /path" below /api.
+// Routes missing here are still listed, with only their parameters.
+var apiOperations = map[string]apiOperation{
+	"GET /openapi.json": {
+		Summary: "This document.",
+		Tag:     "meta",
+		Public:  true,
+	},
+	"POST /providers/{name}/webhook": {
+		Summary: "Receive a provider status page webhook, authenticated by ?token=.",
+		Tag:     "providers",
+		Query:   []apiParam{{"token", "string", "The provider_status webhook token."}},
+		Status:  http.StatusNoContent,
+		Public:  true,
+	},
+	"GET /read-only": {
+		Summary:  "Whether the gateway is in read-only mode.",
+		Tag:      "admin",
+		Response: readOnlyState{},
+	},
+	"PUT /read-only": {
+		Summary:  "Switch read-only mode on or off.",
+		Tag:      "admin",
+		Request:  readOnlyState{},
+		Response: readOnlyState{},
+	},
+	"GET /logs": {
+		Summary:  "Interactions, newest first. Bodies are only included, and access-logged, with ?bodies=true; meta.<key>=<value> filters on metadata.",
+		Tag:      "logs",
+		Query:    append(logFilterParams, apiParam{"bodies", "boolean", "Include the raw request and response bodies."}),
+		Response: []store.InteractionRecord{},
+	},
+	"GET /logs/verify": {
+		Summary:  "Verify the audit log hash chain.",
+		Tag:      "logs",
+		Response: store.ChainReport{},
+	},
+	"GET /logs/export": {
+		Summary:  "Download filtered interactions as JSON, CSV or Parquet.",
+		Tag:      "export",
+		Query:    append(logFilterParams, apiParam{"format", "string", "json (default), csv or parquet."}),
+		Response: []store.InteractionRecord{},
+		CSV:      true,
+		Parquet:  true,
+	},
+	"GET /logs/search": {
+		Summary: "Interactions whose bodies match a full-text query, newest first. Needs storage.search_index; bodies are only included, and access-logged, with ?bodies=true.",
+		Tag:     "logs",
+		Query: append([]apiParam{
+			{"q", "string", `Full-text query: words, "phrases", AND, OR, NOT and request: or response: prefixes.`},
+			{"in", "string", "Search only the request or the response body."},
+			{"bodies", "boolean", "Include the raw request and response bodies."},
+		}, logFilterParams...),
+		Response: []store.InteractionRecord{},
+	},
+	"GET /logs/triage": {
+		Summary: "Flagged interactions awaiting or past review, most severe first.",
+		Tag:     "logs",
+		Query: []apiParam{
+			{"status", "string", "new, acknowledged, resolved, false-positive or open (new and acknowledged)."},
+			{"severity", "string", "info, warning or critical."},
+			{"user", "string", "Only this user's interactions."},
+			{"limit", "integer", "Maximum number of interactions (default 100)."},
+		},
+		Response: []store.Triage{},
+	},
+	"GET /logs/{id}": {
+		Summary:  "One interaction with its bodies; the read is access-logged.",
+		Tag:      "logs",
+		Response: store.InteractionRecord{},
+	},
+	"POST /logs/{id}/replay": {
+		Summary:      "Send a logged request upstream again, optionally to another model, through the model policy and governance rules, and diff the responses. Blocked interactions cannot be replayed.",
+		Tag:          "logs",
+		Request:      replayRequest{},
+		Response:     store.Replay{},
+		OptionalBody: true,
+	},
+	"GET /logs/{id}/replays": {
+		Summary:  "The replays of an interaction, newest first.",
+		Tag:      "logs",
+		Response: []store.Replay{},
+	},
+	"POST /logs/purge": {
+		Summary:  "Delete the interactions matching the log filters, e.g. a user's for an erasure request, with their quarantined payloads, triage entries, replays, access log entries, archive objects, dead letters and records in JSONL log exports; a user's usage rollups are folded into \"(purged)\" and their trust scores and feedback, anomaly alerts, daily quota counts and unlinked quarantined payloads are deleted. Parquet exports that may hold matches are listed under exports.kept. At least one filter is required; limit is ignored.",
+		Tag:      "logs",
+		Query:    append(logFilterParams, apiParam{"dry_run", "boolean", "Only count what would be deleted."}),
+		Response: purgeResult{},
+	},
+	"PATCH /logs/{id}/status": {
+		Summary:  "Set the triage status of a flagged interaction, optionally regrading its severity; attributed to the calling admin.",
+		Tag:      "logs",
+		Request:  triageUpdate{},
+		Response: store.Triage{},
+	},
+	"GET /sessions/{id}": {
+		Summary:  "A session's interactions with their bodies, oldest first, from X-Session-ID or the conversation ID. Every read is access-logged.",
+		Tag:      "logs",
+		Query:    []apiParam{{"user", "string", "Only this user's interactions."}, {"limit", "integer", "Maximum number of interactions (default 500)."}},
+		Response: store.Session{},
+	},
+	"GET /exports": {
+		Summary:  "Manifests of interactions exported to object storage and deleted locally, newest first.",
+		Tag:      "logs",
+		Query:    []apiParam{{"limit", "integer", "Maximum number of manifests (default 100)."}},
+		Response: []store.LogExport{},
+	},
+	"GET /access-log": {
+		Summary:  "Who read raw bodies.",
+		Tag:      "logs",
+		Query:    []apiParam{{"interaction_id", "integer", "Only reads of this interaction."}, {"viewer", "string", "Only reads by this admin."}, {"limit", "integer", "Maximum number of entries."}},
+		Response: []store.AccessEntry{},
+	},
+	"GET /admin-audit": {
+		Summary: "Changes made through the admin API and log exports, newest first, with the admin, the route, the status and what changed.",
+		Tag:     "admin",
+		Query: []apiParam{
+			{"actor", "string", "Only this admin."},
+			{"action", "string", `Only actions starting with this, e.g. "PUT /models".`},
+			{"target", "string", "Only this target, e.g. name=command-r."},
+			{"from", "string", "Start, YYYY-MM-DD or RFC 3339."},
+			{"to", "string", "End (exclusive), YYYY-MM-DD or RFC 3339."},
+			{"limit", "integer", "Maximum number of entries (default 100)."},
+		},
+		Response: []store.AdminAction{},
+	},
+	"GET /quarantine": {
+		Summary:  "Payloads of blocked requests, without their bodies, newest first. Limited to quarantine.readers.",
+		Tag:      "logs",
+		Query:    []apiParam{{"user", "string", "Only this user."}, {"reason", "string", "Only this block code, e.g. FORBIDDEN_CONTENT."}, {"limit", "integer", "Maximum number of payloads (default 100)."}},
+		Response: []store.QuarantinedPayload{},
+	},
+	"GET /quarantine/{id}": {
+		Summary:  "A quarantined payload with its body. The read is recorded in the access log.",
+		Tag:      "logs",
+		Response: store.QuarantinedPayload{},
+	},
+	"GET /summary": {
+		Summary:  "Today's usage from the hourly rollups.",
+		Tag:      "stats",
+		Query:    []apiParam{{"day", "string", "Another day, YYYY-MM-DD."}},
+		Response: store.UsageSummary{},
+	},
+	"GET /stats": {
+		Summary:  "Usage series from the rollups.",
+		Tag:      "stats",
+		Query:    append([]apiParam{{"granularity", "string", "day (default) or hour."}, {"group_by", "string", "user, model or project."}, {"user", "string", "Only this user."}, {"model", "string", "Only this model."}, {"project", "string", "Only this project."}}, statsRangeParams...),
+		Response: usageStats{},
+	},
+	"GET /stats/latency": {
+		Summary:  "Latency percentiles overall and per route, with the latency SLOs.",
+		Tag:      "stats",
+		Query:    append([]apiParam{{"route", "string", "Only this route, e.g. /v1/chat."}}, statsRangeParams...),
+		Response: latencyStats{},
+	},
+	"GET /reports": {
+		Summary: "The compliance report of the last complete week or month, or of a custom range: requests, blocks, redactions, flagged interactions, top violators and cost.",
+		Tag:     "stats",
+		Query: []apiParam{
+			{"period", "string", "weekly (default) or monthly, ending at midnight in reports.compliance.timezone."},
+			{"from", "string", "Start of a custom range, YYYY-MM-DD or RFC 3339."},
+			{"to", "string", "End (exclusive) of a custom range, YYYY-MM-DD or RFC 3339."},
+			{"format", "string", "json (default), html or pdf."},
+		},
+		Response: reports.ComplianceReport{},
+	},
+	"GET /reports/idle": {
+		Summary:  "The latest idle key and model report.",
+		Tag:      "stats",
+		Query:    []apiParam{{"refresh", "boolean", "Generate a new report."}},
+		Response: reports.HygieneReport{},
+	},
+	"GET /webhooks/deliveries": {
+		Summary:  "Recent webhook deliveries.",
+		Tag:      "policies",
+		Query:    []apiParam{{"limit", "integer", "Maximum number of deliveries (default 50)."}},
+		Response: []store.WebhookDelivery{},
+	},
+	"GET /incidents": {
+		Summary:  "Recently updated incidents.",
+		Tag:      "incidents",
+		Query:    []apiParam{{"status", "string", "open, acknowledged, resolved or unresolved."}},
+		Response: []store.Incident{},
+	},
+	"GET /incidents/{id}": {
+		Summary:  "An incident with its timeline.",
+		Tag:      "incidents",
+		Response: store.Incident{},
+	},
+	"POST /incidents/{id}/acknowledge": {
+		Summary:      "Acknowledge an incident, with an optional note.",
+		Tag:          "incidents",
+		Request:      incidentNote{},
+		Response:     store.Incident{},
+		OptionalBody: true,
+	},
+	"POST /incidents/{id}/resolve": {
+		Summary:  "Resolve an incident; the note is required.",
+		Tag:      "incidents",
+		Request:  incidentNote{},
+		Response: store.Incident{},
+	},
+	"POST /incidents/{id}/notes": {
+		Summary:  "Add a note to an incident's timeline.",
+		Tag:      "incidents",
+		Request:  incidentNote{},
+		Response: store.Incident{},
+	},
+	"GET /alerts": {
+		Summary:  "Usage anomalies, newest first.",
+		Tag:      "incidents",
+		Query:    []apiParam{{"user", "string", "Only this user."}, {"kind", "string", "request_spike, token_spike, blocked_surge or unusual_hour."}, {"limit", "integer", "Maximum number of alerts (default 100)."}},
+		Response: []store.Alert{},
+	},
+	"GET /models": {
+		Summary:  "The model registry.",
+		Tag:      "policies",
+		Response: []store.Model{},
+	},
+	"PUT /models/{name}": {
+		Summary:  "Register a model or move it to another lifecycle state.",
+		Tag:      "policies",
+		Request:  modelState{},
+		Response: store.Model{},
+	},
+	"GET /artifacts": {
+		Summary:  "Fine-tuned model artifacts and their owners.",
+		Tag:      "policies",
+		Response: []store.ModelArtifact{},
+	},
+	"GET /config": {
+		Summary: "The effective config, after the VANTAGE_ENV overlay, environment overrides and runtime settings, with secrets masked.",
+		Tag:     "admin",
+		Query:   []apiParam{{"format", "string", "yaml (default), in the layout of config.yaml, or json."}},
+	},
+	"GET /settings": {
+		Summary:  "The runtime settings (forbidden_keywords, redaction, rate_limit, projects) with who last changed them and when.",
+		Tag:      "admin",
+		Response: []store.Setting{},
+	},
+	"GET /settings/{key}": {
+		Summary:  "One runtime setting.",
+		Tag:      "admin",
+		Response: store.Setting{},
+	},
+	"PUT /settings/{key}": {
+		Summary:  "Replace a runtime setting with the JSON of its config.yaml section; it applies at once.",
+		Tag:      "admin",
+		Request:  json.RawMessage{},
+		Response: store.Setting{},
+	},
+	"DELETE /settings/{key}": {
+		Summary:  "Set a runtime setting back to its value in config.yaml.",
+		Tag:      "admin",
+		Response: store.Setting{},
+	},
+	"GET /providers": {
+		Summary:  "Provider status as reported by their status pages.",
+		Tag:      "providers",
+		Response: []provider.Status{},
+	},
+	"GET /quotas": {
+		Summary:  "Today's request allowance per user.",
+		Tag:      "policies",
+		Query:    []apiParam{{"user", "string", "Only this user."}},
+		Response: []quota.Allowance{},
+	},
+	"GET /plans": {
+		Summary:  "The plans and their assignments, or with ?user= the plan of one user.",
+		Tag:      "policies",
+		Query:    []apiParam{{"user", "string", "Return the plan of this user."}},
+		Response: plans.Summary{},
+	},
+	"GET /trust": {
+		Summary:  "Trust scores, lowest first, or with ?user= the score of one user.",
+		Tag:      "policies",
+		Query:    []apiParam{{"user", "string", "Return the score of this user."}},
+		Response: []store.TrustScore{},
+	},
+	"POST /trust/{user}/feedback": {
+		Summary:  "Record an operator's judgement of a user, from -1 (abusive) to 1 (vouched for).",
+		Tag:      "policies",
+		Request:  trustFeedback{},
+		Response: store.TrustScore{},
+	},
+	"GET /signing-keys": {
+		Summary:  "Services with a request signing key; secrets are not returned.",
+		Tag:      "keys",
+		Response: []store.SigningKey{},
+	},
+	"POST /signing-keys": {
+		Summary:  "Issue a signing key for a service, replacing its old one. The secret is only returned here.",
+		Tag:      "keys",
+		Request:  signingKeyRequest{},
+		Response: store.SigningKey{},
+		Status:   http.StatusCreated,
+	},
+	"DELETE /signing-keys/{name}": {
+		Summary: "Revoke a service's signing key.",
+		Tag:     "keys",
+		Status:  http.StatusNoContent,
+	},
+	"GET /keys": {
+		Summary:  "Virtual keys, newest first; keys are not returned. Filter with ?user= and ?status=active|expired|revoked.",
+		Tag:      "keys",
+		Response: []store.VirtualKey{},
+	},
+	"POST /keys": {
+		Summary:  "Issue a virtual key for a user, optionally charging its requests to a project. The key is only returned here.",
+		Tag:      "keys",
+		Request:  virtualKeyRequest{},
+		Response: store.VirtualKey{},
+		Status:   http.StatusCreated,
+	},
+	"GET /keys/{id}": {
+		Summary:  "One virtual key, with its status and last use.",
+		Tag:      "keys",
+		Response: store.VirtualKey{},
+	},
+	"POST /keys/{id}/rotate": {
+		Summary:  "Issue a replacement for a virtual key; the old one keeps working for the grace period.",
+		Tag:      "keys",
+		Request:  rotateKeyRequest{},
+		Response: store.VirtualKey{},
+		Status:   http.StatusCreated,
+	},
+	"DELETE /keys/{id}": {
+		Summary: "Revoke a virtual key.",
+		Tag:     "keys",
+		Status:  http.StatusNoContent,
+	},
+	"GET /templates": {
+		Summary:  "The latest version of every prompt template.",
+		Tag:      "templates",
+		Response: []store.PromptTemplate{},
+	},
+	"POST /templates": {
+		Summary:  "Save a new version of a prompt template.",
+		Tag:      "templates",
+		Request:  store.PromptTemplate{},
+		Response: store.PromptTemplate{},
+		Status:   http.StatusCreated,
+	},
+	"GET /templates/{name}": {
+		Summary:  "A prompt template, by default its latest version.",
+		Tag:      "templates",
+		Query:    []apiParam{{"version", "integer", "Another version."}},
+		Response: store.PromptTemplate{},
+	},
+	"GET /templates/{name}/splits": {
+		Summary:  "The traffic split between template versions.",
+		Tag:      "templates",
+		Response: []store.TemplateSplit{},
+	},
+	"PUT /templates/{name}/splits": {
+		Summary:  "Replace the traffic split between template versions.",
+		Tag:      "templates",
+		Request:  templateSplits{},
+		Response: []store.TemplateSplit{},
+	},
+	"POST /templates/{name}/promote": {
+		Summary:  "Route all traffic to one version.",
+		Tag:      "templates",
+		Request:  templateVersion{},
+		Response: []store.TemplateSplit{},
+	},
+	"POST /templates/{name}/rollback": {
+		Summary:  "Restore the previously promoted version.",
+		Tag:      "templates",
+		Response: []store.TemplateSplit{},
+	},
+	"GET /templates/{name}/stats": {
+		Summary:  "Usage and feedback per template version.",
+		Tag:      "templates",
+		Response: []store.TemplateVersionStats{},
+	},
+}
+
+// handleOpenAPI serves an OpenAPI 3 document of the /api routes. The routes
+// come from the router itself, so none goes undocumented.
+func (s *Server) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	s.openAPIOnce.Do(func() {
+		s.openAPI, s.openAPIErr = json.Marshal(s.buildOpenAPI())
+	})
+	if s.openAPIErr != nil {
+		http.Error(w, s.openAPIErr.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(s.openAPI)
+}
+
+var pathParamRegex = regexp.MustCompile(`\{([^}]+)\}`)
+
+func (s *Server) buildOpenAPI() map[string]interface{} {
+	schemas := &schemaSet{defs: map[string]interface{}{}, names: map[reflect.Type]string{}, taken: map[string]reflect.Type{}}
+	paths := map[string]map[string]interface{}{}
+
+	type route struct {
+		method, path string
+		handler      http.Handler
+	}
+	var routes []route
+	chi.Walk(s.Router, func(method, path string, h http.Handler, _ ...func(http.Handler) http.Handler) error {
+		if strings.HasPrefix(path, "/api/") {
+			routes = append(routes, route{method, strings.TrimSuffix(path, "/"), h})
+		}
+		return nil
+	})
+	// Schemas are named in a stable order
+	sort.Slice(routes, func(i, j int) bool {
+		return routes[i].path+" "+routes[i].method < routes[j].path+" "+routes[j].method
+	})
+
+	for _, rt := range routes {
+		op := apiOperations[rt.method+" "+strings.TrimPrefix(rt.path, "/api")]
+		o := map[string]interface{}{
+			"operationId": operationID(rt.handler, rt.method, rt.path),
+		}
+		if op.Summary != "" {
+			o["summary"] = op.Summary
+		}
+		if op.Tag != "" {
+			o["tags"] = []string{op.Tag}
+		}
+		if op.Public {
+			o["security"] = []interface{}{}
+		}
+
+		var params []interface{}
+		for _, m := range pathParamRegex.FindAllStringSubmatch(rt.path, -1) {
+			params = append(params, map[string]interface{}{"name": m[1], "in": "path", "required": true, "schema": map[string]string{"type": "string"}})
+		}
+		for _, p := range op.Query {
+			params = append(params, map[string]interface{}{"name": p.Name, "in": "query", "description": p.Description, "schema": map[string]string{"type": p.Type}})
+		}
+		if params != nil {
+			o["parameters"] = params
+		}
+		if op.Request != nil {
+			o["requestBody"] = map[string]interface{}{
+				"required": !op.OptionalBody,
+				"content":  map[string]interface{}{"application/json": map[string]interface{}{"schema": schemas.of(reflect.TypeOf(op.Request))}},
+			}
+		}
+
+		status := op.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		resp := map[string]interface{}{"description": http.StatusText(status)}
+		if status != http.StatusNoContent {
+			schema := map[string]interface{}{"type": "object"}
+			if op.Response != nil {
+				schema = schemas.of(reflect.TypeOf(op.Response))
+			}
+			content := map[string]interface{}{"application/json": map[string]interface{}{"schema": schema}}
+			if op.CSV {
+				content["text/csv"] = map[string]interface{}{"schema": map[string]string{"type": "string"}}
+			}
+			if op.Parquet {
+				content["application/vnd.apache.parquet"] = map[string]interface{}{"schema": map[string]string{"type": "string", "format": "binary"}}
+			}
+			resp["content"] = content
+		}
+		o["responses"] = map[string]interface{}{
+			strconv.Itoa(status): resp,
+			"default":            map[string]interface{}{"description": "Error", "content": map[string]interface{}{"application/json": map[string]interface{}{"schema": map[string]string{"$ref": "#/components/schemas/Error"}}}},
+		}
+
+		if paths[rt.path] == nil {
+			paths[rt.path] = map[string]interface{}{}
+		}
+		paths[rt.path][strings.ToLower(rt.method)] = o
+	}
+
+	schemas.defs["Error"] = map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"error": map[string]string{"type": "string"},
+			"code":  map[string]string{"type": "string"},
+		},
+	}
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]string{
+			"title":       "Vantage Admin API",
+			"version":     "2.0.0",
+			"description": "Authenticate with an admin token as a bearer token.",
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas":         schemas.defs,
+			"securitySchemes": map[string]interface{}{"adminToken": map[string]string{"type": "http", "scheme": "bearer"}},
+		},
+		"security": []interface{}{map[string][]string{"adminToken": {}}},
+	}
+}
+
+// operationID names an operation after its handler, handleGetLogs becoming
+// getLogs, so that generated clients get readable method names.
+func operationID(h http.Handler, method, path string) string {
+	// Routes registered With middleware wrap the handler
+	if c, ok := h.(*chi.ChainHandler); ok {
+		h = c.Endpoint
+	}
+	if fn, ok := h.(http.HandlerFunc); ok {
+		name := runtime.FuncForPC(reflect.ValueOf(fn).Pointer()).Name()
+		name = strings.TrimSuffix(name[strings.LastIndex(name, ".")+1:], "-fm")
+		if rest, ok := strings.CutPrefix(name, "handle"); ok && rest != "" {
+			return strings.ToLower(rest[:1]) + rest[1:]
+		}
+	}
+	id := strings.ToLower(method)
+	for _, part := range strings.FieldsFunc(path, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }) {
+		id += strings.ToUpper(part[:1]) + part[1:]
+	}
+	return id
+}
+
+// schemaSet derives JSON schemas from Go types. Named struct types from
+// other packages become components, named after the type and prefixed with
+// their package when two packages use the same name.
+type schemaSet struct {
+	defs  map[string]interface{}
+	names map[reflect.Type]string
+	taken map[string]reflect.Type
+}
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+	rawType  = reflect.TypeOf(json.RawMessage{})
+	localPkg = reflect.TypeOf(apiOperation{}).PkgPath()
+)
+
+func (s *schemaSet) of(t reflect.Type) map[string]interface{} {
+	switch {
+	case t == timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case t == rawType:
+		return map[string]interface{}{}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		schema := s.of(t.Elem())
+		if _, ref := schema["$ref"]; !ref {
+			schema["nullable"] = true
+		}
+		return schema
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if t.String() == "time.Duration" {
+			return map[string]interface{}{"type": "integer", "description": "Nanoseconds."}
+		}
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+		return map[string]interface{}{"type": "array", "items": s.of(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": s.of(t.Elem())}
+	case reflect.Struct:
+		// The body types declared for this document stay inline
+		if t.Name() == "" || t.PkgPath() == localPkg {
+			return s.object(t)
+		}
+		name, ok := s.names[t]
+		if !ok {
+			name = t.Name()
+			if other, taken := s.taken[name]; taken && other != t {
+				pkg := t.PkgPath()[strings.LastIndex(t.PkgPath(), "/")+1:]
+				name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+			}
+			s.names[t], s.taken[name] = name, t
+			// Reserve the name first, types may refer to themselves
+			s.defs[name] = nil
+			s.defs[name] = s.object(t)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	}
+	return map[string]interface{}{}
+}
+
+// object describes a struct the way encoding/json writes it.
+func (s *schemaSet) object(t reflect.Type) map[string]interface{} {
+	props := map[string]interface{}{}
+	s.fields(t, props)
+	return map[string]interface{}{"type": "object", "properties": props}
+}
+
+func (s *schemaSet) fields(t reflect.Type, props map[string]interface{}) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				s.fields(ft, props)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		props[name] = s.of(f.Type)
+	}
+}
@@ -0,0 +1,22 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// handleGetPlans lists the configured plans and user assignments. With
+// ?user= it returns just the plan that applies to that user.
+func (s *Server) handleGetPlans(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if user := r.URL.Query().Get("user"); user != "" {
+		plan, ok := s.plans.PlanFor(user)
+		if !ok {
+			writeJSONError(w, http.StatusNotFound, "No plan applies to "+user, "NO_PLAN")
+			return
+		}
+		json.NewEncoder(w).Encode(plan)
+		return
+	}
+	json.NewEncoder(w).Encode(s.plans.Summary())
+}
@@ -0,0 +1,128 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/soroushbar/vantage/internal/store"
+	pkgmiddleware "github.com/soroushbar/vantage/pkg/middleware"
+)
+
+// requirePortal hides the /portal endpoints unless the portal is enabled.
+func (s *Server) requirePortal(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.Config.Portal.Enabled {
+			writeJSONError(w, http.StatusNotFound, "The developer portal is not enabled", "PORTAL_DISABLED")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handlePortalUsage reports the caller's requests, tokens and estimated cost
+// over the last ?days= (default 30, at most 90), in total, per model and
+// per day.
+func (s *Server) handlePortalUsage(w http.ResponseWriter, r *http.Request) {
+	days, _ := strconv.Atoi(r.URL.Query().Get("days"))
+	if days <= 0 {
+		days = 30
+	}
+	days = min(days, 90)
+	user := pkgmiddleware.UserID(r)
+	to := time.Now().UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+	query := store.StatsQuery{Granularity: "day", From: to.AddDate(0, 0, -days), To: to, User: user}
+
+	daily, err := s.Store.Stats(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	query.GroupBy = "model"
+	perModel, err := s.Store.Stats(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	totals := store.UsageTotals{}
+	for _, d := range daily {
+		addTotals(&totals, d.UsageTotals)
+	}
+	models := []store.UsageTotals{}
+	index := map[string]int{}
+	for _, m := range perModel {
+		i, ok := index[m.Key]
+		if !ok {
+			i = len(models)
+			index[m.Key] = i
+			models = append(models, store.UsageTotals{Key: m.Key})
+		}
+		addTotals(&models[i], m.UsageTotals)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"user_id":  user,
+		"from":     query.From.Format(time.RFC3339),
+		"to":       query.To.Format(time.RFC3339),
+		"totals":   totals,
+		"by_model": models,
+		"daily":    daily,
+	})
+}
+
+func addTotals(dst *store.UsageTotals, t store.UsageTotals) {
+	dst.Requests += t.Requests
+	dst.Tokens += t.Tokens
+	dst.Cost += t.Cost
+	dst.Blocked += t.Blocked
+	dst.Redacted += t.Redacted
+}
+
+// handlePortalLogs lists the caller's most recent interactions, at most
+// portal.log_limit of them. Bodies pass through the redaction patterns
+// again, since the audit log may hold them as sent; captured headers are
+// left out.
+func (s *Server) handlePortalLogs(w http.ResponseWriter, r *http.Request) {
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 || limit > s.Config.Portal.LogLimit {
+		limit = s.Config.Portal.LogLimit
+	}
+	logs, err := s.Store.GetLogs(store.LogFilter{Limit: limit, User: pkgmiddleware.UserID(r)})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for i := range logs {
+		logs[i].RequestBody, _ = s.portalRedactor.Redact(logs[i].RequestBody)
+		logs[i].ResponseBody, _ = s.portalRedactor.Redact(logs[i].ResponseBody)
+		logs[i].Headers = nil
+	}
+	if logs == nil {
+		logs = []store.InteractionRecord{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(logs)
+}
+
+// handlePortalQuota reports the caller's daily request allowance and the
+// plan that applies to them.
+func (s *Server) handlePortalQuota(w http.ResponseWriter, r *http.Request) {
+	user := pkgmiddleware.UserID(r)
+	resp := map[string]interface{}{"user_id": user}
+	if plan, ok := s.plans.PlanFor(user); ok {
+		resp["plan"] = plan
+	}
+	if s.Quotas != nil {
+		allowances, err := s.Quotas.Usage(user)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		resp["quota"] = allowances[0]
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,92 @@
+package server
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/soroushbar/vantage/pkg/vantage"
+)
+
+// compatProvider is a hosted OpenAI-compatible provider whose key may be
+// read from the secret store.
+type compatProvider interface {
+	vantage.Provider
+	SetKeySource(key func() string)
+}
+
+// modelRoute sends requests for models starting with model to the provider
+// mounted at prefix.
+type modelRoute struct {
+	model   string
+	prefix  string
+	handler http.Handler
+}
+
+// dispatchByModel serves /v1/* POST requests whose body names a model with
+// a routed prefix through that provider, as if they had been sent under
+// its mount prefix. Everything else goes to next.
+func (s *Server) dispatchByModel(next http.Handler) http.Handler {
+	if len(s.modelRoutes) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Body == nil || strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "failed to read request body", "BAD_REQUEST")
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		var req struct {
+			Model string `json:"model"`
+		}
+		json.Unmarshal(body, &req)
+		for _, route := range s.modelRoutes {
+			if req.Model == "" || !strings.HasPrefix(req.Model, route.model) {
+				continue
+			}
+			u := *r.URL
+			u.Path = route.prefix + r.URL.Path
+			u.RawPath = ""
+			r2 := r.Clone(r.Context())
+			r2.URL = &u
+			r2.RequestURI = u.RequestURI()
+			route.handler.ServeHTTP(w, r2)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) handleGetProviders(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.Status.Statuses())
+}
+
+func (s *Server) handleProviderWebhook(w http.ResponseWriter, r *http.Request) {
+	token := s.Status.WebhookToken()
+	if token == "" || subtle.ConstantTimeCompare([]byte(r.URL.Query().Get("token")), []byte(token)) != 1 {
+		http.Error(w, "invalid webhook token", http.StatusForbidden)
+		return
+	}
+
+	payload, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := s.Status.HandleWebhook(chi.URLParam(r, "name"), payload); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
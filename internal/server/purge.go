@@ -0,0 +1,87 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/soroushbar/vantage/internal/archive"
+	"github.com/soroushbar/vantage/internal/store"
+)
+
+// purgeResult is the answer to a purge. DeadLetters counts the matching
+// interactions and usage samples removed from the dead-letter file, and
+// Exports the records removed from log export objects, listing the objects
+// that could not be rewritten. ClickHouseError is set when the
+// interactions were purged from the store but not from ClickHouse.
+type purgeResult struct {
+	*store.PurgeReport
+	DeadLetters     int                  `json:"dead_letters"`
+	Exports         *archive.ExportPurge `json:"exports,omitempty"`
+	ClickHouseError string               `json:"clickhouse_error,omitempty"`
+}
+
+// handlePurgeLogs deletes every interaction matching the shared log
+// filters, e.g. ?user=alice for an erasure request, along with its
+// quarantined payload, triage entry, replays, access log entries, archive
+// object and any copy in the dead-letter file or a JSONL log export, and
+// folds the user's usage rollups into store.PurgedUser and deletes the
+// user's other records (see store.PurgeInteractions). At least one filter is required, and ?dry_run=true only
+// counts what would be deleted. The counts go to the admin audit trail.
+//
+// A purge that fails part way can simply be repeated; nothing is reported
+// as purged unless all of it was.
+func (s *Server) handlePurgeLogs(w http.ResponseWriter, r *http.Request) {
+	f, err := logFilter(r, 0)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error(), "BAD_REQUEST")
+		return
+	}
+	if !filtered(f) {
+		writeJSONError(w, http.StatusBadRequest, "a filter such as user is required", "FILTER_REQUIRED")
+		return
+	}
+	dryRun := r.URL.Query().Get("dry_run") == "true"
+	report, err := s.Store.PurgeInteractions(f, dryRun)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error(), "PURGE_FAILED")
+		return
+	}
+	result := purgeResult{PurgeReport: report}
+	if s.DeadLetters != nil {
+		if result.DeadLetters, err = s.DeadLetters.Purge(f, dryRun); err != nil {
+			writeJSONError(w, http.StatusInternalServerError, "interactions purged, but not their dead letters: "+err.Error(), "PURGE_FAILED")
+			return
+		}
+	}
+	if s.Exports != nil {
+		if result.Exports, err = s.Exports.Purge(r.Context(), f, dryRun); err != nil {
+			writeJSONError(w, http.StatusInternalServerError, "interactions purged, but not from the log exports: "+err.Error(), "PURGE_FAILED")
+			return
+		}
+		n, err := s.Store.PurgeAccess(result.Exports.IDs, dryRun)
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, "interactions purged, but not the access log of their exports: "+err.Error(), "PURGE_FAILED")
+			return
+		}
+		report.AccessLog += n
+	}
+	if !dryRun && s.ClickHouse != nil && len(report.IDs) > 0 {
+		if err := s.ClickHouse.Delete(r.Context(), report.IDs); err != nil {
+			log.Printf("Failed to purge %d interactions from clickhouse: %v", len(report.IDs), err)
+			result.ClickHouseError = err.Error()
+		}
+	}
+	if !dryRun {
+		log.Printf("Purged %d interactions for %s", report.Interactions, adminName(r.Context()))
+	}
+	recordChange(r, nil, result)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// filtered reports whether f narrows the log at all.
+func filtered(f store.LogFilter) bool {
+	return f.User != "" || f.Path != "" || !f.From.IsZero() || !f.To.IsZero() || f.Blocked != nil ||
+		len(f.Metadata) > 0 || f.Slow || f.Session != "" || f.Project != "" || f.Request != ""
+}
@@ -0,0 +1,72 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/soroushbar/vantage/internal/store"
+)
+
+// quarantineAccess limits the quarantine endpoints to quarantine.readers.
+// It runs after admin authentication, so the admin name is known.
+func (s *Server) quarantineAccess(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.Config.Quarantine.Enabled {
+			writeJSONError(w, http.StatusNotFound, "Quarantine is not enabled", "QUARANTINE_DISABLED")
+			return
+		}
+		if readers := s.Config.Quarantine.Readers; len(readers) > 0 && !slices.Contains(readers, adminName(r.Context())) {
+			writeJSONError(w, http.StatusForbidden, "Not allowed to read quarantined payloads", "FORBIDDEN")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleListQuarantine lists quarantined payloads without their bodies,
+// newest first, optionally for one ?user= or ?reason=, up to ?limit=
+// (default 100).
+func (s *Server) handleListQuarantine(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f := store.QuarantineFilter{User: q.Get("user"), Reason: q.Get("reason")}
+	f.Limit, _ = strconv.Atoi(q.Get("limit"))
+	if f.Limit <= 0 {
+		f.Limit = 100
+	}
+	payloads, err := s.Store.ListQuarantine(f)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(payloads)
+}
+
+// handleGetQuarantined returns a quarantined payload with its body. The
+// read is recorded in the access log against the blocked interaction.
+func (s *Server) handleGetQuarantined(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid quarantine id", "BAD_REQUEST")
+		return
+	}
+	p, err := s.Store.GetQuarantined(id)
+	if errors.Is(err, store.ErrNotFound) {
+		writeJSONError(w, http.StatusNotFound, "Quarantined payload not found", "NOT_FOUND")
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := s.recordReveal(r, "quarantine", store.InteractionRecord{ID: int(p.LogID)}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(p)
+}
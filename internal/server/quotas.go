@@ -0,0 +1,22 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// handleGetQuotas reports today's remaining request allowance per user,
+// optionally for a single ?user=.
+func (s *Server) handleGetQuotas(w http.ResponseWriter, r *http.Request) {
+	if s.Quotas == nil {
+		writeJSONError(w, http.StatusNotFound, "Request quotas are not enabled", "QUOTAS_DISABLED")
+		return
+	}
+	allowances, err := s.Quotas.Usage(r.URL.Query().Get("user"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(allowances)
+}
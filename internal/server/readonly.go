@@ -0,0 +1,85 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+
+	adminv1 "github.com/soroushbar/vantage/proto/vantage/admin/v1"
+)
+
+// grpcMutations are the gRPC admin methods refused in read-only mode.
+var grpcMutations = map[string]bool{
+	adminv1.AdminService_SetModelState_FullMethodName:    true,
+	adminv1.AdminService_SaveTemplate_FullMethodName:     true,
+	adminv1.AdminService_CreateSigningKey_FullMethodName: true,
+	adminv1.AdminService_DeleteSigningKey_FullMethodName: true,
+	adminv1.AdminService_CreateVirtualKey_FullMethodName: true,
+	adminv1.AdminService_RotateVirtualKey_FullMethodName: true,
+	adminv1.AdminService_RevokeVirtualKey_FullMethodName: true,
+}
+
+// ReadOnly reports whether the gateway is in read-only mode.
+func (s *Server) ReadOnly() bool {
+	return s.readOnly.Load()
+}
+
+// SetReadOnly switches read-only mode on or off.
+func (s *Server) SetReadOnly(enabled bool) {
+	s.readOnly.Store(enabled)
+}
+
+// pauseWhileReadOnly answers proxied requests with the configured status
+// while the gateway is read-only, before they reach the pipeline.
+func (s *Server) pauseWhileReadOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.ReadOnly() {
+			next.ServeHTTP(w, r)
+			return
+		}
+		cfg := s.Config.ReadOnly
+		if cfg.RetryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(cfg.RetryAfter.Seconds()))))
+		}
+		writeJSONError(w, cfg.Status, cfg.Message, "READ_ONLY")
+	})
+}
+
+// freezeWhileReadOnly refuses admin requests that change state while the
+// gateway is read-only; reads pass through.
+func (s *Server) freezeWhileReadOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+		default:
+			if s.ReadOnly() {
+				writeJSONError(w, http.StatusServiceUnavailable, "Changes are frozen while Vantage is in read-only mode", "READ_ONLY")
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) handleGetReadOnly(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]bool{"enabled": s.ReadOnly()})
+}
+
+// handleSetReadOnly toggles read-only mode. It is the one admin mutation
+// allowed while the gateway is read-only.
+func (s *Server) handleSetReadOnly(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Enabled *bool `json:"enabled"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Enabled == nil {
+		writeJSONError(w, http.StatusBadRequest, "enabled is required", "BAD_REQUEST")
+		return
+	}
+	recordChange(r, map[string]bool{"enabled": s.ReadOnly()}, map[string]bool{"enabled": *req.Enabled})
+	s.SetReadOnly(*req.Enabled)
+	log.Printf("Admin API: %s set read-only mode to %t", adminName(r.Context()), *req.Enabled)
+	s.handleGetReadOnly(w, r)
+}
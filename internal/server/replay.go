@@ -0,0 +1,188 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/soroushbar/vantage/internal/audit"
+	"github.com/soroushbar/vantage/internal/replay"
+	"github.com/soroushbar/vantage/internal/store"
+	"github.com/soroushbar/vantage/pkg/vantage"
+)
+
+// handleReplayLog sends a logged request to its upstream again, optionally
+// with another "model", and stores the response with its diff against the
+// original. Replays go through the model policy and the governance rules in
+// force now, so forbidden prompts and models are refused and PII is
+// redacted as on the proxy, but skip the rest of the pipeline and are not
+// logged as interactions. Interactions that were blocked are not replayed,
+// since the stage that blocked them may not be one of those.
+func (s *Server) handleReplayLog(w http.ResponseWriter, r *http.Request) {
+	rec, ok := s.loadLog(w, r)
+	if !ok {
+		return
+	}
+	if rec.IsBlocked {
+		writeJSONError(w, http.StatusConflict, "blocked interactions cannot be replayed", "REPLAY_BLOCKED")
+		return
+	}
+	var req struct {
+		Model string `json:"model"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeJSONError(w, http.StatusBadRequest, "invalid replay request", "BAD_REQUEST")
+		return
+	}
+	body := []byte(rec.RequestBody)
+	if req.Model != "" {
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(body, &fields); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "the logged request is not JSON, so its model cannot be changed", "BAD_REQUEST")
+			return
+		}
+		fields["model"], _ = json.Marshal(req.Model)
+		body, _ = json.Marshal(fields)
+	}
+
+	out, err := http.NewRequestWithContext(r.Context(), rec.Method, "http://upstream"+rec.Path, bytes.NewReader(body))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	out.Header.Set("Content-Type", "application/json")
+	h := s.handlerFor(rec.Path)
+	transport := h.Proxy.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	// The governance stages answer refused requests themselves, so the
+	// replay only reaches the upstream if forward runs
+	var forwarded bool
+	var upstreamErr error
+	var latency int64
+	forward := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		forwarded = true
+		h.Proxy.Director(req)
+		start := time.Now()
+		resp, err := transport.RoundTrip(req)
+		if err != nil {
+			upstreamErr = err
+			return
+		}
+		defer resp.Body.Close()
+		respBody, err := io.ReadAll(resp.Body)
+		latency = time.Since(start).Milliseconds()
+		if err != nil {
+			upstreamErr = err
+			return
+		}
+		rw.WriteHeader(resp.StatusCode)
+		rw.Write(respBody)
+	})
+	answer := httptest.NewRecorder()
+	h.Governance(forward).ServeHTTP(answer, out)
+	if !forwarded {
+		// Relay the refusal, e.g. FORBIDDEN_CONTENT or MODEL_DISABLED
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(answer.Code)
+		w.Write(answer.Body.Bytes())
+		return
+	}
+	if upstreamErr != nil {
+		writeJSONError(w, http.StatusBadGateway, "replay failed: "+upstreamErr.Error(), "UPSTREAM_ERROR")
+		return
+	}
+	respBody := answer.Body.Bytes()
+
+	usage, _, _ := audit.ParseUsage(rec.Path, respBody)
+	diff := replay.Compare(
+		replay.Response{StatusCode: rec.StatusCode, Body: []byte(rec.ResponseBody), Tokens: rec.Tokens, LatencyMs: rec.LatencyMs},
+		replay.Response{StatusCode: answer.Code, Body: respBody, Tokens: usage.Total(), LatencyMs: latency},
+	)
+	encoded, _ := json.Marshal(diff)
+	result := &store.Replay{
+		InteractionID: rec.ID,
+		CreatedAt:     time.Now(),
+		Author:        adminName(r.Context()),
+		Model:         req.Model,
+		StatusCode:    answer.Code,
+		LatencyMs:     latency,
+		Tokens:        usage.Total(),
+		ResponseBody:  string(respBody),
+		Diff:          encoded,
+	}
+	if err := s.Store.SaveReplay(rec.UserID, result); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := s.recordReveal(r, "replay", *rec); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// handleListReplays lists the replays of an interaction, newest first.
+func (s *Server) handleListReplays(w http.ResponseWriter, r *http.Request) {
+	rec, ok := s.loadLog(w, r)
+	if !ok {
+		return
+	}
+	replays, err := s.Store.ListReplays(rec.ID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := s.recordReveal(r, "replays", *rec); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(replays)
+}
+
+// loadLog reads the interaction named by the {id} URL parameter, restoring
+// archived bodies, and writes the error response when it cannot.
+func (s *Server) loadLog(w http.ResponseWriter, r *http.Request) (*store.InteractionRecord, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid log id", http.StatusBadRequest)
+		return nil, false
+	}
+	rec, err := s.Store.GetLog(id)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "log not found", http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+	if rec.ArchiveKey != "" && s.Archive != nil {
+		if err := s.Archive.Restore(r.Context(), rec); err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return nil, false
+		}
+	}
+	return rec, true
+}
+
+// handlerFor returns the provider handler that serves path.
+func (s *Server) handlerFor(path string) *vantage.Handler {
+	for prefix, h := range s.Providers {
+		if strings.HasPrefix(path, prefix+"/") {
+			return h
+		}
+	}
+	return s.cohere
+}
@@ -1,108 +1,992 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
+	"log"
+	"math"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
+	"regexp"
+	"slices"
 	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/soroushbar/vantage/internal/anomaly"
+	"github.com/soroushbar/vantage/internal/archive"
+	"github.com/soroushbar/vantage/internal/audit"
+	"github.com/soroushbar/vantage/internal/clickhouse"
 	"github.com/soroushbar/vantage/internal/config"
+	"github.com/soroushbar/vantage/internal/incident"
+	"github.com/soroushbar/vantage/internal/latency"
+	"github.com/soroushbar/vantage/internal/models"
+	"github.com/soroushbar/vantage/internal/plans"
+	"github.com/soroushbar/vantage/internal/provider"
+	"github.com/soroushbar/vantage/internal/quota"
+	"github.com/soroushbar/vantage/internal/reports"
+	"github.com/soroushbar/vantage/internal/routing"
+	"github.com/soroushbar/vantage/internal/secrets"
+	"github.com/soroushbar/vantage/internal/settings"
 	"github.com/soroushbar/vantage/internal/store"
+	"github.com/soroushbar/vantage/internal/telemetry"
+	"github.com/soroushbar/vantage/internal/trust"
+	"github.com/soroushbar/vantage/internal/upstream"
 	pkgmiddleware "github.com/soroushbar/vantage/pkg/middleware"
+	"github.com/soroushbar/vantage/pkg/providers/bedrock"
+	"github.com/soroushbar/vantage/pkg/providers/gemini"
+	"github.com/soroushbar/vantage/pkg/providers/groq"
+	"github.com/soroushbar/vantage/pkg/providers/local"
+	"github.com/soroushbar/vantage/pkg/providers/mistral"
+	"github.com/soroushbar/vantage/pkg/providers/mock"
+	"github.com/soroushbar/vantage/pkg/vantage"
 )
 
+// Services bundles the background components the HTTP layer reads from.
+// Limiter and Cache, when set, replace the in-process rate limiter and
+// response cache, e.g. with ones shared through Redis.
+type Services struct {
+	Models    *models.Registry
+	Reporter  *reports.Reporter
+	Status    *provider.StatusMonitor
+	Vault     pkgmiddleware.PIIVault
+	Notifier  Notifier
+	Quotas    *quota.Enforcer
+	Archive   *archive.Archiver
+	Trust     *trust.Scorer
+	Incidents *incident.Tracker
+	SLOs      *latency.SLOTracker
+	Anomalies *anomaly.Analyzer
+	Limiter   pkgmiddleware.RateLimiter
+	Cache     pkgmiddleware.ResponseCache
+	// Analytics serves /api/stats when set
+	Analytics *clickhouse.Store
+	// ClickHouse is the copy of the interaction log kept in ClickHouse,
+	// which purges are applied to as well
+	ClickHouse *clickhouse.Store
+	// DeadLetters is the file of failed store writes, which purges are
+	// applied to as well
+	DeadLetters *audit.DeadLetters
+	// Exports writes the log exports, which purges are applied to as well
+	Exports *archive.Exporter
+	// Secrets supplies the provider keys it holds, overriding the static ones
+	Secrets *secrets.Manager
+	// Settings holds the runtime settings; without it they are read from
+	// the config
+	Settings *settings.Manager
+}
+
 type Server struct {
-	Router *chi.Mux
-	Store  *store.Store
-	Config *config.Config
-	Proxy  *httputil.ReverseProxy
+	Services
+	Router   *chi.Mux
+	Store    *store.Store
+	Config   *config.Config
+	Proxy    *httputil.ReverseProxy
+	Pipeline http.Handler
+	// Providers holds the non-Cohere upstreams, keyed by mount prefix
+	Providers map[string]*vantage.Handler
+	// cohere is the handler behind Pipeline
+	cohere *vantage.Handler
+
+	// modelRoutes sends /v1/* requests for some models to a provider
+	modelRoutes []modelRoute
+	auditChan   chan pkgmiddleware.Interaction
+	upstreamURL *url.URL
+	admin       *adminGuard
+	signatures  *pkgmiddleware.SignatureVerifier
+	plans       *plans.Catalog
+	identity    func(http.Handler) http.Handler
+	readOnly    atomic.Bool
+	// governance, projectBudget and limiter follow the runtime settings
+	governance    atomic.Pointer[pkgmiddleware.GovernanceRules]
+	projectBudget atomic.Pointer[pkgmiddleware.ProjectBudget]
+	limiter       *toggledLimiter
+	// auditDrops counts the audit records dropped on a full queue, the
+	// last at lastAuditDrop (Unix nanoseconds)
+	auditDrops    atomic.Int64
+	lastAuditDrop atomic.Int64
+
+	openAPIOnce sync.Once
+	openAPI     []byte
+	openAPIErr  error
+
+	// portalIdentity only accepts signing keys and JWTs, never X-User-ID
+	portalIdentity func(http.Handler) http.Handler
+	portalRedactor *pkgmiddleware.Redactor
 }
 
-func NewServer(st *store.Store, cfg *config.Config, cohereKey string, auditChan chan pkgmiddleware.Interaction) *Server {
+func NewServer(st *store.Store, cfg *config.Config, cohereKey string, auditChan chan pkgmiddleware.Interaction, svc Services) *Server {
+	signatures := pkgmiddleware.NewSignatureVerifier(st, cfg.Signing.Tolerance)
 	s := &Server{
-		Router: chi.NewRouter(),
-		Store:  st,
-		Config: cfg,
-	}
+		Services: svc,
+		Router:   chi.NewRouter(),
+		Store:    st,
+		Config:   cfg,
 
-	// Setup Proxy
-	cohereURL, _ := url.Parse("https://api.cohere.com")
-	s.Proxy = httputil.NewSingleHostReverseProxy(cohereURL)
-	s.Proxy.Director = func(req *http.Request) {
-		req.Header.Set("Authorization", "Bearer "+cohereKey)
-		req.Header.Set("Host", cohereURL.Host)
-		req.URL.Scheme = cohereURL.Scheme
-		req.URL.Host = cohereURL.Host
-		req.Host = cohereURL.Host
+		auditChan:  auditChan,
+		admin:      newAdminGuard(cfg.Admin, svc.Notifier, signatures),
+		signatures: signatures,
+		plans:      plans.NewCatalog(cfg.Plans),
 	}
 
-	s.setupRoutes(auditChan)
+	s.readOnly.Store(cfg.ReadOnly.Enabled)
+	s.setupPipeline(cohereKey, auditChan)
+	s.setupRoutes()
 	return s
 }
 
-func (s *Server) setupRoutes(auditChan chan pkgmiddleware.Interaction) {
+func (s *Server) setupRoutes() {
 	r := s.Router
 
 	r.Use(middleware.RequestID)
-	r.Use(middleware.RealIP)
+	// Forwarding headers are only believed from trusted proxies; chi's
+	// RealIP would let any client choose the address lockouts, rate limits
+	// and ip_access see
+	proxies, _ := s.Config.Server.TrustedProxyPrefixes()
+	r.Use(pkgmiddleware.RealIPMiddleware(proxies))
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
 
-	// Basic CORS for UI
+	// CORS for the dashboard and other browser clients
+	c := s.Config.CORS
 	r.Use(cors.Handler(cors.Options{
-		AllowedOrigins:   []string{"http://localhost:3000"},
-		AllowedMethods:   []string{"GET", "POST", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Content-Type", "X-User-ID"},
-		AllowCredentials: true,
+		AllowedOrigins:   c.AllowedOrigins,
+		AllowedMethods:   c.AllowedMethods,
+		AllowedHeaders:   c.AllowedHeaders,
+		ExposedHeaders:   c.ExposedHeaders,
+		AllowCredentials: c.AllowCredentials,
+		MaxAge:           int(c.MaxAge.Seconds()),
 	}))
 
-	// Status Endpoint
-	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]string{
-			"status": "Vantage v2.0.0 is operational",
-			"node":   "Vantage-Core-01",
+	// Admin and UI routes get hardened response headers; proxied responses are left untouched
+	r.Group(func(r chi.Router) {
+		if s.Config.SecurityHeaders.Enabled {
+			h := s.Config.SecurityHeaders
+			r.Use(pkgmiddleware.SecurityHeadersMiddleware(pkgmiddleware.SecurityHeaders{
+				HSTSMaxAge:            h.HSTSMaxAge,
+				HSTSIncludeSubdomains: h.HSTSIncludeSubdomains,
+				FrameOptions:          h.FrameOptions,
+				ContentSecurityPolicy: h.ContentSecurityPolicy,
+				ReferrerPolicy:        h.ReferrerPolicy,
+			}))
+		}
+
+		// Status Endpoint
+		r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]string{
+				"status": "Vantage v2.0.0 is operational",
+				"node":   "Vantage-Core-01",
+			})
+		})
+
+		// Metrics & Health
+		// OpenMetrics carries the trace ID exemplars of the latency histograms
+		r.Handle("/metrics", promhttp.InstrumentMetricHandler(prometheus.DefaultRegisterer,
+			promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{EnableOpenMetrics: true})))
+		r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("OK"))
+		})
+		r.Get("/health/live", s.handleLive)
+		r.Get("/health/ready", s.handleReady)
+
+		// Internal APIs
+		r.Route("/api", func(r chi.Router) {
+			// Authenticated by the provider's own webhook token
+			r.Post("/providers/{name}/webhook", s.handleProviderWebhook)
+			// Describes the routes, not the data, so client generators need no token
+			r.Get("/openapi.json", s.handleOpenAPI)
+
+			r.Group(func(r chi.Router) {
+				// Before authentication so denied networks cannot trigger lockouts
+				r.Use(ipAccess("admin", s.Config.IPAccess.Admin))
+				r.Use(s.admin.Middleware)
+				r.Use(s.auditAdmin)
+				r.Get("/read-only", s.handleGetReadOnly)
+				r.Put("/read-only", s.handleSetReadOnly)
+				r.Group(func(r chi.Router) {
+					r.Use(s.freezeWhileReadOnly)
+					s.adminRoutes(r)
+				})
+			})
+		})
+
+		// Key holders' self-service view of their own usage; they are proxy clients
+		r.Route("/portal", func(r chi.Router) {
+			r.Use(ipAccess("proxy", s.Config.IPAccess.Proxy))
+			r.Use(s.requirePortal)
+			r.Use(s.portalIdentity)
+			r.Get("/usage", s.handlePortalUsage)
+			r.Get("/logs", s.handlePortalLogs)
+			r.Get("/quota", s.handlePortalQuota)
 		})
 	})
 
-	// Metrics & Health
-	r.Handle("/metrics", promhttp.Handler())
-	r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.Write([]byte("OK"))
+	// Proxied routes are paused in read-only mode
+	r.Group(func(r chi.Router) {
+		r.Use(ipAccess("proxy", s.Config.IPAccess.Proxy))
+		r.Use(s.pauseWhileReadOnly)
+		r.Handle("/v1/*", s.dispatchByModel(s.Pipeline))
+		r.Handle("/v2/*", s.Pipeline)
+		for prefix, h := range s.Providers {
+			r.Handle(prefix+"/*", h)
+		}
+		if s.Config.OpenAI.Enabled {
+			r.Post("/openai/v1/chat/completions", s.handleOpenAIChat)
+		}
+		r.With(s.identity).Post("/v1/templates/{name}/invoke", s.handleInvokeTemplate)
+		r.With(s.identity).Post("/v1/templates/{name}/feedback", s.handleTemplateFeedback)
 	})
+}
 
-	// Internal APIs
-	r.Route("/api", func(r chi.Router) {
-		r.Get("/logs", s.handleGetLogs)
+// setupPipeline builds the embeddable proxy handler from the config.
+func (s *Server) setupPipeline(cohereKey string, auditChan chan pkgmiddleware.Interaction) {
+	cohere := func() string { return cohereKey }
+	if s.Secrets.Has(secrets.Cohere) {
+		cohere = s.Secrets.Source(secrets.Cohere)
+	}
+	// Synchronous safety checks score prompts and responses like the audit worker
+	classify := func(body []byte) float64 { return audit.ClassifySafety(cohere(), body) }
+	classifyText := func(text string) float64 { return audit.ClassifyText(cohere(), text) }
+	var moderator audit.Moderator
+	if s.Config.Moderation.Enabled {
+		mistralKey := func() string { return s.Config.Providers.Mistral.APIKey }
+		if s.Secrets.Has(secrets.Mistral) {
+			mistralKey = s.Secrets.Source(secrets.Mistral)
+		}
+		moderator = audit.NewModerator(s.Config, cohere, mistralKey)
+		classify = func(body []byte) float64 { return audit.ModeratedSafety(moderator, body) }
+		classifyText = func(text string) float64 { return audit.ModeratedTextSafety(moderator, text) }
+	}
+	authenticators := []pkgmiddleware.Authenticator{s.signatures}
+	if s.Config.Identity.VirtualKeys.Enabled {
+		authenticators = append(authenticators, pkgmiddleware.NewVirtualKeyAuthenticator(virtualKeys{s.Store}))
+	}
+	if jwt := s.Config.Identity.JWT; jwt.Secret != "" {
+		authenticators = append(authenticators, pkgmiddleware.NewJWTAuthenticator(pkgmiddleware.JWTOptions{
+			Secret:   jwt.Secret,
+			Issuer:   jwt.Issuer,
+			Audience: jwt.Audience,
+			Claim:    jwt.Claim,
+		}))
+	}
+	s.portalIdentity = pkgmiddleware.IdentityMiddleware(pkgmiddleware.IdentityOptions{
+		Authenticators: authenticators,
+		Require:        true,
 	})
+	s.limiter = newToggledLimiter(s.Limiter, s.Config.RateLimit)
+	s.applySettings(s.settings())
+	if s.Settings != nil {
+		s.Settings.Subscribe(s.applySettings)
+	}
+	s.portalRedactor = pkgmiddleware.NewRedactor(redactionPatterns(s.Config.Redaction), nil, nil)
+	s.portalRedactor.SetLocalePatterns(localePatterns(s.Config.Redaction))
 
-	// The AI Proxy Pipeline
-	r.Group(func(r chi.Router) {
-		r.Use(pkgmiddleware.AuditMiddleware(auditChan))
-		r.Use(pkgmiddleware.GovernanceMiddleware(s.Config.ForbiddenKeywords, true))
+	opts := []vantage.Option{
+		vantage.WithAPIKeySource(cohere),
+		vantage.WithResponseHook(s.addUpstreamRetryHints),
+		vantage.WithRequestIDSource(func(r *http.Request) string { return middleware.GetReqID(r.Context()) }),
+		vantage.WithTrustedUserHeader(s.Config.Identity.TrustHeader),
+		vantage.WithRequireAuth(s.Config.Identity.Require),
+		vantage.WithAuditChannel(auditChan),
+		vantage.WithAuditBackpressure(pkgmiddleware.AuditBackpressure{
+			Strict:     s.Config.AuditQueue.Mode == "strict",
+			Wait:       s.Config.AuditQueue.Wait,
+			RetryAfter: s.Config.AuditQueue.RetryAfter,
+			OnOverflow: s.auditOverflow,
+		}),
+		vantage.WithHeaderCapture(pkgmiddleware.HeaderCapture{
+			Request:  s.Config.Headers.Capture.Request,
+			Response: s.Config.Headers.Capture.Response,
+			Mask:     s.Config.Headers.Capture.Mask,
+		}),
+		vantage.WithModelPolicy(s.Models),
+		vantage.WithFineTuning(pkgmiddleware.FineTunePolicy{
+			Creators:       s.Config.FineTuning.Creators,
+			Invokers:       s.Config.FineTuning.Invokers,
+			RestrictInvoke: s.Config.FineTuning.RestrictInvoke,
+		}, s.Store),
+		vantage.WithGovernanceRules(func() pkgmiddleware.GovernanceRules { return *s.governance.Load() }),
+	}
+	if len(s.Config.Headers.Rules) > 0 {
+		opts = append(opts, vantage.WithHeaderRules(headerRules(s.Config.Headers.Rules)...))
+	}
+	if s.Config.RequestSchemas.Enabled {
+		opts = append(opts, vantage.WithRequestSchemas(requestSchemas(s.Config.RequestSchemas)...))
+	}
+	// Rate limiting is always in place, as it may be turned on at runtime
+	opts = append(opts, vantage.WithRateLimit(s.limiter))
+	if c := s.Config.Concurrency; c.Enabled {
+		opts = append(opts, vantage.WithConcurrencyLimit(pkgmiddleware.NewConcurrencyLimiter(c.PerUser, c.Global, c.Users, c.QueueTimeout)))
+	}
+	for _, a := range authenticators {
+		opts = append(opts, vantage.WithAuthenticator(a))
+	}
+	if s.Quotas != nil {
+		opts = append(opts, vantage.WithQuota(s.Quotas))
+	}
+	if len(s.Config.Routing.Rules) > 0 {
+		opts = append(opts, vantage.WithModelRouter(routing.NewRouter(s.Config.Routing, s.Models)))
+	}
+	if c := s.Config.Conversations; c.Enabled {
+		opts = append(opts, vantage.WithConversationBudget(s.Store, pkgmiddleware.ConversationBudget{
+			MaxTokens: c.MaxTokens,
+			WarnRatio: c.WarnRatio,
+		}))
+	}
+	opts = append(opts, vantage.WithProjectBudgetSource(s.Store, func() pkgmiddleware.ProjectBudget { return *s.projectBudget.Load() }))
+	if p := s.Config.ParamPolicies; len(p.Users) > 0 || len(p.Teams) > 0 {
+		opts = append(opts, vantage.WithParamPolicies(paramPolicies(p)))
+	}
+	if len(s.Config.Plans.Plans) > 0 {
+		var resolver pkgmiddleware.PlanResolver = s.plans
+		if s.Trust != nil {
+			resolver = s.Trust.Plans(s.plans)
+		}
+		opts = append(opts, vantage.WithPlans(resolver))
+	}
+	if len(s.Config.Schedules.Rules) > 0 {
+		opts = append(opts, vantage.WithSchedule(scheduleOptions(s.Config.Schedules, classify, s.Config.Webhooks.SafetyThreshold)))
+	}
+	if m := s.Config.Moderation; m.Enabled && m.Enforce {
+		opts = append(opts, vantage.WithModeration(pkgmiddleware.ModerationOptions{
+			Moderate:   func(body []byte) (map[string]float64, error) { return audit.ModerateRequest(moderator, body) },
+			Thresholds: m.Thresholds,
+		}))
+	}
+	if s.Trust != nil {
+		syncSafety := map[string]bool{}
+		for tier, p := range s.Config.Trust.Tiers {
+			syncSafety[tier] = p.SyncSafety
+		}
+		opts = append(opts, vantage.WithTrust(s.Trust, pkgmiddleware.TrustOptions{
+			SyncSafety: syncSafety,
+			Classify:   classify,
+			Threshold:  s.Config.Webhooks.SafetyThreshold,
+		}))
+	}
+	if r := s.Config.ResponseSafety; r.Enforce {
+		opts = append(opts, vantage.WithResponseSafety(pkgmiddleware.ResponseSafetyOptions{
+			Classify:  classifyText,
+			Threshold: r.Threshold,
+			Fallback:  r.Fallback,
+		}))
+	}
+	if p := s.Config.ResponsePII; p.Enabled {
+		var patterns []pkgmiddleware.RedactionPattern
+		for _, b := range pkgmiddleware.BuiltinRedactionPatterns() {
+			if slices.Contains(p.Types, b.Name) {
+				patterns = append(patterns, b)
+			}
+		}
+		opts = append(opts, vantage.WithResponsePII(pkgmiddleware.ResponsePIIOptions{Patterns: patterns, Action: p.Action}))
+	}
+	if len(s.Config.Transforms) > 0 {
+		opts = append(opts, vantage.WithTransforms(requestTransforms(s.Config.Transforms)...))
+	}
+	if t := s.Config.Truncation; t.Enabled {
+		opts = append(opts, vantage.WithTruncation(pkgmiddleware.ContextWindows{
+			Default: t.DefaultWindow,
+			Models:  t.Windows,
+			Reserve: t.ReserveTokens,
+		}))
+	}
+	if d := s.Config.Dedup; d.Enabled {
+		opts = append(opts, vantage.WithDedup(pkgmiddleware.NewDeduplicator(pkgmiddleware.DedupOptions{
+			Window: d.Window,
+			Mode:   d.Mode,
+			Paths:  d.Paths,
+		})))
+	}
+	if s.Config.Cache.Enabled {
+		var cache pkgmiddleware.ResponseCache = pkgmiddleware.NewMemoryCache(s.Config.Cache.MaxEntries)
+		if s.Cache != nil {
+			cache = s.Cache
+		}
+		opts = append(opts, vantage.WithCache(cache, pkgmiddleware.CacheOptions{
+			TTL:     s.Config.Cache.TTL,
+			Paths:   s.Config.Cache.Paths,
+			PerUser: s.Config.Cache.PerUser,
+		}))
+	}
+
+	transport := upstream.NewTransport(s.Config.Transport)
+	if s.Config.Provider == "mock" {
+		transport = mock.New()
+	}
+	if s.Config.Chaos.Enabled {
+		transport = upstream.NewChaos(s.Config.Chaos, transport)
+	}
+	opts = append(opts, vantage.WithTransport(transport))
 
-		r.HandleFunc("/v1/*", func(w http.ResponseWriter, r *http.Request) {
-			s.Proxy.ServeHTTP(w, r)
+	cohereOpts := opts[:len(opts):len(opts)]
+	if len(s.Config.Upstreams.Targets) > 0 {
+		pool, err := upstream.NewPool(s.Config.Upstreams, transport)
+		if err != nil {
+			log.Printf("Upstream pool disabled: %v", err)
+		} else {
+			if s.Status != nil {
+				pool.SetStatus(s.Status)
+			}
+			cohereOpts = append(cohereOpts, vantage.WithTransport(pool))
+		}
+	}
+	h := vantage.New(cohereOpts...)
+	s.Proxy = h.Proxy
+	s.upstreamURL = h.Upstream
+	s.Pipeline = h
+	s.cohere = h
+	s.identity = h.Identity
+
+	// Additional providers share the pipeline and are mounted under their own prefix
+	s.Providers = map[string]*vantage.Handler{}
+	if g := s.Config.Providers.Gemini; g.Enabled {
+		provider, err := gemini.New(g.APIKey, g.BaseURL)
+		if err != nil {
+			log.Printf("Gemini provider disabled: %v", err)
+		} else {
+			if s.Secrets.Has(secrets.Gemini) {
+				provider.SetKeySource(s.Secrets.Source(secrets.Gemini))
+			}
+			s.Providers[gemini.PathPrefix] = vantage.New(append(opts[:len(opts):len(opts)], vantage.WithProvider(provider))...)
+		}
+	}
+	if b := s.Config.Providers.Bedrock; b.Enabled {
+		provider, err := bedrock.New(context.Background(), b.Region, b.BaseURL)
+		if err != nil {
+			log.Printf("Bedrock provider disabled: %v", err)
+		} else {
+			s.Providers[bedrock.PathPrefix] = vantage.New(append(opts[:len(opts):len(opts)], vantage.WithProvider(provider))...)
+		}
+	}
+	for _, c := range []struct {
+		prefix, name string
+		cfg          config.CompatProviderConfig
+		secret       string
+		new          func(apiKey, baseURL string) (compatProvider, error)
+	}{
+		{mistral.PathPrefix, "Mistral", s.Config.Providers.Mistral, secrets.Mistral, func(k, u string) (compatProvider, error) { return mistral.New(k, u) }},
+		{groq.PathPrefix, "Groq", s.Config.Providers.Groq, secrets.Groq, func(k, u string) (compatProvider, error) { return groq.New(k, u) }},
+	} {
+		if !c.cfg.Enabled {
+			continue
+		}
+		provider, err := c.new(c.cfg.APIKey, c.cfg.BaseURL)
+		if err != nil {
+			log.Printf("%s provider disabled: %v", c.name, err)
+			continue
+		}
+		if s.Secrets.Has(c.secret) {
+			provider.SetKeySource(s.Secrets.Source(c.secret))
+		}
+		h := vantage.New(append(opts[:len(opts):len(opts)], vantage.WithProvider(provider))...)
+		s.Providers[c.prefix] = h
+		for _, model := range c.cfg.ModelPrefixes {
+			s.modelRoutes = append(s.modelRoutes, modelRoute{model: model, prefix: c.prefix, handler: h})
+		}
+	}
+	for _, l := range s.Config.Providers.Local {
+		provider, err := local.New(l.Name, l.BaseURL)
+		if err != nil {
+			log.Printf("Local provider disabled: %v", err)
+			continue
+		}
+		s.Providers[provider.Prefix()] = vantage.New(append(opts[:len(opts):len(opts)], vantage.WithProvider(provider))...)
+	}
+}
+
+// scheduleOptions converts the schedule config, which was validated at
+// load, for ScheduleMiddleware.
+func scheduleOptions(cfg config.ScheduleConfig, classify func([]byte) float64, threshold float64) pkgmiddleware.ScheduleOptions {
+	fallback, _ := time.LoadLocation(cfg.Timezone)
+	zones := map[string]*time.Location{}
+	for user, tz := range cfg.UserTimezones {
+		zones[user], _ = time.LoadLocation(tz)
+	}
+	opts := pkgmiddleware.ScheduleOptions{
+		Location: func(userID string) *time.Location {
+			if loc, ok := zones[userID]; ok {
+				return loc
+			}
+			return fallback
+		},
+		Classify:  classify,
+		Threshold: threshold,
+	}
+	for _, r := range cfg.Rules {
+		days, start, end, _ := cfg.Windows[r.Window].Parse()
+		opts.Rules = append(opts.Rules, pkgmiddleware.ScheduleRule{
+			Name:       r.Name,
+			WindowName: r.Window,
+			Window:     pkgmiddleware.TimeWindow{Days: days, Start: start, End: end},
+			Outside:    r.Outside,
+			DenyModels: r.DenyModels,
+			SyncSafety: r.SyncSafety,
 		})
-	})
+	}
+	return opts
+}
+
+// redactionPatterns returns the enabled built-in and custom patterns,
+// after those of the locales applied to every prompt. The config is
+// validated at load, so invalid patterns are only logged here.
+func redactionPatterns(cfg config.RedactionConfig) []pkgmiddleware.RedactionPattern {
+	patterns := []pkgmiddleware.RedactionPattern{}
+	for _, lang := range pkgmiddleware.LocaleLanguages {
+		if l, ok := cfg.Locales[lang]; ok && l.Always {
+			patterns = append(patterns, localeRedaction(lang, l)...)
+		}
+	}
+	for _, p := range pkgmiddleware.BuiltinRedactionPatterns() {
+		if enabled, ok := cfg.Builtins[p.Name]; !ok || enabled {
+			patterns = append(patterns, p)
+		}
+	}
+	for _, c := range cfg.Patterns {
+		if !c.IsEnabled() {
+			continue
+		}
+		p, err := pkgmiddleware.NewRedactionPattern(c.Name, c.Regex, c.Replacement)
+		if err != nil {
+			log.Printf("Skipping redaction pattern: %v", err)
+			continue
+		}
+		patterns = append(patterns, p)
+	}
+	return patterns
+}
+
+// localePatterns returns the patterns of the locales applied only to
+// prompts detected in their language.
+func localePatterns(cfg config.RedactionConfig) map[string][]pkgmiddleware.RedactionPattern {
+	locales := map[string][]pkgmiddleware.RedactionPattern{}
+	for lang, l := range cfg.Locales {
+		if !l.Always {
+			locales[lang] = localeRedaction(lang, l)
+		}
+	}
+	return locales
+}
+
+// localeRedaction returns the enabled built-in and custom patterns of one
+// locale.
+func localeRedaction(lang string, l config.LocaleRedaction) []pkgmiddleware.RedactionPattern {
+	var patterns []pkgmiddleware.RedactionPattern
+	for _, p := range pkgmiddleware.LocaleRedactionPatterns(lang) {
+		if enabled, ok := l.Builtins[p.Name]; !ok || enabled {
+			patterns = append(patterns, p)
+		}
+	}
+	for _, c := range l.Patterns {
+		if !c.IsEnabled() {
+			continue
+		}
+		p, err := pkgmiddleware.NewRedactionPattern(c.Name, c.Regex, c.Replacement)
+		if err != nil {
+			log.Printf("Skipping redaction pattern: %v", err)
+			continue
+		}
+		patterns = append(patterns, p)
+	}
+	return patterns
+}
+
+// countRedactions feeds the redaction metric.
+func countRedactions(pattern string, matches int) {
+	telemetry.RedactionsTotal.WithLabelValues(pattern).Add(float64(matches))
+}
+
+// governanceProfiles builds the profiles of cfg; the fields a profile
+// leaves unset keep the global setting.
+func (s *Server) governanceProfiles(cfg *config.Config) []pkgmiddleware.GovernanceProfile {
+	profiles := make([]pkgmiddleware.GovernanceProfile, 0, len(cfg.Governance.Profiles))
+	for _, c := range cfg.Governance.Profiles {
+		keywords := c.ForbiddenKeywords
+		if keywords == nil {
+			keywords = cfg.ForbiddenKeywords
+		}
+		redaction := cfg.Redaction
+		if c.Redaction != nil {
+			redaction = *c.Redaction
+		}
+		profile := pkgmiddleware.GovernanceProfile{Name: c.Name, Paths: c.Paths, ForbiddenKeywords: keywords}
+		if redaction.Enabled {
+			var vault pkgmiddleware.PIIVault
+			if redaction.Mode == "tokenize" {
+				vault = s.Vault
+			}
+			profile.Redactor = pkgmiddleware.NewRedactor(redactionPatterns(redaction), vault, countRedactions)
+			profile.Redactor.SetLocalePatterns(localePatterns(redaction))
+		}
+		profiles = append(profiles, profile)
+	}
+	return profiles
+}
+
+// headerRules compiles the configured header rules, which were validated at load.
+func headerRules(cfg []config.HeaderRule) []pkgmiddleware.HeaderRule {
+	rules := make([]pkgmiddleware.HeaderRule, 0, len(cfg))
+	for _, c := range cfg {
+		rule := pkgmiddleware.HeaderRule{Name: c.Name, Header: c.Header, Required: c.Required, Paths: c.Paths}
+		if c.Pattern != "" {
+			rule.Pattern = regexp.MustCompile(c.Pattern)
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// paramPolicies converts the parameter policies, which were validated at
+// load, for ParamPolicyMiddleware.
+func paramPolicies(cfg config.ParamPoliciesConfig) pkgmiddleware.ParamPolicies {
+	convert := func(name string, c config.ParamPolicyConfig) pkgmiddleware.ParamPolicy {
+		p := pkgmiddleware.ParamPolicy{
+			Name:           name,
+			Model:          c.Model,
+			MaxTokens:      c.MaxTokens,
+			Temperature:    c.Temperature,
+			MaxTokensLimit: c.MaxTokensLimit,
+			DisallowTools:  c.DisallowTools,
+			Reject:         c.Action == "reject",
+		}
+		if len(c.TemperatureRange) == 2 {
+			p.MinTemperature, p.MaxTemperature = &c.TemperatureRange[0], &c.TemperatureRange[1]
+		}
+		return p
+	}
+	policies := pkgmiddleware.ParamPolicies{
+		Users:  map[string]pkgmiddleware.ParamPolicy{},
+		Teams:  map[string]pkgmiddleware.ParamPolicy{},
+		TeamOf: map[string]string{},
+	}
+	for user, c := range cfg.Users {
+		policies.Users[user] = convert("user:"+user, c)
+	}
+	for team, c := range cfg.Teams {
+		policies.Teams[team] = convert("team:"+team, c)
+		for _, user := range c.Members {
+			policies.TeamOf[user] = team
+		}
+	}
+	return policies
+}
+
+func requestTransforms(cfg []config.TransformConfig) []pkgmiddleware.RequestTransform {
+	transforms := make([]pkgmiddleware.RequestTransform, 0, len(cfg))
+	for _, c := range cfg {
+		transforms = append(transforms, pkgmiddleware.RequestTransform{
+			Name:         c.Name,
+			Paths:        c.Paths,
+			SystemPrompt: c.SystemPrompt,
+			Instructions: c.AppendInstructions,
+			MaxParams:    c.MaxParams,
+			RemoveParams: c.RemoveParams,
+		})
+	}
+	return transforms
 }
 
+// requestSchemas parses the configured schemas, which were validated at
+// load, and adds the built-in ones for paths without one.
+func requestSchemas(cfg config.RequestSchemasConfig) []pkgmiddleware.RequestSchema {
+	var schemas []pkgmiddleware.RequestSchema
+	covered := map[string]bool{}
+	for _, route := range cfg.Routes {
+		schema, err := route.Parse()
+		if err != nil {
+			log.Printf("Request schema for %s skipped: %v", route.Path, err)
+			continue
+		}
+		schemas = append(schemas, pkgmiddleware.RequestSchema{Path: route.Path, Schema: schema})
+		covered[route.Path] = true
+	}
+	if cfg.Builtin {
+		for _, builtin := range pkgmiddleware.BuiltinRequestSchemas() {
+			if !covered[builtin.Path] {
+				schemas = append(schemas, builtin)
+			}
+		}
+	}
+	return schemas
+}
+
+// adminRoutes registers the /api endpoints that require admin authentication.
+func (s *Server) adminRoutes(r chi.Router) {
+	r.Get("/logs", s.handleGetLogs)
+	r.Get("/logs/verify", s.handleVerifyLogs)
+	r.Get("/logs/export", s.handleExportLogs)
+	r.Get("/logs/search", s.handleSearchLogs)
+	r.Get("/logs/triage", s.handleListTriage)
+	r.Post("/logs/purge", s.handlePurgeLogs)
+	r.Get("/logs/{id}", s.handleGetLog)
+	r.Post("/logs/{id}/replay", s.handleReplayLog)
+	r.Get("/logs/{id}/replays", s.handleListReplays)
+	r.Patch("/logs/{id}/status", s.handleSetLogStatus)
+	r.Get("/exports", s.handleListExports)
+	r.Get("/sessions/{id}", s.handleGetSession)
+	r.Get("/access-log", s.handleGetAccessLog)
+	r.Get("/admin-audit", s.handleListAdminActions)
+	r.With(s.quarantineAccess).Get("/quarantine", s.handleListQuarantine)
+	r.With(s.quarantineAccess).Get("/quarantine/{id}", s.handleGetQuarantined)
+	r.Get("/summary", s.handleGetSummary)
+	r.Get("/stats", s.handleGetStats)
+	r.Get("/stats/latency", s.handleGetLatencyStats)
+	r.Get("/reports", s.handleComplianceReport)
+	r.Get("/reports/idle", s.handleIdleReport)
+	r.Get("/webhooks/deliveries", s.handleGetDeliveries)
+	r.Get("/incidents", s.handleListIncidents)
+	r.Get("/incidents/{id}", s.handleGetIncident)
+	r.Post("/incidents/{id}/acknowledge", s.handleAcknowledgeIncident)
+	r.Post("/incidents/{id}/resolve", s.handleResolveIncident)
+	r.Post("/incidents/{id}/notes", s.handleIncidentNote)
+	r.Get("/alerts", s.handleListAlerts)
+	r.Get("/models", s.handleListModels)
+	r.Put("/models/{name}", s.handleSetModelState)
+	r.Get("/artifacts", s.handleGetArtifacts)
+	r.Get("/providers", s.handleGetProviders)
+	r.Get("/config", s.handleGetConfig)
+	r.Get("/settings", s.handleListSettings)
+	r.Get("/settings/{key}", s.handleGetSetting)
+	r.Put("/settings/{key}", s.handlePutSetting)
+	r.Delete("/settings/{key}", s.handleResetSetting)
+	r.Get("/quotas", s.handleGetQuotas)
+	r.Get("/plans", s.handleGetPlans)
+	r.Get("/trust", s.handleGetTrust)
+	r.Post("/trust/{user}/feedback", s.handleTrustFeedback)
+	r.Get("/signing-keys", s.handleListSigningKeys)
+	r.Post("/signing-keys", s.handleCreateSigningKey)
+	r.Delete("/signing-keys/{name}", s.handleDeleteSigningKey)
+	r.Get("/keys", s.handleListVirtualKeys)
+	r.Post("/keys", s.handleCreateVirtualKey)
+	r.Get("/keys/{id}", s.handleGetVirtualKey)
+	r.Post("/keys/{id}/rotate", s.handleRotateVirtualKey)
+	r.Delete("/keys/{id}", s.handleRevokeVirtualKey)
+	r.Get("/templates", s.handleListTemplates)
+	r.Post("/templates", s.handleSaveTemplate)
+	r.Get("/templates/{name}", s.handleGetTemplate)
+	r.Get("/templates/{name}/splits", s.handleGetTemplateSplits)
+	r.Put("/templates/{name}/splits", s.handleSetTemplateSplits)
+	r.Post("/templates/{name}/promote", s.handlePromoteTemplate)
+	r.Post("/templates/{name}/rollback", s.handleRollbackTemplate)
+	r.Get("/templates/{name}/stats", s.handleTemplateStats)
+}
+
+// addUpstreamRetryHints guarantees upstream 429s carry a Retry-After header.
+// The upstream's own hints win: its Retry-After is passed through, its
+// X-RateLimit-Limit, -Remaining and -Reset are copied to the RateLimit-*
+// headers it did not send, and a missing Retry-After is taken from its
+// reset. Only a 429 without any of them gets rate_limit.upstream_retry_after.
+func (s *Server) addUpstreamRetryHints(resp *http.Response) error {
+	if resp.StatusCode != http.StatusTooManyRequests {
+		return nil
+	}
+	h := resp.Header
+	for _, name := range []string{"Limit", "Remaining"} {
+		if v := h.Get("X-RateLimit-" + name); v != "" && h.Get("RateLimit-"+name) == "" {
+			h.Set("RateLimit-"+name, v)
+		}
+	}
+	if h.Get("RateLimit-Reset") == "" {
+		if reset, ok := parseReset(h.Get("X-RateLimit-Reset"), time.Now()); ok {
+			h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(reset)))
+		}
+	}
+	if h.Get("Retry-After") != "" {
+		return nil
+	}
+	if reset, ok := parseReset(h.Get("RateLimit-Reset"), time.Now()); ok {
+		h.Set("Retry-After", strconv.Itoa(ceilSeconds(reset)))
+		return nil
+	}
+	h.Set("Retry-After", strconv.Itoa(ceilSeconds(s.Config.RateLimit.UpstreamRetryAfter)))
+	return nil
+}
+
+// parseReset reads a rate limit reset header as the time until the limit
+// resets. Providers send seconds to wait, a Unix timestamp in seconds, or
+// a duration such as "6m0s".
+func parseReset(v string, now time.Time) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		n, err := strconv.ParseFloat(v, 64)
+		if err != nil || n < 0 {
+			return 0, false
+		}
+		// No provider resets a limit over 30 years away, so larger values
+		// are timestamps.
+		if n > 1e9 {
+			d = time.Unix(int64(n), 0).Sub(now)
+		} else {
+			d = time.Duration(n * float64(time.Second))
+		}
+	}
+	return max(d, 0), true
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// handleGetLogs lists interactions. Raw bodies are only included with
+// ?bodies=true, and every record revealed that way is access-logged.
 func (s *Server) handleGetLogs(w http.ResponseWriter, r *http.Request) {
-	limitStr := r.URL.Query().Get("limit")
-	limit, _ := strconv.Atoi(limitStr)
+	f, err := logFilter(r, 50)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	logs, err := s.Store.GetLogs(f)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.writeLogList(w, r, logs)
+}
+
+// writeLogList writes interactions without their bodies, or with them and
+// access-logged when the request asks for ?bodies=true.
+func (s *Server) writeLogList(w http.ResponseWriter, r *http.Request, logs []store.InteractionRecord) {
+	if r.URL.Query().Get("bodies") == "true" {
+		if err := s.recordReveal(r, "list", logs...); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	} else {
+		for i := range logs {
+			logs[i].RequestBody, logs[i].ResponseBody = "", ""
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(logs)
+}
+
+func (s *Server) handleIdleReport(w http.ResponseWriter, r *http.Request) {
+	report := s.Reporter.Latest()
+	if report == nil || r.URL.Query().Get("refresh") == "true" {
+		var err error
+		report, err = s.Reporter.Generate()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// handleComplianceReport builds the compliance report of the last complete
+// ?period=weekly (default) or monthly in reports.compliance.timezone, or of
+// ?from= to ?to=, as ?format=json (default), html or pdf.
+func (s *Server) handleComplianceReport(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	cfg := s.Config.Reports.Compliance
+	period := q.Get("period")
+	if period == "" {
+		period = "weekly"
+	}
+	if period != "weekly" && period != "monthly" {
+		writeJSONError(w, http.StatusBadRequest, "period must be weekly or monthly", "BAD_REQUEST")
+		return
+	}
+	loc, err := time.LoadLocation(cfg.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	from, to := reports.PeriodBounds(period, time.Now().In(loc))
+	if q.Get("from") != "" || q.Get("to") != "" {
+		period = ""
+		if to, err = statsTime(q.Get("to"), to); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "to must be YYYY-MM-DD or RFC 3339", "BAD_REQUEST")
+			return
+		}
+		if from, err = statsTime(q.Get("from"), from); err != nil || !from.Before(to) {
+			writeJSONError(w, http.StatusBadRequest, "from must be YYYY-MM-DD or RFC 3339 and before to", "BAD_REQUEST")
+			return
+		}
+	}
+
+	report, err := reports.BuildCompliance(s.Store, period, from, to, cfg.TopN)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	switch q.Get("format") {
+	case "", "json":
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(report)
+	case "html":
+		html, err := report.HTML()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(html)
+	case "pdf":
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Header().Set("Content-Disposition", `attachment; filename="vantage-compliance-`+from.Format(time.DateOnly)+`.pdf"`)
+		w.Write(report.PDF())
+	default:
+		writeJSONError(w, http.StatusBadRequest, "format must be json, html or pdf", "BAD_REQUEST")
+	}
+}
+
+func (s *Server) handleGetLog(w http.ResponseWriter, r *http.Request) {
+	rec, ok := s.loadLog(w, r)
+	if !ok {
+		return
+	}
+	if err := s.recordReveal(r, "view", *rec); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if t, err := s.Store.GetTriage(int64(rec.ID)); err == nil {
+		rec.Triage = t
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rec)
+}
+
+func (s *Server) handleVerifyLogs(w http.ResponseWriter, r *http.Request) {
+	report, err := s.Store.VerifyChain()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+func (s *Server) handleGetDeliveries(w http.ResponseWriter, r *http.Request) {
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
 	if limit == 0 {
 		limit = 50
 	}
-	logs, err := s.Store.GetLogs(limit)
+	deliveries, err := s.Store.ListDeliveries(limit)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(logs)
+	json.NewEncoder(w).Encode(deliveries)
+}
+
+func (s *Server) handleGetArtifacts(w http.ResponseWriter, r *http.Request) {
+	artifacts, err := s.Store.ListArtifacts()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(artifacts)
 }
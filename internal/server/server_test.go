@@ -0,0 +1,76 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/soroushbar/vantage/internal/config"
+)
+
+func TestAddUpstreamRetryHints(t *testing.T) {
+	s := &Server{Config: config.Default()}
+	in30s := strconv.FormatInt(time.Now().Add(30*time.Second).Unix(), 10)
+
+	tests := []struct {
+		name   string
+		header http.Header
+		want   map[string]string
+	}{{
+		name: "upstream Retry-After passed through",
+		header: http.Header{
+			"Retry-After":       {"12"},
+			"X-Ratelimit-Reset": {in30s},
+		},
+		want: map[string]string{"Retry-After": "12", "RateLimit-Reset": "30"},
+	}, {
+		name: "X-RateLimit headers converted",
+		header: http.Header{
+			"X-Ratelimit-Limit":     {"100"},
+			"X-Ratelimit-Remaining": {"0"},
+			"X-Ratelimit-Reset":     {"20"},
+		},
+		want: map[string]string{
+			"Retry-After":         "20",
+			"RateLimit-Limit":     "100",
+			"RateLimit-Remaining": "0",
+			"RateLimit-Reset":     "20",
+		},
+	}, {
+		name:   "reset timestamp converted",
+		header: http.Header{"X-Ratelimit-Reset": {in30s}},
+		want:   map[string]string{"Retry-After": "30", "RateLimit-Reset": "30"},
+	}, {
+		name:   "reset duration converted",
+		header: http.Header{"X-Ratelimit-Reset": {"1m30s"}},
+		want:   map[string]string{"Retry-After": "90"},
+	}, {
+		name:   "RateLimit-Reset used",
+		header: http.Header{"Ratelimit-Reset": {"7"}},
+		want:   map[string]string{"Retry-After": "7"},
+	}, {
+		name:   "configured fallback",
+		header: http.Header{},
+		want:   map[string]string{"Retry-After": "5"},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{StatusCode: http.StatusTooManyRequests, Header: tt.header}
+			if err := s.addUpstreamRetryHints(resp); err != nil {
+				t.Fatal(err)
+			}
+			for name, want := range tt.want {
+				if got := resp.Header.Get(name); got != want {
+					t.Errorf("%s = %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+
+	ok := &http.Response{StatusCode: http.StatusOK, Header: http.Header{"X-Ratelimit-Reset": {"20"}}}
+	s.addUpstreamRetryHints(ok)
+	if got := ok.Header.Get("Retry-After"); got != "" {
+		t.Errorf("Retry-After on a 200 = %q", got)
+	}
+}
@@ -0,0 +1,37 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/soroushbar/vantage/internal/store"
+)
+
+// handleGetSession returns a session's interactions, with their bodies,
+// oldest first, so that a flagged message can be read in context. Session
+// IDs come from clients, so ?user= separates users who picked the same
+// one. Every interaction returned is access-logged.
+func (s *Server) handleGetSession(w http.ResponseWriter, r *http.Request) {
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 {
+		limit = 500
+	}
+	sess, err := s.Store.GetSession(chi.URLParam(r, "id"), r.URL.Query().Get("user"), limit)
+	if errors.Is(err, store.ErrNotFound) {
+		writeJSONError(w, http.StatusNotFound, "Session not found", "NOT_FOUND")
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := s.recordReveal(r, "session", sess.Interactions...); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sess)
+}
@@ -0,0 +1,173 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/soroushbar/vantage/internal/config"
+	"github.com/soroushbar/vantage/internal/settings"
+	pkgmiddleware "github.com/soroushbar/vantage/pkg/middleware"
+)
+
+// settings returns the current runtime settings.
+func (s *Server) settings() settings.Values {
+	if s.Settings == nil {
+		return settings.FromConfig(s.Config)
+	}
+	return s.Settings.Values()
+}
+
+// runtimeConfig returns the config with the current runtime settings in
+// place of the bootstrap values of config.yaml.
+func (s *Server) runtimeConfig() *config.Config {
+	cfg := *s.Config
+	s.settings().Apply(&cfg)
+	return &cfg
+}
+
+// applySettings rebuilds the parts of the pipeline that follow the runtime
+// settings; requests already past them are not affected.
+func (s *Server) applySettings(v settings.Values) {
+	cfg := *s.Config
+	v.Apply(&cfg)
+
+	rules := pkgmiddleware.GovernanceRules{
+		ForbiddenKeywords: cfg.ForbiddenKeywords,
+		Profiles:          s.governanceProfiles(&cfg),
+	}
+	if cfg.Redaction.Enabled {
+		rules.Redactor = pkgmiddleware.NewRedactor(redactionPatterns(cfg.Redaction), s.Vault, countRedactions)
+		rules.Redactor.SetLocalePatterns(localePatterns(cfg.Redaction))
+	}
+	s.governance.Store(&rules)
+	s.projectBudget.Store(&pkgmiddleware.ProjectBudget{
+		Limits:    cfg.Projects.Budgets,
+		WarnRatio: cfg.Projects.WarnRatio,
+	})
+	s.limiter.set(cfg.RateLimit)
+}
+
+// limitSetter is implemented by the rate limiters whose allowance can be
+// changed in place.
+type limitSetter interface {
+	SetLimit(limit int, window time.Duration)
+}
+
+// toggledLimiter is the proxy rate limiter, which the runtime settings turn
+// on and off and resize.
+type toggledLimiter struct {
+	limiter pkgmiddleware.RateLimiter
+	enabled atomic.Bool
+}
+
+// newToggledLimiter wraps shared, or an in-memory limiter when it is nil.
+func newToggledLimiter(shared pkgmiddleware.RateLimiter, cfg config.RateLimitConfig) *toggledLimiter {
+	if shared == nil {
+		shared = pkgmiddleware.NewWindowLimiter(cfg.Requests, cfg.Window)
+	}
+	return &toggledLimiter{limiter: shared}
+}
+
+func (t *toggledLimiter) set(cfg config.RateLimitConfig) {
+	if cfg.Enabled {
+		if l, ok := t.limiter.(limitSetter); ok {
+			l.SetLimit(cfg.Requests, cfg.Window)
+		} else {
+			log.Printf("Rate limiter %T cannot be resized; keeping its allowance", t.limiter)
+		}
+	}
+	t.enabled.Store(cfg.Enabled)
+}
+
+func (t *toggledLimiter) Allow(key string) pkgmiddleware.RateLimitState {
+	if !t.enabled.Load() {
+		return pkgmiddleware.RateLimitState{Allowed: true}
+	}
+	return t.limiter.Allow(key)
+}
+
+// handleListSettings lists the runtime settings with who last changed them
+// and when; "config" is their seeding from config.yaml.
+func (s *Server) handleListSettings(w http.ResponseWriter, r *http.Request) {
+	if !s.requireSettings(w) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.Settings.List())
+}
+
+// handleGetSetting returns one runtime setting.
+func (s *Server) handleGetSetting(w http.ResponseWriter, r *http.Request) {
+	if !s.requireSettings(w) {
+		return
+	}
+	setting, ok := s.Settings.Get(chi.URLParam(r, "key"))
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, "unknown setting", "NOT_FOUND")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(setting)
+}
+
+// handlePutSetting replaces a runtime setting with the body, the JSON of
+// its config.yaml section, e.g. {"enabled": true, "requests": 100,
+// "window": "1m"} for rate_limit. It applies at once.
+func (s *Server) handlePutSetting(w http.ResponseWriter, r *http.Request) {
+	if !s.requireSettings(w) {
+		return
+	}
+	value, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error(), "BAD_REQUEST")
+		return
+	}
+	key := chi.URLParam(r, "key")
+	before, _ := s.Settings.Get(key)
+	setting, err := s.Settings.Set(key, value, adminName(r.Context()))
+	s.writeSettingChange(w, r, before, setting, err)
+}
+
+// handleResetSetting sets a runtime setting back to its value in
+// config.yaml.
+func (s *Server) handleResetSetting(w http.ResponseWriter, r *http.Request) {
+	if !s.requireSettings(w) {
+		return
+	}
+	key := chi.URLParam(r, "key")
+	before, _ := s.Settings.Get(key)
+	setting, err := s.Settings.Reset(key, adminName(r.Context()))
+	s.writeSettingChange(w, r, before, setting, err)
+}
+
+func (s *Server) writeSettingChange(w http.ResponseWriter, r *http.Request, before, after interface{}, err error) {
+	switch {
+	case errors.Is(err, settings.ErrUnknown):
+		writeJSONError(w, http.StatusNotFound, "unknown setting", "NOT_FOUND")
+		return
+	case errors.Is(err, settings.ErrInvalid):
+		writeJSONError(w, http.StatusBadRequest, err.Error(), "INVALID_SETTING")
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	recordChange(r, before, after)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(after)
+}
+
+// requireSettings answers 404 when the server keeps no runtime settings.
+func (s *Server) requireSettings(w http.ResponseWriter) bool {
+	if s.Settings == nil {
+		writeJSONError(w, http.StatusNotFound, "runtime settings are not available", "NOT_FOUND")
+		return false
+	}
+	return true
+}
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/soroushbar/vantage/internal/store"
+)
+
+func (s *Server) handleListSigningKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := s.Store.ListSigningKeys()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(keys)
+}
+
+// handleCreateSigningKey issues a new secret for a service, replacing any
+// existing one. The secret is only returned in this response.
+func (s *Server) handleCreateSigningKey(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Name == "" {
+		writeJSONError(w, http.StatusBadRequest, "A service name is required", "BAD_REQUEST")
+		return
+	}
+
+	key, err := s.createSigningKey(req.Name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(key)
+}
+
+// createSigningKey generates and stores a secret for service name.
+func (s *Server) createSigningKey(name string) (*store.SigningKey, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, err
+	}
+	key := &store.SigningKey{Name: name, Secret: hex.EncodeToString(raw), CreatedAt: time.Now().UTC()}
+	if err := s.Store.PutSigningKey(key.Name, key.Secret); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func (s *Server) handleDeleteSigningKey(w http.ResponseWriter, r *http.Request) {
+	err := s.Store.DeleteSigningKey(chi.URLParam(r, "name"))
+	if errors.Is(err, store.ErrNotFound) {
+		writeJSONError(w, http.StatusNotFound, "Signing key not found", "NOT_FOUND")
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
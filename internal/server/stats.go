@@ -0,0 +1,132 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/soroushbar/vantage/internal/latency"
+	"github.com/soroushbar/vantage/internal/store"
+)
+
+// handleGetStats serves usage series from the rollup tables, or ClickHouse:
+// ?granularity=day|hour (default day), ?group_by=user|model|project, ?from=
+// and ?to= (YYYY-MM-DD or RFC 3339, to exclusive; default the last 30 days
+// including today, or the last 24 hours for hourly series), and ?user= /
+// ?model= / ?project= filters.
+func (s *Server) handleGetStats(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	query := store.StatsQuery{
+		Granularity: q.Get("granularity"),
+		GroupBy:     q.Get("group_by"),
+		User:        q.Get("user"),
+		Model:       q.Get("model"),
+		Project:     q.Get("project"),
+	}
+	var err error
+	if query.To, err = statsTime(q.Get("to"), time.Time{}); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "to must be YYYY-MM-DD or RFC 3339", "BAD_REQUEST")
+		return
+	}
+	if query.From, err = statsTime(q.Get("from"), time.Time{}); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "from must be YYYY-MM-DD or RFC 3339", "BAD_REQUEST")
+		return
+	}
+	if err := resolveStatsQuery(&query); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error(), "BAD_REQUEST")
+		return
+	}
+
+	stats, err := s.stats(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"granularity": query.Granularity,
+		"from":        query.From.UTC().Format(time.RFC3339),
+		"to":          query.To.UTC().Format(time.RFC3339),
+		"series":      stats,
+	})
+}
+
+// resolveStatsQuery checks the granularity and grouping of q and fills in
+// the default granularity and time range.
+func resolveStatsQuery(q *store.StatsQuery) error {
+	unit, periods := 24*time.Hour, 30
+	switch q.Granularity {
+	case "", "day":
+		q.Granularity = "day"
+	case "hour":
+		unit, periods = time.Hour, 24
+	default:
+		return errors.New("granularity must be day or hour")
+	}
+	if g := q.GroupBy; g != "" && g != "user" && g != "model" && g != "project" {
+		return errors.New("group_by must be user, model or project")
+	}
+	if q.To.IsZero() {
+		q.To = time.Now().UTC().Truncate(unit).Add(unit)
+	}
+	if q.From.IsZero() {
+		q.From = q.To.Add(-time.Duration(periods) * unit)
+	}
+	return nil
+}
+
+// stats reads a usage series from ClickHouse when it serves the stats, or
+// else from the rollup tables.
+func (s *Server) stats(q store.StatsQuery) ([]store.UsageStat, error) {
+	if s.Analytics != nil {
+		return s.Analytics.Stats(q)
+	}
+	return s.Store.Stats(q)
+}
+
+// statsTime parses a date or timestamp, returning def when v is empty.
+func statsTime(v string, def time.Time) (time.Time, error) {
+	if v == "" {
+		return def, nil
+	}
+	if t, err := time.Parse("2006-01-02", v); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, v)
+}
+
+// handleGetLatencyStats serves p50/p90/p95/p99 latency over ?from= and
+// ?to= (default the last 24 hours), overall and per route, optionally for
+// one ?route= such as /v1/chat, with the latency SLOs over their window.
+func (s *Server) handleGetLatencyStats(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	to, err := statsTime(q.Get("to"), time.Now().UTC().Truncate(time.Hour).Add(time.Hour))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "to must be YYYY-MM-DD or RFC 3339", "BAD_REQUEST")
+		return
+	}
+	from, err := statsTime(q.Get("from"), to.Add(-24*time.Hour))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "from must be YYYY-MM-DD or RFC 3339", "BAD_REQUEST")
+		return
+	}
+
+	overall, routes, err := s.Store.LatencyStats(from, to, q.Get("route"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	slos := []latency.SLOStatus{}
+	if s.SLOs != nil {
+		slos = s.SLOs.Status()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"from":    from.UTC().Format(time.RFC3339),
+		"to":      to.UTC().Format(time.RFC3339),
+		"overall": overall,
+		"routes":  routes,
+		"slos":    slos,
+	})
+}
@@ -0,0 +1,41 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// handleGetSummary reports today's (UTC) usage for the dashboard header:
+// requests, tokens, estimated cost, blocks, redactions, p95 latency and the
+// top users and models. It reads the hourly rollups, not the raw logs, so
+// it stays cheap however large the log table grows. ?day=YYYY-MM-DD
+// summarizes another day instead.
+func (s *Server) handleGetSummary(w http.ResponseWriter, r *http.Request) {
+	from, err := summaryDay(r.URL.Query().Get("day"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error(), "INVALID_DAY")
+		return
+	}
+	summary, err := s.Store.Summary(from, from.Add(24*time.Hour))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summary)
+}
+
+// summaryDay returns the start of day, YYYY-MM-DD, or of today (UTC) when
+// it is empty.
+func summaryDay(day string) (time.Time, error) {
+	if day == "" {
+		return time.Now().UTC().Truncate(24 * time.Hour), nil
+	}
+	t, err := time.Parse("2006-01-02", day)
+	if err != nil {
+		return time.Time{}, errors.New("day must be YYYY-MM-DD")
+	}
+	return t, nil
+}
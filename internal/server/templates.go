@@ -0,0 +1,255 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/soroushbar/vantage/internal/prompts"
+	"github.com/soroushbar/vantage/internal/store"
+	"github.com/soroushbar/vantage/internal/telemetry"
+	pkgmiddleware "github.com/soroushbar/vantage/pkg/middleware"
+)
+
+func (s *Server) handleListTemplates(w http.ResponseWriter, r *http.Request) {
+	templates, err := s.Store.ListTemplates()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(templates)
+}
+
+func (s *Server) handleGetTemplate(w http.ResponseWriter, r *http.Request) {
+	version, _ := strconv.Atoi(r.URL.Query().Get("version"))
+	t, err := s.Store.GetTemplate(chi.URLParam(r, "name"), version)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "template not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(t)
+}
+
+// handleSaveTemplate stores a new version of a template.
+func (s *Server) handleSaveTemplate(w http.ResponseWriter, r *http.Request) {
+	var t store.PromptTemplate
+	if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if t.Name == "" || t.Body == "" || t.Path == "" {
+		http.Error(w, "name, body and path are required", http.StatusBadRequest)
+		return
+	}
+	if err := prompts.Validate(t.Body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, err := prompts.BuildRequest(&t, ""); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	saved, err := s.Store.SaveTemplate(t)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(saved)
+}
+
+// handleInvokeTemplate renders a stored template server-side and sends the
+// result through the proxy pipeline as if the client had called the target path.
+func (s *Server) handleInvokeTemplate(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Version   int               `json:"version"`
+		Variables map[string]string `json:"variables"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid template invocation", "BAD_REQUEST")
+		return
+	}
+
+	name := chi.URLParam(r, "name")
+	version := req.Version
+	if version == 0 {
+		splits, err := s.Store.GetTemplateSplits(name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		version = prompts.PickVersion(splits)
+	}
+
+	t, err := s.Store.GetTemplate(name, version)
+	if errors.Is(err, store.ErrNotFound) {
+		writeJSONError(w, http.StatusNotFound, "Template not found", "TEMPLATE_NOT_FOUND")
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	userID := pkgmiddleware.UserID(r)
+	if !prompts.Allowed(t, userID) {
+		writeJSONError(w, http.StatusForbidden, "Template access denied", "TEMPLATE_FORBIDDEN")
+		return
+	}
+
+	prompt, err := prompts.Render(t, req.Variables)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error(), "TEMPLATE_RENDER_FAILED")
+		return
+	}
+	body, err := prompts.BuildRequest(t, prompt)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	ref := t.Name + "@" + strconv.Itoa(t.Version)
+	telemetry.TemplateInvocationsTotal.WithLabelValues(t.Name, strconv.Itoa(t.Version)).Inc()
+	w.Header().Set("X-Vantage-Template-Version", strconv.Itoa(t.Version))
+
+	upstream := r.Clone(context.WithValue(r.Context(), pkgmiddleware.TemplateKey, ref))
+	upstream.URL.Path = t.Path
+	upstream.URL.RawPath = ""
+	upstream.Body = io.NopCloser(bytes.NewReader(body))
+	upstream.ContentLength = int64(len(body))
+	upstream.Header.Set("Content-Type", "application/json")
+	s.Pipeline.ServeHTTP(w, upstream)
+}
+
+func (s *Server) handleGetTemplateSplits(w http.ResponseWriter, r *http.Request) {
+	splits, err := s.Store.GetTemplateSplits(chi.URLParam(r, "name"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(splits)
+}
+
+// handleSetTemplateSplits replaces the traffic split between template versions.
+func (s *Server) handleSetTemplateSplits(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Splits []store.TemplateSplit `json:"splits"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	for _, sp := range req.Splits {
+		if sp.Weight < 0 {
+			http.Error(w, "weights must not be negative", http.StatusBadRequest)
+			return
+		}
+	}
+
+	before, _ := s.Store.GetTemplateSplits(chi.URLParam(r, "name"))
+	err := s.Store.SetTemplateSplits(chi.URLParam(r, "name"), req.Splits)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "unknown template version", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	recordChange(r, before, req.Splits)
+	s.handleGetTemplateSplits(w, r)
+}
+
+// handlePromoteTemplate routes all traffic to a single version.
+func (s *Server) handlePromoteTemplate(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Version int `json:"version"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err := s.Store.PromoteTemplate(chi.URLParam(r, "name"), req.Version)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "unknown template version", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.handleGetTemplateSplits(w, r)
+}
+
+// handleRollbackTemplate restores the version that was promoted before the current one.
+func (s *Server) handleRollbackTemplate(w http.ResponseWriter, r *http.Request) {
+	_, err := s.Store.RollbackTemplate(chi.URLParam(r, "name"))
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "no earlier promotion to roll back to", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.handleGetTemplateSplits(w, r)
+}
+
+func (s *Server) handleTemplateStats(w http.ResponseWriter, r *http.Request) {
+	stats, err := s.Store.TemplateStats(chi.URLParam(r, "name"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
+
+// handleTemplateFeedback lets clients score the output of a template version.
+func (s *Server) handleTemplateFeedback(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Version int     `json:"version"`
+		Score   float64 `json:"score"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Version == 0 {
+		writeJSONError(w, http.StatusBadRequest, "version and score are required", "BAD_REQUEST")
+		return
+	}
+
+	name := chi.URLParam(r, "name")
+	if _, err := s.Store.GetTemplate(name, req.Version); err != nil {
+		writeJSONError(w, http.StatusNotFound, "Template not found", "TEMPLATE_NOT_FOUND")
+		return
+	}
+
+	userID := pkgmiddleware.UserID(r)
+	if err := s.Store.AddTemplateFeedback(name, req.Version, req.Score, userID); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeJSONError(w http.ResponseWriter, status int, message, code string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error": message,
+		"code":  code,
+	})
+}
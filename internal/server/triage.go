@@ -0,0 +1,78 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/soroushbar/vantage/internal/config"
+	"github.com/soroushbar/vantage/internal/store"
+)
+
+// handleListTriage lists flagged interactions, most severe first, filtered
+// with ?status= (a triage status or open), ?severity= and ?user=.
+func (s *Server) handleListTriage(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f := store.TriageFilter{Status: q.Get("status"), Severity: q.Get("severity"), User: q.Get("user")}
+	if f.Status != "" && f.Status != "open" && !slices.Contains(store.TriageStatuses, f.Status) {
+		writeJSONError(w, http.StatusBadRequest, "status must be open or one of "+strings.Join(store.TriageStatuses, ", "), "BAD_REQUEST")
+		return
+	}
+	if f.Severity != "" && !slices.Contains(config.Severities, f.Severity) {
+		writeJSONError(w, http.StatusBadRequest, "severity must be one of "+strings.Join(config.Severities, ", "), "BAD_REQUEST")
+		return
+	}
+	f.Limit, _ = strconv.Atoi(q.Get("limit"))
+	if f.Limit <= 0 {
+		f.Limit = 100
+	}
+	queue, err := s.Store.ListTriage(f)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(queue)
+}
+
+// handleSetLogStatus moves a flagged interaction through triage with
+// {"status", "severity", "note"}, only status being required, attributed
+// to the calling admin.
+func (s *Server) handleSetLogStatus(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid log id", "BAD_REQUEST")
+		return
+	}
+	var req triageUpdate
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid request body", "BAD_REQUEST")
+		return
+	}
+	if !slices.Contains(store.TriageStatuses, req.Status) {
+		writeJSONError(w, http.StatusBadRequest, "status must be one of "+strings.Join(store.TriageStatuses, ", "), "BAD_REQUEST")
+		return
+	}
+	if req.Severity != "" && !slices.Contains(config.Severities, req.Severity) {
+		writeJSONError(w, http.StatusBadRequest, "severity must be one of "+strings.Join(config.Severities, ", "), "BAD_REQUEST")
+		return
+	}
+
+	before, _ := s.Store.GetTriage(id)
+	t, err := s.Store.SetTriageStatus(id, req.Status, req.Severity, adminName(r.Context()), strings.TrimSpace(req.Note))
+	if errors.Is(err, store.ErrNotFound) {
+		writeJSONError(w, http.StatusNotFound, "interaction is not flagged for triage", "NOT_FLAGGED")
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	recordChange(r, before, t)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(t)
+}
@@ -0,0 +1,59 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// handleGetTrust lists users' trust scores, lowest first, or with ?user=
+// the score of one user.
+func (s *Server) handleGetTrust(w http.ResponseWriter, r *http.Request) {
+	if s.Trust == nil {
+		writeJSONError(w, http.StatusNotFound, "Trust scoring is not enabled", "TRUST_DISABLED")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if user := r.URL.Query().Get("user"); user != "" {
+		score, ok := s.Trust.Score(user)
+		if !ok {
+			writeJSONError(w, http.StatusNotFound, "No trust score for "+user, "NOT_SCORED")
+			return
+		}
+		json.NewEncoder(w).Encode(score)
+		return
+	}
+	scores, err := s.Trust.Scores()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(scores)
+}
+
+// handleTrustFeedback records an operator's judgement of a user, from -1
+// (abusive) to 1 (vouched for), and returns the user's updated score.
+func (s *Server) handleTrustFeedback(w http.ResponseWriter, r *http.Request) {
+	if s.Trust == nil {
+		writeJSONError(w, http.StatusNotFound, "Trust scoring is not enabled", "TRUST_DISABLED")
+		return
+	}
+	var req struct {
+		Score *float64 `json:"score"`
+		Note  string   `json:"note"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Score == nil || *req.Score < -1 || *req.Score > 1 {
+		writeJSONError(w, http.StatusBadRequest, "score between -1 and 1 is required", "BAD_REQUEST")
+		return
+	}
+
+	user := chi.URLParam(r, "user")
+	if err := s.Trust.AddFeedback(user, *req.Score, req.Note, adminName(r.Context())); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	score, _ := s.Trust.Score(user)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(score)
+}
@@ -0,0 +1,441 @@
+// Package settings keeps the runtime settings of the gateway: forbidden
+// keywords, the redaction toggles, the proxy rate limit and the project
+// budgets. They live in the database, which is their source of truth, and
+// are edited through the admin API; config.yaml only seeds the ones not yet
+// stored. A Manager caches them in memory, reloads them each refresh
+// interval to pick up changes made through other gateways, and calls its
+// subscribers whenever they change.
+package settings
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/soroushbar/vantage/internal/config"
+	"github.com/soroushbar/vantage/internal/store"
+	"github.com/soroushbar/vantage/pkg/middleware"
+)
+
+// Keys of the runtime settings, named after their config.yaml sections.
+const (
+	ForbiddenKeywords = "forbidden_keywords"
+	Redaction         = "redaction"
+	RateLimit         = "rate_limit"
+	Projects          = "projects"
+)
+
+// Keys lists the runtime settings in display order.
+var Keys = []string{ForbiddenKeywords, Redaction, RateLimit, Projects}
+
+// ErrUnknown is returned for a key that is not a runtime setting.
+var ErrUnknown = errors.New("unknown setting")
+
+// ErrInvalid wraps the errors of values that fail validation.
+var ErrInvalid = errors.New("invalid setting")
+
+// Values are the runtime settings.
+type Values struct {
+	ForbiddenKeywords []string        `json:"forbidden_keywords"`
+	Redaction         RedactionValues `json:"redaction"`
+	RateLimit         RateLimitValues `json:"rate_limit"`
+	Projects          ProjectValues   `json:"projects"`
+}
+
+// RedactionValues turns prompt redaction and its built-in patterns on or
+// off, like redaction.enabled and redaction.builtins. The mode and the
+// custom and locale patterns stay in config.yaml.
+type RedactionValues struct {
+	Enabled  bool            `json:"enabled"`
+	Builtins map[string]bool `json:"builtins"`
+}
+
+// RateLimitValues is the per-user proxy rate limit of rate_limit.
+type RateLimitValues struct {
+	Enabled  bool     `json:"enabled"`
+	Requests int      `json:"requests"`
+	Window   Duration `json:"window"`
+}
+
+// ProjectValues are the monthly project budgets of projects.
+type ProjectValues struct {
+	Budgets   map[string]float64 `json:"budgets"`
+	WarnRatio float64            `json:"warn_ratio"`
+}
+
+// Duration is a time.Duration written as a string such as "1m0s".
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string such as \"1m\"")
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// FromConfig returns the runtime settings as cfg sets them.
+func FromConfig(cfg *config.Config) Values {
+	v := Values{
+		ForbiddenKeywords: append([]string{}, cfg.ForbiddenKeywords...),
+		Redaction: RedactionValues{
+			Enabled:  cfg.Redaction.Enabled,
+			Builtins: map[string]bool{},
+		},
+		RateLimit: RateLimitValues{
+			Enabled:  cfg.RateLimit.Enabled,
+			Requests: cfg.RateLimit.Requests,
+			Window:   Duration(cfg.RateLimit.Window),
+		},
+		Projects: ProjectValues{
+			Budgets:   map[string]float64{},
+			WarnRatio: cfg.Projects.WarnRatio,
+		},
+	}
+	for name, on := range cfg.Redaction.Builtins {
+		v.Redaction.Builtins[name] = on
+	}
+	for project, budget := range cfg.Projects.Budgets {
+		v.Projects.Budgets[project] = budget
+	}
+	return v
+}
+
+// Apply sets the runtime settings on cfg. It replaces the fields of cfg
+// rather than modifying them, so cfg may be a shallow copy.
+func (v Values) Apply(cfg *config.Config) {
+	cfg.ForbiddenKeywords = v.ForbiddenKeywords
+	cfg.Redaction.Enabled = v.Redaction.Enabled
+	cfg.Redaction.Builtins = v.Redaction.Builtins
+	cfg.RateLimit.Enabled = v.RateLimit.Enabled
+	cfg.RateLimit.Requests = v.RateLimit.Requests
+	cfg.RateLimit.Window = time.Duration(v.RateLimit.Window)
+	cfg.Projects.Budgets = v.Projects.Budgets
+	cfg.Projects.WarnRatio = v.Projects.WarnRatio
+}
+
+// field returns a pointer to the setting key of v.
+func (v *Values) field(key string) (interface{}, bool) {
+	switch key {
+	case ForbiddenKeywords:
+		return &v.ForbiddenKeywords, true
+	case Redaction:
+		return &v.Redaction, true
+	case RateLimit:
+		return &v.RateLimit, true
+	case Projects:
+		return &v.Projects, true
+	}
+	return nil, false
+}
+
+// clear zeroes the setting key of v and returns a pointer to it, so that
+// decoding into it does not write to maps and slices shared with other
+// values.
+func (v *Values) clear(key string) (interface{}, bool) {
+	switch key {
+	case ForbiddenKeywords:
+		v.ForbiddenKeywords = nil
+	case Redaction:
+		v.Redaction = RedactionValues{}
+	case RateLimit:
+		v.RateLimit = RateLimitValues{}
+	case Projects:
+		v.Projects = ProjectValues{}
+	}
+	return v.field(key)
+}
+
+// validate checks the setting key of v.
+func (v Values) validate(key string) error {
+	var errs []error
+	switch key {
+	case ForbiddenKeywords:
+		for i, kw := range v.ForbiddenKeywords {
+			if strings.TrimSpace(kw) == "" {
+				errs = append(errs, fmt.Errorf("forbidden_keywords[%d]: keyword is empty", i))
+			}
+		}
+	case Redaction:
+		var builtins []string
+		for _, p := range middleware.BuiltinRedactionPatterns() {
+			builtins = append(builtins, p.Name)
+		}
+		for name := range v.Redaction.Builtins {
+			if !slices.Contains(builtins, name) {
+				errs = append(errs, fmt.Errorf("redaction.builtins: unknown pattern %q (want one of %s)", name, strings.Join(builtins, ", ")))
+			}
+		}
+	case RateLimit:
+		if r := v.RateLimit; r.Enabled && (r.Requests <= 0 || r.Window <= 0) {
+			errs = append(errs, errors.New("rate_limit: requests and window must be positive"))
+		}
+	case Projects:
+		for project, budget := range v.Projects.Budgets {
+			if budget <= 0 {
+				errs = append(errs, fmt.Errorf("projects.budgets.%s: budget must be positive", project))
+			}
+		}
+		if r := v.Projects.WarnRatio; r < 0 || r > 1 {
+			errs = append(errs, errors.New("projects: warn_ratio must be between 0 and 1"))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%w: %w", ErrInvalid, errors.Join(errs...))
+	}
+	return nil
+}
+
+// Store persists the runtime settings.
+type Store interface {
+	PutSetting(s store.Setting) error
+	ListSettings() ([]store.Setting, error)
+}
+
+// Manager is an in-memory view of the runtime settings, written through to
+// the store.
+type Manager struct {
+	store    Store
+	defaults Values
+	refresh  time.Duration
+
+	mu          sync.RWMutex
+	values      Values
+	stored      map[string]store.Setting
+	subscribers []func(Values)
+}
+
+// NewManager loads the runtime settings, seeding those not yet stored with
+// their value in cfg.
+func NewManager(st Store, cfg *config.Config) (*Manager, error) {
+	m := &Manager{store: st, defaults: FromConfig(cfg), refresh: cfg.Settings.RefreshInterval}
+	list, err := st.ListSettings()
+	if err != nil {
+		return nil, err
+	}
+	stored := map[string]store.Setting{}
+	for _, s := range list {
+		stored[s.Key] = s
+	}
+	now := time.Now().UTC()
+	for _, key := range Keys {
+		if _, ok := stored[key]; ok {
+			continue
+		}
+		field, _ := m.defaults.field(key)
+		raw, err := json.Marshal(field)
+		if err != nil {
+			return nil, err
+		}
+		s := store.Setting{Key: key, Value: raw, UpdatedBy: "config", UpdatedAt: now}
+		if err := st.PutSetting(s); err != nil {
+			return nil, err
+		}
+		stored[key] = s
+	}
+	m.values, m.stored = m.decode(stored), stored
+	return m, nil
+}
+
+// decode builds the values from stored settings. Settings that no longer
+// decode or validate keep their default, so a bad row cannot stop the
+// gateway from starting.
+func (m *Manager) decode(stored map[string]store.Setting) Values {
+	v := m.defaults
+	for _, key := range Keys {
+		s, ok := stored[key]
+		if !ok {
+			continue
+		}
+		next := v
+		field, _ := next.clear(key)
+		if err := json.Unmarshal(s.Value, field); err != nil {
+			log.Printf("Ignoring stored setting %s: %v", key, err)
+			continue
+		}
+		if err := next.validate(key); err != nil {
+			log.Printf("Ignoring stored setting %s: %v", key, err)
+			continue
+		}
+		v = next
+	}
+	return v
+}
+
+// Values returns the current runtime settings. They must not be modified.
+func (m *Manager) Values() Values {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.values
+}
+
+// List returns the stored settings in display order.
+func (m *Manager) List() []store.Setting {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	out := make([]store.Setting, 0, len(Keys))
+	for _, key := range Keys {
+		if s, ok := m.stored[key]; ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// Get returns one stored setting.
+func (m *Manager) Get(key string) (store.Setting, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	s, ok := m.stored[key]
+	return s, ok
+}
+
+// Subscribe calls fn with the new values whenever the settings change.
+func (m *Manager) Subscribe(fn func(Values)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.subscribers = append(m.subscribers, fn)
+}
+
+// Set replaces a setting with value, the JSON of its section, on behalf of
+// by. Fields value leaves out are reset to their zero value.
+func (m *Manager) Set(key string, value json.RawMessage, by string) (store.Setting, error) {
+	next := m.Values()
+	field, ok := next.clear(key)
+	if !ok {
+		return store.Setting{}, ErrUnknown
+	}
+	dec := json.NewDecoder(bytes.NewReader(value))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(field); err != nil {
+		return store.Setting{}, fmt.Errorf("%w: %s: %v", ErrInvalid, key, err)
+	}
+	return m.put(key, next, by)
+}
+
+// Reset sets a setting back to its value in config.yaml.
+func (m *Manager) Reset(key, by string) (store.Setting, error) {
+	next := m.Values()
+	field, ok := next.field(key)
+	if !ok {
+		return store.Setting{}, ErrUnknown
+	}
+	def, _ := m.defaults.field(key)
+	switch f := field.(type) {
+	case *[]string:
+		*f = *def.(*[]string)
+	case *RedactionValues:
+		*f = *def.(*RedactionValues)
+	case *RateLimitValues:
+		*f = *def.(*RateLimitValues)
+	case *ProjectValues:
+		*f = *def.(*ProjectValues)
+	}
+	return m.put(key, next, by)
+}
+
+// put validates and stores the setting key of next, then makes next
+// current.
+func (m *Manager) put(key string, next Values, by string) (store.Setting, error) {
+	if err := next.validate(key); err != nil {
+		return store.Setting{}, err
+	}
+	field, _ := next.field(key)
+	raw, err := json.Marshal(field)
+	if err != nil {
+		return store.Setting{}, err
+	}
+	s := store.Setting{Key: key, Value: raw, UpdatedBy: by, UpdatedAt: time.Now().UTC()}
+	if err := m.store.PutSetting(s); err != nil {
+		return store.Setting{}, err
+	}
+
+	m.mu.Lock()
+	m.stored[key] = s
+	m.values = next
+	subscribers := m.subscribers
+	m.mu.Unlock()
+	for _, fn := range subscribers {
+		fn(next)
+	}
+	return s, nil
+}
+
+// Start reloads the settings each refresh interval, so changes made
+// through other gateways sharing the database take effect here too.
+func (m *Manager) Start(ctx context.Context) {
+	if m.refresh <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(m.refresh)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := m.Refresh(); err != nil {
+					log.Printf("Failed to reload settings: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// Refresh reloads the settings from the store and notifies the subscribers
+// if any changed.
+func (m *Manager) Refresh() error {
+	list, err := m.store.ListSettings()
+	if err != nil {
+		return err
+	}
+	stored := map[string]store.Setting{}
+	for _, s := range list {
+		stored[s.Key] = s
+	}
+
+	m.mu.Lock()
+	changed := false
+	for _, key := range Keys {
+		if !bytes.Equal(stored[key].Value, m.stored[key].Value) {
+			changed = true
+			break
+		}
+	}
+	if !changed {
+		m.mu.Unlock()
+		return nil
+	}
+	values := m.decode(stored)
+	for _, key := range Keys {
+		if _, ok := stored[key]; !ok {
+			stored[key] = m.stored[key]
+		}
+	}
+	m.values, m.stored = values, stored
+	subscribers := m.subscribers
+	m.mu.Unlock()
+
+	log.Println("Reloaded runtime settings changed through another gateway")
+	for _, fn := range subscribers {
+		fn(values)
+	}
+	return nil
+}
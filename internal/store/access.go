@@ -0,0 +1,124 @@
+package store
+
+import (
+	"strings"
+	"time"
+)
+
+// AccessEntry records one read of an interaction's raw request/response bodies.
+type AccessEntry struct {
+	ID            int       `json:"id"`
+	Viewer        string    `json:"viewer"`
+	RemoteAddr    string    `json:"remote_addr"`
+	InteractionID int       `json:"interaction_id"`
+	Action        string    `json:"action"`
+	AccessedAt    time.Time `json:"accessed_at"`
+}
+
+// AccessFilter narrows ListAccess results; zero values match everything.
+type AccessFilter struct {
+	InteractionID int
+	Viewer        string
+	Limit         int
+}
+
+func (s *Store) initAccessSchema() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS access_log (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		viewer TEXT NOT NULL,
+		remote_addr TEXT,
+		interaction_id INTEGER NOT NULL,
+		action TEXT NOT NULL,
+		accessed_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_access_log_interaction ON access_log(interaction_id);`
+	_, err := s.db.Exec(query)
+	return err
+}
+
+// RecordAccess logs that viewer read the bodies of the given interactions.
+func (s *Store) RecordAccess(viewer, remoteAddr, action string, interactionIDs []int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`INSERT INTO access_log (viewer, remote_addr, interaction_id, action) VALUES (?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, id := range interactionIDs {
+		if _, err := stmt.Exec(viewer, nullString(remoteAddr), id, action); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// PurgeAccess deletes the recorded reads of the given interactions and
+// returns how many there were. With dryRun they are only counted.
+func (s *Store) PurgeAccess(interactionIDs []int, dryRun bool) (int, error) {
+	if len(interactionIDs) == 0 {
+		return 0, nil
+	}
+	ids := strings.TrimSuffix(strings.Repeat("?,", len(interactionIDs)), ",")
+	args := make([]interface{}, len(interactionIDs))
+	for i, id := range interactionIDs {
+		args[i] = id
+	}
+	var n int
+	if dryRun {
+		err := s.db.QueryRow(`SELECT COUNT(*) FROM access_log WHERE interaction_id IN (`+ids+`)`, args...).Scan(&n)
+		return n, err
+	}
+	res, err := s.db.Exec(`DELETE FROM access_log WHERE interaction_id IN (`+ids+`)`, args...)
+	if err != nil {
+		return 0, err
+	}
+	affected, err := res.RowsAffected()
+	return int(affected), err
+}
+
+// ListAccess returns access log entries, newest first.
+func (s *Store) ListAccess(f AccessFilter) ([]AccessEntry, error) {
+	query := `SELECT id, viewer, COALESCE(remote_addr, ''), interaction_id, action, accessed_at FROM access_log WHERE 1 = 1`
+	var args []interface{}
+	if f.InteractionID != 0 {
+		query += ` AND interaction_id = ?`
+		args = append(args, f.InteractionID)
+	}
+	if f.Viewer != "" {
+		query += ` AND viewer = ?`
+		args = append(args, f.Viewer)
+	}
+	query += ` ORDER BY id DESC LIMIT ?`
+	args = append(args, f.Limit)
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []AccessEntry{}
+	for rows.Next() {
+		var e AccessEntry
+		if err := rows.Scan(&e.ID, &e.Viewer, &e.RemoteAddr, &e.InteractionID, &e.Action, &e.AccessedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// PurgeAccessLog deletes access log entries older than cutoff.
+func (s *Store) PurgeAccessLog(cutoff time.Time) (int64, error) {
+	res, err := s.db.Exec(`DELETE FROM access_log WHERE accessed_at < ?`, cutoff.UTC().Format(sqliteTimeLayout))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,114 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// AdminAction is one change made through the admin API, or one export of
+// logs. Action is the route, such as "PUT /models/{name}", or the gRPC
+// method; Target holds its path parameters, such as "name=command-r".
+// Status is the HTTP status the change was answered with. Diff holds the
+// "request" body and, where the handler knows them, the "before" and
+// "after" states.
+type AdminAction struct {
+	ID         int64           `json:"id"`
+	At         time.Time       `json:"at"`
+	Actor      string          `json:"actor"`
+	RemoteAddr string          `json:"remote_addr,omitempty"`
+	Action     string          `json:"action"`
+	Target     string          `json:"target,omitempty"`
+	Status     int             `json:"status"`
+	Diff       json.RawMessage `json:"diff,omitempty"`
+}
+
+// AdminAuditFilter narrows ListAdminActions results; zero values match
+// everything. Action matches a prefix, so "POST /keys" also finds rotations.
+type AdminAuditFilter struct {
+	Actor    string
+	Action   string
+	Target   string
+	From, To time.Time
+	Limit    int
+}
+
+func (s *Store) initAdminAuditSchema() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS admin_audit (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		at DATETIME NOT NULL,
+		actor TEXT NOT NULL,
+		remote_addr TEXT,
+		action TEXT NOT NULL,
+		target TEXT,
+		status INTEGER NOT NULL,
+		diff TEXT
+	);
+	CREATE INDEX IF NOT EXISTS idx_admin_audit_at ON admin_audit(at);`
+	_, err := s.db.Exec(query)
+	return err
+}
+
+// RecordAdminAction appends a to the admin audit trail and sets its ID.
+func (s *Store) RecordAdminAction(a *AdminAction) error {
+	var diff sql.NullString
+	if len(a.Diff) > 0 {
+		diff = sql.NullString{String: string(a.Diff), Valid: true}
+	}
+	res, err := s.db.Exec(`INSERT INTO admin_audit (at, actor, remote_addr, action, target, status, diff) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		a.At.UTC().Format(sqliteTimeLayout), a.Actor, nullString(a.RemoteAddr), a.Action, nullString(a.Target), a.Status, diff)
+	if err != nil {
+		return err
+	}
+	a.ID, err = res.LastInsertId()
+	return err
+}
+
+// ListAdminActions returns admin audit entries, newest first.
+func (s *Store) ListAdminActions(f AdminAuditFilter) ([]AdminAction, error) {
+	query := `SELECT id, at, actor, COALESCE(remote_addr, ''), action, COALESCE(target, ''), status, diff FROM admin_audit WHERE 1 = 1`
+	var args []interface{}
+	if f.Actor != "" {
+		query += ` AND actor = ?`
+		args = append(args, f.Actor)
+	}
+	if f.Action != "" {
+		query += ` AND instr(action, ?) = 1`
+		args = append(args, f.Action)
+	}
+	if f.Target != "" {
+		query += ` AND target = ?`
+		args = append(args, f.Target)
+	}
+	if !f.From.IsZero() {
+		query += ` AND at >= ?`
+		args = append(args, f.From.UTC().Format(sqliteTimeLayout))
+	}
+	if !f.To.IsZero() {
+		query += ` AND at < ?`
+		args = append(args, f.To.UTC().Format(sqliteTimeLayout))
+	}
+	query += ` ORDER BY id DESC LIMIT ?`
+	rows, err := s.db.Query(query, append(args, f.Limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	actions := []AdminAction{}
+	for rows.Next() {
+		var a AdminAction
+		var at string
+		var diff sql.NullString
+		if err := rows.Scan(&a.ID, &at, &a.Actor, &a.RemoteAddr, &a.Action, &a.Target, &a.Status, &diff); err != nil {
+			return nil, err
+		}
+		a.At = parseTimestamp(at)
+		if diff.Valid {
+			a.Diff = json.RawMessage(diff.String)
+		}
+		actions = append(actions, a)
+	}
+	return actions, rows.Err()
+}
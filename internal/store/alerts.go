@@ -0,0 +1,120 @@
+package store
+
+import "time"
+
+// Alert is a usage anomaly flagged for one user in one hour, such as a
+// spike in requests or activity at an hour the user is never active.
+type Alert struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UserID    string    `json:"user_id"`
+	Kind      string    `json:"kind"`
+	// Hour is the HourFormat key of the hour the anomaly was seen in
+	Hour     string  `json:"hour"`
+	Observed float64 `json:"observed"`
+	Baseline float64 `json:"baseline"`
+	Message  string  `json:"message"`
+}
+
+// AlertFilter narrows ListAlerts results.
+type AlertFilter struct {
+	User  string
+	Kind  string
+	Limit int
+}
+
+// UserHour is one user's usage in one hour, summed over models.
+type UserHour struct {
+	Hour     string
+	UserID   string
+	Requests int
+	Tokens   int
+	Blocked  int
+}
+
+func (s *Store) initAlertSchema() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS alerts (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		created_at DATETIME NOT NULL,
+		user_id TEXT NOT NULL,
+		kind TEXT NOT NULL,
+		hour TEXT NOT NULL,
+		observed REAL NOT NULL,
+		baseline REAL NOT NULL,
+		message TEXT NOT NULL,
+		UNIQUE (user_id, kind, hour)
+	);
+	CREATE INDEX IF NOT EXISTS idx_alerts_created ON alerts(created_at);`
+	_, err := s.db.Exec(query)
+	return err
+}
+
+// SaveAlert stores a, setting its ID, and reports whether it is new. An
+// alert of the same kind for the same user and hour is kept as it was.
+func (s *Store) SaveAlert(a *Alert) (bool, error) {
+	res, err := s.db.Exec(`INSERT OR IGNORE INTO alerts (created_at, user_id, kind, hour, observed, baseline, message) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		a.CreatedAt.UTC().Format(sqliteTimeLayout), a.UserID, a.Kind, a.Hour, a.Observed, a.Baseline, a.Message)
+	if err != nil {
+		return false, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return false, nil
+	}
+	a.ID, err = res.LastInsertId()
+	return true, err
+}
+
+// ListAlerts returns the newest alerts first.
+func (s *Store) ListAlerts(f AlertFilter) ([]Alert, error) {
+	query := `SELECT id, created_at, user_id, kind, hour, observed, baseline, message FROM alerts WHERE 1 = 1`
+	var args []interface{}
+	if f.User != "" {
+		query += ` AND user_id = ?`
+		args = append(args, f.User)
+	}
+	if f.Kind != "" {
+		query += ` AND kind = ?`
+		args = append(args, f.Kind)
+	}
+	query += ` ORDER BY created_at DESC, id DESC LIMIT ?`
+	rows, err := s.db.Query(query, append(args, f.Limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	alerts := []Alert{}
+	for rows.Next() {
+		var a Alert
+		var created string
+		if err := rows.Scan(&a.ID, &created, &a.UserID, &a.Kind, &a.Hour, &a.Observed, &a.Baseline, &a.Message); err != nil {
+			return nil, err
+		}
+		a.CreatedAt = parseTimestamp(created)
+		alerts = append(alerts, a)
+	}
+	return alerts, rows.Err()
+}
+
+// UserHours returns every user's hourly usage from the rollups for the
+// hours in [from, to).
+func (s *Store) UserHours(from, to time.Time) ([]UserHour, error) {
+	rows, err := s.db.Query(`SELECT hour, user_id, COALESCE(SUM(requests), 0), COALESCE(SUM(input_tokens + output_tokens), 0), COALESCE(SUM(blocked), 0)
+		FROM usage_hourly WHERE hour >= ? AND hour < ? GROUP BY hour, user_id`,
+		from.UTC().Format(HourFormat), to.UTC().Format(HourFormat))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hours []UserHour
+	for rows.Next() {
+		var h UserHour
+		if err := rows.Scan(&h.Hour, &h.UserID, &h.Requests, &h.Tokens, &h.Blocked); err != nil {
+			return nil, err
+		}
+		hours = append(hours, h)
+	}
+	return hours, rows.Err()
+}
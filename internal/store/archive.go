@@ -0,0 +1,81 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+)
+
+// ArchiveCandidate is an interaction whose bodies are due to be archived.
+// The bodies are as stored, compressed and sealed as Encoding says, so
+// archiving never decrypts them.
+type ArchiveCandidate struct {
+	ID           int
+	Timestamp    time.Time
+	ChainHash    string
+	Encoding     string
+	RequestBody  []byte
+	ResponseBody []byte
+}
+
+// ArchiveCandidates returns up to limit unarchived interactions older than
+// before, oldest first.
+func (s *Store) ArchiveCandidates(before time.Time, limit int) ([]ArchiveCandidate, error) {
+	rows, err := s.db.Query(`
+	SELECT id, timestamp, chain_hash, request_body, response_body, COALESCE(body_encoding, '')
+	FROM interaction_logs
+	WHERE archive_key IS NULL AND timestamp < ?
+	ORDER BY id ASC
+	LIMIT ?`, before.UTC().Format(sqliteTimeLayout), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var candidates []ArchiveCandidate
+	for rows.Next() {
+		var c ArchiveCandidate
+		var hash sql.NullString
+		if err := rows.Scan(&c.ID, &c.Timestamp, &hash, &c.RequestBody, &c.ResponseBody, &c.Encoding); err != nil {
+			return nil, err
+		}
+		c.ChainHash = hash.String
+		candidates = append(candidates, c)
+	}
+	return candidates, rows.Err()
+}
+
+// DecodeBodies decodes the bodies of userID's interaction kept as stored,
+// e.g. in an archive object, and decrypts them if they were sealed.
+func (s *Store) DecodeBodies(encoding, userID string, req, resp []byte) ([]byte, []byte, error) {
+	return s.decodeBodies(encoding, userID, req, resp)
+}
+
+// ArchiveReader reads the bodies of an archived interaction back from its
+// object, with their encoding, as the database stored them.
+type ArchiveReader func(key string, id int) (encoding string, req, resp []byte, err error)
+
+// SetArchiveReader lets VerifyChain recompute the hashes of archived records
+// from their objects. Without it they cannot be verified.
+func (s *Store) SetArchiveReader(r ArchiveReader) {
+	s.archive = r
+}
+
+// ArchiveDeleter deletes archive objects by key. Keys that no longer exist
+// are not an error.
+type ArchiveDeleter func(keys []string) error
+
+// SetArchiveDeleter lets PurgeInteractions delete the objects of archived
+// records. Without it purges that match archived records fail.
+func (s *Store) SetArchiveDeleter(d ArchiveDeleter) {
+	s.archiveDelete = d
+}
+
+// MarkArchived drops an interaction's bodies once they are stored under key,
+// leaving the rest of the row in place.
+func (s *Store) MarkArchived(id int, key string) error {
+	_, err := s.db.Exec(`
+	UPDATE interaction_logs
+	SET request_body = NULL, response_body = NULL, body_encoding = NULL, archive_key = ?, archived_at = ?
+	WHERE id = ? AND archive_key IS NULL`, key, time.Now().UTC().Format(sqliteTimeLayout), id)
+	return err
+}
@@ -0,0 +1,77 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Artifact kinds recorded in the model registry.
+const (
+	ArtifactDataset        = "dataset"
+	ArtifactFinetunedModel = "finetuned_model"
+)
+
+type ModelArtifact struct {
+	ID         int       `json:"id"`
+	Kind       string    `json:"kind"`
+	ArtifactID string    `json:"artifact_id"`
+	Name       string    `json:"name,omitempty"`
+	BaseModel  string    `json:"base_model,omitempty"`
+	DatasetID  string    `json:"dataset_id,omitempty"`
+	CreatedBy  string    `json:"created_by"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func (s *Store) initArtifactSchema() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS model_artifacts (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		kind TEXT NOT NULL,
+		artifact_id TEXT NOT NULL UNIQUE,
+		name TEXT,
+		base_model TEXT,
+		dataset_id TEXT,
+		created_by TEXT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`
+	_, err := s.db.Exec(query)
+	return err
+}
+
+// RecordArtifact adds a dataset or fine-tuned model to the registry.
+func (s *Store) RecordArtifact(a ModelArtifact) error {
+	_, err := s.db.Exec(`INSERT OR IGNORE INTO model_artifacts (kind, artifact_id, name, base_model, dataset_id, created_by) VALUES (?, ?, ?, ?, ?, ?)`,
+		a.Kind, a.ArtifactID, nullString(a.Name), nullString(a.BaseModel), nullString(a.DatasetID), a.CreatedBy)
+	return err
+}
+
+// ArtifactOwner returns the user that created a fine-tuned model.
+func (s *Store) ArtifactOwner(artifactID string) (string, bool) {
+	var owner string
+	err := s.db.QueryRow(`SELECT created_by FROM model_artifacts WHERE artifact_id = ? AND kind = ?`, artifactID, ArtifactFinetunedModel).Scan(&owner)
+	if errors.Is(err, sql.ErrNoRows) || err != nil {
+		return "", false
+	}
+	return owner, true
+}
+
+// ListArtifacts returns the registry, newest first.
+func (s *Store) ListArtifacts() ([]ModelArtifact, error) {
+	rows, err := s.db.Query(`SELECT id, kind, artifact_id, COALESCE(name, ''), COALESCE(base_model, ''), COALESCE(dataset_id, ''), created_by, created_at
+	          FROM model_artifacts ORDER BY id DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	artifacts := []ModelArtifact{}
+	for rows.Next() {
+		var a ModelArtifact
+		if err := rows.Scan(&a.ID, &a.Kind, &a.ArtifactID, &a.Name, &a.BaseModel, &a.DatasetID, &a.CreatedBy, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		artifacts = append(artifacts, a)
+	}
+	return artifacts, rows.Err()
+}
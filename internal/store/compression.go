@@ -0,0 +1,124 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/soroushbar/vantage/internal/codec"
+)
+
+// BodyCipher seals bodies for a tenant under a named key.
+type BodyCipher interface {
+	KeyID() string
+	Seal(tenant string, plaintext []byte) ([]byte, error)
+	Open(keyID, tenant string, ciphertext []byte) ([]byte, error)
+}
+
+// sealedStep is the body encoding step for encryption, followed by the key
+// ID. A row's encoding lists its steps in the order they were applied,
+// joined by "+", e.g. "zstd+aesgcm:primary".
+const sealedStep = "aesgcm:"
+
+// SetBodyCompression sets the algorithm applied to request and response
+// bodies written from now on. Existing rows keep their encoding and are
+// decoded transparently on read.
+func (s *Store) SetBodyCompression(alg string) error {
+	if !slices.Contains(codec.Algorithms, alg) {
+		return fmt.Errorf("unknown body compression %q", alg)
+	}
+	s.compression = alg
+	return nil
+}
+
+// SetBodyCipher encrypts request and response bodies written from now on,
+// after compression, with the user as tenant. Encrypted rows are decrypted
+// transparently on read as long as their key is configured.
+func (s *Store) SetBodyCipher(c BodyCipher) {
+	s.cipher = c
+}
+
+// Cipher returns the cipher set with SetBodyCipher, or nil when bodies are
+// not encrypted.
+func (s *Store) Cipher() BodyCipher {
+	return s.cipher
+}
+
+// encodeBodies compresses and encrypts non-empty bodies and returns the
+// encoding to record, or "" when they are stored as-is.
+func (s *Store) encodeBodies(tenant string, req, resp []byte) ([]byte, []byte, string, error) {
+	var steps []string
+	if s.compression != "" && s.compression != codec.None {
+		steps = append(steps, s.compression)
+	}
+	if s.cipher != nil {
+		steps = append(steps, sealedStep+s.cipher.KeyID())
+	}
+	if len(steps) == 0 || (len(req) == 0 && len(resp) == 0) {
+		return req, resp, "", nil
+	}
+	var err error
+	if req, err = s.encodeBody(steps, tenant, req); err != nil {
+		return nil, nil, "", err
+	}
+	if resp, err = s.encodeBody(steps, tenant, resp); err != nil {
+		return nil, nil, "", err
+	}
+	return req, resp, strings.Join(steps, "+"), nil
+}
+
+func (s *Store) encodeBody(steps []string, tenant string, body []byte) ([]byte, error) {
+	if len(body) == 0 {
+		return body, nil
+	}
+	var err error
+	for _, step := range steps {
+		if strings.HasPrefix(step, sealedStep) {
+			body, err = s.cipher.Seal(tenant, body)
+		} else {
+			body, err = codec.Encode(step, body)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return body, nil
+}
+
+// decodeBodies reverses encodeBodies for a row's recorded encoding.
+func (s *Store) decodeBodies(encoding, tenant string, req, resp []byte) ([]byte, []byte, error) {
+	if encoding == "" {
+		return req, resp, nil
+	}
+	steps := strings.Split(encoding, "+")
+	var err error
+	if req, err = s.decodeBody(steps, tenant, req); err != nil {
+		return nil, nil, fmt.Errorf("decode request body: %w", err)
+	}
+	if resp, err = s.decodeBody(steps, tenant, resp); err != nil {
+		return nil, nil, fmt.Errorf("decode response body: %w", err)
+	}
+	return req, resp, nil
+}
+
+func (s *Store) decodeBody(steps []string, tenant string, body []byte) ([]byte, error) {
+	if len(body) == 0 {
+		return body, nil
+	}
+	var err error
+	for i := len(steps) - 1; i >= 0; i-- {
+		if keyID, sealed := strings.CutPrefix(steps[i], sealedStep); sealed {
+			if s.cipher == nil {
+				return nil, errors.New("body is encrypted but no encryption key is configured")
+			}
+			body, err = s.cipher.Open(keyID, tenant, body)
+		} else {
+			body, err = codec.Decode(steps[i], body)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return body, nil
+}
@@ -0,0 +1,38 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+)
+
+func (s *Store) initConversationSchema() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS conversation_usage (
+		user_id TEXT NOT NULL,
+		conversation_id TEXT NOT NULL,
+		tokens INTEGER NOT NULL DEFAULT 0,
+		turns INTEGER NOT NULL DEFAULT 0,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (user_id, conversation_id)
+	);`
+	_, err := s.db.Exec(query)
+	return err
+}
+
+// AddConversationTokens adds one turn's tokens to a conversation's total.
+func (s *Store) AddConversationTokens(userID, conversationID string, tokens int) error {
+	_, err := s.db.Exec(`INSERT INTO conversation_usage (user_id, conversation_id, tokens, turns) VALUES (?, ?, ?, 1)
+		ON CONFLICT(user_id, conversation_id) DO UPDATE SET tokens = tokens + excluded.tokens, turns = turns + 1, updated_at = CURRENT_TIMESTAMP`,
+		userID, conversationID, tokens)
+	return err
+}
+
+// ConversationTokens returns the tokens a conversation has consumed so far.
+func (s *Store) ConversationTokens(userID, conversationID string) (int, error) {
+	var tokens int
+	err := s.db.QueryRow(`SELECT tokens FROM conversation_usage WHERE user_id = ? AND conversation_id = ?`, userID, conversationID).Scan(&tokens)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return tokens, err
+}
@@ -1,31 +1,94 @@
 package store
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	_ "modernc.org/sqlite"
 )
 
 type InteractionRecord struct {
-	ID           int       `json:"id"`
-	Timestamp    time.Time `json:"timestamp"`
-	UserID       string    `json:"user_id"`
-	Method       string    `json:"method"`
-	Path         string    `json:"path"`
-	RequestBody  string    `json:"request_body"`
-	ResponseBody string    `json:"response_body"`
-	StatusCode   int       `json:"status_code"`
-	LatencyMs    int64     `json:"latency_ms"`
-	Tokens       int       `json:"tokens"`
-	SafetyScore  float64   `json:"safety_score"`
-	IsBlocked    bool      `json:"is_blocked"`
-	IsRedacted   bool      `json:"is_redacted"`
+	ID           int             `json:"id"`
+	Timestamp    time.Time       `json:"timestamp"`
+	UserID       string          `json:"user_id"`
+	Method       string          `json:"method"`
+	Path         string          `json:"path"`
+	RequestBody  string          `json:"request_body"`
+	ResponseBody string          `json:"response_body"`
+	StatusCode   int             `json:"status_code"`
+	LatencyMs    int64           `json:"latency_ms"`
+	Tokens       int             `json:"tokens"`
+	SafetyScore  *float64        `json:"safety_score"`
+	IsBlocked    bool            `json:"is_blocked"`
+	IsRedacted   bool            `json:"is_redacted"`
+	Template     string          `json:"template,omitempty"`
+	CacheHit     bool            `json:"cache_hit"`
+	Metadata     json.RawMessage `json:"metadata,omitempty"`
+	Model        string          `json:"model,omitempty"`
+	RoutedModel  string          `json:"routed_model,omitempty"`
+	Truncation   json.RawMessage `json:"truncation,omitempty"`
+	Verdict      json.RawMessage `json:"verdict,omitempty"`
+	Headers      json.RawMessage `json:"headers,omitempty"`
+	IsSlow       bool            `json:"is_slow"`
+	ArchiveKey   string          `json:"archive_key,omitempty"`
+	Session      string          `json:"session_id,omitempty"`
+
+	// ResponseSafety is the safety score of the generated text, when it
+	// was classified; SafetyScore is the prompt's, nil when it was not
+	// classified, as for trust tiers that skip the safety audit
+	ResponseSafety *float64 `json:"response_safety,omitempty"`
+	// Estimated is set when Tokens was counted locally from the bodies
+	// because the upstream response reported no usage
+	Estimated bool `json:"estimated"`
+	// Transformation is what operator transforms changed before the
+	// request was forwarded; RequestBody is the request as sent by the client
+	Transformation json.RawMessage `json:"transformation,omitempty"`
+	// RedactionReport lists, per PII type, how many values were redacted
+	// from RequestBody and their byte offsets in it
+	RedactionReport json.RawMessage `json:"redaction_report,omitempty"`
+	// ResponsePII is what PII was found in the generated text and whether
+	// it was redacted, blocked or only flagged
+	ResponsePII json.RawMessage `json:"response_pii,omitempty"`
+	// Project is the internal project the interaction is charged to, from
+	// X-Vantage-Project or the caller's virtual key
+	Project string `json:"project,omitempty"`
+	// ParamOverrides is what the caller's parameter policy defaulted,
+	// capped or removed, or the parameter it rejected the request for
+	ParamOverrides json.RawMessage `json:"param_overrides,omitempty"`
+	// Moderation is the prompt's score in each harm category and the
+	// categories it was flagged in; SafetyScore is derived from it
+	Moderation json.RawMessage `json:"moderation,omitempty"`
+	// RequestID is the ID the gateway gave the request, as returned in
+	// X-Vantage-Request-ID and forwarded upstream
+	RequestID string `json:"request_id,omitempty"`
+	// Triage is the review state of a flagged interaction, only set by
+	// the single-log API
+	Triage *Triage `json:"triage,omitempty"`
 }
 
 type Store struct {
 	db *sql.DB
+	// compression and then cipher are applied to bodies as they are written
+	compression string
+	cipher      BodyCipher
+	// search adds new bodies to the full-text index
+	search bool
+	// archive reads archived bodies back for chain verification
+	archive ArchiveReader
+	// archiveDelete deletes the archive objects of purged records
+	archiveDelete ArchiveDeleter
+
+	chainMu   sync.Mutex
+	chainHead string
 }
 
 func NewStore(dbPath string) (*Store, error) {
@@ -46,6 +109,59 @@ func NewStore(dbPath string) (*Store, error) {
 	return s, nil
 }
 
+// CheckDatabase reports whether the database at dbPath can be read, without
+// creating or migrating it. A missing file passes when its directory
+// exists, since NewStore creates it.
+func CheckDatabase(ctx context.Context, dbPath string) error {
+	if _, err := os.Stat(dbPath); errors.Is(err, os.ErrNotExist) {
+		dir := filepath.Dir(dbPath)
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			return fmt.Errorf("%s does not exist and its directory %s is missing", dbPath, dir)
+		}
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		return fmt.Errorf("failed to open sqlite: %w", err)
+	}
+	defer db.Close()
+	var tables int
+	return db.QueryRowContext(ctx, `SELECT COUNT(*) FROM sqlite_master`).Scan(&tables)
+}
+
+// interactionMigrations lists interaction_logs columns added after the original schema.
+var interactionMigrations = []struct {
+	column     string
+	definition string
+}{
+	{"chain_hash", "TEXT"},
+	{"template", "TEXT"},
+	{"cache_hit", "BOOLEAN DEFAULT 0"},
+	{"metadata", "TEXT"},
+	{"model", "TEXT"},
+	{"routed_model", "TEXT"},
+	{"truncation", "TEXT"},
+	{"archive_key", "TEXT"},
+	{"archived_at", "DATETIME"},
+	{"body_encoding", "TEXT"},
+	{"verdict", "TEXT"},
+	{"headers", "TEXT"},
+	{"is_slow", "BOOLEAN DEFAULT 0"},
+	{"response_safety", "REAL"},
+	{"session_id", "TEXT"},
+	{"tokens_estimated", "BOOLEAN DEFAULT 0"},
+	{"transformation", "TEXT"},
+	{"redaction_report", "TEXT"},
+	{"response_pii", "TEXT"},
+	{"project", "TEXT"},
+	{"param_overrides", "TEXT"},
+	{"moderation", "TEXT"},
+	{"request_id", "TEXT"},
+}
+
 func (s *Store) InitSchema() error {
 	query := `
 	CREATE TABLE IF NOT EXISTS interaction_logs (
@@ -63,22 +179,313 @@ func (s *Store) InitSchema() error {
 		is_blocked BOOLEAN DEFAULT 0,
 		is_redacted BOOLEAN DEFAULT 0
 	);`
-	_, err := s.db.Exec(query)
+	if _, err := s.db.Exec(query); err != nil {
+		return err
+	}
+
+	// Columns added after the original schema; also upgrades older databases
+	for _, c := range interactionMigrations {
+		if err := s.ensureColumn("interaction_logs", c.column, c.definition); err != nil {
+			return err
+		}
+	}
+	if _, err := s.db.Exec(`CREATE INDEX IF NOT EXISTS idx_interaction_logs_template ON interaction_logs(template)`); err != nil {
+		return err
+	}
+	if _, err := s.db.Exec(`CREATE INDEX IF NOT EXISTS idx_interaction_logs_session ON interaction_logs(session_id)`); err != nil {
+		return err
+	}
+	if _, err := s.db.Exec(`CREATE INDEX IF NOT EXISTS idx_interaction_logs_project ON interaction_logs(project)`); err != nil {
+		return err
+	}
+	if _, err := s.db.Exec(`CREATE INDEX IF NOT EXISTS idx_interaction_logs_request ON interaction_logs(request_id)`); err != nil {
+		return err
+	}
+	if err := s.initTemplateSchema(); err != nil {
+		return err
+	}
+	if err := s.initTemplateExperimentSchema(); err != nil {
+		return err
+	}
+	if err := s.initVaultSchema(); err != nil {
+		return err
+	}
+	if err := s.initWebhookSchema(); err != nil {
+		return err
+	}
+	if err := s.initArtifactSchema(); err != nil {
+		return err
+	}
+	if err := s.initModelSchema(); err != nil {
+		return err
+	}
+	if err := s.initAccessSchema(); err != nil {
+		return err
+	}
+	if err := s.initQuotaSchema(); err != nil {
+		return err
+	}
+	if err := s.initSigningSchema(); err != nil {
+		return err
+	}
+	if err := s.initConversationSchema(); err != nil {
+		return err
+	}
+	if err := s.initRollupSchema(); err != nil {
+		return err
+	}
+	if err := s.initTrustSchema(); err != nil {
+		return err
+	}
+	if err := s.initReplaySchema(); err != nil {
+		return err
+	}
+	if err := s.initIncidentSchema(); err != nil {
+		return err
+	}
+	if err := s.initAlertSchema(); err != nil {
+		return err
+	}
+	if err := s.initQuarantineSchema(); err != nil {
+		return err
+	}
+	if err := s.initExportSchema(); err != nil {
+		return err
+	}
+	if err := s.initKeySchema(); err != nil {
+		return err
+	}
+	if err := s.initSearchSchema(); err != nil {
+		return err
+	}
+	if err := s.initTriageSchema(); err != nil {
+		return err
+	}
+	if err := s.initAdminAuditSchema(); err != nil {
+		return err
+	}
+	if err := s.initSettingsSchema(); err != nil {
+		return err
+	}
+	if err := s.initPurgeSchema(); err != nil {
+		return err
+	}
+	return s.loadChainHead()
+}
+
+// ensureColumn adds a column to an existing table if it is missing.
+func (s *Store) ensureColumn(table, column, definition string) error {
+	rows, err := s.db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, colType string
+		var dflt sql.NullString
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &dflt, &pk); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	_, err = s.db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
 	return err
 }
 
-func (s *Store) LogInteractionDetailed(userID, method, path string, reqBody, respBody []byte, statusCode int, latencyMs int64, tokens int, safetyScore float64, isBlocked, isRedacted bool) error {
+// LogInteraction appends a record to the interaction log, links it into the
+// hash chain and returns its ID.
+func (s *Store) LogInteraction(rec InteractionRecord) (int64, error) {
+	s.chainMu.Lock()
+	defer s.chainMu.Unlock()
+
+	if rec.Timestamp.IsZero() {
+		rec.Timestamp = time.Now()
+	}
+	ts := rec.Timestamp.UTC().Format(sqliteTimeLayout)
+	reqBody, respBody := []byte(rec.RequestBody), []byte(rec.ResponseBody)
+	fields := chainFields{
+		Timestamp:    ts,
+		UserID:       rec.UserID,
+		Method:       rec.Method,
+		Path:         rec.Path,
+		RequestBody:  reqBody,
+		ResponseBody: respBody,
+		StatusCode:   rec.StatusCode,
+		LatencyMs:    rec.LatencyMs,
+		Tokens:       rec.Tokens,
+		SafetyScore:  rec.SafetyScore,
+		IsBlocked:    rec.IsBlocked,
+		IsRedacted:   rec.IsRedacted,
+		Template:     rec.Template,
+		CacheHit:     rec.CacheHit,
+		Metadata:     string(rec.Metadata),
+		Model:        rec.Model,
+		RoutedModel:  rec.RoutedModel,
+		Truncation:   string(rec.Truncation),
+		Verdict:      string(rec.Verdict),
+		Headers:      string(rec.Headers),
+		IsSlow:       rec.IsSlow,
+		Session:      rec.Session,
+		Estimated:    rec.Estimated,
+	}
+	fields.ResponseSafety = rec.ResponseSafety
+	fields.Transformation = string(rec.Transformation)
+	fields.RedactionReport = string(rec.RedactionReport)
+	fields.ResponsePII = string(rec.ResponsePII)
+	fields.Project = rec.Project
+	fields.ParamOverrides = string(rec.ParamOverrides)
+	fields.Moderation = string(rec.Moderation)
+	fields.RequestID = rec.RequestID
+	hash := chainHash(s.chainHead, fields)
+
+	// The chain covers the raw bodies; compression and encryption are storage details
+	storedReq, storedResp, encoding, err := s.encodeBodies(rec.UserID, reqBody, respBody)
+	if err != nil {
+		return 0, err
+	}
+
 	query := `
-	INSERT INTO interaction_logs (user_id, method, path, request_body, response_body, status_code, latency_ms, token_count, safety_score, is_blocked, is_redacted)
-	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
-	_, err := s.db.Exec(query, userID, method, path, reqBody, respBody, statusCode, latencyMs, tokens, safetyScore, isBlocked, isRedacted)
-	return err
+	INSERT INTO interaction_logs (timestamp, user_id, method, path, request_body, response_body, body_encoding, status_code, latency_ms, token_count, safety_score, is_blocked, is_redacted, template, cache_hit, metadata, model, routed_model, truncation, verdict, headers, is_slow, response_safety, session_id, tokens_estimated, transformation, redaction_report, response_pii, project, param_overrides, moderation, request_id, chain_hash)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	res, err := tx.Exec(query, ts, rec.UserID, rec.Method, rec.Path, storedReq, storedResp, nullString(encoding), rec.StatusCode, rec.LatencyMs, rec.Tokens, rec.SafetyScore, rec.IsBlocked, rec.IsRedacted, nullString(rec.Template), rec.CacheHit, nullString(string(rec.Metadata)), nullString(rec.Model), nullString(rec.RoutedModel), nullString(string(rec.Truncation)), nullString(string(rec.Verdict)), nullString(string(rec.Headers)), rec.IsSlow, rec.ResponseSafety, nullString(rec.Session), rec.Estimated, nullString(string(rec.Transformation)), nullString(string(rec.RedactionReport)), nullString(string(rec.ResponsePII)), nullString(rec.Project), nullString(string(rec.ParamOverrides)), nullString(string(rec.Moderation)), nullString(rec.RequestID), hash)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	if s.search {
+		if err := indexBodies(tx, id, rec.RequestBody, rec.ResponseBody); err != nil {
+			return 0, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	s.chainHead = hash
+	return id, nil
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// interactionColumns is the column list read by scanInteraction.
+const interactionColumns = `id, timestamp, user_id, method, path, request_body, response_body, status_code, latency_ms, token_count, safety_score, is_blocked, is_redacted, template, cache_hit, metadata, model, routed_model, truncation, verdict, headers, COALESCE(is_slow, 0), response_safety, archive_key, body_encoding, session_id, COALESCE(tokens_estimated, 0), transformation, redaction_report, response_pii, project, param_overrides, moderation, request_id`
+
+func (s *Store) scanInteraction(row rowScanner) (*InteractionRecord, error) {
+	var r InteractionRecord
+	var req, resp []byte
+	var template, metadata, model, routedModel, truncation, verdict, headers, archiveKey, encoding, session, transformation, redactionReport, responsePII, project, paramOverrides, moderation, requestID sql.NullString
+	var safety, responseSafety sql.NullFloat64
+	err := row.Scan(&r.ID, &r.Timestamp, &r.UserID, &r.Method, &r.Path, &req, &resp, &r.StatusCode, &r.LatencyMs, &r.Tokens, &safety, &r.IsBlocked, &r.IsRedacted, &template, &r.CacheHit, &metadata, &model, &routedModel, &truncation, &verdict, &headers, &r.IsSlow, &responseSafety, &archiveKey, &encoding, &session, &r.Estimated, &transformation, &redactionReport, &responsePII, &project, &paramOverrides, &moderation, &requestID)
+	if err != nil {
+		return nil, err
+	}
+	if req, resp, err = s.decodeBodies(encoding.String, r.UserID, req, resp); err != nil {
+		return nil, fmt.Errorf("interaction %d: %w", r.ID, err)
+	}
+	r.RequestBody = string(req)
+	r.ResponseBody = string(resp)
+	r.Template = template.String
+	r.Model = model.String
+	r.RoutedModel = routedModel.String
+	r.ArchiveKey = archiveKey.String
+	r.Session = session.String
+	r.Project = project.String
+	r.RequestID = requestID.String
+	if safety.Valid {
+		r.SafetyScore = &safety.Float64
+	}
+	if responseSafety.Valid {
+		r.ResponseSafety = &responseSafety.Float64
+	}
+	if metadata.Valid {
+		r.Metadata = json.RawMessage(metadata.String)
+	}
+	if truncation.Valid {
+		r.Truncation = json.RawMessage(truncation.String)
+	}
+	if verdict.Valid {
+		r.Verdict = json.RawMessage(verdict.String)
+	}
+	if headers.Valid {
+		r.Headers = json.RawMessage(headers.String)
+	}
+	if transformation.Valid {
+		r.Transformation = json.RawMessage(transformation.String)
+	}
+	if redactionReport.Valid {
+		r.RedactionReport = json.RawMessage(redactionReport.String)
+	}
+	if responsePII.Valid {
+		r.ResponsePII = json.RawMessage(responsePII.String)
+	}
+	if paramOverrides.Valid {
+		r.ParamOverrides = json.RawMessage(paramOverrides.String)
+	}
+	if moderation.Valid {
+		r.Moderation = json.RawMessage(moderation.String)
+	}
+	return &r, nil
+}
+
+// GetLog returns a single interaction by ID.
+func (s *Store) GetLog(id int) (*InteractionRecord, error) {
+	row := s.db.QueryRow(`SELECT `+interactionColumns+` FROM interaction_logs WHERE id = ?`, id)
+	r, err := s.scanInteraction(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return r, err
+}
+
+// LogFilter narrows GetLogs results. Metadata matches top-level keys of the
+// client-supplied metadata against string values. Path matches a prefix;
+// From and To bound the timestamp, To exclusive, when set, and Blocked
+// keeps only blocked (true) or only allowed (false) interactions. Session
+// matches the client-supplied session ID and Project the project the
+// interaction was charged to. Search is
+// a full-text query over the indexed bodies, in FTS5 syntax: words,
+// "phrases", AND/OR/NOT and request: or response: column filters.
+type LogFilter struct {
+	Limit    int
+	User     string
+	Path     string
+	From, To time.Time
+	Blocked  *bool
+	Metadata map[string]string
+	Slow     bool
+	Session  string
+	Project  string
+	Request  string
+	Search   string
 }
 
-func (s *Store) GetLogs(limit int) ([]InteractionRecord, error) {
-	query := `SELECT id, timestamp, user_id, method, path, request_body, response_body, status_code, latency_ms, token_count, safety_score, is_blocked, is_redacted 
-	          FROM interaction_logs ORDER BY timestamp DESC LIMIT ?`
-	rows, err := s.db.Query(query, limit)
+func (s *Store) GetLogs(f LogFilter) ([]InteractionRecord, error) {
+	where, args, err := f.where()
+	if err != nil {
+		return nil, err
+	}
+	query := `SELECT ` + interactionColumns + ` FROM interaction_logs WHERE ` + where + ` ORDER BY timestamp DESC LIMIT ?`
+	args = append(args, f.Limit)
+
+	rows, err := s.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -86,19 +493,125 @@ func (s *Store) GetLogs(limit int) ([]InteractionRecord, error) {
 
 	var logs []InteractionRecord
 	for rows.Next() {
-		var r InteractionRecord
-		var req, resp []byte
-		err := rows.Scan(&r.ID, &r.Timestamp, &r.UserID, &r.Method, &r.Path, &req, &resp, &r.StatusCode, &r.LatencyMs, &r.Tokens, &r.SafetyScore, &r.IsBlocked, &r.IsRedacted)
+		r, err := s.scanInteraction(rows)
 		if err != nil {
 			return nil, err
 		}
-		r.RequestBody = string(req)
-		r.ResponseBody = string(resp)
-		logs = append(logs, r)
+		logs = append(logs, *r)
 	}
 	return logs, nil
 }
 
+// where returns the condition on interaction_logs that selects the
+// interactions f matches, and its arguments. Limit is not applied.
+func (f LogFilter) where() (string, []interface{}, error) {
+	query := `1 = 1`
+	var args []interface{}
+	for key, value := range f.Metadata {
+		if strings.ContainsAny(key, `"\`) {
+			return "", nil, fmt.Errorf("invalid metadata key %q", key)
+		}
+		query += ` AND CAST(json_extract(metadata, ?) AS TEXT) = ?`
+		args = append(args, `$."`+key+`"`, value)
+	}
+	if f.User != "" {
+		query += ` AND user_id = ?`
+		args = append(args, f.User)
+	}
+	if f.Path != "" {
+		query += ` AND instr(path, ?) = 1`
+		args = append(args, f.Path)
+	}
+	if !f.From.IsZero() {
+		query += ` AND timestamp >= ?`
+		args = append(args, f.From.UTC().Format(sqliteTimeLayout))
+	}
+	if !f.To.IsZero() {
+		query += ` AND timestamp < ?`
+		args = append(args, f.To.UTC().Format(sqliteTimeLayout))
+	}
+	if f.Blocked != nil {
+		query += ` AND is_blocked = ?`
+		args = append(args, *f.Blocked)
+	}
+	if f.Slow {
+		query += ` AND is_slow = 1`
+	}
+	if f.Session != "" {
+		query += ` AND session_id = ?`
+		args = append(args, f.Session)
+	}
+	if f.Project != "" {
+		query += ` AND project = ?`
+		args = append(args, f.Project)
+	}
+	if f.Request != "" {
+		query += ` AND request_id = ?`
+		args = append(args, f.Request)
+	}
+	if f.Search != "" {
+		query += ` AND id IN (SELECT rowid FROM interaction_search WHERE interaction_search MATCH ?)`
+		args = append(args, f.Search)
+	}
+	return query, args, nil
+}
+
+// Match reports whether f's where clause would select rec, for copies of
+// interactions kept outside the database. Search is not applied.
+func (f LogFilter) Match(rec InteractionRecord) bool {
+	if (f.User != "" && rec.UserID != f.User) || !strings.HasPrefix(rec.Path, f.Path) ||
+		(!f.From.IsZero() && rec.Timestamp.Before(f.From)) || (!f.To.IsZero() && !rec.Timestamp.Before(f.To)) ||
+		(f.Blocked != nil && rec.IsBlocked != *f.Blocked) || (f.Slow && !rec.IsSlow) ||
+		(f.Session != "" && rec.Session != f.Session) || (f.Project != "" && rec.Project != f.Project) ||
+		(f.Request != "" && rec.RequestID != f.Request) {
+		return false
+	}
+	if len(f.Metadata) == 0 {
+		return true
+	}
+	var metadata map[string]interface{}
+	if json.Unmarshal(rec.Metadata, &metadata) != nil {
+		return false
+	}
+	for key, value := range f.Metadata {
+		// As CAST(json_extract(...) AS TEXT) renders it
+		switch v := metadata[key].(type) {
+		case string:
+			if v != value {
+				return false
+			}
+		case bool:
+			if (v && value != "1") || (!v && value != "0") {
+				return false
+			}
+		case float64:
+			if strconv.FormatFloat(v, 'f', -1, 64) != value {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// MatchUsage reports whether u belongs to the interactions f selects. Only
+// a filter on a user and at most a time range can tell.
+func (f LogFilter) MatchUsage(u UsageSample) bool {
+	return f.erasure() && u.UserID == f.User &&
+		(f.From.IsZero() || !u.Time.Before(f.From)) && (f.To.IsZero() || u.Time.Before(f.To))
+}
+
+// nullString stores empty optional values as NULL.
+func nullString(v string) sql.NullString {
+	return sql.NullString{String: v, Valid: v != ""}
+}
+
+// Ping verifies the database connection is usable.
+func (s *Store) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
 func (s *Store) Close() error {
 	return s.db.Close()
 }
@@ -0,0 +1,189 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ExportRecord is an interaction as written to an export object. ChainHash
+// lets the exported records be checked against the hash chain.
+type ExportRecord struct {
+	InteractionRecord
+	ChainHash string `json:"chain_hash,omitempty"`
+}
+
+// LogExport is the manifest of one export object: which interactions it
+// holds and how to check it. ChainHash is the chain hash of its last record,
+// which the first remaining record links to.
+type LogExport struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Key       string    `json:"key"`
+	Format    string    `json:"format"`
+	Records   int       `json:"records"`
+	FirstID   int       `json:"first_id"`
+	LastID    int       `json:"last_id"`
+	From      time.Time `json:"from"`
+	To        time.Time `json:"to"`
+	Bytes     int       `json:"bytes"`
+	SHA256    string    `json:"sha256"`
+	ChainHash string    `json:"chain_hash,omitempty"`
+}
+
+func (s *Store) initExportSchema() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS log_exports (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		created_at DATETIME NOT NULL,
+		object_key TEXT NOT NULL,
+		format TEXT NOT NULL,
+		records INTEGER NOT NULL,
+		first_id INTEGER NOT NULL,
+		last_id INTEGER NOT NULL,
+		from_ts DATETIME NOT NULL,
+		to_ts DATETIME NOT NULL,
+		bytes INTEGER NOT NULL,
+		sha256 TEXT NOT NULL,
+		chain_hash TEXT
+	);`
+	_, err := s.db.Exec(query)
+	return err
+}
+
+// withChainHash reads the chain hash after the interaction columns.
+type withChainHash struct {
+	rowScanner
+	hash *sql.NullString
+}
+
+func (w withChainHash) Scan(dest ...interface{}) error {
+	return w.rowScanner.Scan(append(dest, w.hash)...)
+}
+
+// ExportCandidates returns up to limit of the oldest interactions, stopping
+// at the first one not older than before. Only a prefix of the log is ever
+// exported, so the records left behind still form an unbroken chain.
+func (s *Store) ExportCandidates(before time.Time, limit int) ([]ExportRecord, error) {
+	rows, err := s.db.Query(`SELECT `+interactionColumns+`, chain_hash FROM interaction_logs ORDER BY id ASC LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []ExportRecord
+	for rows.Next() {
+		var hash sql.NullString
+		rec, err := s.scanInteraction(withChainHash{rows, &hash})
+		if err != nil {
+			return nil, err
+		}
+		if !rec.Timestamp.Before(before) {
+			break
+		}
+		records = append(records, ExportRecord{InteractionRecord: *rec, ChainHash: hash.String})
+	}
+	return records, rows.Err()
+}
+
+// CompleteExport records the manifest of an uploaded export and deletes the
+// interactions it holds, in one transaction, and sets e.ID.
+func (s *Store) CompleteExport(e *LogExport) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`INSERT INTO log_exports (created_at, object_key, format, records, first_id, last_id, from_ts, to_ts, bytes, sha256, chain_hash) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		e.CreatedAt.UTC().Format(sqliteTimeLayout), e.Key, e.Format, e.Records, e.FirstID, e.LastID,
+		e.From.UTC().Format(sqliteTimeLayout), e.To.UTC().Format(sqliteTimeLayout), e.Bytes, e.SHA256, nullString(e.ChainHash))
+	if err != nil {
+		return err
+	}
+	if e.ID, err = res.LastInsertId(); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM interaction_logs WHERE id BETWEEN ? AND ?`, e.FirstID, e.LastID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM interaction_search WHERE rowid BETWEEN ? AND ?`, e.FirstID, e.LastID); err != nil {
+		return err
+	}
+	// The chain now starts from the export, past any record purged before it
+	if _, err := tx.Exec(`DELETE FROM purged_interactions WHERE id <= ?`, e.LastID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ListExports returns export manifests, newest first.
+func (s *Store) ListExports(limit int) ([]LogExport, error) {
+	rows, err := s.db.Query(`SELECT id, created_at, object_key, format, records, first_id, last_id, from_ts, to_ts, bytes, sha256, COALESCE(chain_hash, '')
+		FROM log_exports ORDER BY id DESC LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanExports(rows)
+}
+
+func scanExports(rows *sql.Rows) ([]LogExport, error) {
+	exports := []LogExport{}
+	for rows.Next() {
+		var e LogExport
+		var created, from, to string
+		if err := rows.Scan(&e.ID, &created, &e.Key, &e.Format, &e.Records, &e.FirstID, &e.LastID, &from, &to, &e.Bytes, &e.SHA256, &e.ChainHash); err != nil {
+			return nil, err
+		}
+		e.CreatedAt = parseTimestamp(created)
+		e.From = parseTimestamp(from)
+		e.To = parseTimestamp(to)
+		exports = append(exports, e)
+	}
+	return exports, rows.Err()
+}
+
+// ExportsBetween returns the manifests of the exports holding interactions
+// from [from, to), oldest first. Zero times leave that end open.
+func (s *Store) ExportsBetween(from, to time.Time) ([]LogExport, error) {
+	where, args := `1 = 1`, []interface{}{}
+	if !from.IsZero() {
+		where += ` AND to_ts >= ?`
+		args = append(args, from.UTC().Format(sqliteTimeLayout))
+	}
+	if !to.IsZero() {
+		where += ` AND from_ts < ?`
+		args = append(args, to.UTC().Format(sqliteTimeLayout))
+	}
+	rows, err := s.db.Query(`SELECT id, created_at, object_key, format, records, first_id, last_id, from_ts, to_ts, bytes, sha256, COALESCE(chain_hash, '')
+		FROM log_exports WHERE `+where+` ORDER BY id ASC`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanExports(rows)
+}
+
+// UpdateExport records the new size and checksum of a rewritten export
+// object and how many records it still holds.
+func (s *Store) UpdateExport(e *LogExport) error {
+	_, err := s.db.Exec(`UPDATE log_exports SET records = ?, bytes = ?, sha256 = ? WHERE id = ?`, e.Records, e.Bytes, e.SHA256, e.ID)
+	return err
+}
+
+// exportedChain returns the chain hash the oldest remaining interaction
+// links to and how many interactions were exported.
+func (s *Store) exportedChain() (string, int, error) {
+	var head string
+	err := s.db.QueryRow(`SELECT COALESCE(chain_hash, '') FROM log_exports ORDER BY last_id DESC LIMIT 1`).Scan(&head)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", 0, nil
+	}
+	if err != nil {
+		return "", 0, err
+	}
+	var exported int
+	err = s.db.QueryRow(`SELECT COALESCE(SUM(records), 0) FROM log_exports`).Scan(&exported)
+	return head, exported, err
+}
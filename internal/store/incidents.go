@@ -0,0 +1,205 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Incident statuses.
+const (
+	IncidentOpen         = "open"
+	IncidentAcknowledged = "acknowledged"
+	IncidentResolved     = "resolved"
+)
+
+// Incident groups correlated notification events, such as a burst of
+// blocked requests or one provider outage, with the operators' handling.
+type Incident struct {
+	ID             int64      `json:"id"`
+	Key            string     `json:"key"`
+	Title          string     `json:"title"`
+	Severity       string     `json:"severity"`
+	Status         string     `json:"status"`
+	OpenedAt       time.Time  `json:"opened_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	EventCount     int        `json:"event_count"`
+	AcknowledgedBy string     `json:"acknowledged_by,omitempty"`
+	AcknowledgedAt *time.Time `json:"acknowledged_at,omitempty"`
+	ResolvedBy     string     `json:"resolved_by,omitempty"`
+	ResolvedAt     *time.Time `json:"resolved_at,omitempty"`
+	Resolution     string     `json:"resolution,omitempty"`
+
+	Timeline []IncidentEntry `json:"timeline,omitempty"`
+}
+
+// IncidentEntry is one step of an incident's timeline: an event (Kind
+// "event") or an operator action ("acknowledged", "resolved" or "note").
+type IncidentEntry struct {
+	At        time.Time `json:"at"`
+	Kind      string    `json:"kind"`
+	EventID   string    `json:"event_id,omitempty"`
+	EventType string    `json:"event_type,omitempty"`
+	Severity  string    `json:"severity,omitempty"`
+	UserID    string    `json:"user_id,omitempty"`
+	Path      string    `json:"path,omitempty"`
+	LogID     int64     `json:"log_id,omitempty"`
+	Author    string    `json:"author,omitempty"`
+	Note      string    `json:"note,omitempty"`
+}
+
+func (s *Store) initIncidentSchema() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS incidents (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		key TEXT NOT NULL,
+		title TEXT NOT NULL,
+		severity TEXT NOT NULL,
+		status TEXT NOT NULL,
+		opened_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL,
+		event_count INTEGER DEFAULT 0,
+		acknowledged_by TEXT,
+		acknowledged_at DATETIME,
+		resolved_by TEXT,
+		resolved_at DATETIME,
+		resolution TEXT
+	);
+	CREATE INDEX IF NOT EXISTS idx_incidents_status ON incidents(status);
+	CREATE TABLE IF NOT EXISTS incident_entries (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		incident_id INTEGER NOT NULL,
+		at DATETIME NOT NULL,
+		kind TEXT NOT NULL,
+		event_id TEXT,
+		event_type TEXT,
+		severity TEXT,
+		user_id TEXT,
+		path TEXT,
+		log_id INTEGER,
+		author TEXT,
+		note TEXT
+	);
+	CREATE INDEX IF NOT EXISTS idx_incident_entries_incident ON incident_entries(incident_id);`
+	_, err := s.db.Exec(query)
+	return err
+}
+
+// SaveIncident inserts inc, setting its ID, or updates the stored incident
+// with its ID. The timeline is written separately by AddIncidentEntry.
+func (s *Store) SaveIncident(inc *Incident) error {
+	if inc.ID != 0 {
+		_, err := s.db.Exec(`UPDATE incidents SET title = ?, severity = ?, status = ?, updated_at = ?, event_count = ?, acknowledged_by = ?, acknowledged_at = ?, resolved_by = ?, resolved_at = ?, resolution = ? WHERE id = ?`,
+			inc.Title, inc.Severity, inc.Status, inc.UpdatedAt.UTC().Format(sqliteTimeLayout), inc.EventCount,
+			nullString(inc.AcknowledgedBy), nullTime(inc.AcknowledgedAt), nullString(inc.ResolvedBy), nullTime(inc.ResolvedAt), nullString(inc.Resolution), inc.ID)
+		return err
+	}
+	res, err := s.db.Exec(`INSERT INTO incidents (key, title, severity, status, opened_at, updated_at, event_count) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		inc.Key, inc.Title, inc.Severity, inc.Status, inc.OpenedAt.UTC().Format(sqliteTimeLayout), inc.UpdatedAt.UTC().Format(sqliteTimeLayout), inc.EventCount)
+	if err != nil {
+		return err
+	}
+	inc.ID, err = res.LastInsertId()
+	return err
+}
+
+// AddIncidentEntry appends an entry to an incident's timeline.
+func (s *Store) AddIncidentEntry(incidentID int64, e IncidentEntry) error {
+	_, err := s.db.Exec(`INSERT INTO incident_entries (incident_id, at, kind, event_id, event_type, severity, user_id, path, log_id, author, note) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		incidentID, e.At.UTC().Format(sqliteTimeLayout), e.Kind, nullString(e.EventID), nullString(e.EventType), nullString(e.Severity),
+		nullString(e.UserID), nullString(e.Path), e.LogID, nullString(e.Author), nullString(e.Note))
+	return err
+}
+
+const incidentColumns = `id, key, title, severity, status, opened_at, updated_at, event_count, COALESCE(acknowledged_by, ''), acknowledged_at, COALESCE(resolved_by, ''), resolved_at, COALESCE(resolution, '')`
+
+func scanIncident(row rowScanner) (*Incident, error) {
+	var inc Incident
+	var opened, updated string
+	var acked, resolved sql.NullString
+	err := row.Scan(&inc.ID, &inc.Key, &inc.Title, &inc.Severity, &inc.Status, &opened, &updated, &inc.EventCount,
+		&inc.AcknowledgedBy, &acked, &inc.ResolvedBy, &resolved, &inc.Resolution)
+	if err != nil {
+		return nil, err
+	}
+	inc.OpenedAt = parseTimestamp(opened)
+	inc.UpdatedAt = parseTimestamp(updated)
+	if acked.Valid {
+		t := parseTimestamp(acked.String)
+		inc.AcknowledgedAt = &t
+	}
+	if resolved.Valid {
+		t := parseTimestamp(resolved.String)
+		inc.ResolvedAt = &t
+	}
+	return &inc, nil
+}
+
+// ListIncidents returns the most recently updated incidents, optionally
+// only those with status, or with status "unresolved" every incident that
+// is open or acknowledged.
+func (s *Store) ListIncidents(status string, limit int) ([]Incident, error) {
+	query := `SELECT ` + incidentColumns + ` FROM incidents`
+	var args []interface{}
+	switch status {
+	case "":
+	case "unresolved":
+		query += ` WHERE status != ?`
+		args = append(args, IncidentResolved)
+	default:
+		query += ` WHERE status = ?`
+		args = append(args, status)
+	}
+	query += ` ORDER BY updated_at DESC, id DESC LIMIT ?`
+	rows, err := s.db.Query(query, append(args, limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	incidents := []Incident{}
+	for rows.Next() {
+		inc, err := scanIncident(rows)
+		if err != nil {
+			return nil, err
+		}
+		incidents = append(incidents, *inc)
+	}
+	return incidents, rows.Err()
+}
+
+// GetIncident returns an incident with its timeline, oldest entry first.
+func (s *Store) GetIncident(id int64) (*Incident, error) {
+	inc, err := scanIncident(s.db.QueryRow(`SELECT `+incidentColumns+` FROM incidents WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(`SELECT at, kind, COALESCE(event_id, ''), COALESCE(event_type, ''), COALESCE(severity, ''), COALESCE(user_id, ''), COALESCE(path, ''), COALESCE(log_id, 0), COALESCE(author, ''), COALESCE(note, '')
+		FROM incident_entries WHERE incident_id = ? ORDER BY at, id`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	inc.Timeline = []IncidentEntry{}
+	for rows.Next() {
+		var e IncidentEntry
+		var at string
+		if err := rows.Scan(&at, &e.Kind, &e.EventID, &e.EventType, &e.Severity, &e.UserID, &e.Path, &e.LogID, &e.Author, &e.Note); err != nil {
+			return nil, err
+		}
+		e.At = parseTimestamp(at)
+		inc.Timeline = append(inc.Timeline, e)
+	}
+	return inc, rows.Err()
+}
+
+func nullTime(t *time.Time) sql.NullString {
+	if t == nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: t.UTC().Format(sqliteTimeLayout), Valid: true}
+}
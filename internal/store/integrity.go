@@ -0,0 +1,196 @@
+package store
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+)
+
+// chainFields is the canonical content covered by an interaction's chain hash.
+// Fields added later must use omitempty so older records keep verifying.
+type chainFields struct {
+	Timestamp    string   `json:"timestamp"`
+	UserID       string   `json:"user_id"`
+	Method       string   `json:"method"`
+	Path         string   `json:"path"`
+	RequestBody  []byte   `json:"request_body"`
+	ResponseBody []byte   `json:"response_body"`
+	StatusCode   int      `json:"status_code"`
+	LatencyMs    int64    `json:"latency_ms"`
+	Tokens       int      `json:"tokens"`
+	SafetyScore  *float64 `json:"safety_score"`
+	IsBlocked    bool     `json:"is_blocked"`
+	IsRedacted   bool     `json:"is_redacted"`
+	Template     string   `json:"template,omitempty"`
+	CacheHit     bool     `json:"cache_hit,omitempty"`
+	Metadata     string   `json:"metadata,omitempty"`
+	Model        string   `json:"model,omitempty"`
+	RoutedModel  string   `json:"routed_model,omitempty"`
+	Truncation   string   `json:"truncation,omitempty"`
+	Verdict      string   `json:"verdict,omitempty"`
+	Headers      string   `json:"headers,omitempty"`
+	IsSlow       bool     `json:"is_slow,omitempty"`
+	Session      string   `json:"session_id,omitempty"`
+	Estimated    bool     `json:"estimated,omitempty"`
+
+	ResponseSafety  *float64 `json:"response_safety,omitempty"`
+	Transformation  string   `json:"transformation,omitempty"`
+	RedactionReport string   `json:"redaction_report,omitempty"`
+	ResponsePII     string   `json:"response_pii,omitempty"`
+	Project         string   `json:"project,omitempty"`
+	ParamOverrides  string   `json:"param_overrides,omitempty"`
+	Moderation      string   `json:"moderation,omitempty"`
+	RequestID       string   `json:"request_id,omitempty"`
+}
+
+// chainHash returns SHA-256(prev || canonical JSON of f) as hex.
+func chainHash(prev string, f chainFields) string {
+	// Empty and NULL blobs must hash identically after a database round trip
+	if len(f.RequestBody) == 0 {
+		f.RequestBody = nil
+	}
+	if len(f.ResponseBody) == 0 {
+		f.ResponseBody = nil
+	}
+	content, _ := json.Marshal(f)
+	h := sha256.New()
+	h.Write([]byte(prev))
+	h.Write(content)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// loadChainHead reads the most recent chain hash so new records link to it,
+// which may be that of a purged record. When every record was exported, the
+// chain continues from the last export.
+func (s *Store) loadChainHead() error {
+	err := s.db.QueryRow(`SELECT chain_hash FROM (
+		SELECT id, chain_hash FROM interaction_logs WHERE chain_hash IS NOT NULL
+		UNION ALL SELECT id, chain_hash FROM purged_interactions
+	) ORDER BY id DESC LIMIT 1`).Scan(&s.chainHead)
+	if errors.Is(err, sql.ErrNoRows) {
+		s.chainHead, _, err = s.exportedChain()
+	}
+	return err
+}
+
+// ChainReport is the result of walking the interaction hash chain.
+type ChainReport struct {
+	Valid         bool   `json:"valid"`
+	Checked       int    `json:"checked"`
+	Unchained     int    `json:"unchained"`
+	Archived      int    `json:"archived"`
+	Exported      int    `json:"exported,omitempty"`
+	Purged        int    `json:"purged,omitempty"`
+	FirstBrokenID int    `json:"first_broken_id,omitempty"`
+	Reason        string `json:"reason,omitempty"`
+}
+
+// VerifyChain recomputes every record's hash in insertion order and reports
+// the first record whose stored hash does not match. Records written before
+// the chain existed are counted as unchained and skipped. Records whose
+// bodies were archived are recomputed with the bodies read back through the
+// ArchiveReader and counted as archived; without a reader they fail
+// verification, as their other fields could not be checked. Exported records
+// are gone; the chain starts from the hash recorded with the last export.
+// Purged records are gone too, but their hashes are kept to link the chain.
+func (s *Store) VerifyChain() (*ChainReport, error) {
+	prev, exported, err := s.exportedChain()
+	if err != nil {
+		return nil, err
+	}
+	purged, err := s.purgedChain()
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT id, COALESCE(strftime('%Y-%m-%d %H:%M:%S', timestamp), ''), COALESCE(user_id, ''), COALESCE(method, ''), COALESCE(path, ''),
+	                 request_body, response_body, COALESCE(status_code, 0), COALESCE(latency_ms, 0), COALESCE(token_count, 0), safety_score,
+	                 is_blocked, is_redacted, COALESCE(template, ''), COALESCE(cache_hit, 0), COALESCE(metadata, ''), COALESCE(model, ''), COALESCE(routed_model, ''), COALESCE(truncation, ''), COALESCE(verdict, ''), COALESCE(headers, ''), COALESCE(is_slow, 0), response_safety, COALESCE(session_id, ''), COALESCE(tokens_estimated, 0), COALESCE(transformation, ''), COALESCE(redaction_report, ''), COALESCE(response_pii, ''), COALESCE(project, ''), COALESCE(param_overrides, ''), COALESCE(moderation, ''), COALESCE(request_id, ''), COALESCE(archive_key, ''), COALESCE(body_encoding, ''), chain_hash
+	          FROM interaction_logs ORDER BY id ASC`
+	rows, err := s.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	report := &ChainReport{Valid: true, Exported: exported}
+	started := prev != ""
+	for rows.Next() {
+		var id int
+		var f chainFields
+		var stored sql.NullString
+		var archiveKey string
+		var encoding string
+		var safety, responseSafety sql.NullFloat64
+		err := rows.Scan(&id, &f.Timestamp, &f.UserID, &f.Method, &f.Path, &f.RequestBody, &f.ResponseBody, &f.StatusCode, &f.LatencyMs, &f.Tokens, &safety, &f.IsBlocked, &f.IsRedacted, &f.Template, &f.CacheHit, &f.Metadata, &f.Model, &f.RoutedModel, &f.Truncation, &f.Verdict, &f.Headers, &f.IsSlow, &responseSafety, &f.Session, &f.Estimated, &f.Transformation, &f.RedactionReport, &f.ResponsePII, &f.Project, &f.ParamOverrides, &f.Moderation, &f.RequestID, &archiveKey, &encoding, &stored)
+		if err != nil {
+			return nil, err
+		}
+		for len(purged) > 0 && purged[0].id < id {
+			report.Purged++
+			started = true
+			prev = purged[0].hash
+			purged = purged[1:]
+		}
+		if safety.Valid {
+			f.SafetyScore = &safety.Float64
+		}
+		if responseSafety.Valid {
+			f.ResponseSafety = &responseSafety.Float64
+		}
+		if f.RequestBody, f.ResponseBody, err = s.decodeBodies(encoding, f.UserID, f.RequestBody, f.ResponseBody); err != nil {
+			report.Valid = false
+			report.FirstBrokenID = id
+			report.Reason = err.Error()
+			return report, nil
+		}
+
+		if !stored.Valid {
+			if started {
+				report.Valid = false
+				report.FirstBrokenID = id
+				report.Reason = "missing chain hash"
+				return report, nil
+			}
+			report.Unchained++
+			continue
+		}
+		started = true
+		if archiveKey != "" {
+			if err := s.restoreArchived(archiveKey, id, &f); err != nil {
+				report.Valid = false
+				report.FirstBrokenID = id
+				report.Reason = "archived bodies: " + err.Error()
+				return report, nil
+			}
+			report.Archived++
+		} else {
+			report.Checked++
+		}
+
+		if chainHash(prev, f) != stored.String {
+			report.Valid = false
+			report.FirstBrokenID = id
+			report.Reason = "hash mismatch: record altered or predecessor removed"
+			return report, nil
+		}
+		prev = stored.String
+	}
+	report.Purged += len(purged)
+	return report, rows.Err()
+}
+
+// restoreArchived fills in the bodies of an archived record for hashing.
+func (s *Store) restoreArchived(key string, id int, f *chainFields) error {
+	if s.archive == nil {
+		return errors.New("archive is not configured")
+	}
+	encoding, req, resp, err := s.archive(key, id)
+	if err != nil {
+		return err
+	}
+	f.RequestBody, f.ResponseBody, err = s.decodeBodies(encoding, f.UserID, req, resp)
+	return err
+}
@@ -0,0 +1,216 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Virtual key states, derived when a key is read.
+const (
+	KeyActive  = "active"
+	KeyExpired = "expired"
+	KeyRevoked = "revoked"
+)
+
+// VirtualKey is an API key issued to a proxy user. Only the SHA-256 of the
+// key is stored: Key is populated when it is issued and Prefix identifies
+// it afterwards. A rotated key names its replacement in RotatedTo and
+// expires at the end of its grace period. Requests made with a key are
+// charged to its Project, if any.
+type VirtualKey struct {
+	ID         int64      `json:"id"`
+	Prefix     string     `json:"prefix"`
+	Key        string     `json:"key,omitempty"`
+	UserID     string     `json:"user_id"`
+	Name       string     `json:"name,omitempty"`
+	Project    string     `json:"project,omitempty"`
+	Status     string     `json:"status"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RotatedTo  int64      `json:"rotated_to,omitempty"`
+}
+
+// VirtualKeyFilter narrows ListVirtualKeys results. Status is one of the
+// key states, or empty for all.
+type VirtualKeyFilter struct {
+	User   string
+	Status string
+}
+
+func (s *Store) initKeySchema() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS virtual_keys (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		key_hash TEXT NOT NULL UNIQUE,
+		prefix TEXT NOT NULL,
+		user_id TEXT NOT NULL,
+		name TEXT,
+		created_at DATETIME NOT NULL,
+		expires_at DATETIME,
+		revoked_at DATETIME,
+		last_used_at DATETIME,
+		rotated_to INTEGER
+	);
+	CREATE INDEX IF NOT EXISTS idx_virtual_keys_user ON virtual_keys(user_id);`
+	if _, err := s.db.Exec(query); err != nil {
+		return err
+	}
+	return s.ensureColumn("virtual_keys", "project", "TEXT")
+}
+
+const virtualKeyColumns = `id, prefix, user_id, COALESCE(name, ''), created_at, expires_at, revoked_at, last_used_at, COALESCE(rotated_to, 0), COALESCE(project, '')`
+
+// CreateVirtualKey stores k under the hash of its key and sets k.ID.
+func (s *Store) CreateVirtualKey(k *VirtualKey, hash string) error {
+	return insertVirtualKey(s.db, k, hash)
+}
+
+func insertVirtualKey(db execer, k *VirtualKey, hash string) error {
+	res, err := db.Exec(`INSERT INTO virtual_keys (key_hash, prefix, user_id, name, project, created_at, expires_at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		hash, k.Prefix, k.UserID, nullString(k.Name), nullString(k.Project), k.CreatedAt.UTC().Format(sqliteTimeLayout), nullTime(k.ExpiresAt))
+	if err != nil {
+		return err
+	}
+	k.ID, err = res.LastInsertId()
+	k.Status = k.status(time.Now())
+	return err
+}
+
+// RotateVirtualKey issues next for the owner and project of key id and lets the old
+// key expire at graceUntil, unless it expires sooner. It returns
+// ErrNotFound for an unknown or revoked key.
+func (s *Store) RotateVirtualKey(id int64, next *VirtualKey, hash string, graceUntil time.Time) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := tx.QueryRow(`SELECT user_id, COALESCE(name, ''), COALESCE(project, '') FROM virtual_keys WHERE id = ? AND revoked_at IS NULL`, id).Scan(&next.UserID, &next.Name, &next.Project); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		return err
+	}
+	if err := insertVirtualKey(tx, next, hash); err != nil {
+		return err
+	}
+	grace := graceUntil.UTC().Format(sqliteTimeLayout)
+	if _, err := tx.Exec(`UPDATE virtual_keys SET rotated_to = ?,
+		expires_at = CASE WHEN expires_at IS NULL OR expires_at > ? THEN ? ELSE expires_at END WHERE id = ?`,
+		next.ID, grace, grace, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RevokeVirtualKey rejects a key from now on. The key stays listed, as
+// revoked, and revoking it again keeps the first revocation time.
+func (s *Store) RevokeVirtualKey(id int64) error {
+	res, err := s.db.Exec(`UPDATE virtual_keys SET revoked_at = COALESCE(revoked_at, ?) WHERE id = ?`,
+		time.Now().UTC().Format(sqliteTimeLayout), id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ListVirtualKeys returns keys without their hashes, newest first.
+func (s *Store) ListVirtualKeys(f VirtualKeyFilter) ([]VirtualKey, error) {
+	query := `SELECT ` + virtualKeyColumns + ` FROM virtual_keys WHERE 1 = 1`
+	var args []interface{}
+	if f.User != "" {
+		query += ` AND user_id = ?`
+		args = append(args, f.User)
+	}
+	now := time.Now().UTC().Format(sqliteTimeLayout)
+	switch f.Status {
+	case KeyActive:
+		query += ` AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)`
+		args = append(args, now)
+	case KeyExpired:
+		query += ` AND revoked_at IS NULL AND expires_at <= ?`
+		args = append(args, now)
+	case KeyRevoked:
+		query += ` AND revoked_at IS NOT NULL`
+	}
+	rows, err := s.db.Query(query+` ORDER BY id DESC`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []VirtualKey{}
+	for rows.Next() {
+		k, err := scanVirtualKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *k)
+	}
+	return keys, rows.Err()
+}
+
+// GetVirtualKey returns a key by ID, or ErrNotFound.
+func (s *Store) GetVirtualKey(id int64) (*VirtualKey, error) {
+	k, err := scanVirtualKey(s.db.QueryRow(`SELECT `+virtualKeyColumns+` FROM virtual_keys WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return k, err
+}
+
+// VirtualKeyByHash returns the key with the given hash, or ErrNotFound.
+func (s *Store) VirtualKeyByHash(hash string) (*VirtualKey, error) {
+	k, err := scanVirtualKey(s.db.QueryRow(`SELECT `+virtualKeyColumns+` FROM virtual_keys WHERE key_hash = ?`, hash))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return k, err
+}
+
+// TouchVirtualKey records that a key was just used.
+func (s *Store) TouchVirtualKey(id int64, at time.Time) error {
+	_, err := s.db.Exec(`UPDATE virtual_keys SET last_used_at = ? WHERE id = ?`, at.UTC().Format(sqliteTimeLayout), id)
+	return err
+}
+
+func scanVirtualKey(row rowScanner) (*VirtualKey, error) {
+	var k VirtualKey
+	var created string
+	var expires, revoked, used sql.NullString
+	if err := row.Scan(&k.ID, &k.Prefix, &k.UserID, &k.Name, &created, &expires, &revoked, &used, &k.RotatedTo, &k.Project); err != nil {
+		return nil, err
+	}
+	k.CreatedAt = parseTimestamp(created)
+	k.ExpiresAt = parseNullTime(expires)
+	k.RevokedAt = parseNullTime(revoked)
+	k.LastUsedAt = parseNullTime(used)
+	k.Status = k.status(time.Now())
+	return &k, nil
+}
+
+// status is the state of k at now.
+func (k *VirtualKey) status(now time.Time) string {
+	switch {
+	case k.RevokedAt != nil:
+		return KeyRevoked
+	case k.ExpiresAt != nil && !now.Before(*k.ExpiresAt):
+		return KeyExpired
+	}
+	return KeyActive
+}
+
+func parseNullTime(v sql.NullString) *time.Time {
+	if !v.Valid {
+		return nil
+	}
+	t := parseTimestamp(v.String)
+	return &t
+}
@@ -0,0 +1,43 @@
+package store
+
+// DatabaseSize is the on-disk size of the main database file and the part
+// of it held by free pages, which VACUUM returns to the filesystem.
+type DatabaseSize struct {
+	Bytes     int64
+	FreeBytes int64
+}
+
+// Size reports the database size from its page counts.
+func (s *Store) Size() (DatabaseSize, error) {
+	var pageSize, pages, free int64
+	if err := s.db.QueryRow(`PRAGMA page_size`).Scan(&pageSize); err != nil {
+		return DatabaseSize{}, err
+	}
+	if err := s.db.QueryRow(`PRAGMA page_count`).Scan(&pages); err != nil {
+		return DatabaseSize{}, err
+	}
+	if err := s.db.QueryRow(`PRAGMA freelist_count`).Scan(&free); err != nil {
+		return DatabaseSize{}, err
+	}
+	return DatabaseSize{Bytes: pages * pageSize, FreeBytes: free * pageSize}, nil
+}
+
+// Vacuum rebuilds the database file, dropping free pages. It holds an
+// exclusive lock for its duration, so writers block until it finishes.
+func (s *Store) Vacuum() error {
+	_, err := s.db.Exec(`VACUUM`)
+	return err
+}
+
+// Analyze refreshes the query planner statistics.
+func (s *Store) Analyze() error {
+	_, err := s.db.Exec(`ANALYZE`)
+	return err
+}
+
+// CheckpointWAL copies the write-ahead log into the database and truncates
+// it. It is a no-op when the database is not in WAL mode.
+func (s *Store) CheckpointWAL() error {
+	var busy, logFrames, checkpointed int
+	return s.db.QueryRow(`PRAGMA wal_checkpoint(TRUNCATE)`).Scan(&busy, &logFrames, &checkpointed)
+}
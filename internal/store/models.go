@@ -0,0 +1,63 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+)
+
+type Model struct {
+	Name      string     `json:"name"`
+	State     string     `json:"state"`
+	Notes     string     `json:"notes,omitempty"`
+	SunsetAt  *time.Time `json:"sunset_at,omitempty"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+func (s *Store) initModelSchema() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS models (
+		name TEXT PRIMARY KEY,
+		state TEXT NOT NULL,
+		notes TEXT,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`
+	if _, err := s.db.Exec(query); err != nil {
+		return err
+	}
+	return s.ensureColumn("models", "sunset_at", "DATETIME")
+}
+
+// UpsertModel creates or updates a registry entry.
+func (s *Store) UpsertModel(m Model) error {
+	var sunset sql.NullTime
+	if m.SunsetAt != nil {
+		sunset = sql.NullTime{Time: m.SunsetAt.UTC(), Valid: true}
+	}
+	_, err := s.db.Exec(`INSERT INTO models (name, state, notes, sunset_at, updated_at) VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP)
+	          ON CONFLICT(name) DO UPDATE SET state = excluded.state, notes = excluded.notes, sunset_at = excluded.sunset_at, updated_at = CURRENT_TIMESTAMP`,
+		m.Name, m.State, nullString(m.Notes), sunset)
+	return err
+}
+
+// ListModels returns every registered model.
+func (s *Store) ListModels() ([]Model, error) {
+	rows, err := s.db.Query(`SELECT name, state, COALESCE(notes, ''), sunset_at, updated_at FROM models ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	models := []Model{}
+	for rows.Next() {
+		var m Model
+		var sunset sql.NullTime
+		if err := rows.Scan(&m.Name, &m.State, &m.Notes, &sunset, &m.UpdatedAt); err != nil {
+			return nil, err
+		}
+		if sunset.Valid {
+			m.SunsetAt = &sunset.Time
+		}
+		models = append(models, m)
+	}
+	return models, rows.Err()
+}
@@ -0,0 +1,313 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// PurgeReport counts the records a purge deleted, or would delete on a dry
+// run. Archived is how many of the interactions had their bodies in
+// archive objects, which are deleted with them. AccessLog counts the
+// recorded reads of their bodies. Rollups is how many usage rollup rows of
+// the purged user were folded into PurgedUser, and Trust, Alerts and Quotas
+// count the user's trust scores and feedback, anomaly alerts and daily
+// request counts. IDs are the purged interactions, for copies of the log
+// kept elsewhere.
+type PurgeReport struct {
+	DryRun       bool  `json:"dry_run"`
+	Interactions int   `json:"interactions"`
+	Archived     int   `json:"archived"`
+	Quarantined  int   `json:"quarantined"`
+	Triage       int   `json:"triage"`
+	Replays      int   `json:"replays"`
+	AccessLog    int   `json:"access_log"`
+	Rollups      int   `json:"rollups"`
+	Trust        int   `json:"trust"`
+	Alerts       int   `json:"alerts"`
+	Quotas       int   `json:"quotas"`
+	IDs          []int `json:"-"`
+}
+
+// PurgedUser is the user the usage rollups of purged users are counted
+// under, so usage totals are unchanged by an erasure.
+const PurgedUser = "(purged)"
+
+// initPurgeSchema creates the table that keeps the chain hashes of purged
+// interactions, so the records after them still verify.
+func (s *Store) initPurgeSchema() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS purged_interactions (
+		id INTEGER PRIMARY KEY,
+		chain_hash TEXT NOT NULL,
+		purged_at DATETIME NOT NULL
+	);`
+	_, err := s.db.Exec(query)
+	return err
+}
+
+// PurgeInteractions deletes every interaction f matches, ignoring its
+// Limit, together with their quarantined payloads, triage entries, replays,
+// access log entries, search index entries and archive objects, in one
+// transaction. Only the chain hashes of the interactions are kept. When f
+// selects a user and at most a time range, as an erasure request does, the
+// user's usage rollups over that range are folded into PurgedUser as well,
+// and the user's other records are deleted: payloads quarantined without a
+// logged interaction, trust scores and feedback, anomaly alerts and daily
+// request counts. With dryRun nothing is deleted.
+//
+// The archive objects are deleted last, before the transaction commits; if
+// that fails nothing is purged. Purges matching archived records fail when
+// no ArchiveDeleter is set.
+func (s *Store) PurgeInteractions(f LogFilter, dryRun bool) (*PurgeReport, error) {
+	where, args, err := f.where()
+	if err != nil {
+		return nil, err
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	report := &PurgeReport{DryRun: dryRun}
+	var archiveKeys []string
+	rows, err := tx.Query(`SELECT id, COALESCE(archive_key, '') FROM interaction_logs WHERE `+where+` ORDER BY id ASC`, args...)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var id int
+		var key string
+		if err := rows.Scan(&id, &key); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		report.IDs = append(report.IDs, id)
+		if key != "" {
+			archiveKeys = append(archiveKeys, key)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	report.Interactions, report.Archived = len(report.IDs), len(archiveKeys)
+	if report.Archived > 0 && s.archiveDelete == nil && !dryRun {
+		return nil, fmt.Errorf("%d of the interactions have their bodies in the archive, which is not configured", report.Archived)
+	}
+	if f.erasure() {
+		if report.Rollups, err = purgeRollups(tx, f, dryRun); err != nil {
+			return nil, err
+		}
+		if err := purgeUserRecords(tx, f, dryRun, report); err != nil {
+			return nil, err
+		}
+	}
+	if report.Interactions == 0 {
+		return report, tx.Commit()
+	}
+
+	// Dependent records go first, while the interactions still match f
+	matched := `(SELECT id FROM interaction_logs WHERE ` + where + `)`
+	dependents := []struct {
+		table, column string
+		count         *int
+	}{
+		{"quarantine", "log_id", &report.Quarantined},
+		{"triage", "log_id", &report.Triage},
+		{"replays", "interaction_id", &report.Replays},
+		{"access_log", "interaction_id", &report.AccessLog},
+	}
+	for _, d := range dependents {
+		var n int
+		if dryRun {
+			err = tx.QueryRow(`SELECT COUNT(*) FROM `+d.table+` WHERE `+d.column+` IN `+matched, args...).Scan(&n)
+		} else {
+			n, err = execCount(tx, `DELETE FROM `+d.table+` WHERE `+d.column+` IN `+matched, args...)
+		}
+		if err != nil {
+			return nil, err
+		}
+		*d.count += n
+	}
+	if dryRun {
+		return report, nil
+	}
+
+	if _, err := tx.Exec(`INSERT INTO purged_interactions (id, chain_hash, purged_at)
+		SELECT id, chain_hash, ? FROM interaction_logs WHERE chain_hash IS NOT NULL AND `+where,
+		append([]interface{}{time.Now().UTC().Format(sqliteTimeLayout)}, args...)...); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(`DELETE FROM interaction_search WHERE rowid IN `+matched, args...); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(`DELETE FROM interaction_logs WHERE `+where, args...); err != nil {
+		return nil, err
+	}
+	if len(archiveKeys) > 0 {
+		if err := s.archiveDelete(archiveKeys); err != nil {
+			return nil, fmt.Errorf("delete archive objects: %w", err)
+		}
+	}
+	return report, tx.Commit()
+}
+
+// erasure reports whether f selects a user's interactions, optionally over
+// a time range, and nothing narrower.
+func (f LogFilter) erasure() bool {
+	return f.User != "" && f.Path == "" && f.Blocked == nil && len(f.Metadata) == 0 && !f.Slow &&
+		f.Session == "" && f.Project == "" && f.Request == "" && f.Search == ""
+}
+
+// mergeUsageColumns adds a rollup row, as the excluded row, to another.
+const mergeUsageColumns = `
+	requests = requests + excluded.requests,
+	input_tokens = input_tokens + excluded.input_tokens,
+	output_tokens = output_tokens + excluded.output_tokens,
+	cost = cost + excluded.cost,
+	blocked = blocked + excluded.blocked,
+	redacted = redacted + excluded.redacted,
+	latency_ms = latency_ms + excluded.latency_ms`
+
+// purgeRollups moves the usage rollup rows of f.User in the periods that
+// overlap f's time range to PurgedUser, adding them to what is already
+// counted there, and returns how many rows it moved. With no time range
+// the user's conversation totals are deleted too.
+func purgeRollups(tx *sql.Tx, f LogFilter, dryRun bool) (int, error) {
+	moved := 0
+	for _, r := range []struct{ table, key, format string }{
+		{"usage_hourly", "hour", HourFormat},
+		{"usage_daily", "day", DayFormat},
+		{"usage_project_hourly", "hour, project", HourFormat},
+		{"usage_project_daily", "day, project", DayFormat},
+	} {
+		period := strings.SplitN(r.key, ",", 2)[0]
+		where := `user_id = ?`
+		args := []interface{}{f.User}
+		if !f.From.IsZero() {
+			where += ` AND ` + period + ` >= ?`
+			args = append(args, f.From.UTC().Format(r.format))
+		}
+		if !f.To.IsZero() {
+			// To is exclusive; the period holding the instant before it is the last
+			where += ` AND ` + period + ` <= ?`
+			args = append(args, f.To.UTC().Add(-time.Nanosecond).Format(r.format))
+		}
+
+		var n int
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM `+r.table+` WHERE `+where, args...).Scan(&n); err != nil {
+			return 0, err
+		}
+		moved += n
+		if dryRun || n == 0 {
+			continue
+		}
+		// WHERE is required before ON CONFLICT in an INSERT ... SELECT
+		if _, err := tx.Exec(fmt.Sprintf(`
+			INSERT INTO %[1]s (%[2]s, user_id, model, requests, input_tokens, output_tokens, cost, blocked, redacted, latency_ms)
+			SELECT %[2]s, ?, model, requests, input_tokens, output_tokens, cost, blocked, redacted, latency_ms
+			FROM %[1]s WHERE %[3]s
+			ON CONFLICT(%[2]s, user_id, model) DO UPDATE SET `+mergeUsageColumns, r.table, r.key, where),
+			append([]interface{}{PurgedUser}, args...)...); err != nil {
+			return 0, err
+		}
+		if _, err := tx.Exec(`DELETE FROM `+r.table+` WHERE `+where, args...); err != nil {
+			return 0, err
+		}
+	}
+	if !f.From.IsZero() || !f.To.IsZero() {
+		return moved, nil
+	}
+	var n int
+	var err error
+	if dryRun {
+		err = tx.QueryRow(`SELECT COUNT(*) FROM conversation_usage WHERE user_id = ?`, f.User).Scan(&n)
+	} else {
+		n, err = execCount(tx, `DELETE FROM conversation_usage WHERE user_id = ?`, f.User)
+	}
+	return moved + n, err
+}
+
+// purgeUserRecords deletes the records of f.User kept apart from the
+// interaction log, over f's time range where they have a time, and counts
+// them into report. The trust score has none and always goes; it is
+// recomputed from what remains.
+func purgeUserRecords(tx *sql.Tx, f LogFilter, dryRun bool, report *PurgeReport) error {
+	for _, r := range []struct {
+		table, where, column, format string
+		count                        *int
+	}{
+		// Interactions that were logged take their payloads with them
+		{"quarantine", `user_id = ? AND log_id IS NULL`, "created_at", sqliteTimeLayout, &report.Quarantined},
+		{"trust_feedback", `user_id = ?`, "created_at", sqliteTimeLayout, &report.Trust},
+		{"user_trust", `user_id = ?`, "", "", &report.Trust},
+		{"alerts", `user_id = ?`, "created_at", sqliteTimeLayout, &report.Alerts},
+		{"request_quotas", `user_id = ?`, "day", DayFormat, &report.Quotas},
+	} {
+		where := r.where
+		args := []interface{}{f.User}
+		if r.column != "" && !f.From.IsZero() {
+			where += ` AND ` + r.column + ` >= ?`
+			args = append(args, f.From.UTC().Format(r.format))
+		}
+		if r.column != "" && !f.To.IsZero() {
+			if r.format == DayFormat {
+				where += ` AND ` + r.column + ` <= ?`
+				args = append(args, f.To.UTC().Add(-time.Nanosecond).Format(r.format))
+			} else {
+				where += ` AND ` + r.column + ` < ?`
+				args = append(args, f.To.UTC().Format(r.format))
+			}
+		}
+		var n int
+		var err error
+		if dryRun {
+			err = tx.QueryRow(`SELECT COUNT(*) FROM `+r.table+` WHERE `+where, args...).Scan(&n)
+		} else {
+			n, err = execCount(tx, `DELETE FROM `+r.table+` WHERE `+where, args...)
+		}
+		if err != nil {
+			return err
+		}
+		*r.count += n
+	}
+	return nil
+}
+
+func execCount(tx *sql.Tx, query string, args ...interface{}) (int, error) {
+	res, err := tx.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+// purgedLink is the chain hash of a purged interaction.
+type purgedLink struct {
+	id   int
+	hash string
+}
+
+// purgedChain returns the chain hashes of the purged interactions in ID
+// order.
+func (s *Store) purgedChain() ([]purgedLink, error) {
+	rows, err := s.db.Query(`SELECT id, chain_hash FROM purged_interactions ORDER BY id ASC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var links []purgedLink
+	for rows.Next() {
+		var l purgedLink
+		if err := rows.Scan(&l.id, &l.hash); err != nil {
+			return nil, err
+		}
+		links = append(links, l)
+	}
+	return links, rows.Err()
+}
@@ -0,0 +1,86 @@
+package store
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPurgeInteractionsErasesUser(t *testing.T) {
+	s, err := NewStore(filepath.Join(t.TempDir(), "audit.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	now := time.Now().UTC()
+	for _, user := range []string{"alice", "bob"} {
+		id, err := s.LogInteraction(InteractionRecord{Timestamp: now, UserID: user, Method: "POST", Path: "/v1/chat", RequestBody: `{"message":"hi"}`, StatusCode: 200})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.RecordAccess("admin", "127.0.0.1", "view", []int{int(id)}); err != nil {
+			t.Fatal(err)
+		}
+		linked := &QuarantinedPayload{CreatedAt: now, UserID: user, Method: "POST", Path: "/v1/chat", Reason: "FORBIDDEN_CONTENT", Body: "x"}
+		if err := s.QuarantinePayload(linked); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.LinkQuarantine(linked.ID, id); err != nil {
+			t.Fatal(err)
+		}
+		// The interaction of this one failed to log
+		if err := s.QuarantinePayload(&QuarantinedPayload{CreatedAt: now, UserID: user, Method: "POST", Path: "/v1/chat", Reason: "FORBIDDEN_CONTENT", Body: "y"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.AddTrustFeedback(user, -1, "abusive", "admin"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.SaveAlert(&Alert{CreatedAt: now, UserID: user, Kind: "spike", Hour: now.Format(HourFormat), Observed: 10, Baseline: 1, Message: "spike"}); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := s.ConsumeQuota(user, now.Format(DayFormat), 100); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.RecordUsage(UsageSample{Time: now, UserID: user, Model: "command-r", InputTokens: 10}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.SaveTrustScores([]TrustScore{{UserID: "alice", Tier: "low", UpdatedAt: now}, {UserID: "bob", Tier: "high", UpdatedAt: now}}); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := s.PurgeInteractions(LogFilter{User: "alice"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Interactions != 1 || report.Quarantined != 2 || report.AccessLog != 1 || report.Trust != 2 || report.Alerts != 1 || report.Quotas != 1 {
+		t.Errorf("unexpected report %+v", report)
+	}
+
+	for _, table := range []string{"interaction_logs", "quarantine", "trust_feedback", "user_trust", "alerts", "request_quotas", "usage_hourly", "usage_daily"} {
+		for user, want := range map[string]int{"alice": 0, "bob": 1} {
+			if table == "quarantine" && user == "bob" {
+				want = 2
+			}
+			var n int
+			if err := s.db.QueryRow(`SELECT COUNT(*) FROM `+table+` WHERE user_id = ?`, user).Scan(&n); err != nil {
+				t.Fatalf("%s: %v", table, err)
+			}
+			if n != want {
+				t.Errorf("%s: %d rows for %s, want %d", table, n, user, want)
+			}
+		}
+	}
+	// access_log names the viewer, not the user; only bob's read is left
+	var reads int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM access_log`).Scan(&reads); err != nil {
+		t.Fatal(err)
+	}
+	if reads != 1 {
+		t.Errorf("access_log: %d rows, want 1", reads)
+	}
+	if chain, err := s.VerifyChain(); err != nil || !chain.Valid {
+		t.Errorf("chain after purge: %+v, %v", chain, err)
+	}
+}
@@ -0,0 +1,124 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// QuarantinedPayload is the request body of a blocked request, kept apart
+// from the interaction log so that reading it can be restricted. Reason is
+// the error code the request was blocked with, e.g. FORBIDDEN_CONTENT.
+type QuarantinedPayload struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	LogID     int64     `json:"log_id,omitempty"`
+	UserID    string    `json:"user_id"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	Reason    string    `json:"reason"`
+	// Body is only set by GetQuarantined
+	Body string `json:"body,omitempty"`
+}
+
+// QuarantineFilter narrows ListQuarantine results.
+type QuarantineFilter struct {
+	User   string
+	Reason string
+	Limit  int
+}
+
+func (s *Store) initQuarantineSchema() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS quarantine (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		created_at DATETIME NOT NULL,
+		log_id INTEGER,
+		user_id TEXT NOT NULL,
+		method TEXT NOT NULL,
+		path TEXT NOT NULL,
+		reason TEXT NOT NULL,
+		body BLOB,
+		body_encoding TEXT
+	);
+	CREATE INDEX IF NOT EXISTS idx_quarantine_created ON quarantine(created_at);`
+	_, err := s.db.Exec(query)
+	return err
+}
+
+// QuarantinePayload stores p and sets its ID. The body is compressed and
+// encrypted like interaction bodies.
+func (s *Store) QuarantinePayload(p *QuarantinedPayload) error {
+	body, _, encoding, err := s.encodeBodies(p.UserID, []byte(p.Body), nil)
+	if err != nil {
+		return err
+	}
+	res, err := s.db.Exec(`INSERT INTO quarantine (created_at, user_id, method, path, reason, body, body_encoding) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		p.CreatedAt.UTC().Format(sqliteTimeLayout), p.UserID, p.Method, p.Path, p.Reason, body, nullString(encoding))
+	if err != nil {
+		return err
+	}
+	p.ID, err = res.LastInsertId()
+	return err
+}
+
+// LinkQuarantine records the interaction a quarantined payload was taken from.
+func (s *Store) LinkQuarantine(id, logID int64) error {
+	_, err := s.db.Exec(`UPDATE quarantine SET log_id = ? WHERE id = ?`, logID, id)
+	return err
+}
+
+// ListQuarantine returns quarantined payloads without their bodies, newest first.
+func (s *Store) ListQuarantine(f QuarantineFilter) ([]QuarantinedPayload, error) {
+	query := `SELECT id, created_at, COALESCE(log_id, 0), user_id, method, path, reason FROM quarantine WHERE 1 = 1`
+	var args []interface{}
+	if f.User != "" {
+		query += ` AND user_id = ?`
+		args = append(args, f.User)
+	}
+	if f.Reason != "" {
+		query += ` AND reason = ?`
+		args = append(args, f.Reason)
+	}
+	query += ` ORDER BY id DESC LIMIT ?`
+	rows, err := s.db.Query(query, append(args, f.Limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	payloads := []QuarantinedPayload{}
+	for rows.Next() {
+		var p QuarantinedPayload
+		var created string
+		if err := rows.Scan(&p.ID, &created, &p.LogID, &p.UserID, &p.Method, &p.Path, &p.Reason); err != nil {
+			return nil, err
+		}
+		p.CreatedAt = parseTimestamp(created)
+		payloads = append(payloads, p)
+	}
+	return payloads, rows.Err()
+}
+
+// GetQuarantined returns a quarantined payload with its body, or
+// ErrNotFound.
+func (s *Store) GetQuarantined(id int64) (*QuarantinedPayload, error) {
+	var p QuarantinedPayload
+	var created string
+	var body []byte
+	var encoding sql.NullString
+	err := s.db.QueryRow(`SELECT id, created_at, COALESCE(log_id, 0), user_id, method, path, reason, body, body_encoding FROM quarantine WHERE id = ?`, id).
+		Scan(&p.ID, &created, &p.LogID, &p.UserID, &p.Method, &p.Path, &p.Reason, &body, &encoding)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if body, _, err = s.decodeBodies(encoding.String, p.UserID, body, nil); err != nil {
+		return nil, err
+	}
+	p.CreatedAt = parseTimestamp(created)
+	p.Body = string(body)
+	return &p, nil
+}
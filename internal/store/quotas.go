@@ -0,0 +1,72 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// QuotaUsage is the number of requests a user made on one (UTC) day.
+type QuotaUsage struct {
+	UserID string `json:"user_id"`
+	Day    string `json:"day"`
+	Count  int    `json:"count"`
+}
+
+func (s *Store) initQuotaSchema() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS request_quotas (
+		user_id TEXT NOT NULL,
+		day TEXT NOT NULL,
+		count INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (user_id, day)
+	);`
+	_, err := s.db.Exec(query)
+	return err
+}
+
+// ConsumeQuota counts one request for userID on day unless the user has
+// already made limit requests that day. It returns the count after the call
+// and whether the request was counted.
+func (s *Store) ConsumeQuota(userID, day string, limit int) (int, bool, error) {
+	var count int
+	err := s.db.QueryRow(`
+		INSERT INTO request_quotas (user_id, day, count) VALUES (?, ?, 1)
+		ON CONFLICT(user_id, day) DO UPDATE SET count = count + 1 WHERE count < ?
+		RETURNING count`, userID, day, limit).Scan(&count)
+	if errors.Is(err, sql.ErrNoRows) {
+		// The update was skipped because the quota is exhausted
+		return limit, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return count, true, nil
+}
+
+// QuotaUsageForDay returns every user's request count on day.
+func (s *Store) QuotaUsageForDay(day string) ([]QuotaUsage, error) {
+	rows, err := s.db.Query(`SELECT user_id, day, count FROM request_quotas WHERE day = ? ORDER BY user_id`, day)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	usage := []QuotaUsage{}
+	for rows.Next() {
+		var u QuotaUsage
+		if err := rows.Scan(&u.UserID, &u.Day, &u.Count); err != nil {
+			return nil, err
+		}
+		usage = append(usage, u)
+	}
+	return usage, rows.Err()
+}
+
+// PurgeQuotas deletes quota counters for days before the given day.
+func (s *Store) PurgeQuotas(before string) (int64, error) {
+	res, err := s.db.Exec(`DELETE FROM request_quotas WHERE day < ?`, before)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
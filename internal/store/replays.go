@@ -0,0 +1,92 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Replay is one re-execution of a logged interaction against the upstream,
+// with its diff against the original response.
+type Replay struct {
+	ID            int             `json:"id"`
+	InteractionID int             `json:"interaction_id"`
+	CreatedAt     time.Time       `json:"created_at"`
+	Author        string          `json:"author"`
+	Model         string          `json:"model,omitempty"`
+	StatusCode    int             `json:"status_code"`
+	LatencyMs     int64           `json:"latency_ms"`
+	Tokens        int             `json:"tokens"`
+	ResponseBody  string          `json:"response_body"`
+	Diff          json.RawMessage `json:"diff"`
+}
+
+func (s *Store) initReplaySchema() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS replays (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		interaction_id INTEGER NOT NULL,
+		user_id TEXT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		author TEXT,
+		model TEXT,
+		status_code INTEGER,
+		latency_ms INTEGER,
+		tokens INTEGER,
+		response_body BLOB,
+		body_encoding TEXT,
+		diff TEXT
+	);
+	CREATE INDEX IF NOT EXISTS idx_replays_interaction ON replays(interaction_id);`
+	_, err := s.db.Exec(query)
+	return err
+}
+
+// SaveReplay stores a replay of an interaction made by userID. The response
+// body is compressed and encrypted like interaction bodies.
+func (s *Store) SaveReplay(userID string, r *Replay) error {
+	_, body, encoding, err := s.encodeBodies(userID, nil, []byte(r.ResponseBody))
+	if err != nil {
+		return err
+	}
+	res, err := s.db.Exec(`INSERT INTO replays (interaction_id, user_id, created_at, author, model, status_code, latency_ms, tokens, response_body, body_encoding, diff) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		r.InteractionID, userID, r.CreatedAt.UTC().Format(sqliteTimeLayout), r.Author, nullString(r.Model), r.StatusCode, r.LatencyMs, r.Tokens, body, nullString(encoding), string(r.Diff))
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	r.ID = int(id)
+	return err
+}
+
+// ListReplays returns the replays of an interaction, newest first.
+func (s *Store) ListReplays(interactionID int) ([]Replay, error) {
+	rows, err := s.db.Query(`SELECT id, interaction_id, COALESCE(user_id, ''), created_at, COALESCE(author, ''), COALESCE(model, ''), status_code, latency_ms, tokens, response_body, COALESCE(body_encoding, ''), diff
+		FROM replays WHERE interaction_id = ? ORDER BY id DESC`, interactionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	replays := []Replay{}
+	for rows.Next() {
+		var r Replay
+		var userID, created, encoding string
+		var body []byte
+		var diff sql.NullString
+		if err := rows.Scan(&r.ID, &r.InteractionID, &userID, &created, &r.Author, &r.Model, &r.StatusCode, &r.LatencyMs, &r.Tokens, &body, &encoding, &diff); err != nil {
+			return nil, err
+		}
+		if _, body, err = s.decodeBodies(encoding, userID, nil, body); err != nil {
+			return nil, fmt.Errorf("replay %d: %w", r.ID, err)
+		}
+		r.CreatedAt = parseTimestamp(created)
+		r.ResponseBody = string(body)
+		if diff.Valid {
+			r.Diff = json.RawMessage(diff.String)
+		}
+		replays = append(replays, r)
+	}
+	return replays, rows.Err()
+}
@@ -0,0 +1,74 @@
+package store
+
+import (
+	"time"
+)
+
+// sqliteTimeLayout matches the format produced by CURRENT_TIMESTAMP.
+const sqliteTimeLayout = "2006-01-02 15:04:05"
+
+type CallerActivity struct {
+	UserID   string    `json:"user_id"`
+	LastSeen time.Time `json:"last_seen"`
+	Requests int       `json:"requests"`
+}
+
+// IdleCallers returns callers whose most recent interaction is older than cutoff.
+func (s *Store) IdleCallers(cutoff time.Time) ([]CallerActivity, error) {
+	query := `SELECT user_id, MAX(timestamp) AS last_seen, COUNT(*)
+	          FROM interaction_logs GROUP BY user_id HAVING last_seen < ? ORDER BY last_seen ASC`
+	rows, err := s.db.Query(query, cutoff.UTC().Format(sqliteTimeLayout))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var callers []CallerActivity
+	for rows.Next() {
+		var c CallerActivity
+		var lastSeen string
+		if err := rows.Scan(&c.UserID, &lastSeen, &c.Requests); err != nil {
+			return nil, err
+		}
+		c.LastSeen = parseTimestamp(lastSeen)
+		callers = append(callers, c)
+	}
+	return callers, rows.Err()
+}
+
+// ModelsUsedSince returns the distinct models served since the given time:
+// the routed model if the request was rewritten, otherwise its "model" field.
+// Rows predating the model column fall back to the uncompressed body.
+func (s *Store) ModelsUsedSince(since time.Time) (map[string]bool, error) {
+	query := `SELECT DISTINCT COALESCE(routed_model, model,
+	                 CASE WHEN body_encoding IS NULL AND json_valid(CAST(request_body AS TEXT))
+	                      THEN json_extract(CAST(request_body AS TEXT), '$.model') END)
+	          FROM interaction_logs
+	          WHERE timestamp >= ?`
+	rows, err := s.db.Query(query, since.UTC().Format(sqliteTimeLayout))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	models := make(map[string]bool)
+	for rows.Next() {
+		var model *string
+		if err := rows.Scan(&model); err != nil {
+			return nil, err
+		}
+		if model != nil && *model != "" {
+			models[*model] = true
+		}
+	}
+	return models, rows.Err()
+}
+
+func parseTimestamp(v string) time.Time {
+	for _, layout := range []string{sqliteTimeLayout, time.RFC3339Nano, "2006-01-02 15:04:05.999999999-07:00"} {
+		if t, err := time.Parse(layout, v); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
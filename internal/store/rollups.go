@@ -0,0 +1,580 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// HourFormat keys the hourly rollup tables; it sorts chronologically and a
+// day's hours share the "2006-01-02T" prefix.
+const HourFormat = "2006-01-02T15"
+
+// DayFormat keys the daily rollup table.
+const DayFormat = "2006-01-02"
+
+// LatencyBucketsMs are the upper bounds of the latency histogram kept per
+// hour. Latencies above the last bound are counted in the last bucket.
+var LatencyBucketsMs = []int64{25, 50, 100, 250, 500, 1000, 2500, 5000, 10000, 30000, 60000, 120000}
+
+// UsageSample is one interaction as counted in the rollup tables.
+type UsageSample struct {
+	Time         time.Time
+	UserID       string
+	Model        string
+	InputTokens  int
+	OutputTokens int
+	Cost         float64
+	LatencyMs    int64
+	Blocked      bool
+	// Redacted is set when governance redacted PII from the prompt before
+	// it was forwarded
+	Redacted bool
+	// Route keys the per-route latency histogram; see latency.Route
+	Route string
+	// Project is also rolled up per project when set
+	Project string
+}
+
+// UsageTotals aggregates rollup rows for one user, model or project, or
+// overall. Blocked and Redacted count requests, not matches.
+type UsageTotals struct {
+	Key      string  `json:"key,omitempty"`
+	Requests int     `json:"requests"`
+	Tokens   int     `json:"tokens"`
+	Cost     float64 `json:"estimated_cost"`
+	Blocked  int     `json:"blocked"`
+	Redacted int     `json:"redacted"`
+}
+
+// UsageStat is one period of a /api/stats series, for one user, model or
+// project when grouped.
+type UsageStat struct {
+	Period string `json:"period"`
+	UsageTotals
+	AvgLatencyMs float64 `json:"avg_latency_ms"`
+}
+
+// StatsQuery selects a stats series. Granularity is "hour" or "day" and
+// GroupBy is "", "user", "model" or "project"; From and To bound the
+// periods, To exclusive. User, Model and Project filter the rows before
+// grouping. Grouping or filtering by project only counts the interactions
+// that were charged to one.
+type StatsQuery struct {
+	Granularity string
+	GroupBy     string
+	From, To    time.Time
+	User        string
+	Model       string
+	Project     string
+}
+
+// ByProject reports whether q reads the per-project rollups.
+func (q StatsQuery) ByProject() bool {
+	return q.GroupBy == "project" || q.Project != ""
+}
+
+// BackfillRow is an interaction logged before the rollups were kept live.
+type BackfillRow struct {
+	ID   int64
+	Path string
+	UsageSample
+}
+
+// UsageSummary is the dashboard view of usage over a time range.
+type UsageSummary struct {
+	From         string        `json:"from"`
+	To           string        `json:"to"`
+	Totals       UsageTotals   `json:"totals"`
+	P95LatencyMs int64         `json:"p95_latency_ms"`
+	TopUsers     []UsageTotals `json:"top_users"`
+	TopModels    []UsageTotals `json:"top_models"`
+}
+
+func (s *Store) initRollupSchema() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS usage_hourly (
+		hour TEXT NOT NULL,
+		user_id TEXT NOT NULL,
+		model TEXT NOT NULL,
+		requests INTEGER NOT NULL DEFAULT 0,
+		input_tokens INTEGER NOT NULL DEFAULT 0,
+		output_tokens INTEGER NOT NULL DEFAULT 0,
+		cost REAL NOT NULL DEFAULT 0,
+		blocked INTEGER NOT NULL DEFAULT 0,
+		redacted INTEGER NOT NULL DEFAULT 0,
+		latency_ms INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (hour, user_id, model)
+	);
+	CREATE TABLE IF NOT EXISTS usage_daily (
+		day TEXT NOT NULL,
+		user_id TEXT NOT NULL,
+		model TEXT NOT NULL,
+		requests INTEGER NOT NULL DEFAULT 0,
+		input_tokens INTEGER NOT NULL DEFAULT 0,
+		output_tokens INTEGER NOT NULL DEFAULT 0,
+		cost REAL NOT NULL DEFAULT 0,
+		blocked INTEGER NOT NULL DEFAULT 0,
+		redacted INTEGER NOT NULL DEFAULT 0,
+		latency_ms INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (day, user_id, model)
+	);
+	CREATE TABLE IF NOT EXISTS usage_project_hourly (
+		hour TEXT NOT NULL,
+		project TEXT NOT NULL,
+		user_id TEXT NOT NULL,
+		model TEXT NOT NULL,
+		requests INTEGER NOT NULL DEFAULT 0,
+		input_tokens INTEGER NOT NULL DEFAULT 0,
+		output_tokens INTEGER NOT NULL DEFAULT 0,
+		cost REAL NOT NULL DEFAULT 0,
+		blocked INTEGER NOT NULL DEFAULT 0,
+		redacted INTEGER NOT NULL DEFAULT 0,
+		latency_ms INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (hour, project, user_id, model)
+	);
+	CREATE TABLE IF NOT EXISTS usage_project_daily (
+		day TEXT NOT NULL,
+		project TEXT NOT NULL,
+		user_id TEXT NOT NULL,
+		model TEXT NOT NULL,
+		requests INTEGER NOT NULL DEFAULT 0,
+		input_tokens INTEGER NOT NULL DEFAULT 0,
+		output_tokens INTEGER NOT NULL DEFAULT 0,
+		cost REAL NOT NULL DEFAULT 0,
+		blocked INTEGER NOT NULL DEFAULT 0,
+		redacted INTEGER NOT NULL DEFAULT 0,
+		latency_ms INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (day, project, user_id, model)
+	);
+	CREATE TABLE IF NOT EXISTS latency_hourly (
+		hour TEXT NOT NULL,
+		le_ms INTEGER NOT NULL,
+		count INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (hour, le_ms)
+	);
+	CREATE TABLE IF NOT EXISTS latency_route_hourly (
+		hour TEXT NOT NULL,
+		route TEXT NOT NULL,
+		le_ms INTEGER NOT NULL,
+		count INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (hour, route, le_ms)
+	);
+	CREATE TABLE IF NOT EXISTS rollup_state (
+		name TEXT PRIMARY KEY,
+		value INTEGER NOT NULL
+	);`
+	if _, err := s.db.Exec(query); err != nil {
+		return err
+	}
+	// Interactions from here on are rolled up by the audit worker; older
+	// ones are left to the backfill. Recorded once, on the first start
+	// with rollups.
+	_, err := s.db.Exec(`INSERT OR IGNORE INTO rollup_state (name, value)
+		SELECT 'live_from', COALESCE(MAX(id), 0) + 1 FROM interaction_logs`)
+	return err
+}
+
+// RecordUsage adds one interaction to the hourly and daily rollups.
+func (s *Store) RecordUsage(u UsageSample) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := addUsage(tx, u); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// addUsageColumns adds a sample, as the excluded row, to a rollup row.
+const addUsageColumns = `
+	requests = requests + 1,
+	input_tokens = input_tokens + excluded.input_tokens,
+	output_tokens = output_tokens + excluded.output_tokens,
+	cost = cost + excluded.cost,
+	blocked = blocked + excluded.blocked,
+	redacted = redacted + excluded.redacted,
+	latency_ms = latency_ms + excluded.latency_ms`
+
+func addUsage(tx execer, u UsageSample) error {
+	t := u.Time.UTC()
+	for _, r := range []struct{ table, column, period string }{
+		{"usage_hourly", "hour", t.Format(HourFormat)},
+		{"usage_daily", "day", t.Format(DayFormat)},
+	} {
+		_, err := tx.Exec(fmt.Sprintf(`
+			INSERT INTO %[1]s (%[2]s, user_id, model, requests, input_tokens, output_tokens, cost, blocked, redacted, latency_ms)
+			VALUES (?, ?, ?, 1, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT(%[2]s, user_id, model) DO UPDATE SET `+addUsageColumns, r.table, r.column),
+			r.period, u.UserID, u.Model, u.InputTokens, u.OutputTokens, u.Cost, boolToInt(u.Blocked), boolToInt(u.Redacted), u.LatencyMs)
+		if err != nil {
+			return err
+		}
+	}
+	if u.Project != "" {
+		for _, r := range []struct{ table, column, period string }{
+			{"usage_project_hourly", "hour", t.Format(HourFormat)},
+			{"usage_project_daily", "day", t.Format(DayFormat)},
+		} {
+			_, err := tx.Exec(fmt.Sprintf(`
+				INSERT INTO %[1]s (%[2]s, project, user_id, model, requests, input_tokens, output_tokens, cost, blocked, redacted, latency_ms)
+				VALUES (?, ?, ?, ?, 1, ?, ?, ?, ?, ?, ?)
+				ON CONFLICT(%[2]s, project, user_id, model) DO UPDATE SET `+addUsageColumns, r.table, r.column),
+				r.period, u.Project, u.UserID, u.Model, u.InputTokens, u.OutputTokens, u.Cost, boolToInt(u.Blocked), boolToInt(u.Redacted), u.LatencyMs)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	_, err := tx.Exec(`
+		INSERT INTO latency_hourly (hour, le_ms, count) VALUES (?, ?, 1)
+		ON CONFLICT(hour, le_ms) DO UPDATE SET count = count + 1`,
+		t.Format(HourFormat), latencyBucket(u.LatencyMs))
+	if err != nil || u.Route == "" {
+		return err
+	}
+	_, err = tx.Exec(`
+		INSERT INTO latency_route_hourly (hour, route, le_ms, count) VALUES (?, ?, ?, 1)
+		ON CONFLICT(hour, route, le_ms) DO UPDATE SET count = count + 1`,
+		t.Format(HourFormat), u.Route, latencyBucket(u.LatencyMs))
+	return err
+}
+
+// PendingBackfill returns up to limit of the oldest interactions that were
+// logged before live rollups started and have not been backfilled yet.
+// Their token counts have no input/output split and are returned as input.
+func (s *Store) PendingBackfill(limit int) ([]BackfillRow, error) {
+	rows, err := s.db.Query(`
+		SELECT id, timestamp, COALESCE(user_id, ''), COALESCE(path, ''),
+		       COALESCE(routed_model, model,
+		                CASE WHEN body_encoding IS NULL AND json_valid(CAST(request_body AS TEXT))
+		                     THEN json_extract(CAST(request_body AS TEXT), '$.model') END, ''),
+		       COALESCE(token_count, 0), COALESCE(latency_ms, 0), COALESCE(is_blocked, 0), COALESCE(is_redacted, 0)
+		FROM interaction_logs
+		WHERE id < (SELECT value FROM rollup_state WHERE name = 'live_from')
+		  AND id > COALESCE((SELECT value FROM rollup_state WHERE name = 'backfilled_through'), 0)
+		ORDER BY id LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var pending []BackfillRow
+	for rows.Next() {
+		var r BackfillRow
+		var ts string
+		if err := rows.Scan(&r.ID, &ts, &r.UserID, &r.Path, &r.Model, &r.InputTokens, &r.LatencyMs, &r.Blocked, &r.Redacted); err != nil {
+			return nil, err
+		}
+		r.Time = parseTimestamp(ts)
+		pending = append(pending, r)
+	}
+	return pending, rows.Err()
+}
+
+// RecordBackfill adds rows to the rollups and advances the backfill
+// position past them in one transaction, so a restart resumes cleanly.
+func (s *Store) RecordBackfill(rows []BackfillRow) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, r := range rows {
+		if err := addUsage(tx, r.UsageSample); err != nil {
+			return err
+		}
+	}
+	_, err = tx.Exec(`INSERT INTO rollup_state (name, value) VALUES ('backfilled_through', ?)
+		ON CONFLICT(name) DO UPDATE SET value = excluded.value`, rows[len(rows)-1].ID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Stats returns the usage series selected by q, oldest period first and,
+// within a period, busiest user, model or project first.
+func (s *Store) Stats(q StatsQuery) ([]UsageStat, error) {
+	table, column, layout := "usage_daily", "day", DayFormat
+	if q.Granularity == "hour" {
+		table, column, layout = "usage_hourly", "hour", HourFormat
+	}
+	if q.ByProject() {
+		table = strings.Replace(table, "usage_", "usage_project_", 1)
+	}
+	key := "''"
+	switch q.GroupBy {
+	case "user":
+		key = "user_id"
+	case "model":
+		key = "model"
+	case "project":
+		key = "project"
+	case "":
+	default:
+		return nil, fmt.Errorf("unknown group_by %q", q.GroupBy)
+	}
+
+	query := fmt.Sprintf(`SELECT %[1]s, %[2]s, `+totalsColumns+`, COALESCE(SUM(latency_ms), 0)
+		FROM %[3]s WHERE %[1]s >= ? AND %[1]s < ?`, column, key, table)
+	args := []interface{}{q.From.UTC().Format(layout), q.To.UTC().Format(layout)}
+	if q.User != "" {
+		query += ` AND user_id = ?`
+		args = append(args, q.User)
+	}
+	if q.Model != "" {
+		query += ` AND model = ?`
+		args = append(args, q.Model)
+	}
+	if q.Project != "" {
+		query += ` AND project = ?`
+		args = append(args, q.Project)
+	}
+	query += fmt.Sprintf(` GROUP BY %[1]s, %[2]s ORDER BY %[1]s, SUM(requests) DESC, %[2]s`, column, key)
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats := []UsageStat{}
+	for rows.Next() {
+		var st UsageStat
+		var latency int64
+		if err := rows.Scan(&st.Period, &st.Key, &st.Requests, &st.Tokens, &st.Cost, &st.Blocked, &st.Redacted, &latency); err != nil {
+			return nil, err
+		}
+		if st.Requests > 0 {
+			st.AvgLatencyMs = float64(latency) / float64(st.Requests)
+		}
+		stats = append(stats, st)
+	}
+	return stats, rows.Err()
+}
+
+// ProjectCost returns the estimated cost charged to project since the
+// start of the hour holding since.
+func (s *Store) ProjectCost(project string, since time.Time) (float64, error) {
+	var cost float64
+	err := s.db.QueryRow(`SELECT COALESCE(SUM(cost), 0) FROM usage_project_hourly WHERE project = ? AND hour >= ?`,
+		project, since.UTC().Format(HourFormat)).Scan(&cost)
+	return cost, err
+}
+
+// Summary totals the rollups of the hours in [from, to), with the five busiest
+// users and models by request count. The p95 latency is the upper bound of
+// the histogram bucket holding the 95th percentile.
+func (s *Store) Summary(from, to time.Time) (UsageSummary, error) {
+	summary := UsageSummary{From: from.UTC().Format(time.RFC3339), To: to.UTC().Format(time.RFC3339)}
+	hours := []interface{}{from.UTC().Format(HourFormat), to.UTC().Format(HourFormat)}
+
+	totals, err := s.usageTotals(`SELECT '', `+totalsColumns+` FROM usage_hourly WHERE hour >= ? AND hour < ?`, hours...)
+	if err != nil {
+		return summary, err
+	}
+	if len(totals) > 0 {
+		summary.Totals = totals[0]
+	}
+	if summary.TopUsers, err = s.usageTotals(`SELECT user_id, `+totalsColumns+` FROM usage_hourly
+		WHERE hour >= ? AND hour < ? GROUP BY user_id ORDER BY SUM(requests) DESC, user_id LIMIT 5`, hours...); err != nil {
+		return summary, err
+	}
+	if summary.TopModels, err = s.usageTotals(`SELECT model, `+totalsColumns+` FROM usage_hourly
+		WHERE hour >= ? AND hour < ? AND model != '' GROUP BY model ORDER BY SUM(requests) DESC, model LIMIT 5`, hours...); err != nil {
+		return summary, err
+	}
+	summary.P95LatencyMs, err = s.latencyPercentile(hours, 0.95)
+	return summary, err
+}
+
+// TopViolators totals the rollups of the hours in [from, to) for the limit
+// users with the most blocked requests.
+func (s *Store) TopViolators(from, to time.Time, limit int) ([]UsageTotals, error) {
+	return s.usageTotals(`SELECT user_id, `+totalsColumns+` FROM usage_hourly
+		WHERE hour >= ? AND hour < ? GROUP BY user_id HAVING SUM(blocked) > 0 ORDER BY SUM(blocked) DESC, user_id LIMIT ?`,
+		from.UTC().Format(HourFormat), to.UTC().Format(HourFormat), limit)
+}
+
+// TopSpenders totals the rollups of the hours in [from, to) for the limit
+// users with the highest estimated cost.
+func (s *Store) TopSpenders(from, to time.Time, limit int) ([]UsageTotals, error) {
+	return s.usageTotals(`SELECT user_id, `+totalsColumns+` FROM usage_hourly
+		WHERE hour >= ? AND hour < ? GROUP BY user_id HAVING SUM(cost) > 0 ORDER BY SUM(cost) DESC, user_id LIMIT ?`,
+		from.UTC().Format(HourFormat), to.UTC().Format(HourFormat), limit)
+}
+
+const totalsColumns = `COALESCE(SUM(requests), 0), COALESCE(SUM(input_tokens + output_tokens), 0),
+	COALESCE(SUM(cost), 0), COALESCE(SUM(blocked), 0), COALESCE(SUM(redacted), 0)`
+
+func (s *Store) usageTotals(query string, args ...interface{}) ([]UsageTotals, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	totals := []UsageTotals{}
+	for rows.Next() {
+		var t UsageTotals
+		if err := rows.Scan(&t.Key, &t.Requests, &t.Tokens, &t.Cost, &t.Blocked, &t.Redacted); err != nil {
+			return nil, err
+		}
+		totals = append(totals, t)
+	}
+	return totals, rows.Err()
+}
+
+// latencyPercentile returns the p percentile of the merged histogram of
+// the given hours.
+func (s *Store) latencyPercentile(hours []interface{}, p float64) (int64, error) {
+	rows, err := s.db.Query(`SELECT '', le_ms, SUM(count) FROM latency_hourly WHERE hour >= ? AND hour < ? GROUP BY le_ms ORDER BY le_ms`, hours...)
+	if err != nil {
+		return 0, err
+	}
+	histograms, err := scanHistograms(rows)
+	if err != nil {
+		return 0, err
+	}
+	return histograms[""].percentile(p), nil
+}
+
+// LatencyBreakdown is the latency distribution of one route, or of all
+// requests when Route is empty. Percentiles are histogram bucket upper bounds.
+type LatencyBreakdown struct {
+	Route    string `json:"route,omitempty"`
+	Requests int64  `json:"requests"`
+	P50Ms    int64  `json:"p50_ms"`
+	P90Ms    int64  `json:"p90_ms"`
+	P95Ms    int64  `json:"p95_ms"`
+	P99Ms    int64  `json:"p99_ms"`
+}
+
+// LatencyStats returns the latency breakdown of all requests in the hours
+// in [from, to) and of each route, busiest first; with route set, only
+// that route is broken down. Routes are recorded from the first start
+// that keeps per-route histograms on, so the overall figures may cover
+// more requests.
+func (s *Store) LatencyStats(from, to time.Time, route string) (LatencyBreakdown, []LatencyBreakdown, error) {
+	hours := []interface{}{from.UTC().Format(HourFormat), to.UTC().Format(HourFormat)}
+	rows, err := s.db.Query(`SELECT '', le_ms, SUM(count) FROM latency_hourly WHERE hour >= ? AND hour < ? GROUP BY le_ms ORDER BY le_ms`, hours...)
+	if err != nil {
+		return LatencyBreakdown{}, nil, err
+	}
+	overall, err := scanHistograms(rows)
+	if err != nil {
+		return LatencyBreakdown{}, nil, err
+	}
+
+	query := `SELECT route, le_ms, SUM(count) FROM latency_route_hourly WHERE hour >= ? AND hour < ?`
+	if route != "" {
+		query += ` AND route = ?`
+		hours = append(hours, route)
+	}
+	rows, err = s.db.Query(query+` GROUP BY route, le_ms ORDER BY route, le_ms`, hours...)
+	if err != nil {
+		return LatencyBreakdown{}, nil, err
+	}
+	perRoute, err := scanHistograms(rows)
+	if err != nil {
+		return LatencyBreakdown{}, nil, err
+	}
+	routes := make([]LatencyBreakdown, 0, len(perRoute))
+	for name, h := range perRoute {
+		routes = append(routes, h.breakdown(name))
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Requests != routes[j].Requests {
+			return routes[i].Requests > routes[j].Requests
+		}
+		return routes[i].Route < routes[j].Route
+	})
+	return overall[""].breakdown(""), routes, nil
+}
+
+// histogram is a latency histogram ordered by bucket upper bound.
+type histogram []histogramBucket
+
+type histogramBucket struct{ le, count int64 }
+
+// scanHistograms reads (key, le_ms, count) rows ordered by le_ms within
+// each key.
+func scanHistograms(rows *sql.Rows) (map[string]histogram, error) {
+	defer rows.Close()
+	histograms := map[string]histogram{}
+	for rows.Next() {
+		var key string
+		var le, count int64
+		if err := rows.Scan(&key, &le, &count); err != nil {
+			return nil, err
+		}
+		histograms[key] = append(histograms[key], histogramBucket{le, count})
+	}
+	return histograms, rows.Err()
+}
+
+func (h histogram) total() int64 {
+	var total int64
+	for _, b := range h {
+		total += b.count
+	}
+	return total
+}
+
+// percentile walks the histogram from the fastest bucket until it has
+// covered fraction p of the requests.
+func (h histogram) percentile(p float64) int64 {
+	total := h.total()
+	if total == 0 {
+		return 0
+	}
+	var seen int64
+	for _, b := range h {
+		seen += b.count
+		if float64(seen) >= p*float64(total) {
+			return b.le
+		}
+	}
+	return h[len(h)-1].le
+}
+
+func (h histogram) breakdown(route string) LatencyBreakdown {
+	return LatencyBreakdown{
+		Route:    route,
+		Requests: h.total(),
+		P50Ms:    h.percentile(0.50),
+		P90Ms:    h.percentile(0.90),
+		P95Ms:    h.percentile(0.95),
+		P99Ms:    h.percentile(0.99),
+	}
+}
+
+func latencyBucket(ms int64) int64 {
+	for _, le := range LatencyBucketsMs {
+		if ms <= le {
+			return le
+		}
+	}
+	return LatencyBucketsMs[len(LatencyBucketsMs)-1]
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,95 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"math"
+)
+
+// searchBatch is how many interactions BackfillSearchIndex indexes per
+// transaction.
+const searchBatch = 500
+
+// initSearchSchema creates the full-text index over request and response
+// bodies. It is contentless: only the index is kept, keyed by interaction
+// ID, so bodies are not stored a second time, and contentless_delete lets
+// exported interactions be removed from it.
+func (s *Store) initSearchSchema() error {
+	_, err := s.db.Exec(`CREATE VIRTUAL TABLE IF NOT EXISTS interaction_search USING fts5(
+		request, response, content = '', contentless_delete = 1
+	)`)
+	return err
+}
+
+// SetSearchIndex adds the bodies of interactions logged from now on to the
+// full-text index that LogFilter.Search queries.
+func (s *Store) SetSearchIndex(enabled bool) {
+	s.search = enabled
+}
+
+func indexBodies(db execer, id int64, req, resp string) error {
+	_, err := db.Exec(`INSERT INTO interaction_search (rowid, request, response) VALUES (?, ?, ?)`, id, req, resp)
+	return err
+}
+
+// BackfillSearchIndex indexes the interactions that are not in the index
+// yet, newest first, such as those logged before it was enabled. Archived
+// interactions are indexed without their bodies. It returns how many were
+// indexed.
+func (s *Store) BackfillSearchIndex(ctx context.Context) (int, error) {
+	total := 0
+	before := int64(math.MaxInt64)
+	for ctx.Err() == nil {
+		rows, err := s.db.QueryContext(ctx, `SELECT id, user_id, request_body, response_body, body_encoding FROM interaction_logs l
+			WHERE id < ? AND NOT EXISTS (SELECT 1 FROM interaction_search WHERE rowid = l.id)
+			ORDER BY id DESC LIMIT ?`, before, searchBatch)
+		if err != nil {
+			return total, err
+		}
+		type doc struct {
+			id        int64
+			req, resp string
+		}
+		var docs []doc
+		for rows.Next() {
+			var d doc
+			var user string
+			var req, resp []byte
+			var encoding sql.NullString
+			if err := rows.Scan(&d.id, &user, &req, &resp, &encoding); err != nil {
+				rows.Close()
+				return total, err
+			}
+			if req, resp, err = s.decodeBodies(encoding.String, user, req, resp); err != nil {
+				rows.Close()
+				return total, err
+			}
+			d.req, d.resp = string(req), string(resp)
+			docs = append(docs, d)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return total, err
+		}
+		if len(docs) == 0 {
+			return total, nil
+		}
+
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
+			return total, err
+		}
+		for _, d := range docs {
+			if err := indexBodies(tx, d.id, d.req, d.resp); err != nil {
+				tx.Rollback()
+				return total, err
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			return total, err
+		}
+		total += len(docs)
+		before = docs[len(docs)-1].id
+	}
+	return total, ctx.Err()
+}
@@ -0,0 +1,52 @@
+package store
+
+import "time"
+
+// Session is the interactions that share a client-supplied session ID, in
+// the order they were logged.
+type Session struct {
+	ID           string              `json:"session_id"`
+	Users        []string            `json:"users"`
+	Started      time.Time           `json:"started"`
+	LastActive   time.Time           `json:"last_active"`
+	Interactions []InteractionRecord `json:"interactions"`
+}
+
+// GetSession returns up to limit interactions of session id, oldest first,
+// optionally only user's. It returns ErrNotFound when there are none.
+func (s *Store) GetSession(id, user string, limit int) (*Session, error) {
+	query := `SELECT ` + interactionColumns + ` FROM interaction_logs WHERE session_id = ?`
+	args := []interface{}{id}
+	if user != "" {
+		query += ` AND user_id = ?`
+		args = append(args, user)
+	}
+	rows, err := s.db.Query(query+` ORDER BY id ASC LIMIT ?`, append(args, limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sess := &Session{ID: id, Users: []string{}}
+	seen := map[string]bool{}
+	for rows.Next() {
+		r, err := s.scanInteraction(rows)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[r.UserID] {
+			seen[r.UserID] = true
+			sess.Users = append(sess.Users, r.UserID)
+		}
+		sess.Interactions = append(sess.Interactions, *r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(sess.Interactions) == 0 {
+		return nil, ErrNotFound
+	}
+	sess.Started = sess.Interactions[0].Timestamp
+	sess.LastActive = sess.Interactions[len(sess.Interactions)-1].Timestamp
+	return sess, nil
+}
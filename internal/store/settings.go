@@ -0,0 +1,55 @@
+package store
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Setting is the stored value of one runtime setting, as JSON.
+type Setting struct {
+	Key       string          `json:"key"`
+	Value     json.RawMessage `json:"value"`
+	UpdatedBy string          `json:"updated_by,omitempty"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+func (s *Store) initSettingsSchema() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS settings (
+		key TEXT PRIMARY KEY,
+		value TEXT NOT NULL,
+		updated_by TEXT,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`
+	_, err := s.db.Exec(query)
+	return err
+}
+
+// PutSetting creates or replaces a setting.
+func (s *Store) PutSetting(st Setting) error {
+	_, err := s.db.Exec(`INSERT INTO settings (key, value, updated_by, updated_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(key) DO UPDATE SET value = excluded.value, updated_by = excluded.updated_by, updated_at = excluded.updated_at`,
+		st.Key, string(st.Value), nullString(st.UpdatedBy), st.UpdatedAt.UTC())
+	return err
+}
+
+// ListSettings returns every stored setting.
+func (s *Store) ListSettings() ([]Setting, error) {
+	rows, err := s.db.Query(`SELECT key, value, COALESCE(updated_by, ''), updated_at FROM settings ORDER BY key`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	settings := []Setting{}
+	for rows.Next() {
+		var st Setting
+		var value string
+		if err := rows.Scan(&st.Key, &value, &st.UpdatedBy, &st.UpdatedAt); err != nil {
+			return nil, err
+		}
+		st.Value = json.RawMessage(value)
+		settings = append(settings, st)
+	}
+	return settings, rows.Err()
+}
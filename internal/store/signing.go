@@ -0,0 +1,72 @@
+package store
+
+import (
+	"time"
+)
+
+// SigningKey is a service's HMAC request-signing key. Secret is only
+// populated when the key is created or rotated.
+type SigningKey struct {
+	Name      string    `json:"name"`
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (s *Store) initSigningSchema() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS signing_keys (
+		name TEXT PRIMARY KEY,
+		secret TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`
+	_, err := s.db.Exec(query)
+	return err
+}
+
+// PutSigningKey creates the key for a service or replaces its secret.
+func (s *Store) PutSigningKey(name, secret string) error {
+	_, err := s.db.Exec(`INSERT INTO signing_keys (name, secret) VALUES (?, ?)
+		ON CONFLICT(name) DO UPDATE SET secret = excluded.secret, created_at = CURRENT_TIMESTAMP`, name, secret)
+	return err
+}
+
+// ListSigningKeys returns all signing keys without their secrets.
+func (s *Store) ListSigningKeys() ([]SigningKey, error) {
+	rows, err := s.db.Query(`SELECT name, created_at FROM signing_keys ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []SigningKey{}
+	for rows.Next() {
+		var k SigningKey
+		if err := rows.Scan(&k.Name, &k.CreatedAt); err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+// DeleteSigningKey revokes a service's signing key.
+func (s *Store) DeleteSigningKey(name string) error {
+	res, err := s.db.Exec(`DELETE FROM signing_keys WHERE name = ?`, name)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// SigningSecret returns the secret for a service, if it has a key.
+func (s *Store) SigningSecret(name string) (string, bool) {
+	var secret string
+	err := s.db.QueryRow(`SELECT secret FROM signing_keys WHERE name = ?`, name).Scan(&secret)
+	if err != nil {
+		return "", false
+	}
+	return secret, true
+}
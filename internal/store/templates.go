@@ -0,0 +1,313 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// ErrNotFound is returned when a requested record does not exist.
+var ErrNotFound = errors.New("not found")
+
+type PromptTemplate struct {
+	ID           int       `json:"id"`
+	Name         string    `json:"name"`
+	Version      int       `json:"version"`
+	Body         string    `json:"body"`
+	Path         string    `json:"path"`
+	Model        string    `json:"model"`
+	AllowedUsers []string  `json:"allowed_users"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func (s *Store) initTemplateSchema() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS prompt_templates (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		version INTEGER NOT NULL,
+		body TEXT NOT NULL,
+		path TEXT NOT NULL,
+		model TEXT,
+		allowed_users TEXT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE(name, version)
+	);`
+	_, err := s.db.Exec(query)
+	return err
+}
+
+// SaveTemplate stores t as the next version of its name and returns the saved record.
+func (s *Store) SaveTemplate(t PromptTemplate) (*PromptTemplate, error) {
+	allowed, err := json.Marshal(t.AllowedUsers)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := tx.QueryRow(`SELECT COALESCE(MAX(version), 0) + 1 FROM prompt_templates WHERE name = ?`, t.Name).Scan(&t.Version); err != nil {
+		return nil, err
+	}
+	res, err := tx.Exec(`INSERT INTO prompt_templates (name, version, body, path, model, allowed_users) VALUES (?, ?, ?, ?, ?, ?)`,
+		t.Name, t.Version, t.Body, t.Path, t.Model, string(allowed))
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	id, _ := res.LastInsertId()
+	return s.getTemplate(`WHERE id = ?`, id)
+}
+
+// GetTemplate returns a specific version of a template, or the latest when version is 0.
+func (s *Store) GetTemplate(name string, version int) (*PromptTemplate, error) {
+	if version == 0 {
+		return s.getTemplate(`WHERE name = ? ORDER BY version DESC LIMIT 1`, name)
+	}
+	return s.getTemplate(`WHERE name = ? AND version = ?`, name, version)
+}
+
+// ListTemplates returns the latest version of every template.
+func (s *Store) ListTemplates() ([]PromptTemplate, error) {
+	rows, err := s.db.Query(`SELECT id, name, version, body, path, model, allowed_users, created_at FROM prompt_templates t
+	          WHERE version = (SELECT MAX(version) FROM prompt_templates WHERE name = t.name) ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	templates := []PromptTemplate{}
+	for rows.Next() {
+		t, err := scanTemplate(rows)
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, *t)
+	}
+	return templates, rows.Err()
+}
+
+func (s *Store) getTemplate(where string, args ...interface{}) (*PromptTemplate, error) {
+	row := s.db.QueryRow(`SELECT id, name, version, body, path, model, allowed_users, created_at FROM prompt_templates `+where, args...)
+	t, err := scanTemplate(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return t, err
+}
+
+func scanTemplate(row rowScanner) (*PromptTemplate, error) {
+	var t PromptTemplate
+	var model, allowed sql.NullString
+	if err := row.Scan(&t.ID, &t.Name, &t.Version, &t.Body, &t.Path, &model, &allowed, &t.CreatedAt); err != nil {
+		return nil, err
+	}
+	t.Model = model.String
+	if allowed.Valid && allowed.String != "" {
+		if err := json.Unmarshal([]byte(allowed.String), &t.AllowedUsers); err != nil {
+			return nil, err
+		}
+	}
+	return &t, nil
+}
+
+// TemplateSplit is the share of traffic routed to one version of a template.
+type TemplateSplit struct {
+	Version int `json:"version"`
+	Weight  int `json:"weight"`
+}
+
+// TemplateVersionStats summarizes how one template version has performed.
+type TemplateVersionStats struct {
+	Version       int     `json:"version"`
+	Weight        int     `json:"weight"`
+	Requests      int     `json:"requests"`
+	Errors        int     `json:"errors"`
+	AvgLatencyMs  float64 `json:"avg_latency_ms"`
+	AvgTokens     float64 `json:"avg_tokens"`
+	FeedbackCount int     `json:"feedback_count"`
+	AvgFeedback   float64 `json:"avg_feedback"`
+}
+
+func (s *Store) initTemplateExperimentSchema() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS template_splits (
+		name TEXT NOT NULL,
+		version INTEGER NOT NULL,
+		weight INTEGER NOT NULL,
+		PRIMARY KEY(name, version)
+	);
+	CREATE TABLE IF NOT EXISTS template_promotions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		version INTEGER NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE TABLE IF NOT EXISTS template_feedback (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		version INTEGER NOT NULL,
+		score REAL NOT NULL,
+		user_id TEXT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`
+	_, err := s.db.Exec(query)
+	return err
+}
+
+// GetTemplateSplits returns the active versions of a template and their weights.
+func (s *Store) GetTemplateSplits(name string) ([]TemplateSplit, error) {
+	rows, err := s.db.Query(`SELECT version, weight FROM template_splits WHERE name = ? AND weight > 0 ORDER BY version`, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	splits := []TemplateSplit{}
+	for rows.Next() {
+		var sp TemplateSplit
+		if err := rows.Scan(&sp.Version, &sp.Weight); err != nil {
+			return nil, err
+		}
+		splits = append(splits, sp)
+	}
+	return splits, rows.Err()
+}
+
+// SetTemplateSplits replaces the traffic split for a template.
+func (s *Store) SetTemplateSplits(name string, splits []TemplateSplit) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := setSplitsTx(tx, name, splits); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// PromoteTemplate sends all traffic to one version and records the promotion.
+func (s *Store) PromoteTemplate(name string, version int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := setSplitsTx(tx, name, []TemplateSplit{{Version: version, Weight: 100}}); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`INSERT INTO template_promotions (name, version) VALUES (?, ?)`, name, version); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RollbackTemplate undoes the latest promotion and returns the version now serving all traffic.
+func (s *Store) RollbackTemplate(name string) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`SELECT id, version FROM template_promotions WHERE name = ? ORDER BY id DESC LIMIT 2`, name)
+	if err != nil {
+		return 0, err
+	}
+	var ids, versions []int
+	for rows.Next() {
+		var id, version int
+		if err := rows.Scan(&id, &version); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+		versions = append(versions, version)
+	}
+	rows.Close()
+	if len(ids) < 2 {
+		return 0, ErrNotFound
+	}
+
+	if _, err := tx.Exec(`DELETE FROM template_promotions WHERE id = ?`, ids[0]); err != nil {
+		return 0, err
+	}
+	if err := setSplitsTx(tx, name, []TemplateSplit{{Version: versions[1], Weight: 100}}); err != nil {
+		return 0, err
+	}
+	return versions[1], tx.Commit()
+}
+
+func setSplitsTx(tx *sql.Tx, name string, splits []TemplateSplit) error {
+	for _, sp := range splits {
+		var exists int
+		err := tx.QueryRow(`SELECT COUNT(*) FROM prompt_templates WHERE name = ? AND version = ?`, name, sp.Version).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if exists == 0 {
+			return ErrNotFound
+		}
+	}
+
+	if _, err := tx.Exec(`DELETE FROM template_splits WHERE name = ?`, name); err != nil {
+		return err
+	}
+	for _, sp := range splits {
+		if _, err := tx.Exec(`INSERT INTO template_splits (name, version, weight) VALUES (?, ?, ?)`, name, sp.Version, sp.Weight); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddTemplateFeedback records a client's score for a template version.
+func (s *Store) AddTemplateFeedback(name string, version int, score float64, userID string) error {
+	_, err := s.db.Exec(`INSERT INTO template_feedback (name, version, score, user_id) VALUES (?, ?, ?, ?)`, name, version, score, userID)
+	return err
+}
+
+// TemplateStats returns per-version latency, token and feedback aggregates for a template.
+func (s *Store) TemplateStats(name string) ([]TemplateVersionStats, error) {
+	query := `
+	SELECT t.version,
+	       COALESCE(sp.weight, 0),
+	       COUNT(l.id),
+	       COALESCE(SUM(CASE WHEN l.status_code >= 400 THEN 1 ELSE 0 END), 0),
+	       COALESCE(AVG(l.latency_ms), 0),
+	       COALESCE(AVG(l.token_count), 0),
+	       (SELECT COUNT(*) FROM template_feedback f WHERE f.name = t.name AND f.version = t.version),
+	       (SELECT COALESCE(AVG(score), 0) FROM template_feedback f WHERE f.name = t.name AND f.version = t.version)
+	FROM prompt_templates t
+	LEFT JOIN template_splits sp ON sp.name = t.name AND sp.version = t.version
+	LEFT JOIN interaction_logs l ON l.template = t.name || '@' || t.version
+	WHERE t.name = ?
+	GROUP BY t.version
+	ORDER BY t.version`
+	rows, err := s.db.Query(query, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats := []TemplateVersionStats{}
+	for rows.Next() {
+		var st TemplateVersionStats
+		if err := rows.Scan(&st.Version, &st.Weight, &st.Requests, &st.Errors, &st.AvgLatencyMs, &st.AvgTokens, &st.FeedbackCount, &st.AvgFeedback); err != nil {
+			return nil, err
+		}
+		stats = append(stats, st)
+	}
+	return stats, rows.Err()
+}
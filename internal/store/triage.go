@@ -0,0 +1,177 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Triage statuses of flagged interactions.
+const (
+	TriageNew           = "new"
+	TriageAcknowledged  = "acknowledged"
+	TriageResolved      = "resolved"
+	TriageFalsePositive = "false-positive"
+)
+
+// TriageStatuses are the statuses a reviewer can set.
+var TriageStatuses = []string{TriageNew, TriageAcknowledged, TriageResolved, TriageFalsePositive}
+
+// Triage is the review state of a flagged interaction: one that was
+// blocked, had a low safety score or got an unsafe response. Severity is
+// info, warning or critical, and Reasons say why it was flagged.
+type Triage struct {
+	LogID      int64      `json:"log_id"`
+	FlaggedAt  time.Time  `json:"flagged_at"`
+	UserID     string     `json:"user_id"`
+	Path       string     `json:"path"`
+	Severity   string     `json:"severity"`
+	Reasons    []string   `json:"reasons"`
+	Status     string     `json:"status"`
+	ReviewedBy string     `json:"reviewed_by,omitempty"`
+	ReviewedAt *time.Time `json:"reviewed_at,omitempty"`
+	Note       string     `json:"note,omitempty"`
+}
+
+// TriageFilter narrows ListTriage results. Status "open" matches new and
+// acknowledged interactions.
+type TriageFilter struct {
+	Status   string
+	Severity string
+	User     string
+	Limit    int
+}
+
+func (s *Store) initTriageSchema() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS triage (
+		log_id INTEGER PRIMARY KEY,
+		flagged_at DATETIME NOT NULL,
+		user_id TEXT NOT NULL,
+		path TEXT NOT NULL,
+		severity TEXT NOT NULL,
+		reasons TEXT NOT NULL,
+		status TEXT NOT NULL,
+		reviewed_by TEXT,
+		reviewed_at DATETIME,
+		note TEXT
+	);
+	CREATE INDEX IF NOT EXISTS idx_triage_status ON triage(status, flagged_at);`
+	_, err := s.db.Exec(query)
+	return err
+}
+
+// FlagInteraction queues an interaction for triage with status new. An
+// interaction already queued is left as it is.
+func (s *Store) FlagInteraction(t Triage) error {
+	_, err := s.db.Exec(`INSERT OR IGNORE INTO triage (log_id, flagged_at, user_id, path, severity, reasons, status) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		t.LogID, t.FlaggedAt.UTC().Format(sqliteTimeLayout), t.UserID, t.Path, t.Severity, strings.Join(t.Reasons, ","), TriageNew)
+	return err
+}
+
+// SetTriageStatus records a reviewer's status, and optionally a new
+// severity and a note, on a flagged interaction. It returns ErrNotFound
+// for interactions that were not flagged.
+func (s *Store) SetTriageStatus(logID int64, status, severity, reviewer, note string) (*Triage, error) {
+	res, err := s.db.Exec(`UPDATE triage SET status = ?, severity = COALESCE(NULLIF(?, ''), severity), reviewed_by = ?, reviewed_at = ?, note = COALESCE(NULLIF(?, ''), note) WHERE log_id = ?`,
+		status, severity, nullString(reviewer), time.Now().UTC().Format(sqliteTimeLayout), note, logID)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, ErrNotFound
+	}
+	return s.GetTriage(logID)
+}
+
+const triageColumns = `log_id, flagged_at, user_id, path, severity, reasons, status, COALESCE(reviewed_by, ''), reviewed_at, COALESCE(note, '')`
+
+func scanTriage(row interface{ Scan(...interface{}) error }) (*Triage, error) {
+	var t Triage
+	var flagged, reasons string
+	var reviewed sql.NullString
+	if err := row.Scan(&t.LogID, &flagged, &t.UserID, &t.Path, &t.Severity, &reasons, &t.Status, &t.ReviewedBy, &reviewed, &t.Note); err != nil {
+		return nil, err
+	}
+	t.FlaggedAt = parseTimestamp(flagged)
+	t.Reasons = strings.Split(reasons, ",")
+	if reviewed.Valid {
+		at := parseTimestamp(reviewed.String)
+		t.ReviewedAt = &at
+	}
+	return &t, nil
+}
+
+// GetTriage returns the triage state of an interaction, or ErrNotFound
+// when it was not flagged.
+func (s *Store) GetTriage(logID int64) (*Triage, error) {
+	t, err := scanTriage(s.db.QueryRow(`SELECT `+triageColumns+` FROM triage WHERE log_id = ?`, logID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return t, err
+}
+
+// ListTriage returns flagged interactions, most severe first and newest
+// first within a severity.
+func (s *Store) ListTriage(f TriageFilter) ([]Triage, error) {
+	query := `SELECT ` + triageColumns + ` FROM triage WHERE 1 = 1`
+	var args []interface{}
+	switch f.Status {
+	case "":
+	case "open":
+		query += ` AND status IN (?, ?)`
+		args = append(args, TriageNew, TriageAcknowledged)
+	default:
+		query += ` AND status = ?`
+		args = append(args, f.Status)
+	}
+	if f.Severity != "" {
+		query += ` AND severity = ?`
+		args = append(args, f.Severity)
+	}
+	if f.User != "" {
+		query += ` AND user_id = ?`
+		args = append(args, f.User)
+	}
+	query += ` ORDER BY CASE severity WHEN 'critical' THEN 0 WHEN 'warning' THEN 1 ELSE 2 END, flagged_at DESC, log_id DESC LIMIT ?`
+	rows, err := s.db.Query(query, append(args, f.Limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	queue := []Triage{}
+	for rows.Next() {
+		t, err := scanTriage(rows)
+		if err != nil {
+			return nil, err
+		}
+		queue = append(queue, *t)
+	}
+	return queue, rows.Err()
+}
+
+// TriageCounts counts the interactions flagged in [from, to) by severity
+// and by status.
+func (s *Store) TriageCounts(from, to time.Time) (bySeverity, byStatus map[string]int, err error) {
+	rows, err := s.db.Query(`SELECT severity, status, COUNT(*) FROM triage WHERE flagged_at >= ? AND flagged_at < ? GROUP BY severity, status`,
+		from.UTC().Format(sqliteTimeLayout), to.UTC().Format(sqliteTimeLayout))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	bySeverity, byStatus = map[string]int{}, map[string]int{}
+	for rows.Next() {
+		var severity, status string
+		var n int
+		if err := rows.Scan(&severity, &status, &n); err != nil {
+			return nil, nil, err
+		}
+		bySeverity[severity] += n
+		byStatus[status] += n
+	}
+	return bySeverity, byStatus, rows.Err()
+}
@@ -0,0 +1,134 @@
+package store
+
+import "time"
+
+// TrustHistory is a user's recent behaviour as the trust score sees it.
+// AvgSafety averages the safety scores of the requests that were
+// classified, and is 1 when none were.
+type TrustHistory struct {
+	UserID    string
+	Requests  int
+	Blocked   int
+	AvgSafety float64
+	// Feedback is the sum of operator feedback scores in the window
+	Feedback float64
+}
+
+// TrustScore is a user's computed trust as stored and reported by /api/trust.
+type TrustScore struct {
+	UserID    string    `json:"user_id"`
+	Score     float64   `json:"score"`
+	Tier      string    `json:"tier"`
+	Requests  int       `json:"requests"`
+	BlockRate float64   `json:"block_rate"`
+	AvgSafety float64   `json:"avg_safety"`
+	Feedback  float64   `json:"feedback"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (s *Store) initTrustSchema() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS user_trust (
+		user_id TEXT PRIMARY KEY,
+		score REAL NOT NULL,
+		tier TEXT NOT NULL,
+		requests INTEGER NOT NULL DEFAULT 0,
+		block_rate REAL NOT NULL DEFAULT 0,
+		avg_safety REAL NOT NULL DEFAULT 1,
+		feedback REAL NOT NULL DEFAULT 0,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE TABLE IF NOT EXISTS trust_feedback (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id TEXT NOT NULL,
+		score REAL NOT NULL,
+		note TEXT,
+		author TEXT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_trust_feedback_user ON trust_feedback(user_id, created_at);`
+	_, err := s.db.Exec(query)
+	return err
+}
+
+// AddTrustFeedback records an operator's judgement of a user, from -1
+// (abusive) to 1 (vouched for).
+func (s *Store) AddTrustFeedback(userID string, score float64, note, author string) error {
+	_, err := s.db.Exec(`INSERT INTO trust_feedback (user_id, score, note, author) VALUES (?, ?, ?, ?)`, userID, score, note, author)
+	return err
+}
+
+// TrustHistorySince summarizes each user's interactions and feedback from
+// since onwards. Users with feedback but no requests are included.
+func (s *Store) TrustHistorySince(since time.Time) ([]TrustHistory, error) {
+	from := since.UTC().Format(sqliteTimeLayout)
+	rows, err := s.db.Query(`
+		SELECT user_id, SUM(requests), SUM(blocked), COALESCE(SUM(safety) / NULLIF(SUM(scored), 0), 1), SUM(feedback)
+		FROM (
+			SELECT user_id, COUNT(*) AS requests, SUM(is_blocked) AS blocked, SUM(safety_score) AS safety, COUNT(safety_score) AS scored, 0 AS feedback
+			FROM interaction_logs WHERE timestamp >= ? AND user_id IS NOT NULL AND user_id != '' GROUP BY user_id
+			UNION ALL
+			SELECT user_id, 0, 0, 0, 0, SUM(score)
+			FROM trust_feedback WHERE created_at >= ? GROUP BY user_id
+		)
+		GROUP BY user_id`, from, from)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var history []TrustHistory
+	for rows.Next() {
+		var h TrustHistory
+		if err := rows.Scan(&h.UserID, &h.Requests, &h.Blocked, &h.AvgSafety, &h.Feedback); err != nil {
+			return nil, err
+		}
+		history = append(history, h)
+	}
+	return history, rows.Err()
+}
+
+// SaveTrustScores replaces the stored scores with scores.
+func (s *Store) SaveTrustScores(scores []TrustScore) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM user_trust`); err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare(`INSERT INTO user_trust (user_id, score, tier, requests, block_rate, avg_safety, feedback, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, t := range scores {
+		if _, err := stmt.Exec(t.UserID, t.Score, t.Tier, t.Requests, t.BlockRate, t.AvgSafety, t.Feedback, t.UpdatedAt.UTC().Format(sqliteTimeLayout)); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// ListTrustScores returns the stored scores, lowest first.
+func (s *Store) ListTrustScores() ([]TrustScore, error) {
+	rows, err := s.db.Query(`SELECT user_id, score, tier, requests, block_rate, avg_safety, feedback, updated_at FROM user_trust ORDER BY score, user_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	scores := []TrustScore{}
+	for rows.Next() {
+		var t TrustScore
+		var updated string
+		if err := rows.Scan(&t.UserID, &t.Score, &t.Tier, &t.Requests, &t.BlockRate, &t.AvgSafety, &t.Feedback, &updated); err != nil {
+			return nil, err
+		}
+		t.UpdatedAt = parseTimestamp(updated)
+		scores = append(scores, t)
+	}
+	return scores, rows.Err()
+}
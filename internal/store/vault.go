@@ -0,0 +1,33 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+)
+
+func (s *Store) initVaultSchema() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS pii_vault (
+		token TEXT PRIMARY KEY,
+		ciphertext BLOB NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`
+	_, err := s.db.Exec(query)
+	return err
+}
+
+// PutVaultEntry stores the encrypted value for a token. Existing tokens are left untouched.
+func (s *Store) PutVaultEntry(token string, ciphertext []byte) error {
+	_, err := s.db.Exec(`INSERT OR IGNORE INTO pii_vault (token, ciphertext) VALUES (?, ?)`, token, ciphertext)
+	return err
+}
+
+// GetVaultEntry returns the encrypted value stored for a token.
+func (s *Store) GetVaultEntry(token string) ([]byte, error) {
+	var ciphertext []byte
+	err := s.db.QueryRow(`SELECT ciphertext FROM pii_vault WHERE token = ?`, token).Scan(&ciphertext)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return ciphertext, err
+}
@@ -0,0 +1,73 @@
+package store
+
+import (
+	"time"
+)
+
+type WebhookDelivery struct {
+	ID           int64     `json:"id"`
+	EventID      string    `json:"event_id"`
+	EventType    string    `json:"event_type"`
+	Endpoint     string    `json:"endpoint"`
+	Status       string    `json:"status"`
+	Attempts     int       `json:"attempts"`
+	ResponseCode int       `json:"response_code"`
+	LastError    string    `json:"last_error,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+func (s *Store) initWebhookSchema() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS webhook_deliveries (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		event_id TEXT NOT NULL,
+		event_type TEXT NOT NULL,
+		endpoint TEXT NOT NULL,
+		status TEXT NOT NULL,
+		attempts INTEGER DEFAULT 0,
+		response_code INTEGER DEFAULT 0,
+		last_error TEXT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`
+	_, err := s.db.Exec(query)
+	return err
+}
+
+// CreateDelivery records a pending delivery of an event to an endpoint.
+func (s *Store) CreateDelivery(eventID, eventType, endpoint string) (int64, error) {
+	res, err := s.db.Exec(`INSERT INTO webhook_deliveries (event_id, event_type, endpoint, status) VALUES (?, ?, ?, 'pending')`,
+		eventID, eventType, endpoint)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// UpdateDelivery records the outcome of a delivery attempt.
+func (s *Store) UpdateDelivery(id int64, status string, attempts, responseCode int, lastError string) error {
+	_, err := s.db.Exec(`UPDATE webhook_deliveries SET status = ?, attempts = ?, response_code = ?, last_error = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
+		status, attempts, responseCode, nullString(lastError), id)
+	return err
+}
+
+// ListDeliveries returns the most recent webhook deliveries.
+func (s *Store) ListDeliveries(limit int) ([]WebhookDelivery, error) {
+	rows, err := s.db.Query(`SELECT id, event_id, event_type, endpoint, status, attempts, response_code, COALESCE(last_error, ''), created_at, updated_at
+	          FROM webhook_deliveries ORDER BY id DESC LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []WebhookDelivery{}
+	for rows.Next() {
+		var d WebhookDelivery
+		if err := rows.Scan(&d.ID, &d.EventID, &d.EventType, &d.Endpoint, &d.Status, &d.Attempts, &d.ResponseCode, &d.LastError, &d.CreatedAt, &d.UpdatedAt); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
@@ -0,0 +1,72 @@
+package telemetry
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// The default histogram bounds suit LLM calls, which take seconds and use
+// thousands of tokens, where Prometheus' defaults stop at 10s.
+var (
+	DefaultLatencyBuckets = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 20, 30, 60, 120}
+	DefaultTokenBuckets   = []float64{16, 64, 256, 512, 1024, 2048, 4096, 8192, 16384, 32768, 65536, 131072}
+)
+
+func httpRequestDurationOpts(buckets []float64) prometheus.HistogramOpts {
+	return prometheus.HistogramOpts{
+		Name:    "vantage_http_request_duration_seconds",
+		Help:    "Duration of HTTP requests in seconds.",
+		Buckets: buckets,
+	}
+}
+
+func upstreamLatencyOpts(buckets []float64) prometheus.HistogramOpts {
+	return prometheus.HistogramOpts{
+		Name:    "vantage_upstream_latency_seconds",
+		Help:    "Time to response headers of successful attempts per pooled upstream.",
+		Buckets: buckets,
+	}
+}
+
+func requestTokensOpts(buckets []float64) prometheus.HistogramOpts {
+	return prometheus.HistogramOpts{
+		Name:    "vantage_request_tokens",
+		Help:    "Billed tokens per request, by model, endpoint and direction (input or output).",
+		Buckets: buckets,
+	}
+}
+
+// exemplars is whether ObserveDuration attaches trace IDs.
+var exemplars = true
+
+// ConfigureHistograms replaces the latency and token histograms with ones
+// using the given bounds, empty keeping the defaults, and turns trace ID
+// exemplars on or off. It must be called before anything is observed.
+func ConfigureHistograms(latency, tokens []float64, withExemplars bool) {
+	exemplars = withExemplars
+	if len(latency) > 0 {
+		prometheus.Unregister(HttpRequestDuration)
+		HttpRequestDuration = prometheus.NewHistogramVec(httpRequestDurationOpts(latency), []string{"method", "path"})
+		prometheus.MustRegister(HttpRequestDuration)
+		prometheus.Unregister(UpstreamLatency)
+		UpstreamLatency = prometheus.NewHistogramVec(upstreamLatencyOpts(latency), []string{"upstream"})
+		prometheus.MustRegister(UpstreamLatency)
+	}
+	if len(tokens) > 0 {
+		prometheus.Unregister(RequestTokens)
+		RequestTokens = prometheus.NewHistogramVec(requestTokensOpts(tokens), []string{"model", "endpoint", "direction"})
+		prometheus.MustRegister(RequestTokens)
+	}
+}
+
+// ObserveDuration records d in seconds, with traceID as an exemplar when
+// there is one and exemplars are on, so Grafana can link the bucket to the
+// trace.
+func ObserveDuration(o prometheus.Observer, d time.Duration, traceID string) {
+	if e, ok := o.(prometheus.ExemplarObserver); ok && exemplars && traceID != "" {
+		e.ObserveWithExemplar(d.Seconds(), prometheus.Labels{"trace_id": traceID})
+		return
+	}
+	o.Observe(d.Seconds())
+}
@@ -0,0 +1,37 @@
+package telemetry
+
+import "sync"
+
+// OtherLabel is the label value of everything past a LabelLimiter's limit.
+const OtherLabel = "other"
+
+// LabelLimiter caps the distinct values of a metric label, so values that
+// come from clients, such as model names and callers, cannot grow a metric
+// without bound. The first max values pass through and later ones are
+// reported as OtherLabel; a max of zero lets every value through.
+type LabelLimiter struct {
+	max  int
+	mu   sync.Mutex
+	seen map[string]bool
+}
+
+func NewLabelLimiter(max int) *LabelLimiter {
+	return &LabelLimiter{max: max, seen: map[string]bool{}}
+}
+
+// Value returns the label value to record for v.
+func (l *LabelLimiter) Value(v string) string {
+	if l.max <= 0 {
+		return v
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.seen[v] {
+		return v
+	}
+	if len(l.seen) >= l.max {
+		return OtherLabel
+	}
+	l.seen[v] = true
+	return v
+}
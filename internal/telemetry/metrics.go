@@ -14,20 +14,271 @@ var (
 		[]string{"method", "path", "status"},
 	)
 
-	HttpRequestDuration = promauto.NewHistogramVec(
-		prometheus.HistogramOpts{
-			Name:    "vantage_http_request_duration_seconds",
-			Help:    "Duration of HTTP requests in seconds.",
-			Buckets: prometheus.DefBuckets,
-		},
-		[]string{"method", "path"},
-	)
+	HttpRequestDuration = promauto.NewHistogramVec(httpRequestDurationOpts(DefaultLatencyBuckets), []string{"method", "path"})
+
+	RequestTokens = promauto.NewHistogramVec(requestTokensOpts(DefaultTokenBuckets), []string{"model", "endpoint", "direction"})
 
 	TokenUsageTotal = promauto.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "vantage_token_usage_total",
-			Help: "Total number of tokens consumed.",
+			Help: "Total number of tokens consumed, by provider, model and endpoint.",
+		},
+		[]string{"provider", "model", "endpoint"},
+	)
+
+	TokenCostTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "vantage_token_cost_total",
+			Help: "Estimated spend on tokens from the configured pricing, by provider, model and endpoint.",
+		},
+		[]string{"provider", "model", "endpoint"},
+	)
+
+	SearchUnitsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "vantage_search_units_total",
+			Help: "Total number of search units billed by rerank endpoints.",
+		},
+		[]string{"model", "endpoint"},
+	)
+
+	ProviderStatus = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "vantage_provider_status",
+			Help: "Provider status page indicator (0=none, 1=minor, 2=major, 3=critical).",
+		},
+		[]string{"provider"},
+	)
+
+	TemplateInvocationsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "vantage_template_invocations_total",
+			Help: "Total number of prompt template invocations by version.",
+		},
+		[]string{"template", "version"},
+	)
+
+	CacheRequestsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "vantage_cache_requests_total",
+			Help: "Total number of cacheable requests by result (hit, miss, bypass).",
+		},
+		[]string{"path", "result"},
+	)
+
+	CacheTokensSavedTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "vantage_cache_tokens_saved_total",
+			Help: "Total number of tokens served from the response cache instead of upstream.",
+		},
+		[]string{"model", "endpoint"},
+	)
+
+	ModerationFlaggedTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "vantage_moderation_flagged_total",
+			Help: "Total number of prompts flagged by moderation, by harm category.",
+		},
+		[]string{"category"},
+	)
+
+	ResponsePIITotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "vantage_response_pii_total",
+			Help: "Total number of PII values found in generated text, by type and the action taken (redact, block, flag).",
+		},
+		[]string{"pii_type", "action"},
+	)
+
+	AuditOverflowTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "vantage_audit_overflow_total",
+			Help: "Total number of audit records dropped, and of requests refused in strict mode, because the audit queue was full.",
+		},
+		[]string{"outcome"},
+	)
+
+	DedupRequestsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "vantage_dedup_requests_total",
+			Help: "Total number of duplicate requests collapsed by the deduplication window, by result (shared, rejected).",
+		},
+		[]string{"path", "result"},
+	)
+
+	UserRequestsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "vantage_user_requests_total",
+			Help: "Total number of proxied requests per resolved caller or team.",
+		},
+		[]string{"user", "status"},
+	)
+
+	UserTokenUsageTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "vantage_user_token_usage_total",
+			Help: "Total number of tokens consumed per resolved caller or team.",
+		},
+		[]string{"user", "model", "endpoint"},
+	)
+
+	UserTokenCostTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "vantage_user_token_cost_total",
+			Help: "Estimated spend on tokens per resolved caller or team.",
+		},
+		[]string{"user", "model", "endpoint"},
+	)
+
+	EstimatedTokensTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "vantage_estimated_tokens_total",
+			Help: "Total number of tokens counted locally for responses that reported no usage, by route.",
+		},
+		[]string{"route"},
+	)
+
+	BlockedRequestsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "vantage_blocked_requests_total",
+			Help: "Total number of requests rejected by a governance stage, by path and error code.",
+		},
+		[]string{"path", "code"},
+	)
+
+	RedactedRequestsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "vantage_redacted_requests_total",
+			Help: "Total number of requests forwarded with PII redacted, by path.",
+		},
+		[]string{"path"},
+	)
+
+	RedactionsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "vantage_redactions_total",
+			Help: "Total number of PII values redacted from prompts, by pattern.",
+		},
+		[]string{"pattern"},
+	)
+
+	LatencySLORequestsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "vantage_latency_slo_requests_total",
+			Help: "Total number of requests counted against each latency SLO, by whether they met the threshold.",
+		},
+		[]string{"slo", "result"},
+	)
+
+	LatencySLOBurnRate = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "vantage_latency_slo_burn_rate",
+			Help: "Rate at which each latency SLO spends its error budget over the configured window; 1 spends it exactly.",
+		},
+		[]string{"slo"},
+	)
+
+	IPDeniedTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "vantage_ip_denied_total",
+			Help: "Total number of requests rejected by the IP access lists, by route group.",
+		},
+		[]string{"scope"},
+	)
+
+	DatabaseSizeBytes = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "vantage_database_size_bytes",
+			Help: "Size of the database file after the last maintenance run.",
+		},
+	)
+
+	MaintenanceReclaimedBytesTotal = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "vantage_maintenance_reclaimed_bytes_total",
+			Help: "Total bytes returned to the filesystem by database maintenance.",
+		},
+	)
+
+	MaintenanceRunsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "vantage_maintenance_runs_total",
+			Help: "Total number of database maintenance tasks run, by task and outcome.",
+		},
+		[]string{"task", "status"},
+	)
+
+	UpstreamRequestsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "vantage_upstream_requests_total",
+			Help: "Total number of attempts sent to each pooled upstream, by response status.",
+		},
+		[]string{"upstream", "status"},
+	)
+
+	UpstreamLatency = promauto.NewHistogramVec(upstreamLatencyOpts(DefaultLatencyBuckets), []string{"upstream"})
+
+	UpstreamHealthy = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "vantage_upstream_healthy",
+			Help: "Whether a pooled upstream is in rotation (1) or cooling down after repeated failures (0).",
+		},
+		[]string{"upstream"},
+	)
+
+	StoreWriteRetriesTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "vantage_store_write_retries_total",
+			Help: "Total number of retried interaction and usage writes, by kind.",
+		},
+		[]string{"kind"},
+	)
+
+	DeadLettersTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "vantage_dead_letters_total",
+			Help: "Total number of interaction and usage writes given up on after their retries, by kind.",
+		},
+		[]string{"kind"},
+	)
+
+	RedisErrorsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "vantage_redis_errors_total",
+			Help: "Total number of failed Redis operations, by use (rate_limit, quota or cache).",
+		},
+		[]string{"use"},
+	)
+
+	ClickHouseErrorsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "vantage_clickhouse_errors_total",
+			Help: "Total number of failed ClickHouse operations (insert, stats or delete) and of rows dropped while it was unreachable (dropped).",
+		},
+		[]string{"op"},
+	)
+
+	SecretRefreshErrorsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "vantage_secret_refresh_errors_total",
+			Help: "Total number of failed secret refreshes, by secret name or renew for the secret store token.",
+		},
+		[]string{"secret"},
+	)
+
+	UpstreamConnectionsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "vantage_upstream_connections_total",
+			Help: "Total number of upstream requests by host and whether they reused an idle connection.",
+		},
+		[]string{"host", "reused"},
+	)
+
+	ChaosFaultsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "vantage_chaos_faults_total",
+			Help: "Total number of faults injected into upstream calls in chaos mode, by fault: latency, rate_limit or error.",
 		},
-		[]string{"model"},
+		[]string{"fault"},
 	)
 )
@@ -0,0 +1,185 @@
+// Package trust scores users from their recent history so policies can
+// treat established, well-behaved users differently from risky ones.
+package trust
+
+import (
+	"context"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/soroushbar/vantage/internal/config"
+	"github.com/soroushbar/vantage/internal/store"
+	"github.com/soroushbar/vantage/pkg/middleware"
+)
+
+// Trust tiers.
+const (
+	Low      = "low"
+	Standard = "standard"
+	High     = "high"
+)
+
+// feedbackWeight is how far one unit of operator feedback moves a score.
+const feedbackWeight = 0.1
+
+// Store interface for decoupling
+type Store interface {
+	TrustHistorySince(since time.Time) ([]store.TrustHistory, error)
+	SaveTrustScores(scores []store.TrustScore) error
+	ListTrustScores() ([]store.TrustScore, error)
+	AddTrustFeedback(userID string, score float64, note, author string) error
+}
+
+// Scorer recomputes trust scores periodically and answers tier lookups
+// from memory.
+type Scorer struct {
+	store Store
+	cfg   config.TrustConfig
+
+	mu     sync.RWMutex
+	scores map[string]store.TrustScore
+}
+
+// NewScorer returns a scorer seeded with the last stored scores, so tiers
+// survive a restart until the first recompute.
+func NewScorer(st Store, cfg config.TrustConfig) (*Scorer, error) {
+	s := &Scorer{store: st, cfg: cfg, scores: map[string]store.TrustScore{}}
+	saved, err := st.ListTrustScores()
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range saved {
+		s.scores[t.UserID] = t
+	}
+	return s, nil
+}
+
+// Start recomputes the scores now and then every configured interval.
+func (s *Scorer) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(s.cfg.Interval)
+		defer ticker.Stop()
+		for {
+			if err := s.Recompute(); err != nil {
+				log.Printf("Trust scoring failed: %v", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Recompute scores every user with history in the window and stores the
+// result. Users without recent history drop out and are treated as
+// standard.
+func (s *Scorer) Recompute() error {
+	now := time.Now()
+	history, err := s.store.TrustHistorySince(now.Add(-s.cfg.Window))
+	if err != nil {
+		return err
+	}
+
+	scores := make([]store.TrustScore, 0, len(history))
+	byUser := make(map[string]store.TrustScore, len(history))
+	for _, h := range history {
+		t := s.score(h)
+		t.UpdatedAt = now
+		scores = append(scores, t)
+		byUser[h.UserID] = t
+	}
+	if err := s.store.SaveTrustScores(scores); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.scores = byUser
+	s.mu.Unlock()
+	return nil
+}
+
+// score combines a user's history into a score in [0, 1]: the mean of the
+// share of unblocked requests and the average safety score, shifted by
+// operator feedback.
+func (s *Scorer) score(h store.TrustHistory) store.TrustScore {
+	t := store.TrustScore{UserID: h.UserID, Requests: h.Requests, AvgSafety: h.AvgSafety, Feedback: h.Feedback}
+	if h.Requests > 0 {
+		t.BlockRate = float64(h.Blocked) / float64(h.Requests)
+	}
+	score := 0.5*(1-t.BlockRate) + 0.5*h.AvgSafety + feedbackWeight*h.Feedback
+	t.Score = math.Max(0, math.Min(1, score))
+
+	switch {
+	case h.Requests < s.cfg.MinRequests && h.Feedback == 0:
+		t.Tier = Standard
+	case t.Score < s.cfg.LowBelow:
+		t.Tier = Low
+	case t.Score >= s.cfg.HighAbove:
+		t.Tier = High
+	default:
+		t.Tier = Standard
+	}
+	return t
+}
+
+// TrustTier returns the user's current tier; unscored users are standard.
+func (s *Scorer) TrustTier(userID string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if t, ok := s.scores[userID]; ok {
+		return t.Tier
+	}
+	return Standard
+}
+
+// Score returns the user's current score, if they have one.
+func (s *Scorer) Score(userID string) (store.TrustScore, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	t, ok := s.scores[userID]
+	return t, ok
+}
+
+// Scores lists every scored user, lowest score first.
+func (s *Scorer) Scores() ([]store.TrustScore, error) {
+	return s.store.ListTrustScores()
+}
+
+// AddFeedback records operator feedback about a user and rescores
+// immediately so it takes effect without waiting for the next interval.
+func (s *Scorer) AddFeedback(userID string, score float64, note, author string) error {
+	if err := s.store.AddTrustFeedback(userID, score, note, author); err != nil {
+		return err
+	}
+	return s.Recompute()
+}
+
+// PlanCatalog resolves users' regular plans and looks plans up by name.
+type PlanCatalog interface {
+	middleware.PlanResolver
+	Plan(name string) (middleware.Plan, bool)
+}
+
+// Plans returns a resolver that gives users the plan configured for their
+// trust tier, and their regular plan when the tier has none.
+func (s *Scorer) Plans(catalog PlanCatalog) middleware.PlanResolver {
+	return tierPlans{scorer: s, catalog: catalog}
+}
+
+type tierPlans struct {
+	scorer  *Scorer
+	catalog PlanCatalog
+}
+
+func (t tierPlans) PlanFor(userID string) (middleware.Plan, bool) {
+	if name := t.scorer.cfg.Tiers[t.scorer.TrustTier(userID)].Plan; name != "" {
+		if p, ok := t.catalog.Plan(name); ok {
+			return p, true
+		}
+	}
+	return t.catalog.PlanFor(userID)
+}
@@ -0,0 +1,97 @@
+package upstream
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/soroushbar/vantage/internal/config"
+	"github.com/soroushbar/vantage/internal/telemetry"
+)
+
+// ChaosHeader is set on responses made up by the chaos transport, naming
+// the fault.
+const ChaosHeader = "X-Vantage-Chaos"
+
+// Chaos is an http.RoundTripper that injects the faults of a ChaosConfig
+// into the calls it forwards to next. Under a Pool it sits below the
+// failover, so injected 429s and 5xx count against the target like real
+// ones.
+type Chaos struct {
+	cfg  config.ChaosConfig
+	next http.RoundTripper
+}
+
+// NewChaos wraps next, or http.DefaultTransport when next is nil.
+func NewChaos(cfg config.ChaosConfig, next http.RoundTripper) *Chaos {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &Chaos{cfg: cfg, next: next}
+}
+
+func (c *Chaos) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !c.applies(req.URL.Path) {
+		return c.next.RoundTrip(req)
+	}
+	if c.cfg.Latency > 0 && rand.Float64() < c.cfg.LatencyRate {
+		telemetry.ChaosFaultsTotal.WithLabelValues("latency").Inc()
+		select {
+		case <-time.After(c.cfg.Latency):
+		case <-req.Context().Done():
+			if req.Body != nil {
+				req.Body.Close()
+			}
+			return nil, req.Context().Err()
+		}
+	}
+	roll := rand.Float64()
+	switch {
+	case roll < c.cfg.RateLimitRate:
+		telemetry.ChaosFaultsTotal.WithLabelValues("rate_limit").Inc()
+		resp := c.fault(req, http.StatusTooManyRequests, "rate_limit")
+		resp.Header.Set("Retry-After", strconv.Itoa(int(c.cfg.RetryAfter.Round(time.Second).Seconds())))
+		return resp, nil
+	case roll < c.cfg.RateLimitRate+c.cfg.ErrorRate:
+		telemetry.ChaosFaultsTotal.WithLabelValues("error").Inc()
+		return c.fault(req, c.cfg.ErrorStatus, "error"), nil
+	}
+	return c.next.RoundTrip(req)
+}
+
+// applies reports whether path is under one of the configured prefixes, or
+// whether there are none.
+func (c *Chaos) applies(path string) bool {
+	if len(c.cfg.Paths) == 0 {
+		return true
+	}
+	for _, prefix := range c.cfg.Paths {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// fault answers req with status without forwarding it.
+func (c *Chaos) fault(req *http.Request, status int, kind string) *http.Response {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	body := fmt.Sprintf(`{"message":"fault injected by vantage chaos mode: %d %s"}`, status, http.StatusText(status))
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"application/json"}, ChaosHeader: {kind}},
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
@@ -0,0 +1,267 @@
+// Package upstream holds the HTTP transport to the providers and spreads
+// proxied traffic over several API keys or regional endpoints of one
+// provider, failing over between them.
+package upstream
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/soroushbar/vantage/internal/config"
+	"github.com/soroushbar/vantage/internal/telemetry"
+	"github.com/soroushbar/vantage/pkg/middleware"
+)
+
+// Load-balancing strategies.
+const (
+	RoundRobin   = "round_robin"
+	LeastLatency = "least_latency"
+	Weighted     = "weighted"
+)
+
+// StatusWeigher scales a target's weight by the status of the provider it
+// follows, e.g. provider.StatusMonitor.RoutingWeight.
+type StatusWeigher interface {
+	RoutingWeight(provider string, weight float64) float64
+}
+
+// defaultStatusPage is the status page of targets that name none.
+const defaultStatusPage = "cohere"
+
+// target is one upstream with its health state.
+type target struct {
+	name       string
+	baseURL    *url.URL
+	apiKey     string
+	weight     float64
+	statusPage string
+
+	mu           sync.Mutex
+	failures     int
+	downUntil    time.Time
+	latencyEWMA  time.Duration
+	latencyKnown bool
+}
+
+// Pool is an http.RoundTripper that sends each request to one of its
+// targets, retrying on the next target when one fails with a transport
+// error, 429 or 5xx. A target that fails FailureThreshold times in a row is
+// skipped for Cooldown.
+type Pool struct {
+	targets   []*target
+	strategy  string
+	threshold int
+	cooldown  time.Duration
+	next      http.RoundTripper
+	status    StatusWeigher
+
+	mu      sync.Mutex
+	counter int
+}
+
+// NewPool builds a pool from cfg that forwards through next, or
+// http.DefaultTransport when next is nil.
+func NewPool(cfg config.UpstreamsConfig, next http.RoundTripper) (*Pool, error) {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	p := &Pool{strategy: cfg.Strategy, threshold: cfg.FailureThreshold, cooldown: cfg.Cooldown, next: next}
+	for _, t := range cfg.Targets {
+		u, err := url.Parse(t.BaseURL)
+		if err != nil {
+			return nil, fmt.Errorf("upstream %s: %w", t.Name, err)
+		}
+		key := t.APIKey
+		if t.APIKeyEnv != "" {
+			key = os.Getenv(t.APIKeyEnv)
+		}
+		weight := t.Weight
+		if weight <= 0 {
+			weight = 1
+		}
+		page := t.StatusPage
+		if page == "" {
+			page = defaultStatusPage
+		}
+		p.targets = append(p.targets, &target{name: t.Name, baseURL: u, apiKey: key, weight: weight, statusPage: page})
+		telemetry.UpstreamHealthy.WithLabelValues(t.Name).Set(1)
+	}
+	if len(p.targets) == 0 {
+		return nil, errors.New("no upstream targets configured")
+	}
+	return p, nil
+}
+
+// SetStatus lets provider incidents shift traffic away from the targets
+// that follow the affected provider.
+func (p *Pool) SetStatus(s StatusWeigher) {
+	p.status = s
+}
+
+// RoundTrip sends req to the targets in strategy order until one answers
+// without a retryable failure. The last failure is returned when all do.
+func (p *Pool) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+	}
+
+	order := p.order()
+	var resp *http.Response
+	var err error
+	for i, t := range order {
+		out := req.Clone(req.Context())
+		out.URL.Scheme = t.baseURL.Scheme
+		out.URL.Host = t.baseURL.Host
+		out.Host = t.baseURL.Host
+		if t.apiKey != "" {
+			out.Header.Set("Authorization", "Bearer "+t.apiKey)
+		}
+		out.Body = io.NopCloser(bytes.NewReader(body))
+		out.ContentLength = int64(len(body))
+
+		start := time.Now()
+		resp, err = p.next.RoundTrip(out)
+		status := "error"
+		if err == nil {
+			status = strconv.Itoa(resp.StatusCode)
+		}
+		telemetry.UpstreamRequestsTotal.WithLabelValues(t.name, status).Inc()
+
+		if err == nil && resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < 500 {
+			p.succeeded(t, time.Since(start), middleware.TraceID(req.Header))
+			return resp, nil
+		}
+		p.failed(t)
+		if i == len(order)-1 || req.Context().Err() != nil {
+			break
+		}
+		if err == nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+	}
+	return resp, err
+}
+
+// order lists the healthy targets with the strategy's pick first, followed
+// by the targets in cooldown as a last resort.
+func (p *Pool) order() []*target {
+	now := time.Now()
+	var healthy, down []*target
+	for _, t := range p.targets {
+		t.mu.Lock()
+		up := !now.Before(t.downUntil)
+		t.mu.Unlock()
+		if up {
+			healthy = append(healthy, t)
+		} else {
+			down = append(down, t)
+		}
+	}
+	if len(healthy) == 0 {
+		return down
+	}
+
+	weights := make(map[*target]float64, len(healthy))
+	for _, t := range healthy {
+		weights[t] = p.weight(t)
+	}
+	switch p.strategy {
+	case LeastLatency:
+		sort.SliceStable(healthy, func(i, j int) bool { return latency(healthy[i]) < latency(healthy[j]) })
+	case Weighted:
+		return append(weightedOrder(healthy, weights), down...)
+	default:
+		p.mu.Lock()
+		n := p.counter % len(healthy)
+		p.counter++
+		p.mu.Unlock()
+		healthy = append(healthy[n:], healthy[:n]...)
+	}
+	// Without weights to scale, targets the status monitor weighs down go last
+	shifted := func(t *target) bool { return weights[t] < t.weight }
+	sort.SliceStable(healthy, func(i, j int) bool { return !shifted(healthy[i]) && shifted(healthy[j]) })
+	return append(healthy, down...)
+}
+
+// weight returns t's weight as scaled by the status of its provider.
+func (p *Pool) weight(t *target) float64 {
+	if p.status == nil {
+		return t.weight
+	}
+	return p.status.RoutingWeight(t.statusPage, t.weight)
+}
+
+// weightedOrder draws targets without replacement in proportion to their
+// weights.
+func weightedOrder(targets []*target, weights map[*target]float64) []*target {
+	remaining := append([]*target(nil), targets...)
+	ordered := make([]*target, 0, len(targets))
+	for len(remaining) > 0 {
+		total := 0.0
+		for _, t := range remaining {
+			total += weights[t]
+		}
+		n := rand.Float64() * total
+		i := 0
+		for ; i < len(remaining)-1; i++ {
+			if n < weights[remaining[i]] {
+				break
+			}
+			n -= weights[remaining[i]]
+		}
+		ordered = append(ordered, remaining[i])
+		remaining = append(remaining[:i], remaining[i+1:]...)
+	}
+	return ordered
+}
+
+// latency returns the target's moving-average latency; untried targets sort
+// first so they get measured.
+func latency(t *target) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.latencyKnown {
+		return 0
+	}
+	return t.latencyEWMA
+}
+
+func (p *Pool) succeeded(t *target, elapsed time.Duration, traceID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.failures = 0
+	t.downUntil = time.Time{}
+	if t.latencyKnown {
+		t.latencyEWMA = (4*t.latencyEWMA + elapsed) / 5
+	} else {
+		t.latencyEWMA, t.latencyKnown = elapsed, true
+	}
+	telemetry.UpstreamHealthy.WithLabelValues(t.name).Set(1)
+	telemetry.ObserveDuration(telemetry.UpstreamLatency.WithLabelValues(t.name), elapsed, traceID)
+}
+
+func (p *Pool) failed(t *target) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.failures++
+	if t.failures >= p.threshold {
+		t.downUntil = time.Now().Add(p.cooldown)
+		telemetry.UpstreamHealthy.WithLabelValues(t.name).Set(0)
+	}
+}
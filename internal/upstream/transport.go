@@ -0,0 +1,56 @@
+package upstream
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"time"
+
+	"github.com/soroushbar/vantage/internal/config"
+	"github.com/soroushbar/vantage/internal/telemetry"
+)
+
+// NewTransport builds the transport shared by every upstream provider.
+// Connections it opens and reuses are counted per host, so that churn shows
+// up in vantage_upstream_connections_total.
+func NewTransport(cfg config.TransportConfig) http.RoundTripper {
+	dialer := &net.Dialer{Timeout: cfg.DialTimeout, KeepAlive: cfg.KeepAlive}
+	t := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		MaxIdleConns:          cfg.MaxIdleConns,
+		MaxIdleConnsPerHost:   cfg.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       cfg.MaxConnsPerHost,
+		IdleConnTimeout:       cfg.IdleConnTimeout,
+		TLSHandshakeTimeout:   cfg.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: cfg.ResponseHeaderTimeout,
+		ExpectContinueTimeout: time.Second,
+		TLSClientConfig:       &tls.Config{},
+		ForceAttemptHTTP2:     cfg.HTTP2,
+	}
+	if cfg.TLSSessionCache > 0 {
+		t.TLSClientConfig.ClientSessionCache = tls.NewLRUClientSessionCache(cfg.TLSSessionCache)
+	}
+	if !cfg.HTTP2 {
+		// A non-nil empty map is how net/http is told not to upgrade
+		t.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+	return &countingTransport{next: t}
+}
+
+// countingTransport records whether each request got a new connection.
+type countingTransport struct {
+	next http.RoundTripper
+}
+
+func (c *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Host
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			telemetry.UpstreamConnectionsTotal.WithLabelValues(host, strconv.FormatBool(info.Reused)).Inc()
+		},
+	}
+	return c.next.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+}
@@ -0,0 +1,103 @@
+package vault
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+)
+
+// Store interface for decoupling
+type Store interface {
+	PutVaultEntry(token string, ciphertext []byte) error
+	GetVaultEntry(token string) ([]byte, error)
+}
+
+// Vault maps PII values to deterministic tokens and keeps the originals
+// encrypted with AES-GCM in the store.
+type Vault struct {
+	store  Store
+	macKey []byte
+	aead   cipher.AEAD
+
+	cache sync.Map // token -> plaintext
+}
+
+// New derives separate tokenization and encryption keys from secret.
+func New(st Store, secret string) (*Vault, error) {
+	if secret == "" {
+		return nil, errors.New("vault secret is required")
+	}
+	encKey := sha256.Sum256([]byte("vantage-vault-enc:" + secret))
+	macKey := sha256.Sum256([]byte("vantage-vault-mac:" + secret))
+
+	block, err := aes.NewCipher(encKey[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Vault{store: st, macKey: macKey[:], aead: aead}, nil
+}
+
+// Tokenize returns the token for value, persisting the encrypted original on first sight.
+func (v *Vault) Tokenize(kind, value string) (string, error) {
+	token := v.token(kind, value)
+	if _, ok := v.cache.Load(token); ok {
+		return token, nil
+	}
+
+	nonce := make([]byte, v.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	ciphertext := v.aead.Seal(nonce, nonce, []byte(value), []byte(token))
+	if err := v.store.PutVaultEntry(token, ciphertext); err != nil {
+		return "", fmt.Errorf("failed to store vault entry: %w", err)
+	}
+	v.cache.Store(token, value)
+	return token, nil
+}
+
+// Detokenize returns the original value for a token.
+func (v *Vault) Detokenize(token string) (string, bool) {
+	if value, ok := v.cache.Load(token); ok {
+		return value.(string), true
+	}
+
+	ciphertext, err := v.store.GetVaultEntry(token)
+	if err != nil {
+		return "", false
+	}
+	size := v.aead.NonceSize()
+	if len(ciphertext) < size {
+		return "", false
+	}
+	plaintext, err := v.aead.Open(nil, ciphertext[:size], ciphertext[size:], []byte(token))
+	if err != nil {
+		log.Printf("Vault entry %s failed to decrypt: %v", token, err)
+		return "", false
+	}
+	v.cache.Store(token, string(plaintext))
+	return string(plaintext), true
+}
+
+// token encodes an HMAC of the value using only the letters a-p.
+func (v *Vault) token(kind, value string) string {
+	mac := hmac.New(sha256.New, v.macKey)
+	mac.Write([]byte(kind + ":" + value))
+	sum := mac.Sum(nil)
+
+	out := make([]byte, 16)
+	for i := range out {
+		out[i] = 'a' + sum[i]&0x0f
+	}
+	return "[PII_" + kind + "_" + string(out) + "]"
+}
@@ -2,22 +2,111 @@ package middleware
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"io"
 	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
 )
 
+// auditSignals collects what the pipeline stages decide about a request
+// for AuditMiddleware. The middleware puts it in the request context as a
+// pointer, so stages fill it in through any context derived from that one
+// and nothing has to travel in response headers, which reach the client
+// once written.
+type auditSignals struct {
+	blocked        bool
+	redacted       bool
+	redactions     string
+	budgetExceeded string
+	truncation     string
+	transformation string
+	verdict        string
+	// responseSafety is set when the response was classified before it
+	// was returned
+	responseSafety *float64
+	// responsePII is the ResponsePIIReport of a response PII was found in
+	responsePII string
+	// paramOverrides is the ParamOverrides of the caller's parameter policy
+	paramOverrides string
+	// moderation is the ModerationReport of the prompt
+	moderation string
+}
+
+type auditSignalsKey struct{}
+
+// signals returns the audit signals of r. Outside AuditMiddleware they are
+// a fresh set nobody reads, so stages can record into them unconditionally.
+func signals(r *http.Request) *auditSignals {
+	if s, ok := r.Context().Value(auditSignalsKey{}).(*auditSignals); ok {
+		return s
+	}
+	return &auditSignals{}
+}
+
+var traceparentRegex = regexp.MustCompile(`^[0-9a-f]{2}-([0-9a-f]{32})-[0-9a-f]{16}-[0-9a-f]{2}`)
+
+// TraceID returns the trace ID of a W3C traceparent header, or "" when
+// there is none or it is invalid.
+func TraceID(h http.Header) string {
+	m := traceparentRegex.FindStringSubmatch(strings.TrimSpace(h.Get("traceparent")))
+	if m == nil || strings.Trim(m[1], "0") == "" {
+		return ""
+	}
+	return m[1]
+}
+
+// markBlocked records that a stage rejected the request on policy.
+func markBlocked(r *http.Request) {
+	signals(r).blocked = true
+}
+
+// AuditBackpressure is what AuditMiddleware does while the audit queue is
+// full. A record that does not fit waits up to Wait for room and is then
+// dropped. Strict also refuses new requests with 503 and a Retry-After of
+// RetryAfter while the queue is full, so that nothing is forwarded that is
+// likely to go unaudited. OnOverflow, when set, is called with "dropped"
+// for every dropped record and "rejected" for every refused request.
+type AuditBackpressure struct {
+	Strict     bool
+	Wait       time.Duration
+	RetryAfter time.Duration
+	OnOverflow func(outcome string)
+}
+
+func (b AuditBackpressure) overflow(outcome string) {
+	if b.OnOverflow != nil {
+		b.OnOverflow(outcome)
+	}
+}
+
 // AuditMiddleware captures request and response data and sends it to a channel for async processing.
-func AuditMiddleware(auditChan chan<- Interaction) func(http.Handler) http.Handler {
+// Headers selected by capture are recorded alongside the bodies; bp decides
+// what happens while the channel is full.
+func AuditMiddleware(auditChan chan<- Interaction, capture HeaderCapture, bp AuditBackpressure) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if bp.Strict && auditChan != nil && len(auditChan) >= cap(auditChan) {
+				bp.overflow("rejected")
+				w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(bp.RetryAfter)))
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusServiceUnavailable)
+				json.NewEncoder(w).Encode(map[string]string{
+					"error": "The audit log is saturated; retry later",
+					"code":  "AUDIT_UNAVAILABLE",
+				})
+				return
+			}
 			start := time.Now()
 
 			// Extract User ID
-			userID := r.Header.Get("X-User-ID")
-			if userID == "" {
-				userID = "anonymous"
-			}
+			userID := UserID(r)
+
+			// Client metadata; malformed values are rejected by MetadataMiddleware
+			metadata, _ := ParseMetadata(r.Header.Get(MetadataHeader))
 
 			// Capture Request Body
 			var reqBody []byte
@@ -25,6 +114,13 @@ func AuditMiddleware(auditChan chan<- Interaction) func(http.Handler) http.Handl
 				reqBody, _ = io.ReadAll(r.Body)
 				r.Body = io.NopCloser(bytes.NewBuffer(reqBody))
 			}
+			conversation := ConversationID(r)
+			session := r.Header.Get(SessionHeader)
+			if session == "" {
+				session = conversation
+			}
+			project := Project(r)
+			restrictAcceptEncoding(r.Header)
 
 			// Wrap ResponseWriter
 			rw := &responseWriterWrapper{
@@ -33,34 +129,71 @@ func AuditMiddleware(auditChan chan<- Interaction) func(http.Handler) http.Handl
 				statusCode:     http.StatusOK,
 			}
 
+			sig := &auditSignals{}
+			r = r.WithContext(context.WithValue(r.Context(), auditSignalsKey{}, sig))
 			next.ServeHTTP(rw, r)
 
-			// Capture metadata from context if added by other middlewares
-			isRedacted, _ := r.Context().Value("is_redacted").(bool)
+			template, _ := r.Context().Value(TemplateKey).(string)
+			headers := capture.record(r.Header, rw.Header())
 
-			// Check for blocked header from Governance
-			isBlocked := rw.Header().Get("X-Vantage-Blocked") == "true"
-			if isBlocked {
-				rw.Header().Del("X-Vantage-Blocked") // Clean up
+			var deprecation string
+			if rw.Header().Get("Deprecation") != "" {
+				deprecation = rw.Header().Get("Warning")
 			}
 
 			interaction := Interaction{
-				Timestamp:    start,
-				UserID:       userID,
-				Method:       r.Method,
-				Path:         r.URL.Path,
-				RequestBody:  reqBody,
-				ResponseBody: rw.body.Bytes(),
-				StatusCode:   rw.statusCode,
-				Duration:     time.Since(start),
-				IsBlocked:    isBlocked,
-				IsRedacted:   isRedacted,
+				Timestamp:      start,
+				UserID:         userID,
+				Method:         r.Method,
+				Path:           r.URL.Path,
+				RequestBody:    reqBody,
+				ResponseBody:   decodeContent(rw.Header().Get("Content-Encoding"), rw.body.Bytes()),
+				StatusCode:     rw.statusCode,
+				Duration:       time.Since(start),
+				IsBlocked:      sig.blocked,
+				IsRedacted:     sig.redacted,
+				TraceID:        TraceID(r.Header),
+				Redactions:     sig.redactions,
+				Template:       template,
+				CacheStatus:    rw.Header().Get("X-Vantage-Cache"),
+				Dedup:          rw.Header().Get(DedupHeader),
+				Deprecation:    deprecation,
+				Metadata:       metadata,
+				RoutedModel:    rw.Header().Get("X-Vantage-Routed-Model"),
+				Conversation:   conversation,
+				Session:        session,
+				Project:        project,
+				BudgetExceeded: sig.budgetExceeded,
+				Truncation:     sig.truncation,
+				Transformation: sig.transformation,
+				TrustTier:      rw.Header().Get(TrustTierHeader),
+				Verdict:        sig.verdict,
+				ResponseSafety: sig.responseSafety,
+				ResponsePII:    sig.responsePII,
+				ParamOverrides: sig.paramOverrides,
+				Moderation:     sig.moderation,
+				RequestID:      r.Header.Get(RequestIDHeader),
+				Headers:        headers,
 			}
 
 			select {
 			case auditChan <- interaction:
+				return
 			default:
 			}
+			if auditChan == nil {
+				return
+			}
+			if bp.Wait > 0 {
+				timer := time.NewTimer(bp.Wait)
+				defer timer.Stop()
+				select {
+				case auditChan <- interaction:
+					return
+				case <-timer.C:
+				}
+			}
+			bp.overflow("dropped")
 		})
 	}
 }
@@ -80,3 +213,9 @@ func (rw *responseWriterWrapper) Write(b []byte) (int, error) {
 	rw.body.Write(b)
 	return rw.ResponseWriter.Write(b)
 }
+
+// Unwrap lets http.ResponseController flush streamed responses through the
+// capture as they arrive.
+func (rw *responseWriterWrapper) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// peekBody reads the request body and restores it for the next handler.
+func peekBody(r *http.Request) []byte {
+	if r.Body == nil {
+		return nil
+	}
+	body, _ := io.ReadAll(r.Body)
+	r.Body = io.NopCloser(bytes.NewBuffer(body))
+	return body
+}
+
+// requestModel returns the "model" field of a JSON request body, if any.
+func requestModel(r *http.Request) string {
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+		return ""
+	}
+	var req struct {
+		Model string `json:"model"`
+	}
+	if json.Unmarshal(peekBody(r), &req) != nil {
+		return ""
+	}
+	return req.Model
+}
@@ -0,0 +1,166 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CachedResponse is an upstream response stored for replay.
+type CachedResponse struct {
+	StatusCode  int
+	ContentType string
+	Body        []byte
+}
+
+// ResponseCache stores upstream responses by request fingerprint.
+type ResponseCache interface {
+	Get(key string) (*CachedResponse, bool)
+	Set(key string, resp *CachedResponse, ttl time.Duration)
+}
+
+type cacheEntry struct {
+	resp    *CachedResponse
+	expires time.Time
+}
+
+// MemoryCache is an in-process ResponseCache bounded by entry count.
+type MemoryCache struct {
+	maxEntries int
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+func NewMemoryCache(maxEntries int) *MemoryCache {
+	return &MemoryCache{maxEntries: maxEntries, entries: make(map[string]cacheEntry)}
+}
+
+func (c *MemoryCache) Get(key string) (*CachedResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(e.expires) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return e.resp, true
+}
+
+func (c *MemoryCache) Set(key string, resp *CachedResponse, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if len(c.entries) >= c.maxEntries {
+		// Drop expired entries first, then the one closest to expiry
+		var oldestKey string
+		var oldest time.Time
+		for k, e := range c.entries {
+			if now.After(e.expires) {
+				delete(c.entries, k)
+				continue
+			}
+			if oldestKey == "" || e.expires.Before(oldest) {
+				oldestKey, oldest = k, e.expires
+			}
+		}
+		if len(c.entries) >= c.maxEntries {
+			delete(c.entries, oldestKey)
+		}
+	}
+	c.entries[key] = cacheEntry{resp: resp, expires: now.Add(ttl)}
+}
+
+// CacheOptions controls which requests are cached and for how long.
+type CacheOptions struct {
+	TTL     time.Duration
+	Paths   []string
+	PerUser bool
+}
+
+// CacheMiddleware serves repeat prompts from cache. Clients can skip the
+// cache with "Cache-Control: no-cache" or "X-Vantage-Cache: bypass"; every
+// response carries X-Vantage-Cache set to HIT, MISS or BYPASS.
+func CacheMiddleware(cache ResponseCache, opts CacheOptions) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost || !contains(opts.Paths, strings.TrimSuffix(r.URL.Path, "/")) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if r.Header.Get("Cache-Control") == "no-cache" || strings.EqualFold(r.Header.Get("X-Vantage-Cache"), "bypass") {
+				w.Header().Set("X-Vantage-Cache", "BYPASS")
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			body := peekBody(r)
+			if isStreaming(body) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			scope := ""
+			if opts.PerUser {
+				scope = UserID(r)
+			}
+			key := cacheKey(scope, r.URL.Path, body)
+
+			// 1. Serve from cache
+			if cached, ok := cache.Get(key); ok {
+				w.Header().Set("Content-Type", cached.ContentType)
+				w.Header().Set("X-Vantage-Cache", "HIT")
+				w.WriteHeader(cached.StatusCode)
+				w.Write(cached.Body)
+				return
+			}
+
+			// 2. Forward and remember successful responses
+			w.Header().Set("X-Vantage-Cache", "MISS")
+			// Ask for an uncompressed body so it can be replayed as-is
+			r.Header.Del("Accept-Encoding")
+			rw := &responseWriterWrapper{ResponseWriter: w, body: &bytes.Buffer{}, statusCode: http.StatusOK}
+			next.ServeHTTP(rw, r)
+
+			if rw.statusCode == http.StatusOK {
+				cache.Set(key, &CachedResponse{
+					StatusCode:  rw.statusCode,
+					ContentType: w.Header().Get("Content-Type"),
+					Body:        rw.body.Bytes(),
+				}, opts.TTL)
+			}
+		})
+	}
+}
+
+// cacheKey fingerprints the request. JSON bodies are re-encoded so key order
+// and whitespace don't cause misses.
+func cacheKey(scope, path string, body []byte) string {
+	var parsed interface{}
+	if err := json.Unmarshal(body, &parsed); err == nil {
+		if normalized, err := json.Marshal(parsed); err == nil {
+			body = normalized
+		}
+	}
+	h := sha256.New()
+	h.Write([]byte(scope + "\x00" + path + "\x00"))
+	h.Write(bytes.TrimSpace(body))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func isStreaming(body []byte) bool {
+	var req struct {
+		Stream bool `json:"stream"`
+	}
+	return json.Unmarshal(body, &req) == nil && req.Stream
+}
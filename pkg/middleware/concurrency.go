@@ -0,0 +1,158 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ConcurrencyLimiter caps the requests in flight for each key and across
+// all keys, so that one caller's batch job cannot take every upstream
+// connection from interactive callers. A request over a cap waits up to the
+// queue timeout for a slot to free up.
+type ConcurrencyLimiter struct {
+	perKey       int
+	global       int
+	overrides    map[string]int
+	queueTimeout time.Duration
+
+	mu       sync.Mutex
+	inFlight map[string]int
+	total    int
+	// released is closed, and replaced, whenever a slot frees up
+	released chan struct{}
+	// hold is a moving average of how long requests keep their slot
+	hold time.Duration
+}
+
+// NewConcurrencyLimiter caps each key at perKey requests, or at its entry
+// in overrides, and all keys together at global. A zero cap is no cap.
+func NewConcurrencyLimiter(perKey, global int, overrides map[string]int, queueTimeout time.Duration) *ConcurrencyLimiter {
+	return &ConcurrencyLimiter{
+		perKey:       perKey,
+		global:       global,
+		overrides:    overrides,
+		queueTimeout: queueTimeout,
+		inFlight:     map[string]int{},
+		released:     make(chan struct{}),
+	}
+}
+
+// Acquire takes a slot for key, waiting for one until the queue timeout or
+// ctx ends. It returns the function that gives the slot back, or the cap
+// that was hit, "user" or "global", when no slot became free.
+func (l *ConcurrencyLimiter) Acquire(ctx context.Context, key string) (release func(), limited string) {
+	var timeout <-chan time.Time
+	if l.queueTimeout > 0 {
+		timer := time.NewTimer(l.queueTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	for {
+		l.mu.Lock()
+		limited = l.full(key)
+		if limited == "" {
+			l.inFlight[key]++
+			l.total++
+			l.mu.Unlock()
+			start := time.Now()
+			return func() { l.release(key, time.Since(start)) }, ""
+		}
+		released := l.released
+		l.mu.Unlock()
+
+		if timeout == nil {
+			return nil, limited
+		}
+		select {
+		case <-released:
+		case <-timeout:
+			return nil, limited
+		case <-ctx.Done():
+			return nil, limited
+		}
+	}
+}
+
+// full names the cap key is at, if any. l.mu must be held.
+func (l *ConcurrencyLimiter) full(key string) string {
+	if limit := l.keyLimit(key); limit > 0 && l.inFlight[key] >= limit {
+		return "user"
+	}
+	if l.global > 0 && l.total >= l.global {
+		return "global"
+	}
+	return ""
+}
+
+func (l *ConcurrencyLimiter) keyLimit(key string) int {
+	if n, ok := l.overrides[key]; ok {
+		return n
+	}
+	return l.perKey
+}
+
+// State describes a request for key rejected at the limited cap as a
+// limiter state: no slots remain, and one is expected to free up once a
+// request has held its slot for as long as requests usually do. That
+// estimate is both the reset and the window.
+func (l *ConcurrencyLimiter) State(key, limited string) RateLimitState {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	limit := l.global
+	if limited == "user" {
+		limit = l.keyLimit(key)
+	}
+	reset := l.hold
+	if reset < time.Second {
+		reset = time.Second
+	}
+	return RateLimitState{Limit: limit, Reset: reset, Window: reset}
+}
+
+func (l *ConcurrencyLimiter) release(key string, held time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.hold == 0 {
+		l.hold = held
+	} else {
+		l.hold += (held - l.hold) / 8
+	}
+	if l.inFlight[key]--; l.inFlight[key] <= 0 {
+		delete(l.inFlight, key)
+	}
+	l.total--
+	close(l.released)
+	l.released = make(chan struct{})
+}
+
+// ConcurrencyMiddleware holds a slot of the limiter for each user while
+// their request is in flight, including the whole of a streamed response,
+// and rejects requests that find no free slot with 429, code
+// CONCURRENCY_LIMITED and the RateLimit-* headers and Retry-After of the
+// limiter's State.
+func ConcurrencyMiddleware(limiter *ConcurrencyLimiter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			release, limited := limiter.Acquire(r.Context(), UserID(r))
+			if release == nil {
+				message := "Too many requests in flight for this user"
+				if limited == "global" {
+					message = "Too many requests in flight"
+				}
+				SetRateLimitHeaders(w.Header(), limiter.State(UserID(r), limited))
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusTooManyRequests)
+				json.NewEncoder(w).Encode(map[string]string{
+					"error": message,
+					"code":  "CONCURRENCY_LIMITED",
+				})
+				return
+			}
+			defer release()
+			next.ServeHTTP(w, r)
+		})
+	}
+}
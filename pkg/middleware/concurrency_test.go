@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestConcurrencyMiddlewareRateLimitHeaders(t *testing.T) {
+	limiter := NewConcurrencyLimiter(1, 0, map[string]int{"bob": 3}, 10*time.Millisecond)
+	release, _ := limiter.Acquire(context.Background(), "alice")
+	defer release()
+
+	handler := ConcurrencyMiddleware(limiter)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("request over the cap reached the handler")
+	}))
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat", nil)
+	req = req.WithContext(WithUserID(req.Context(), "alice"))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want 429", rec.Code)
+	}
+	for header, want := range map[string]string{
+		"RateLimit-Limit":     "1",
+		"RateLimit-Remaining": "0",
+		"RateLimit-Reset":     "1",
+		"RateLimit-Policy":    "1;w=1",
+		"Retry-After":         "1",
+	} {
+		if got := rec.Header().Get(header); got != want {
+			t.Errorf("%s = %q, want %q", header, got, want)
+		}
+	}
+}
+
+func TestConcurrencyLimiterStateUsesHoldTime(t *testing.T) {
+	limiter := NewConcurrencyLimiter(1, 5, map[string]int{"bob": 3}, 0)
+	limiter.release("bob", 4*time.Second)
+
+	if s := limiter.State("bob", "user"); s.Limit != 3 || s.Remaining != 0 || s.Reset != 4*time.Second {
+		t.Errorf("user state = %+v, want limit 3, remaining 0, reset 4s", s)
+	}
+	if s := limiter.State("bob", "global"); s.Limit != 5 {
+		t.Errorf("global limit = %d, want 5", s.Limit)
+	}
+}
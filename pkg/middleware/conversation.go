@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ConversationHeader identifies the conversation a request belongs to. The
+// body's "conversation_id" field is used when the header is absent.
+const ConversationHeader = "X-Vantage-Conversation"
+
+// SessionHeader groups interactions into a session for review. Requests
+// without it are grouped by their conversation, if any.
+const SessionHeader = "X-Session-ID"
+
+// ConversationUsage reports the tokens a user's conversation has consumed.
+type ConversationUsage interface {
+	ConversationTokens(userID, conversationID string) (int, error)
+}
+
+// ConversationBudget caps the cumulative tokens of one conversation.
+// Requests are flagged with a Warning header once WarnRatio of MaxTokens is
+// used, and rejected once MaxTokens is reached.
+type ConversationBudget struct {
+	MaxTokens int
+	WarnRatio float64
+}
+
+// ConversationID returns the conversation a request belongs to, if any.
+func ConversationID(r *http.Request) string {
+	if id := r.Header.Get(ConversationHeader); id != "" {
+		return id
+	}
+	if r.Method != http.MethodPost || strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+		return ""
+	}
+	var req struct {
+		ConversationID string `json:"conversation_id"`
+	}
+	if json.Unmarshal(peekBody(r), &req) != nil {
+		return ""
+	}
+	return req.ConversationID
+}
+
+// ConversationBudgetMiddleware enforces the per-conversation token budget.
+// Usage is recorded by the audit worker once responses are parsed, so the
+// turn that crosses the budget completes and later turns are rejected.
+func ConversationBudgetMiddleware(usage ConversationUsage, budget ConversationBudget) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := ConversationID(r)
+			if id == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			used, err := usage.ConversationTokens(UserID(r), id)
+			if err != nil {
+				// Fail open; the budget is a cost control, not a security boundary
+				next.ServeHTTP(w, r)
+				return
+			}
+			w.Header().Set("X-Vantage-Conversation-Tokens", strconv.Itoa(used)+"/"+strconv.Itoa(budget.MaxTokens))
+
+			if used >= budget.MaxTokens {
+				sig := signals(r)
+				sig.blocked = true
+				sig.budgetExceeded = "conversation"
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusForbidden)
+				json.NewEncoder(w).Encode(map[string]string{
+					"error": "Conversation token budget exhausted; start a new conversation",
+					"code":  "CONVERSATION_BUDGET_EXCEEDED",
+				})
+				return
+			}
+			if budget.WarnRatio > 0 && float64(used) >= budget.WarnRatio*float64(budget.MaxTokens) {
+				w.Header().Add("Warning", fmt.Sprintf(`299 vantage "conversation has used %d of %d tokens"`, used, budget.MaxTokens))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
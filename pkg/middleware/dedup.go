@@ -0,0 +1,183 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DedupHeader tells the client, and AuditMiddleware, that a request was a
+// duplicate: SHARED when it was answered with the original's response,
+// REJECTED when it was refused.
+const DedupHeader = "X-Vantage-Dedup"
+
+// DedupOptions controls which requests are deduplicated and how.
+type DedupOptions struct {
+	Window time.Duration
+	// Mode is "share" to answer duplicates with the original's response,
+	// or "reject" to refuse them with 409
+	Mode  string
+	Paths []string
+}
+
+type dedupEntry struct {
+	// done is closed when the original completes; resp stays nil if it
+	// failed or its caller went away first
+	done    chan struct{}
+	resp    *CachedResponse
+	expires time.Time
+}
+
+// Deduplicator collapses identical requests from the same user, such as a
+// prompt submitted twice by a double click or a client retrying too eagerly.
+// A request is a duplicate of one with the same body and path that is still
+// in flight or that started less than the window ago.
+type Deduplicator struct {
+	opts DedupOptions
+
+	mu      sync.Mutex
+	entries map[string]*dedupEntry
+}
+
+func NewDeduplicator(opts DedupOptions) *Deduplicator {
+	return &Deduplicator{opts: opts, entries: make(map[string]*dedupEntry)}
+}
+
+// claim returns the entry key is a duplicate of, or registers a new one
+// for the caller to fill in and reports that it is the original.
+func (d *Deduplicator) claim(key string) (e *dedupEntry, original bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if e, ok := d.entries[key]; ok {
+		return e, false
+	}
+	e = &dedupEntry{done: make(chan struct{}), expires: time.Now().Add(d.opts.Window)}
+	d.entries[key] = e
+	return e, true
+}
+
+// finish hands resp to the duplicates waiting on e and forgets e once its
+// window has passed, straight away when resp is nil so that the next
+// identical request is treated as an original.
+func (d *Deduplicator) finish(key string, e *dedupEntry, resp *CachedResponse) {
+	d.mu.Lock()
+	e.resp = resp
+	remaining := time.Until(e.expires)
+	if resp == nil || remaining <= 0 {
+		delete(d.entries, key)
+	}
+	d.mu.Unlock()
+	close(e.done)
+
+	if resp != nil && remaining > 0 {
+		time.AfterFunc(remaining, func() {
+			d.mu.Lock()
+			if d.entries[key] == e {
+				delete(d.entries, key)
+			}
+			d.mu.Unlock()
+		})
+	}
+}
+
+// DedupMiddleware answers duplicate POSTs to the configured paths with the
+// original request's response, once it completes, or refuses them with 409
+// and the seconds left in the window as Retry-After. Duplicates carry
+// DedupHeader. Only successful (2xx) originals are shared or remembered:
+// when the original fails, waiting duplicates go upstream themselves and
+// the next identical request is not refused.
+func DedupMiddleware(d *Deduplicator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost || !contains(d.opts.Paths, strings.TrimSuffix(r.URL.Path, "/")) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			key := cacheKey(UserID(r), r.URL.Path, peekBody(r))
+
+			e, original := d.claim(key)
+			if !original {
+				if d.opts.Mode == "reject" {
+					retryAfter := int(time.Until(e.expires).Seconds()) + 1
+					if retryAfter < 1 {
+						retryAfter = 1
+					}
+					w.Header().Set(DedupHeader, "REJECTED")
+					w.Header().Set("Content-Type", "application/json")
+					w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+					w.WriteHeader(http.StatusConflict)
+					json.NewEncoder(w).Encode(map[string]string{
+						"error": "an identical request is already being answered",
+						"code":  "DUPLICATE_REQUEST",
+					})
+					return
+				}
+				select {
+				case <-e.done:
+				case <-r.Context().Done():
+					return
+				}
+				if e.resp == nil {
+					// The original failed or was abandoned, so this one goes upstream itself
+					next.ServeHTTP(w, r)
+					return
+				}
+				w.Header().Set(DedupHeader, "SHARED")
+				w.Header().Set("Content-Type", e.resp.ContentType)
+				w.WriteHeader(e.resp.StatusCode)
+				w.Write(e.resp.Body)
+				return
+			}
+
+			if d.opts.Mode == "reject" {
+				// Duplicates never see the response, so it need not be kept
+				sw := &statusWriter{ResponseWriter: w, statusCode: http.StatusOK}
+				var resp *CachedResponse
+				defer func() { d.finish(key, e, resp) }()
+				next.ServeHTTP(sw, r)
+				if r.Context().Err() == nil && succeeded(sw.statusCode) {
+					resp = &CachedResponse{}
+				}
+				return
+			}
+			// Ask for an uncompressed body so it can be replayed as-is
+			r.Header.Del("Accept-Encoding")
+			rw := &responseWriterWrapper{ResponseWriter: w, body: &bytes.Buffer{}, statusCode: http.StatusOK}
+			var resp *CachedResponse
+			defer func() { d.finish(key, e, resp) }()
+			next.ServeHTTP(rw, r)
+
+			if r.Context().Err() == nil && succeeded(rw.statusCode) {
+				resp = &CachedResponse{
+					StatusCode:  rw.statusCode,
+					ContentType: w.Header().Get("Content-Type"),
+					Body:        rw.body.Bytes(),
+				}
+			}
+		})
+	}
+}
+
+func succeeded(status int) bool {
+	return status >= 200 && status < 300
+}
+
+// statusWriter records the status of a response it passes through.
+type statusWriter struct {
+	http.ResponseWriter
+	statusCode int
+}
+
+func (sw *statusWriter) WriteHeader(code int) {
+	sw.statusCode = code
+	sw.ResponseWriter.WriteHeader(code)
+}
+
+// Unwrap lets http.ResponseController flush streamed responses through.
+func (sw *statusWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
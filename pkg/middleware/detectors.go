@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Structured detectors pair a loose regex with a checksum or format rule,
+// so numbers that merely look like the PII class are left alone.
+var (
+	cardRegex = regexp.MustCompile(`\b(?:\d{13,19}|\d{4}(?: \d{4}){2} \d{1,7}|\d{4}(?:-\d{4}){2}-\d{1,7}|\d{4} \d{6} \d{4,5}|\d{4}-\d{6}-\d{4,5})\b`)
+	ibanRegex = regexp.MustCompile(`\b[A-Z]{2}\d{2}(?: ?[A-Z0-9]{4}){2,7}(?: ?[A-Z0-9]{1,3})?\b`)
+	ssnRegex  = regexp.MustCompile(`\b\d{3}[- ]\d{2}[- ]\d{4}\b`)
+)
+
+// digitsOf returns s without separators.
+func digitsOf(s string) string {
+	return strings.NewReplacer(" ", "", "-", "").Replace(s)
+}
+
+// validCard reports whether a 13 to 19 digit number passes the Luhn check
+// that payment card numbers carry.
+func validCard(match string) bool {
+	digits := digitsOf(match)
+	if len(digits) < 13 || len(digits) > 19 {
+		return false
+	}
+	sum := 0
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if (len(digits)-i)%2 == 0 {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
+
+// validIBAN reports whether an IBAN's check digits are right: moved behind
+// the account number, with letters as 10 to 35, it is 1 modulo 97.
+func validIBAN(match string) bool {
+	iban := digitsOf(match)
+	if len(iban) < 15 || len(iban) > 34 {
+		return false
+	}
+	rem := 0
+	for _, r := range iban[4:] + iban[:4] {
+		switch {
+		case r >= '0' && r <= '9':
+			rem = (rem*10 + int(r-'0')) % 97
+		case r >= 'A' && r <= 'Z':
+			rem = (rem*100 + int(r-'A'+10)) % 97
+		default:
+			return false
+		}
+	}
+	return rem == 1
+}
+
+// validSSN reports whether a US Social Security number is one the SSA can
+// issue: no 000, 666 or 9xx area, 00 group or 0000 serial.
+func validSSN(match string) bool {
+	digits := digitsOf(match)
+	area, group, serial := digits[:3], digits[3:5], digits[5:]
+	return area != "000" && area != "666" && area[0] != '9' && group != "00" && serial != "0000"
+}
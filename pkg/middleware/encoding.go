@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// capturableEncodings are the response content codings AuditMiddleware can
+// decode before recording a body.
+var capturableEncodings = []string{"gzip", "x-gzip", "deflate", "zstd", "identity"}
+
+var zstdDecoder, _ = zstd.NewReader(nil)
+
+// restrictAcceptEncoding drops codings the audit capture cannot decode,
+// such as br, from the client's Accept-Encoding. When nothing is left the
+// header is removed and the transport negotiates and decodes gzip itself.
+func restrictAcceptEncoding(h http.Header) {
+	accept := h.Get("Accept-Encoding")
+	if accept == "" {
+		return
+	}
+	var kept []string
+	for _, part := range strings.Split(accept, ",") {
+		coding, _, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if contains(capturableEncodings, strings.ToLower(strings.TrimSpace(coding))) {
+			kept = append(kept, strings.TrimSpace(part))
+		}
+	}
+	if len(kept) == 0 {
+		h.Del("Accept-Encoding")
+		return
+	}
+	h.Set("Accept-Encoding", strings.Join(kept, ", "))
+}
+
+// decodeContent undoes a response's Content-Encoding so the recorded body
+// is the plain payload. Bodies that fail to decode are returned as-is.
+func decodeContent(encoding string, body []byte) []byte {
+	var r io.ReadCloser
+	var err error
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "", "identity":
+		return body
+	case "gzip", "x-gzip":
+		r, err = gzip.NewReader(bytes.NewReader(body))
+	case "deflate":
+		r, err = zlib.NewReader(bytes.NewReader(body))
+	case "zstd":
+		decoded, err := zstdDecoder.DecodeAll(body, nil)
+		if err != nil {
+			return body
+		}
+		return decoded
+	default:
+		return body
+	}
+	if err != nil {
+		return body
+	}
+	defer r.Close()
+	decoded, err := io.ReadAll(r)
+	if err != nil {
+		return body
+	}
+	return decoded
+}
@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// ArtifactRegistry resolves the owner of a registered fine-tuned model.
+type ArtifactRegistry interface {
+	ArtifactOwner(modelID string) (string, bool)
+}
+
+// FineTunePolicy restricts who may create and invoke fine-tuned models.
+// Empty Creators allows anyone to create; when RestrictInvoke is set, only a
+// model's creator and the listed Invokers may call it.
+type FineTunePolicy struct {
+	Creators       []string
+	Invokers       []string
+	RestrictInvoke bool
+}
+
+var fineTuneCreatePaths = map[string]bool{
+	"/v1/datasets":                    true,
+	"/v1/finetuning/finetuned-models": true,
+}
+
+// FineTuneMiddleware enforces the fine-tuning policy before requests reach the provider.
+func FineTuneMiddleware(policy FineTunePolicy, registry ArtifactRegistry) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			userID := UserID(r)
+
+			// 1. Dataset uploads and fine-tune jobs
+			if fineTuneCreatePaths[strings.TrimSuffix(r.URL.Path, "/")] {
+				if len(policy.Creators) > 0 && !contains(policy.Creators, userID) {
+					denyFineTune(w, r)
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// 2. Invocations of fine-tuned models
+			if policy.RestrictInvoke {
+				if model := requestModel(r); model != "" {
+					owner, ok := registry.ArtifactOwner(strings.TrimSuffix(model, "-ft"))
+					if ok && owner != userID && !contains(policy.Invokers, userID) {
+						denyFineTune(w, r)
+						return
+					}
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func denyFineTune(w http.ResponseWriter, r *http.Request) {
+	markBlocked(r)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusForbidden)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error": "Fine-tuning Policy Violation",
+		"code":  "FINETUNE_FORBIDDEN",
+	})
+}
+
+func contains(list []string, v string) bool {
+	for _, item := range list {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
@@ -2,7 +2,6 @@ package middleware
 
 import (
 	"bytes"
-	"context"
 	"encoding/json"
 	"io"
 	"net/http"
@@ -16,8 +15,47 @@ var (
 	uuidRegex  = regexp.MustCompile(`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`)
 )
 
-// GovernanceMiddleware handles PII redaction and forbidden keywords.
-func GovernanceMiddleware(forbiddenKeywords []string, redactionEnabled bool) func(http.Handler) http.Handler {
+// GovernanceProfile is a rule set for the requests under Paths, which are
+// path prefixes. A nil Redactor disables redaction for them.
+type GovernanceProfile struct {
+	Name              string
+	Paths             []string
+	ForbiddenKeywords []string
+	Redactor          *Redactor
+}
+
+// matches reports whether the profile applies to path.
+func (p GovernanceProfile) matches(path string) bool {
+	for _, prefix := range p.Paths {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// GovernanceRules are the rule sets GovernanceMiddleware applies: the first
+// of Profiles matching a request's path, else ForbiddenKeywords and
+// Redactor. A nil Redactor disables redaction.
+type GovernanceRules struct {
+	ForbiddenKeywords []string
+	Redactor          *Redactor
+	Profiles          []GovernanceProfile
+}
+
+// GovernanceMiddleware handles PII redaction and forbidden keywords. A nil
+// redactor disables redaction. When the redactor has a vault, PII is
+// tokenized instead of masked and the tokens are swapped back in the response.
+// A request is governed by the first of profiles matching its path, and by
+// forbiddenKeywords and redactor when none does.
+func GovernanceMiddleware(forbiddenKeywords []string, redactor *Redactor, profiles ...GovernanceProfile) func(http.Handler) http.Handler {
+	rules := GovernanceRules{ForbiddenKeywords: forbiddenKeywords, Redactor: redactor, Profiles: profiles}
+	return DynamicGovernanceMiddleware(func() GovernanceRules { return rules })
+}
+
+// DynamicGovernanceMiddleware is GovernanceMiddleware with the rules read
+// for every request, for rules that are changed while it serves.
+func DynamicGovernanceMiddleware(rules func() GovernanceRules) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			if r.Method != http.MethodPost || r.Body == nil {
@@ -25,45 +63,61 @@ func GovernanceMiddleware(forbiddenKeywords []string, redactionEnabled bool) fun
 				return
 			}
 
+			current := rules()
+			forbiddenKeywords, redactor := current.ForbiddenKeywords, current.Redactor
+			for _, p := range current.Profiles {
+				if p.matches(r.URL.Path) {
+					forbiddenKeywords, redactor = p.ForbiddenKeywords, p.Redactor
+					break
+				}
+			}
+
 			body, _ := io.ReadAll(r.Body)
 			bodyStr := string(body)
 
 			// 1. Rule Engine: Forbidden Keywords
 			for _, kw := range forbiddenKeywords {
 				if strings.Contains(strings.ToLower(bodyStr), strings.ToLower(kw)) {
+					markBlocked(r)
 					w.Header().Set("Content-Type", "application/json")
 					w.WriteHeader(http.StatusForbidden)
 					json.NewEncoder(w).Encode(map[string]string{
 						"error": "Security Policy Violation",
 						"code":  "FORBIDDEN_CONTENT",
 					})
-
-					// Set header for AuditMiddleware to pick up
-					w.Header().Set("X-Vantage-Blocked", "true")
 					return
 				}
 			}
 
 			// 2. PII Redactor
 			isRedacted := false
-			if redactionEnabled {
-				original := bodyStr
-				bodyStr = emailRegex.ReplaceAllString(bodyStr, "[REDACTED_EMAIL]")
-				bodyStr = phoneRegex.ReplaceAllString(bodyStr, "[REDACTED_PHONE]")
-				bodyStr = uuidRegex.ReplaceAllString(bodyStr, "[REDACTED_UUID]")
-
-				if bodyStr != original {
-					isRedacted = true
-					body = []byte(bodyStr)
+			if redactor != nil {
+				redacted, findings := redactor.RedactReport(bodyStr)
+				if isRedacted = redacted != bodyStr; isRedacted {
+					body = []byte(redacted)
+					report, _ := json.Marshal(findings)
+					signals(r).redactions = string(report)
 				}
 			}
 
 			// Restore body
 			r.Body = io.NopCloser(bytes.NewBuffer(body))
+			r.ContentLength = int64(len(body))
 
-			// Add to context for audit
-			ctx := context.WithValue(r.Context(), "is_redacted", isRedacted)
-			next.ServeHTTP(w, r.WithContext(ctx))
+			if isRedacted {
+				signals(r).redacted = true
+			}
+
+			// 3. De-tokenize the response so the client sees its original values
+			if isRedacted && redactor.vault != nil {
+				// Ask for an uncompressed response so tokens can be found
+				r.Header.Del("Accept-Encoding")
+				bw := &bufferedResponseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+				next.ServeHTTP(bw, r)
+				bw.flush(detokenizePII(bw.body.Bytes(), redactor.vault))
+				return
+			}
+			next.ServeHTTP(w, r)
 		})
 	}
 }
@@ -0,0 +1,121 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// maskedValue replaces the value of a masked header in the audit log.
+const maskedValue = "***"
+
+// sensitiveHeaders are always masked when captured.
+var sensitiveHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key"}
+
+// HeaderCapture selects the request and response headers AuditMiddleware
+// records. Only the listed headers are captured; values of credential
+// headers and of those listed in Mask are recorded as "***".
+type HeaderCapture struct {
+	Request  []string
+	Response []string
+	Mask     []string
+}
+
+// capturedHeaders is the JSON recorded in Interaction.Headers.
+type capturedHeaders struct {
+	Request  map[string]string `json:"request,omitempty"`
+	Response map[string]string `json:"response,omitempty"`
+}
+
+// record returns the allowlisted headers of a request and its response as
+// JSON, or "" when none of them were present.
+func (c HeaderCapture) record(req, resp http.Header) string {
+	captured := capturedHeaders{
+		Request:  c.pick(req, c.Request),
+		Response: c.pick(resp, c.Response),
+	}
+	if captured.Request == nil && captured.Response == nil {
+		return ""
+	}
+	out, _ := json.Marshal(captured)
+	return string(out)
+}
+
+func (c HeaderCapture) pick(h http.Header, names []string) map[string]string {
+	var out map[string]string
+	for _, name := range names {
+		values := h.Values(name)
+		if len(values) == 0 {
+			continue
+		}
+		if out == nil {
+			out = map[string]string{}
+		}
+		name = http.CanonicalHeaderKey(name)
+		if c.masked(name) {
+			out[name] = maskedValue
+		} else {
+			out[name] = strings.Join(values, ", ")
+		}
+	}
+	return out
+}
+
+func (c HeaderCapture) masked(name string) bool {
+	match := func(m string) bool { return strings.EqualFold(m, name) }
+	return slices.ContainsFunc(sensitiveHeaders, match) || slices.ContainsFunc(c.Mask, match)
+}
+
+// HeaderRule is a governance rule on a request header. With Required the
+// header must be present; a non-nil Pattern must match its value when it
+// is. Paths limits the rule to path prefixes; empty applies it everywhere.
+type HeaderRule struct {
+	Name     string
+	Header   string
+	Required bool
+	Pattern  *regexp.Regexp
+	Paths    []string
+}
+
+// HeaderRulesMiddleware blocks requests that break a header rule with 403
+// and code HEADER_POLICY_VIOLATION, naming the rule in the error.
+func HeaderRulesMiddleware(rules []HeaderRule) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, rule := range rules {
+				if msg := rule.check(r); msg != "" {
+					markBlocked(r)
+					w.Header().Set("Content-Type", "application/json")
+					w.WriteHeader(http.StatusForbidden)
+					json.NewEncoder(w).Encode(map[string]string{
+						"error": msg,
+						"code":  "HEADER_POLICY_VIOLATION",
+						"rule":  rule.Name,
+					})
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// check returns why r breaks the rule, or "" when it does not.
+func (rule HeaderRule) check(r *http.Request) string {
+	if len(rule.Paths) > 0 && !slices.ContainsFunc(rule.Paths, func(p string) bool { return strings.HasPrefix(r.URL.Path, p) }) {
+		return ""
+	}
+	values := r.Header.Values(rule.Header)
+	if len(values) == 0 {
+		if rule.Required {
+			return "missing required header " + http.CanonicalHeaderKey(rule.Header)
+		}
+		return ""
+	}
+	if rule.Pattern != nil && !rule.Pattern.MatchString(strings.Join(values, ", ")) {
+		return "header " + http.CanonicalHeaderKey(rule.Header) + " does not match the required pattern"
+	}
+	return ""
+}
@@ -0,0 +1,97 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+)
+
+type identityKey struct{}
+
+// Authenticator resolves the caller from request credentials. ok is false
+// when the request carries no credentials the authenticator handles; err is
+// set when it does but they are invalid.
+type Authenticator interface {
+	Authenticate(r *http.Request) (userID string, ok bool, err error)
+}
+
+// IdentityOptions configures how IdentityMiddleware resolves callers.
+// Authenticators are tried in order. When none applies, X-User-ID is used if
+// TrustHeader is set; Require rejects such unauthenticated requests instead.
+type IdentityOptions struct {
+	Authenticators []Authenticator
+	TrustHeader    bool
+	Require        bool
+}
+
+// WithUserID returns a context carrying the caller identity.
+func WithUserID(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, identityKey{}, userID)
+}
+
+// UserID returns the caller resolved by IdentityMiddleware. Without it, the
+// X-User-ID header is used, and "anonymous" when that is empty too.
+func UserID(r *http.Request) string {
+	if id, ok := r.Context().Value(identityKey{}).(string); ok {
+		return id
+	}
+	if id := r.Header.Get("X-User-ID"); id != "" {
+		return id
+	}
+	return "anonymous"
+}
+
+// IdentityMiddleware resolves the caller once per request and stores it in
+// the context, where audit records, metrics and policies read it via
+// UserID. Requests whose identity was already resolved pass straight through.
+func IdentityMiddleware(opts IdentityOptions) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := r.Context().Value(identityKey{}).(string); ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// 1. Credentials
+			userID := ""
+			for _, a := range opts.Authenticators {
+				id, ok, err := a.Authenticate(r)
+				if err != nil {
+					denyIdentity(w, "Authentication failed: "+err.Error(), "INVALID_CREDENTIALS")
+					return
+				}
+				if ok {
+					userID = id
+					break
+				}
+			}
+
+			// 2. Unauthenticated callers
+			if userID == "" {
+				if opts.Require {
+					denyIdentity(w, "Authentication required", "CREDENTIALS_REQUIRED")
+					return
+				}
+				if opts.TrustHeader {
+					userID = r.Header.Get("X-User-ID")
+				}
+				if userID == "" {
+					userID = "anonymous"
+				}
+			}
+
+			// Mirrored into the header so the upstream sees the resolved identity, not a spoofed one
+			r.Header.Set("X-User-ID", userID)
+			next.ServeHTTP(w, r.WithContext(WithUserID(r.Context(), userID)))
+		})
+	}
+}
+
+func denyIdentity(w http.ResponseWriter, message, code string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnauthorized)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error": message,
+		"code":  code,
+	})
+}
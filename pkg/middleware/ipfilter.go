@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/netip"
+)
+
+// IPFilter decides which client addresses may reach a group of routes.
+// Deny wins over Allow; an empty Allow admits every address not denied.
+type IPFilter struct {
+	Allow []netip.Prefix
+	Deny  []netip.Prefix
+}
+
+// Allowed reports whether addr may pass the filter.
+func (f IPFilter) Allowed(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, p := range f.Deny {
+		if p.Contains(addr) {
+			return false
+		}
+	}
+	if len(f.Allow) == 0 {
+		return true
+	}
+	for _, p := range f.Allow {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// IPFilterMiddleware rejects clients outside the filter with 403 and code
+// IP_DENIED. It judges the connection's peer, so behind a reverse proxy it
+// must run after RealIPMiddleware with the proxy trusted; forwarding
+// headers are never read here. onDeny, if set, is called for every
+// rejected request; unparseable addresses are rejected.
+func IPFilterMiddleware(f IPFilter, onDeny func(r *http.Request, addr string)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			host := RemoteIP(r)
+			addr, err := netip.ParseAddr(host)
+			if err == nil && f.Allowed(addr) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if onDeny != nil {
+				onDeny(r, host)
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Access from this network is not allowed",
+				"code":  "IP_DENIED",
+			})
+		})
+	}
+}
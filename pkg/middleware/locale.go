@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"regexp"
+	"strings"
+)
+
+// localeStopwords are frequent words that identify a language. Words
+// common to several of them, such as "de" and "la", are left out.
+var localeStopwords = map[string][]string{
+	"en": {"the", "and", "is", "are", "of", "to", "that", "it", "for", "with", "you", "this", "what", "my", "please", "how", "can"},
+	"de": {"der", "die", "das", "und", "ist", "nicht", "ich", "sie", "mit", "ein", "eine", "zu", "den", "für", "auf", "bitte", "wie", "mein", "meine", "ihre", "können"},
+	"fr": {"le", "les", "et", "est", "des", "une", "je", "vous", "pour", "pas", "que", "dans", "avec", "mon", "ma", "sur", "bonjour", "merci", "votre", "il", "elle"},
+	"es": {"el", "los", "las", "y", "es", "que", "una", "por", "para", "con", "mi", "hola", "gracias", "está", "usted", "su"},
+	"it": {"il", "gli", "è", "di", "che", "per", "una", "non", "sono", "ciao", "grazie", "della", "questo", "mio", "suo"},
+	"nl": {"het", "een", "van", "ik", "niet", "je", "met", "op", "voor", "dat", "mijn", "hoe", "zijn", "wat", "alstublieft"},
+}
+
+// LocaleLanguages are the languages DetectLanguage recognizes.
+var LocaleLanguages = []string{"de", "en", "es", "fr", "it", "nl"}
+
+var (
+	wordRegex     = regexp.MustCompile(`\p{L}+`)
+	stopwordIndex = map[string][]string{}
+)
+
+func init() {
+	for lang, words := range localeStopwords {
+		for _, w := range words {
+			stopwordIndex[w] = append(stopwordIndex[w], lang)
+		}
+	}
+}
+
+// detectWords is how many words of a body DetectLanguage looks at.
+const detectWords = 2000
+
+// DetectLanguage guesses the language of text from its most frequent
+// words and returns its ISO 639-1 code, or "" when no language has at
+// least two hits and more than any other.
+func DetectLanguage(text string) string {
+	hits := map[string]int{}
+	for _, w := range wordRegex.FindAllString(text, detectWords) {
+		for _, lang := range stopwordIndex[strings.ToLower(w)] {
+			hits[lang]++
+		}
+	}
+	best, bestHits, second := "", 0, 0
+	for lang, n := range hits {
+		switch {
+		case n > bestHits:
+			best, bestHits, second = lang, n, bestHits
+		case n > second:
+			second = n
+		}
+	}
+	if bestHits < 2 || bestHits == second {
+		return ""
+	}
+	return best
+}
+
+// localePatterns are the built-in patterns for national formats: IBANs,
+// VAT numbers, national identifiers and phone numbers.
+var localePatterns = map[string][]RedactionPattern{
+	"de": {
+		{Name: "de_iban", Regex: regexp.MustCompile(`\bDE\d{2}(?: ?\d{4}){4} ?\d{2}\b`), Replacement: "[REDACTED_IBAN]", Valid: validIBAN},
+		{Name: "de_vat", Regex: regexp.MustCompile(`\bDE ?\d{9}\b`), Replacement: "[REDACTED_VAT]"},
+		{Name: "de_tax_id", Regex: regexp.MustCompile(`\b[1-9]\d{2} ?\d{3} ?\d{3} ?\d{2}\b`), Replacement: "[REDACTED_TAX_ID]"},
+		{Name: "de_social_security", Regex: regexp.MustCompile(`\b\d{2} ?[0-3]\d[01]\d\d{2} ?[A-Z] ?\d{3}\b`), Replacement: "[REDACTED_SOCIAL_SECURITY]"},
+		{Name: "de_phone", Regex: regexp.MustCompile(`(?:\+49 ?(?:\(0\) ?)?|\b0)[1-9]\d{1,4}[ /-]?\d{3,9}\b`), Replacement: "[REDACTED_PHONE]"},
+	},
+	"fr": {
+		{Name: "fr_iban", Regex: regexp.MustCompile(`\bFR\d{2}(?: ?[A-Z0-9]{4}){5} ?[A-Z0-9]{3}\b`), Replacement: "[REDACTED_IBAN]", Valid: validIBAN},
+		{Name: "fr_vat", Regex: regexp.MustCompile(`\bFR ?[0-9A-HJ-NP-Z]{2} ?\d{9}\b`), Replacement: "[REDACTED_VAT]"},
+		{Name: "fr_nir", Regex: regexp.MustCompile(`\b[12] ?\d{2} ?(?:0[1-9]|1[0-2]|[2-9]\d) ?(?:\d{2}|2[AB]) ?\d{3} ?\d{3}(?: ?\d{2})?\b`), Replacement: "[REDACTED_NIR]"},
+		{Name: "fr_phone", Regex: regexp.MustCompile(`(?:\+33 ?(?:\(0\) ?)?|\b0)[1-9](?:[ .-]?\d{2}){4}\b`), Replacement: "[REDACTED_PHONE]"},
+	},
+}
+
+// LocaleRedactionPatterns returns the built-in patterns of a language,
+// which may be none.
+func LocaleRedactionPatterns(lang string) []RedactionPattern {
+	return append([]RedactionPattern(nil), localePatterns[lang]...)
+}
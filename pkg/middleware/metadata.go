@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// MetadataHeader carries client-supplied JSON context (feature name, ticket
+// ID, ...) that is stored with the interaction for cost attribution.
+const MetadataHeader = "X-Vantage-Metadata"
+
+// MaxMetadataBytes is the largest metadata header accepted.
+const MaxMetadataBytes = 2048
+
+// ParseMetadata validates a metadata header value and returns it as compact
+// JSON with sorted keys. An empty value yields an empty result.
+func ParseMetadata(raw string) (string, error) {
+	if raw == "" {
+		return "", nil
+	}
+	if len(raw) > MaxMetadataBytes {
+		return "", errors.New("metadata exceeds 2048 bytes")
+	}
+	var obj map[string]interface{}
+	if err := json.Unmarshal([]byte(raw), &obj); err != nil || obj == nil {
+		return "", errors.New("metadata must be a JSON object")
+	}
+	normalized, err := json.Marshal(obj)
+	if err != nil {
+		return "", err
+	}
+	return string(normalized), nil
+}
+
+// MetadataMiddleware rejects malformed metadata headers and strips the header
+// before the request is forwarded upstream.
+func MetadataMiddleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, err := ParseMetadata(r.Header.Get(MetadataHeader)); err != nil {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]string{
+					"error": "Invalid " + MetadataHeader + " header: " + err.Error(),
+					"code":  "INVALID_METADATA",
+				})
+				return
+			}
+			r.Header.Del(MetadataHeader)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
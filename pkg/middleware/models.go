@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ModelPolicy decides whether a requested model may be called.
+type ModelPolicy interface {
+	CheckModel(name string) (allowed bool, state string)
+	Sunset(name string) (time.Time, bool)
+}
+
+// ModelPolicyMiddleware rejects requests for models the registry does not permit.
+// Deprecated models are still served, with Deprecation, Sunset and Warning
+// response headers telling the client to migrate.
+func ModelPolicyMiddleware(policy ModelPolicy) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if model := requestModel(r); model != "" {
+				allowed, state := policy.CheckModel(model)
+				if !allowed {
+					markBlocked(r)
+					w.Header().Set("Content-Type", "application/json")
+					w.WriteHeader(http.StatusForbidden)
+					json.NewEncoder(w).Encode(map[string]string{
+						"error": "Model not permitted: " + model,
+						"code":  "MODEL_" + strings.ToUpper(state),
+					})
+					return
+				}
+				if state == "deprecated" {
+					setDeprecationHeaders(w, policy, model)
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func setDeprecationHeaders(w http.ResponseWriter, policy ModelPolicy, model string) {
+	w.Header().Set("Deprecation", "true")
+	msg := fmt.Sprintf("model %s is deprecated", model)
+	if sunset, ok := policy.Sunset(model); ok {
+		w.Header().Set("Sunset", sunset.UTC().Format(http.TimeFormat))
+		msg += " and will be blocked after " + sunset.UTC().Format(time.DateOnly)
+	}
+	w.Header().Set("Warning", fmt.Sprintf(`299 vantage "%s"`, msg))
+}
@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// ModerationCategories are the harm categories prompts and responses are
+// scored in, from 0 (absent) to 1 (certain).
+var ModerationCategories = []string{"violence", "self_harm", "sexual", "hate", "illegal"}
+
+// ModerationReport is the moderation of one text: its score in each
+// category and the categories that reached their threshold.
+type ModerationReport struct {
+	Scores  map[string]float64 `json:"scores"`
+	Flagged []string           `json:"flagged,omitempty"`
+}
+
+// NewModerationReport flags the categories of scores that reach their
+// threshold; categories without one are never flagged.
+func NewModerationReport(scores map[string]float64, thresholds map[string]float64) ModerationReport {
+	report := ModerationReport{Scores: scores}
+	for _, c := range ModerationCategories {
+		if limit, ok := thresholds[c]; ok && scores[c] >= limit {
+			report.Flagged = append(report.Flagged, c)
+		}
+	}
+	return report
+}
+
+// SafetyScore is the confidence that the text is safe: one minus its
+// highest category score.
+func (m ModerationReport) SafetyScore() float64 {
+	highest := 0.0
+	for _, s := range m.Scores {
+		highest = max(highest, s)
+	}
+	return 1 - highest
+}
+
+// ModerationOptions configures ModerationMiddleware. Moderate scores the
+// prompt of a request body in each category; Thresholds are the scores at
+// which a category is flagged.
+type ModerationOptions struct {
+	Moderate   func(body []byte) (map[string]float64, error)
+	Thresholds map[string]float64
+}
+
+// ModerationMiddleware moderates prompts before they are forwarded and
+// rejects those flagged in any category. The report goes to the audit
+// record, so the audit worker does not moderate the prompt again. Requests
+// are let through when the moderator fails.
+func ModerationMiddleware(opts ModerationOptions) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost {
+				next.ServeHTTP(w, r)
+				return
+			}
+			scores, err := opts.Moderate(peekBody(r))
+			if err != nil {
+				log.Printf("Moderation failed: %v", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+			if scores == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			report := NewModerationReport(scores, opts.Thresholds)
+			record, _ := json.Marshal(report)
+			signals(r).moderation = string(record)
+
+			if len(report.Flagged) > 0 {
+				markBlocked(r)
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusForbidden)
+				json.NewEncoder(w).Encode(map[string]string{
+					"error": "Prompt flagged by moderation: " + strings.Join(report.Flagged, ", "),
+					"code":  "MODERATION_FLAGGED",
+				})
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,230 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ParamOverridesHeader lists, on the response, the parameters the caller's
+// parameter policy set, changed or removed.
+const ParamOverridesHeader = "X-Vantage-Param-Overrides"
+
+// ParamPolicy holds the defaults and limits of one user's or team's
+// generation parameters. Model, MaxTokens and Temperature are set on
+// requests without them. MaxTokensLimit, the temperature range and
+// DisallowTools bound what callers may ask for: out-of-range values are
+// brought into range and tools removed, unless Reject refuses the request
+// instead. Zero values leave a parameter alone.
+type ParamPolicy struct {
+	// Name identifies the policy in audit records, as "user:alice" or
+	// "team:research"
+	Name           string
+	Model          string
+	MaxTokens      int
+	Temperature    *float64
+	MaxTokensLimit int
+	MinTemperature *float64
+	MaxTemperature *float64
+	DisallowTools  bool
+	Reject         bool
+}
+
+// ParamPolicies maps callers to their parameter policy: their own in Users,
+// else that of their team (TeamOf) in Teams.
+type ParamPolicies struct {
+	Users  map[string]ParamPolicy
+	Teams  map[string]ParamPolicy
+	TeamOf map[string]string
+}
+
+// For returns the policy that governs userID.
+func (p ParamPolicies) For(userID string) (ParamPolicy, bool) {
+	if policy, ok := p.Users[userID]; ok {
+		return policy, true
+	}
+	team, ok := p.TeamOf[userID]
+	if !ok {
+		return ParamPolicy{}, false
+	}
+	policy, ok := p.Teams[team]
+	return policy, ok
+}
+
+// ParamOverrides records what ParamPolicyMiddleware did to a request. The
+// audit log keeps the request body as the client sent it.
+type ParamOverrides struct {
+	Policy    string                 `json:"policy"`
+	Defaulted map[string]interface{} `json:"defaulted,omitempty"`
+	Clamped   map[string]CappedParam `json:"clamped,omitempty"`
+	Removed   []string               `json:"removed,omitempty"`
+	// Rejected is the parameter the request was refused for
+	Rejected string `json:"rejected,omitempty"`
+}
+
+// toolParams are the request fields that give the model tools, in Cohere
+// and OpenAI-compatible chat requests.
+var toolParams = []string{"tools", "tool_choice"}
+
+// ParamPolicyMiddleware applies the caller's parameter policy to Cohere and
+// OpenAI-compatible chat requests, which carry "message" or "messages".
+// What it changed, or the parameter it rejected the request for, is
+// recorded with the interaction.
+func ParamPolicyMiddleware(policies ParamPolicies) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			policy, ok := policies.For(UserID(r))
+			if !ok || r.Method != http.MethodPost || strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+				next.ServeHTTP(w, r)
+				return
+			}
+			dec := json.NewDecoder(bytes.NewReader(peekBody(r)))
+			dec.UseNumber()
+			var doc map[string]interface{}
+			if dec.Decode(&doc) != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			_, v1 := doc["message"]
+			_, v2 := doc["messages"]
+			if !v1 && !v2 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			o := ParamOverrides{Policy: policy.Name}
+			if status, message, code := policy.apply(doc, &o); status != 0 {
+				record, _ := json.Marshal(o)
+				signals(r).paramOverrides = string(record)
+				markBlocked(r)
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(status)
+				json.NewEncoder(w).Encode(map[string]string{
+					"error": message,
+					"code":  code,
+				})
+				return
+			}
+			changed := o.params()
+			if len(changed) == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+			body, err := json.Marshal(doc)
+			if err != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			record, _ := json.Marshal(o)
+			signals(r).paramOverrides = string(record)
+			w.Header().Set(ParamOverridesHeader, strings.Join(changed, ","))
+			r.Body = io.NopCloser(bytes.NewBuffer(body))
+			r.ContentLength = int64(len(body))
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// apply enforces the policy on doc and records the changes in o. A non-zero
+// status rejects the request, with its error message and code.
+func (p ParamPolicy) apply(doc map[string]interface{}, o *ParamOverrides) (status int, message, code string) {
+	// 1. Tools
+	if p.DisallowTools {
+		for _, name := range toolParams {
+			if _, present := doc[name]; !present {
+				continue
+			}
+			if p.Reject {
+				o.Rejected = name
+				return http.StatusForbidden, fmt.Sprintf("Tools are not allowed by policy %s", p.Name), "TOOLS_NOT_ALLOWED"
+			}
+			delete(doc, name)
+			o.Removed = append(o.Removed, name)
+		}
+	}
+
+	// 2. Output tokens
+	if v, present := numberParam(doc, "max_tokens"); present {
+		if limit := float64(p.MaxTokensLimit); limit > 0 && v > limit {
+			if p.Reject {
+				o.Rejected = "max_tokens"
+				return http.StatusBadRequest, fmt.Sprintf("max_tokens %g exceeds the limit of %d set by policy %s", v, p.MaxTokensLimit, p.Name), "PARAM_OUT_OF_RANGE"
+			}
+			o.clamp(doc, "max_tokens", v, limit)
+		}
+	} else if p.MaxTokens > 0 {
+		o.setDefault(doc, "max_tokens", p.MaxTokens)
+	}
+
+	// 3. Temperature
+	if v, present := numberParam(doc, "temperature"); present {
+		bound, out := v, false
+		if p.MinTemperature != nil && v < *p.MinTemperature {
+			bound, out = *p.MinTemperature, true
+		}
+		if p.MaxTemperature != nil && v > *p.MaxTemperature {
+			bound, out = *p.MaxTemperature, true
+		}
+		if out {
+			if p.Reject {
+				o.Rejected = "temperature"
+				return http.StatusBadRequest, fmt.Sprintf("temperature %g is outside the range allowed by policy %s", v, p.Name), "PARAM_OUT_OF_RANGE"
+			}
+			o.clamp(doc, "temperature", v, bound)
+		}
+	} else if p.Temperature != nil {
+		o.setDefault(doc, "temperature", *p.Temperature)
+	}
+
+	// 4. Model
+	if model, _ := doc["model"].(string); model == "" && p.Model != "" {
+		o.setDefault(doc, "model", p.Model)
+	}
+	return 0, "", ""
+}
+
+// numberParam returns the value of a numeric top-level parameter, and
+// whether the request sets one.
+func numberParam(doc map[string]interface{}, name string) (float64, bool) {
+	n, ok := doc[name].(json.Number)
+	if !ok {
+		return 0, false
+	}
+	v, err := n.Float64()
+	return v, err == nil
+}
+
+func (o *ParamOverrides) clamp(doc map[string]interface{}, name string, from, to float64) {
+	doc[name] = json.Number(strconv.FormatFloat(to, 'f', -1, 64))
+	if o.Clamped == nil {
+		o.Clamped = map[string]CappedParam{}
+	}
+	o.Clamped[name] = CappedParam{From: from, To: to}
+}
+
+func (o *ParamOverrides) setDefault(doc map[string]interface{}, name string, value interface{}) {
+	doc[name] = value
+	if o.Defaulted == nil {
+		o.Defaulted = map[string]interface{}{}
+	}
+	o.Defaulted[name] = value
+}
+
+// params lists the parameters o changed, sorted.
+func (o *ParamOverrides) params() []string {
+	names := append([]string{}, o.Removed...)
+	for name := range o.Defaulted {
+		names = append(names, name)
+	}
+	for name := range o.Clamped {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+)
+
+// Plan bundles the request limits of a subscription tier. Zero limits and an
+// empty model list mean unrestricted.
+type Plan struct {
+	Name            string   `json:"name"`
+	MaxPromptBytes  int      `json:"max_prompt_bytes"`
+	MaxOutputTokens int      `json:"max_output_tokens"`
+	Models          []string `json:"models"`
+	Stream          bool     `json:"stream"`
+}
+
+// PlanResolver returns the plan a user is on.
+type PlanResolver interface {
+	PlanFor(userID string) (Plan, bool)
+}
+
+// PlanMiddleware enforces the caller's plan: oversized prompts, models
+// outside the plan and streaming on plans without it are rejected, and
+// max_tokens is clamped to the plan's output limit.
+func PlanMiddleware(plans PlanResolver) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID := UserID(r)
+			plan, ok := plans.PlanFor(userID)
+			if !ok || r.Method != http.MethodPost {
+				next.ServeHTTP(w, r)
+				return
+			}
+			w.Header().Set("X-Vantage-Plan", plan.Name)
+
+			// 1. Prompt size
+			body := peekBody(r)
+			if plan.MaxPromptBytes > 0 && len(body) > plan.MaxPromptBytes {
+				denyPlan(w, r, http.StatusRequestEntityTooLarge, "Prompt exceeds the "+plan.Name+" plan limit", "PROMPT_TOO_LARGE")
+				return
+			}
+
+			// 2. Allowed models
+			if model := requestModel(r); model != "" && len(plan.Models) > 0 && !contains(plan.Models, model) {
+				denyPlan(w, r, http.StatusForbidden, "Model "+model+" is not available on the "+plan.Name+" plan", "MODEL_NOT_IN_PLAN")
+				return
+			}
+
+			// 3. Streaming
+			if !plan.Stream && isStreaming(body) {
+				denyPlan(w, r, http.StatusForbidden, "Streaming is not available on the "+plan.Name+" plan", "STREAM_NOT_IN_PLAN")
+				return
+			}
+
+			// 4. Output tokens
+			if plan.MaxOutputTokens > 0 {
+				clampMaxTokens(r, body, plan.MaxOutputTokens)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// clampMaxTokens sets max_tokens to limit when it is missing or higher.
+func clampMaxTokens(r *http.Request, body []byte, limit int) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return
+	}
+	var current int
+	if raw, ok := fields["max_tokens"]; ok && json.Unmarshal(raw, &current) == nil && current > 0 && current <= limit {
+		return
+	}
+	fields["max_tokens"], _ = json.Marshal(limit)
+	body, _ = json.Marshal(fields)
+	r.Body = io.NopCloser(bytes.NewBuffer(body))
+	r.ContentLength = int64(len(body))
+}
+
+func denyPlan(w http.ResponseWriter, r *http.Request, status int, message, code string) {
+	markBlocked(r)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error": message,
+		"code":  code,
+	})
+}
@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ProjectHeader names the internal project a request is charged to. Virtual
+// keys issued for a project set it themselves.
+const ProjectHeader = "X-Vantage-Project"
+
+// Project returns the project a request is charged to, or "" for none.
+func Project(r *http.Request) string {
+	return strings.TrimSpace(r.Header.Get(ProjectHeader))
+}
+
+// ProjectSpend reports the estimated cost charged to a project since a
+// point in time.
+type ProjectSpend interface {
+	ProjectCost(project string, since time.Time) (float64, error)
+}
+
+// ProjectBudget caps the estimated cost each project may be charged per
+// calendar month (UTC). Projects without a limit are unrestricted.
+// Requests are flagged with a Warning header once WarnRatio of the limit is
+// spent, and rejected once the limit is reached.
+type ProjectBudget struct {
+	Limits    map[string]float64
+	WarnRatio float64
+}
+
+// ProjectBudgetMiddleware enforces the monthly project budgets. Costs are
+// rolled up by the audit worker once responses are parsed, so the request
+// that crosses a budget completes and later ones are rejected.
+func ProjectBudgetMiddleware(spend ProjectSpend, budget ProjectBudget) func(http.Handler) http.Handler {
+	return DynamicProjectBudgetMiddleware(spend, func() ProjectBudget { return budget })
+}
+
+// DynamicProjectBudgetMiddleware is ProjectBudgetMiddleware with the budgets
+// read for every request, for budgets that are changed while it serves.
+func DynamicProjectBudgetMiddleware(spend ProjectSpend, budgets func() ProjectBudget) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			budget := budgets()
+			project := Project(r)
+			limit, ok := budget.Limits[project]
+			if project == "" || !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			now := time.Now().UTC()
+			month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+			spent, err := spend.ProjectCost(project, month)
+			if err != nil {
+				// Fail open; the budget is a cost control, not a security boundary
+				next.ServeHTTP(w, r)
+				return
+			}
+			w.Header().Set("X-Vantage-Project-Spend", fmt.Sprintf("%.2f/%.2f", spent, limit))
+
+			if spent >= limit {
+				sig := signals(r)
+				sig.blocked = true
+				sig.budgetExceeded = "project"
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusForbidden)
+				json.NewEncoder(w).Encode(map[string]string{
+					"error": fmt.Sprintf("Monthly budget of project %s exhausted", project),
+					"code":  "PROJECT_BUDGET_EXCEEDED",
+				})
+				return
+			}
+			if budget.WarnRatio > 0 && spent >= budget.WarnRatio*limit {
+				w.Header().Add("Warning", fmt.Sprintf(`299 vantage "project %s has spent %.2f of its %.2f monthly budget"`, project, spent, limit))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
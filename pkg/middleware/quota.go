@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+// QuotaMiddleware enforces a per-user request quota (e.g. requests per day)
+// before the request is proxied. Limiter states with a zero Limit are
+// treated as unlimited and leave the response untouched.
+//
+// Every limited response carries the quota in X-Quota-Limit, -Remaining and
+// -Reset. A rejection also carries it in the RateLimit-* headers and
+// Retry-After, as a rate limit rejection does; on allowed requests those
+// are left to the rate limiter, so X-Quota-* are aliases of them there.
+func QuotaMiddleware(quota RateLimiter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID := UserID(r)
+
+			state := quota.Allow(userID)
+			if state.Limit > 0 {
+				h := w.Header()
+				h.Set("X-Quota-Limit", strconv.Itoa(state.Limit))
+				h.Set("X-Quota-Remaining", strconv.Itoa(state.Remaining))
+				h.Set("X-Quota-Reset", strconv.Itoa(ceilSeconds(state.Reset)))
+			}
+			if !state.Allowed {
+				SetRateLimitHeaders(w.Header(), state)
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusTooManyRequests)
+				json.NewEncoder(w).Encode(map[string]string{
+					"error": "Request quota exceeded",
+					"code":  "QUOTA_EXCEEDED",
+				})
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type fixedLimiter RateLimitState
+
+func (l fixedLimiter) Allow(string) RateLimitState { return RateLimitState(l) }
+
+func TestQuotaMiddlewareRejectionHeaders(t *testing.T) {
+	quota := fixedLimiter{Limit: 500, Reset: 90 * time.Minute, Window: 24 * time.Hour}
+	handler := QuotaMiddleware(quota)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("request over the quota reached the handler")
+	}))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/chat", nil))
+
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want 429", rec.Code)
+	}
+	for header, want := range map[string]string{
+		"RateLimit-Limit":     "500",
+		"RateLimit-Remaining": "0",
+		"RateLimit-Reset":     "5400",
+		"RateLimit-Policy":    "500;w=86400",
+		"Retry-After":         "5400",
+		"X-Quota-Limit":       "500",
+		"X-Quota-Remaining":   "0",
+		"X-Quota-Reset":       "5400",
+	} {
+		if got := rec.Header().Get(header); got != want {
+			t.Errorf("%s = %q, want %q", header, got, want)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package middleware
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimitState describes a limiter decision and the window it was taken in.
+// A state without a Window lets the request through unlimited.
+type RateLimitState struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	Reset     time.Duration
+	Window    time.Duration
+}
+
+// RateLimiter decides whether a key may make another request.
+type RateLimiter interface {
+	Allow(key string) RateLimitState
+}
+
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+// WindowLimiter is an in-memory fixed-window rate limiter.
+type WindowLimiter struct {
+	limit  int
+	window time.Duration
+
+	mu        sync.Mutex
+	windows   map[string]*rateWindow
+	lastSweep time.Time
+}
+
+func NewWindowLimiter(limit int, window time.Duration) *WindowLimiter {
+	return &WindowLimiter{
+		limit:     limit,
+		window:    window,
+		windows:   make(map[string]*rateWindow),
+		lastSweep: time.Now(),
+	}
+}
+
+// SetLimit changes the allowance. Windows already open keep their count.
+func (l *WindowLimiter) SetLimit(limit int, window time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.limit = limit
+	l.window = window
+}
+
+func (l *WindowLimiter) Allow(key string) RateLimitState {
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Drop expired windows so idle keys don't accumulate
+	if now.Sub(l.lastSweep) > l.window {
+		for k, w := range l.windows {
+			if now.Sub(w.start) >= l.window {
+				delete(l.windows, k)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	w, ok := l.windows[key]
+	if !ok || now.Sub(w.start) >= l.window {
+		w = &rateWindow{start: now}
+		l.windows[key] = w
+	}
+
+	state := RateLimitState{
+		Limit:  l.limit,
+		Reset:  w.start.Add(l.window).Sub(now),
+		Window: l.window,
+	}
+	if w.count < l.limit {
+		w.count++
+		state.Allowed = true
+	}
+	state.Remaining = l.limit - w.count
+	return state
+}
+
+// SetRateLimitHeaders writes the IETF RateLimit-* headers for a limiter state,
+// plus Retry-After when the request was rejected.
+func SetRateLimitHeaders(h http.Header, state RateLimitState) {
+	reset := ceilSeconds(state.Reset)
+	h.Set("RateLimit-Limit", strconv.Itoa(state.Limit))
+	h.Set("RateLimit-Remaining", strconv.Itoa(state.Remaining))
+	h.Set("RateLimit-Reset", strconv.Itoa(reset))
+	h.Set("RateLimit-Policy", strconv.Itoa(state.Limit)+";w="+strconv.Itoa(ceilSeconds(state.Window)))
+	if !state.Allowed {
+		h.Set("Retry-After", strconv.Itoa(reset))
+	}
+}
+
+// RateLimitMiddleware enforces a per-user request rate.
+func RateLimitMiddleware(limiter RateLimiter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID := UserID(r)
+
+			state := limiter.Allow(userID)
+			if state.Window == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+			SetRateLimitHeaders(w.Header(), state)
+			if !state.Allowed {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusTooManyRequests)
+				json.NewEncoder(w).Encode(map[string]string{
+					"error": "Rate limit exceeded",
+					"code":  "RATE_LIMITED",
+				})
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	if d <= 0 {
+		return 0
+	}
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// TrustedProxies are the networks of reverse proxies whose forwarding
+// headers are believed.
+type TrustedProxies []netip.Prefix
+
+func (t TrustedProxies) trusts(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, p := range t {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP returns the address of the client that sent r. It is the
+// connection's peer unless the peer is a trusted proxy. In that case the
+// last X-Forwarded-For hop that is not itself a trusted proxy is used,
+// falling back to X-Real-IP when the proxy sent no X-Forwarded-For.
+// Headers from other peers are ignored, so clients cannot choose their
+// own address.
+func (t TrustedProxies) ClientIP(r *http.Request) string {
+	peer := RemoteIP(r)
+	addr, err := netip.ParseAddr(peer)
+	if err != nil || !t.trusts(addr) {
+		return peer
+	}
+	// Proxies append to the header, so only the hops right of the last
+	// trusted proxy were added by ones we trust
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	client := ""
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+		client = hop.Unmap().String()
+		if !t.trusts(hop) {
+			return client
+		}
+	}
+	if client != "" {
+		// Only trusted hops; the earliest of them is the client
+		return client
+	}
+	if real, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("X-Real-IP"))); err == nil {
+		return real.Unmap().String()
+	}
+	return peer
+}
+
+// RealIPMiddleware sets r.RemoteAddr to the ClientIP of the request, for
+// the middleware and handlers that identify clients by address.
+func RealIPMiddleware(t TrustedProxies) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if len(t) > 0 {
+				if ip := t.ClientIP(r); ip != RemoteIP(r) {
+					r.RemoteAddr = ip
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RemoteIP returns the host part of r.RemoteAddr.
+func RemoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
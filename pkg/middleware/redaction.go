@@ -0,0 +1,195 @@
+package middleware
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// RedactionPattern is one kind of PII removed from prompts. Matches are
+// replaced with Replacement (which may reference capture groups as $1), or
+// with a vault token when tokenizing. When Valid is set, only the matches
+// it accepts are removed.
+type RedactionPattern struct {
+	Name        string
+	Regex       *regexp.Regexp
+	Replacement string
+	Valid       func(match string) bool
+}
+
+// Pattern names become part of vault tokens, so they are restricted to
+// letters and underscores.
+var patternNameRegex = regexp.MustCompile(`^[a-z][a-z_]*$`)
+
+// NewRedactionPattern compiles a pattern. An empty replacement defaults to
+// "[REDACTED_<NAME>]".
+func NewRedactionPattern(name, expr, replacement string) (RedactionPattern, error) {
+	if !patternNameRegex.MatchString(name) {
+		return RedactionPattern{}, fmt.Errorf("redaction pattern name %q must be lowercase letters and underscores", name)
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return RedactionPattern{}, fmt.Errorf("redaction pattern %s: %w", name, err)
+	}
+	if replacement == "" {
+		replacement = "[REDACTED_" + strings.ToUpper(name) + "]"
+	}
+	return RedactionPattern{Name: name, Regex: re, Replacement: replacement}, nil
+}
+
+// BuiltinRedactionPatterns returns the email, Luhn-checked credit card,
+// checksummed IBAN, US SSN, phone and UUID patterns, in that order, so
+// card and account numbers are not taken for phone numbers.
+func BuiltinRedactionPatterns() []RedactionPattern {
+	return []RedactionPattern{
+		{Name: "email", Regex: emailRegex, Replacement: "[REDACTED_EMAIL]"},
+		{Name: "credit_card", Regex: cardRegex, Replacement: "[REDACTED_CREDIT_CARD]", Valid: validCard},
+		{Name: "iban", Regex: ibanRegex, Replacement: "[REDACTED_IBAN]", Valid: validIBAN},
+		{Name: "ssn", Regex: ssnRegex, Replacement: "[REDACTED_SSN]", Valid: validSSN},
+		{Name: "phone", Regex: phoneRegex, Replacement: "[REDACTED_PHONE]"},
+		{Name: "uuid", Regex: uuidRegex, Replacement: "[REDACTED_UUID]"},
+	}
+}
+
+// Redactor applies redaction patterns to request bodies, either masking
+// matches or, with a vault, swapping them for reversible tokens.
+type Redactor struct {
+	patterns []RedactionPattern
+	locales  map[string][]RedactionPattern
+	vault    PIIVault
+	counter  func(pattern string, matches int)
+}
+
+// NewRedactor builds a Redactor. vault and counter may be nil; counter is
+// called with the number of matches per pattern for each redacted body.
+func NewRedactor(patterns []RedactionPattern, vault PIIVault, counter func(pattern string, matches int)) *Redactor {
+	return &Redactor{patterns: patterns, vault: vault, counter: counter}
+}
+
+// SetLocalePatterns adds patterns keyed by language code that apply, ahead
+// of the others, to bodies DetectLanguage finds in that language.
+func (rd *Redactor) SetLocalePatterns(locales map[string][]RedactionPattern) {
+	rd.locales = locales
+}
+
+// RedactionFinding is what one pattern removed from a body: how many
+// values, and their byte offsets in the body before redaction.
+type RedactionFinding struct {
+	PIIType string   `json:"pii_type"`
+	Count   int      `json:"count"`
+	Offsets [][2]int `json:"offsets"`
+}
+
+// Redact returns the body with every pattern match removed and whether
+// anything changed.
+func (rd *Redactor) Redact(body string) (string, bool) {
+	redacted, _ := rd.RedactReport(body)
+	return redacted, redacted != body
+}
+
+// RedactReport is Redact that also returns what each pattern removed, in
+// the order the patterns ran.
+func (rd *Redactor) RedactReport(body string) (string, []RedactionFinding) {
+	patterns := rd.patterns
+	if len(rd.locales) > 0 {
+		if locale := rd.locales[DetectLanguage(body)]; len(locale) > 0 {
+			patterns = append(append([]RedactionPattern(nil), locale...), patterns...)
+		}
+	}
+	var findings []RedactionFinding
+	var spans offsetMap
+	for _, p := range patterns {
+		finding := RedactionFinding{PIIType: p.Name}
+		var replaced []replacement
+		var b strings.Builder
+		last := 0
+		for _, loc := range p.Regex.FindAllStringSubmatchIndex(body, -1) {
+			value := body[loc[0]:loc[1]]
+			if p.Valid != nil && !p.Valid(value) {
+				continue
+			}
+			masked := ""
+			if rd.vault != nil {
+				if token, err := rd.vault.Tokenize(strings.ToUpper(p.Name), value); err == nil {
+					masked = token
+				}
+			}
+			if masked == "" {
+				masked = string(p.Regex.ExpandString(nil, p.Replacement, body, loc))
+			}
+			b.WriteString(body[last:loc[0]])
+			b.WriteString(masked)
+			last = loc[1]
+			replaced = append(replaced, replacement{start: loc[0], end: loc[1], length: len(masked)})
+			finding.Count++
+			finding.Offsets = append(finding.Offsets, [2]int{spans.original(loc[0], false), spans.original(loc[1], true)})
+		}
+		if finding.Count == 0 {
+			continue
+		}
+		b.WriteString(body[last:])
+		body = b.String()
+		spans = spans.replace(replaced)
+		findings = append(findings, finding)
+		if rd.counter != nil {
+			rd.counter(p.Name, finding.Count)
+		}
+	}
+	return body, findings
+}
+
+// span is a replaced range of a body being redacted, [start, end), and the
+// range of the original body it stands for.
+type span struct {
+	start, end         int
+	origStart, origEnd int
+}
+
+// replacement is a range of a body, [start, end), replaced by length bytes.
+type replacement struct {
+	start, end, length int
+}
+
+// offsetMap holds the replaced ranges of a body, in order, so positions in
+// it can be traced back to the original.
+type offsetMap []span
+
+// original returns the offset in the original body of pos. A position
+// inside a replacement maps to the start of what it replaced, or to its
+// end for the end of a range.
+func (m offsetMap) original(pos int, end bool) int {
+	o := pos
+	for _, s := range m {
+		switch {
+		case pos >= s.end:
+			o = pos - s.end + s.origEnd
+		case pos <= s.start:
+			return o
+		case end:
+			return s.origEnd
+		default:
+			return s.origStart
+		}
+	}
+	return o
+}
+
+// replace returns the map after the ranges of one pass, in order and
+// before the pass, were replaced. They swallow earlier ones they overlap.
+func (m offsetMap) replace(replaced []replacement) offsetMap {
+	var next offsetMap
+	shift, i := 0, 0
+	for _, r := range replaced {
+		for ; i < len(m) && m[i].end <= r.start; i++ {
+			next = append(next, span{m[i].start + shift, m[i].end + shift, m[i].origStart, m[i].origEnd})
+		}
+		for ; i < len(m) && m[i].start < r.end; i++ {
+		}
+		next = append(next, span{r.start + shift, r.start + shift + r.length, m.original(r.start, false), m.original(r.end, true)})
+		shift += r.length - (r.end - r.start)
+	}
+	for ; i < len(m); i++ {
+		next = append(next, span{m[i].start + shift, m[i].end + shift, m[i].origStart, m[i].origEnd})
+	}
+	return next
+}
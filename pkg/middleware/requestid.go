@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+// RequestIDHeader carries the ID of a proxied request. It is set on the
+// response and on the request forwarded upstream, and stored with the
+// interaction, so the three can be matched up.
+const RequestIDHeader = "X-Vantage-Request-ID"
+
+// RequestIDMiddleware tags each request with an ID, taken from id when it
+// returns one (e.g. chi's middleware.GetReqID) and generated otherwise. Any
+// ID the client sent in RequestIDHeader is replaced.
+func RequestIDMiddleware(id func(*http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			reqID := ""
+			if id != nil {
+				reqID = id(r)
+			}
+			if reqID == "" {
+				reqID = newRequestID()
+			}
+			r.Header.Set(RequestIDHeader, reqID)
+			w.Header().Set(RequestIDHeader, reqID)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func newRequestID() string {
+	raw := make([]byte, 12)
+	rand.Read(raw)
+	return hex.EncodeToString(raw)
+}
@@ -0,0 +1,104 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// ResponsePIIHeader tells the client what was done about PII found in the
+// generated text: "redacted" or "flagged". Blocked responses carry the
+// RESPONSE_PII error code instead.
+const ResponsePIIHeader = "X-Vantage-Response-PII"
+
+// ResponsePIIOptions configures ResponsePIIMiddleware. Patterns find the
+// PII; Action is "redact" to mask it, "block" to withhold the response or
+// "flag" to only record it.
+type ResponsePIIOptions struct {
+	Patterns []RedactionPattern
+	Action   string
+}
+
+// ResponsePIIFinding is how many values of one PII type the generated text
+// of a response held.
+type ResponsePIIFinding struct {
+	PIIType string `json:"pii_type"`
+	Count   int    `json:"count"`
+}
+
+// ResponsePIIReport is what ResponsePIIMiddleware found in a response and
+// what it did about it.
+type ResponsePIIReport struct {
+	Action   string               `json:"action"`
+	Findings []ResponsePIIFinding `json:"findings"`
+}
+
+// ResponsePIIMiddleware scans the generated text of successful
+// non-streaming responses for PII the model made up or repeated, which
+// prompt redaction cannot catch, and redacts, blocks or flags it. The
+// report goes to the audit record.
+func ResponsePIIMiddleware(opts ResponsePIIOptions) func(http.Handler) http.Handler {
+	redactor := NewRedactor(opts.Patterns, nil, nil)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost || len(opts.Patterns) == 0 || isStreaming(peekBody(r)) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// Ask for an uncompressed body so it can be read and rewritten
+			r.Header.Del("Accept-Encoding")
+			buf := &bufferedResponse{ResponseWriter: w, statusCode: http.StatusOK}
+			next.ServeHTTP(buf, r)
+
+			body := buf.body.Bytes()
+			if buf.statusCode != http.StatusOK {
+				w.WriteHeader(buf.statusCode)
+				w.Write(body)
+				return
+			}
+			counts := map[string]int{}
+			redacted, ok := rewriteResponseText(body, func(text string) string {
+				masked, findings := redactor.RedactReport(text)
+				for _, f := range findings {
+					counts[f.PIIType] += f.Count
+				}
+				return masked
+			})
+			if !ok || len(counts) == 0 {
+				w.WriteHeader(buf.statusCode)
+				w.Write(body)
+				return
+			}
+
+			report := ResponsePIIReport{Action: opts.Action}
+			for _, p := range opts.Patterns {
+				if n := counts[p.Name]; n > 0 {
+					report.Findings = append(report.Findings, ResponsePIIFinding{PIIType: p.Name, Count: n})
+				}
+			}
+			record, _ := json.Marshal(report)
+			signals(r).responsePII = string(record)
+
+			switch opts.Action {
+			case "block":
+				markBlocked(r)
+				w.Header().Del("Content-Length")
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusBadGateway)
+				json.NewEncoder(w).Encode(map[string]string{
+					"error": "The response was withheld because it contained personal data",
+					"code":  "RESPONSE_PII",
+				})
+				return
+			case "redact":
+				body = redacted
+				w.Header().Del("Content-Length")
+				w.Header().Set(ResponsePIIHeader, "redacted")
+			default:
+				w.Header().Set(ResponsePIIHeader, "flagged")
+			}
+			w.WriteHeader(buf.statusCode)
+			w.Write(body)
+		})
+	}
+}
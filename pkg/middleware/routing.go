@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+)
+
+// ModelRouter picks the model a request is actually sent to.
+type ModelRouter interface {
+	Route(userID, model string) (target, rule string, ok bool)
+}
+
+// ModelRoutingMiddleware rewrites the "model" field of JSON requests. The
+// served model and the rule that chose it are returned to the client in
+// X-Vantage-Routed-Model and X-Vantage-Routing-Rule.
+func ModelRoutingMiddleware(router ModelRouter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			model := requestModel(r)
+			if model == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			target, rule, ok := router.Route(UserID(r), model)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			var fields map[string]json.RawMessage
+			if err := json.Unmarshal(peekBody(r), &fields); err != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			fields["model"], _ = json.Marshal(target)
+			body, _ := json.Marshal(fields)
+			r.Body = io.NopCloser(bytes.NewBuffer(body))
+			r.ContentLength = int64(len(body))
+
+			w.Header().Set("X-Vantage-Routed-Model", target)
+			w.Header().Set("X-Vantage-Routing-Rule", rule)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,210 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// ResponseSuppressedHeader tells the client that the generated text of the
+// response was replaced by the fallback.
+const ResponseSuppressedHeader = "X-Vantage-Response-Suppressed"
+
+// ResponseSafetyOptions configures ResponseSafetyMiddleware. Classify scores
+// generated text from 0 (unsafe) to 1 (safe); responses scoring below
+// Threshold have their text replaced by Fallback.
+type ResponseSafetyOptions struct {
+	Classify  func(text string) float64
+	Threshold float64
+	Fallback  string
+}
+
+// ResponseSafetyMiddleware classifies successful non-streaming responses
+// before they are returned and suppresses unsafe ones. The response keeps
+// its shape, so clients parse the fallback like any other completion.
+func ResponseSafetyMiddleware(opts ResponseSafetyOptions) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost || opts.Classify == nil || isStreaming(peekBody(r)) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// Ask for an uncompressed body so it can be read and rewritten
+			r.Header.Del("Accept-Encoding")
+			buf := &bufferedResponse{ResponseWriter: w, statusCode: http.StatusOK}
+			next.ServeHTTP(buf, r)
+
+			body := buf.body.Bytes()
+			if buf.statusCode == http.StatusOK {
+				if text := ResponseText(body); text != "" {
+					score := opts.Classify(text)
+					signals(r).responseSafety = &score
+					if score < opts.Threshold {
+						if replaced, ok := replaceResponseText(body, opts.Fallback); ok {
+							body = replaced
+							w.Header().Del("Content-Length")
+							w.Header().Set(ResponseSuppressedHeader, "true")
+						}
+					}
+				}
+			}
+			w.WriteHeader(buf.statusCode)
+			w.Write(body)
+		})
+	}
+}
+
+// bufferedResponse holds the status and body back until the response has
+// been classified. Headers go straight to the wrapped writer.
+type bufferedResponse struct {
+	http.ResponseWriter
+	body       bytes.Buffer
+	statusCode int
+}
+
+func (b *bufferedResponse) WriteHeader(code int) {
+	b.statusCode = code
+}
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	return b.body.Write(p)
+}
+
+// responseTextPaths are where completion responses carry generated text:
+// Cohere v1 chat, Ollama generate, llama.cpp and Anthropic on Bedrock, Cohere
+// v2 and Ollama chat, Cohere generate, OpenAI-compatible chat and
+// completions, Gemini, and Bedrock Converse. A path ends at a string or at
+// a list of blocks with a "text" field.
+var responseTextPaths = [][]string{
+	{"text"},
+	{"response"},
+	{"content"},
+	{"message", "content"},
+	{"generations", "*", "text"},
+	{"choices", "*", "message", "content"},
+	{"choices", "*", "text"},
+	{"candidates", "*", "content", "parts"},
+	{"output", "message", "content"},
+}
+
+// ResponseText returns the generated text of a JSON completion response,
+// or "" when the body is not one.
+func ResponseText(body []byte) string {
+	doc, ok := decodeResponse(body)
+	if !ok {
+		return ""
+	}
+	var parts []string
+	for _, path := range responseTextPaths {
+		walkText(doc, path, func(s string) string {
+			if s != "" {
+				parts = append(parts, s)
+			}
+			return s
+		})
+	}
+	return strings.Join(parts, "\n")
+}
+
+// streamTextPaths are where streamed events carry their piece of the
+// generated text beyond the responseTextPaths, which the Ollama, llama.cpp,
+// Gemini and Cohere v1 events share with whole responses: OpenAI-compatible
+// and Cohere v2 deltas.
+var streamTextPaths = [][]string{
+	{"choices", "*", "delta", "content"},
+	{"delta", "message", "content", "text"},
+}
+
+// StreamText returns the generated text of a streamed response, NDJSON or
+// server-sent events, joined from the text of each event.
+func StreamText(body []byte) string {
+	var b strings.Builder
+	for _, line := range bytes.Split(body, []byte("\n")) {
+		line = bytes.TrimSpace(bytes.TrimPrefix(bytes.TrimSpace(line), []byte("data:")))
+		doc, ok := decodeResponse(line)
+		if !ok {
+			continue
+		}
+		for _, paths := range [][][]string{responseTextPaths, streamTextPaths} {
+			for _, path := range paths {
+				walkText(doc, path, func(s string) string {
+					b.WriteString(s)
+					return s
+				})
+			}
+		}
+	}
+	return b.String()
+}
+
+// replaceResponseText returns body with every generated text replaced by
+// text, and false when there was none to replace.
+func replaceResponseText(body []byte, text string) ([]byte, bool) {
+	return rewriteResponseText(body, func(string) string { return text })
+}
+
+// rewriteResponseText returns body with every generated text replaced by
+// what rewrite returns for it, and false when there was none.
+func rewriteResponseText(body []byte, rewrite func(string) string) ([]byte, bool) {
+	doc, ok := decodeResponse(body)
+	if !ok {
+		return nil, false
+	}
+	found := false
+	for _, path := range responseTextPaths {
+		walkText(doc, path, func(s string) string {
+			found = true
+			return rewrite(s)
+		})
+	}
+	if !found {
+		return nil, false
+	}
+	out, err := json.Marshal(doc)
+	return out, err == nil
+}
+
+func decodeResponse(body []byte) (map[string]interface{}, bool) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var doc map[string]interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, false
+	}
+	return doc, true
+}
+
+// walkText calls visit on the text at path below v and stores what it
+// returns in its place. "*" steps into every element of a list.
+func walkText(v interface{}, path []string, visit func(string) string) interface{} {
+	if len(path) == 0 {
+		switch t := v.(type) {
+		case string:
+			return visit(t)
+		case []interface{}:
+			for _, e := range t {
+				if block, ok := e.(map[string]interface{}); ok {
+					if s, ok := block["text"].(string); ok {
+						block["text"] = visit(s)
+					}
+				}
+			}
+		}
+		return v
+	}
+	switch t := v.(type) {
+	case map[string]interface{}:
+		if child, ok := t[path[0]]; ok {
+			t[path[0]] = walkText(child, path[1:], visit)
+		}
+	case []interface{}:
+		if path[0] == "*" {
+			for i := range t {
+				t[i] = walkText(t[i], path[1:], visit)
+			}
+		}
+	}
+	return v
+}
@@ -0,0 +1,139 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// Verdict records the governance decisions that depended on more than the
+// request itself, so the audit trail shows why a request was treated the
+// way it was.
+type Verdict struct {
+	Schedules []ScheduleDecision `json:"schedules,omitempty"`
+}
+
+// ScheduleDecision is one schedule rule that applied to a request, with the
+// caller's local time it was evaluated at.
+type ScheduleDecision struct {
+	Rule      string `json:"rule"`
+	Window    string `json:"window"`
+	Timezone  string `json:"timezone"`
+	LocalTime string `json:"local_time"`
+	Action    string `json:"action"`
+}
+
+// TimeWindow is a daily time range on the given weekdays; no days means
+// every day. A window ending before it starts wraps past midnight and
+// belongs to the day it started on.
+type TimeWindow struct {
+	Days       []time.Weekday
+	Start, End time.Duration
+}
+
+// Contains reports whether t, in its own location, falls inside the window.
+func (w TimeWindow) Contains(t time.Time) bool {
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	offset := t.Sub(midnight)
+	day := t.Weekday()
+	if w.Start <= w.End {
+		return w.onDay(day) && offset >= w.Start && offset < w.End
+	}
+	if offset >= w.Start {
+		return w.onDay(day)
+	}
+	return offset < w.End && w.onDay((day+6)%7)
+}
+
+func (w TimeWindow) onDay(d time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, day := range w.Days {
+		if day == d {
+			return true
+		}
+	}
+	return false
+}
+
+// ScheduleRule applies while the caller's local time is inside its window,
+// or outside it when Outside is set. DenyModels are rejected while the rule
+// applies; SyncSafety classifies the prompt before it is forwarded.
+type ScheduleRule struct {
+	Name       string
+	WindowName string
+	Window     TimeWindow
+	Outside    bool
+	DenyModels []string
+	SyncSafety bool
+}
+
+// ScheduleOptions configures ScheduleMiddleware. Location returns the
+// timezone a user's schedule is evaluated in. Classify and Threshold are
+// used by rules with SyncSafety, as in TrustOptions.
+type ScheduleOptions struct {
+	Rules     []ScheduleRule
+	Location  func(userID string) *time.Location
+	Classify  func(body []byte) float64
+	Threshold float64
+}
+
+// ScheduleMiddleware enforces time-of-day policies and records each rule
+// that applied in the request's verdict.
+func ScheduleMiddleware(opts ScheduleOptions) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost {
+				next.ServeHTTP(w, r)
+				return
+			}
+			loc := opts.Location(UserID(r))
+			now := time.Now().In(loc)
+			model := requestModel(r)
+
+			var verdict Verdict
+			decide := func(rule ScheduleRule, action string) {
+				verdict.Schedules = append(verdict.Schedules, ScheduleDecision{
+					Rule:      rule.Name,
+					Window:    rule.WindowName,
+					Timezone:  loc.String(),
+					LocalTime: now.Format("Mon 15:04"),
+					Action:    action,
+				})
+				record, _ := json.Marshal(verdict)
+				signals(r).verdict = string(record)
+			}
+
+			for _, rule := range opts.Rules {
+				if rule.Window.Contains(now) == rule.Outside {
+					continue
+				}
+				if model != "" && contains(rule.DenyModels, model) {
+					decide(rule, "blocked")
+					denySchedule(w, r, "Model "+model+" is not available at this time", "MODEL_OUT_OF_HOURS")
+					return
+				}
+				if rule.SyncSafety && opts.Classify != nil {
+					if opts.Classify(peekBody(r)) < opts.Threshold {
+						decide(rule, "blocked")
+						denySchedule(w, r, "Prompt failed the safety check", "UNSAFE_CONTENT")
+						return
+					}
+					decide(rule, "safety_checked")
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func denySchedule(w http.ResponseWriter, r *http.Request, message, code string) {
+	markBlocked(r)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusForbidden)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error": message,
+		"code":  code,
+	})
+}
@@ -0,0 +1,357 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxSchemaProblems caps the problems listed in one rejection.
+const maxSchemaProblems = 10
+
+// Schema is the subset of JSON Schema that request validation needs: type,
+// properties, required, additionalProperties, items, enum, anyOf, the
+// numeric bounds and the length bounds of strings and arrays. Other
+// keywords are ignored, as JSON Schema does with unknown ones. The literal
+// schemas true and false accept and reject everything.
+type Schema struct {
+	Type                 schemaTypes        `json:"type"`
+	Properties           map[string]*Schema `json:"properties"`
+	Required             []string           `json:"required"`
+	AdditionalProperties *Schema            `json:"additionalProperties"`
+	Items                *Schema            `json:"items"`
+	Enum                 []interface{}      `json:"enum"`
+	AnyOf                []*Schema          `json:"anyOf"`
+	Minimum              *float64           `json:"minimum"`
+	Maximum              *float64           `json:"maximum"`
+	MinLength            *int               `json:"minLength"`
+	MaxLength            *int               `json:"maxLength"`
+	MinItems             *int               `json:"minItems"`
+	MaxItems             *int               `json:"maxItems"`
+
+	reject bool
+}
+
+// ParseSchema parses a JSON Schema document.
+func ParseSchema(data []byte) (*Schema, error) {
+	var s Schema
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("schema: %w", err)
+	}
+	return &s, nil
+}
+
+func (s *Schema) UnmarshalJSON(data []byte) error {
+	switch string(bytes.TrimSpace(data)) {
+	case "true":
+		*s = Schema{}
+		return nil
+	case "false":
+		*s = Schema{reject: true}
+		return nil
+	}
+	type plain Schema
+	if err := json.Unmarshal(data, (*plain)(s)); err != nil {
+		return err
+	}
+	for _, t := range s.Type {
+		switch t {
+		case "object", "array", "string", "number", "integer", "boolean", "null":
+		default:
+			return fmt.Errorf("unknown type %q", t)
+		}
+	}
+	return nil
+}
+
+// schemaTypes is the "type" keyword, a single type or a list of them.
+type schemaTypes []string
+
+func (t *schemaTypes) UnmarshalJSON(data []byte) error {
+	var one string
+	if json.Unmarshal(data, &one) == nil {
+		*t = schemaTypes{one}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return fmt.Errorf("type must be a string or a list of strings")
+	}
+	*t = many
+	return nil
+}
+
+// Validate returns what is wrong with a decoded JSON value, one entry per
+// problem prefixed with the location of the value, e.g.
+// "messages[0].role: must be one of ...". It returns nil for a valid value.
+func (s *Schema) Validate(v interface{}) []string {
+	var problems []string
+	s.validate("body", v, &problems)
+	return problems
+}
+
+func (s *Schema) validate(at string, v interface{}, problems *[]string) {
+	report := func(format string, args ...interface{}) {
+		if len(*problems) < maxSchemaProblems {
+			*problems = append(*problems, at+": "+fmt.Sprintf(format, args...))
+		}
+	}
+	if s.reject {
+		report("is not allowed")
+		return
+	}
+	if len(s.Type) > 0 && !s.hasType(v) {
+		report("must be %s, got %s", strings.Join(s.Type, " or "), jsonType(v))
+		return
+	}
+	if len(s.Enum) > 0 && !s.inEnum(v) {
+		allowed := make([]string, len(s.Enum))
+		for i, e := range s.Enum {
+			b, _ := json.Marshal(e)
+			allowed[i] = string(b)
+		}
+		report("must be one of %s", strings.Join(allowed, ", "))
+	}
+	if len(s.AnyOf) > 0 {
+		matched := false
+		for _, alt := range s.AnyOf {
+			var altProblems []string
+			alt.validate(at, v, &altProblems)
+			if len(altProblems) == 0 {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			report("does not match any of the allowed forms")
+		}
+	}
+
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				report("missing required field %q", name)
+			}
+		}
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if prop, ok := s.Properties[name]; ok {
+				prop.validate(at+"."+name, v[name], problems)
+			} else if s.AdditionalProperties != nil {
+				s.AdditionalProperties.validate(at+"."+name, v[name], problems)
+			}
+		}
+	case []interface{}:
+		if s.MinItems != nil && len(v) < *s.MinItems {
+			report("must have at least %d items", *s.MinItems)
+		}
+		if s.MaxItems != nil && len(v) > *s.MaxItems {
+			report("must have at most %d items", *s.MaxItems)
+		}
+		if s.Items != nil {
+			for i, item := range v {
+				s.Items.validate(at+"["+strconv.Itoa(i)+"]", item, problems)
+			}
+		}
+	case string:
+		n := len([]rune(v))
+		if s.MinLength != nil && n < *s.MinLength {
+			report("must be at least %d characters", *s.MinLength)
+		}
+		if s.MaxLength != nil && n > *s.MaxLength {
+			report("must be at most %d characters", *s.MaxLength)
+		}
+	case float64:
+		if s.Minimum != nil && v < *s.Minimum {
+			report("must be at least %v", *s.Minimum)
+		}
+		if s.Maximum != nil && v > *s.Maximum {
+			report("must be at most %v", *s.Maximum)
+		}
+	}
+}
+
+func (s *Schema) hasType(v interface{}) bool {
+	actual := jsonType(v)
+	for _, t := range s.Type {
+		if t == actual || t == "number" && actual == "integer" {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Schema) inEnum(v interface{}) bool {
+	got, _ := json.Marshal(v)
+	for _, e := range s.Enum {
+		want, _ := json.Marshal(e)
+		if bytes.Equal(got, want) {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonType names the JSON Schema type of a value decoded by encoding/json.
+func jsonType(v interface{}) string {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	case bool:
+		return "boolean"
+	}
+	return "null"
+}
+
+// RequestSchema is the schema the JSON bodies of requests to Path must
+// match. Path is compared exactly, so /v1/embed does not cover /v1/embed-jobs.
+type RequestSchema struct {
+	Path   string
+	Schema *Schema
+}
+
+// builtinSchemas cover the Cohere chat and embed endpoints. They check
+// the fields that are most often malformed and leave the rest to the
+// provider, so new optional fields pass through.
+var builtinSchemas = map[string]string{
+	"/v1/chat": `{
+		"type": "object",
+		"required": ["message"],
+		"properties": {
+			"message": {"type": "string"},
+			"model": {"type": "string"},
+			"preamble": {"type": "string"},
+			"stream": {"type": "boolean"},
+			"temperature": {"type": "number", "minimum": 0},
+			"max_tokens": {"type": "integer", "minimum": 1},
+			"chat_history": {
+				"type": "array",
+				"items": {
+					"type": "object",
+					"required": ["role"],
+					"properties": {"role": {"enum": ["USER", "CHATBOT", "SYSTEM", "TOOL"]}}
+				}
+			}
+		}
+	}`,
+	"/v2/chat": `{
+		"type": "object",
+		"required": ["model", "messages"],
+		"properties": {
+			"model": {"type": "string", "minLength": 1},
+			"stream": {"type": "boolean"},
+			"temperature": {"type": "number", "minimum": 0},
+			"max_tokens": {"type": "integer", "minimum": 1},
+			"messages": {
+				"type": "array",
+				"minItems": 1,
+				"items": {
+					"type": "object",
+					"required": ["role"],
+					"properties": {"role": {"enum": ["system", "user", "assistant", "tool"]}}
+				}
+			}
+		}
+	}`,
+	"/v1/embed": `{
+		"type": "object",
+		"properties": {
+			"model": {"type": "string"},
+			"texts": {"type": "array", "maxItems": 96, "items": {"type": "string"}},
+			"input_type": {"enum": ["search_document", "search_query", "classification", "clustering", "image"]},
+			"truncate": {"enum": ["NONE", "START", "END"]}
+		}
+	}`,
+	"/v2/embed": `{
+		"type": "object",
+		"required": ["model"],
+		"properties": {
+			"model": {"type": "string", "minLength": 1},
+			"texts": {"type": "array", "maxItems": 96, "items": {"type": "string"}},
+			"input_type": {"enum": ["search_document", "search_query", "classification", "clustering", "image"]},
+			"truncate": {"enum": ["NONE", "START", "END"]}
+		}
+	}`,
+}
+
+// BuiltinRequestSchemas returns the schemas of /v1/chat, /v2/chat,
+// /v1/embed and /v2/embed.
+func BuiltinRequestSchemas() []RequestSchema {
+	paths := make([]string, 0, len(builtinSchemas))
+	for path := range builtinSchemas {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	schemas := make([]RequestSchema, 0, len(paths))
+	for _, path := range paths {
+		s, err := ParseSchema([]byte(builtinSchemas[path]))
+		if err != nil {
+			panic("middleware: builtin schema for " + path + ": " + err.Error())
+		}
+		schemas = append(schemas, RequestSchema{Path: path, Schema: s})
+	}
+	return schemas
+}
+
+// SchemaValidationMiddleware rejects JSON bodies that do not match the
+// schema of their path with 400 and code INVALID_REQUEST_BODY, listing
+// each problem under "details", before they cost an upstream call. The
+// first schema for a path wins; requests to other paths, without a body
+// to check or with a multipart body pass unchanged.
+func SchemaValidationMiddleware(schemas []RequestSchema) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var schema *Schema
+			for _, rs := range schemas {
+				if rs.Path == r.URL.Path {
+					schema = rs.Schema
+					break
+				}
+			}
+			if schema == nil || r.Method != http.MethodPost || strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			var body interface{}
+			if err := json.Unmarshal(peekBody(r), &body); err != nil {
+				denySchema(w, "Request body is not valid JSON", []string{"body: " + err.Error()})
+				return
+			}
+			if problems := schema.Validate(body); len(problems) > 0 {
+				denySchema(w, "Request body does not match the schema for "+r.URL.Path, problems)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func denySchema(w http.ResponseWriter, message string, details []string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":   message,
+		"code":    "INVALID_REQUEST_BODY",
+		"details": details,
+	})
+}
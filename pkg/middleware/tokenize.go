@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"regexp"
+)
+
+// PIIVault swaps PII values for deterministic, reversible tokens.
+type PIIVault interface {
+	Tokenize(kind, value string) (string, error)
+	Detokenize(token string) (string, bool)
+}
+
+// Tokens only use the letters a-p so the phone and UUID patterns can never match inside one.
+var tokenRegex = regexp.MustCompile(`\[PII_[A-Z_]+_[a-p]{16}\]`)
+
+// detokenizePII restores original values for any tokens the vault knows about.
+func detokenizePII(body []byte, vault PIIVault) []byte {
+	return tokenRegex.ReplaceAllFunc(body, func(token []byte) []byte {
+		if value, ok := vault.Detokenize(string(token)); ok {
+			return []byte(value)
+		}
+		return token
+	})
+}
+
+// bufferedResponseWriter holds the upstream response so it can be rewritten before reaching the client.
+type bufferedResponseWriter struct {
+	http.ResponseWriter
+	body       bytes.Buffer
+	statusCode int
+}
+
+func (bw *bufferedResponseWriter) WriteHeader(code int) {
+	bw.statusCode = code
+}
+
+func (bw *bufferedResponseWriter) Write(b []byte) (int, error) {
+	return bw.body.Write(b)
+}
+
+// flush writes the rewritten body to the underlying writer.
+func (bw *bufferedResponseWriter) flush(body []byte) {
+	bw.ResponseWriter.Header().Del("Content-Length")
+	bw.ResponseWriter.WriteHeader(bw.statusCode)
+	bw.ResponseWriter.Write(body)
+}
@@ -0,0 +1,243 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// TransformedHeader names, on the response, the transforms that rewrote
+// the request.
+const TransformedHeader = "X-Vantage-Transformed"
+
+// RequestTransform is an operator rewrite of the requests under Paths
+// (path prefixes; empty applies it everywhere) before they reach the
+// provider. SystemPrompt is put ahead of the request's own system prompt
+// and Instructions after its latest user message, in Cohere v1 and v2,
+// OpenAI-compatible and Gemini chat requests. MaxParams lowers numeric
+// parameters above their limit and RemoveParams deletes parameters; nested
+// ones are named with dots, such as generationConfig.temperature.
+type RequestTransform struct {
+	Name         string
+	Paths        []string
+	SystemPrompt string
+	Instructions string
+	MaxParams    map[string]float64
+	RemoveParams []string
+}
+
+// Transformation records what TransformMiddleware changed in a request.
+// The audit log keeps the request body as the client sent it.
+type Transformation struct {
+	Rules        []string               `json:"rules"`
+	SystemPrompt bool                   `json:"system_prompt,omitempty"`
+	Instructions bool                   `json:"instructions,omitempty"`
+	Capped       map[string]CappedParam `json:"capped,omitempty"`
+	Removed      []string               `json:"removed,omitempty"`
+}
+
+// CappedParam is a parameter lowered to its limit.
+type CappedParam struct {
+	From float64 `json:"from"`
+	To   float64 `json:"to"`
+}
+
+// TransformMiddleware applies every transform matching a JSON request's
+// path, in order, and records what changed with the interaction.
+func TransformMiddleware(transforms []RequestTransform) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost || strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+				next.ServeHTTP(w, r)
+				return
+			}
+			var matched []RequestTransform
+			for _, t := range transforms {
+				if len(t.Paths) == 0 || slices.ContainsFunc(t.Paths, func(p string) bool { return strings.HasPrefix(r.URL.Path, p) }) {
+					matched = append(matched, t)
+				}
+			}
+			if len(matched) == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			dec := json.NewDecoder(bytes.NewReader(peekBody(r)))
+			dec.UseNumber()
+			var doc map[string]interface{}
+			if dec.Decode(&doc) != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			var t Transformation
+			for _, transform := range matched {
+				if transform.apply(doc, &t) {
+					t.Rules = append(t.Rules, transform.Name)
+				}
+			}
+			if len(t.Rules) == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+			body, err := json.Marshal(doc)
+			if err != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			record, _ := json.Marshal(t)
+			signals(r).transformation = string(record)
+			w.Header().Set(TransformedHeader, strings.Join(t.Rules, ","))
+			r.Body = io.NopCloser(bytes.NewBuffer(body))
+			r.ContentLength = int64(len(body))
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// apply rewrites doc and adds the changes to t, reporting whether there
+// were any.
+func (rt RequestTransform) apply(doc map[string]interface{}, t *Transformation) bool {
+	changed := false
+	if rt.SystemPrompt != "" && prependSystemPrompt(doc, rt.SystemPrompt) {
+		t.SystemPrompt, changed = true, true
+	}
+	if rt.Instructions != "" && appendInstructions(doc, rt.Instructions) {
+		t.Instructions, changed = true, true
+	}
+	for name, limit := range rt.MaxParams {
+		parent, key, ok := paramParent(doc, name)
+		if !ok {
+			continue
+		}
+		n, isNumber := parent[key].(json.Number)
+		if !isNumber {
+			continue
+		}
+		if v, err := n.Float64(); err == nil && v > limit {
+			parent[key] = json.Number(strconv.FormatFloat(limit, 'f', -1, 64))
+			if t.Capped == nil {
+				t.Capped = map[string]CappedParam{}
+			}
+			t.Capped[name] = CappedParam{From: v, To: limit}
+			changed = true
+		}
+	}
+	for _, name := range rt.RemoveParams {
+		if parent, key, ok := paramParent(doc, name); ok {
+			if _, present := parent[key]; present {
+				delete(parent, key)
+				t.Removed = append(t.Removed, name)
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+// paramParent returns the object holding the dotted parameter name and the
+// key within it.
+func paramParent(doc map[string]interface{}, name string) (map[string]interface{}, string, bool) {
+	parts := strings.Split(name, ".")
+	for _, p := range parts[:len(parts)-1] {
+		child, ok := doc[p].(map[string]interface{})
+		if !ok {
+			return nil, "", false
+		}
+		doc = child
+	}
+	return doc, parts[len(parts)-1], true
+}
+
+// prependSystemPrompt puts prompt ahead of the request's system prompt:
+// Cohere v1 "preamble", a leading system turn of "messages", or Gemini's
+// systemInstruction.
+func prependSystemPrompt(doc map[string]interface{}, prompt string) bool {
+	if _, ok := doc["message"]; ok {
+		if existing, _ := doc["preamble"].(string); existing != "" {
+			prompt += "\n\n" + existing
+		}
+		doc["preamble"] = prompt
+		return true
+	}
+	if messages, ok := doc["messages"].([]interface{}); ok {
+		if len(messages) > 0 {
+			if turn, ok := messages[0].(map[string]interface{}); ok && turn["role"] == "system" {
+				if content, ok := turn["content"].(string); ok {
+					turn["content"] = prompt + "\n\n" + content
+					return true
+				}
+			}
+		}
+		doc["messages"] = append([]interface{}{map[string]interface{}{"role": "system", "content": prompt}}, messages...)
+		return true
+	}
+	if _, ok := doc["contents"]; ok {
+		key := "systemInstruction"
+		if _, snake := doc["system_instruction"]; snake {
+			key = "system_instruction"
+		}
+		part := map[string]interface{}{"text": prompt}
+		if instruction, ok := doc[key].(map[string]interface{}); ok {
+			parts, _ := instruction["parts"].([]interface{})
+			instruction["parts"] = append([]interface{}{part}, parts...)
+		} else {
+			doc[key] = map[string]interface{}{"parts": []interface{}{part}}
+		}
+		return true
+	}
+	return false
+}
+
+// appendInstructions adds text after the latest user message: Cohere v1
+// "message", the last user turn of "messages" or of Gemini "contents".
+func appendInstructions(doc map[string]interface{}, text string) bool {
+	if message, ok := doc["message"].(string); ok {
+		doc["message"] = message + "\n\n" + text
+		return true
+	}
+	if messages, ok := doc["messages"].([]interface{}); ok {
+		turn := lastTurn(messages, "user")
+		if turn == nil {
+			return false
+		}
+		switch content := turn["content"].(type) {
+		case string:
+			turn["content"] = content + "\n\n" + text
+		case []interface{}:
+			turn["content"] = append(content, map[string]interface{}{"type": "text", "text": text})
+		default:
+			return false
+		}
+		return true
+	}
+	if contents, ok := doc["contents"].([]interface{}); ok {
+		turn := lastTurn(contents, "user")
+		if turn == nil {
+			return false
+		}
+		parts, _ := turn["parts"].([]interface{})
+		turn["parts"] = append(parts, map[string]interface{}{"text": text})
+		return true
+	}
+	return false
+}
+
+// lastTurn returns the last turn of role; Gemini turns without a role are
+// the user's.
+func lastTurn(turns []interface{}, role string) map[string]interface{} {
+	for i := len(turns) - 1; i >= 0; i-- {
+		turn, ok := turns[i].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if r, _ := turn["role"].(string); r == role || r == "" {
+			return turn
+		}
+	}
+	return nil
+}
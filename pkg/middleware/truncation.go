@@ -0,0 +1,155 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ContextWindows sizes model context windows in tokens. Requests whose
+// estimated prompt plus reserved output exceeds the window have their oldest
+// chat turns dropped before they are forwarded.
+type ContextWindows struct {
+	// Default applies to models missing from Models; zero disables
+	// truncation for them.
+	Default int
+	Models  map[string]int
+	// Reserve is kept free for the completion when the request has no
+	// max_tokens.
+	Reserve int
+}
+
+// For returns the context window of a model.
+func (c ContextWindows) For(model string) int {
+	if window, ok := c.Models[model]; ok {
+		return window
+	}
+	return c.Default
+}
+
+// Truncation records what TruncationMiddleware removed from a request.
+// Token counts are estimates, not provider tokenizer output.
+type Truncation struct {
+	Window          int           `json:"window"`
+	EstimatedTokens int           `json:"estimated_tokens"`
+	RemainingTokens int           `json:"remaining_tokens"`
+	Dropped         []DroppedTurn `json:"dropped"`
+}
+
+// DroppedTurn identifies one removed turn by its position in the original
+// request body, so it can be recovered from the logged request.
+type DroppedTurn struct {
+	Field           string `json:"field"`
+	Index           int    `json:"index"`
+	Role            string `json:"role,omitempty"`
+	EstimatedTokens int    `json:"estimated_tokens"`
+}
+
+// historyFields are the request fields holding chat turns, oldest first:
+// Cohere v1 "chat_history", Cohere v2 and OpenAI-style "messages", and
+// Gemini "contents".
+var historyFields = []string{"chat_history", "messages", "contents"}
+
+// estimateTokens approximates a token count at four bytes per token.
+func estimateTokens(b []byte) int {
+	return (len(b) + 3) / 4
+}
+
+// TruncationMiddleware drops the oldest chat turns from requests that would
+// overflow the model's context window. System turns and the latest turn are
+// always kept; if the request still does not fit it is forwarded as-is and
+// the provider decides.
+func TruncationMiddleware(windows ContextWindows) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost || strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+				next.ServeHTTP(w, r)
+				return
+			}
+			window := windows.For(requestModel(r))
+			if window <= 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			body := peekBody(r)
+			if t, truncated := truncateHistory(body, window, windows.Reserve); t != nil {
+				record, _ := json.Marshal(t)
+				signals(r).truncation = string(record)
+				w.Header().Set("X-Vantage-Truncated-Turns", strconv.Itoa(len(t.Dropped)))
+				r.Body = io.NopCloser(bytes.NewBuffer(truncated))
+				r.ContentLength = int64(len(truncated))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// truncateHistory removes turns from body, oldest first, until it fits the
+// window. It returns nil when nothing had to be dropped.
+func truncateHistory(body []byte, window, reserve int) (*Truncation, []byte) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, nil
+	}
+	var maxTokens int
+	if raw, ok := fields["max_tokens"]; ok && json.Unmarshal(raw, &maxTokens) == nil && maxTokens > 0 {
+		reserve = maxTokens
+	}
+
+	estimated := estimateTokens(body)
+	budget := window - reserve
+	if estimated <= budget {
+		return nil, nil
+	}
+
+	t := &Truncation{Window: window, EstimatedTokens: estimated}
+	remaining := estimated
+	for _, field := range historyFields {
+		var turns []json.RawMessage
+		if json.Unmarshal(fields[field], &turns) != nil || len(turns) == 0 {
+			continue
+		}
+		// chat_history excludes the current message; the other fields end with it
+		last := len(turns) - 1
+		if field == "chat_history" {
+			last = len(turns)
+		}
+
+		kept := make([]json.RawMessage, 0, len(turns))
+		for i, turn := range turns {
+			role := turnRole(turn)
+			if remaining <= budget || i >= last || strings.EqualFold(role, "system") {
+				kept = append(kept, turn)
+				continue
+			}
+			tokens := estimateTokens(turn)
+			remaining -= tokens
+			t.Dropped = append(t.Dropped, DroppedTurn{Field: field, Index: i, Role: role, EstimatedTokens: tokens})
+		}
+		if len(kept) < len(turns) {
+			fields[field], _ = json.Marshal(kept)
+		}
+	}
+	if len(t.Dropped) == 0 {
+		return nil, nil
+	}
+
+	truncated, err := json.Marshal(fields)
+	if err != nil {
+		return nil, nil
+	}
+	t.RemainingTokens = estimateTokens(truncated)
+	return t, truncated
+}
+
+func turnRole(turn json.RawMessage) string {
+	var t struct {
+		Role string `json:"role"`
+	}
+	json.Unmarshal(turn, &t)
+	return t.Role
+}
@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// TrustTierHeader tells the client, and AuditMiddleware, which trust tier
+// the request was handled under.
+const TrustTierHeader = "X-Vantage-Trust-Tier"
+
+// TrustResolver returns the trust tier a user is currently in.
+type TrustResolver interface {
+	TrustTier(userID string) string
+}
+
+// TrustOptions configures TrustMiddleware. Classify scores a prompt from 0
+// (unsafe) to 1 (safe); requests from tiers in SyncSafety are blocked when
+// the score is below Threshold.
+type TrustOptions struct {
+	SyncSafety map[string]bool
+	Classify   func(body []byte) float64
+	Threshold  float64
+}
+
+// TrustMiddleware tags each request with the caller's trust tier and, for
+// tiers that require it, classifies the prompt before it is forwarded.
+func TrustMiddleware(trust TrustResolver, opts TrustOptions) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tier := trust.TrustTier(UserID(r))
+			if tier == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			w.Header().Set(TrustTierHeader, tier)
+
+			if r.Method == http.MethodPost && opts.SyncSafety[tier] && opts.Classify != nil {
+				if score := opts.Classify(peekBody(r)); score < opts.Threshold {
+					markBlocked(r)
+					w.Header().Set("Content-Type", "application/json")
+					w.WriteHeader(http.StatusForbidden)
+					json.NewEncoder(w).Encode(map[string]string{
+						"error": "Prompt failed the safety check",
+						"code":  "UNSAFE_CONTENT",
+					})
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
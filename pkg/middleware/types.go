@@ -6,14 +6,50 @@ import (
 
 // Interaction represents a single request-response cycle captured by the proxy.
 type Interaction struct {
-	Timestamp    time.Time
-	UserID       string
-	Method       string
-	Path         string
-	RequestBody  []byte
-	ResponseBody []byte
-	StatusCode   int
-	Duration     time.Duration
-	IsBlocked    bool
-	IsRedacted   bool
+	Timestamp      time.Time
+	UserID         string
+	Method         string
+	Path           string
+	RequestBody    []byte
+	ResponseBody   []byte
+	StatusCode     int
+	Duration       time.Duration
+	IsBlocked      bool
+	IsRedacted     bool
+	Redactions     string
+	Template       string
+	CacheStatus    string
+	Dedup          string
+	Deprecation    string
+	Metadata       string
+	RoutedModel    string
+	Conversation   string
+	Session        string
+	Project        string
+	BudgetExceeded string
+	Truncation     string
+	Transformation string
+	TrustTier      string
+	Verdict        string
+	Headers        string
+	TraceID        string
+	// ResponseSafety is set when the response was classified before it
+	// was returned
+	ResponseSafety *float64
+	// ResponsePII is the JSON ResponsePIIReport of PII found in the
+	// generated text
+	ResponsePII string
+	// ParamOverrides is the JSON ParamOverrides of what the caller's
+	// parameter policy changed or rejected
+	ParamOverrides string
+	// Moderation is the JSON ModerationReport of a prompt moderated before
+	// it was forwarded
+	Moderation string
+	// RequestID is the ID RequestIDMiddleware gave the request
+	RequestID string
 }
+
+type contextKey string
+
+// TemplateKey carries the "name@version" of the prompt template that produced a request.
+const TemplateKey contextKey = "template"
@@ -0,0 +1,104 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// VirtualKeyPrefix starts every virtual key, which tells them apart from
+// JWTs and provider keys in the Authorization header.
+const VirtualKeyPrefix = "vk_"
+
+// touchInterval is how often the last use of a busy key is written back.
+const touchInterval = time.Minute
+
+// VirtualKey is the state of an issued key that authentication depends on.
+// Project, when set, is the project the key's requests are charged to.
+type VirtualKey struct {
+	ID        int64
+	UserID    string
+	Project   string
+	ExpiresAt *time.Time
+	Revoked   bool
+}
+
+// VirtualKeys looks up issued keys by the hash of the key and records
+// their use.
+type VirtualKeys interface {
+	LookupVirtualKey(hash string) (key VirtualKey, ok bool, err error)
+	TouchVirtualKey(id int64, at time.Time) error
+}
+
+// HashVirtualKey returns the hex SHA-256 of key, which is all that is kept
+// of it once issued.
+func HashVirtualKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// VirtualKeyAuthenticator identifies callers from an
+// "Authorization: Bearer vk_..." header, rejecting unknown, revoked and
+// expired keys. A key issued for a project sets ProjectHeader, replacing
+// whatever project the client asked for.
+type VirtualKeyAuthenticator struct {
+	keys VirtualKeys
+
+	mu      sync.Mutex
+	touched map[int64]time.Time
+}
+
+func NewVirtualKeyAuthenticator(keys VirtualKeys) *VirtualKeyAuthenticator {
+	return &VirtualKeyAuthenticator{keys: keys, touched: map[int64]time.Time{}}
+}
+
+func (a *VirtualKeyAuthenticator) Authenticate(r *http.Request) (string, bool, error) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || !strings.HasPrefix(token, VirtualKeyPrefix) {
+		return "", false, nil
+	}
+
+	key, found, err := a.keys.LookupVirtualKey(HashVirtualKey(token))
+	if err != nil {
+		return "", true, err
+	}
+	now := time.Now()
+	switch {
+	case !found:
+		return "", true, errors.New("unknown key")
+	case key.Revoked:
+		return "", true, errors.New("key revoked")
+	case key.ExpiresAt != nil && !now.Before(*key.ExpiresAt):
+		return "", true, errors.New("key expired")
+	}
+	a.touch(key.ID, now)
+	// The upstream gets the provider key instead
+	r.Header.Del("Authorization")
+	if key.Project != "" {
+		r.Header.Set(ProjectHeader, key.Project)
+	}
+	return key.UserID, true, nil
+}
+
+// touch records the use of a key at most once per touchInterval, so that
+// busy keys do not write on every request. A failed write is retried on
+// the next use.
+func (a *VirtualKeyAuthenticator) touch(id int64, now time.Time) {
+	a.mu.Lock()
+	if last, ok := a.touched[id]; ok && now.Sub(last) < touchInterval {
+		a.mu.Unlock()
+		return
+	}
+	a.touched[id] = now
+	a.mu.Unlock()
+
+	if err := a.keys.TouchVirtualKey(id, now); err != nil {
+		a.mu.Lock()
+		delete(a.touched, id)
+		a.mu.Unlock()
+	}
+}
@@ -0,0 +1,155 @@
+// Package bedrock adapts proxied requests for Amazon Bedrock Runtime and
+// signs them with AWS Signature Version 4.
+package bedrock
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/config"
+)
+
+// PathPrefix is where Bedrock traffic is mounted on the proxy. Native
+// Runtime paths under it are forwarded with the prefix removed, e.g.
+// /bedrock/model/anthropic.claude-3-haiku-20240307-v1:0/converse.
+const PathPrefix = "/bedrock"
+
+// Operations reachable as /bedrock/{operation} with the model ID taken from
+// the body's "model" field, so model routing and policy apply as they do
+// for Cohere.
+var Operations = []string{"converse", "converse-stream", "invoke", "invoke-with-response-stream"}
+
+// signingName is the SigV4 service name of the bedrock-runtime endpoint.
+const signingName = "bedrock"
+
+// forwardedHeaders are the only client headers sent to AWS. Everything
+// else is dropped so the signature covers a predictable set.
+var forwardedHeaders = []string{"Content-Type", "Accept"}
+
+// Provider signs requests with credentials from the default AWS chain.
+type Provider struct {
+	region  string
+	baseURL *url.URL
+	creds   aws.CredentialsProvider
+	signer  *v4.Signer
+}
+
+// New returns a Bedrock provider. Credentials come from the default AWS
+// chain: environment, shared config, web identity (IRSA), then ECS and EC2
+// roles; they are cached and refreshed before they expire. An empty region
+// falls back to AWS_REGION and an empty baseURL uses the regional
+// bedrock-runtime endpoint.
+func New(ctx context.Context, region, baseURL string) (*Provider, error) {
+	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+	if err != nil {
+		return nil, fmt.Errorf("load AWS config: %w", err)
+	}
+	if cfg.Region == "" {
+		return nil, errors.New("no AWS region configured")
+	}
+	if baseURL == "" {
+		baseURL = "https://bedrock-runtime." + cfg.Region + ".amazonaws.com"
+	}
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+	return &Provider{region: cfg.Region, baseURL: u, creds: cfg.Credentials, signer: v4.NewSigner()}, nil
+}
+
+func (p *Provider) Name() string { return "bedrock" }
+
+// CheckCredentials reports whether the default AWS chain yields
+// credentials to sign requests with.
+func (p *Provider) CheckCredentials(ctx context.Context) error {
+	_, err := p.creds.Retrieve(ctx)
+	return err
+}
+
+func (p *Provider) BaseURL() *url.URL { return p.baseURL }
+
+// Direct maps the proxy path onto the Runtime API and signs the request.
+// When credentials cannot be retrieved the request is sent unsigned and
+// AWS rejects it.
+func (p *Provider) Direct(req *http.Request) {
+	var body []byte
+	if req.Body != nil {
+		body, _ = io.ReadAll(req.Body)
+	}
+
+	path := strings.TrimPrefix(req.URL.Path, PathPrefix)
+	if op := strings.TrimPrefix(path, "/"); isOperation(op) {
+		body = modelPath(req, op, body)
+	} else {
+		req.URL.Path = path
+		req.URL.RawPath = ""
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.ContentLength = int64(len(body))
+
+	header := http.Header{}
+	for _, name := range forwardedHeaders {
+		if v := req.Header.Get(name); v != "" {
+			header.Set(name, v)
+		}
+	}
+	req.Header = header
+
+	creds, err := p.creds.Retrieve(req.Context())
+	if err != nil {
+		log.Printf("Bedrock credentials unavailable: %v", err)
+		return
+	}
+	hash := sha256.Sum256(body)
+	if err := p.signer.SignHTTP(req.Context(), creds, req, hex.EncodeToString(hash[:]), signingName, p.region, time.Now()); err != nil {
+		log.Printf("Bedrock request signing failed: %v", err)
+	}
+}
+
+func isOperation(op string) bool {
+	for _, o := range Operations {
+		if op == o {
+			return true
+		}
+	}
+	return false
+}
+
+// modelPath points the request at /model/{modelId}/{op}, taking the model
+// from the body and removing it there since Bedrock rejects unknown
+// fields. Bodies without a model are forwarded unchanged so AWS reports
+// the error.
+func modelPath(req *http.Request, op string, body []byte) []byte {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return body
+	}
+	var model string
+	if json.Unmarshal(fields["model"], &model) != nil || model == "" {
+		return body
+	}
+	delete(fields, "model")
+	rewritten, err := json.Marshal(fields)
+	if err != nil {
+		return body
+	}
+
+	// Model IDs and ARNs contain ':' and '/', which must reach AWS escaped
+	escaped := strings.ReplaceAll(url.PathEscape(model), ":", "%3A")
+	req.URL.Path = "/model/" + model + "/" + op
+	req.URL.RawPath = "/model/" + escaped + "/" + op
+	return rewritten
+}
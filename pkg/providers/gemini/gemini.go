@@ -0,0 +1,170 @@
+// Package gemini adapts proxied requests for Google's Generative Language
+// API (Gemini).
+package gemini
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// DefaultBaseURL is the public Generative Language API endpoint.
+const DefaultBaseURL = "https://generativelanguage.googleapis.com"
+
+// PathPrefix is where Gemini traffic is mounted on the proxy. Requests under
+// it are forwarded with the prefix removed, e.g.
+// /gemini/v1beta/models/gemini-1.5-flash:generateContent.
+const PathPrefix = "/gemini"
+
+// ChatCompletionsPath is the OpenAI-style route; its requests are translated
+// to generateContent (or streamGenerateContent when "stream" is set).
+const ChatCompletionsPath = PathPrefix + "/v1/chat/completions"
+
+// OpenAIPath is Gemini's own OpenAI-compatible chat route, which unlike
+// ChatCompletionsPath also answers in the OpenAI format.
+const OpenAIPath = PathPrefix + "/v1beta/openai/chat/completions"
+
+// Provider authenticates with an API key in the "key" query parameter.
+type Provider struct {
+	apiKey    string
+	keySource func() string
+	baseURL   *url.URL
+}
+
+// New returns a Gemini provider. An empty baseURL uses DefaultBaseURL.
+func New(apiKey, baseURL string) (*Provider, error) {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+	return &Provider{apiKey: apiKey, baseURL: u}, nil
+}
+
+// SetKeySource reads the API key on every request instead, for keys that
+// are rotated while the provider is in use.
+func (p *Provider) SetKeySource(key func() string) {
+	p.keySource = key
+}
+
+func (p *Provider) Name() string { return "gemini" }
+
+func (p *Provider) BaseURL() *url.URL { return p.baseURL }
+
+// Direct maps the proxy path onto the Gemini API and adds the API key.
+func (p *Provider) Direct(req *http.Request) {
+	if req.URL.Path == ChatCompletionsPath {
+		translateChat(req)
+	} else {
+		req.URL.Path = strings.TrimPrefix(req.URL.Path, PathPrefix)
+		req.URL.RawPath = ""
+	}
+
+	// Gemini uses its own key, never the caller's credentials
+	req.Header.Del("Authorization")
+	key := p.apiKey
+	if p.keySource != nil {
+		key = p.keySource()
+	}
+	q := req.URL.Query()
+	q.Set("key", key)
+	req.URL.RawQuery = q.Encode()
+}
+
+type chatRequest struct {
+	Model       string        `json:"model"`
+	Messages    []chatMessage `json:"messages"`
+	MaxTokens   int           `json:"max_tokens,omitempty"`
+	Temperature *float64      `json:"temperature,omitempty"`
+	TopP        *float64      `json:"top_p,omitempty"`
+	Stop        []string      `json:"stop,omitempty"`
+	Stream      bool          `json:"stream,omitempty"`
+}
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type part struct {
+	Text string `json:"text"`
+}
+
+type content struct {
+	Role  string `json:"role,omitempty"`
+	Parts []part `json:"parts"`
+}
+
+type generationConfig struct {
+	MaxOutputTokens int      `json:"maxOutputTokens,omitempty"`
+	Temperature     *float64 `json:"temperature,omitempty"`
+	TopP            *float64 `json:"topP,omitempty"`
+	StopSequences   []string `json:"stopSequences,omitempty"`
+}
+
+type generateRequest struct {
+	Contents          []content         `json:"contents"`
+	SystemInstruction *content          `json:"systemInstruction,omitempty"`
+	GenerationConfig  *generationConfig `json:"generationConfig,omitempty"`
+}
+
+// translateChat rewrites an OpenAI-style chat request into a generateContent
+// call for the requested model. Bodies that cannot be parsed are forwarded
+// unchanged so the upstream reports the error.
+func translateChat(req *http.Request) {
+	if req.Body == nil {
+		return
+	}
+	body, _ := io.ReadAll(req.Body)
+	req.Body = io.NopCloser(bytes.NewReader(body))
+
+	var chat chatRequest
+	if err := json.Unmarshal(body, &chat); err != nil || chat.Model == "" {
+		return
+	}
+
+	out := generateRequest{Contents: []content{}}
+	for _, m := range chat.Messages {
+		switch m.Role {
+		case "system":
+			if out.SystemInstruction == nil {
+				out.SystemInstruction = &content{}
+			}
+			out.SystemInstruction.Parts = append(out.SystemInstruction.Parts, part{Text: m.Content})
+		case "assistant":
+			out.Contents = append(out.Contents, content{Role: "model", Parts: []part{{Text: m.Content}}})
+		default:
+			out.Contents = append(out.Contents, content{Role: "user", Parts: []part{{Text: m.Content}}})
+		}
+	}
+	if chat.MaxTokens > 0 || chat.Temperature != nil || chat.TopP != nil || len(chat.Stop) > 0 {
+		out.GenerationConfig = &generationConfig{
+			MaxOutputTokens: chat.MaxTokens,
+			Temperature:     chat.Temperature,
+			TopP:            chat.TopP,
+			StopSequences:   chat.Stop,
+		}
+	}
+	translated, err := json.Marshal(out)
+	if err != nil {
+		return
+	}
+
+	method := ":generateContent"
+	if chat.Stream {
+		method = ":streamGenerateContent"
+		q := req.URL.Query()
+		q.Set("alt", "sse")
+		req.URL.RawQuery = q.Encode()
+	}
+	req.URL.Path = "/v1beta/models/" + url.PathEscape(chat.Model) + method
+	req.URL.RawPath = ""
+	req.Body = io.NopCloser(bytes.NewReader(translated))
+	req.ContentLength = int64(len(translated))
+	req.Header.Del("Content-Length")
+}
@@ -0,0 +1,60 @@
+// Package groq adapts proxied requests for Groq's OpenAI-compatible API.
+package groq
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// DefaultBaseURL is the public Groq endpoint; its OpenAI-compatible routes
+// live under /openai.
+const DefaultBaseURL = "https://api.groq.com/openai"
+
+// PathPrefix is where Groq traffic is mounted on the proxy. Requests under it
+// are forwarded to the base URL with the prefix removed, so
+// /groq/v1/chat/completions reaches /openai/v1/chat/completions.
+const PathPrefix = "/groq"
+
+// Provider authenticates with a bearer API key.
+type Provider struct {
+	apiKey    string
+	keySource func() string
+	baseURL   *url.URL
+}
+
+// New returns a Groq provider. An empty baseURL uses DefaultBaseURL; a path
+// on it is prepended to forwarded paths.
+func New(apiKey, baseURL string) (*Provider, error) {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+	return &Provider{apiKey: apiKey, baseURL: u}, nil
+}
+
+// SetKeySource reads the API key on every request instead, for keys that
+// are rotated while the provider is in use.
+func (p *Provider) SetKeySource(key func() string) {
+	p.keySource = key
+}
+
+func (p *Provider) Name() string { return "groq" }
+
+func (p *Provider) BaseURL() *url.URL { return p.baseURL }
+
+// Direct maps the proxy path onto the API and replaces the caller's
+// credentials with the Groq key.
+func (p *Provider) Direct(req *http.Request) {
+	req.URL.Path = strings.TrimSuffix(p.baseURL.Path, "/") + strings.TrimPrefix(req.URL.Path, PathPrefix)
+	req.URL.RawPath = ""
+
+	key := p.apiKey
+	if p.keySource != nil {
+		key = p.keySource()
+	}
+	req.Header.Set("Authorization", "Bearer "+key)
+}
@@ -0,0 +1,57 @@
+// Package local adapts proxied requests for self-hosted inference servers
+// such as Ollama, vLLM and the llama.cpp server.
+package local
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// PathPrefix is where local upstreams are mounted. Each is served under
+// /local/{name} with the rest of the path forwarded to its base URL, e.g.
+// /local/ollama/api/chat.
+const PathPrefix = "/local"
+
+var nameRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// Provider forwards requests without credentials; local servers are
+// expected to be reachable only from the proxy.
+type Provider struct {
+	name    string
+	baseURL *url.URL
+}
+
+// New returns a provider for the server at baseURL. A path on baseURL, such
+// as vLLM's /v1, is prepended to forwarded paths.
+func New(name, baseURL string) (*Provider, error) {
+	if !nameRegex.MatchString(name) {
+		return nil, fmt.Errorf("local provider name %q must be lowercase letters, digits, '-' or '_'", name)
+	}
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("local provider %s: base URL %q must include scheme and host", name, baseURL)
+	}
+	return &Provider{name: name, baseURL: u}, nil
+}
+
+func (p *Provider) Name() string { return p.name }
+
+func (p *Provider) BaseURL() *url.URL { return p.baseURL }
+
+// Prefix is the proxy path the provider is mounted under.
+func (p *Provider) Prefix() string { return PathPrefix + "/" + p.name }
+
+// Direct maps the proxy path onto the server. The caller's Authorization is
+// gateway credentials and is not forwarded.
+func (p *Provider) Direct(req *http.Request) {
+	path := strings.TrimPrefix(req.URL.Path, p.Prefix())
+	req.URL.Path = strings.TrimSuffix(p.baseURL.Path, "/") + path
+	req.URL.RawPath = ""
+	req.Header.Del("Authorization")
+}
@@ -0,0 +1,58 @@
+// Package mistral adapts proxied requests for Mistral AI's La Plateforme
+// API.
+package mistral
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// DefaultBaseURL is the public La Plateforme endpoint.
+const DefaultBaseURL = "https://api.mistral.ai"
+
+// PathPrefix is where Mistral traffic is mounted on the proxy. Requests under
+// it are forwarded with the prefix removed, e.g. /mistral/v1/chat/completions.
+const PathPrefix = "/mistral"
+
+// Provider authenticates with a bearer API key.
+type Provider struct {
+	apiKey    string
+	keySource func() string
+	baseURL   *url.URL
+}
+
+// New returns a Mistral provider. An empty baseURL uses DefaultBaseURL.
+func New(apiKey, baseURL string) (*Provider, error) {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+	return &Provider{apiKey: apiKey, baseURL: u}, nil
+}
+
+// SetKeySource reads the API key on every request instead, for keys that
+// are rotated while the provider is in use.
+func (p *Provider) SetKeySource(key func() string) {
+	p.keySource = key
+}
+
+func (p *Provider) Name() string { return "mistral" }
+
+func (p *Provider) BaseURL() *url.URL { return p.baseURL }
+
+// Direct maps the proxy path onto the API and replaces the caller's
+// credentials with the Mistral key.
+func (p *Provider) Direct(req *http.Request) {
+	req.URL.Path = strings.TrimSuffix(p.baseURL.Path, "/") + strings.TrimPrefix(req.URL.Path, PathPrefix)
+	req.URL.RawPath = ""
+
+	key := p.apiKey
+	if p.keySource != nil {
+		key = p.keySource()
+	}
+	req.Header.Set("Authorization", "Bearer "+key)
+}
@@ -0,0 +1,340 @@
+// Package mock answers Cohere API calls locally with canned responses, so
+// the gateway and its dashboard can run, and the full pipeline can be
+// tested, without a Cohere key or network access.
+package mock
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// EmbeddingDims is the length of mock embeddings, as for embed-english-v3.0.
+const EmbeddingDims = 1024
+
+// Transport is an http.RoundTripper standing in for api.cohere.com. It
+// answers chat (v1 and v2, whole or streamed), generate, embed and rerank
+// requests with responses shaped like Cohere's, including billed units
+// counted from the request, and 404s anything else. Latency delays every
+// response.
+type Transport struct {
+	Latency time.Duration
+
+	ids atomic.Int64
+}
+
+// New returns a Transport without added latency.
+func New() *Transport {
+	return &Transport{}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		body, _ = io.ReadAll(req.Body)
+		req.Body.Close()
+	}
+	if t.Latency > 0 {
+		select {
+		case <-time.After(t.Latency):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+	var doc map[string]interface{}
+	json.Unmarshal(body, &doc)
+	id := fmt.Sprintf("mock-%d", t.ids.Add(1))
+
+	path := strings.TrimSuffix(req.URL.Path, "/")
+	stream, _ := doc["stream"].(bool)
+	switch path {
+	case "/v1/chat":
+		if stream {
+			return respond(req, "application/stream+json", v1ChatStream(id, doc)), nil
+		}
+		return respondJSON(req, v1Chat(id, doc)), nil
+	case "/v2/chat":
+		if stream {
+			return respond(req, "text/event-stream", v2ChatStream(id, doc)), nil
+		}
+		return respondJSON(req, v2Chat(id, doc)), nil
+	case "/v1/generate":
+		return respondJSON(req, generate(id, doc)), nil
+	case "/v1/embed", "/v2/embed":
+		return respondJSON(req, embed(id, path, doc)), nil
+	case "/v1/rerank", "/v2/rerank":
+		return respondJSON(req, rerank(id, doc)), nil
+	}
+	resp := respondJSON(req, map[string]string{"message": "mock provider: unknown endpoint " + req.URL.Path})
+	resp.StatusCode, resp.Status = http.StatusNotFound, "404 Not Found"
+	return resp, nil
+}
+
+// respondJSON answers req with v as JSON.
+func respondJSON(req *http.Request, v interface{}) *http.Response {
+	body, _ := json.Marshal(v)
+	return respond(req, "application/json", body)
+}
+
+func respond(req *http.Request, contentType string, body []byte) *http.Response {
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {contentType}, "X-Vantage-Mock": {"true"}},
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+// countTokens approximates Cohere's tokenizer at about four characters a
+// token, at least one per word.
+func countTokens(text string) int {
+	return max(len(strings.Fields(text)), (len(text)+3)/4)
+}
+
+// reply is the generated text for a prompt.
+func reply(prompt string) string {
+	prompt = strings.Join(strings.Fields(prompt), " ")
+	if len(prompt) > 80 {
+		prompt = prompt[:80] + "..."
+	}
+	if prompt == "" {
+		return "This is a mock response from Vantage."
+	}
+	return fmt.Sprintf("This is a mock response from Vantage to: %q", prompt)
+}
+
+// units is a Cohere billed_units or tokens block.
+func units(input, output int) map[string]int {
+	return map[string]int{"input_tokens": input, "output_tokens": output}
+}
+
+// chatInput returns the latest user message of a v1 or v2 chat request and
+// all of its text, for counting.
+func chatInput(doc map[string]interface{}) (string, string) {
+	if message, ok := doc["message"].(string); ok {
+		return message, allText(doc)
+	}
+	latest := ""
+	messages, _ := doc["messages"].([]interface{})
+	for _, m := range messages {
+		turn, _ := m.(map[string]interface{})
+		if turn["role"] == "user" {
+			latest = allText(turn["content"])
+		}
+	}
+	return latest, allText(doc)
+}
+
+// allText joins every string in v.
+func allText(v interface{}) string {
+	var parts []string
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch t := v.(type) {
+		case string:
+			parts = append(parts, t)
+		case []interface{}:
+			for _, e := range t {
+				walk(e)
+			}
+		case map[string]interface{}:
+			for k, e := range t {
+				if k != "model" && k != "role" && k != "type" {
+					walk(e)
+				}
+			}
+		}
+	}
+	walk(v)
+	return strings.Join(parts, " ")
+}
+
+func v1Chat(id string, doc map[string]interface{}) map[string]interface{} {
+	prompt, input := chatInput(doc)
+	text := reply(prompt)
+	in, out := countTokens(input), countTokens(text)
+	return map[string]interface{}{
+		"response_id":   id,
+		"generation_id": id,
+		"text":          text,
+		"finish_reason": "COMPLETE",
+		"meta": map[string]interface{}{
+			"api_version":  map[string]string{"version": "1"},
+			"billed_units": units(in, out),
+			"tokens":       units(in+60, out),
+		},
+	}
+}
+
+func v1ChatStream(id string, doc map[string]interface{}) []byte {
+	full := v1Chat(id, doc)
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.Encode(map[string]interface{}{"is_finished": false, "event_type": "stream-start", "generation_id": id})
+	for _, piece := range pieces(full["text"].(string)) {
+		enc.Encode(map[string]interface{}{"is_finished": false, "event_type": "text-generation", "text": piece})
+	}
+	enc.Encode(map[string]interface{}{"is_finished": true, "event_type": "stream-end", "finish_reason": "COMPLETE", "response": full})
+	return b.Bytes()
+}
+
+func v2Chat(id string, doc map[string]interface{}) map[string]interface{} {
+	prompt, input := chatInput(doc)
+	text := reply(prompt)
+	in, out := countTokens(input), countTokens(text)
+	return map[string]interface{}{
+		"id":            id,
+		"finish_reason": "COMPLETE",
+		"message": map[string]interface{}{
+			"role":    "assistant",
+			"content": []map[string]string{{"type": "text", "text": text}},
+		},
+		"usage": map[string]interface{}{
+			"billed_units": units(in, out),
+			"tokens":       units(in+60, out),
+		},
+	}
+}
+
+func v2ChatStream(id string, doc map[string]interface{}) []byte {
+	full := v2Chat(id, doc)
+	text := full["message"].(map[string]interface{})["content"].([]map[string]string)[0]["text"]
+	var b bytes.Buffer
+	event := func(name string, data map[string]interface{}) {
+		data["type"] = name
+		line, _ := json.Marshal(data)
+		fmt.Fprintf(&b, "event: %s\ndata: %s\n\n", name, line)
+	}
+	event("message-start", map[string]interface{}{"id": id, "delta": map[string]interface{}{"message": map[string]string{"role": "assistant"}}})
+	event("content-start", map[string]interface{}{"index": 0, "delta": map[string]interface{}{"message": map[string]interface{}{"content": map[string]string{"type": "text", "text": ""}}}})
+	for _, piece := range pieces(text) {
+		event("content-delta", map[string]interface{}{"index": 0, "delta": map[string]interface{}{"message": map[string]interface{}{"content": map[string]string{"text": piece}}}})
+	}
+	event("content-end", map[string]interface{}{"index": 0})
+	event("message-end", map[string]interface{}{"delta": map[string]interface{}{"finish_reason": "COMPLETE", "usage": full["usage"]}})
+	return b.Bytes()
+}
+
+// pieces splits text into the words a stream sends one by one.
+func pieces(text string) []string {
+	words := strings.SplitAfter(text, " ")
+	if len(words) > 0 && words[len(words)-1] == "" {
+		words = words[:len(words)-1]
+	}
+	return words
+}
+
+func generate(id string, doc map[string]interface{}) map[string]interface{} {
+	prompt, _ := doc["prompt"].(string)
+	text := reply(prompt)
+	return map[string]interface{}{
+		"id":          id,
+		"prompt":      prompt,
+		"generations": []map[string]string{{"id": id, "text": text, "finish_reason": "COMPLETE"}},
+		"meta": map[string]interface{}{
+			"api_version":  map[string]string{"version": "1"},
+			"billed_units": units(countTokens(prompt), countTokens(text)),
+		},
+	}
+}
+
+// embed returns a deterministic unit vector per text, so equal texts get
+// equal embeddings. v2 and requests naming embedding_types get them keyed
+// by type.
+func embed(id, path string, doc map[string]interface{}) map[string]interface{} {
+	var texts []string
+	raw, _ := doc["texts"].([]interface{})
+	for _, t := range raw {
+		s, _ := t.(string)
+		texts = append(texts, s)
+	}
+	vectors := make([][]float64, len(texts))
+	tokens := 0
+	for i, text := range texts {
+		vectors[i] = vector(text)
+		tokens += countTokens(text)
+	}
+	var embeddings interface{} = vectors
+	types, _ := doc["embedding_types"].([]interface{})
+	if path == "/v2/embed" || len(types) > 0 {
+		embeddings = map[string]interface{}{"float": vectors}
+	}
+	return map[string]interface{}{
+		"id":         id,
+		"texts":      texts,
+		"embeddings": embeddings,
+		"meta": map[string]interface{}{
+			"api_version":  map[string]string{"version": "1"},
+			"billed_units": map[string]int{"input_tokens": tokens},
+		},
+	}
+}
+
+// vector derives a unit vector of EmbeddingDims from the hash of text.
+func vector(text string) []float64 {
+	v := make([]float64, EmbeddingDims)
+	seed := sha256.Sum256([]byte(text))
+	norm := 0.0
+	for i := range v {
+		block := sha256.Sum256(append(seed[:], byte(i), byte(i>>8)))
+		v[i] = float64(int32(binary.BigEndian.Uint32(block[:4]))) / math.MaxInt32
+		norm += v[i] * v[i]
+	}
+	norm = math.Sqrt(norm)
+	for i := range v {
+		v[i] = math.Round(v[i]/norm*1e6) / 1e6
+	}
+	return v
+}
+
+// rerank scores each document by the share of query words it contains.
+func rerank(id string, doc map[string]interface{}) map[string]interface{} {
+	query, _ := doc["query"].(string)
+	words := strings.Fields(strings.ToLower(query))
+	documents, _ := doc["documents"].([]interface{})
+	type result struct {
+		Index          int     `json:"index"`
+		RelevanceScore float64 `json:"relevance_score"`
+	}
+	results := make([]result, 0, len(documents))
+	for i, d := range documents {
+		text := strings.ToLower(allText(d))
+		hits := 0
+		for _, w := range words {
+			if strings.Contains(text, w) {
+				hits++
+			}
+		}
+		score := 0.0
+		if len(words) > 0 {
+			score = float64(hits) / float64(len(words))
+		}
+		results = append(results, result{Index: i, RelevanceScore: score})
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].RelevanceScore > results[j].RelevanceScore })
+	if n, ok := doc["top_n"].(float64); ok && int(n) < len(results) && n >= 0 {
+		results = results[:int(n)]
+	}
+	return map[string]interface{}{
+		"id":      id,
+		"results": results,
+		"meta": map[string]interface{}{
+			"api_version":  map[string]string{"version": "1"},
+			"billed_units": map[string]int{"search_units": 1},
+		},
+	}
+}
@@ -0,0 +1,57 @@
+package vantage
+
+import (
+	"context"
+	"time"
+
+	"github.com/soroushbar/vantage/internal/audit"
+	"github.com/soroushbar/vantage/internal/store"
+	"github.com/soroushbar/vantage/pkg/middleware"
+)
+
+// AuditLog persists interactions to a hash-chained SQLite audit log, the
+// same one the standalone server writes. Records are processed by a
+// background worker started with Start.
+type AuditLog struct {
+	store  *store.Store
+	ch     chan middleware.Interaction
+	worker *audit.Worker
+}
+
+// OpenAuditLog opens (or creates) the audit database at dbPath. apiKey is
+// used to score prompt safety with the provider's classify endpoint.
+func OpenAuditLog(dbPath, apiKey string) (*AuditLog, error) {
+	st, err := store.NewStore(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	ch := make(chan middleware.Interaction, 100)
+	return &AuditLog{
+		store:  st,
+		ch:     ch,
+		worker: audit.NewWorker(ch, st, apiKey, nil, 0),
+	}, nil
+}
+
+// Start runs the audit worker until ctx is cancelled.
+func (l *AuditLog) Start(ctx context.Context) {
+	l.worker.Start(ctx)
+}
+
+// Close releases the audit database. Call it after the worker's context has
+// been cancelled.
+func (l *AuditLog) Close() error {
+	return l.store.Close()
+}
+
+// ConversationTokens implements middleware.ConversationUsage, so an
+// AuditLog can back WithConversationBudget.
+func (l *AuditLog) ConversationTokens(userID, conversationID string) (int, error) {
+	return l.store.ConversationTokens(userID, conversationID)
+}
+
+// ProjectCost implements middleware.ProjectSpend, so an AuditLog can back
+// WithProjectBudgets.
+func (l *AuditLog) ProjectCost(project string, since time.Time) (float64, error) {
+	return l.store.ProjectCost(project, since)
+}
@@ -0,0 +1,349 @@
+package vantage
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/soroushbar/vantage/pkg/middleware"
+)
+
+// Option configures a Handler.
+type Option func(*options)
+
+type options struct {
+	upstream       *url.URL
+	apiKey         string
+	apiKeySource   func() string
+	provider       Provider
+	transport      http.RoundTripper
+	modifyResponse func(*http.Response) error
+	requestID      func(*http.Request) string
+
+	authenticators    []middleware.Authenticator
+	trustUserHeader   bool
+	requireAuth       bool
+	auditChan         chan<- middleware.Interaction
+	headerCapture     middleware.HeaderCapture
+	backpressure      middleware.AuditBackpressure
+	headerRules       []middleware.HeaderRule
+	requestSchemas    []middleware.RequestSchema
+	limiter           middleware.RateLimiter
+	quota             middleware.RateLimiter
+	concurrency       *middleware.ConcurrencyLimiter
+	router            middleware.ModelRouter
+	models            middleware.ModelPolicy
+	plans             middleware.PlanResolver
+	paramPolicies     *middleware.ParamPolicies
+	moderation        *middleware.ModerationOptions
+	trust             middleware.TrustResolver
+	trustOptions      middleware.TrustOptions
+	schedule          *middleware.ScheduleOptions
+	conversations     middleware.ConversationUsage
+	conversationLimit middleware.ConversationBudget
+	projectSpend      middleware.ProjectSpend
+	projectBudget     middleware.ProjectBudget
+	projectBudgets    func() middleware.ProjectBudget
+	contextWindows    *middleware.ContextWindows
+	transforms        []middleware.RequestTransform
+	fineTune          middleware.FineTunePolicy
+	artifacts         middleware.ArtifactRegistry
+	forbiddenKeywords []string
+	governance        []middleware.GovernanceProfile
+	governanceRules   func() middleware.GovernanceRules
+	redact            bool
+	redactionPatterns []middleware.RedactionPattern
+	localePatterns    map[string][]middleware.RedactionPattern
+	redactionCounter  func(pattern string, matches int)
+	vault             middleware.PIIVault
+	cache             middleware.ResponseCache
+	cacheOptions      middleware.CacheOptions
+	dedup             *middleware.Deduplicator
+	responseSafety    *middleware.ResponseSafetyOptions
+	responsePII       *middleware.ResponsePIIOptions
+}
+
+// WithProvider forwards requests to a provider other than Cohere, e.g.
+// gemini.New. WithUpstream and WithAPIKey only configure the default Cohere
+// provider.
+func WithProvider(p Provider) Option {
+	return func(o *options) { o.provider = p }
+}
+
+// WithUpstream sets the Cohere base URL requests are forwarded to.
+func WithUpstream(u *url.URL) Option {
+	return func(o *options) { o.upstream = u }
+}
+
+// WithAPIKey sets the bearer token sent to Cohere.
+func WithAPIKey(key string) Option {
+	return func(o *options) { o.apiKey = key }
+}
+
+// WithAPIKeySource reads the bearer token sent to Cohere on every request,
+// for keys that are rotated while the handler runs. It takes precedence
+// over WithAPIKey.
+func WithAPIKeySource(key func() string) Option {
+	return func(o *options) { o.apiKeySource = key }
+}
+
+// WithTransport sets the RoundTripper used for upstream requests.
+func WithTransport(rt http.RoundTripper) Option {
+	return func(o *options) { o.transport = rt }
+}
+
+// WithResponseHook lets the caller inspect or modify upstream responses
+// before they reach the pipeline.
+func WithResponseHook(fn func(*http.Response) error) Option {
+	return func(o *options) { o.modifyResponse = fn }
+}
+
+// WithRequestIDSource takes the ID of each request from id, e.g. chi's
+// middleware.GetReqID, so that the proxy shares it with the host service.
+// Requests it returns no ID for get a generated one.
+func WithRequestIDSource(id func(*http.Request) string) Option {
+	return func(o *options) { o.requestID = id }
+}
+
+// WithAuthenticator identifies callers from request credentials, e.g. a
+// SignatureVerifier or JWTAuthenticator. Authenticators are tried in the
+// order they are added.
+func WithAuthenticator(a middleware.Authenticator) Option {
+	return func(o *options) { o.authenticators = append(o.authenticators, a) }
+}
+
+// WithTrustedUserHeader controls whether unauthenticated callers may name
+// themselves with X-User-ID. It is on by default.
+func WithTrustedUserHeader(trust bool) Option {
+	return func(o *options) { o.trustUserHeader = trust }
+}
+
+// WithRequireAuth rejects requests no authenticator recognises.
+func WithRequireAuth(require bool) Option {
+	return func(o *options) { o.requireAuth = require }
+}
+
+// WithAuditChannel delivers a record of every request to ch. Sends never
+// block; records are dropped while ch is full, unless WithAuditBackpressure
+// says otherwise.
+func WithAuditChannel(ch chan<- middleware.Interaction) Option {
+	return func(o *options) { o.auditChan = ch }
+}
+
+// WithAuditLog persists every request to the given audit log.
+func WithAuditLog(l *AuditLog) Option {
+	return WithAuditChannel(l.ch)
+}
+
+// WithAuditBackpressure sets what happens while the audit channel is full:
+// how long records wait for room, and whether new requests are refused.
+func WithAuditBackpressure(bp middleware.AuditBackpressure) Option {
+	return func(o *options) { o.backpressure = bp }
+}
+
+// WithHeaderCapture records the selected request and response headers in
+// the audit log.
+func WithHeaderCapture(capture middleware.HeaderCapture) Option {
+	return func(o *options) { o.headerCapture = capture }
+}
+
+// WithRequestSchemas rejects request bodies that do not match the schema
+// of their path with 400 before they reach the provider, e.g.
+// middleware.BuiltinRequestSchemas for the chat and embed endpoints.
+func WithRequestSchemas(schemas ...middleware.RequestSchema) Option {
+	return func(o *options) { o.requestSchemas = schemas }
+}
+
+// WithHeaderRules blocks requests that break a header rule, e.g. ones
+// missing an X-Purpose header.
+func WithHeaderRules(rules ...middleware.HeaderRule) Option {
+	return func(o *options) { o.headerRules = rules }
+}
+
+// WithRateLimit rejects requests over the limiter's per-user allowance.
+func WithRateLimit(limiter middleware.RateLimiter) Option {
+	return func(o *options) { o.limiter = limiter }
+}
+
+// WithQuota rejects requests once a user has used up their quota, e.g. a
+// daily request allowance.
+func WithQuota(quota middleware.RateLimiter) Option {
+	return func(o *options) { o.quota = quota }
+}
+
+// WithConcurrencyLimit caps the upstream requests each user, and all users
+// together, may have in flight. Handlers built with the same limiter share
+// its caps.
+func WithConcurrencyLimit(limiter *middleware.ConcurrencyLimiter) Option {
+	return func(o *options) { o.concurrency = limiter }
+}
+
+// WithModelRouter rewrites the requested model before it is checked and
+// forwarded.
+func WithModelRouter(router middleware.ModelRouter) Option {
+	return func(o *options) { o.router = router }
+}
+
+// WithModelPolicy rejects models the policy does not allow and flags
+// deprecated ones.
+func WithModelPolicy(policy middleware.ModelPolicy) Option {
+	return func(o *options) { o.models = policy }
+}
+
+// WithPlans enforces each user's plan limits on prompt size, output tokens,
+// models and streaming.
+func WithPlans(plans middleware.PlanResolver) Option {
+	return func(o *options) { o.plans = plans }
+}
+
+// WithParamPolicies applies each user's or team's defaults and limits for
+// the model and generation parameters of chat requests.
+func WithParamPolicies(policies middleware.ParamPolicies) Option {
+	return func(o *options) { o.paramPolicies = &policies }
+}
+
+// WithModeration moderates prompts before they are forwarded, rejecting
+// those flagged in any harm category.
+func WithModeration(opts middleware.ModerationOptions) Option {
+	return func(o *options) { o.moderation = &opts }
+}
+
+// WithTrust tags requests with the caller's trust tier and runs the
+// synchronous safety check for the tiers that need it.
+func WithTrust(trust middleware.TrustResolver, opts middleware.TrustOptions) Option {
+	return func(o *options) {
+		o.trust = trust
+		o.trustOptions = opts
+	}
+}
+
+// WithSchedule enforces time-of-day policies, evaluated in each caller's
+// timezone.
+func WithSchedule(opts middleware.ScheduleOptions) Option {
+	return func(o *options) { o.schedule = &opts }
+}
+
+// WithConversationBudget caps the cumulative tokens per conversation. usage
+// must be fed by an audit consumer, as AuditLog does.
+func WithConversationBudget(usage middleware.ConversationUsage, budget middleware.ConversationBudget) Option {
+	return func(o *options) {
+		o.conversations = usage
+		o.conversationLimit = budget
+	}
+}
+
+// WithProjectBudgets caps the monthly estimated cost of the projects in
+// budget. spend must be fed by an audit consumer, as AuditLog does.
+func WithProjectBudgets(spend middleware.ProjectSpend, budget middleware.ProjectBudget) Option {
+	return func(o *options) {
+		o.projectSpend = spend
+		o.projectBudget = budget
+	}
+}
+
+// WithProjectBudgetSource is WithProjectBudgets with the budgets read on
+// every request, for budgets that are changed while the handler runs.
+func WithProjectBudgetSource(spend middleware.ProjectSpend, budgets func() middleware.ProjectBudget) Option {
+	return func(o *options) {
+		o.projectSpend = spend
+		o.projectBudgets = budgets
+	}
+}
+
+// WithTruncation drops the oldest chat turns of prompts that would overflow
+// the model's context window. What was dropped is recorded with the
+// interaction.
+func WithTruncation(windows middleware.ContextWindows) Option {
+	return func(o *options) { o.contextWindows = &windows }
+}
+
+// WithTransforms rewrites matching requests before they are forwarded:
+// system prompts and instructions are added and parameters capped or
+// removed. What changed is recorded with the interaction.
+func WithTransforms(transforms ...middleware.RequestTransform) Option {
+	return func(o *options) { o.transforms = append(o.transforms, transforms...) }
+}
+
+// WithFineTuning enforces who may create and invoke fine-tuned models.
+func WithFineTuning(policy middleware.FineTunePolicy, registry middleware.ArtifactRegistry) Option {
+	return func(o *options) {
+		o.fineTune = policy
+		o.artifacts = registry
+	}
+}
+
+// WithForbiddenKeywords blocks prompts containing any of the keywords.
+func WithForbiddenKeywords(keywords ...string) Option {
+	return func(o *options) { o.forbiddenKeywords = keywords }
+}
+
+// WithGovernanceProfiles applies other forbidden keywords and redaction to
+// the requests under the paths of a profile. The first matching profile
+// wins; other requests keep the rules set by WithForbiddenKeywords and
+// WithRedaction.
+func WithGovernanceProfiles(profiles ...middleware.GovernanceProfile) Option {
+	return func(o *options) { o.governance = profiles }
+}
+
+// WithGovernanceRules reads the forbidden keywords, redactor and profiles
+// on every request, for rules that are changed while the handler runs. It
+// replaces WithForbiddenKeywords, WithGovernanceProfiles and the redaction
+// options, so the redactor brings its own patterns, vault and counter.
+func WithGovernanceRules(rules func() middleware.GovernanceRules) Option {
+	return func(o *options) { o.governanceRules = rules }
+}
+
+// WithRedaction turns PII redaction on or off. It is on by default.
+func WithRedaction(enabled bool) Option {
+	return func(o *options) { o.redact = enabled }
+}
+
+// WithRedactionPatterns replaces the built-in email, phone and UUID
+// patterns with the given list.
+func WithRedactionPatterns(patterns ...middleware.RedactionPattern) Option {
+	return func(o *options) { o.redactionPatterns = patterns }
+}
+
+// WithLocaleRedaction adds patterns, keyed by language code, that apply
+// ahead of the others to prompts detected in that language, such as
+// middleware.LocaleRedactionPatterns("de").
+func WithLocaleRedaction(locales map[string][]middleware.RedactionPattern) Option {
+	return func(o *options) { o.localePatterns = locales }
+}
+
+// WithRedactionCounter is called with the number of matches per pattern
+// whenever a prompt is redacted, e.g. to feed metrics.
+func WithRedactionCounter(fn func(pattern string, matches int)) Option {
+	return func(o *options) { o.redactionCounter = fn }
+}
+
+// WithPIIVault tokenizes PII through vault instead of masking it, and
+// restores the original values in responses.
+func WithPIIVault(vault middleware.PIIVault) Option {
+	return func(o *options) { o.vault = vault }
+}
+
+// WithResponsePII scans responses for PII before they are returned and
+// redacts, blocks or flags what it finds.
+func WithResponsePII(opts middleware.ResponsePIIOptions) Option {
+	return func(o *options) { o.responsePII = &opts }
+}
+
+// WithResponseSafety classifies responses before they are returned and
+// replaces unsafe generated text with a fallback.
+func WithResponseSafety(opts middleware.ResponseSafetyOptions) Option {
+	return func(o *options) { o.responseSafety = &opts }
+}
+
+// WithDedup collapses identical requests from the same user that arrive
+// within the deduplicator's window.
+func WithDedup(d *middleware.Deduplicator) Option {
+	return func(o *options) { o.dedup = d }
+}
+
+// WithCache replays upstream responses for identical prompts.
+func WithCache(cache middleware.ResponseCache, opts middleware.CacheOptions) Option {
+	return func(o *options) {
+		o.cache = cache
+		o.cacheOptions = opts
+	}
+}
@@ -0,0 +1,35 @@
+package vantage
+
+import (
+	"net/http"
+	"net/url"
+)
+
+// Provider adapts proxied requests for one upstream API.
+type Provider interface {
+	Name() string
+	BaseURL() *url.URL
+	// Direct authenticates the outgoing request and maps its path onto the
+	// upstream API. The scheme and host are already set.
+	Direct(req *http.Request)
+}
+
+// cohere is the default provider: bearer-token auth with paths forwarded
+// unchanged.
+type cohere struct {
+	apiKey    string
+	keySource func() string
+	baseURL   *url.URL
+}
+
+func (c *cohere) Name() string { return "cohere" }
+
+func (c *cohere) BaseURL() *url.URL { return c.baseURL }
+
+func (c *cohere) Direct(req *http.Request) {
+	key := c.apiKey
+	if c.keySource != nil {
+		key = c.keySource()
+	}
+	req.Header.Set("Authorization", "Bearer "+key)
+}
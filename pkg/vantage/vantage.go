@@ -0,0 +1,188 @@
+// Package vantage embeds the Vantage proxy pipeline (governance, audit and
+// provider routing) in an existing Go service. A Handler is configured
+// entirely through options and never reads the environment:
+//
+//	h := vantage.New(
+//		vantage.WithAPIKey(key),
+//		vantage.WithForbiddenKeywords("password", "secret_key"),
+//	)
+//	mux.Handle("/v1/", h)
+//	mux.Handle("/v2/", h)
+package vantage
+
+import (
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/soroushbar/vantage/pkg/middleware"
+)
+
+// DefaultUpstream is the Cohere endpoint requests are forwarded to unless
+// WithUpstream or WithProvider is given.
+const DefaultUpstream = "https://api.cohere.com"
+
+// Handler runs requests through the governance pipeline and proxies them
+// to the upstream provider. Request paths are forwarded unchanged, so mount
+// it where the provider's paths (/v1/chat, ...) are preserved or strip any
+// prefix with http.StripPrefix.
+type Handler struct {
+	// Proxy forwards requests that pass the pipeline. Its Transport may be
+	// replaced before the handler serves traffic.
+	Proxy    *httputil.ReverseProxy
+	Upstream *url.URL
+	// Identity is the caller-resolution stage of the pipeline, for routes
+	// served next to the Handler that need the same identity.
+	Identity func(http.Handler) http.Handler
+	// Governance is the policy stages of the pipeline, the model policy and
+	// the forbidden keyword and redaction rules, for requests sent upstream
+	// outside it, such as replays of logged requests.
+	Governance func(http.Handler) http.Handler
+
+	pipeline http.Handler
+}
+
+// New builds a Handler. Without options it forwards to DefaultUpstream and
+// masks PII in prompts, taking the caller's identity from X-User-ID; every
+// other stage is opt-in.
+func New(opts ...Option) *Handler {
+	o := options{redact: true, trustUserHeader: true}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.provider == nil {
+		if o.upstream == nil {
+			o.upstream, _ = url.Parse(DefaultUpstream)
+		}
+		o.provider = &cohere{apiKey: o.apiKey, keySource: o.apiKeySource, baseURL: o.upstream}
+	}
+
+	h := &Handler{Upstream: o.provider.BaseURL()}
+
+	// 1. Reverse proxy to the provider
+	upstream := h.Upstream
+	provider := o.provider
+	h.Proxy = httputil.NewSingleHostReverseProxy(upstream)
+	h.Proxy.Director = func(req *http.Request) {
+		req.Header.Set("Host", upstream.Host)
+		req.URL.Scheme = upstream.Scheme
+		req.URL.Host = upstream.Host
+		req.Host = upstream.Host
+		provider.Direct(req)
+	}
+	h.Proxy.Transport = o.transport
+	h.Proxy.ModifyResponse = o.modifyResponse
+
+	// 2. Pipeline, outermost first
+	h.Identity = middleware.IdentityMiddleware(middleware.IdentityOptions{
+		Authenticators: o.authenticators,
+		TrustHeader:    o.trustUserHeader,
+		Require:        o.requireAuth,
+	})
+	pipeline := []func(http.Handler) http.Handler{middleware.RequestIDMiddleware(o.requestID), h.Identity, middleware.AuditMiddleware(o.auditChan, o.headerCapture, o.backpressure), middleware.MetadataMiddleware()}
+	if o.limiter != nil {
+		pipeline = append(pipeline, middleware.RateLimitMiddleware(o.limiter))
+	}
+	if len(o.requestSchemas) > 0 {
+		// Ahead of the quota so malformed requests do not use it up
+		pipeline = append(pipeline, middleware.SchemaValidationMiddleware(o.requestSchemas))
+	}
+	if o.quota != nil {
+		pipeline = append(pipeline, middleware.QuotaMiddleware(o.quota))
+	}
+	if o.paramPolicies != nil {
+		// Before routing so a defaulted model is routed and checked too
+		pipeline = append(pipeline, middleware.ParamPolicyMiddleware(*o.paramPolicies))
+	}
+	if o.router != nil {
+		// Before the model policy so the rewritten model is the one checked
+		pipeline = append(pipeline, middleware.ModelRoutingMiddleware(o.router))
+	}
+	var policy []func(http.Handler) http.Handler
+	if o.models != nil {
+		policy = append(policy, middleware.ModelPolicyMiddleware(o.models))
+		pipeline = append(pipeline, policy[0])
+	}
+	if o.plans != nil {
+		pipeline = append(pipeline, middleware.PlanMiddleware(o.plans))
+	}
+	if o.conversations != nil {
+		pipeline = append(pipeline, middleware.ConversationBudgetMiddleware(o.conversations, o.conversationLimit))
+	}
+	if o.projectSpend != nil && o.projectBudgets != nil {
+		pipeline = append(pipeline, middleware.DynamicProjectBudgetMiddleware(o.projectSpend, o.projectBudgets))
+	} else if o.projectSpend != nil {
+		pipeline = append(pipeline, middleware.ProjectBudgetMiddleware(o.projectSpend, o.projectBudget))
+	}
+	if o.artifacts != nil {
+		pipeline = append(pipeline, middleware.FineTuneMiddleware(o.fineTune, o.artifacts))
+	}
+	var redactor *middleware.Redactor
+	if o.redact {
+		patterns := o.redactionPatterns
+		if patterns == nil {
+			patterns = middleware.BuiltinRedactionPatterns()
+		}
+		redactor = middleware.NewRedactor(patterns, o.vault, o.redactionCounter)
+		redactor.SetLocalePatterns(o.localePatterns)
+	}
+	if len(o.headerRules) > 0 {
+		pipeline = append(pipeline, middleware.HeaderRulesMiddleware(o.headerRules))
+	}
+	governance := middleware.GovernanceMiddleware(o.forbiddenKeywords, redactor, o.governance...)
+	if o.governanceRules != nil {
+		governance = middleware.DynamicGovernanceMiddleware(o.governanceRules)
+	}
+	pipeline = append(pipeline, governance)
+	h.Governance = chi.Chain(append(policy, governance)...).Handler
+	if o.schedule != nil {
+		pipeline = append(pipeline, middleware.ScheduleMiddleware(*o.schedule))
+	}
+	if o.moderation != nil {
+		// After governance so the moderator sees the redacted prompt
+		pipeline = append(pipeline, middleware.ModerationMiddleware(*o.moderation))
+	}
+	if o.trust != nil {
+		// After governance so the safety check sees the redacted prompt
+		pipeline = append(pipeline, middleware.TrustMiddleware(o.trust, o.trustOptions))
+	}
+	if len(o.transforms) > 0 {
+		// After governance so policies judge the client's prompt, and before
+		// truncation so the added text counts against the context window
+		pipeline = append(pipeline, middleware.TransformMiddleware(o.transforms))
+	}
+	if o.contextWindows != nil {
+		pipeline = append(pipeline, middleware.TruncationMiddleware(*o.contextWindows))
+	}
+	if o.dedup != nil {
+		// Just outside the cache, so duplicates of a request still in flight,
+		// which the cache cannot answer yet, wait for it instead of going upstream
+		pipeline = append(pipeline, middleware.DedupMiddleware(o.dedup))
+	}
+	if o.cache != nil {
+		// After governance and truncation so cache keys use the prompt actually sent and blocked requests are never stored
+		pipeline = append(pipeline, middleware.CacheMiddleware(o.cache, o.cacheOptions))
+	}
+	if o.responseSafety != nil {
+		// Inside the cache, so cached responses were already checked and suppressed ones are cached as sent
+		pipeline = append(pipeline, middleware.ResponseSafetyMiddleware(*o.responseSafety))
+	}
+	if o.responsePII != nil {
+		// Inside the cache, so cached responses were already scanned, and
+		// inside governance, so a caller's own tokenized PII is not taken
+		// for a leak
+		pipeline = append(pipeline, middleware.ResponsePIIMiddleware(*o.responsePII))
+	}
+	if o.concurrency != nil {
+		// Innermost, so only requests that reach the provider hold a slot
+		pipeline = append(pipeline, middleware.ConcurrencyMiddleware(o.concurrency))
+	}
+	h.pipeline = chi.Chain(pipeline...).Handler(h.Proxy)
+
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.pipeline.ServeHTTP(w, r)
+}
@@ -15,7 +15,8 @@ import {
   X,
   Check,
   Zap,
-  Clock
+  Clock,
+  KeyRound
 } from 'lucide-react';
 import { 
   BarChart, 
@@ -38,18 +39,28 @@ interface LogEntry {
   status_code: number;
   latency_ms: number;
   tokens: number;
-  safety_score: number;
+  safety_score: number | null;
+  response_safety?: number;
   is_blocked: boolean;
   is_redacted: boolean;
 }
 
+interface PortalData {
+  usage: { totals: { requests: number; tokens: number; estimated_cost: number; blocked: number } } | null;
+  quota: { plan?: { name: string }; quota?: { limit: number; used: number; remaining: number; unlimited?: boolean } } | null;
+  logs: LogEntry[];
+}
+
 const App = () => {
   const [logs, setLogs] = useState<LogEntry[]>([]);
-  const [activeTab, setActiveTab] = useState<'overview' | 'audit' | 'playground' | 'safety'>('overview');
+  const [activeTab, setActiveTab] = useState<'overview' | 'audit' | 'playground' | 'safety' | 'portal'>('overview');
   const [chatMessage, setChatMessage] = useState('');
   const [chatResponse, setChatResponse] = useState<any>(null);
   const [chatLoading, setChatLoading] = useState(false);
   const [selectedLog, setSelectedLog] = useState<LogEntry | null>(null);
+  const [portalToken, setPortalToken] = useState(localStorage.getItem('vantage_portal_token') || '');
+  const [portal, setPortal] = useState<PortalData | null>(null);
+  const [portalError, setPortalError] = useState('');
 
   useEffect(() => {
     fetchLogs();
@@ -57,9 +68,34 @@ const App = () => {
     return () => clearInterval(interval);
   }, []);
 
+  // Admin token for deployments that protect /api (stored by the operator in localStorage)
+  const adminHeaders = (): Record<string, string> => {
+    const token = localStorage.getItem('vantage_admin_token');
+    return token ? { Authorization: `Bearer ${token}` } : {};
+  };
+
+  // Raw bodies are only returned (and access-logged) by the detail endpoint
+  const openLog = (id: number | string) => {
+    fetch(`/api/logs/${id}`, { headers: adminHeaders() })
+      .then(res => (res.ok ? res.json() : null))
+      .then(data => {
+        if (data) {
+          setActiveTab('audit');
+          setSelectedLog(data);
+        }
+      })
+      .catch(err => console.error(err));
+  };
+
+  // Deep links from alerts: /?log=<id>
+  useEffect(() => {
+    const logId = new URLSearchParams(window.location.search).get('log');
+    if (logId) openLog(logId);
+  }, []);
+
   const fetchLogs = async () => {
     try {
-      const res = await fetch('/api/logs?limit=100');
+      const res = await fetch('/api/logs?limit=100', { headers: adminHeaders() });
       const data = await res.json();
       setLogs(data || []);
     } catch (err) {
@@ -67,6 +103,27 @@ const App = () => {
     }
   };
 
+  // Self-service view for key holders, authenticated by their own JWT rather than the admin token
+  const fetchPortal = async () => {
+    localStorage.setItem('vantage_portal_token', portalToken);
+    const headers = { Authorization: `Bearer ${portalToken}` };
+    try {
+      const [usage, quota, logs] = await Promise.all(
+        ['/portal/usage', '/portal/quota', '/portal/logs'].map(path => fetch(path, { headers }))
+      );
+      if (!usage.ok) {
+        const err = await usage.json().catch(() => null);
+        setPortal(null);
+        setPortalError(err?.error || `Request failed (${usage.status})`);
+        return;
+      }
+      setPortalError('');
+      setPortal({ usage: await usage.json(), quota: quota.ok ? await quota.json() : null, logs: logs.ok ? await logs.json() : [] });
+    } catch (err) {
+      console.error(err);
+    }
+  };
+
   const stats = useMemo(() => {
     const totalTokens = logs.reduce((acc, log) => acc + log.tokens, 0);
     const blockedCount = logs.filter(l => l.is_blocked || l.status_code === 403).length;
@@ -131,6 +188,7 @@ const App = () => {
             { id: 'audit', icon: Database, label: 'Audit Vault' },
             { id: 'playground', icon: Terminal, label: 'Playground' },
             { id: 'safety', icon: ShieldCheck, label: 'Safety Policy' },
+            { id: 'portal', icon: KeyRound, label: 'My Usage' },
           ].map(item => (
             <button
               key={item.id}
@@ -281,7 +339,7 @@ const App = () => {
                   <p className="text-apple-gray-500 text-[14px]">Historical immutable sequence of all proxy interactions.</p>
                 </div>
                 <div className="flex gap-2">
-                   <button className="apple-button px-6 py-2 text-[13px]">Export JSON</button>
+                   <button onClick={() => window.open('/api/logs/export?format=json')} className="apple-button px-6 py-2 text-[13px]">Export JSON</button>
                    <button className="bg-apple-gray-900 border border-apple-gray-800 text-white px-4 py-2 rounded-full hover:bg-apple-gray-800 text-[13px]">Filter</button>
                 </div>
               </div>
@@ -301,7 +359,7 @@ const App = () => {
                     {logs.map(log => (
                       <tr 
                         key={log.id} 
-                        onClick={() => setSelectedLog(log)}
+                        onClick={() => openLog(log.id)}
                         className="border-b border-apple-gray-900/50 last:border-0 hover:bg-apple-gray-900/30 cursor-pointer transition-colors"
                       >
                         <td className="px-6 py-4">
@@ -521,6 +579,61 @@ const App = () => {
                 </div>
             </div>
           )}
+
+          {activeTab === 'portal' && (
+            <div className="animate-in fade-in duration-500 space-y-10">
+              <div className="space-y-1">
+                <h1 className="text-[28px] font-semibold tracking-tight">My Usage</h1>
+                <p className="text-apple-gray-500 text-[14px]">Your own usage, cost and quota, authenticated by your key. Prompts are shown redacted.</p>
+              </div>
+
+              <div className="apple-card p-6 flex gap-4">
+                <input
+                  type="password"
+                  value={portalToken}
+                  onChange={e => setPortalToken(e.target.value)}
+                  placeholder="Your API token (JWT)"
+                  className="flex-1 bg-apple-gray-950 border border-apple-gray-800 rounded-lg px-4 py-2 text-[13px] font-mono focus:outline-none focus:border-apple-blue"
+                />
+                <button onClick={fetchPortal} disabled={!portalToken} className="apple-button px-6 py-2 text-[13px]">Load</button>
+              </div>
+              {portalError && <p className="text-apple-red text-[13px]">{portalError}</p>}
+
+              {portal?.usage && (
+                <div className="grid grid-cols-4 gap-4">
+                  {[
+                    { label: 'Requests (30d)', value: portal.usage.totals.requests.toLocaleString() },
+                    { label: 'Tokens (30d)', value: portal.usage.totals.tokens.toLocaleString() },
+                    { label: 'Estimated Cost', value: `$${portal.usage.totals.estimated_cost.toFixed(2)}` },
+                    {
+                      label: portal.quota?.plan ? `Quota Today (${portal.quota.plan.name})` : 'Quota Today',
+                      value: !portal.quota?.quota ? 'n/a' : portal.quota.quota.unlimited ? 'Unlimited' : `${portal.quota.quota.remaining} / ${portal.quota.quota.limit}`,
+                    },
+                  ].map(kpi => (
+                    <div key={kpi.label} className="apple-card p-5">
+                      <p className="text-[13px] text-apple-gray-500 font-medium">{kpi.label}</p>
+                      <p className="text-[22px] font-semibold tracking-tight text-white">{kpi.value}</p>
+                    </div>
+                  ))}
+                </div>
+              )}
+
+              {portal && (
+                <div className="apple-card divide-y divide-apple-gray-800">
+                  {portal.logs.length === 0 && <p className="p-6 text-apple-gray-500 text-[13px]">No recent interactions.</p>}
+                  {portal.logs.map(log => (
+                    <div key={log.id} className="p-6 space-y-2">
+                      <div className="flex justify-between text-[12px] text-apple-gray-500">
+                        <span className="font-mono">{log.method} {log.path}</span>
+                        <span>{new Date(log.timestamp).toLocaleString()} · {log.status_code} · {log.tokens} tokens</span>
+                      </div>
+                      <p className="text-[13px] font-mono text-apple-gray-300 truncate">{log.request_body}</p>
+                    </div>
+                  ))}
+                </div>
+              )}
+            </div>
+          )}
         </div>
       </main>
 
@@ -546,7 +659,10 @@ const App = () => {
                     </div>
                     <div>
                        <p className="text-[10px] uppercase font-black text-apple-gray-600 mb-1">Safety Conf</p>
-                       <p className="text-[16px] font-bold text-apple-blue">{(selectedLog.safety_score * 100).toFixed(1)}%</p>
+                       <p className="text-[16px] font-bold text-apple-blue">{selectedLog.safety_score === null ? 'Not scored' : `${(selectedLog.safety_score * 100).toFixed(1)}%`}</p>
+                       {selectedLog.response_safety !== undefined && (
+                          <p className="text-[11px] font-bold text-apple-gray-500 mt-1">Response {(selectedLog.response_safety * 100).toFixed(1)}%</p>
+                       )}
                     </div>
                  </div>
 